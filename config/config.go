@@ -0,0 +1,48 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GetEnv gets an environment variable or returns a default value
+func GetEnv(key, defaultValue string) string {
+	if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// GetEnvInt gets an integer environment variable or returns a default value
+func GetEnvInt(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(GetEnv(key, "")); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// GetEnvFloat gets a float environment variable or returns a default value
+func GetEnvFloat(key string, defaultValue float64) float64 {
+	if value, err := strconv.ParseFloat(GetEnv(key, ""), 64); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// GetEnvBool gets a boolean environment variable or returns a default value
+func GetEnvBool(key string, defaultValue bool) bool {
+	if value, err := strconv.ParseBool(GetEnv(key, "")); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// GetEnvDuration gets a duration environment variable (e.g. "24h", "30m") or returns a default value
+func GetEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, err := time.ParseDuration(GetEnv(key, "")); err == nil {
+		return value
+	}
+	return defaultValue
+}
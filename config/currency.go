@@ -0,0 +1,61 @@
+package config
+
+import (
+	"sort"
+	"strings"
+)
+
+// DefaultCurrency is assumed for prices that do not name a currency
+const DefaultCurrency = "PHP"
+
+// currencyPointRates is how many suggested-value points one unit of each supported
+// currency is worth. PHP is the base (1 PHP = 1 point); the others are rough rates
+// that can be overridden with CURRENCY_RATE_<CODE>, e.g. CURRENCY_RATE_USD=57.5.
+var currencyPointRates = map[string]float64{
+	"PHP": 1,
+	"USD": 56,
+	"EUR": 61,
+	"JPY": 0.38,
+	"SGD": 42,
+}
+
+// SupportedCurrencies lists the accepted ISO 4217 codes in alphabetical order
+func SupportedCurrencies() []string {
+	codes := make([]string, 0, len(currencyPointRates))
+	for code := range currencyPointRates {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// NormalizeCurrency upper-cases a currency code, defaulting an empty one to
+// DefaultCurrency. The second result is false if the code is not supported.
+func NormalizeCurrency(code string) (string, bool) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return DefaultCurrency, true
+	}
+	_, ok := currencyPointRates[code]
+	return code, ok
+}
+
+// PointsPerUnit returns the point value of one unit of currency. Unknown codes
+// are treated as the default currency.
+func PointsPerUnit(currency string) float64 {
+	code, ok := NormalizeCurrency(currency)
+	if !ok {
+		code = DefaultCurrency
+	}
+	rate := GetEnvFloat("CURRENCY_RATE_"+code, currencyPointRates[code])
+	if rate <= 0 {
+		return currencyPointRates[code]
+	}
+	return rate
+}
+
+// InDefaultCurrency converts amount to the default currency using the point rates,
+// which are defined relative to it
+func InDefaultCurrency(amount float64, currency string) float64 {
+	return amount * PointsPerUnit(currency)
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DeliveryLimits is the logistics policy that both delivery creation and rider
+// claims enforce, so the two can't drift apart
+type DeliveryLimits struct {
+	// StandardMaxItems caps a standard batch: a single request, and everything a
+	// rider carries on standard deliveries at once (DELIVERY_STANDARD_MAX_ITEMS)
+	StandardMaxItems int `json:"standard_max_items"`
+	// ExpressMaxItems caps an express delivery (DELIVERY_EXPRESS_MAX_ITEMS)
+	ExpressMaxItems int `json:"express_max_items"`
+	// FragileKeywords in a product description mark it fragile (DELIVERY_FRAGILE_KEYWORDS)
+	FragileKeywords []string `json:"fragile_keywords"`
+	// FragileCategories mark every product in a matching category fragile (DELIVERY_FRAGILE_CATEGORIES)
+	FragileCategories []string `json:"fragile_categories"`
+}
+
+// DefaultDeliveryLimits is the policy used when nothing is configured
+func DefaultDeliveryLimits() DeliveryLimits {
+	return DeliveryLimits{
+		StandardMaxItems:  5,
+		ExpressMaxItems:   1,
+		FragileKeywords:   []string{"fragile", "breakable", "glass"},
+		FragileCategories: []string{"electronics", "fragile"},
+	}
+}
+
+// DeliveryLimitsFromEnv reads the delivery policy from the environment. Unlike the
+// other limits it reports bad values instead of quietly using the default, so a
+// typo stops the server at startup rather than changing what riders can carry.
+func DeliveryLimitsFromEnv() (DeliveryLimits, error) {
+	limits := DefaultDeliveryLimits()
+	for key, dst := range map[string]*int{
+		"DELIVERY_STANDARD_MAX_ITEMS": &limits.StandardMaxItems,
+		"DELIVERY_EXPRESS_MAX_ITEMS":  &limits.ExpressMaxItems,
+	} {
+		raw := GetEnv(key, "")
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			return DefaultDeliveryLimits(), fmt.Errorf("%s must be a positive integer, got %q", key, raw)
+		}
+		*dst = n
+	}
+	if limits.ExpressMaxItems > limits.StandardMaxItems {
+		return DefaultDeliveryLimits(), fmt.Errorf("DELIVERY_EXPRESS_MAX_ITEMS (%d) must not exceed DELIVERY_STANDARD_MAX_ITEMS (%d)", limits.ExpressMaxItems, limits.StandardMaxItems)
+	}
+	if raw := GetEnv("DELIVERY_FRAGILE_KEYWORDS", ""); raw != "" {
+		limits.FragileKeywords = splitList(raw)
+	}
+	if raw := GetEnv("DELIVERY_FRAGILE_CATEGORIES", ""); raw != "" {
+		limits.FragileCategories = splitList(raw)
+	}
+	return limits, nil
+}
+
+// CurrentDeliveryLimits is the delivery policy in effect. Startup already rejected
+// an invalid configuration, so any error here falls back to the defaults.
+func CurrentDeliveryLimits() DeliveryLimits {
+	limits, _ := DeliveryLimitsFromEnv()
+	return limits
+}
+
+// MaxItems is the item cap for a delivery type
+func (l DeliveryLimits) MaxItems(deliveryType string) int {
+	if deliveryType == "express" {
+		return l.ExpressMaxItems
+	}
+	return l.StandardMaxItems
+}
+
+// splitList turns a comma-separated value into trimmed, lower-cased, non-empty entries
+func splitList(raw string) []string {
+	var out []string
+	for _, part := range strings.Split(raw, ",") {
+		if part = strings.ToLower(strings.TrimSpace(part)); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// DeliveryAssignmentWindow is how long a rider handed a delivery has to accept it before
+// it goes back to the pending queue (DELIVERY_ASSIGNMENT_WINDOW)
+func DeliveryAssignmentWindow() time.Duration {
+	return GetEnvDuration("DELIVERY_ASSIGNMENT_WINDOW", 10*time.Minute)
+}
+
+// DeliveryStaleClaimAfter is how long a claimed delivery may go without being picked up
+// before it is flagged to admins (DELIVERY_STALE_CLAIM_AFTER)
+func DeliveryStaleClaimAfter() time.Duration {
+	return GetEnvDuration("DELIVERY_STALE_CLAIM_AFTER", 45*time.Minute)
+}
+
+// DeliveryStaleAutoRelease takes flagged deliveries off their rider and back to the
+// pending queue instead of only flagging them (DELIVERY_STALE_AUTO_RELEASE)
+func DeliveryStaleAutoRelease() bool {
+	return GetEnvBool("DELIVERY_STALE_AUTO_RELEASE", false)
+}
@@ -0,0 +1,31 @@
+package config
+
+import "testing"
+
+func TestDeliveryLimitsFromEnv(t *testing.T) {
+	t.Setenv("DELIVERY_STANDARD_MAX_ITEMS", "8")
+	t.Setenv("DELIVERY_FRAGILE_KEYWORDS", " Glass, ceramic ,,")
+	limits, err := DeliveryLimitsFromEnv()
+	if err != nil {
+		t.Fatalf("DeliveryLimitsFromEnv: %v", err)
+	}
+	if limits.StandardMaxItems != 8 || limits.ExpressMaxItems != 1 {
+		t.Errorf("item caps = %d/%d, want 8/1", limits.StandardMaxItems, limits.ExpressMaxItems)
+	}
+	if len(limits.FragileKeywords) != 2 || limits.FragileKeywords[0] != "glass" || limits.FragileKeywords[1] != "ceramic" {
+		t.Errorf("fragile keywords = %q", limits.FragileKeywords)
+	}
+
+	for _, bad := range [][2]string{
+		{"DELIVERY_STANDARD_MAX_ITEMS", "0"},
+		{"DELIVERY_STANDARD_MAX_ITEMS", "five"},
+		{"DELIVERY_EXPRESS_MAX_ITEMS", "9"},
+	} {
+		t.Setenv("DELIVERY_STANDARD_MAX_ITEMS", "")
+		t.Setenv("DELIVERY_EXPRESS_MAX_ITEMS", "")
+		t.Setenv(bad[0], bad[1])
+		if _, err := DeliveryLimitsFromEnv(); err == nil {
+			t.Errorf("%s=%s accepted, want error", bad[0], bad[1])
+		}
+	}
+}
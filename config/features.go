@@ -0,0 +1,90 @@
+package config
+
+// APIVersion is the version reported by /health and /api/meta
+const APIVersion = "1.0.0"
+
+// DefaultBodyLimit matches Fiber's default request body limit of 4 MB
+const DefaultBodyLimit = 4 * 1024 * 1024
+
+// DeliveryEnabled reports whether the delivery and rider routes are served (FEATURE_DELIVERY)
+func DeliveryEnabled() bool {
+	return GetEnvBool("FEATURE_DELIVERY", true)
+}
+
+// AIFeaturesEnabled reports whether the /api/ai routes are served (FEATURE_AI)
+func AIFeaturesEnabled() bool {
+	return GetEnvBool("FEATURE_AI", true)
+}
+
+// BodyLimit is the largest request body, uploads included, in bytes (BODY_LIMIT_BYTES)
+func BodyLimit() int {
+	if n := GetEnvInt("BODY_LIMIT_BYTES", DefaultBodyLimit); n > 0 {
+		return n
+	}
+	return DefaultBodyLimit
+}
+
+// What to do when an SSE subscriber's buffer is full (SSE_OVERFLOW_STRATEGY)
+const (
+	// SSEOverflowAdaptive drops the oldest queued event for transient types such as
+	// presence and typing, and asks the client to resync for trade and delivery events
+	SSEOverflowAdaptive = "adaptive"
+	// SSEOverflowDropOldest always makes room by discarding the oldest queued event
+	SSEOverflowDropOldest = "drop_oldest"
+	// SSEOverflowResync never discards queued events; the client is told to resync instead
+	SSEOverflowResync = "resync"
+)
+
+// DefaultSSEBufferSize is how many events an SSE subscriber may have queued
+const DefaultSSEBufferSize = 32
+
+// SSEBufferSize is the per-subscriber SSE event buffer (SSE_BUFFER_SIZE)
+func SSEBufferSize() int {
+	if n := GetEnvInt("SSE_BUFFER_SIZE", DefaultSSEBufferSize); n > 0 {
+		return n
+	}
+	return DefaultSSEBufferSize
+}
+
+// DefaultProductStreamSubscribers caps the open vote streams on one product
+const DefaultProductStreamSubscribers = 200
+
+// ProductStreamSubscribers is how many clients may watch one product's public vote stream
+// at once (PRODUCT_STREAM_MAX_SUBSCRIBERS)
+func ProductStreamSubscribers() int {
+	if n := GetEnvInt("PRODUCT_STREAM_MAX_SUBSCRIBERS", DefaultProductStreamSubscribers); n > 0 {
+		return n
+	}
+	return DefaultProductStreamSubscribers
+}
+
+// SSEOverflowStrategy is the configured overflow strategy, falling back to adaptive
+// for unknown values (SSE_OVERFLOW_STRATEGY)
+func SSEOverflowStrategy() string {
+	switch s := GetEnv("SSE_OVERFLOW_STRATEGY", SSEOverflowAdaptive); s {
+	case SSEOverflowAdaptive, SSEOverflowDropOldest, SSEOverflowResync:
+		return s
+	default:
+		return SSEOverflowAdaptive
+	}
+}
+
+// What DELETE /api/users/me does with the account (ACCOUNT_DELETION_MODE)
+const (
+	// AccountDeletionAnonymize blanks the user's personal data and hands their listings to
+	// a "Deleted user" placeholder, keeping trades, orders and chats readable for others
+	AccountDeletionAnonymize = "anonymize"
+	// AccountDeletionHard deletes the user row and everything that cascades from it
+	AccountDeletionHard = "hard"
+)
+
+// AccountDeletionMode is the configured deletion mode, falling back to anonymize for
+// unknown values (ACCOUNT_DELETION_MODE)
+func AccountDeletionMode() string {
+	switch m := GetEnv("ACCOUNT_DELETION_MODE", AccountDeletionAnonymize); m {
+	case AccountDeletionAnonymize, AccountDeletionHard:
+		return m
+	default:
+		return AccountDeletionAnonymize
+	}
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"strconv"
+	"time"
+)
+
+// MaxProductImages is the maximum number of images per listing (MAX_PRODUCT_IMAGES)
+func MaxProductImages() int {
+	return GetEnvInt("MAX_PRODUCT_IMAGES", 8)
+}
+
+// MaxActiveListings is the active listing cap for regular accounts (MAX_ACTIVE_LISTINGS)
+func MaxActiveListings() int {
+	return GetEnvInt("MAX_ACTIVE_LISTINGS", 20)
+}
+
+// MaxActiveListingsPremium is the active listing cap for premium sellers and
+// verified organizations (MAX_ACTIVE_LISTINGS_PREMIUM)
+func MaxActiveListingsPremium() int {
+	return GetEnvInt("MAX_ACTIVE_LISTINGS_PREMIUM", 100)
+}
+
+// DefaultPageSize is the page size used when a list request omits ?limit=, for lists without
+// a default of their own (DEFAULT_PAGE_SIZE)
+func DefaultPageSize() int {
+	return GetEnvInt("DEFAULT_PAGE_SIZE", 20)
+}
+
+// MinPayoutAmount is the smallest payout a seller may request (PAYOUT_MIN_AMOUNT)
+func MinPayoutAmount() float64 {
+	return GetEnvFloat("PAYOUT_MIN_AMOUNT", 100)
+}
+
+// MaxPageSize caps ?limit= on paginated list endpoints (MAX_PAGE_SIZE)
+func MaxPageSize() int {
+	return GetEnvInt("MAX_PAGE_SIZE", 100)
+}
+
+// MaxMessageLength caps chat and trade message content, in characters (MAX_MESSAGE_LENGTH)
+func MaxMessageLength() int {
+	return GetEnvInt("MAX_MESSAGE_LENGTH", 2000)
+}
+
+// MessagesPerMinute is how many messages a user may send per conversation or trade
+// per minute (MESSAGES_PER_MINUTE)
+func MessagesPerMinute() int {
+	return GetEnvInt("MESSAGES_PER_MINUTE", 20)
+}
+
+// ProfileAnalysesPerMinute is how many profile analyses a user may recompute per minute;
+// cached results are not counted (PROFILE_ANALYSES_PER_MINUTE)
+func ProfileAnalysesPerMinute() int {
+	return GetEnvInt("PROFILE_ANALYSES_PER_MINUTE", 5)
+}
+
+// DataExportsPerMinute is how many account data exports a user may download per minute
+// (DATA_EXPORTS_PER_MINUTE)
+func DataExportsPerMinute() int {
+	return GetEnvInt("DATA_EXPORTS_PER_MINUTE", 1)
+}
+
+// DefaultRequestTimeout bounds how long a request's database work may run
+const DefaultRequestTimeout = 15 * time.Second
+
+// RequestTimeout is the deadline given to each request's context (REQUEST_TIMEOUT).
+// Zero or a negative value turns the deadline off.
+func RequestTimeout() time.Duration {
+	return GetEnvDuration("REQUEST_TIMEOUT", DefaultRequestTimeout)
+}
+
+// PremiumPricePerDay is what one day of premium placement costs, in DefaultCurrency
+// (PREMIUM_PRICE_PER_DAY)
+func PremiumPricePerDay() float64 {
+	return GetEnvFloat("PREMIUM_PRICE_PER_DAY", 10)
+}
+
+// defaultPremiumDurationDays are the premium windows on sale when none are configured
+var defaultPremiumDurationDays = []int{7, 14, 30}
+
+// PremiumDurationDays lists the premium windows that can be bought, in days
+// (PREMIUM_DURATION_DAYS, comma-separated). Entries that are not positive integers are
+// ignored; if none remain the defaults apply.
+func PremiumDurationDays() []int {
+	var days []int
+	for _, part := range splitList(GetEnv("PREMIUM_DURATION_DAYS", "")) {
+		if n, err := strconv.Atoi(part); err == nil && n > 0 {
+			days = append(days, n)
+		}
+	}
+	if len(days) == 0 {
+		return append([]int(nil), defaultPremiumDurationDays...)
+	}
+	return days
+}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 )
@@ -25,17 +24,22 @@ func InitDatabase() error {
 	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true&loc=Local",
 		dbUser, dbPassword, dbHost, dbPort, dbName)
 
+	pool, err := LoadPoolSettings()
+	if err != nil {
+		return fmt.Errorf("invalid database pool settings: %v", err)
+	}
+
 	// Open database connection
-	var err error
 	DB, err = sql.Open("mysql", dsn)
 	if err != nil {
 		return fmt.Errorf("failed to open database: %v", err)
 	}
 
 	// Configure connection pool
-	DB.SetMaxOpenConns(25)
-	DB.SetMaxIdleConns(25)
-	DB.SetConnMaxLifetime(5 * time.Minute)
+	DB.SetMaxOpenConns(pool.MaxOpenConns)
+	DB.SetMaxIdleConns(pool.MaxIdleConns)
+	DB.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	log.Printf("Database pool: max_open=%d max_idle=%d conn_max_lifetime=%s", pool.MaxOpenConns, pool.MaxIdleConns, pool.ConnMaxLifetime)
 
 	// Test the connection
 	if err := DB.Ping(); err != nil {
@@ -101,7 +105,7 @@ func CreateTables() error {
 			image_url VARCHAR(500),
 			seller_id INT NOT NULL,
 			premium BOOLEAN DEFAULT FALSE,
-			status ENUM('available', 'sold', 'traded', 'locked') DEFAULT 'available',
+			status ENUM('available', 'sold', 'traded', 'locked', 'draft', 'removed') DEFAULT 'available',
 			allow_buying BOOLEAN DEFAULT TRUE,
 			barter_only BOOLEAN DEFAULT FALSE,
 			location VARCHAR(255),
@@ -170,8 +174,8 @@ func CreateTables() error {
 			id INT AUTO_INCREMENT PRIMARY KEY,
 			buyer_id INT NOT NULL,
 			seller_id INT NOT NULL,
-			target_product_id INT NOT NULL,
-			status ENUM('pending','accepted','declined','countered','active','completed','cancelled') DEFAULT 'pending',
+			target_product_id INT NULL,
+			status ` + tradeStatusEnum + ` DEFAULT 'pending',
 			message TEXT NULL,
 			offered_cash_amount DECIMAL(10,2) NULL,
 			buyer_completed BOOLEAN DEFAULT FALSE,
@@ -181,10 +185,10 @@ func CreateTables() error {
 			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
 			FOREIGN KEY (buyer_id) REFERENCES users(id) ON DELETE CASCADE,
 			FOREIGN KEY (seller_id) REFERENCES users(id) ON DELETE CASCADE,
-			FOREIGN KEY (target_product_id) REFERENCES products(id) ON DELETE CASCADE
+			FOREIGN KEY (target_product_id) REFERENCES products(id) ON DELETE SET NULL
 		)`,
 		// Backfill/alter for existing deployments (ignore errors if already applied)
-		`ALTER TABLE trades MODIFY status ENUM('pending','accepted','declined','countered','active','completed','cancelled') DEFAULT 'pending'`,
+		`ALTER TABLE trades MODIFY status ` + tradeStatusEnum + ` DEFAULT 'pending'`,
 		`ALTER TABLE trades ADD COLUMN IF NOT EXISTS buyer_completed BOOLEAN DEFAULT FALSE`,
 		`ALTER TABLE trades ADD COLUMN IF NOT EXISTS seller_completed BOOLEAN DEFAULT FALSE`,
 		`ALTER TABLE trades ADD COLUMN IF NOT EXISTS completed_at TIMESTAMP NULL`,
@@ -196,14 +200,255 @@ func CreateTables() error {
 		`ALTER TABLE trades ADD COLUMN IF NOT EXISTS seller_feedback TEXT NULL`,
 		`ALTER TABLE products ADD COLUMN IF NOT EXISTS image_url VARCHAR(500)`,
 		`ALTER TABLE products ADD COLUMN IF NOT EXISTS slug VARCHAR(255) NULL AFTER id`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS listing_limit_override INT NULL DEFAULT NULL`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS username VARCHAR(30) NULL DEFAULT NULL`,
+		`ALTER TABLE products ADD COLUMN IF NOT EXISTS counterfeit_confidence DECIMAL(3,2) DEFAULT NULL`,
+		`ALTER TABLE products ADD COLUMN IF NOT EXISTS counterfeit_flags JSON DEFAULT NULL`,
+		`ALTER TABLE products ADD COLUMN IF NOT EXISTS last_counterfeit_check_at TIMESTAMP NULL DEFAULT NULL`,
+		// Explicit listing currency; existing prices are PHP
+		`ALTER TABLE products ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'PHP'`,
+		`ALTER TABLE products ADD COLUMN IF NOT EXISTS geocode_status VARCHAR(16) NULL`,
+		// Denormalized COUNT(*) of wishlists, kept by the wishlist handlers; see reconcileWishlistCounts
+		`ALTER TABLE products ADD COLUMN IF NOT EXISTS wishlist_count INT NOT NULL DEFAULT 0`,
+		`ALTER TABLE products MODIFY status ENUM('available', 'sold', 'traded', 'locked', 'draft', 'removed') DEFAULT 'available'`,
+		// Near-duplicate listing detection
+		`ALTER TABLE products ADD COLUMN IF NOT EXISTS image_hashes JSON DEFAULT NULL`,
+		`ALTER TABLE products ADD COLUMN IF NOT EXISTS duplicate_of_product_id INT NULL DEFAULT NULL`,
+		`ALTER TABLE products ADD COLUMN IF NOT EXISTS duplicate_similarity DECIMAL(5,4) DEFAULT NULL`,
+		`ALTER TABLE products ADD COLUMN IF NOT EXISTS duplicate_flags JSON DEFAULT NULL`,
+		`ALTER TABLE trade_messages ADD COLUMN IF NOT EXISTS read_at TIMESTAMP NULL DEFAULT NULL`,
+		`ALTER TABLE trade_events ADD COLUMN IF NOT EXISTS details JSON NULL`,
+		`ALTER TABLE transactions ADD COLUMN IF NOT EXISTS fee DECIMAL(10,2) NOT NULL DEFAULT 0`,
+		`ALTER TABLE transactions ADD COLUMN IF NOT EXISTS net_amount DECIMAL(10,2) NULL`,
+		`ALTER TABLE trades ADD COLUMN IF NOT EXISTS platform_fee DECIMAL(10,2) NULL`,
+		`ALTER TABLE trades ADD COLUMN IF NOT EXISTS net_amount DECIMAL(10,2) NULL`,
+		`ALTER TABLE trades ADD COLUMN IF NOT EXISTS confirm_reminder_sent_at DATETIME NULL`,
+		`ALTER TABLE trades ADD COLUMN IF NOT EXISTS target_snapshot_title VARCHAR(255) NULL`,
+		`ALTER TABLE trades ADD COLUMN IF NOT EXISTS target_snapshot_price DECIMAL(10,2) NULL`,
+		`ALTER TABLE trades ADD COLUMN IF NOT EXISTS target_snapshot_image_url VARCHAR(500) NULL`,
+		// Who made the latest counter-offer, so only the other party can accept it
+		`ALTER TABLE trades ADD COLUMN IF NOT EXISTS countered_by INT NULL`,
+		`ALTER TABLE trade_items ADD COLUMN IF NOT EXISTS snapshot_title VARCHAR(255) NULL`,
+		`ALTER TABLE trade_items ADD COLUMN IF NOT EXISTS snapshot_price DECIMAL(10,2) NULL`,
+		`ALTER TABLE trade_items ADD COLUMN IF NOT EXISTS snapshot_image_url VARCHAR(500) NULL`,
+		// Admin actions taken on behalf of or against users
+		`CREATE TABLE IF NOT EXISTS admin_audit_log (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			admin_id INT NOT NULL,
+			action VARCHAR(64) NOT NULL,
+			target_user_id INT NULL,
+			details JSON NULL,
+			ip_address VARCHAR(64) NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (admin_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (target_user_id) REFERENCES users(id) ON DELETE SET NULL,
+			INDEX idx_admin_audit_admin (admin_id, created_at),
+			INDEX idx_admin_audit_target (target_user_id, created_at)
+		)`,
+		// Owner-initiated listing transfers awaiting the recipient's confirmation
+		`CREATE TABLE IF NOT EXISTS product_transfers (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			product_id INT NOT NULL,
+			from_user_id INT NOT NULL,
+			to_user_id INT NOT NULL,
+			token VARCHAR(64) NOT NULL,
+			status ENUM('pending','accepted','declined','cancelled','expired') NOT NULL DEFAULT 'pending',
+			expires_at TIMESTAMP NOT NULL,
+			responded_at TIMESTAMP NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE KEY uniq_product_transfers_token (token),
+			FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE,
+			FOREIGN KEY (from_user_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (to_user_id) REFERENCES users(id) ON DELETE CASCADE,
+			INDEX idx_product_transfers_product (product_id, status),
+			INDEX idx_product_transfers_to_user (to_user_id, status)
+		)`,
+		// Admin-tuned counterfeit detection thresholds (single row, id = 1)
+		`CREATE TABLE IF NOT EXISTS counterfeit_settings (
+			id TINYINT PRIMARY KEY,
+			settings JSON NOT NULL,
+			updated_by INT NULL,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			FOREIGN KEY (updated_by) REFERENCES users(id) ON DELETE SET NULL
+		)`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS last_seen_at TIMESTAMP NULL`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS auto_arrange_delivery BOOLEAN NOT NULL DEFAULT FALSE`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS vacation_until DATETIME NULL`,
+		// Conversations a participant silenced notifications for
+		`CREATE TABLE IF NOT EXISTS conversation_mutes (
+			conversation_id INT NOT NULL,
+			user_id INT NOT NULL,
+			muted_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (conversation_id, user_id),
+			FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+		// Last profile analysis per user, served until it is older than PROFILE_ANALYSIS_TTL
+		`CREATE TABLE IF NOT EXISTS profile_analysis_cache (
+			user_id INT PRIMARY KEY,
+			analysis JSON NOT NULL,
+			computed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+		// Seller withdrawal requests; see services.SellerPayoutBalance for the balance they draw on
+		`CREATE TABLE IF NOT EXISTS payouts (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			user_id INT NOT NULL,
+			amount DECIMAL(10,2) NOT NULL,
+			status ENUM('pending', 'approved', 'paid', 'rejected') NOT NULL DEFAULT 'pending',
+			note VARCHAR(255) NULL,
+			admin_note VARCHAR(255) NULL,
+			reviewed_by INT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			approved_at TIMESTAMP NULL,
+			paid_at TIMESTAMP NULL,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (reviewed_by) REFERENCES users(id) ON DELETE SET NULL,
+			INDEX idx_payouts_user (user_id, created_at),
+			INDEX idx_payouts_status (status)
+		)`,
+		// Accounts whose email only differed in case from an older account; see normalizeUserEmails
+		`CREATE TABLE IF NOT EXISTS email_normalization_conflicts (
+			user_id INT PRIMARY KEY,
+			original_email VARCHAR(255) NOT NULL,
+			normalized_email VARCHAR(255) NOT NULL,
+			kept_user_id INT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		// Emails are stored lowercased; the unique index on this column holds regardless of collation
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS email_normalized VARCHAR(255) AS (LOWER(TRIM(email))) STORED`,
+		// Chat response metrics kept by updateUserResponseMetrics (first added in migration 015)
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS response_score DECIMAL(3,2) NULL`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS average_response_time_hours DECIMAL(10,2) NULL`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS response_rate DECIMAL(3,2) NULL`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS response_rating VARCHAR(20) NULL`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS last_response_at TIMESTAMP NULL`,
+		// Admin broadcasts, kept so users who were offline can still read them
+		`CREATE TABLE IF NOT EXISTS announcements (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			title VARCHAR(120) NOT NULL,
+			message VARCHAR(500) NOT NULL,
+			critical BOOLEAN NOT NULL DEFAULT FALSE,
+			created_by INT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE SET NULL,
+			INDEX idx_announcements_created (created_at)
+		)`,
+		// Opt-out of non-critical announcements
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS mute_announcements BOOLEAN NOT NULL DEFAULT FALSE`,
+		// Slugs a product had before it was given a new one; they still resolve to it
+		`CREATE TABLE IF NOT EXISTS product_slug_history (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			product_id INT NOT NULL,
+			slug VARCHAR(255) NOT NULL UNIQUE,
+			retired_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE,
+			INDEX idx_product_slug_history_product (product_id)
+		)`,
+		// Cash price negotiation on buyable listings, separate from barter trades
+		`CREATE TABLE IF NOT EXISTS price_offers (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			product_id INT NOT NULL,
+			buyer_id INT NOT NULL,
+			seller_id INT NOT NULL,
+			amount DECIMAL(10,2) NOT NULL,
+			proposed_by ENUM('buyer', 'seller') NOT NULL DEFAULT 'buyer',
+			status ENUM('pending', 'accepted', 'declined', 'withdrawn', 'ordered', 'closed') NOT NULL DEFAULT 'pending',
+			message VARCHAR(500) NULL,
+			order_id INT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE,
+			FOREIGN KEY (buyer_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (seller_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE SET NULL,
+			INDEX idx_price_offers_product (product_id, status),
+			INDEX idx_price_offers_buyer (buyer_id, status)
+		)`,
+		// Price agreed through a price offer; NULL means the order was at the list price
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS agreed_price DECIMAL(10,2) NULL`,
+		// Lowercased copies for typeahead: a prefix LIKE on these can use the index
+		`ALTER TABLE products ADD COLUMN IF NOT EXISTS title_normalized VARCHAR(255) AS (LOWER(TRIM(title))) STORED`,
+		`ALTER TABLE products ADD COLUMN IF NOT EXISTS category_normalized VARCHAR(100) AS (LOWER(TRIM(category))) STORED`,
+		`ALTER TABLE products ADD COLUMN IF NOT EXISTS location_normalized VARCHAR(255) AS (LOWER(TRIM(location))) STORED`,
+		`CREATE INDEX IF NOT EXISTS idx_products_title_normalized ON products(title_normalized)`,
+		`CREATE INDEX IF NOT EXISTS idx_products_category_normalized ON products(category_normalized)`,
+		`CREATE INDEX IF NOT EXISTS idx_products_location_normalized ON products(location_normalized)`,
+		// Per-user token revocations checked by the auth middleware. No foreign key: the
+		// entry must outlive a hard-deleted user.
+		`CREATE TABLE IF NOT EXISTS token_blacklist (
+			user_id INT PRIMARY KEY,
+			revoked_before TIMESTAMP NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		// Profile images were added on first upload; account deletion blanks them
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS profile_picture VARCHAR(255) NULL`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS background_image VARCHAR(255) NULL`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS background_position VARCHAR(50) NULL`,
+		// Optimistic-concurrency counter, bumped by every write that changes a listing's state
+		`ALTER TABLE products ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1`,
+		// What was paid for each premium window
+		`ALTER TABLE premium_listings ADD COLUMN IF NOT EXISTS amount DECIMAL(10,2) NOT NULL DEFAULT 0`,
+		// Amounts sellers pay out of their earnings; kept when the listing is deleted
+		`CREATE TABLE IF NOT EXISTS seller_charges (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			user_id INT NOT NULL,
+			kind VARCHAR(30) NOT NULL,
+			amount DECIMAL(10,2) NOT NULL,
+			product_id INT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+			INDEX idx_seller_charges_user (user_id, created_at)
+		)`,
+		`ALTER TABLE premium_listings ADD COLUMN IF NOT EXISTS charge_id INT NULL`,
+		// A rider handed a delivery (rather than claiming it) must accept it before this
+		// time; riders.reject_count tallies the assignments they turned down or let lapse
+		`ALTER TABLE deliveries ADD COLUMN IF NOT EXISTS assignment_expires_at TIMESTAMP NULL`,
+		`ALTER TABLE riders ADD COLUMN IF NOT EXISTS reject_count INT NOT NULL DEFAULT 0`,
+		// Claimed deliveries that never reached pickup in time: when the job flagged them,
+		// and a record of every flag and whether the rider was taken off
+		`ALTER TABLE deliveries ADD COLUMN IF NOT EXISTS stale_flagged_at TIMESTAMP NULL`,
+		`CREATE TABLE IF NOT EXISTS delivery_stale_events (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			delivery_id INT NOT NULL,
+			rider_id INT NULL,
+			claimed_at TIMESTAMP NULL,
+			released BOOLEAN NOT NULL DEFAULT FALSE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (delivery_id) REFERENCES deliveries(id) ON DELETE CASCADE,
+			FOREIGN KEY (rider_id) REFERENCES riders(id) ON DELETE SET NULL,
+			INDEX idx_delivery_stale_events_delivery (delivery_id, created_at)
+		)`,
+		// Official list of colleges; users.department holds the code of the chosen one
+		`CREATE TABLE IF NOT EXISTS departments (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			code VARCHAR(20) NOT NULL UNIQUE,
+			name VARCHAR(150) NOT NULL UNIQUE,
+			is_active BOOLEAN NOT NULL DEFAULT TRUE
+		)`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS department_id INT NULL`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS latitude DECIMAL(10,8) NULL`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS longitude DECIMAL(11,8) NULL`,
+		`CREATE TABLE IF NOT EXISTS meetup_spots (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			city VARCHAR(100) NOT NULL,
+			name VARCHAR(255) NOT NULL,
+			latitude DECIMAL(10,8) NOT NULL,
+			longitude DECIMAL(11,8) NOT NULL,
+			is_active BOOLEAN NOT NULL DEFAULT TRUE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE KEY uniq_meetup_spots_city_name (city, name),
+			INDEX idx_meetup_spots_city (city)
+		)`,
 		`CREATE TABLE IF NOT EXISTS trade_items (
 			id INT AUTO_INCREMENT PRIMARY KEY,
 			trade_id INT NOT NULL,
-			product_id INT NOT NULL,
+			product_id INT NULL,
 			offered_by ENUM('buyer','seller') NOT NULL,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			FOREIGN KEY (trade_id) REFERENCES trades(id) ON DELETE CASCADE,
-			FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+			FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE SET NULL
 		)`,
 		`CREATE TABLE IF NOT EXISTS trade_messages (
 			id INT AUTO_INCREMENT PRIMARY KEY,
@@ -235,6 +480,8 @@ func CreateTables() error {
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
 		)`,
+		`ALTER TABLE notifications ADD COLUMN IF NOT EXISTS reference_type VARCHAR(32) NULL`,
+		`ALTER TABLE notifications ADD COLUMN IF NOT EXISTS reference_id INT NULL`,
 		`CREATE TABLE IF NOT EXISTS comments (
 			id INT AUTO_INCREMENT PRIMARY KEY,
 			product_id INT NOT NULL,
@@ -245,6 +492,10 @@ func CreateTables() error {
 			FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE,
 			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
 		)`,
+		// Soft-deletion of comments by moderators
+		`ALTER TABLE comments ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP NULL`,
+		`ALTER TABLE comments ADD COLUMN IF NOT EXISTS deleted_by INT NULL`,
+		`ALTER TABLE comments ADD COLUMN IF NOT EXISTS moderation_reason VARCHAR(255) NULL`,
 		`CREATE TABLE IF NOT EXISTS wishlists (
 			id INT AUTO_INCREMENT PRIMARY KEY,
 			user_id INT NOT NULL,
@@ -269,6 +520,17 @@ func CreateTables() error {
 			INDEX idx_created_at (created_at),
 			INDEX idx_deleted_at (deleted_at)
 		)`,
+		// Watchers told that a wishlisted or saved product is available again
+		`CREATE TABLE IF NOT EXISTS back_in_stock_alerts (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			user_id INT NOT NULL,
+			product_id INT NOT NULL,
+			alerted_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE,
+			UNIQUE KEY uniq_back_in_stock (user_id, product_id),
+			INDEX idx_back_in_stock_user (user_id, alerted_at)
+		)`,
 		`CREATE TABLE IF NOT EXISTS product_votes (
 			id INT AUTO_INCREMENT PRIMARY KEY,
 			product_id INT NOT NULL,
@@ -278,6 +540,20 @@ func CreateTables() error {
 			FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE,
 			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
 			UNIQUE KEY uniq_product_user_vote (product_id, user_id)
+		)`,
+		// Product status change audit trail
+		`CREATE TABLE IF NOT EXISTS product_status_history (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			product_id INT NOT NULL,
+			from_status VARCHAR(32) NULL,
+			to_status VARCHAR(32) NOT NULL,
+			actor_id INT NULL,
+			reason VARCHAR(255) NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE,
+			FOREIGN KEY (actor_id) REFERENCES users(id) ON DELETE SET NULL,
+			INDEX idx_product_status_history_product (product_id, created_at)
+		)`,
 		`CREATE TABLE IF NOT EXISTS riders (
 			id INT AUTO_INCREMENT PRIMARY KEY,
 			user_id INT NOT NULL,
@@ -339,6 +615,19 @@ func CreateTables() error {
 			INDEX idx_delivery_items_delivery (delivery_id),
 			INDEX idx_delivery_items_product (product_id)
 		)`,
+		// Mirrors trade_id while the delivery is live so a unique index allows one non-cancelled delivery per trade
+		`ALTER TABLE deliveries ADD COLUMN IF NOT EXISTS active_trade_id INT AS (IF(status = 'cancelled', NULL, trade_id)) STORED`,
+		// Breadcrumbs riders report through UpdateDeliveryStatus, pruned after RIDER_TRAIL_RETENTION
+		`CREATE TABLE IF NOT EXISTS rider_location_history (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			delivery_id INT NOT NULL,
+			rider_id INT NOT NULL,
+			latitude DECIMAL(10,8) NOT NULL,
+			longitude DECIMAL(11,8) NOT NULL,
+			recorded_at TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+			FOREIGN KEY (delivery_id) REFERENCES deliveries(id) ON DELETE CASCADE,
+			INDEX idx_rider_location_delivery (delivery_id, recorded_at)
+		)`,
 	}
 
 	for _, query := range queries {
@@ -347,6 +636,14 @@ func CreateTables() error {
 		}
 	}
 
+	// Trades keep their snapshots when a listing is deleted
+	if err := keepTradesOnProductDelete(); err != nil {
+		return err
+	}
+
+	// Must run before the unique index on email_normalized can be created
+	normalizeUserEmails()
+
 	// Create indexes
 	indexQueries := []string{
 		"CREATE INDEX IF NOT EXISTS idx_products_seller ON products(seller_id)",
@@ -368,13 +665,21 @@ func CreateTables() error {
 		"CREATE INDEX IF NOT EXISTS idx_trade_items_product ON trade_items(product_id)",
 		"CREATE INDEX IF NOT EXISTS idx_trade_messages_trade ON trade_messages(trade_id)",
 		"CREATE INDEX IF NOT EXISTS idx_trade_messages_sender ON trade_messages(sender_id)",
+		"CREATE INDEX IF NOT EXISTS idx_trade_messages_unread ON trade_messages(trade_id, read_at)",
+		"CREATE INDEX IF NOT EXISTS idx_products_duplicate_of ON products(duplicate_of_product_id)",
 		"CREATE INDEX IF NOT EXISTS idx_notifications_user ON notifications(user_id)",
 		"CREATE INDEX IF NOT EXISTS idx_notifications_read ON notifications(is_read)",
 		"CREATE INDEX IF NOT EXISTS idx_notifications_type ON notifications(type)",
+		"CREATE INDEX IF NOT EXISTS idx_notifications_reference ON notifications(user_id, reference_type, reference_id)",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username ON users(username)",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_normalized ON users(email_normalized)",
+		"CREATE INDEX IF NOT EXISTS idx_users_department_id ON users(department_id)",
 		"CREATE INDEX IF NOT EXISTS idx_comments_product ON comments(product_id)",
 		"CREATE INDEX IF NOT EXISTS idx_comments_user ON comments(user_id)",
+		"CREATE INDEX IF NOT EXISTS idx_comments_product_deleted ON comments(product_id, deleted_at)",
 		"CREATE INDEX IF NOT EXISTS idx_wishlists_user ON wishlists(user_id)",
 		"CREATE INDEX IF NOT EXISTS idx_wishlists_product ON wishlists(product_id)",
+		"CREATE INDEX IF NOT EXISTS idx_products_wishlist_count ON products(status, wishlist_count)",
 		"CREATE INDEX IF NOT EXISTS idx_riders_user ON riders(user_id)",
 		"CREATE INDEX IF NOT EXISTS idx_riders_active ON riders(is_active)",
 		"CREATE INDEX IF NOT EXISTS idx_deliveries_user ON deliveries(user_id)",
@@ -389,13 +694,184 @@ func CreateTables() error {
 		}
 	}
 
+	// Delivery handlers rely on this index to refuse a second live delivery for a trade
+	if err := ensureActiveTradeDeliveryIndex(); err != nil {
+		return err
+	}
+
 	// Ensure users table has all required columns (for existing databases)
 	ensureUserColumns()
 
+	// Seed default meetup spots used for trade meetup suggestions
+	seedMeetupSpots()
+
+	// Seed the department list and link free-text departments entered before it existed
+	seedDepartments()
+	mapUserDepartments()
+
+	// Backfills wishlist_count when the column is new, and repairs drift from wishlist rows
+	// removed by cascade (a deleted user) rather than through the handlers
+	reconcileWishlistCounts()
+
+	// Saves removed under non-strict SQL mode hold the zero date; the handlers treat only
+	// NULL as still saved (migration 054)
+	clearZeroSavedProductDeletes()
+
+	// Refuse to start if handlers could write a trade status the column cannot hold
+	if err := assertTradeStatusEnum(); err != nil {
+		return err
+	}
+
 	log.Println("Database tables and indexes created successfully")
 	return nil
 }
 
+// tradeProductKeys are the trade columns that point at a listing. Deleting the listing
+// clears them instead of deleting the trade, whose snapshot columns still describe it.
+var tradeProductKeys = []struct{ table, column, constraint string }{
+	{"trades", "target_product_id", "fk_trades_target_product"},
+	{"trade_items", "product_id", "fk_trade_items_product"},
+}
+
+// keepTradesOnProductDelete makes the trade product columns nullable and swaps foreign keys
+// created with ON DELETE CASCADE for ON DELETE SET NULL
+func keepTradesOnProductDelete() error {
+	for _, k := range tradeProductKeys {
+		var name string
+		err := DB.QueryRow(`
+			SELECT rc.CONSTRAINT_NAME
+			FROM information_schema.REFERENTIAL_CONSTRAINTS rc
+			JOIN information_schema.KEY_COLUMN_USAGE kcu
+			  ON kcu.CONSTRAINT_SCHEMA = rc.CONSTRAINT_SCHEMA AND kcu.CONSTRAINT_NAME = rc.CONSTRAINT_NAME
+			 AND kcu.TABLE_NAME = rc.TABLE_NAME
+			WHERE rc.CONSTRAINT_SCHEMA = DATABASE() AND rc.TABLE_NAME = ? AND kcu.COLUMN_NAME = ?
+			  AND rc.REFERENCED_TABLE_NAME = 'products' AND rc.DELETE_RULE = 'CASCADE'`, k.table, k.column).Scan(&name)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to inspect %s.%s foreign key: %v", k.table, k.column, err)
+		}
+		// Dropping and re-adding a key in one ALTER is not allowed when the table is copied
+		queries := []string{
+			fmt.Sprintf("ALTER TABLE %s DROP FOREIGN KEY `%s`, MODIFY %s INT NULL", k.table, name, k.column),
+			fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s FOREIGN KEY (%s) REFERENCES products(id) ON DELETE SET NULL", k.table, k.constraint, k.column),
+		}
+		for _, query := range queries {
+			if _, err := DB.Exec(query); err != nil {
+				return fmt.Errorf("failed to relax %s.%s foreign key: %v", k.table, k.column, err)
+			}
+		}
+		log.Printf("%s.%s now keeps the row when the product is deleted", k.table, k.column)
+	}
+	return nil
+}
+
+// normalizeUserEmails lowercases stored emails. When two accounts differ only in the case
+// of their email, the oldest keeps the address; the others get a placeholder address and
+// are listed in email_normalization_conflicts for an admin to merge or follow up.
+func normalizeUserEmails() {
+	res, err := DB.Exec(`
+		INSERT IGNORE INTO email_normalization_conflicts (user_id, original_email, normalized_email, kept_user_id)
+		SELECT u.id, u.email, k.normalized, k.kept
+		FROM users u
+		JOIN (
+			SELECT LOWER(TRIM(email)) AS normalized, MIN(id) AS kept
+			FROM users GROUP BY LOWER(TRIM(email)) HAVING COUNT(*) > 1
+		) k ON LOWER(TRIM(u.email)) = k.normalized
+		WHERE u.id <> k.kept`)
+	if err != nil {
+		log.Printf("Warning: failed to check for duplicate emails: %v", err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		log.Printf("Warning: %d account(s) share an email with an older account; see email_normalization_conflicts", n)
+	}
+	if _, err := DB.Exec(`
+		UPDATE users u JOIN email_normalization_conflicts c ON c.user_id = u.id
+		SET u.email = CONCAT('duplicate-', u.id, '+', c.normalized_email)
+		WHERE u.email = c.original_email`); err != nil {
+		log.Printf("Warning: failed to set aside duplicate emails: %v", err)
+		return
+	}
+	if _, err := DB.Exec("UPDATE users SET email = LOWER(TRIM(email)) WHERE BINARY email <> BINARY LOWER(TRIM(email))"); err != nil {
+		log.Printf("Warning: failed to normalize emails: %v", err)
+	}
+}
+
+// reconcileWishlistCounts sets products.wishlist_count to the real number of wishlist
+// entries wherever the two disagree
+func reconcileWishlistCounts() {
+	res, err := DB.Exec(`
+		UPDATE products p
+		LEFT JOIN (SELECT product_id, COUNT(*) AS n FROM wishlists GROUP BY product_id) w ON w.product_id = p.id
+		SET p.wishlist_count = COALESCE(w.n, 0)
+		WHERE p.wishlist_count <> COALESCE(w.n, 0)`)
+	if err != nil {
+		log.Printf("Warning: failed to reconcile wishlist counts: %v", err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		log.Printf("Reconciled wishlist_count on %d product(s)", n)
+	}
+}
+
+// clearZeroSavedProductDeletes turns saved_products.deleted_at zero dates back into NULL,
+// first making the column nullable on databases created before it was. Strict mode rejects
+// the zero date as a literal, so the rows are matched by range: no real TIMESTAMP is
+// before 1970-01-01 00:00:01.
+func clearZeroSavedProductDeletes() {
+	var nullable string
+	err := DB.QueryRow(`SELECT IS_NULLABLE FROM information_schema.COLUMNS
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'saved_products' AND COLUMN_NAME = 'deleted_at'`).Scan(&nullable)
+	if err != nil {
+		log.Printf("Warning: failed to inspect saved_products.deleted_at: %v", err)
+		return
+	}
+	if nullable == "NO" {
+		if _, err := DB.Exec("ALTER TABLE saved_products MODIFY deleted_at TIMESTAMP NULL DEFAULT NULL"); err != nil {
+			log.Printf("Warning: failed to make saved_products.deleted_at nullable: %v", err)
+			return
+		}
+	}
+	res, err := DB.Exec("UPDATE saved_products SET deleted_at = NULL WHERE deleted_at < '1970-01-02'")
+	if err != nil {
+		log.Printf("Warning: failed to clear zero saved_products.deleted_at: %v", err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		log.Printf("Restored %d saved product(s) stored with a zero deleted_at", n)
+	}
+}
+
+// ensureActiveTradeDeliveryIndex creates the unique index allowing one live delivery per
+// trade. Duplicates from before the index existed are cancelled first, keeping the
+// delivery furthest along (the oldest on a tie), as in migration 061.
+func ensureActiveTradeDeliveryIndex() error {
+	res, err := DB.Exec(`
+		UPDATE deliveries SET status = 'cancelled'
+		WHERE id IN (
+			SELECT id FROM (
+				SELECT DISTINCT d.id
+				FROM deliveries d
+				JOIN deliveries k ON k.trade_id = d.trade_id AND k.id <> d.id AND k.status <> 'cancelled'
+				WHERE d.status <> 'cancelled'
+					AND (FIELD(k.status, 'pending', 'claimed', 'picked_up', 'in_transit', 'delivered') > FIELD(d.status, 'pending', 'claimed', 'picked_up', 'in_transit', 'delivered')
+						OR (k.status = d.status AND k.id < d.id))
+			) AS superseded
+		)`)
+	if err != nil {
+		return fmt.Errorf("failed to cancel duplicate trade deliveries: %v", err)
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		log.Printf("Warning: cancelled %d duplicate delivery(ies) so each trade has one live delivery", n)
+	}
+	if _, err := DB.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_deliveries_active_trade ON deliveries(active_trade_id)"); err != nil {
+		return fmt.Errorf("failed to create idx_deliveries_active_trade: %v", err)
+	}
+	return nil
+}
+
 // ensureUserColumns adds missing columns to the users table if they don't exist
 func ensureUserColumns() {
 	columns := []struct {
@@ -0,0 +1,122 @@
+package database
+
+import (
+	"log"
+	"strings"
+	"unicode"
+)
+
+// defaultDepartments are the WMSU colleges students pick from at signup. Aliases are the
+// free-text spellings seen before the list existed; they are only used to map old rows.
+var defaultDepartments = []struct {
+	Code    string
+	Name    string
+	Aliases []string
+}{
+	{"CA", "College of Agriculture", []string{"Agriculture", "Agri"}},
+	{"CAIS", "College of Asian and Islamic Studies", []string{"Asian and Islamic Studies", "Islamic Studies"}},
+	{"CARCH", "College of Architecture", []string{"Architecture", "Archi", "CArch"}},
+	{"CCJE", "College of Criminal Justice Education", []string{"Criminology", "Criminal Justice", "Crim"}},
+	{"CCS", "College of Computing Studies", []string{"Computing Studies", "Computer Science", "Comp Sci", "CompSci", "College of Computing", "BSCS", "BSIT", "IT", "CS"}},
+	{"COE", "College of Engineering", []string{"Engineering", "Engg", "CET"}},
+	{"CFES", "College of Forestry and Environmental Studies", []string{"Forestry", "Environmental Studies"}},
+	{"CHE", "College of Home Economics", []string{"Home Economics", "Home Econ"}},
+	{"CL", "College of Law", []string{"Law"}},
+	{"CLA", "College of Liberal Arts", []string{"Liberal Arts", "Arts"}},
+	{"CM", "College of Medicine", []string{"Medicine", "Med"}},
+	{"CN", "College of Nursing", []string{"Nursing", "BSN"}},
+	{"CPADS", "College of Public Administration and Development Studies", []string{"Public Administration", "Pub Ad", "PubAd"}},
+	{"CSM", "College of Science and Mathematics", []string{"Science and Mathematics", "Science and Math", "Math", "Mathematics"}},
+	{"CSSPE", "College of Sports Science and Physical Education", []string{"Physical Education", "PE", "Sports Science", "CPERS"}},
+	{"CSWCD", "College of Social Work and Community Development", []string{"Social Work", "Community Development"}},
+	{"CTE", "College of Teacher Education", []string{"Teacher Education", "Education", "Educ", "CED"}},
+}
+
+// departmentKey folds a department spelling for comparison: case, spacing and punctuation
+// are ignored, so "Comp. Sci" and "comp sci" match
+func departmentKey(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// seedDepartments inserts the default departments; existing rows are left untouched
+func seedDepartments() {
+	for _, d := range defaultDepartments {
+		if _, err := DB.Exec("INSERT IGNORE INTO departments (code, name) VALUES (?, ?)", d.Code, d.Name); err != nil {
+			log.Printf("Warning: failed to seed department %s: %v", d.Code, err)
+		}
+	}
+}
+
+// mapUserDepartments links users who only have a free-text department to the matching
+// department row and rewrites the text to its code. Values that match nothing are kept
+// as they are so an admin can fix them by hand.
+func mapUserDepartments() {
+	ids := map[string]int{}
+	codes := map[int]string{}
+	rows, err := DB.Query("SELECT id, code, name FROM departments")
+	if err != nil {
+		log.Printf("Warning: failed to load departments: %v", err)
+		return
+	}
+	for rows.Next() {
+		var id int
+		var code, name string
+		if err := rows.Scan(&id, &code, &name); err != nil {
+			continue
+		}
+		ids[departmentKey(code)] = id
+		ids[departmentKey(name)] = id
+		codes[id] = code
+	}
+	rows.Close()
+	for _, d := range defaultDepartments {
+		id, ok := ids[departmentKey(d.Code)]
+		if !ok {
+			continue
+		}
+		for _, alias := range d.Aliases {
+			if _, taken := ids[departmentKey(alias)]; !taken {
+				ids[departmentKey(alias)] = id
+			}
+		}
+	}
+
+	rows, err = DB.Query("SELECT DISTINCT department FROM users WHERE department_id IS NULL AND department IS NOT NULL AND department <> ''")
+	if err != nil {
+		log.Printf("Warning: failed to load free-text departments: %v", err)
+		return
+	}
+	var values []string
+	for rows.Next() {
+		var v string
+		if rows.Scan(&v) == nil {
+			values = append(values, v)
+		}
+	}
+	rows.Close()
+
+	mapped, unmatched := 0, 0
+	for _, v := range values {
+		id, ok := ids[departmentKey(v)]
+		if !ok {
+			unmatched++
+			continue
+		}
+		res, err := DB.Exec("UPDATE users SET department_id = ?, department = ? WHERE department_id IS NULL AND department = ?", id, codes[id], v)
+		if err != nil {
+			log.Printf("Warning: failed to map department %q: %v", v, err)
+			continue
+		}
+		n, _ := res.RowsAffected()
+		mapped += int(n)
+	}
+	if mapped > 0 || unmatched > 0 {
+		log.Printf("Mapped %d user(s) to a department; %d free-text value(s) matched no department", mapped, unmatched)
+	}
+}
@@ -0,0 +1,28 @@
+package database
+
+import "testing"
+
+// Each code, name and alias must point at exactly one department, or mapping old rows
+// would depend on list order
+func TestDepartmentSpellingsAreUnambiguous(t *testing.T) {
+	owner := map[string]string{}
+	for _, d := range defaultDepartments {
+		spellings := append([]string{d.Code, d.Name}, d.Aliases...)
+		for _, s := range spellings {
+			key := departmentKey(s)
+			if prev, ok := owner[key]; ok && prev != d.Code {
+				t.Errorf("%q is claimed by both %s and %s", s, prev, d.Code)
+			}
+			owner[key] = d.Code
+		}
+	}
+}
+
+func TestDepartmentKeyIgnoresCaseAndPunctuation(t *testing.T) {
+	if departmentKey(" Comp. Sci ") != departmentKey("comp sci") {
+		t.Error("expected spacing, punctuation and case to be ignored")
+	}
+	if departmentKey("CCS") == departmentKey("CS") {
+		t.Error("distinct codes must not fold together")
+	}
+}
@@ -0,0 +1,45 @@
+package database
+
+import "log"
+
+// defaultMeetupSpots are well-known public places suggested for in-person trades
+var defaultMeetupSpots = []struct {
+	City      string
+	Name      string
+	Latitude  float64
+	Longitude float64
+}{
+	{"Manila", "SM Mall of Asia", 14.5350, 120.9822},
+	{"Manila", "Robinsons Place", 14.5764, 120.9836},
+	{"Manila", "Greenbelt Mall", 14.5526, 121.0216},
+	{"Manila", "Ayala Center", 14.5510, 121.0244},
+	{"Quezon City", "SM North EDSA", 14.6565, 121.0290},
+	{"Quezon City", "Trinoma Mall", 14.6536, 121.0334},
+	{"Quezon City", "Eastwood City", 14.6091, 121.0800},
+	{"Quezon City", "UP Diliman", 14.6538, 121.0685},
+	{"Makati", "Glorietta", 14.5509, 121.0254},
+	{"Makati", "Power Plant Mall", 14.5653, 121.0365},
+	{"Makati", "Greenbelt", 14.5526, 121.0216},
+	{"Makati", "Ayala Avenue", 14.5564, 121.0244},
+	{"Taguig", "BGC High Street", 14.5508, 121.0510},
+	{"Taguig", "Market Market", 14.5496, 121.0556},
+	{"Taguig", "SM Aura", 14.5465, 121.0545},
+	{"Taguig", "Venice Grand Canal", 14.5353, 121.0516},
+	{"Pasig", "Ortigas Center", 14.5869, 121.0614},
+	{"Pasig", "Tiendesitas", 14.5874, 121.0784},
+	{"Pasig", "Robinsons Galleria", 14.5906, 121.0597},
+	{"Pasig", "Eastwood", 14.6091, 121.0800},
+}
+
+// seedMeetupSpots inserts the default meetup spots; existing rows are left untouched
+func seedMeetupSpots() {
+	for _, spot := range defaultMeetupSpots {
+		_, err := DB.Exec(
+			"INSERT IGNORE INTO meetup_spots (city, name, latitude, longitude) VALUES (?, ?, ?, ?)",
+			spot.City, spot.Name, spot.Latitude, spot.Longitude,
+		)
+		if err != nil {
+			log.Printf("Warning: failed to seed meetup spot %s (%s): %v", spot.Name, spot.City, err)
+		}
+	}
+}
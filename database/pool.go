@@ -0,0 +1,62 @@
+package database
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/xashathebest/clovia/config"
+)
+
+// PoolSettings sizes the MySQL connection pool
+type PoolSettings struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+// defaultPoolSettings are used for any DB_* pool variable left unset
+var defaultPoolSettings = PoolSettings{
+	MaxOpenConns:    25,
+	MaxIdleConns:    25,
+	ConnMaxLifetime: 5 * time.Minute,
+}
+
+// LoadPoolSettings reads DB_MAX_OPEN_CONNS, DB_MAX_IDLE_CONNS and DB_CONN_MAX_LIFETIME.
+// Unlike the config helpers it rejects malformed values instead of falling back, so a
+// typo does not silently leave the pool at its defaults.
+func LoadPoolSettings() (PoolSettings, error) {
+	s := defaultPoolSettings
+	var err error
+	if v := config.GetEnv("DB_MAX_OPEN_CONNS", ""); v != "" {
+		if s.MaxOpenConns, err = strconv.Atoi(v); err != nil {
+			return s, fmt.Errorf("invalid DB_MAX_OPEN_CONNS %q", v)
+		}
+	}
+	if v := config.GetEnv("DB_MAX_IDLE_CONNS", ""); v != "" {
+		if s.MaxIdleConns, err = strconv.Atoi(v); err != nil {
+			return s, fmt.Errorf("invalid DB_MAX_IDLE_CONNS %q", v)
+		}
+	}
+	if v := config.GetEnv("DB_CONN_MAX_LIFETIME", ""); v != "" {
+		if s.ConnMaxLifetime, err = time.ParseDuration(v); err != nil {
+			return s, fmt.Errorf("invalid DB_CONN_MAX_LIFETIME %q", v)
+		}
+	}
+	return s, s.Validate()
+}
+
+// Validate requires at least one open connection, an idle pool no larger than the open
+// limit, and a non-negative lifetime (0 keeps connections forever)
+func (s PoolSettings) Validate() error {
+	if s.MaxOpenConns < 1 {
+		return fmt.Errorf("DB_MAX_OPEN_CONNS must be at least 1, got %d", s.MaxOpenConns)
+	}
+	if s.MaxIdleConns < 0 || s.MaxIdleConns > s.MaxOpenConns {
+		return fmt.Errorf("DB_MAX_IDLE_CONNS must be between 0 and DB_MAX_OPEN_CONNS (%d), got %d", s.MaxOpenConns, s.MaxIdleConns)
+	}
+	if s.ConnMaxLifetime < 0 {
+		return fmt.Errorf("DB_CONN_MAX_LIFETIME must not be negative, got %s", s.ConnMaxLifetime)
+	}
+	return nil
+}
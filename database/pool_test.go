@@ -0,0 +1,45 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoadPoolSettings(t *testing.T) {
+	t.Setenv("DB_MAX_OPEN_CONNS", "")
+	t.Setenv("DB_MAX_IDLE_CONNS", "")
+	t.Setenv("DB_CONN_MAX_LIFETIME", "")
+	s, err := LoadPoolSettings()
+	if err != nil || s != defaultPoolSettings {
+		t.Fatalf("defaults = %+v, %v; want %+v", s, err, defaultPoolSettings)
+	}
+
+	t.Setenv("DB_MAX_OPEN_CONNS", "5")
+	t.Setenv("DB_MAX_IDLE_CONNS", "2")
+	t.Setenv("DB_CONN_MAX_LIFETIME", "90s")
+	s, err = LoadPoolSettings()
+	want := PoolSettings{MaxOpenConns: 5, MaxIdleConns: 2, ConnMaxLifetime: 90 * time.Second}
+	if err != nil || s != want {
+		t.Fatalf("overrides = %+v, %v; want %+v", s, err, want)
+	}
+
+	invalid := []struct{ key, value string }{
+		{"DB_MAX_OPEN_CONNS", "lots"},
+		{"DB_MAX_OPEN_CONNS", "0"},
+		{"DB_MAX_IDLE_CONNS", "6"},
+		{"DB_MAX_IDLE_CONNS", "-1"},
+		{"DB_CONN_MAX_LIFETIME", "5"},
+		{"DB_CONN_MAX_LIFETIME", "-1m"},
+	}
+	for _, tc := range invalid {
+		t.Run(tc.key+"="+tc.value, func(t *testing.T) {
+			t.Setenv("DB_MAX_OPEN_CONNS", "5")
+			t.Setenv("DB_MAX_IDLE_CONNS", "2")
+			t.Setenv("DB_CONN_MAX_LIFETIME", "90s")
+			t.Setenv(tc.key, tc.value)
+			if _, err := LoadPoolSettings(); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xashathebest/clovia/models"
+)
+
+// tradeStatusEnum is the column type of trades.status, used by both the CREATE and the
+// backfill ALTER so existing deployments pick up new statuses on restart
+const tradeStatusEnum = "ENUM('pending','accepted','declined','countered','active','awaiting_confirmation','completed','auto_completed','cancelled')"
+
+// enumValues parses a MySQL column type such as "enum('a','b')" into its values
+func enumValues(columnType string) []string {
+	start := strings.Index(columnType, "(")
+	end := strings.LastIndex(columnType, ")")
+	if start < 0 || end <= start {
+		return nil
+	}
+	var values []string
+	for _, v := range strings.Split(columnType[start+1:end], ",") {
+		values = append(values, strings.Trim(strings.TrimSpace(v), "'"))
+	}
+	return values
+}
+
+// missingEnumValues returns the entries of want that columnType cannot store
+func missingEnumValues(columnType string, want []string) []string {
+	have := make(map[string]bool)
+	for _, v := range enumValues(columnType) {
+		have[v] = true
+	}
+	var missing []string
+	for _, v := range want {
+		if !have[v] {
+			missing = append(missing, v)
+		}
+	}
+	return missing
+}
+
+// assertTradeStatusEnum checks the live trades.status column against models.TradeStatuses
+func assertTradeStatusEnum() error {
+	var columnType string
+	err := DB.QueryRow(`
+		SELECT COLUMN_TYPE FROM information_schema.COLUMNS
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'trades' AND COLUMN_NAME = 'status'
+	`).Scan(&columnType)
+	if err != nil {
+		return fmt.Errorf("failed to read trades.status column type: %v", err)
+	}
+	if missing := missingEnumValues(columnType, models.TradeStatuses); len(missing) > 0 {
+		return fmt.Errorf("trades.status enum is missing statuses %v (column type %s)", missing, columnType)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package database
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xashathebest/clovia/models"
+)
+
+func TestTradeStatusEnumCoversModel(t *testing.T) {
+	if missing := missingEnumValues(tradeStatusEnum, models.TradeStatuses); len(missing) > 0 {
+		t.Fatalf("tradeStatusEnum is missing %v", missing)
+	}
+
+	field, ok := reflect.TypeOf(models.Trade{}).FieldByName("Status")
+	if !ok {
+		t.Fatal("models.Trade has no Status field")
+	}
+	tag := field.Tag.Get("validate")
+	allowed := strings.Fields(strings.TrimPrefix(tag, "oneof="))
+	if !reflect.DeepEqual(allowed, models.TradeStatuses) {
+		t.Fatalf("Trade.Status validate tag %q does not match models.TradeStatuses %v", tag, models.TradeStatuses)
+	}
+}
+
+func TestMissingEnumValues(t *testing.T) {
+	oldEnum := "enum('pending','accepted','declined','countered','active','completed','cancelled')"
+	missing := missingEnumValues(oldEnum, models.TradeStatuses)
+	if !reflect.DeepEqual(missing, []string{"awaiting_confirmation", "auto_completed"}) {
+		t.Fatalf("unexpected missing statuses: %v", missing)
+	}
+}
@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/config"
+	"github.com/xashathebest/clovia/middleware"
+	"github.com/xashathebest/clovia/models"
+	"github.com/xashathebest/clovia/utils"
+)
+
+// deletedEmailDomain is the address domain of anonymized accounts and the placeholder
+const deletedEmailDomain = "@clovia.invalid"
+
+// deletedUserEmail identifies the placeholder account that anonymized listings move to
+const deletedUserEmail = "deleted-user" + deletedEmailDomain
+
+// deletedUserName replaces the name of an anonymized account and names the placeholder
+const deletedUserName = "Deleted user"
+
+// activeDeliveryStatuses are deliveries still being arranged or carried
+var activeDeliveryStatuses = []string{"pending", "claimed", "picked_up", "in_transit"}
+
+// accountDeletionNotice is a message for a counterparty of a closed interaction
+type accountDeletionNotice struct {
+	UserID  int
+	Message string
+	RefType string
+	RefID   int
+}
+
+// DeleteAccount deletes the authenticated user's account after re-checking their password.
+// Accounts with open trades, pending orders or active deliveries are refused until those
+// finish. Open price offers and product transfers are closed and the other side is told.
+// ACCOUNT_DELETION_MODE picks between anonymizing the account and deleting it outright;
+// either way every token issued so far stops working.
+// Body: { "password": "..." }
+func (h *UserHandler) DeleteAccount(c *fiber.Ctx) error {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "User not authenticated"})
+	}
+	var payload struct {
+		Password string `json:"password"`
+	}
+	if err := c.BodyParser(&payload); err != nil || payload.Password == "" {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Password is required to delete your account"})
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to start transaction"})
+	}
+	defer tx.Rollback()
+
+	var passwordHash, email string
+	err = tx.QueryRow("SELECT password_hash, email FROM users WHERE id = ? FOR UPDATE", userID).Scan(&passwordHash, &email)
+	if err == sql.ErrNoRows {
+		return c.Status(404).JSON(models.APIResponse{Success: false, Error: "User not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to retrieve user"})
+	}
+	if email == deletedUserEmail {
+		return c.Status(403).JSON(models.APIResponse{Success: false, Error: "This account cannot be deleted"})
+	}
+	if !utils.CheckPasswordHash(payload.Password, passwordHash) {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "Password is incorrect"})
+	}
+
+	blocking, err := accountDeletionBlockers(tx, userID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to check open activity"})
+	}
+	if len(blocking) > 0 {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Finish or cancel your open activity before deleting your account: " + strings.Join(blocking, ", "),
+			Data:    fiber.Map{"blocking": blocking},
+		})
+	}
+
+	notices, err := closeInteractionsForDeletion(tx, userID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to close open offers"})
+	}
+	// Wishlist counts are denormalized and no cascade keeps them right
+	if err := clearWishlist(tx, userID); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to delete account"})
+	}
+
+	mode := config.AccountDeletionMode()
+	if mode == config.AccountDeletionHard {
+		_, err = tx.Exec("DELETE FROM users WHERE id = ?", userID)
+	} else {
+		err = anonymizeUser(tx, userID)
+	}
+	if err != nil {
+		log.Printf("Account deletion (%s) for user %d failed: %v", mode, userID, err)
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to delete account"})
+	}
+
+	revokedAt := time.Now()
+	if err := middleware.SaveTokenRevocation(tx, userID, revokedAt); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to sign out sessions"})
+	}
+	if err := tx.Commit(); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to delete account"})
+	}
+	middleware.RememberTokenRevocation(userID, revokedAt)
+
+	for _, n := range notices {
+		notify(h.db, n.UserID, "account_deleted", n.Message, n.RefType, n.RefID)
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Your account has been deleted",
+		Data:    fiber.Map{"mode": mode},
+	})
+}
+
+// accountDeletionBlockers describes the user's activity that must finish before the
+// account can go: open trades, pending orders (as buyer or seller) and deliveries they
+// requested or are carrying
+func accountDeletionBlockers(db queryRower, userID int) ([]string, error) {
+	tradePlaceholders := strings.TrimSuffix(strings.Repeat("?,", len(openTradeStatuses)), ",")
+	deliveryPlaceholders := strings.TrimSuffix(strings.Repeat("?,", len(activeDeliveryStatuses)), ",")
+	args := []interface{}{userID, userID}
+	for _, s := range openTradeStatuses {
+		args = append(args, s)
+	}
+	args = append(args, userID, userID, userID, userID)
+	for _, s := range activeDeliveryStatuses {
+		args = append(args, s)
+	}
+
+	var trades, orders, deliveries int
+	err := db.QueryRow(`SELECT
+		(SELECT COUNT(*) FROM trades WHERE (buyer_id = ? OR seller_id = ?) AND status IN (`+tradePlaceholders+`)),
+		(SELECT COUNT(*) FROM orders o JOIN products p ON p.id = o.product_id
+			WHERE (o.buyer_id = ? OR p.seller_id = ?) AND o.status = 'pending'),
+		(SELECT COUNT(*) FROM deliveries d LEFT JOIN riders r ON r.id = d.rider_id
+			WHERE (d.user_id = ? OR r.user_id = ?) AND d.status IN (`+deliveryPlaceholders+`))`, args...).
+		Scan(&trades, &orders, &deliveries)
+	if err != nil {
+		return nil, err
+	}
+	var blocking []string
+	for _, b := range []struct {
+		n              int
+		singular, many string
+	}{
+		{trades, "open trade", "open trades"},
+		{orders, "pending order", "pending orders"},
+		{deliveries, "active delivery", "active deliveries"},
+	} {
+		if b.n == 1 {
+			blocking = append(blocking, "1 "+b.singular)
+		} else if b.n > 1 {
+			blocking = append(blocking, fmt.Sprintf("%d %s", b.n, b.many))
+		}
+	}
+	return blocking, nil
+}
+
+// closeInteractionsForDeletion closes the user's open price offers and pending product
+// transfers and returns who to tell about each
+func closeInteractionsForDeletion(tx *sql.Tx, userID int) ([]accountDeletionNotice, error) {
+	var notices []accountDeletionNotice
+
+	rows, err := tx.Query(`SELECT po.id, IF(po.buyer_id = ?, po.seller_id, po.buyer_id), p.title
+		FROM price_offers po JOIN products p ON p.id = po.product_id
+		WHERE (po.buyer_id = ? OR po.seller_id = ?) AND po.status IN ('pending', 'accepted')
+		FOR UPDATE`, userID, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var n accountDeletionNotice
+		var title string
+		if err := rows.Scan(&n.RefID, &n.UserID, &title); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		n.RefType = models.NotificationRefPriceOffer
+		n.Message = fmt.Sprintf("The price offer on \"%s\" was closed because the other person deleted their account", title)
+		notices = append(notices, n)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`UPDATE price_offers SET status = 'closed'
+		WHERE (buyer_id = ? OR seller_id = ?) AND status IN ('pending', 'accepted')`, userID, userID); err != nil {
+		return nil, err
+	}
+
+	rows, err = tx.Query(`SELECT pt.product_id, IF(pt.from_user_id = ?, pt.to_user_id, pt.from_user_id), p.title
+		FROM product_transfers pt JOIN products p ON p.id = pt.product_id
+		WHERE (pt.from_user_id = ? OR pt.to_user_id = ?) AND pt.status = 'pending'
+		FOR UPDATE`, userID, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var n accountDeletionNotice
+		var title string
+		if err := rows.Scan(&n.RefID, &n.UserID, &title); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		n.RefType = models.NotificationRefProduct
+		n.Message = fmt.Sprintf("The transfer of \"%s\" was cancelled because the other person deleted their account", title)
+		notices = append(notices, n)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`UPDATE product_transfers SET status = 'cancelled', responded_at = CURRENT_TIMESTAMP
+		WHERE (from_user_id = ? OR to_user_id = ?) AND status = 'pending'`, userID, userID); err != nil {
+		return nil, err
+	}
+	return notices, nil
+}
+
+// deletedUserID returns the placeholder account that owns anonymized listings, creating
+// it on first use. Its password hash matches no password, so nobody can sign in as it.
+func deletedUserID(tx *sql.Tx) (int, error) {
+	if _, err := tx.Exec("INSERT IGNORE INTO users (name, email, password_hash) VALUES (?, ?, '!')", deletedUserName, deletedUserEmail); err != nil {
+		return 0, err
+	}
+	var id int
+	err := tx.QueryRow("SELECT id FROM users WHERE email = ?", deletedUserEmail).Scan(&id)
+	return id, err
+}
+
+// anonymizeUser strips the account of personal data while keeping the row, so trades,
+// orders, ratings and chats stay readable for the other side under "Deleted user". The
+// user's listings move to the placeholder account; those still for sale are taken down.
+func anonymizeUser(tx *sql.Tx, userID int) error {
+	placeholder, err := deletedUserID(tx)
+	if err != nil {
+		return err
+	}
+	if err := clearWishlist(tx, userID); err != nil {
+		return err
+	}
+	statements := []struct {
+		query string
+		args  []interface{}
+	}{
+		{`UPDATE products SET seller_id = ?,
+			status = IF(status IN ('available', 'locked', 'draft'), 'removed', status), version = version + 1
+			WHERE seller_id = ?`, []interface{}{placeholder, userID}},
+		{"DELETE FROM saved_products WHERE user_id = ?", []interface{}{userID}},
+		{"DELETE FROM back_in_stock_alerts WHERE user_id = ?", []interface{}{userID}},
+		{"DELETE FROM conversation_mutes WHERE user_id = ?", []interface{}{userID}},
+		{"DELETE FROM notifications WHERE user_id = ?", []interface{}{userID}},
+		{"DELETE FROM profile_analysis_cache WHERE user_id = ?", []interface{}{userID}},
+		{"DELETE FROM riders WHERE user_id = ?", []interface{}{userID}},
+		{`UPDATE deliveries SET pickup_address = '', delivery_address = '', special_instructions = NULL,
+			pickup_latitude = NULL, pickup_longitude = NULL, delivery_latitude = NULL, delivery_longitude = NULL
+			WHERE user_id = ?`, []interface{}{userID}},
+		{`UPDATE users SET name = ?, email = CONCAT('deleted-', id, ?), password_hash = '', role = 'user',
+			username = NULL, bio = NULL, badges = NULL, department = NULL, department_id = NULL,
+			is_organization = 0, org_verified = 0, org_name = NULL, org_logo_url = NULL, verified = FALSE,
+			profile_picture = NULL, background_image = NULL, background_position = NULL,
+			latitude = NULL, longitude = NULL, vacation_until = NULL, last_seen_at = NULL,
+			listing_limit_override = NULL, mute_announcements = TRUE
+			WHERE id = ?`, []interface{}{deletedUserName, deletedEmailDomain, userID}},
+	}
+	for _, s := range statements {
+		if _, err := tx.Exec(s.query, s.args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
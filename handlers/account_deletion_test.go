@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/utils"
+)
+
+// TestDeleteAccountAnonymizes walks a deletion through a wrong password and an open trade,
+// then checks the account is anonymized, its listing moved and taken down, and the buyer
+// with an open price offer told about it
+func TestDeleteAccountAnonymizes(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	t.Setenv("ACCOUNT_DELETION_MODE", "anonymize")
+
+	seller := createTestUser(t, db, "Leaving Seller")
+	buyer := createTestUser(t, db, "Staying Buyer")
+	hash, _ := utils.HashPassword("correct horse")
+	if _, err := db.Exec("UPDATE users SET password_hash = ?, bio = 'about me' WHERE id = ?", hash, seller); err != nil {
+		t.Fatalf("set password: %v", err)
+	}
+	product := createTestProduct(t, db, seller, "Leaving Lamp")
+	t.Cleanup(func() { db.Exec("DELETE FROM products WHERE id = ?", product) })
+	res, err := db.Exec("INSERT INTO trades (buyer_id, seller_id, target_product_id, status) VALUES (?, ?, ?, 'active')", buyer, seller, product)
+	if err != nil {
+		t.Fatalf("insert trade: %v", err)
+	}
+	tradeID, _ := res.LastInsertId()
+	if _, err := db.Exec("INSERT INTO price_offers (product_id, buyer_id, seller_id, amount) VALUES (?, ?, ?, 80)", product, buyer, seller); err != nil {
+		t.Fatalf("insert offer: %v", err)
+	}
+
+	h := &UserHandler{db: db}
+	app := newTestApp(&seller, func(app *fiber.App) { app.Delete("/users/me", h.DeleteAccount) })
+	del := func(password string) int {
+		req := httptest.NewRequest("DELETE", "/users/me", strings.NewReader(`{"password":"`+password+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		return resp.StatusCode
+	}
+
+	if got := del("wrong"); got != 401 {
+		t.Fatalf("wrong password: status %d, want 401", got)
+	}
+	if got := del("correct horse"); got != 409 {
+		t.Fatalf("with an open trade: status %d, want 409", got)
+	}
+	if _, err := db.Exec("UPDATE trades SET status = 'cancelled' WHERE id = ?", tradeID); err != nil {
+		t.Fatalf("cancel trade: %v", err)
+	}
+	if got := del("correct horse"); got != 200 {
+		t.Fatalf("delete: status %d, want 200", got)
+	}
+
+	var name, email string
+	var bio *string
+	if err := db.QueryRow("SELECT name, email, bio FROM users WHERE id = ?", seller).Scan(&name, &email, &bio); err != nil {
+		t.Fatalf("load user: %v", err)
+	}
+	if name != deletedUserName || !strings.HasSuffix(email, "@clovia.invalid") || bio != nil {
+		t.Errorf("user not anonymized: name %q, email %q, bio %v", name, email, bio)
+	}
+	var ownerEmail, status string
+	if err := db.QueryRow("SELECT u.email, p.status FROM products p JOIN users u ON u.id = p.seller_id WHERE p.id = ?", product).Scan(&ownerEmail, &status); err != nil {
+		t.Fatalf("load product: %v", err)
+	}
+	if ownerEmail != deletedUserEmail || status != "removed" {
+		t.Errorf("product owner %q status %q, want the placeholder and removed", ownerEmail, status)
+	}
+	var offerStatus string
+	db.QueryRow("SELECT status FROM price_offers WHERE product_id = ?", product).Scan(&offerStatus)
+	if offerStatus != "closed" {
+		t.Errorf("price offer status %q, want closed", offerStatus)
+	}
+	var notified int
+	db.QueryRow("SELECT COUNT(*) FROM notifications WHERE user_id = ? AND type = 'account_deleted'", buyer).Scan(&notified)
+	if notified != 1 {
+		t.Errorf("buyer got %d account_deleted notifications, want 1", notified)
+	}
+}
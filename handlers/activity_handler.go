@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/config"
+	"github.com/xashathebest/clovia/middleware"
+	"github.com/xashathebest/clovia/models"
+)
+
+// activityFeedQuery unions every event source for a user into one row shape.
+// Each branch takes the user ID once; rows are ordered by (created_at, type, source_id)
+// descending, which is unique per row and so gives a stable cursor.
+const activityFeedQuery = `
+	SELECT 'offer_received' AS type, t.id AS source_id, t.buyer_id AS actor_id, u.name AS actor_name,
+		t.target_product_id AS product_id, p.title AS product_title, t.id AS trade_id, NULL AS order_id,
+		LEFT(t.message, 140) AS snippet, t.created_at AS created_at
+	FROM trades t
+	JOIN users u ON u.id = t.buyer_id
+	JOIN products p ON p.id = t.target_product_id
+	WHERE t.seller_id = ?
+	UNION ALL
+	SELECT CASE te.to_status WHEN 'accepted' THEN 'offer_accepted' ELSE 'offer_declined' END, te.id, te.actor_id, u.name,
+		t.target_product_id, p.title, t.id, NULL, LEFT(te.note, 140), te.created_at
+	FROM trade_events te
+	JOIN trades t ON t.id = te.trade_id
+	JOIN products p ON p.id = t.target_product_id
+	LEFT JOIN users u ON u.id = te.actor_id
+	WHERE t.buyer_id = ? AND te.to_status IN ('accepted', 'declined')
+	UNION ALL
+	SELECT 'comment', c.id, c.user_id, u.name, p.id, p.title, NULL, NULL, LEFT(c.content, 140), c.created_at
+	FROM comments c
+	JOIN products p ON p.id = c.product_id
+	JOIN users u ON u.id = c.user_id
+	WHERE p.seller_id = ? AND c.user_id <> p.seller_id AND c.deleted_at IS NULL
+	UNION ALL
+	SELECT 'wishlisted', w.id, w.user_id, u.name, p.id, p.title, NULL, NULL, NULL, w.created_at
+	FROM wishlists w
+	JOIN products p ON p.id = w.product_id
+	JOIN users u ON u.id = w.user_id
+	WHERE p.seller_id = ? AND w.user_id <> p.seller_id
+	UNION ALL
+	SELECT 'order_placed', o.id, o.buyer_id, u.name, p.id, p.title, NULL, o.id, NULL, o.created_at
+	FROM orders o
+	JOIN products p ON p.id = o.product_id
+	JOIN users u ON u.id = o.buyer_id
+	WHERE p.seller_id = ?`
+
+// activityFeedSources is the number of user ID placeholders in activityFeedQuery
+const activityFeedSources = 5
+
+// activityCursor marks the last entry of a page; the next page starts strictly after it
+type activityCursor struct {
+	CreatedAt time.Time
+	Type      string
+	SourceID  int
+}
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// encodeActivityCursor renders the position after entry as an opaque token
+func encodeActivityCursor(entry models.ActivityEntry) string {
+	raw := fmt.Sprintf("%d:%s:%d", entry.CreatedAt.Unix(), entry.Type, entry.SourceID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeActivityCursor parses a token produced by encodeActivityCursor
+func decodeActivityCursor(token string) (activityCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return activityCursor{}, errInvalidCursor
+	}
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 3 || parts[1] == "" {
+		return activityCursor{}, errInvalidCursor
+	}
+	sec, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || sec < 0 {
+		return activityCursor{}, errInvalidCursor
+	}
+	id, err := strconv.Atoi(parts[2])
+	if err != nil || id < 1 {
+		return activityCursor{}, errInvalidCursor
+	}
+	return activityCursor{CreatedAt: time.Unix(sec, 0), Type: parts[1], SourceID: id}, nil
+}
+
+// GetMyActivity returns the caller's activity feed: offers received, responses to their
+// offers, comments and wishlist adds on their listings, and orders placed for them.
+// Newest first; page with ?limit= and the next_cursor from the previous response.
+func (h *UserHandler) GetMyActivity(c *fiber.Ctx) error {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "User not authenticated"})
+	}
+	limit := normalizePagination("", c.Query("limit"), "", config.DefaultPageSize(), config.MaxPageSize()).Limit
+
+	var cursor *activityCursor
+	if token := c.Query("cursor"); token != "" {
+		cur, err := decodeActivityCursor(token)
+		if err != nil {
+			return c.Status(400).JSON(models.APIResponse{Success: false, Error: err.Error()})
+		}
+		cursor = &cur
+	}
+
+	feed, err := loadActivityFeed(h.db, userID, cursor, limit)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to load activity"})
+	}
+	return c.JSON(models.APIResponse{Success: true, Data: feed})
+}
+
+// loadActivityFeed reads up to limit feed entries for a user, newest first, starting
+// after cursor when one is given
+func loadActivityFeed(db *sql.DB, userID int, cursor *activityCursor, limit int) (models.ActivityFeed, error) {
+	feed := models.ActivityFeed{Entries: []models.ActivityEntry{}}
+	query := "SELECT type, source_id, actor_id, actor_name, product_id, product_title, trade_id, order_id, snippet, created_at FROM (" +
+		activityFeedQuery + ") feed"
+	args := make([]interface{}, 0, activityFeedSources+6)
+	for i := 0; i < activityFeedSources; i++ {
+		args = append(args, userID)
+	}
+	if cursor != nil {
+		query += " WHERE created_at < ? OR (created_at = ? AND (type < ? OR (type = ? AND source_id < ?)))"
+		args = append(args, cursor.CreatedAt, cursor.CreatedAt, cursor.Type, cursor.Type, cursor.SourceID)
+	}
+	// Fetch one extra row to learn whether another page exists
+	query += " ORDER BY created_at DESC, type DESC, source_id DESC LIMIT ?"
+	args = append(args, limit+1)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return feed, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e models.ActivityEntry
+		var actorID, productID, tradeID, orderID sql.NullInt64
+		var actorName, productTitle, snippet sql.NullString
+		if err := rows.Scan(&e.Type, &e.SourceID, &actorID, &actorName, &productID, &productTitle, &tradeID, &orderID, &snippet, &e.CreatedAt); err != nil {
+			return feed, err
+		}
+		e.ID = fmt.Sprintf("%s:%d", e.Type, e.SourceID)
+		e.ActorID = nullIntPtr(actorID)
+		e.ProductID = nullIntPtr(productID)
+		e.TradeID = nullIntPtr(tradeID)
+		e.OrderID = nullIntPtr(orderID)
+		e.ActorName = actorName.String
+		e.ProductTitle = productTitle.String
+		e.Snippet = snippet.String
+		feed.Entries = append(feed.Entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return feed, err
+	}
+
+	if len(feed.Entries) > limit {
+		feed.Entries = feed.Entries[:limit]
+		feed.HasMore = true
+		feed.NextCursor = encodeActivityCursor(feed.Entries[limit-1])
+	}
+	return feed, nil
+}
+
+// nullIntPtr converts a nullable integer column into an optional JSON field
+func nullIntPtr(n sql.NullInt64) *int {
+	if !n.Valid {
+		return nil
+	}
+	v := int(n.Int64)
+	return &v
+}
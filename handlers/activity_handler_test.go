@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/xashathebest/clovia/models"
+)
+
+func TestActivityCursorRoundTrip(t *testing.T) {
+	entry := models.ActivityEntry{Type: models.ActivityOfferReceived, SourceID: 42, CreatedAt: time.Unix(1700000000, 0)}
+	cur, err := decodeActivityCursor(encodeActivityCursor(entry))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !cur.CreatedAt.Equal(entry.CreatedAt) || cur.Type != entry.Type || cur.SourceID != entry.SourceID {
+		t.Errorf("got %+v, want created_at=%v type=%s source_id=%d", cur, entry.CreatedAt, entry.Type, entry.SourceID)
+	}
+}
+
+func TestDecodeActivityCursorRejectsMalformed(t *testing.T) {
+	enc := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	for _, token := range []string{
+		"not base64!",
+		enc("1700000000:comment"),
+		enc("abc:comment:1"),
+		enc("-1:comment:1"),
+		enc("1700000000::1"),
+		enc("1700000000:comment:0"),
+		enc("1700000000:comment:x"),
+	} {
+		if _, err := decodeActivityCursor(token); err != errInvalidCursor {
+			t.Errorf("decodeActivityCursor(%q) err = %v, want errInvalidCursor", token, err)
+		}
+	}
+}
@@ -1,12 +1,22 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/xashathebest/clovia/database"
+	"github.com/xashathebest/clovia/middleware"
 	"github.com/xashathebest/clovia/models"
+	"github.com/xashathebest/clovia/services"
+	"github.com/xashathebest/clovia/utils"
 )
 
 type AdminHandler struct {
@@ -17,8 +27,51 @@ func NewAdminHandler() *AdminHandler {
 	return &AdminHandler{db: database.DB}
 }
 
+// adminActiveListingFilter matches listings counted as active on the admin dashboard
+const adminActiveListingFilter = "status NOT IN ('sold', 'expired', 'draft') AND deleted_at IS NULL"
+
+// productBucket is one group in a product count aggregation
+type productBucket struct {
+	Label string
+	Count int
+}
+
+// groupProductCounts counts products matching where, grouped by column and ordered by
+// count (top `limit` groups, 0 for all). column and where must be trusted SQL fragments.
+func groupProductCounts(ctx context.Context, db *sql.DB, column, fallback, where string, limit int) ([]productBucket, error) {
+	query := fmt.Sprintf(`
+		SELECT COALESCE(NULLIF(%[1]s, ''), ?) AS label, COUNT(*) AS count
+		FROM products
+		WHERE %[2]s
+		GROUP BY label
+		ORDER BY count DESC`, column, where)
+	args := []interface{}{fallback}
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	buckets := []productBucket{}
+	for rows.Next() {
+		var b productBucket
+		if err := rows.Scan(&b.Label, &b.Count); err == nil {
+			buckets = append(buckets, b)
+		}
+	}
+	return buckets, rows.Err()
+}
+
 // GetAdminStats returns comprehensive dashboard statistics for admin
 func (h *AdminHandler) GetAdminStats(c *fiber.Ctx) error {
+	// Every query runs under the request deadline so a slow dashboard cannot hold the pool
+	ctx := c.UserContext()
+
 	// Get current time and 30 days ago for date calculations
 	now := time.Now()
 	thirtyDaysAgo := now.AddDate(0, 0, -30)
@@ -27,7 +80,7 @@ func (h *AdminHandler) GetAdminStats(c *fiber.Ctx) error {
 
 	// Active Listings (exclude sold/expired/draft)
 	var activeListings int
-	err := h.db.QueryRow(`
+	err := h.db.QueryRowContext(ctx, `
 		SELECT COUNT(*) FROM products 
 		WHERE status NOT IN ('sold', 'expired', 'draft') 
 		AND deleted_at IS NULL
@@ -38,7 +91,7 @@ func (h *AdminHandler) GetAdminStats(c *fiber.Ctx) error {
 
 	// Premium Listings (active listings where is_premium=true)
 	var premiumListings int
-	err = h.db.QueryRow(`
+	err = h.db.QueryRowContext(ctx, `
 		SELECT COUNT(*) FROM products 
 		WHERE is_premium = true 
 		AND status NOT IN ('sold', 'expired', 'draft') 
@@ -50,7 +103,7 @@ func (h *AdminHandler) GetAdminStats(c *fiber.Ctx) error {
 
 	// Transactions (Last 30 Days)
 	var transactions30Days int
-	err = h.db.QueryRow(`
+	err = h.db.QueryRowContext(ctx, `
 		SELECT COUNT(*) FROM trades 
 		WHERE status = 'completed' 
 		AND created_at >= ?
@@ -61,7 +114,7 @@ func (h *AdminHandler) GetAdminStats(c *fiber.Ctx) error {
 
 	// Net Revenue (Last 30 Days)
 	var netRevenue30Days float64
-	err = h.db.QueryRow(`
+	err = h.db.QueryRowContext(ctx, `
 		SELECT COALESCE(SUM(net_amount), 0) FROM trades 
 		WHERE status = 'completed' 
 		AND created_at >= ? 
@@ -73,14 +126,14 @@ func (h *AdminHandler) GetAdminStats(c *fiber.Ctx) error {
 
 	// Registered Users breakdown
 	var totalUsers, adminUsers int
-	err = h.db.QueryRow(`
+	err = h.db.QueryRowContext(ctx, `
 		SELECT COUNT(*) FROM users WHERE deleted_at IS NULL
 	`).Scan(&totalUsers)
 	if err != nil {
 		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to fetch total users"})
 	}
 
-	err = h.db.QueryRow(`
+	err = h.db.QueryRowContext(ctx, `
 		SELECT COUNT(*) FROM users WHERE role = 'admin' AND deleted_at IS NULL
 	`).Scan(&adminUsers)
 	if err != nil {
@@ -91,7 +144,7 @@ func (h *AdminHandler) GetAdminStats(c *fiber.Ctx) error {
 
 	// Reports to Review
 	var reportsToReview int
-	err = h.db.QueryRow(`
+	err = h.db.QueryRowContext(ctx, `
 		SELECT COUNT(*) FROM reports WHERE status = 'pending'
 	`).Scan(&reportsToReview)
 	if err != nil {
@@ -100,7 +153,7 @@ func (h *AdminHandler) GetAdminStats(c *fiber.Ctx) error {
 
 	// Pending Verifications
 	var pendingVerifications int
-	err = h.db.QueryRow(`
+	err = h.db.QueryRowContext(ctx, `
 		SELECT COUNT(*) FROM user_verifications WHERE status = 'pending'
 	`).Scan(&pendingVerifications)
 	if err != nil {
@@ -109,7 +162,7 @@ func (h *AdminHandler) GetAdminStats(c *fiber.Ctx) error {
 
 	// Listings Awaiting Approval
 	var listingsAwaitingApproval int
-	err = h.db.QueryRow(`
+	err = h.db.QueryRowContext(ctx, `
 		SELECT COUNT(*) FROM products WHERE status = 'pending_approval' AND deleted_at IS NULL
 	`).Scan(&listingsAwaitingApproval)
 	if err != nil {
@@ -118,7 +171,7 @@ func (h *AdminHandler) GetAdminStats(c *fiber.Ctx) error {
 
 	// Disputes Pending
 	var disputesPending int
-	err = h.db.QueryRow(`
+	err = h.db.QueryRowContext(ctx, `
 		SELECT COUNT(*) FROM disputes WHERE status = 'pending'
 	`).Scan(&disputesPending)
 	if err != nil {
@@ -127,7 +180,7 @@ func (h *AdminHandler) GetAdminStats(c *fiber.Ctx) error {
 
 	// Payouts Pending
 	var payoutsPending int
-	err = h.db.QueryRow(`
+	err = h.db.QueryRowContext(ctx, `
 		SELECT COUNT(*) FROM payouts WHERE status = 'pending'
 	`).Scan(&payoutsPending)
 	if err != nil {
@@ -138,7 +191,7 @@ func (h *AdminHandler) GetAdminStats(c *fiber.Ctx) error {
 
 	// DAU (Daily Active Users)
 	var dau int
-	err = h.db.QueryRow(`
+	err = h.db.QueryRowContext(ctx, `
 		SELECT COUNT(DISTINCT user_id) FROM user_activity 
 		WHERE DATE(created_at) = CURDATE()
 	`).Scan(&dau)
@@ -148,7 +201,7 @@ func (h *AdminHandler) GetAdminStats(c *fiber.Ctx) error {
 
 	// WAU (Weekly Active Users)
 	var wau int
-	err = h.db.QueryRow(`
+	err = h.db.QueryRowContext(ctx, `
 		SELECT COUNT(DISTINCT user_id) FROM user_activity 
 		WHERE created_at >= DATE_SUB(CURDATE(), INTERVAL 7 DAY)
 	`).Scan(&wau)
@@ -158,7 +211,7 @@ func (h *AdminHandler) GetAdminStats(c *fiber.Ctx) error {
 
 	// MAU (Monthly Active Users)
 	var mau int
-	err = h.db.QueryRow(`
+	err = h.db.QueryRowContext(ctx, `
 		SELECT COUNT(DISTINCT user_id) FROM user_activity 
 		WHERE created_at >= DATE_SUB(CURDATE(), INTERVAL 30 DAY)
 	`).Scan(&mau)
@@ -170,7 +223,7 @@ func (h *AdminHandler) GetAdminStats(c *fiber.Ctx) error {
 
 	// Views (product views)
 	var totalViews int
-	err = h.db.QueryRow(`
+	err = h.db.QueryRowContext(ctx, `
 		SELECT COUNT(*) FROM product_views WHERE created_at >= ?
 	`, thirtyDaysAgo).Scan(&totalViews)
 	if err != nil {
@@ -179,7 +232,7 @@ func (h *AdminHandler) GetAdminStats(c *fiber.Ctx) error {
 
 	// Chats initiated
 	var totalChats int
-	err = h.db.QueryRow(`
+	err = h.db.QueryRowContext(ctx, `
 		SELECT COUNT(*) FROM chats WHERE created_at >= ?
 	`, thirtyDaysAgo).Scan(&totalChats)
 	if err != nil {
@@ -188,7 +241,7 @@ func (h *AdminHandler) GetAdminStats(c *fiber.Ctx) error {
 
 	// Offers made
 	var totalOffers int
-	err = h.db.QueryRow(`
+	err = h.db.QueryRowContext(ctx, `
 		SELECT COUNT(*) FROM offers WHERE created_at >= ?
 	`, thirtyDaysAgo).Scan(&totalOffers)
 	if err != nil {
@@ -197,7 +250,7 @@ func (h *AdminHandler) GetAdminStats(c *fiber.Ctx) error {
 
 	// Completed transactions
 	var completedTransactions int
-	err = h.db.QueryRow(`
+	err = h.db.QueryRowContext(ctx, `
 		SELECT COUNT(*) FROM trades WHERE status = 'completed' AND created_at >= ?
 	`, thirtyDaysAgo).Scan(&completedTransactions)
 	if err != nil {
@@ -207,7 +260,7 @@ func (h *AdminHandler) GetAdminStats(c *fiber.Ctx) error {
 	// ===== TOP CATEGORIES =====
 
 	// Get top categories by share of active listings
-	categoryRows, err := h.db.Query(`
+	categoryRows, err := h.db.QueryContext(ctx, `
 		SELECT c.name, COUNT(p.id) as count
 		FROM categories c
 		LEFT JOIN products p ON p.category_id = c.id 
@@ -246,7 +299,7 @@ func (h *AdminHandler) GetAdminStats(c *fiber.Ctx) error {
 	// ===== TRANSACTION TRENDS CHART =====
 
 	// Get transaction data for chart (last 30 days) with multiple metrics
-	trendRows, err := h.db.Query(`
+	trendRows, err := h.db.QueryContext(ctx, `
 		SELECT 
 			DATE_FORMAT(created_at, '%Y-%m-%d') as date,
 			COUNT(*) as count,
@@ -281,7 +334,7 @@ func (h *AdminHandler) GetAdminStats(c *fiber.Ctx) error {
 	// ===== RECENT ADMIN ACTIVITY =====
 
 	// Get recent admin actions (reports, approvals, etc.)
-	activityRows, err := h.db.Query(`
+	activityRows, err := h.db.QueryContext(ctx, `
 		SELECT 
 			'Report' as action_type,
 			r.id,
@@ -341,7 +394,7 @@ func (h *AdminHandler) GetAdminStats(c *fiber.Ctx) error {
 		Percentage float64 `json:"percentage"`
 	}
 
-	priceRangeRows, err := h.db.Query(`
+	priceRangeRows, err := h.db.QueryContext(ctx, `
 		SELECT 
 			CASE 
 				WHEN price IS NULL OR price = 0 THEN 'Barter Only'
@@ -383,31 +436,14 @@ func (h *AdminHandler) GetAdminStats(c *fiber.Ctx) error {
 		Percentage float64 `json:"percentage"`
 	}
 
-	conditionRows, err := h.db.Query(`
-		SELECT 
-			COALESCE(condition, 'Not Specified') as condition,
-			COUNT(*) as count
-		FROM products 
-		WHERE status NOT IN ('sold', 'expired', 'draft') 
-		AND deleted_at IS NULL
-		GROUP BY condition
-		ORDER BY count DESC
-	`)
-	if err != nil {
-		conditionRows = nil
-	}
-
 	var conditionDistribution []ConditionData
-	if conditionRows != nil {
-		defer conditionRows.Close()
-		for conditionRows.Next() {
-			var cd ConditionData
-			if err := conditionRows.Scan(&cd.Condition, &cd.Count); err == nil {
-				if activeListings > 0 {
-					cd.Percentage = float64(cd.Count) / float64(activeListings) * 100
-				}
-				conditionDistribution = append(conditionDistribution, cd)
+	if buckets, err := groupProductCounts(ctx, h.db, "`condition`", "Not Specified", adminActiveListingFilter, 0); err == nil {
+		for _, b := range buckets {
+			cd := ConditionData{Condition: b.Label, Count: b.Count}
+			if activeListings > 0 {
+				cd.Percentage = float64(cd.Count) / float64(activeListings) * 100
 			}
+			conditionDistribution = append(conditionDistribution, cd)
 		}
 	}
 
@@ -418,7 +454,15 @@ func (h *AdminHandler) GetAdminStats(c *fiber.Ctx) error {
 		MeetupSpots []string `json:"meetup_spots"`
 	}
 
-	locationRows, err := h.db.Query(`
+	// Meetup spot names per city, from the meetup_spots table
+	spotsByCity := map[string][]string{}
+	if spots, err := loadMeetupSpots(h.db, ""); err == nil {
+		for _, spot := range spots {
+			spotsByCity[spot.City] = append(spotsByCity[spot.City], spot.Name)
+		}
+	}
+
+	locationRows, err := h.db.QueryContext(ctx, `
 		SELECT 
 			COALESCE(location, 'Not Specified') as location,
 			COUNT(*) as count
@@ -439,20 +483,9 @@ func (h *AdminHandler) GetAdminStats(c *fiber.Ctx) error {
 		for locationRows.Next() {
 			var ld LocationData
 			if err := locationRows.Scan(&ld.City, &ld.Count); err == nil {
-				// Add some sample meetup spots based on city
-				switch ld.City {
-				case "Manila":
-					ld.MeetupSpots = []string{"SM Mall of Asia", "Greenbelt Mall", "Robinsons Place", "Ayala Center"}
-				case "Quezon City":
-					ld.MeetupSpots = []string{"SM North EDSA", "Trinoma Mall", "Eastwood City", "UP Diliman"}
-				case "Makati":
-					ld.MeetupSpots = []string{"Glorietta", "Power Plant Mall", "Greenbelt", "Ayala Avenue"}
-				case "Taguig":
-					ld.MeetupSpots = []string{"BGC High Street", "Market Market", "SM Aura", "Venice Grand Canal"}
-				case "Pasig":
-					ld.MeetupSpots = []string{"Ortigas Center", "Tiendesitas", "Robinsons Galleria", "Eastwood"}
-				default:
-					ld.MeetupSpots = []string{"City Center", "Main Mall", "Public Market"}
+				ld.MeetupSpots = spotsByCity[ld.City]
+				if ld.MeetupSpots == nil {
+					ld.MeetupSpots = []string{}
 				}
 				locationAnalytics = append(locationAnalytics, ld)
 			}
@@ -467,36 +500,16 @@ func (h *AdminHandler) GetAdminStats(c *fiber.Ctx) error {
 		Color      string  `json:"color"`
 	}
 
-	categoryAnalyticsRows, err := h.db.Query(`
-		SELECT 
-			COALESCE(category, 'Uncategorized') as category,
-			COUNT(*) as count
-		FROM products 
-		WHERE status NOT IN ('sold', 'expired', 'draft') 
-		AND deleted_at IS NULL
-		GROUP BY category
-		ORDER BY count DESC
-		LIMIT 10
-	`)
-	if err != nil {
-		categoryAnalyticsRows = nil
-	}
-
 	var categoryAnalytics []CategoryAnalytics
 	colors := []string{"blue", "green", "purple", "orange", "teal", "pink", "red", "yellow", "cyan", "indigo"}
-	if categoryAnalyticsRows != nil {
-		defer categoryAnalyticsRows.Close()
-		colorIndex := 0
-		for categoryAnalyticsRows.Next() {
-			var ca CategoryAnalytics
-			if err := categoryAnalyticsRows.Scan(&ca.Category, &ca.Count); err == nil {
-				if activeListings > 0 {
-					ca.Percentage = float64(ca.Count) / float64(activeListings) * 100
-				}
-				ca.Color = colors[colorIndex%len(colors)]
-				categoryAnalytics = append(categoryAnalytics, ca)
-				colorIndex++
+	if buckets, err := groupProductCounts(ctx, h.db, "category", "Uncategorized", adminActiveListingFilter, 10); err == nil {
+		for colorIndex, b := range buckets {
+			ca := CategoryAnalytics{Category: b.Label, Count: b.Count}
+			if activeListings > 0 {
+				ca.Percentage = float64(ca.Count) / float64(activeListings) * 100
 			}
+			ca.Color = colors[colorIndex%len(colors)]
+			categoryAnalytics = append(categoryAnalytics, ca)
 		}
 	}
 
@@ -513,7 +526,7 @@ func (h *AdminHandler) GetAdminStats(c *fiber.Ctx) error {
 		Status     string    `json:"status"`
 	}
 
-	recentListingsRows, err := h.db.Query(`
+	recentListingsRows, err := h.db.QueryContext(ctx, `
 		SELECT 
 			p.id,
 			p.title,
@@ -546,6 +559,11 @@ func (h *AdminHandler) GetAdminStats(c *fiber.Ctx) error {
 		}
 	}
 
+	// Queries that failed on the deadline were skipped above; don't pass the gaps off as zeros
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	// ===== COMPILE ALL STATISTICS =====
 
 	stats := fiber.Map{
@@ -591,3 +609,306 @@ func (h *AdminHandler) GetAdminStats(c *fiber.Ctx) error {
 
 	return c.JSON(models.APIResponse{Success: true, Data: stats})
 }
+
+// SetListingLimit sets or clears a user's active listing cap override.
+// Body: { "limit": n } where null clears the override and 0 means unlimited.
+func (h *AdminHandler) SetListingLimit(c *fiber.Ctx) error {
+	userID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid user ID"})
+	}
+
+	var payload struct {
+		Limit *int `json:"limit"`
+	}
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid request body"})
+	}
+	if payload.Limit != nil && *payload.Limit < 0 {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Limit cannot be negative"})
+	}
+
+	result, err := h.db.Exec("UPDATE users SET listing_limit_override = ? WHERE id = ?", payload.Limit, userID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to update listing limit"})
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		var exists bool
+		if err := h.db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE id = ?)", userID).Scan(&exists); err != nil || !exists {
+			return c.Status(404).JSON(models.APIResponse{Success: false, Error: "User not found"})
+		}
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Listing limit updated",
+		Data:    fiber.Map{"user_id": userID, "listing_limit_override": payload.Limit},
+	})
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// recordAdminAction appends an entry to admin_audit_log
+func recordAdminAction(db execer, adminID int, action string, targetUserID int, details interface{}, ip string) error {
+	var detailsJSON interface{}
+	if details != nil {
+		b, err := json.Marshal(details)
+		if err != nil {
+			return err
+		}
+		detailsJSON = string(b)
+	}
+	_, err := db.Exec(
+		"INSERT INTO admin_audit_log (admin_id, action, target_user_id, details, ip_address) VALUES (?, ?, ?, ?, ?)",
+		adminID, action, targetUserID, detailsJSON, ip,
+	)
+	return err
+}
+
+// ImpersonateUser mints a short-lived read-only token to view the app as another user.
+// Admin accounts cannot be impersonated and every token issued is written to the audit log.
+func (h *AdminHandler) ImpersonateUser(c *fiber.Ctx) error {
+	adminID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "User not authenticated"})
+	}
+	targetID, err := strconv.Atoi(c.Params("userId"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid user ID"})
+	}
+	if targetID == adminID {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Cannot impersonate yourself"})
+	}
+
+	var name, email, role string
+	err = h.db.QueryRow("SELECT name, email, role FROM users WHERE id = ?", targetID).Scan(&name, &email, &role)
+	if err == sql.ErrNoRows {
+		return c.Status(404).JSON(models.APIResponse{Success: false, Error: "User not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to fetch user"})
+	}
+	if role == "admin" {
+		return c.Status(403).JSON(models.APIResponse{Success: false, Error: "Admin accounts cannot be impersonated"})
+	}
+
+	token, expiresAt, err := utils.GenerateImpersonationJWT(targetID, email, adminID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to create impersonation token"})
+	}
+
+	// No audit entry, no token
+	if err := recordAdminAction(h.db, adminID, "impersonate", targetID, fiber.Map{"expires_at": expiresAt, "read_only": true}, c.IP()); err != nil {
+		log.Printf("Failed to audit impersonation of user %d by admin %d: %v", targetID, adminID, err)
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to record impersonation"})
+	}
+	log.Printf("Admin %d started read-only impersonation of user %d", adminID, targetID)
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Read-only impersonation token issued",
+		Data: fiber.Map{
+			"token":      token,
+			"expires_at": expiresAt,
+			"read_only":  true,
+			"user":       fiber.Map{"id": targetID, "name": name, "email": email},
+		},
+	})
+}
+
+// GetModerationComments is the comment moderation queue.
+// ?status=removed (default), active or all; results are paginated and newest first.
+func (h *AdminHandler) GetModerationComments(c *fiber.Ctx) error {
+	where := "WHERE c.deleted_at IS NOT NULL"
+	switch c.Query("status", "removed") {
+	case "removed":
+	case "active":
+		where = "WHERE c.deleted_at IS NULL"
+	case "all":
+		where = ""
+	default:
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "status must be removed, active or all"})
+	}
+	pg, err := parsePagination(c)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: err.Error()})
+	}
+
+	var total int
+	if err := h.db.QueryRow("SELECT COUNT(*) FROM comments c " + where).Scan(&total); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to count comments"})
+	}
+
+	rows, err := h.db.Query(`
+		SELECT c.id, c.product_id, c.user_id, c.content, c.created_at, c.updated_at, u.name,
+			c.deleted_at, c.deleted_by, c.moderation_reason
+		FROM comments c
+		JOIN users u ON c.user_id = u.id
+		`+where+`
+		ORDER BY COALESCE(c.deleted_at, c.created_at) DESC, c.id DESC
+		LIMIT ? OFFSET ?`, pg.Limit, pg.Offset)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to fetch comments"})
+	}
+	defer rows.Close()
+
+	comments := []models.Comment{}
+	for rows.Next() {
+		comment, err := scanModeratedComment(rows)
+		if err != nil {
+			continue
+		}
+		comments = append(comments, comment)
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data: models.PaginatedResponse{
+			Data:       comments,
+			Total:      total,
+			Page:       pg.Page,
+			Limit:      pg.Limit,
+			TotalPages: (total + pg.Limit - 1) / pg.Limit,
+		},
+	})
+}
+
+// ModerateComment removes or restores a comment. Removal is a soft delete that keeps the
+// original content; both actions require an audit log entry or nothing changes.
+// Body: { "action": "remove" | "restore", "reason": "..." }
+func (h *AdminHandler) ModerateComment(c *fiber.Ctx) error {
+	adminID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "User not authenticated"})
+	}
+	commentID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid comment ID"})
+	}
+
+	var payload struct {
+		Action string `json:"action"`
+		Reason string `json:"reason"`
+	}
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid request body"})
+	}
+	payload.Reason = strings.TrimSpace(payload.Reason)
+	payload.Reason = services.TruncateRunes(payload.Reason, 255)
+	if payload.Action != "remove" && payload.Action != "restore" {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "action must be remove or restore"})
+	}
+	if payload.Action == "remove" && payload.Reason == "" {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "A reason is required to remove a comment"})
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to moderate comment"})
+	}
+	defer tx.Rollback()
+
+	var authorID, productID int
+	var deletedAt sql.NullTime
+	err = tx.QueryRow("SELECT user_id, product_id, deleted_at FROM comments WHERE id = ? FOR UPDATE", commentID).Scan(&authorID, &productID, &deletedAt)
+	if err == sql.ErrNoRows {
+		return c.Status(404).JSON(models.APIResponse{Success: false, Error: "Comment not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to fetch comment"})
+	}
+	if payload.Action == "remove" && deletedAt.Valid {
+		return c.Status(409).JSON(models.APIResponse{Success: false, Error: "Comment is already removed"})
+	}
+	if payload.Action == "restore" && !deletedAt.Valid {
+		return c.Status(409).JSON(models.APIResponse{Success: false, Error: "Comment is not removed"})
+	}
+
+	// updated_at = updated_at keeps moderation from looking like an edit by the author
+	if payload.Action == "remove" {
+		_, err = tx.Exec("UPDATE comments SET deleted_at = NOW(), deleted_by = ?, moderation_reason = ?, updated_at = updated_at WHERE id = ?", adminID, payload.Reason, commentID)
+	} else {
+		_, err = tx.Exec("UPDATE comments SET deleted_at = NULL, deleted_by = NULL, moderation_reason = NULL, updated_at = updated_at WHERE id = ?", commentID)
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to moderate comment"})
+	}
+
+	details := fiber.Map{"comment_id": commentID, "product_id": productID, "reason": payload.Reason}
+	if err := recordAdminAction(tx, adminID, "comment_"+payload.Action, authorID, details, c.IP()); err != nil {
+		log.Printf("Failed to audit comment %d moderation by admin %d: %v", commentID, adminID, err)
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to record moderation"})
+	}
+	if err := tx.Commit(); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to moderate comment"})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Comment " + payload.Action + "d",
+		Data:    fiber.Map{"comment_id": commentID, "removed": payload.Action == "remove"},
+	})
+}
+
+// GetCounterfeitSettings returns the active counterfeit detection thresholds, the
+// environment defaults, and the live category price medians they are applied to
+func (h *AdminHandler) GetCounterfeitSettings(c *fiber.Ctx) error {
+	medians, err := services.CategoryPriceMedians(h.db)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to load category medians"})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data: fiber.Map{
+			"settings":         services.CurrentCounterfeitSettings(),
+			"defaults":         services.DefaultCounterfeitSettings(),
+			"category_medians": medians,
+		},
+	})
+}
+
+// UpdateCounterfeitSettings replaces the counterfeit detection thresholds. Fields left
+// out of the body keep their current values; the change applies immediately.
+func (h *AdminHandler) UpdateCounterfeitSettings(c *fiber.Ctx) error {
+	adminID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "User not authenticated"})
+	}
+
+	settings := services.CurrentCounterfeitSettings()
+	if err := json.Unmarshal(c.Body(), &settings); err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid request body"})
+	}
+	saved, err := services.SaveCounterfeitSettings(h.db, settings, adminID)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCounterfeitSettings) {
+			return c.Status(400).JSON(models.APIResponse{Success: false, Error: err.Error()})
+		}
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to save counterfeit settings"})
+	}
+	return c.JSON(models.APIResponse{Success: true, Message: "Counterfeit settings updated", Data: saved})
+}
+
+// SetMaintenanceMode turns read-only maintenance mode on or off until the next restart,
+// when MAINTENANCE_MODE applies again.
+// Body: { "enabled": true, "message": "optional text shown to clients" }
+func (h *AdminHandler) SetMaintenanceMode(c *fiber.Ctx) error {
+	adminID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "User not authenticated"})
+	}
+	var payload struct {
+		Enabled *bool  `json:"enabled"`
+		Message string `json:"message"`
+	}
+	if err := c.BodyParser(&payload); err != nil || payload.Enabled == nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "enabled is required"})
+	}
+
+	state := middleware.SetMaintenanceMode(*payload.Enabled, payload.Message)
+	log.Printf("Admin %d set maintenance mode to %t", adminID, state.Enabled)
+	return c.JSON(models.APIResponse{Success: true, Message: "Maintenance mode updated", Data: state})
+}
@@ -2,15 +2,22 @@ package handlers
 
 import (
 	"database/sql"
+	"fmt"
+	"log"
 	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/xashathebest/clovia/config"
 	"github.com/xashathebest/clovia/database"
 	"github.com/xashathebest/clovia/middleware"
 	"github.com/xashathebest/clovia/models"
 	"github.com/xashathebest/clovia/services"
 )
 
+// profileAnalysisLimiter throttles on-demand profile analysis recomputes per caller
+var profileAnalysisLimiter = &messageRateLimiter{buckets: make(map[string]*tokenBucket)}
+
 type AIFeaturesHandler struct {
 	db *sql.DB
 }
@@ -46,7 +53,7 @@ func (h *AIFeaturesHandler) GetProximity(c *fiber.Ctx) error {
 
 	// Get current user's coordinates
 	var userLat, userLon sql.NullFloat64
-	err = h.db.QueryRow("SELECT latitude, longitude FROM users WHERE id = ?", userID).Scan(&userLat, &userLon)
+	err = h.db.QueryRowContext(c.UserContext(), "SELECT latitude, longitude FROM users WHERE id = ?", userID).Scan(&userLat, &userLon)
 	if err != nil {
 		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to get user location"})
 	}
@@ -64,7 +71,7 @@ func (h *AIFeaturesHandler) GetProximity(c *fiber.Ctx) error {
 	case "user":
 		// Calculate distance to another user
 		var targetLat, targetLon sql.NullFloat64
-		err = h.db.QueryRow("SELECT latitude, longitude FROM users WHERE id = ?", targetID).Scan(&targetLat, &targetLon)
+		err = h.db.QueryRowContext(c.UserContext(), "SELECT latitude, longitude FROM users WHERE id = ?", targetID).Scan(&targetLat, &targetLon)
 		if err != nil {
 			return c.Status(404).JSON(models.APIResponse{Success: false, Error: "Target user not found"})
 		}
@@ -83,7 +90,7 @@ func (h *AIFeaturesHandler) GetProximity(c *fiber.Ctx) error {
 	case "product":
 		// Calculate distance to a product
 		var productLat, productLon sql.NullFloat64
-		err = h.db.QueryRow("SELECT latitude, longitude FROM products WHERE id = ?", targetID).Scan(&productLat, &productLon)
+		err = h.db.QueryRowContext(c.UserContext(), "SELECT latitude, longitude FROM products WHERE id = ?", targetID).Scan(&productLat, &productLon)
 		if err != nil {
 			return c.Status(404).JSON(models.APIResponse{Success: false, Error: "Product not found"})
 		}
@@ -132,7 +139,7 @@ func (h *AIFeaturesHandler) GetResponseMetrics(c *fiber.Ctx) error {
 		// Note: In production, you might want to restrict viewing other users' metrics
 	}
 
-	metrics, err := services.CalculateResponseMetrics(h.db, targetUserID)
+	metrics, err := services.CalculateResponseMetrics(c.UserContext(), h.db, targetUserID)
 	if err != nil {
 		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to calculate response metrics"})
 	}
@@ -161,18 +168,35 @@ func (h *AIFeaturesHandler) GetProfileAnalysis(c *fiber.Ctx) error {
 		}
 	}
 
-	analysis, err := services.AnalyzeProfile(h.db, targetUserID)
+	now := time.Now()
+	cached, err := services.LoadCachedProfileAnalysis(h.db, targetUserID, services.ProfileAnalysisTTL(), now)
+	if err != nil {
+		log.Printf("profile analysis cache read for user %d failed: %v", targetUserID, err)
+	}
+	if cached != nil && !cached.Stale && !c.QueryBool("refresh") {
+		return c.JSON(models.APIResponse{Success: true, Data: cached})
+	}
+
+	// Recomputing is throttled per caller; a stale result beats no result
+	if !profileAnalysisLimiter.allow(fmt.Sprintf("profile-analysis:%d", userID), config.ProfileAnalysesPerMinute(), now) {
+		if cached != nil {
+			return c.JSON(models.APIResponse{Success: true, Data: cached})
+		}
+		return c.Status(429).JSON(models.APIResponse{Success: false, Error: "Too many profile analyses. Please wait a moment."})
+	}
+
+	fresh, err := services.RefreshProfileAnalysis(c.UserContext(), h.db, targetUserID)
 	if err != nil {
 		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to analyze profile"})
 	}
 
 	return c.JSON(models.APIResponse{
 		Success: true,
-		Data:    analysis,
+		Data:    fresh,
 	})
 }
 
-// AnalyzeAllProfiles analyzes all user profiles (admin only)
+// AnalyzeAllProfiles starts a background analysis of all user profiles (admin only)
 func (h *AIFeaturesHandler) AnalyzeAllProfiles(c *fiber.Ctx) error {
 	userID, ok := middleware.GetUserIDFromContext(c)
 	if !ok {
@@ -186,18 +210,38 @@ func (h *AIFeaturesHandler) AnalyzeAllProfiles(c *fiber.Ctx) error {
 		return c.Status(403).JSON(models.APIResponse{Success: false, Error: "Admin access required"})
 	}
 
-	summary, err := services.AnalyzeAllProfiles(h.db)
-	if err != nil {
-		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to analyze profiles"})
+	// The full pass runs in the background and fills the per-user cache; progress is
+	// polled at GetProfileAnalysisJob
+	job, started := services.StartProfileAnalysisJob(c.UserContext(), h.db)
+	message := "Profile analysis started"
+	if !started {
+		message = "Profile analysis is already running"
 	}
-
-	return c.JSON(models.APIResponse{
+	c.Set(fiber.HeaderLocation, profileAnalysisJobPath)
+	return c.Status(202).JSON(models.APIResponse{
 		Success: true,
-		Data:    summary,
+		Message: message,
+		Data:    job,
 	})
 }
 
-// GetCounterfeitReport returns counterfeit detection report for a product
+// profileAnalysisJobPath is where the analyze-all job started by AnalyzeAllProfiles is polled
+const profileAnalysisJobPath = "/api/ai/profile-analysis/all/status"
+
+// GetProfileAnalysisJob reports whether the analyze-all job is running and the summary of
+// the last finished run (admin only)
+func (h *AIFeaturesHandler) GetProfileAnalysisJob(c *fiber.Ctx) error {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "Unauthorized"})
+	}
+	if !isAdminUser(h.db, userID) {
+		return c.Status(403).JSON(models.APIResponse{Success: false, Error: "Admin access required"})
+	}
+	return c.JSON(models.APIResponse{Success: true, Data: services.CurrentProfileAnalysisJob()})
+}
+
+// GetCounterfeitReport returns counterfeit detection report for a product (seller or admin only)
 func (h *AIFeaturesHandler) GetCounterfeitReport(c *fiber.Ctx) error {
 	productIDStr := c.Params("id")
 	productID, err := strconv.Atoi(productIDStr)
@@ -205,20 +249,31 @@ func (h *AIFeaturesHandler) GetCounterfeitReport(c *fiber.Ctx) error {
 		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid product ID"})
 	}
 
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "User not authenticated"})
+	}
+
 	// Get product details
-	var title, description string
+	var title, description, category, currency string
 	var price sql.NullFloat64
-	err = h.db.QueryRow("SELECT title, description, price FROM products WHERE id = ?", productID).Scan(&title, &description, &price)
+	var sellerID int
+	err = h.db.QueryRowContext(c.UserContext(), "SELECT title, description, price, seller_id, COALESCE(category, ''), COALESCE(currency, 'PHP') FROM products WHERE id = ?", productID).Scan(&title, &description, &price, &sellerID, &category, &currency)
 	if err != nil {
 		return c.Status(404).JSON(models.APIResponse{Success: false, Error: "Product not found"})
 	}
 
+	// Counterfeit reports are moderation signals: seller and admins only
+	if userID != sellerID && !isAdminUser(h.db, userID) {
+		return c.Status(403).JSON(models.APIResponse{Success: false, Error: "Not authorized to view this report"})
+	}
+
 	productPrice := 0.0
 	if price.Valid {
 		productPrice = price.Float64
 	}
 
-	report := services.DetectCounterfeit(title, description, productPrice)
+	report := services.DetectCounterfeit(h.db, title, description, category, config.InDefaultCurrency(productPrice, currency))
 
 	return c.JSON(models.APIResponse{
 		Success: true,
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/services"
+)
+
+// getProfileAnalysis calls GET /ai/profile-analysis as userID and decodes the cached result
+func getProfileAnalysis(t *testing.T, app *fiber.App) services.CachedProfileAnalysis {
+	t.Helper()
+	resp, err := app.Test(httptest.NewRequest("GET", "/ai/profile-analysis", nil), -1)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("status %d, want 200", resp.StatusCode)
+	}
+	var body struct {
+		Data services.CachedProfileAnalysis `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	return body.Data
+}
+
+// TestProfileAnalysisCache serves a fresh cached analysis as is and recomputes a stale one
+func TestProfileAnalysisCache(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	t.Setenv("PROFILE_ANALYSIS_TTL", "1h")
+
+	user := createTestUser(t, db, "Analysis User")
+	t.Cleanup(func() { db.Exec("DELETE FROM profile_analysis_cache WHERE user_id = ?", user) })
+
+	// The marker recommendation is never produced by AnalyzeProfile, so seeing it means a cache hit
+	marker := "cached-marker"
+	if _, err := services.StoreProfileAnalysis(db, user, services.ProfileAnalysisResult{Recommendations: []string{marker}}); err != nil {
+		t.Fatalf("store analysis: %v", err)
+	}
+
+	h := &AIFeaturesHandler{db: db}
+	app := newTestApp(&user, func(app *fiber.App) { app.Get("/ai/profile-analysis", h.GetProfileAnalysis) })
+
+	hit := getProfileAnalysis(t, app)
+	if len(hit.Analysis.Recommendations) != 1 || hit.Analysis.Recommendations[0] != marker {
+		t.Errorf("fresh entry: recommendations %v, want the cached %q", hit.Analysis.Recommendations, marker)
+	}
+
+	if _, err := db.Exec("UPDATE profile_analysis_cache SET computed_at = ? WHERE user_id = ?", time.Now().Add(-2*time.Hour), user); err != nil {
+		t.Fatalf("age cache entry: %v", err)
+	}
+	fresh := getProfileAnalysis(t, app)
+	for _, r := range fresh.Analysis.Recommendations {
+		if r == marker {
+			t.Fatalf("stale entry was served instead of recomputed: %v", fresh.Analysis.Recommendations)
+		}
+	}
+	if fresh.Stale || time.Since(fresh.ComputedAt) > time.Minute {
+		t.Errorf("recomputed entry: stale=%v computed_at=%v, want a fresh result", fresh.Stale, fresh.ComputedAt)
+	}
+
+	cached, err := services.LoadCachedProfileAnalysis(db, user, time.Hour, time.Now())
+	if err != nil || cached == nil || cached.Stale {
+		t.Errorf("cache after recompute = %+v (err %v), want a fresh entry", cached, err)
+	}
+}
+
+// TestProfileAnalysisJobStatus checks the analyze-all status endpoint is admin only
+func TestProfileAnalysisJobStatus(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	user := createTestUser(t, db, "Status User")
+	admin := createTestUser(t, db, "Status Admin")
+	if _, err := db.Exec("UPDATE users SET role = 'admin' WHERE id = ?", admin); err != nil {
+		t.Fatalf("promote admin: %v", err)
+	}
+
+	h := &AIFeaturesHandler{db: db}
+	caller := user
+	app := newTestApp(&caller, func(app *fiber.App) {
+		app.Get("/ai/profile-analysis/all/status", h.GetProfileAnalysisJob)
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/ai/profile-analysis/all/status", nil), -1)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if resp.StatusCode != 403 {
+		t.Errorf("non-admin: status %d, want 403", resp.StatusCode)
+	}
+
+	caller = admin
+	resp, err = app.Test(httptest.NewRequest("GET", "/ai/profile-analysis/all/status", nil), -1)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if resp.StatusCode != 200 {
+		t.Errorf("admin: status %d, want 200", resp.StatusCode)
+	}
+	var body struct {
+		Data services.ProfileAnalysisJob `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	if want := services.CurrentProfileAnalysisJob(); body.Data.Running != want.Running {
+		t.Errorf("running = %v, want %v", body.Data.Running, want.Running)
+	}
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/middleware"
+	"github.com/xashathebest/clovia/models"
+	"github.com/xashathebest/clovia/services"
+)
+
+// CreateAnnouncement broadcasts a message to every user: it is stored for later reading,
+// added to each recipient's notifications, and pushed as an "announcement" event to
+// whoever is connected. Users who muted announcements only get critical ones.
+// Body: { "title": "...", "message": "...", "critical": false }
+func (h *AdminHandler) CreateAnnouncement(c *fiber.Ctx) error {
+	adminID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "User not authenticated"})
+	}
+	var payload struct {
+		Title    string `json:"title"`
+		Message  string `json:"message"`
+		Critical bool   `json:"critical"`
+	}
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid request body"})
+	}
+	payload.Title = strings.TrimSpace(payload.Title)
+	payload.Message = strings.TrimSpace(payload.Message)
+	if payload.Title == "" || payload.Message == "" {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Title and message are required"})
+	}
+	if len([]rune(payload.Title)) > 120 || len([]rune(payload.Message)) > 500 {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Title is limited to 120 characters and message to 500"})
+	}
+
+	res, err := h.db.Exec("INSERT INTO announcements (title, message, critical, created_by) VALUES (?, ?, ?, ?)",
+		payload.Title, payload.Message, payload.Critical, adminID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to save announcement"})
+	}
+	id, _ := res.LastInsertId()
+	announcement := models.Announcement{
+		ID:        int(id),
+		Title:     payload.Title,
+		Message:   payload.Message,
+		Critical:  payload.Critical,
+		CreatedBy: adminID,
+		CreatedAt: time.Now(),
+	}
+
+	recipients, err := services.AnnouncementRecipients(h.db, payload.Critical)
+	if err != nil {
+		log.Printf("Failed to load recipients for announcement %d: %v", id, err)
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Announcement saved but could not be delivered"})
+	}
+	stored, err := services.StoreAnnouncementNotifications(h.db, announcement, recipients, services.AnnouncementBatchSize)
+	if err != nil {
+		log.Printf("Announcement %d: stored %d of %d notifications: %v", id, stored, len(recipients), err)
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Announcement saved but delivery stopped partway",
+			Data:    fiber.Map{"announcement": announcement, "notified": stored, "recipients": len(recipients)},
+		})
+	}
+
+	evt := sseEvent{Type: "announcement", Data: announcement}
+	for _, uid := range recipients {
+		publishToUser(uid, evt)
+	}
+
+	return c.Status(201).JSON(models.APIResponse{
+		Success: true,
+		Message: "Announcement sent",
+		Data:    fiber.Map{"announcement": announcement, "notified": stored},
+	})
+}
+
+// GetAnnouncements lists announcements made since the user joined, newest first, so anyone
+// who was offline can catch up. Non-critical ones are hidden while announcements are muted.
+func (h *UserHandler) GetAnnouncements(c *fiber.Ctx) error {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "User not authenticated"})
+	}
+	pg, err := parsePagination(c)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: err.Error()})
+	}
+
+	const scope = `FROM announcements a JOIN users u ON u.id = ?
+		WHERE a.created_at >= u.created_at AND (a.critical OR u.mute_announcements = FALSE)`
+	var total int
+	if err := h.db.QueryRow("SELECT COUNT(*) "+scope, userID).Scan(&total); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to count announcements"})
+	}
+	rows, err := h.db.Query(
+		"SELECT a.id, a.title, a.message, a.critical, COALESCE(a.created_by, 0), a.created_at "+scope+" ORDER BY a.created_at DESC, a.id DESC LIMIT ? OFFSET ?",
+		userID, pg.Limit, pg.Offset,
+	)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to fetch announcements"})
+	}
+	defer rows.Close()
+	announcements := []models.Announcement{}
+	for rows.Next() {
+		var a models.Announcement
+		if err := rows.Scan(&a.ID, &a.Title, &a.Message, &a.Critical, &a.CreatedBy, &a.CreatedAt); err != nil {
+			return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to read announcements"})
+		}
+		announcements = append(announcements, a)
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data: models.PaginatedResponse{
+			Data:       announcements,
+			Total:      total,
+			Page:       pg.Page,
+			Limit:      pg.Limit,
+			TotalPages: (total + pg.Limit - 1) / pg.Limit,
+		},
+	})
+}
+
+// GetNotificationPreferences returns the authenticated user's notification opt-outs
+func (h *UserHandler) GetNotificationPreferences(c *fiber.Ctx) error {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "User not authenticated"})
+	}
+	var muted bool
+	if err := h.db.QueryRow("SELECT mute_announcements FROM users WHERE id = ?", userID).Scan(&muted); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to load preferences"})
+	}
+	return c.JSON(models.APIResponse{Success: true, Data: models.NotificationPreferences{Announcements: !muted}})
+}
+
+// UpdateNotificationPreferences turns non-critical announcements on or off.
+// Body: { "announcements": false }
+func (h *UserHandler) UpdateNotificationPreferences(c *fiber.Ctx) error {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "User not authenticated"})
+	}
+	var payload struct {
+		Announcements *bool `json:"announcements"`
+	}
+	if err := c.BodyParser(&payload); err != nil || payload.Announcements == nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "announcements must be true or false"})
+	}
+	if _, err := h.db.Exec("UPDATE users SET mute_announcements = ? WHERE id = ?", !*payload.Announcements, userID); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to update preferences"})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Notification preferences updated",
+		Data:    models.NotificationPreferences{Announcements: *payload.Announcements},
+	})
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/middleware"
+	"github.com/xashathebest/clovia/models"
+)
+
+// productWatchers lists everyone who wishlisted or saved a product, its seller excluded
+func productWatchers(db *sql.DB, productID int) ([]int, error) {
+	rows, err := db.Query(`
+		SELECT w.user_id FROM wishlists w JOIN products p ON p.id = w.product_id
+		WHERE w.product_id = ? AND w.user_id <> p.seller_id
+		UNION
+		SELECT sp.user_id FROM saved_products sp JOIN products p ON p.id = sp.product_id
+		WHERE sp.product_id = ? AND sp.user_id <> p.seller_id
+		  AND sp.deleted_at IS NULL`,
+		productID, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// alertBackInStock tells the watchers of each product that it is available again. Call it
+// after the unlocking transaction commits; products that were re-locked or sold in the
+// meantime are skipped.
+func alertBackInStock(db *sql.DB, productIDs []int) {
+	for _, pid := range productIDs {
+		var status, title string
+		if err := db.QueryRow("SELECT status, title FROM products WHERE id = ?", pid).Scan(&status, &title); err != nil || status != "available" {
+			continue
+		}
+		watchers, err := productWatchers(db, pid)
+		if err != nil {
+			log.Printf("Failed to load watchers of product %d: %v", pid, err)
+			continue
+		}
+		for _, uid := range watchers {
+			if _, err := db.Exec(
+				"INSERT INTO back_in_stock_alerts (user_id, product_id) VALUES (?, ?) ON DUPLICATE KEY UPDATE alerted_at = CURRENT_TIMESTAMP",
+				uid, pid,
+			); err != nil {
+				log.Printf("Failed to record back-in-stock alert for user %d, product %d: %v", uid, pid, err)
+			}
+			notify(db, uid, "back_in_stock", fmt.Sprintf("\"%s\" is available again", title), models.NotificationRefProduct, pid)
+			publishToUser(uid, sseEvent{Type: "product_back_in_stock", Data: fiber.Map{"product_id": pid, "title": title}})
+		}
+	}
+}
+
+// GetBackInStock lists the authenticated user's wishlisted or saved products that came back
+// on the market, most recent first. Alerts whose product was taken again drop off the list.
+func (h *ProductHandler) GetBackInStock(c *fiber.Ctx) error {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "User not authenticated"})
+	}
+	pg, err := parsePagination(c)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: err.Error()})
+	}
+
+	const scope = `FROM back_in_stock_alerts a JOIN products p ON p.id = a.product_id
+		WHERE a.user_id = ? AND p.status = 'available'`
+	var total int
+	if err := h.db.QueryRow("SELECT COUNT(*) "+scope, userID).Scan(&total); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to count alerts"})
+	}
+	rows, err := h.db.Query("SELECT a.product_id, a.alerted_at "+scope+" ORDER BY a.alerted_at DESC, a.id DESC LIMIT ? OFFSET ?", userID, pg.Limit, pg.Offset)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to fetch alerts"})
+	}
+	var alerts []models.BackInStockAlert
+	for rows.Next() {
+		var a models.BackInStockAlert
+		if err := rows.Scan(&a.Product.ID, &a.AlertedAt); err == nil {
+			alerts = append(alerts, a)
+		}
+	}
+	rows.Close()
+
+	items := []models.BackInStockAlert{}
+	for _, a := range alerts {
+		product, err := h.loadProductDetail("p.id = ?", a.Product.ID)
+		if err != nil {
+			log.Printf("Warning: failed to load product %d for back-in-stock list: %v", a.Product.ID, err)
+			continue
+		}
+		a.Product = product
+		items = append(items, a)
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data: models.PaginatedResponse{
+			Data:       items,
+			Total:      total,
+			Page:       pg.Page,
+			Limit:      pg.Limit,
+			TotalPages: (total + pg.Limit - 1) / pg.Limit,
+		},
+	})
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/xashathebest/clovia/database"
+	"github.com/xashathebest/clovia/models"
+)
+
+// TestCancelledTradeAlertsWatchers cancels an accepted trade and checks that a user who
+// wishlisted the locked target hears it is available again
+func TestCancelledTradeAlertsWatchers(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	database.DB = db
+
+	buyerID := createTestUser(t, db, "restock_buyer")
+	sellerID := createTestUser(t, db, "restock_seller")
+	watcherID := createTestUser(t, db, "restock_watcher")
+	target := createTestProduct(t, db, sellerID, "Wanted Item")
+	db.Exec("UPDATE products SET status = 'locked' WHERE id = ?", target)
+	db.Exec("INSERT INTO wishlists (user_id, product_id) VALUES (?, ?)", watcherID, target)
+	res, err := db.Exec("INSERT INTO trades (buyer_id, seller_id, target_product_id, status) VALUES (?, ?, ?, 'active')", buyerID, sellerID, target)
+	if err != nil {
+		t.Fatalf("insert trade: %v", err)
+	}
+	id64, _ := res.LastInsertId()
+	tradeID := int(id64)
+	t.Cleanup(func() { db.Exec("DELETE FROM trades WHERE id = ?", tradeID) })
+
+	trades := &TradeHandler{db: db}
+	products := NewProductHandler()
+	currentUser := buyerID
+	app := newTestApp(&currentUser, func(app *fiber.App) {
+		app.Put("/trades/:id", trades.UpdateTrade)
+		app.Get("/users/me/back-in-stock", products.GetBackInStock)
+	})
+
+	req := httptest.NewRequest("PUT", fmt.Sprintf("/trades/%d", tradeID), strings.NewReader(`{"action":"cancel"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, -1)
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatalf("cancel trade: %v %v", resp.StatusCode, err)
+	}
+
+	var notified int
+	db.QueryRow("SELECT COUNT(*) FROM notifications WHERE user_id = ? AND type = 'back_in_stock' AND reference_id = ?", watcherID, target).Scan(&notified)
+	if notified != 1 {
+		t.Errorf("watcher got %d back-in-stock notifications, want 1", notified)
+	}
+
+	currentUser = watcherID
+	resp, err = app.Test(httptest.NewRequest("GET", "/users/me/back-in-stock", nil), -1)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	var body models.APIResponse
+	json.NewDecoder(resp.Body).Decode(&body)
+	data, _ := body.Data.(map[string]interface{})
+	if resp.StatusCode != 200 || data["total"] != float64(1) {
+		t.Errorf("back-in-stock list: got %d %+v, want one alert", resp.StatusCode, body.Data)
+	}
+
+	// Once the item is taken again the alert is no longer actionable
+	db.Exec("UPDATE products SET status = 'sold' WHERE id = ?", target)
+	resp, _ = app.Test(httptest.NewRequest("GET", "/users/me/back-in-stock", nil), -1)
+	body = models.APIResponse{}
+	json.NewDecoder(resp.Body).Decode(&body)
+	data, _ = body.Data.(map[string]interface{})
+	if data["total"] != float64(0) {
+		t.Errorf("sold item still listed: %+v", body.Data)
+	}
+}
+
+// TestCancelledTradeLeavesSoldItemSold cancels a trade whose target was sold elsewhere and
+// checks the listing stays sold and nobody is told it is back
+func TestCancelledTradeLeavesSoldItemSold(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	database.DB = db
+
+	buyerID := createTestUser(t, db, "sold_buyer")
+	sellerID := createTestUser(t, db, "sold_seller")
+	watcherID := createTestUser(t, db, "sold_watcher")
+	target := createTestProduct(t, db, sellerID, "Sold Elsewhere")
+	db.Exec("UPDATE products SET status = 'sold' WHERE id = ?", target)
+	db.Exec("INSERT INTO wishlists (user_id, product_id) VALUES (?, ?)", watcherID, target)
+	res, err := db.Exec("INSERT INTO trades (buyer_id, seller_id, target_product_id, status) VALUES (?, ?, ?, 'pending')", buyerID, sellerID, target)
+	if err != nil {
+		t.Fatalf("insert trade: %v", err)
+	}
+	id64, _ := res.LastInsertId()
+	tradeID := int(id64)
+	t.Cleanup(func() { db.Exec("DELETE FROM trades WHERE id = ?", tradeID) })
+
+	trades := &TradeHandler{db: db}
+	currentUser := buyerID
+	app := newTestApp(&currentUser, func(app *fiber.App) { app.Put("/trades/:id", trades.UpdateTrade) })
+	req := httptest.NewRequest("PUT", fmt.Sprintf("/trades/%d", tradeID), strings.NewReader(`{"action":"cancel"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, -1)
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatalf("cancel trade: %v %v", resp.StatusCode, err)
+	}
+
+	var status string
+	db.QueryRow("SELECT status FROM products WHERE id = ?", target).Scan(&status)
+	if status != "sold" {
+		t.Errorf("status = %q, want sold", status)
+	}
+	var notified int
+	db.QueryRow("SELECT COUNT(*) FROM notifications WHERE user_id = ? AND type = 'back_in_stock' AND reference_id = ?", watcherID, target).Scan(&notified)
+	if notified != 0 {
+		t.Errorf("watcher got %d back-in-stock notifications, want 0", notified)
+	}
+}
@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"bufio"
+	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"strconv"
@@ -9,6 +11,7 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/xashathebest/clovia/config"
 	"github.com/xashathebest/clovia/database"
 	"github.com/xashathebest/clovia/middleware"
 	"github.com/xashathebest/clovia/models"
@@ -80,35 +83,40 @@ func (h *ChatHandler) Stream(c *fiber.Ctx) error {
 	c.Set("Cache-Control", "no-cache")
 	c.Set("Connection", "keep-alive")
 
-	msgCh := make(chan []byte, 32)
+	msgCh := newSSEChannel()
 	// register
-	userStreams.Lock()
-	userStreams.m[userID] = append(userStreams.m[userID], msgCh)
-	userStreams.Unlock()
-
-	// cleanup on finish
-	defer func() {
-		userStreams.Lock()
-		subs := userStreams.m[userID]
-		for i, ch := range subs {
-			if ch == msgCh {
-				userStreams.m[userID] = append(subs[:i], subs[i+1:]...)
-				break
-			}
-		}
-		userStreams.Unlock()
-		close(msgCh)
-	}()
+	if registerStream(userID, msgCh) {
+		go announcePresence(database.DB, userID, true)
+	}
 
+	// The body writer runs after this handler returns, so unregister from inside
+	// it once a write fails. Heartbeats make a silent disconnect show up promptly.
 	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer func() {
+			forgetStream(msgCh)
+			if unregisterStream(userID, msgCh) {
+				announcePresence(database.DB, userID, false)
+			}
+		}()
+		heartbeat := time.NewTicker(config.GetEnvDuration("SSE_HEARTBEAT_INTERVAL", 25*time.Second))
+		defer heartbeat.Stop()
 		for {
-			if b, ok := <-msgCh; ok {
+			select {
+			case b := <-msgCh:
+				w.WriteString("data: ")
+				w.Write(b)
+				w.WriteString("\n\n")
+			case <-heartbeat.C:
+				w.WriteString(": ping\n\n")
+			}
+			// A critical event that did not fit in the buffer is replaced by one resync
+			if b, ok := takeResync(msgCh); ok {
 				w.WriteString("data: ")
 				w.Write(b)
 				w.WriteString("\n\n")
-				w.Flush()
-			} else {
-				break
+			}
+			if err := w.Flush(); err != nil {
+				return
 			}
 		}
 	})
@@ -124,11 +132,9 @@ func publishToUser(userID int, evt sseEvent) {
 		return
 	}
 	payload, _ := json.Marshal(evt)
+	strategy := config.SSEOverflowStrategy()
 	for _, ch := range subs {
-		select {
-		case ch <- payload:
-		default:
-		}
+		offerSSE(ch, evt.Type, payload, strategy)
 	}
 }
 
@@ -137,12 +143,51 @@ func publishNotification(userID int, message string) {
 	publishToUser(userID, sseEvent{Type: "notification", Data: fiber.Map{"message": message}})
 }
 
-// EnsureConversation creates or returns an existing conversation
+// EnsureConversation creates or returns an existing conversation.
+// The authenticated user must be one of the participants and the seller must own the product.
+// SellerID defaults to the product owner and BuyerID to the caller when omitted.
 func (h *ChatHandler) EnsureConversation(c *fiber.Ctx) error {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return fiber.ErrUnauthorized
+	}
 	var p struct{ ProductID, BuyerID, SellerID int }
 	if err := c.BodyParser(&p); err != nil {
 		return fiber.ErrBadRequest
 	}
+	if p.ProductID <= 0 {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Product ID is required"})
+	}
+
+	var ownerID int
+	err := database.DB.QueryRow("SELECT seller_id FROM products WHERE id = ?", p.ProductID).Scan(&ownerID)
+	if err == sql.ErrNoRows {
+		return c.Status(404).JSON(models.APIResponse{Success: false, Error: "Product not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to check product"})
+	}
+	if p.SellerID == 0 {
+		p.SellerID = ownerID
+	}
+	if p.BuyerID == 0 {
+		p.BuyerID = userID
+	}
+
+	if p.SellerID != ownerID {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Seller does not own this product"})
+	}
+	if p.BuyerID == p.SellerID {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Cannot start a conversation with yourself"})
+	}
+	if userID != p.BuyerID && userID != p.SellerID {
+		return c.Status(403).JSON(models.APIResponse{Success: false, Error: "You must be a participant in the conversation"})
+	}
+	var buyerExists bool
+	if err := database.DB.QueryRow("SELECT COUNT(*) > 0 FROM users WHERE id = ?", p.BuyerID).Scan(&buyerExists); err != nil || !buyerExists {
+		return c.Status(404).JSON(models.APIResponse{Success: false, Error: "Buyer not found"})
+	}
+
 	id, err := ensureConversation(p.ProductID, p.BuyerID, p.SellerID)
 	if err != nil {
 		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to start conversation"})
@@ -166,6 +211,12 @@ func (h *ChatHandler) SendMessage(c *fiber.Ctx) error {
 	if p.ConversationID == 0 || p.Content == "" {
 		return fiber.ErrBadRequest
 	}
+	if messageTooLong(p.Content) {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: fmt.Sprintf("Message exceeds %d characters", config.MaxMessageLength())})
+	}
+	if !allowMessage("chat", userID, p.ConversationID) {
+		return c.Status(429).JSON(models.APIResponse{Success: false, Error: "You are sending messages too quickly. Please wait a moment."})
+	}
 	msgID, createdAt, err := saveMessage(p.ConversationID, userID, p.Content)
 	if err != nil {
 		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to send message"})
@@ -181,6 +232,7 @@ func (h *ChatHandler) SendMessage(c *fiber.Ctx) error {
 	for _, pid := range participants {
 		publishToUser(pid, evt)
 	}
+	notifyNewMessage(database.DB, p.ConversationID, userID, participants, p.Content)
 	return c.JSON(models.APIResponse{Success: true})
 }
 
@@ -236,7 +288,7 @@ func saveMessage(conversationID, senderID int, content string) (int, time.Time,
 
 // updateUserResponseMetrics updates response metrics for a user
 func updateUserResponseMetrics(userID int) {
-	metrics, err := services.CalculateResponseMetrics(database.DB, userID)
+	metrics, err := services.CalculateResponseMetrics(context.Background(), database.DB, userID)
 	if err != nil {
 		return
 	}
@@ -268,7 +320,10 @@ func (h *ChatHandler) GetConversations(c *fiber.Ctx) error {
 	if !ok {
 		return fiber.ErrUnauthorized
 	}
-	rows, err := database.DB.Query("SELECT id, product_id, buyer_id, seller_id, created_at, updated_at FROM conversations WHERE buyer_id = ? OR seller_id = ? ORDER BY updated_at DESC", userID, userID)
+	rows, err := database.DB.Query(`SELECT c.id, c.product_id, c.buyer_id, c.seller_id, c.created_at, c.updated_at, cm.user_id IS NOT NULL
+		FROM conversations c
+		LEFT JOIN conversation_mutes cm ON cm.conversation_id = c.id AND cm.user_id = ?
+		WHERE c.buyer_id = ? OR c.seller_id = ? ORDER BY c.updated_at DESC`, userID, userID, userID)
 	if err != nil {
 		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to get conversations"})
 	}
@@ -276,7 +331,7 @@ func (h *ChatHandler) GetConversations(c *fiber.Ctx) error {
 	var list []models.ChatConversation
 	for rows.Next() {
 		var conv models.ChatConversation
-		if err := rows.Scan(&conv.ID, &conv.ProductID, &conv.BuyerID, &conv.SellerID, &conv.CreatedAt, &conv.UpdatedAt); err == nil {
+		if err := rows.Scan(&conv.ID, &conv.ProductID, &conv.BuyerID, &conv.SellerID, &conv.CreatedAt, &conv.UpdatedAt, &conv.Muted); err == nil {
 			list = append(list, conv)
 		}
 	}
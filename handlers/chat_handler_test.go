@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/xashathebest/clovia/database"
+)
+
+// TestEnsureConversationValidatesParticipants covers self-conversations, impersonation and wrong sellers
+func TestEnsureConversationValidatesParticipants(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	database.DB = db
+
+	sellerID := createTestUser(t, db, "seller")
+	buyerID := createTestUser(t, db, "buyer")
+	outsiderID := createTestUser(t, db, "outsider")
+	productID := createTestProduct(t, db, sellerID, "Chat Item")
+	defer db.Exec("DELETE FROM conversations WHERE product_id = ?", productID)
+	defer db.Exec("DELETE FROM products WHERE id = ?", productID)
+
+	handler := NewChatHandler()
+	currentUser := buyerID
+	app := newTestApp(&currentUser, func(app *fiber.App) { app.Post("/conversations", handler.EnsureConversation) })
+	post := func(asUser int, body string) int {
+		currentUser = asUser
+		req := httptest.NewRequest("POST", "/conversations", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		return resp.StatusCode
+	}
+
+	cases := []struct {
+		name   string
+		asUser int
+		body   string
+		want   int
+	}{
+		{"seller chatting with themselves", sellerID, fmt.Sprintf(`{"ProductID":%d,"BuyerID":%d,"SellerID":%d}`, productID, sellerID, sellerID), 400},
+		{"seller id does not own product", buyerID, fmt.Sprintf(`{"ProductID":%d,"BuyerID":%d,"SellerID":%d}`, productID, buyerID, outsiderID), 400},
+		{"caller is not a participant", outsiderID, fmt.Sprintf(`{"ProductID":%d,"BuyerID":%d,"SellerID":%d}`, productID, buyerID, sellerID), 403},
+		{"buyer starts conversation", buyerID, fmt.Sprintf(`{"ProductID":%d}`, productID), 200},
+	}
+	for _, tc := range cases {
+		if got := post(tc.asUser, tc.body); got != tc.want {
+			t.Errorf("%s: expected %d, got %d", tc.name, tc.want, got)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"database/sql"
 	"strconv"
 
 	"github.com/gofiber/fiber/v2"
@@ -68,19 +69,29 @@ func (h *CommentHandler) CreateComment(c *fiber.Ctx) error {
 	})
 }
 
-// GetComments retrieves all comments for a product
+// GetComments retrieves all comments for a product. Moderated comments are hidden,
+// except for admins who see them flagged as removed.
 func (h *CommentHandler) GetComments(c *fiber.Ctx) error {
 	productID, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
 		return fiber.ErrBadRequest
 	}
 
+	viewerIsAdmin := false
+	if viewerID, ok := middleware.GetUserIDFromContext(c); ok {
+		viewerIsAdmin = isAdminUser(database.DB, viewerID)
+	}
+
 	query := `
-		SELECT c.id, c.product_id, c.user_id, c.content, c.created_at, c.updated_at, u.name 
+		SELECT c.id, c.product_id, c.user_id, c.content, c.created_at, c.updated_at, u.name,
+			c.deleted_at, c.deleted_by, c.moderation_reason
 		FROM comments c
 		JOIN users u ON c.user_id = u.id
-		WHERE c.product_id = ? 
-		ORDER BY c.created_at DESC`
+		WHERE c.product_id = ? `
+	if !viewerIsAdmin {
+		query += "AND c.deleted_at IS NULL "
+	}
+	query += "ORDER BY c.created_at DESC"
 	rows, err := database.DB.Query(query, productID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(models.APIResponse{
@@ -92,11 +103,7 @@ func (h *CommentHandler) GetComments(c *fiber.Ctx) error {
 
 	var comments []models.Comment
 	for rows.Next() {
-		var comment models.Comment
-		err := rows.Scan(
-			&comment.ID, &comment.ProductID, &comment.UserID, &comment.Content,
-			&comment.CreatedAt, &comment.UpdatedAt, &comment.CommenterName,
-		)
+		comment, err := scanModeratedComment(rows)
 		if err != nil {
 			continue
 		}
@@ -108,3 +115,23 @@ func (h *CommentHandler) GetComments(c *fiber.Ctx) error {
 		Data:    comments,
 	})
 }
+
+// scanModeratedComment reads a comment row followed by its moderation columns
+func scanModeratedComment(rows *sql.Rows) (models.Comment, error) {
+	var comment models.Comment
+	var deletedBy sql.NullInt64
+	err := rows.Scan(
+		&comment.ID, &comment.ProductID, &comment.UserID, &comment.Content,
+		&comment.CreatedAt, &comment.UpdatedAt, &comment.CommenterName,
+		&comment.DeletedAt, &deletedBy, &comment.ModerationReason,
+	)
+	if err != nil {
+		return comment, err
+	}
+	if deletedBy.Valid {
+		id := int(deletedBy.Int64)
+		comment.DeletedBy = &id
+	}
+	comment.Removed = comment.DeletedAt != nil
+	return comment, nil
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/xashathebest/clovia/database"
+	"github.com/xashathebest/clovia/models"
+)
+
+// TestModerateCommentHidesFromPublicAndAudits removes a comment and checks who still sees it
+func TestModerateCommentHidesFromPublicAndAudits(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	database.DB = db
+
+	adminID := createTestUser(t, db, "moderator")
+	db.Exec("UPDATE users SET role = 'admin' WHERE id = ?", adminID)
+	authorID := createTestUser(t, db, "commenter")
+	productID := createTestProduct(t, db, authorID, "Commented Item")
+	defer db.Exec("DELETE FROM products WHERE id = ?", productID)
+	defer db.Exec("DELETE FROM admin_audit_log WHERE admin_id = ?", adminID)
+
+	res, err := db.Exec("INSERT INTO comments (product_id, user_id, content) VALUES (?, ?, 'rude words')", productID, authorID)
+	if err != nil {
+		t.Fatalf("failed to create comment: %v", err)
+	}
+	commentID, _ := res.LastInsertId()
+
+	comments := NewCommentHandler()
+	admin := NewAdminHandler()
+	currentUser := adminID
+	app := newTestApp(&currentUser, func(app *fiber.App) {
+		app.Get("/products/:id/comments", comments.GetComments)
+		app.Put("/admin/comments/:id/moderate", admin.ModerateComment)
+	})
+
+	req := httptest.NewRequest("PUT", fmt.Sprintf("/admin/comments/%d/moderate", commentID), strings.NewReader(`{"action":"remove","reason":"abusive"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, -1)
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatalf("moderate: expected 200, got %v (%v)", resp.StatusCode, err)
+	}
+
+	list := func(asUser int) []models.Comment {
+		currentUser = asUser
+		resp, err := app.Test(httptest.NewRequest("GET", fmt.Sprintf("/products/%d/comments", productID), nil), -1)
+		if err != nil {
+			t.Fatalf("list failed: %v", err)
+		}
+		var body struct {
+			Data []models.Comment `json:"data"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		return body.Data
+	}
+
+	if got := list(authorID); len(got) != 0 {
+		t.Errorf("expected removed comment hidden from non-admins, got %d comments", len(got))
+	}
+	if got := list(adminID); len(got) != 1 || !got[0].Removed {
+		t.Errorf("expected admins to see the comment marked removed, got %+v", got)
+	}
+
+	var audited int
+	db.QueryRow("SELECT COUNT(*) FROM admin_audit_log WHERE admin_id = ? AND action = 'comment_remove' AND target_user_id = ?", adminID, authorID).Scan(&audited)
+	if audited != 1 {
+		t.Errorf("expected one audit entry, got %d", audited)
+	}
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"database/sql"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/database"
+	"github.com/xashathebest/clovia/middleware"
+	"github.com/xashathebest/clovia/models"
+)
+
+// messagePreviewLength caps how much of a message is quoted in its notification
+const messagePreviewLength = 80
+
+// MuteConversation silences notifications that reference one conversation for the caller.
+// Messages still arrive on the stream and in GetMessages.
+func (h *ChatHandler) MuteConversation(c *fiber.Ctx) error {
+	return h.setConversationMuted(c, true)
+}
+
+// UnmuteConversation restores notifications for the conversation for the caller
+func (h *ChatHandler) UnmuteConversation(c *fiber.Ctx) error {
+	return h.setConversationMuted(c, false)
+}
+
+func (h *ChatHandler) setConversationMuted(c *fiber.Ctx, muted bool) error {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "User not authenticated"})
+	}
+	convID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid conversation ID"})
+	}
+
+	var buyerID, sellerID int
+	err = database.DB.QueryRow("SELECT buyer_id, seller_id FROM conversations WHERE id = ?", convID).Scan(&buyerID, &sellerID)
+	if err == sql.ErrNoRows {
+		return c.Status(404).JSON(models.APIResponse{Success: false, Error: "Conversation not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to load conversation"})
+	}
+	if userID != buyerID && userID != sellerID {
+		return c.Status(403).JSON(models.APIResponse{Success: false, Error: "Not a participant in this conversation"})
+	}
+
+	if muted {
+		_, err = database.DB.Exec("INSERT IGNORE INTO conversation_mutes (conversation_id, user_id) VALUES (?, ?)", convID, userID)
+	} else {
+		_, err = database.DB.Exec("DELETE FROM conversation_mutes WHERE conversation_id = ? AND user_id = ?", convID, userID)
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to update mute setting"})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    fiber.Map{"conversation_id": convID, "muted": muted},
+	})
+}
+
+// messagePreview shortens content for a notification, cutting on a rune boundary
+func messagePreview(content string) string {
+	runes := []rune(content)
+	if len(runes) <= messagePreviewLength {
+		return content
+	}
+	return string(runes[:messagePreviewLength]) + "…"
+}
+
+// notifyNewMessage notifies every participant except the sender through notify, which
+// skips anyone who muted the conversation. A participant who still has an unread message
+// notification for the conversation is not notified again, so a busy chat leaves one row.
+func notifyNewMessage(db *sql.DB, conversationID, senderID int, participants []int, content string) {
+	var senderName string
+	_ = db.QueryRow("SELECT name FROM users WHERE id = ?", senderID).Scan(&senderName)
+	message := senderName + ": " + messagePreview(content)
+
+	for _, pid := range participants {
+		if pid == senderID {
+			continue
+		}
+		var pending bool
+		err := db.QueryRow(`
+			SELECT COUNT(*) > 0 FROM notifications
+			WHERE user_id = ? AND type = 'message' AND reference_type = ? AND reference_id = ? AND is_read = FALSE`,
+			pid, models.NotificationRefConversation, conversationID).Scan(&pending)
+		if err != nil || pending {
+			continue
+		}
+		notify(db, pid, "message", message, models.NotificationRefConversation, conversationID)
+	}
+}
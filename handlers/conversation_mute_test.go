@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/database"
+)
+
+func TestMessagePreview(t *testing.T) {
+	if got := messagePreview("short"); got != "short" {
+		t.Errorf("short preview = %q", got)
+	}
+	long := strings.Repeat("é", messagePreviewLength+5)
+	if got := messagePreview(long); len([]rune(got)) != messagePreviewLength+1 || !strings.HasSuffix(got, "…") {
+		t.Errorf("long preview = %q, want %d runes plus an ellipsis", got, messagePreviewLength)
+	}
+}
+
+// TestMutedConversationSkipsNotifications mutes as the seller and checks conversation
+// notifications reach only the buyer
+func TestMutedConversationSkipsNotifications(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	database.DB = db
+
+	sellerID := createTestUser(t, db, "mute_seller")
+	buyerID := createTestUser(t, db, "mute_buyer")
+	productID := createTestProduct(t, db, sellerID, "Mute Item")
+	convID, err := ensureConversation(productID, buyerID, sellerID)
+	if err != nil {
+		t.Fatalf("ensureConversation: %v", err)
+	}
+	defer db.Exec("DELETE FROM conversations WHERE id = ?", convID)
+
+	handler := NewChatHandler()
+	currentUser := sellerID
+	app := newTestApp(&currentUser, func(app *fiber.App) {
+		app.Post("/conversations/:id/mute", handler.MuteConversation)
+		app.Post("/messages", handler.SendMessage)
+	})
+	resp, err := app.Test(httptest.NewRequest("POST", fmt.Sprintf("/conversations/%d/mute", convID), nil), -1)
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatalf("mute = %v, %v; want 200", resp, err)
+	}
+
+	send := func(asUser int) {
+		currentUser = asUser
+		req := httptest.NewRequest("POST", "/messages", strings.NewReader(fmt.Sprintf(`{"ConversationID":%d,"Content":"hello"}`, convID)))
+		req.Header.Set("Content-Type", "application/json")
+		if resp, err := app.Test(req, -1); err != nil || resp.StatusCode != 200 {
+			t.Fatalf("send as %d = %v, %v", asUser, resp, err)
+		}
+	}
+	defer db.Exec("DELETE FROM notifications WHERE reference_type = 'conversation' AND reference_id = ?", convID)
+	send(buyerID)
+	send(sellerID)
+	send(sellerID)
+
+	count := func(userID int) int {
+		var n int
+		_ = db.QueryRow("SELECT COUNT(*) FROM notifications WHERE user_id = ? AND reference_type = 'conversation' AND reference_id = ?", userID, convID).Scan(&n)
+		return n
+	}
+	if n := count(sellerID); n != 0 {
+		t.Errorf("muted seller got %d notifications, want 0", n)
+	}
+	// Two messages while the first notification is unread collapse into one row
+	if n := count(buyerID); n != 1 {
+		t.Errorf("buyer got %d notifications, want 1", n)
+	}
+	var messages int
+	_ = db.QueryRow("SELECT COUNT(*) FROM messages WHERE conversation_id = ?", convID).Scan(&messages)
+	if messages != 3 {
+		t.Errorf("saved %d messages, want 3", messages)
+	}
+}
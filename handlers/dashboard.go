@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"database/sql"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/config"
+	"github.com/xashathebest/clovia/middleware"
+	"github.com/xashathebest/clovia/models"
+)
+
+// dashboardActivityPreview is how many recent activity entries the dashboard shows
+const dashboardActivityPreview = 5
+
+// dashboardCache keeps each user's last dashboard for DASHBOARD_CACHE_TTL (default 15s),
+// so a home screen that is reopened or refreshed does not rerun every query
+var dashboardCache = struct {
+	sync.Mutex
+	entries map[int]dashboardCacheEntry
+}{entries: map[int]dashboardCacheEntry{}}
+
+type dashboardCacheEntry struct {
+	data      models.Dashboard
+	expiresAt time.Time
+}
+
+// cachedDashboard returns the user's cached dashboard while it is fresh
+func cachedDashboard(userID int, now time.Time) (models.Dashboard, bool) {
+	dashboardCache.Lock()
+	defer dashboardCache.Unlock()
+	entry, ok := dashboardCache.entries[userID]
+	if !ok || !now.Before(entry.expiresAt) {
+		return models.Dashboard{}, false
+	}
+	return entry.data, true
+}
+
+// storeDashboard caches a dashboard and drops expired entries so the map stays bounded
+// by recently active users
+func storeDashboard(userID int, data models.Dashboard, now time.Time) {
+	ttl := config.GetEnvDuration("DASHBOARD_CACHE_TTL", 15*time.Second)
+	if ttl <= 0 {
+		return
+	}
+	dashboardCache.Lock()
+	defer dashboardCache.Unlock()
+	for id, entry := range dashboardCache.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(dashboardCache.entries, id)
+		}
+	}
+	dashboardCache.entries[userID] = dashboardCacheEntry{data: data, expiresAt: now.Add(ttl)}
+}
+
+// GetMyDashboard returns the home screen in one payload: unread notifications, pending
+// trades each way, active deliveries, saved and wishlist counts and the latest activity.
+// The sections are loaded concurrently and the result is cached briefly per user.
+func (h *UserHandler) GetMyDashboard(c *fiber.Ctx) error {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "User not authenticated"})
+	}
+	now := time.Now()
+	if cached, ok := cachedDashboard(userID, now); ok {
+		return c.JSON(models.APIResponse{Success: true, Data: cached})
+	}
+
+	dash := models.Dashboard{ActiveDeliveries: []models.DeliverySummary{}, GeneratedAt: now}
+	sections := []func() error{
+		func() error {
+			return h.db.QueryRow("SELECT COUNT(*) FROM notifications WHERE user_id = ? AND is_read = FALSE", userID).Scan(&dash.UnreadNotifications)
+		},
+		func() (err error) {
+			dash.IncomingPendingTrades, err = countTrades(h.db, userID, "incoming", "pending")
+			return err
+		},
+		func() (err error) {
+			dash.OutgoingPendingTrades, err = countTrades(h.db, userID, "outgoing", "pending")
+			return err
+		},
+		func() error {
+			return h.db.QueryRow("SELECT COUNT(*) FROM saved_products WHERE user_id = ? AND deleted_at IS NULL", userID).Scan(&dash.SavedCount)
+		},
+		func() error {
+			return h.db.QueryRow("SELECT COUNT(*) FROM wishlists WHERE user_id = ?", userID).Scan(&dash.WishlistCount)
+		},
+		func() error {
+			feed, err := loadActivityFeed(h.db, userID, nil, dashboardActivityPreview)
+			dash.RecentActivity = feed.Entries
+			return err
+		},
+	}
+	if config.DeliveryEnabled() {
+		sections = append(sections, func() (err error) {
+			dash.ActiveDeliveries, err = loadActiveDeliverySummaries(h.db, userID)
+			return err
+		})
+	}
+
+	// Each section writes its own field, so they can run side by side
+	errs := make([]error, len(sections))
+	var wg sync.WaitGroup
+	for i, load := range sections {
+		wg.Add(1)
+		go func(i int, load func() error) {
+			defer wg.Done()
+			errs[i] = load()
+		}(i, load)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to load dashboard"})
+		}
+	}
+
+	storeDashboard(userID, dash, now)
+	return c.JSON(models.APIResponse{Success: true, Data: dash})
+}
+
+// loadActiveDeliverySummaries lists the user's deliveries that are still being arranged or
+// carried, most recently updated first
+func loadActiveDeliverySummaries(db *sql.DB, userID int) ([]models.DeliverySummary, error) {
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(activeDeliveryStatuses)), ",")
+	args := []interface{}{userID}
+	for _, s := range activeDeliveryStatuses {
+		args = append(args, s)
+	}
+	rows, err := db.Query(`
+		SELECT d.id, d.trade_id, d.delivery_type, d.status, d.item_count, COALESCE(r.name, ''), d.estimated_eta, d.updated_at
+		FROM deliveries d
+		LEFT JOIN riders r ON r.id = d.rider_id
+		WHERE d.user_id = ? AND d.status IN (`+placeholders+`)
+		ORDER BY d.updated_at DESC, d.id DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	summaries := []models.DeliverySummary{}
+	for rows.Next() {
+		var d models.DeliverySummary
+		var tradeID sql.NullInt64
+		var eta sql.NullTime
+		if err := rows.Scan(&d.ID, &tradeID, &d.DeliveryType, &d.Status, &d.ItemCount, &d.RiderName, &eta, &d.UpdatedAt); err != nil {
+			return nil, err
+		}
+		d.TradeID = nullIntPtr(tradeID)
+		if eta.Valid {
+			t := eta.Time
+			d.EstimatedETA = &t
+		}
+		summaries = append(summaries, d)
+	}
+	return summaries, rows.Err()
+}
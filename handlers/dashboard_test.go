@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/models"
+)
+
+// TestGetMyDashboard checks the counts on a fresh dashboard and that a repeat request
+// within the cache window is served from the cache
+func TestGetMyDashboard(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	t.Setenv("DASHBOARD_CACHE_TTL", "1m")
+
+	seller := createTestUser(t, db, "Dashboard Seller")
+	buyer := createTestUser(t, db, "Dashboard Buyer")
+	product := createTestProduct(t, db, seller, "Dashboard Lamp")
+	t.Cleanup(func() { db.Exec("DELETE FROM products WHERE id = ?", product) })
+	if _, err := db.Exec("INSERT INTO trades (buyer_id, seller_id, target_product_id, status) VALUES (?, ?, ?, 'pending')", buyer, seller, product); err != nil {
+		t.Fatalf("insert trade: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO saved_products (user_id, product_id) VALUES (?, ?)", buyer, product); err != nil {
+		t.Fatalf("insert saved product: %v", err)
+	}
+
+	h := &UserHandler{db: db}
+	app := newTestApp(&buyer, func(app *fiber.App) { app.Get("/users/me/dashboard", h.GetMyDashboard) })
+	load := func() models.Dashboard {
+		resp, err := app.Test(httptest.NewRequest("GET", "/users/me/dashboard", nil), -1)
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		if resp.StatusCode != 200 {
+			t.Fatalf("status = %d, want 200", resp.StatusCode)
+		}
+		var body struct {
+			Data models.Dashboard `json:"data"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return body.Data
+	}
+
+	dash := load()
+	if dash.OutgoingPendingTrades != 1 || dash.IncomingPendingTrades != 0 {
+		t.Errorf("pending trades in/out = %d/%d, want 0/1", dash.IncomingPendingTrades, dash.OutgoingPendingTrades)
+	}
+	if dash.SavedCount != 1 {
+		t.Errorf("saved count = %d, want 1", dash.SavedCount)
+	}
+
+	if _, err := db.Exec("UPDATE saved_products SET deleted_at = NOW() WHERE user_id = ?", buyer); err != nil {
+		t.Fatalf("unsave: %v", err)
+	}
+	if again := load(); again.SavedCount != 1 || !again.GeneratedAt.Equal(dash.GeneratedAt) {
+		t.Errorf("second load was recomputed (saved %d), want the cached dashboard", again.SavedCount)
+	}
+}
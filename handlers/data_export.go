@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/config"
+	"github.com/xashathebest/clovia/middleware"
+	"github.com/xashathebest/clovia/models"
+)
+
+// dataExportBatchSize is how many rows of a section are read per query while streaming
+const dataExportBatchSize = 500
+
+// dataExportLimiter throttles export downloads per user
+var dataExportLimiter = &messageRateLimiter{buckets: make(map[string]*tokenBucket)}
+
+// dataExportHiddenColumns never leave the server: secrets and derived lookup columns
+var dataExportHiddenColumns = map[string]bool{
+	"password_hash":       true,
+	"email_normalized":    true,
+	"title_normalized":    true,
+	"category_normalized": true,
+	"location_normalized": true,
+}
+
+// dataExportSection is one array in the export. Rows of Table (aliased Alias) matching
+// Where are read in id order; every ? in Where is bound to the user's id.
+type dataExportSection struct {
+	Name, Table, Alias, Where string
+}
+
+// dataExportSections is everything an account owns or took part in, in output order
+var dataExportSections = []dataExportSection{
+	{Name: "products", Table: "products p", Alias: "p", Where: "p.seller_id = ?"},
+	{Name: "trades", Table: "trades t", Alias: "t", Where: "t.buyer_id = ? OR t.seller_id = ?"},
+	{Name: "trade_items", Table: "trade_items ti JOIN trades t ON t.id = ti.trade_id", Alias: "ti", Where: "t.buyer_id = ? OR t.seller_id = ?"},
+	{Name: "trade_messages", Table: "trade_messages tm JOIN trades t ON t.id = tm.trade_id", Alias: "tm", Where: "t.buyer_id = ? OR t.seller_id = ?"},
+	{Name: "orders", Table: "orders o JOIN products p ON p.id = o.product_id", Alias: "o", Where: "o.buyer_id = ? OR p.seller_id = ?"},
+	{Name: "price_offers", Table: "price_offers po", Alias: "po", Where: "po.buyer_id = ? OR po.seller_id = ?"},
+	{Name: "comments", Table: "comments c", Alias: "c", Where: "c.user_id = ?"},
+	{Name: "wishlists", Table: "wishlists w", Alias: "w", Where: "w.user_id = ?"},
+	{Name: "saved_products", Table: "saved_products sp", Alias: "sp", Where: "sp.user_id = ?"},
+	{Name: "conversations", Table: "conversations cv", Alias: "cv", Where: "cv.buyer_id = ? OR cv.seller_id = ?"},
+	{Name: "messages", Table: "messages m JOIN conversations cv ON cv.id = m.conversation_id", Alias: "m", Where: "cv.buyer_id = ? OR cv.seller_id = ?"},
+	{Name: "notifications", Table: "notifications n", Alias: "n", Where: "n.user_id = ?"},
+}
+
+// ExportMyData downloads everything stored about the authenticated user as one JSON
+// document: the profile followed by one array per section. Sections are streamed in
+// batches so a heavy account is never held in memory at once. The document ends with
+// "complete": true, or with "error" when a section could not be read partway through.
+func (h *UserHandler) ExportMyData(c *fiber.Ctx) error {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "User not authenticated"})
+	}
+	if !dataExportLimiter.allow(fmt.Sprintf("data-export:%d", userID), config.DataExportsPerMinute(), time.Now()) {
+		return c.Status(429).JSON(models.APIResponse{Success: false, Error: "An export was just downloaded. Please wait a moment."})
+	}
+
+	profileRows, err := h.db.QueryContext(c.UserContext(), "SELECT * FROM users WHERE id = ?", userID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to load profile"})
+	}
+	profiles, err := exportRows(profileRows)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to load profile"})
+	}
+	if len(profiles) == 0 {
+		return c.Status(404).JSON(models.APIResponse{Success: false, Error: "User not found"})
+	}
+
+	now := time.Now()
+	header, err := json.Marshal(fiber.Map{"exported_at": now, "user_id": userID, "profile": profiles[0]})
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to build export"})
+	}
+
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSONCharsetUTF8)
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="clovia-export-%d-%s.json"`, userID, now.Format("20060102")))
+	c.Set(fiber.HeaderCacheControl, "no-store")
+
+	db := h.db
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		// The request context ends when the handler returns; the stream outlives it
+		ctx := context.Background()
+		w.Write(header[:len(header)-1]) // reopen the object to append sections
+		for _, section := range dataExportSections {
+			if err := writeExportSection(ctx, db, w, section, userID); err != nil {
+				log.Printf("data export for user %d: section %s: %v", userID, section.Name, err)
+				msg, _ := json.Marshal("export incomplete: failed to read " + section.Name)
+				w.WriteString(`,"complete":false,"error":`)
+				w.Write(msg)
+				w.WriteString("}")
+				w.Flush()
+				return
+			}
+		}
+		w.WriteString(`,"complete":true}`)
+		w.Flush()
+	})
+	return nil
+}
+
+// writeExportSection writes `,"name":[...]`, reading the section in id-ordered batches and
+// flushing after each. The array is closed even when a batch fails.
+func writeExportSection(ctx context.Context, db *sql.DB, w *bufio.Writer, s dataExportSection, userID int) error {
+	w.WriteString(`,"` + s.Name + `":[`)
+	defer w.WriteString("]")
+
+	query := "SELECT " + s.Alias + ".* FROM " + s.Table + " WHERE (" + s.Where + ") AND " + s.Alias + ".id > ? ORDER BY " + s.Alias + ".id LIMIT ?"
+	args := make([]interface{}, 0, strings.Count(s.Where, "?")+2)
+	for i := strings.Count(s.Where, "?"); i > 0; i-- {
+		args = append(args, userID)
+	}
+	var lastID int64
+	first := true
+	for {
+		rows, err := db.QueryContext(ctx, query, append(args, lastID, dataExportBatchSize)...)
+		if err != nil {
+			return err
+		}
+		batch, err := exportRows(rows)
+		if err != nil {
+			return err
+		}
+		startID := lastID
+		for _, row := range batch {
+			b, err := json.Marshal(row)
+			if err != nil {
+				return err
+			}
+			if !first {
+				w.WriteString(",")
+			}
+			first = false
+			w.Write(b)
+			if id, ok := row["id"].(int64); ok {
+				lastID = id
+			}
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if len(batch) < dataExportBatchSize {
+			return nil
+		}
+		if lastID == startID {
+			return fmt.Errorf("rows of %s have no id to page by", s.Table)
+		}
+	}
+}
+
+// exportRows reads rows into column-name maps and closes them. Text comes out as strings,
+// DECIMAL as JSON numbers and JSON columns as embedded JSON; hidden columns are dropped.
+func exportRows(rows *sql.Rows) ([]map[string]interface{}, error) {
+	defer rows.Close()
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	var out []map[string]interface{}
+	for rows.Next() {
+		values := make([]interface{}, len(types))
+		ptrs := make([]interface{}, len(types))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]interface{}, len(types))
+		for i, ct := range types {
+			if dataExportHiddenColumns[ct.Name()] {
+				continue
+			}
+			v := values[i]
+			if b, ok := v.([]byte); ok {
+				switch ct.DatabaseTypeName() {
+				case "DECIMAL":
+					v = json.Number(b)
+				case "JSON":
+					if json.Valid(b) {
+						v = json.RawMessage(b)
+					} else {
+						v = string(b)
+					}
+				default:
+					v = string(b)
+				}
+			}
+			row[ct.Name()] = v
+		}
+		out = append(out, row)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestDataExportSectionsAreScoped checks each section selects through its alias and is
+// bound to the user, so no section can dump a whole table
+func TestDataExportSectionsAreScoped(t *testing.T) {
+	seen := map[string]bool{}
+	for _, s := range dataExportSections {
+		if seen[s.Name] {
+			t.Errorf("section %s listed twice", s.Name)
+		}
+		seen[s.Name] = true
+		if !strings.Contains(" "+s.Table+" ", " "+s.Alias+" ") {
+			t.Errorf("section %s: table %q does not define alias %q", s.Name, s.Table, s.Alias)
+		}
+		if !strings.Contains(s.Where, "?") {
+			t.Errorf("section %s is not filtered by user", s.Name)
+		}
+	}
+}
+
+// TestExportMyDataStreamsOwnRows downloads an export and checks it is complete, holds the
+// user's rows and nobody else's, and leaves out the password hash
+func TestExportMyDataStreamsOwnRows(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	owner := createTestUser(t, db, "Export Owner")
+	other := createTestUser(t, db, "Export Other")
+	mine := createTestProduct(t, db, owner, "Exported Lamp")
+	theirs := createTestProduct(t, db, other, "Not Exported Lamp")
+	t.Cleanup(func() { db.Exec("DELETE FROM products WHERE id IN (?, ?)", mine, theirs) })
+	if _, err := db.Exec("INSERT INTO comments (product_id, user_id, content) VALUES (?, ?, 'Still available?')", theirs, owner); err != nil {
+		t.Fatalf("insert comment: %v", err)
+	}
+
+	h := &UserHandler{db: db}
+	app := newTestApp(&owner, func(app *fiber.App) { app.Get("/users/me/export", h.ExportMyData) })
+	resp, err := app.Test(httptest.NewRequest("GET", "/users/me/export", nil), -1)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if cd := resp.Header.Get("Content-Disposition"); !strings.HasPrefix(cd, "attachment;") {
+		t.Errorf("Content-Disposition = %q, want an attachment", cd)
+	}
+	raw, _ := io.ReadAll(resp.Body)
+	var export struct {
+		UserID   int                      `json:"user_id"`
+		Profile  map[string]interface{}   `json:"profile"`
+		Products []map[string]interface{} `json:"products"`
+		Comments []map[string]interface{} `json:"comments"`
+		Complete bool                     `json:"complete"`
+		Error    string                   `json:"error"`
+	}
+	if err := json.Unmarshal(raw, &export); err != nil {
+		t.Fatalf("export is not valid JSON: %v\n%s", err, raw)
+	}
+	if !export.Complete {
+		t.Fatalf("export incomplete: %s", export.Error)
+	}
+	if export.UserID != owner || int(export.Profile["id"].(float64)) != owner {
+		t.Errorf("exported user %d / profile %v, want %d", export.UserID, export.Profile["id"], owner)
+	}
+	if _, ok := export.Profile["password_hash"]; ok {
+		t.Error("profile exposes password_hash")
+	}
+	if len(export.Products) != 1 || int(export.Products[0]["id"].(float64)) != mine {
+		t.Errorf("products = %v, want only %d", export.Products, mine)
+	}
+	if len(export.Comments) != 1 || export.Comments[0]["content"] != "Still available?" {
+		t.Errorf("comments = %v, want the one comment", export.Comments)
+	}
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/models"
+)
+
+// deliveryStatuses are every status a delivery can have, in lifecycle order
+var deliveryStatuses = []string{"pending", "claimed", "picked_up", "in_transit", "delivered", "cancelled"}
+
+// parseDeliveryDate reads a from/to filter: an RFC 3339 timestamp or a YYYY-MM-DD date,
+// which as an upper bound covers the whole day
+func parseDeliveryDate(name, raw string, upper bool) (*time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		day, dayErr := time.ParseInLocation("2006-01-02", raw, time.Local)
+		if dayErr != nil {
+			return nil, fmt.Errorf("%s must be a date (YYYY-MM-DD) or an RFC 3339 timestamp", name)
+		}
+		t = day
+		if upper {
+			t = day.Add(24*time.Hour - time.Second)
+		}
+	}
+	return &t, nil
+}
+
+// AdminListDeliveries lists every delivery for operators, newest first, with customer and
+// rider details. Filters: ?status=, ?rider_id=, a ?from=/?to= range on creation time and
+// ?stale=true for claims flagged as not progressing (stale_flagged_at). status_counts
+// counts the deliveries matching every filter but status.
+func (h *DeliveryHandler) AdminListDeliveries(c *fiber.Ctx) error {
+	pg, err := parsePagination(c)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: err.Error()})
+	}
+
+	where := "1=1"
+	var args []interface{}
+	if raw := c.Query("rider_id"); raw != "" {
+		riderID, err := strconv.Atoi(raw)
+		if err != nil || riderID < 1 {
+			return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid rider_id filter"})
+		}
+		where += " AND d.rider_id = ?"
+		args = append(args, riderID)
+	}
+	if raw := c.Query("stale"); raw != "" {
+		stale, err := strconv.ParseBool(raw)
+		if err != nil {
+			return c.Status(400).JSON(models.APIResponse{Success: false, Error: "stale must be true or false"})
+		}
+		if stale {
+			where += " AND d.status = 'claimed' AND d.stale_flagged_at IS NOT NULL"
+		} else {
+			where += " AND NOT (d.status = 'claimed' AND d.stale_flagged_at IS NOT NULL)"
+		}
+	}
+	from, err := parseDeliveryDate("from", c.Query("from"), false)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: err.Error()})
+	}
+	to, err := parseDeliveryDate("to", c.Query("to"), true)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: err.Error()})
+	}
+	if from != nil && to != nil && to.Before(*from) {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "to must not be before from"})
+	}
+	if from != nil {
+		where += " AND d.created_at >= ?"
+		args = append(args, *from)
+	}
+	if to != nil {
+		where += " AND d.created_at <= ?"
+		args = append(args, *to)
+	}
+
+	statusCounts := make(map[string]int, len(deliveryStatuses))
+	for _, s := range deliveryStatuses {
+		statusCounts[s] = 0
+	}
+	countRows, err := h.db.Query("SELECT d.status, COUNT(*) FROM deliveries d WHERE "+where+" GROUP BY d.status", args...)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to count deliveries"})
+	}
+	defer countRows.Close()
+	for countRows.Next() {
+		var status string
+		var n int
+		if err := countRows.Scan(&status, &n); err != nil {
+			return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to count deliveries"})
+		}
+		statusCounts[status] = n
+	}
+	countRows.Close()
+
+	total := 0
+	if status := c.Query("status"); status != "" {
+		if _, ok := statusCounts[status]; !ok {
+			return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid status filter"})
+		}
+		where += " AND d.status = ?"
+		args = append(args, status)
+		total = statusCounts[status]
+	} else {
+		for _, n := range statusCounts {
+			total += n
+		}
+	}
+
+	rows, err := h.db.Query(`
+		SELECT `+deliveryColumns+`
+		FROM deliveries d
+		JOIN users u ON d.user_id = u.id
+		WHERE `+where+`
+		ORDER BY d.created_at DESC, d.id DESC
+		LIMIT ? OFFSET ?`, append(args, pg.Limit, pg.Offset)...)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to fetch deliveries"})
+	}
+	defer rows.Close()
+
+	deliveries := []models.Delivery{}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to read deliveries"})
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to read deliveries"})
+	}
+	rows.Close()
+	// Details are loaded once the result set is closed, so each lookup gets a free connection
+	for i := range deliveries {
+		h.loadRiderInfo(&deliveries[i])
+		h.loadDeliveryItems(&deliveries[i])
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data: models.DeliveryAdminList{
+			PaginatedResponse: models.PaginatedResponse{
+				Data:       deliveries,
+				Total:      total,
+				Page:       pg.Page,
+				Limit:      pg.Limit,
+				TotalPages: (total + pg.Limit - 1) / pg.Limit,
+			},
+			StatusCounts: statusCounts,
+		},
+	})
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/models"
+	"github.com/xashathebest/clovia/services"
+)
+
+func TestParseDeliveryDate(t *testing.T) {
+	from, err := parseDeliveryDate("from", "2026-03-04", false)
+	if err != nil || from.Format("2006-01-02 15:04:05") != "2026-03-04 00:00:00" {
+		t.Errorf("from = %v, %v; want the start of the day", from, err)
+	}
+	to, err := parseDeliveryDate("to", "2026-03-04", true)
+	if err != nil || to.Format("2006-01-02 15:04:05") != "2026-03-04 23:59:59" {
+		t.Errorf("to = %v, %v; want the end of the day", to, err)
+	}
+	if _, err := parseDeliveryDate("from", "04/03/2026", false); err == nil {
+		t.Error("expected an error for an unsupported date format")
+	}
+	if got, err := parseDeliveryDate("from", "", false); got != nil || err != nil {
+		t.Errorf("empty filter = %v, %v; want no bound", got, err)
+	}
+}
+
+// TestAdminListDeliveries filters one rider's deliveries and checks the status counts
+// ignore the status filter
+func TestAdminListDeliveries(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	admin := createTestUser(t, db, "Delivery Admin")
+	customer := createTestUser(t, db, "Listed Customer")
+	riderUser := createTestUser(t, db, "Listed Rider")
+	res, err := db.Exec("INSERT INTO riders (user_id, name, phone) VALUES (?, 'Listed Rider', '0917')", riderUser)
+	if err != nil {
+		t.Fatalf("insert rider: %v", err)
+	}
+	riderID, _ := res.LastInsertId()
+	t.Cleanup(func() { db.Exec("DELETE FROM riders WHERE id = ?", riderID) })
+	for _, status := range []string{"claimed", "in_transit", "delivered"} {
+		res, err := db.Exec(`INSERT INTO deliveries (user_id, status, rider_id, pickup_address, delivery_address)
+			VALUES (?, ?, ?, 'A', 'B')`, customer, status, riderID)
+		if err != nil {
+			t.Fatalf("insert delivery: %v", err)
+		}
+		id, _ := res.LastInsertId()
+		t.Cleanup(func() { db.Exec("DELETE FROM deliveries WHERE id = ?", id) })
+	}
+
+	h := &DeliveryHandler{db: db}
+	app := newTestApp(&admin, func(app *fiber.App) { app.Get("/admin/deliveries", h.AdminListDeliveries) })
+	get := func(query string) (int, models.DeliveryAdminList, []models.Delivery) {
+		resp, err := app.Test(httptest.NewRequest("GET", "/admin/deliveries?"+query, nil), -1)
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		var body struct {
+			Data struct {
+				models.DeliveryAdminList
+				Data []models.Delivery `json:"data"`
+			} `json:"data"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode, body.Data.DeliveryAdminList, body.Data.Data
+	}
+
+	rider := "rider_id=" + strconv.FormatInt(riderID, 10)
+	status, list, deliveries := get(rider + "&status=delivered")
+	if status != 200 {
+		t.Fatalf("status %d, want 200", status)
+	}
+	if list.Total != 1 || len(deliveries) != 1 || deliveries[0].Status != "delivered" {
+		t.Errorf("got %d of %d deliveries, want the one delivered", len(deliveries), list.Total)
+	}
+	if len(deliveries) == 1 && deliveries[0].UserName != "Listed Customer" {
+		t.Errorf("customer name %q, want Listed Customer", deliveries[0].UserName)
+	}
+	want := map[string]int{"pending": 0, "claimed": 1, "picked_up": 0, "in_transit": 1, "delivered": 1, "cancelled": 0}
+	for s, n := range want {
+		if list.StatusCounts[s] != n {
+			t.Errorf("status_counts[%s] = %d, want %d", s, list.StatusCounts[s], n)
+		}
+	}
+
+	if status, _, _ := get("status=lost"); status != 400 {
+		t.Errorf("unknown status: status %d, want 400", status)
+	}
+	if status, _, _ := get("from=2026-05-02&to=2026-05-01"); status != 400 {
+		t.Errorf("inverted range: status %d, want 400", status)
+	}
+}
+
+// TestStaleDeliveriesFlaggedAndListed lets a claim sit past the threshold, checks the
+// monitor flags it once and records the event, and finds it through ?stale=true
+func TestStaleDeliveriesFlaggedAndListed(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	admin := createTestUser(t, db, "Stale Admin")
+	customer := createTestUser(t, db, "Stale Customer")
+	riderUser := createTestUser(t, db, "Stale Rider")
+	res, err := db.Exec("INSERT INTO riders (user_id, name, phone) VALUES (?, 'Stale Rider', '0917')", riderUser)
+	if err != nil {
+		t.Fatalf("insert rider: %v", err)
+	}
+	riderID, _ := res.LastInsertId()
+	t.Cleanup(func() { db.Exec("DELETE FROM riders WHERE id = ?", riderID) })
+	insert := func(claimedAgo string) int64 {
+		res, err := db.Exec(`INSERT INTO deliveries (user_id, status, rider_id, pickup_address, delivery_address, claimed_at)
+			VALUES (?, 'claimed', ?, 'A', 'B', NOW() - INTERVAL `+claimedAgo+`)`, customer, riderID)
+		if err != nil {
+			t.Fatalf("insert delivery: %v", err)
+		}
+		id, _ := res.LastInsertId()
+		t.Cleanup(func() { db.Exec("DELETE FROM deliveries WHERE id = ?", id) })
+		return id
+	}
+	stuck := insert("2 HOUR")
+	fresh := insert("5 MINUTE")
+
+	for pass := 0; pass < 2; pass++ {
+		if _, err := services.FlagStaleDeliveries(db, time.Hour, false); err != nil {
+			t.Fatalf("flag: %v", err)
+		}
+	}
+	var events int
+	db.QueryRow("SELECT COUNT(*) FROM delivery_stale_events WHERE delivery_id = ?", stuck).Scan(&events)
+	if events != 1 {
+		t.Errorf("stuck delivery has %d stale events, want 1", events)
+	}
+	var freshFlagged bool
+	db.QueryRow("SELECT stale_flagged_at IS NOT NULL FROM deliveries WHERE id = ?", fresh).Scan(&freshFlagged)
+	if freshFlagged {
+		t.Error("a recent claim was flagged stale")
+	}
+
+	h := &DeliveryHandler{db: db}
+	app := newTestApp(&admin, func(app *fiber.App) { app.Get("/admin/deliveries", h.AdminListDeliveries) })
+	resp, err := app.Test(httptest.NewRequest("GET", "/admin/deliveries?stale=true&rider_id="+strconv.FormatInt(riderID, 10), nil), -1)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	var body struct {
+		Data struct {
+			Data []models.Delivery `json:"data"`
+		} `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	if len(body.Data.Data) != 1 || int64(body.Data.Data[0].ID) != stuck || body.Data.Data[0].StaleFlaggedAt == nil {
+		t.Errorf("stale listing = %+v, want only delivery %d with its flag", body.Data.Data, stuck)
+	}
+
+	if _, err := services.FlagStaleDeliveries(db, time.Minute, true); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	var status string
+	db.QueryRow("SELECT status FROM deliveries WHERE id = ?", fresh).Scan(&status)
+	if status != "pending" {
+		t.Errorf("auto-released delivery is %s, want pending", status)
+	}
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"database/sql"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/middleware"
+	"github.com/xashathebest/clovia/models"
+	"github.com/xashathebest/clovia/services"
+)
+
+// RespondToAssignment lets the rider a delivery was assigned to (by an admin or express
+// auto-assignment) accept it or turn it down. Turning it down, or answering after the
+// window has passed, returns the delivery to pending for any rider to claim, counts
+// against the rider and tells the customer. Deliveries a rider claimed themselves need
+// no answer.
+func (h *DeliveryHandler) RespondToAssignment(c *fiber.Ctx) error {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "User not authenticated"})
+	}
+	deliveryID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid delivery ID"})
+	}
+	var req models.DeliveryAssignmentResponse
+	if err := c.BodyParser(&req); err != nil || req.Accept == nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "accept must be true or false"})
+	}
+
+	var riderID int
+	err = h.db.QueryRow("SELECT id FROM riders WHERE user_id = ?", userID).Scan(&riderID)
+	if err != nil {
+		return c.Status(403).JSON(models.APIResponse{Success: false, Error: "User is not a rider"})
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to start transaction"})
+	}
+	defer tx.Rollback()
+
+	var customerID int
+	var status string
+	var assignedRider sql.NullInt64
+	var awaiting, lapsed bool
+	err = tx.QueryRow(`
+		SELECT user_id, status, rider_id, assignment_expires_at IS NOT NULL,
+			COALESCE(assignment_expires_at <= NOW(), FALSE)
+		FROM deliveries WHERE id = ? FOR UPDATE`, deliveryID).Scan(&customerID, &status, &assignedRider, &awaiting, &lapsed)
+	if err == sql.ErrNoRows {
+		return c.Status(404).JSON(models.APIResponse{Success: false, Error: "Delivery not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to fetch delivery"})
+	}
+	if !assignedRider.Valid || int(assignedRider.Int64) != riderID {
+		return c.Status(403).JSON(models.APIResponse{Success: false, Error: "You are not assigned to this delivery"})
+	}
+	if status != "claimed" || !awaiting {
+		return c.Status(409).JSON(models.APIResponse{Success: false, Error: "This delivery is not waiting for your answer"})
+	}
+
+	if *req.Accept && !lapsed {
+		if _, err := tx.Exec("UPDATE deliveries SET assignment_expires_at = NULL, updated_at = CURRENT_TIMESTAMP WHERE id = ?", deliveryID); err != nil {
+			return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to accept delivery"})
+		}
+		if err := tx.Commit(); err != nil {
+			return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to accept delivery"})
+		}
+		return h.respondWithDelivery(c, deliveryID, "Delivery accepted")
+	}
+
+	if _, err := services.ReleaseDeliveryAssignment(tx, deliveryID, riderID); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to release delivery"})
+	}
+	if err := tx.Commit(); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to release delivery"})
+	}
+	notify(h.db, customerID, "delivery_reassigned", services.DeliveryReassignedMessage(deliveryID), "delivery", deliveryID)
+
+	if *req.Accept {
+		return c.Status(409).JSON(models.APIResponse{Success: false, Error: "The time to accept this delivery has passed; it was offered to other riders"})
+	}
+	return h.respondWithDelivery(c, deliveryID, "Delivery declined; it is back in the queue")
+}
+
+// respondWithDelivery answers with the delivery's current state
+func (h *DeliveryHandler) respondWithDelivery(c *fiber.Ctx, deliveryID int, message string) error {
+	delivery, err := h.getDeliveryByID(deliveryID, 0)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to retrieve delivery"})
+	}
+	return c.JSON(models.APIResponse{Success: true, Message: message, Data: delivery})
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/services"
+)
+
+// TestRespondToAssignment turns an assignment down, then lets a second one lapse, and
+// checks both times the delivery is claimable again, the rider's rejections are counted
+// and the customer is told
+func TestRespondToAssignment(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	customer := createTestUser(t, db, "Assigned Customer")
+	riderUser := createTestUser(t, db, "Assigned Rider")
+	res, err := db.Exec("INSERT INTO riders (user_id, name, phone) VALUES (?, 'Assigned Rider', '0917')", riderUser)
+	if err != nil {
+		t.Fatalf("insert rider: %v", err)
+	}
+	riderID, _ := res.LastInsertId()
+	t.Cleanup(func() { db.Exec("DELETE FROM riders WHERE id = ?", riderID) })
+	res, err = db.Exec(`INSERT INTO deliveries (user_id, status, rider_id, pickup_address, delivery_address, claimed_at, assignment_expires_at)
+		VALUES (?, 'claimed', ?, 'A', 'B', NOW(), NOW() + INTERVAL 10 MINUTE)`, customer, riderID)
+	if err != nil {
+		t.Fatalf("insert delivery: %v", err)
+	}
+	deliveryID, _ := res.LastInsertId()
+
+	h := &DeliveryHandler{db: db}
+	app := newTestApp(&riderUser, func(app *fiber.App) { app.Post("/deliveries/:id/respond", h.RespondToAssignment) })
+	req := httptest.NewRequest("POST", "/deliveries/"+strconv.FormatInt(deliveryID, 10)+"/respond", strings.NewReader(`{"accept":false}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("decline: status %d, want 200", resp.StatusCode)
+	}
+
+	check := func(step string, wantRejects int) {
+		t.Helper()
+		var status string
+		var rider *int
+		db.QueryRow("SELECT status, rider_id FROM deliveries WHERE id = ?", deliveryID).Scan(&status, &rider)
+		if status != "pending" || rider != nil {
+			t.Errorf("%s: delivery %s with rider %v, want pending and unassigned", step, status, rider)
+		}
+		var rejects int
+		db.QueryRow("SELECT reject_count FROM riders WHERE id = ?", riderID).Scan(&rejects)
+		if rejects != wantRejects {
+			t.Errorf("%s: reject_count %d, want %d", step, rejects, wantRejects)
+		}
+		var notices int
+		db.QueryRow("SELECT COUNT(*) FROM notifications WHERE user_id = ? AND type = 'delivery_reassigned'", customer).Scan(&notices)
+		if notices != wantRejects {
+			t.Errorf("%s: customer got %d reassignment notices, want %d", step, notices, wantRejects)
+		}
+	}
+	check("declined", 1)
+
+	if _, err := db.Exec("UPDATE deliveries SET status = 'claimed', rider_id = ?, assignment_expires_at = NOW() - INTERVAL 1 MINUTE WHERE id = ?", riderID, deliveryID); err != nil {
+		t.Fatalf("reassign: %v", err)
+	}
+	if _, err := services.ExpireDeliveryAssignments(db); err != nil {
+		t.Fatalf("expire: %v", err)
+	}
+	check("lapsed", 2)
+}
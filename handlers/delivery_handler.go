@@ -6,9 +6,11 @@ import (
 	"log"
 	"math"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/xashathebest/clovia/config"
 	"github.com/xashathebest/clovia/database"
 	"github.com/xashathebest/clovia/middleware"
 	"github.com/xashathebest/clovia/models"
@@ -58,9 +60,13 @@ func calculateCost(deliveryType string) float64 {
 	return 30.0 // ₱30 for standard
 }
 
-// CheckFragileItems checks if any products in the delivery are fragile
-func (h *DeliveryHandler) checkFragileItems(productIDs []int) (bool, error) {
-	// Check product descriptions/categories for fragile keywords
+// checkFragileItems returns the set of products in the delivery that look fragile
+func checkFragileItems(db *sql.DB, productIDs []int) (map[int]bool, error) {
+	// Check product descriptions/categories for the configured fragile keywords
+	limits := config.CurrentDeliveryLimits()
+	if len(productIDs) == 0 || len(limits.FragileKeywords)+len(limits.FragileCategories) == 0 {
+		return map[int]bool{}, nil
+	}
 	placeholders := ""
 	args := []interface{}{}
 	for i, id := range productIDs {
@@ -71,24 +77,37 @@ func (h *DeliveryHandler) checkFragileItems(productIDs []int) (bool, error) {
 		args = append(args, id)
 	}
 
+	var matches []string
+	for _, kw := range limits.FragileKeywords {
+		matches = append(matches, "LOWER(description) LIKE ?")
+		args = append(args, "%"+kw+"%")
+	}
+	for _, cat := range limits.FragileCategories {
+		matches = append(matches, "LOWER(category) LIKE ?")
+		args = append(args, "%"+cat+"%")
+	}
+
 	query := fmt.Sprintf(`
-		SELECT COUNT(*) FROM products 
+		SELECT id FROM products 
 		WHERE id IN (%s) 
-		AND (
-			LOWER(description) LIKE '%%fragile%%' OR
-			LOWER(description) LIKE '%%breakable%%' OR
-			LOWER(description) LIKE '%%glass%%' OR
-			LOWER(category) LIKE '%%electronics%%' OR
-			LOWER(category) LIKE '%%fragile%%'
-		)
-	`, placeholders)
+		AND (%s)
+	`, placeholders, strings.Join(matches, " OR "))
 
-	var count int
-	err := h.db.QueryRow(query, args...).Scan(&count)
+	rows, err := db.Query(query, args...)
 	if err != nil {
-		return false, err
+		return nil, err
+	}
+	defer rows.Close()
+
+	fragile := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		fragile[id] = true
 	}
-	return count > 0, nil
+	return fragile, rows.Err()
 }
 
 // FindNearestRider finds the nearest available rider to pickup location
@@ -159,7 +178,7 @@ func (h *DeliveryHandler) findNearestRider(pickupLat, pickupLon *float64, delive
 	return nearestRider, nil
 }
 
-// FindAvailableBatch finds an available batch for standard delivery (up to 5 items)
+// FindAvailableBatch finds an available batch for standard delivery (up to DELIVERY_STANDARD_MAX_ITEMS items)
 func (h *DeliveryHandler) findAvailableBatch(pickupLat, pickupLon *float64, itemCount int) (int, error) {
 	// Find a pending standard delivery with space for more items
 	// For simplicity, we'll create a new batch for each delivery
@@ -179,6 +198,11 @@ func (h *DeliveryHandler) CreateDelivery(c *fiber.Ctx) error {
 		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid request body"})
 	}
 
+	return h.submitDelivery(c, userID, req)
+}
+
+// submitDelivery validates and stores a delivery request, then writes the created delivery as the response
+func (h *DeliveryHandler) submitDelivery(c *fiber.Ctx, userID int, req models.DeliveryRequest) error {
 	// Validate delivery type
 	if req.DeliveryType != "standard" && req.DeliveryType != "express" {
 		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid delivery type. Must be 'standard' or 'express'"})
@@ -191,11 +215,8 @@ func (h *DeliveryHandler) CreateDelivery(c *fiber.Ctx) error {
 	}
 
 	// Validate batch limits
-	if req.DeliveryType == "express" && itemCount > 1 {
-		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Express delivery allows only 1 item per delivery"})
-	}
-	if req.DeliveryType == "standard" && itemCount > 5 {
-		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Standard delivery allows maximum 5 items per batch"})
+	if err := checkDeliveryItems(config.CurrentDeliveryLimits(), req.DeliveryType, 0, itemCount); err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: err.Error()})
 	}
 
 	// Validate GPS or manual address
@@ -217,6 +238,25 @@ func (h *DeliveryHandler) CreateDelivery(c *fiber.Ctx) error {
 	}
 	defer tx.Rollback()
 
+	// A trade gets at most one live delivery: asking again returns the one already
+	// arranged, and a replacement is only possible once that one is cancelled
+	if req.TradeID != nil {
+		var buyerID, sellerID int
+		if err := tx.QueryRow("SELECT buyer_id, seller_id FROM trades WHERE id = ?", *req.TradeID).Scan(&buyerID, &sellerID); err != nil {
+			return c.Status(404).JSON(models.APIResponse{Success: false, Error: "Trade not found"})
+		}
+		if userID != buyerID && userID != sellerID {
+			return c.Status(403).JSON(models.APIResponse{Success: false, Error: "Not authorized for this trade"})
+		}
+		existingID, err := activeTradeDelivery(tx, *req.TradeID)
+		if err != nil {
+			return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to check existing deliveries"})
+		}
+		if existingID != 0 {
+			return h.existingTradeDelivery(c, existingID)
+		}
+	}
+
 	// Verify products exist
 	for _, productID := range req.ProductIDs {
 		var exists bool
@@ -226,11 +266,12 @@ func (h *DeliveryHandler) CreateDelivery(c *fiber.Ctx) error {
 		}
 	}
 
-	// Check for fragile items
-	isFragile, err := h.checkFragileItems(req.ProductIDs)
+	// Check for fragile items; the delivery is fragile if any item is
+	fragileItems, err := checkFragileItems(h.db, req.ProductIDs)
 	if err != nil {
 		log.Printf("Warning: failed to check fragile items: %v", err)
 	}
+	isFragile := len(fragileItems) > 0
 
 	// Calculate distance and ETA
 	var distanceKm float64
@@ -276,6 +317,13 @@ func (h *DeliveryHandler) CreateDelivery(c *fiber.Ctx) error {
 		req.SpecialInstructions, totalCost, estimatedETA, itemCount, isFragile)
 
 	if err != nil {
+		// Lost a race with the other party arranging the same trade's delivery
+		if req.TradeID != nil && isDuplicateEntry(err) {
+			tx.Rollback()
+			if existingID, lookupErr := activeTradeDelivery(h.db, *req.TradeID); lookupErr == nil && existingID != 0 {
+				return h.existingTradeDelivery(c, existingID)
+			}
+		}
 		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to create delivery"})
 	}
 
@@ -290,20 +338,20 @@ func (h *DeliveryHandler) CreateDelivery(c *fiber.Ctx) error {
 		_, err := tx.Exec(`
 			INSERT INTO delivery_items (delivery_id, product_id, product_name, is_fragile)
 			VALUES (?, ?, ?, ?)
-		`, deliveryID, productID, productName, isFragile)
+		`, deliveryID, productID, productName, fragileItems[productID])
 		if err != nil {
 			return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to create delivery items"})
 		}
 	}
 
-	// If express and rider assigned, update status to claimed
+	// If express and rider assigned, update status to claimed; the rider still has to accept
 	if req.DeliveryType == "express" && riderID != nil {
 		now := time.Now()
 		_, err = tx.Exec(`
 			UPDATE deliveries 
-			SET status = 'claimed', claimed_at = ?
+			SET status = 'claimed', claimed_at = ?, assignment_expires_at = ?
 			WHERE id = ?
-		`, now, deliveryID)
+		`, now, now.Add(config.DeliveryAssignmentWindow()), deliveryID)
 		if err != nil {
 			log.Printf("Warning: failed to update delivery status: %v", err)
 		}
@@ -326,6 +374,79 @@ func (h *DeliveryHandler) CreateDelivery(c *fiber.Ctx) error {
 	})
 }
 
+// existingTradeDelivery answers a repeated delivery request for a trade with the delivery already arranged
+func (h *DeliveryHandler) existingTradeDelivery(c *fiber.Ctx, deliveryID int) error {
+	delivery, err := h.getDeliveryByID(deliveryID, 0)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to retrieve existing delivery"})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "A delivery is already arranged for this trade",
+		Data:    delivery,
+	})
+}
+
+// ArrangeTradeDelivery creates a delivery pre-filled from a trade's products and participants.
+// Pickup defaults to the target product's location and drop-off to the location of the
+// buyer's offered items; any address or coordinates in the body take precedence.
+func (h *DeliveryHandler) ArrangeTradeDelivery(c *fiber.Ctx) error {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "User not authenticated"})
+	}
+
+	tradeID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid trade id"})
+	}
+
+	var req models.DeliveryRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid request body"})
+		}
+	}
+	if req.DeliveryType == "" {
+		req.DeliveryType = "standard"
+	}
+
+	var buyerID, sellerID int
+	var targetProductID sql.NullInt64 // NULL once the listing was deleted
+	var status string
+	err = h.db.QueryRow("SELECT buyer_id, seller_id, target_product_id, status FROM trades WHERE id = ?", tradeID).Scan(&buyerID, &sellerID, &targetProductID, &status)
+	if err != nil {
+		return c.Status(404).JSON(models.APIResponse{Success: false, Error: "Trade not found"})
+	}
+	if userID != buyerID && userID != sellerID {
+		return c.Status(403).JSON(models.APIResponse{Success: false, Error: "Not authorized for this trade"})
+	}
+	if status != "accepted" && status != "active" && status != "completed" && status != "auto_completed" {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Delivery can only be arranged for accepted trades"})
+	}
+
+	route, err := loadTradeRoute(h.db, tradeID, int(targetProductID.Int64))
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to load trade items"})
+	}
+	if len(route.ProductIDs) == 0 {
+		return c.Status(409).JSON(models.APIResponse{Success: false, Error: "Every item in this trade was deleted; there is nothing to deliver"})
+	}
+
+	req.TradeID = &tradeID
+	req.ProductIDs = route.ProductIDs
+	if req.PickupAddress == "" && req.PickupLatitude == nil {
+		req.PickupAddress = route.PickupAddress
+		req.PickupLatitude, req.PickupLongitude = route.PickupLat, route.PickupLon
+	}
+	if req.DeliveryAddress == "" && req.DeliveryLatitude == nil {
+		req.DeliveryAddress = route.DropoffAddress
+		req.DeliveryLatitude, req.DeliveryLongitude = route.DropoffLat, route.DropoffLon
+	}
+
+	return h.submitDelivery(c, userID, req)
+}
+
 // GetDeliveries gets deliveries for the current user
 func (h *DeliveryHandler) GetDeliveries(c *fiber.Ctx) error {
 	userID, ok := middleware.GetUserIDFromContext(c)
@@ -335,13 +456,7 @@ func (h *DeliveryHandler) GetDeliveries(c *fiber.Ctx) error {
 
 	status := c.Query("status", "")
 	query := `
-		SELECT d.id, d.user_id, d.trade_id, d.delivery_type, d.status, d.rider_id,
-			d.pickup_latitude, d.pickup_longitude, d.pickup_address,
-			d.delivery_latitude, d.delivery_longitude, d.delivery_address,
-			d.special_instructions, d.total_cost, d.estimated_eta, d.item_count, d.is_fragile,
-			d.claimed_at, d.picked_up_at, d.in_transit_at, d.delivered_at,
-			d.created_at, d.updated_at,
-			u.name AS user_name
+		SELECT `+deliveryColumns+`
 		FROM deliveries d
 		JOIN users u ON d.user_id = u.id
 		WHERE d.user_id = ?
@@ -363,16 +478,7 @@ func (h *DeliveryHandler) GetDeliveries(c *fiber.Ctx) error {
 
 	deliveries := []models.Delivery{}
 	for rows.Next() {
-		var d models.Delivery
-		err := rows.Scan(
-			&d.ID, &d.UserID, &d.TradeID, &d.DeliveryType, &d.Status, &d.RiderID,
-			&d.PickupLatitude, &d.PickupLongitude, &d.PickupAddress,
-			&d.DeliveryLatitude, &d.DeliveryLongitude, &d.DeliveryAddress,
-			&d.SpecialInstructions, &d.TotalCost, &d.EstimatedETA, &d.ItemCount, &d.IsFragile,
-			&d.ClaimedAt, &d.PickedUpAt, &d.InTransitAt, &d.DeliveredAt,
-			&d.CreatedAt, &d.UpdatedAt,
-			&d.UserName,
-		)
+		d, err := scanDelivery(rows)
 		if err != nil {
 			continue
 		}
@@ -482,6 +588,9 @@ func (h *DeliveryHandler) UpdateDeliveryStatus(c *fiber.Ctx) error {
 		if err != nil {
 			log.Printf("Warning: failed to update rider location: %v", err)
 		}
+		if err := recordRiderLocation(h.db, deliveryID, riderID, *update.Latitude, *update.Longitude); err != nil {
+			log.Printf("Warning: failed to record location for delivery %d: %v", deliveryID, err)
+		}
 	}
 
 	if update.EstimatedETA != nil {
@@ -489,7 +598,8 @@ func (h *DeliveryHandler) UpdateDeliveryStatus(c *fiber.Ctx) error {
 		args = append(args, *update.EstimatedETA)
 	}
 
-	if len(updates) == 0 {
+	// A bare location ping is a valid update: it extends the trail and touches updated_at
+	if len(updates) == 0 && (update.Latitude == nil || update.Longitude == nil) {
 		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "No updates provided"})
 	}
 
@@ -560,13 +670,13 @@ func (h *DeliveryHandler) AssignRider(c *fiber.Ctx) error {
 		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Rider is not active"})
 	}
 
-	// Assign rider
+	// Assign rider, who has until the assignment window ends to accept
 	now := time.Now()
 	_, err = h.db.Exec(`
 		UPDATE deliveries 
-		SET rider_id = ?, status = 'claimed', claimed_at = ?, updated_at = CURRENT_TIMESTAMP
+		SET rider_id = ?, status = 'claimed', claimed_at = ?, assignment_expires_at = ?, updated_at = CURRENT_TIMESTAMP
 		WHERE id = ?
-	`, payload.RiderID, now, deliveryID)
+	`, payload.RiderID, now, now.Add(config.DeliveryAssignmentWindow()), deliveryID)
 	if err != nil {
 		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to assign rider"})
 	}
@@ -599,13 +709,7 @@ func (h *DeliveryHandler) GetAvailableDeliveries(c *fiber.Ctx) error {
 
 	// Get pending deliveries (not yet claimed)
 	rows, err := h.db.Query(`
-		SELECT d.id, d.user_id, d.trade_id, d.delivery_type, d.status, d.rider_id,
-			d.pickup_latitude, d.pickup_longitude, d.pickup_address,
-			d.delivery_latitude, d.delivery_longitude, d.delivery_address,
-			d.special_instructions, d.total_cost, d.estimated_eta, d.item_count, d.is_fragile,
-			d.claimed_at, d.picked_up_at, d.in_transit_at, d.delivered_at,
-			d.created_at, d.updated_at,
-			u.name AS user_name
+		SELECT `+deliveryColumns+`
 		FROM deliveries d
 		JOIN users u ON d.user_id = u.id
 		WHERE d.status = 'pending'
@@ -618,16 +722,7 @@ func (h *DeliveryHandler) GetAvailableDeliveries(c *fiber.Ctx) error {
 
 	deliveries := []models.Delivery{}
 	for rows.Next() {
-		var d models.Delivery
-		err := rows.Scan(
-			&d.ID, &d.UserID, &d.TradeID, &d.DeliveryType, &d.Status, &d.RiderID,
-			&d.PickupLatitude, &d.PickupLongitude, &d.PickupAddress,
-			&d.DeliveryLatitude, &d.DeliveryLongitude, &d.DeliveryAddress,
-			&d.SpecialInstructions, &d.TotalCost, &d.EstimatedETA, &d.ItemCount, &d.IsFragile,
-			&d.ClaimedAt, &d.PickedUpAt, &d.InTransitAt, &d.DeliveredAt,
-			&d.CreatedAt, &d.UpdatedAt,
-			&d.UserName,
-		)
+		d, err := scanDelivery(rows)
 		if err != nil {
 			continue
 		}
@@ -662,13 +757,7 @@ func (h *DeliveryHandler) GetRiderDeliveries(c *fiber.Ctx) error {
 
 	status := c.Query("status", "")
 	query := `
-		SELECT d.id, d.user_id, d.trade_id, d.delivery_type, d.status, d.rider_id,
-			d.pickup_latitude, d.pickup_longitude, d.pickup_address,
-			d.delivery_latitude, d.delivery_longitude, d.delivery_address,
-			d.special_instructions, d.total_cost, d.estimated_eta, d.item_count, d.is_fragile,
-			d.claimed_at, d.picked_up_at, d.in_transit_at, d.delivered_at,
-			d.created_at, d.updated_at,
-			u.name AS user_name
+		SELECT `+deliveryColumns+`
 		FROM deliveries d
 		JOIN users u ON d.user_id = u.id
 		WHERE d.rider_id = ?
@@ -690,16 +779,7 @@ func (h *DeliveryHandler) GetRiderDeliveries(c *fiber.Ctx) error {
 
 	deliveries := []models.Delivery{}
 	for rows.Next() {
-		var d models.Delivery
-		err := rows.Scan(
-			&d.ID, &d.UserID, &d.TradeID, &d.DeliveryType, &d.Status, &d.RiderID,
-			&d.PickupLatitude, &d.PickupLongitude, &d.PickupAddress,
-			&d.DeliveryLatitude, &d.DeliveryLongitude, &d.DeliveryAddress,
-			&d.SpecialInstructions, &d.TotalCost, &d.EstimatedETA, &d.ItemCount, &d.IsFragile,
-			&d.ClaimedAt, &d.PickedUpAt, &d.InTransitAt, &d.DeliveredAt,
-			&d.CreatedAt, &d.UpdatedAt,
-			&d.UserName,
-		)
+		d, err := scanDelivery(rows)
 		if err != nil {
 			continue
 		}
@@ -752,29 +832,17 @@ func (h *DeliveryHandler) ClaimDelivery(c *fiber.Ctx) error {
 		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Delivery is not pending"})
 	}
 
-	// For standard deliveries, check if rider already has an active batch
+	// For standard deliveries, count what the rider already carries in active batches
+	var totalItems int
 	if deliveryType == "standard" {
-		var activeCount int
-		h.db.QueryRow(`
-			SELECT COUNT(*) FROM deliveries 
-			WHERE rider_id = ? AND status IN ('claimed', 'picked_up', 'in_transit')
-			AND delivery_type = 'standard'
-		`, actualRiderID).Scan(&activeCount)
-
-		// Check total items in active batches (max 5 for standard)
-		var totalItems int
 		h.db.QueryRow(`
 			SELECT COALESCE(SUM(item_count), 0) FROM deliveries 
 			WHERE rider_id = ? AND status IN ('claimed', 'picked_up', 'in_transit')
 			AND delivery_type = 'standard'
 		`, actualRiderID).Scan(&totalItems)
-
-		if totalItems+itemCount > 5 {
-			return c.Status(400).JSON(models.APIResponse{
-				Success: false,
-				Error:   fmt.Sprintf("Cannot add delivery: would exceed 5 item limit (current: %d, adding: %d)", totalItems, itemCount),
-			})
-		}
+	}
+	if err := checkDeliveryItems(config.CurrentDeliveryLimits(), deliveryType, totalItems, itemCount); err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: err.Error()})
 	}
 
 	// Claim delivery
@@ -894,15 +962,8 @@ func (h *DeliveryHandler) GetRiderEarnings(c *fiber.Ctx) error {
 
 // Helper function to get delivery by ID
 func (h *DeliveryHandler) getDeliveryByID(deliveryID, userID int) (*models.Delivery, error) {
-	var d models.Delivery
 	query := `
-		SELECT d.id, d.user_id, d.trade_id, d.delivery_type, d.status, d.rider_id,
-			d.pickup_latitude, d.pickup_longitude, d.pickup_address,
-			d.delivery_latitude, d.delivery_longitude, d.delivery_address,
-			d.special_instructions, d.total_cost, d.estimated_eta, d.item_count, d.is_fragile,
-			d.claimed_at, d.picked_up_at, d.in_transit_at, d.delivered_at,
-			d.created_at, d.updated_at,
-			u.name AS user_name
+		SELECT `+deliveryColumns+`
 		FROM deliveries d
 		JOIN users u ON d.user_id = u.id
 		WHERE d.id = ?
@@ -914,15 +975,7 @@ func (h *DeliveryHandler) getDeliveryByID(deliveryID, userID int) (*models.Deliv
 		args = append(args, userID)
 	}
 
-	err := h.db.QueryRow(query, args...).Scan(
-		&d.ID, &d.UserID, &d.TradeID, &d.DeliveryType, &d.Status, &d.RiderID,
-		&d.PickupLatitude, &d.PickupLongitude, &d.PickupAddress,
-		&d.DeliveryLatitude, &d.DeliveryLongitude, &d.DeliveryAddress,
-		&d.SpecialInstructions, &d.TotalCost, &d.EstimatedETA, &d.ItemCount, &d.IsFragile,
-		&d.ClaimedAt, &d.PickedUpAt, &d.InTransitAt, &d.DeliveredAt,
-		&d.CreatedAt, &d.UpdatedAt,
-		&d.UserName,
-	)
+	d, err := scanDelivery(h.db.QueryRow(query, args...))
 	if err != nil {
 		return nil, err
 	}
@@ -954,8 +1007,9 @@ func (h *DeliveryHandler) loadRiderInfo(d *models.Delivery) {
 	}
 }
 
-// Helper function to load delivery items
+// Helper function to load delivery items onto the delivery
 func (h *DeliveryHandler) loadDeliveryItems(d *models.Delivery) {
+	d.Items = []models.DeliveryItem{}
 	rows, err := h.db.Query(`
 		SELECT id, delivery_id, product_id, product_name, is_fragile, created_at
 		FROM delivery_items
@@ -976,6 +1030,18 @@ func (h *DeliveryHandler) loadDeliveryItems(d *models.Delivery) {
 		}
 		items = append(items, item)
 	}
-	// Note: Delivery model doesn't have Items field, but we could add it if needed
+
+	// Fragility is tracked per item; the delivery-level flag follows its items
+	fragileCount := 0
+	for _, item := range items {
+		if item.IsFragile {
+			fragileCount++
+		}
+	}
+	if len(items) > 0 {
+		d.FragileItemCount = fragileCount
+		d.IsFragile = fragileCount > 0
+	}
+	d.Items = items
 }
 
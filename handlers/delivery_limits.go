@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/xashathebest/clovia/config"
+)
+
+// checkDeliveryItems applies the delivery item caps. carried is what the rider already
+// holds on active standard deliveries; it is 0 when a delivery is being requested.
+func checkDeliveryItems(limits config.DeliveryLimits, deliveryType string, carried, adding int) error {
+	max := limits.MaxItems(deliveryType)
+	if deliveryType == "express" {
+		if adding > max {
+			return fmt.Errorf("Express delivery allows at most %d item(s) per delivery", max)
+		}
+		return nil
+	}
+	if carried == 0 && adding > max {
+		return fmt.Errorf("Standard delivery allows maximum %d items per batch", max)
+	}
+	if carried+adding > max {
+		return fmt.Errorf("Cannot add delivery: would exceed %d item limit (current: %d, adding: %d)", max, carried, adding)
+	}
+	return nil
+}
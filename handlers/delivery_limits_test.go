@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/xashathebest/clovia/config"
+)
+
+func TestCheckDeliveryItems(t *testing.T) {
+	limits := config.DeliveryLimits{StandardMaxItems: 5, ExpressMaxItems: 1}
+	cases := []struct {
+		deliveryType    string
+		carried, adding int
+		ok              bool
+	}{
+		{"express", 0, 1, true},
+		{"express", 0, 2, false},
+		{"standard", 0, 5, true},
+		{"standard", 0, 6, false},
+		{"standard", 3, 2, true},
+		{"standard", 3, 3, false},
+	}
+	for _, tc := range cases {
+		err := checkDeliveryItems(limits, tc.deliveryType, tc.carried, tc.adding)
+		if (err == nil) != tc.ok {
+			t.Errorf("checkDeliveryItems(%s, carried %d, adding %d) = %v, want ok=%v", tc.deliveryType, tc.carried, tc.adding, err, tc.ok)
+		}
+	}
+}
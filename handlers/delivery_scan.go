@@ -0,0 +1,33 @@
+package handlers
+
+import "github.com/xashathebest/clovia/models"
+
+// deliveryColumns is the select list read by scanDelivery. Queries alias deliveries as d
+// and join the customer as u.
+const deliveryColumns = `d.id, d.user_id, d.trade_id, d.delivery_type, d.status, d.rider_id,
+			d.pickup_latitude, d.pickup_longitude, d.pickup_address,
+			d.delivery_latitude, d.delivery_longitude, d.delivery_address,
+			d.special_instructions, d.total_cost, d.estimated_eta, d.item_count, d.is_fragile,
+			d.claimed_at, d.assignment_expires_at, d.stale_flagged_at, d.picked_up_at, d.in_transit_at, d.delivered_at,
+			d.created_at, d.updated_at,
+			u.name AS user_name`
+
+// rowScanner is satisfied by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanDelivery reads one row selected with deliveryColumns
+func scanDelivery(row rowScanner) (models.Delivery, error) {
+	var d models.Delivery
+	err := row.Scan(
+		&d.ID, &d.UserID, &d.TradeID, &d.DeliveryType, &d.Status, &d.RiderID,
+		&d.PickupLatitude, &d.PickupLongitude, &d.PickupAddress,
+		&d.DeliveryLatitude, &d.DeliveryLongitude, &d.DeliveryAddress,
+		&d.SpecialInstructions, &d.TotalCost, &d.EstimatedETA, &d.ItemCount, &d.IsFragile,
+		&d.ClaimedAt, &d.AssignmentExpiresAt, &d.StaleFlaggedAt, &d.PickedUpAt, &d.InTransitAt, &d.DeliveredAt,
+		&d.CreatedAt, &d.UpdatedAt,
+		&d.UserName,
+	)
+	return d, err
+}
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// activeTradeDelivery returns the id of the trade's delivery that hasn't been
+// cancelled, or 0 when the trade has none
+func activeTradeDelivery(q queryRower, tradeID int) (int, error) {
+	var id int
+	err := q.QueryRow(
+		"SELECT id FROM deliveries WHERE trade_id = ? AND status <> 'cancelled' ORDER BY id LIMIT 1",
+		tradeID,
+	).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return id, err
+}
+
+// isDuplicateEntry reports whether err is MySQL's duplicate key error (1062)
+func isDuplicateEntry(err error) bool {
+	var myErr *mysql.MySQLError
+	return errors.As(err, &myErr) && myErr.Number == 1062
+}
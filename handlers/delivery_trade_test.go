@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestTradeHasOneActiveDelivery returns the arranged delivery on a repeat request and allows a replacement after cancelling
+func TestTradeHasOneActiveDelivery(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	sellerID := createTestUser(t, db, "delivery_seller")
+	buyerID := createTestUser(t, db, "delivery_buyer")
+	target := createTestProduct(t, db, sellerID, "Delivered Item")
+	res, err := db.Exec("INSERT INTO trades (buyer_id, seller_id, target_product_id, status) VALUES (?, ?, ?, 'accepted')", buyerID, sellerID, target)
+	if err != nil {
+		t.Fatalf("insert trade: %v", err)
+	}
+	tradeID64, _ := res.LastInsertId()
+	tradeID := int(tradeID64)
+	t.Cleanup(func() {
+		db.Exec("DELETE FROM deliveries WHERE trade_id = ?", tradeID)
+		db.Exec("DELETE FROM trades WHERE id = ?", tradeID)
+	})
+
+	handler := &DeliveryHandler{db: db}
+	currentUser := buyerID
+	app := newTestApp(&currentUser, func(app *fiber.App) {
+		app.Post("/deliveries", handler.CreateDelivery)
+	})
+	body := fmt.Sprintf(`{"trade_id":%d,"delivery_type":"standard","pickup_address":"A","delivery_address":"B","product_ids":[%d]}`, tradeID, target)
+	create := func(asUser int) (int, int) {
+		currentUser = asUser
+		req := httptest.NewRequest("POST", "/deliveries", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		var out struct {
+			Data struct {
+				ID int `json:"id"`
+			} `json:"data"`
+		}
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out.Data.ID
+	}
+
+	code, firstID := create(buyerID)
+	if code != 201 {
+		t.Fatalf("first delivery: expected 201, got %d", code)
+	}
+	if code, id := create(sellerID); code != 200 || id != firstID {
+		t.Errorf("duplicate delivery: got %d id %d, want 200 with existing id %d", code, id, firstID)
+	}
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM deliveries WHERE trade_id = ?", tradeID).Scan(&count)
+	if count != 1 {
+		t.Errorf("trade has %d deliveries, want 1", count)
+	}
+
+	db.Exec("UPDATE deliveries SET status = 'cancelled' WHERE id = ?", firstID)
+	if code, id := create(buyerID); code != 201 || id == firstID {
+		t.Errorf("replacement delivery: got %d id %d, want 201 with a new id", code, id)
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"database/sql"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/xashathebest/clovia/middleware"
+	"github.com/xashathebest/clovia/models"
+)
+
+// recordRiderLocation appends a breadcrumb to a delivery's trail
+func recordRiderLocation(db execer, deliveryID, riderID int, lat, lon float64) error {
+	_, err := db.Exec(
+		"INSERT INTO rider_location_history (delivery_id, rider_id, latitude, longitude) VALUES (?, ?, ?, ?)",
+		deliveryID, riderID, lat, lon,
+	)
+	return err
+}
+
+// GetDeliveryTrail returns the ordered breadcrumbs of a delivery to its owner or assigned rider
+func (h *DeliveryHandler) GetDeliveryTrail(c *fiber.Ctx) error {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "User not authenticated"})
+	}
+	deliveryID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid delivery ID"})
+	}
+
+	var ownerID int
+	var riderID sql.NullInt64
+	trail := models.DeliveryTrail{DeliveryID: deliveryID, Points: []models.RiderLocationPoint{}}
+	err = h.db.QueryRow("SELECT user_id, rider_id, status FROM deliveries WHERE id = ?", deliveryID).Scan(&ownerID, &riderID, &trail.Status)
+	if err == sql.ErrNoRows {
+		return c.Status(404).JSON(models.APIResponse{Success: false, Error: "Delivery not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to fetch delivery"})
+	}
+	// Same rider identity UpdateDeliveryStatus checks against
+	if ownerID != userID && !(riderID.Valid && int(riderID.Int64) == userID) {
+		return c.Status(403).JSON(models.APIResponse{Success: false, Error: "Only the delivery owner or assigned rider can view its trail"})
+	}
+
+	rows, err := h.db.Query(
+		"SELECT latitude, longitude, recorded_at FROM rider_location_history WHERE delivery_id = ? ORDER BY recorded_at, id",
+		deliveryID,
+	)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to fetch delivery trail"})
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p models.RiderLocationPoint
+		if err := rows.Scan(&p.Latitude, &p.Longitude, &p.RecordedAt); err != nil {
+			return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to read delivery trail"})
+		}
+		trail.Points = append(trail.Points, p)
+	}
+
+	return c.JSON(models.APIResponse{Success: true, Data: trail})
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/xashathebest/clovia/models"
+)
+
+// TestDeliveryTrailIsOrderedAndOwnerOnly replays breadcrumbs in order and hides them from strangers
+func TestDeliveryTrailIsOrderedAndOwnerOnly(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	ownerID := createTestUser(t, db, "trail_owner")
+	strangerID := createTestUser(t, db, "trail_stranger")
+	res, err := db.Exec("INSERT INTO deliveries (user_id, status, pickup_address, delivery_address) VALUES (?, 'in_transit', 'A', 'B')", ownerID)
+	if err != nil {
+		t.Fatalf("insert delivery: %v", err)
+	}
+	id64, _ := res.LastInsertId()
+	deliveryID := int(id64)
+	defer db.Exec("DELETE FROM deliveries WHERE id = ?", deliveryID)
+
+	points := [][2]float64{{14.5995, 120.9842}, {14.6010, 120.9860}, {14.6042, 120.9901}}
+	for _, p := range points {
+		if err := recordRiderLocation(db, deliveryID, ownerID, p[0], p[1]); err != nil {
+			t.Fatalf("recordRiderLocation: %v", err)
+		}
+	}
+
+	handler := &DeliveryHandler{db: db}
+	currentUser := ownerID
+	app := newTestApp(&currentUser, func(app *fiber.App) {
+		app.Get("/deliveries/:id/trail", handler.GetDeliveryTrail)
+	})
+	path := fmt.Sprintf("/deliveries/%d/trail", deliveryID)
+
+	currentUser = strangerID
+	resp, _ := app.Test(httptest.NewRequest("GET", path, nil), -1)
+	if resp.StatusCode != 403 {
+		t.Errorf("stranger trail: expected 403, got %d", resp.StatusCode)
+	}
+
+	currentUser = ownerID
+	resp, _ = app.Test(httptest.NewRequest("GET", path, nil), -1)
+	if resp.StatusCode != 200 {
+		t.Fatalf("owner trail: expected 200, got %d", resp.StatusCode)
+	}
+	var body struct {
+		Data models.DeliveryTrail `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	if len(body.Data.Points) != len(points) {
+		t.Fatalf("trail has %d points, want %d", len(body.Data.Points), len(points))
+	}
+	for i, p := range body.Data.Points {
+		if p.Latitude != points[i][0] || p.Longitude != points[i][1] {
+			t.Errorf("point %d = %+v, want %v", i, p, points[i])
+		}
+	}
+}
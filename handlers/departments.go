@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/models"
+)
+
+// errUnknownDepartment is the message for a department that is not on the official list
+const errUnknownDepartment = "Please select your department/college from the list"
+
+// resolveDepartment finds the active department a submitted value refers to. The signup
+// dropdown sends the id; the code or full name are accepted too, ignoring case.
+// Returns sql.ErrNoRows when nothing matches.
+func resolveDepartment(db queryRower, value string) (models.Department, error) {
+	var d models.Department
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return d, sql.ErrNoRows
+	}
+	id, _ := strconv.Atoi(value) // 0 when the value is a code or name
+	err := db.QueryRow(
+		"SELECT id, code, name FROM departments WHERE is_active = TRUE AND (id = ? OR UPPER(code) = UPPER(?) OR LOWER(name) = LOWER(?)) LIMIT 1",
+		id, value, value,
+	).Scan(&d.ID, &d.Code, &d.Name)
+	return d, err
+}
+
+// GetDepartments lists the active departments for the signup and profile dropdowns
+func (h *UserHandler) GetDepartments(c *fiber.Ctx) error {
+	rows, err := h.db.Query("SELECT id, code, name FROM departments WHERE is_active = TRUE ORDER BY name")
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to fetch departments"})
+	}
+	defer rows.Close()
+
+	departments := []models.Department{}
+	for rows.Next() {
+		var d models.Department
+		if err := rows.Scan(&d.ID, &d.Code, &d.Name); err != nil {
+			return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to read departments"})
+		}
+		departments = append(departments, d)
+	}
+
+	c.Set(fiber.HeaderCacheControl, "public, max-age="+metaMaxAge)
+	return c.JSON(models.APIResponse{Success: true, Data: departments})
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"mime/multipart"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/models"
+	"github.com/xashathebest/clovia/services"
+)
+
+// hashUploads hashes each uploaded image; files that cannot be read are skipped
+func hashUploads(files []*multipart.FileHeader) []string {
+	hashes := make([]string, 0, len(files))
+	for _, fh := range files {
+		f, err := fh.Open()
+		if err != nil {
+			log.Printf("Warning: failed to open upload %q for hashing: %v", fh.Filename, err)
+			continue
+		}
+		hash, err := services.HashImage(f)
+		f.Close()
+		if err != nil {
+			log.Printf("Warning: failed to hash upload %q: %v", fh.Filename, err)
+			continue
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes
+}
+
+// recordListingDuplicates stores a new listing's image hashes and, when it was flagged,
+// the listing it duplicates, alongside the counterfeit signals
+func recordListingDuplicates(db execer, productID int, imageHashes []string, match *services.DuplicateMatch) error {
+	hashesJSON, _ := json.Marshal(imageHashes)
+	if match == nil {
+		_, err := db.Exec("UPDATE products SET image_hashes = ? WHERE id = ?", string(hashesJSON), productID)
+		return err
+	}
+	flagsJSON, _ := json.Marshal(match.Reasons)
+	_, err := db.Exec(
+		"UPDATE products SET image_hashes = ?, duplicate_of_product_id = ?, duplicate_similarity = ?, duplicate_flags = ? WHERE id = ?",
+		string(hashesJSON), match.ProductID, match.Similarity, string(flagsJSON), productID,
+	)
+	return err
+}
+
+// GetDuplicateListings is the admin review queue of listings flagged as near-duplicates,
+// newest first, each with the earlier listing it matched. ?status= narrows by listing status.
+func (h *AdminHandler) GetDuplicateListings(c *fiber.Ctx) error {
+	pg, err := parsePagination(c)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: err.Error()})
+	}
+	where := "WHERE p.duplicate_of_product_id IS NOT NULL"
+	var args []interface{}
+	if status := c.Query("status"); status != "" {
+		where += " AND p.status = ?"
+		args = append(args, status)
+	}
+
+	var total int
+	if err := h.db.QueryRowContext(c.UserContext(), "SELECT COUNT(*) FROM products p "+where, args...).Scan(&total); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to count duplicate listings"})
+	}
+
+	rows, err := h.db.QueryContext(c.UserContext(), `
+		SELECT p.id, p.title, p.status, p.seller_id, COALESCE(u.name, ''), p.created_at,
+			p.duplicate_of_product_id, COALESCE(o.title, ''), COALESCE(o.status, ''),
+			COALESCE(p.duplicate_similarity, 0), p.duplicate_flags
+		FROM products p
+		LEFT JOIN products o ON o.id = p.duplicate_of_product_id
+		LEFT JOIN users u ON u.id = p.seller_id
+		`+where+`
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT ? OFFSET ?`, append(args, pg.Limit, pg.Offset)...)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to fetch duplicate listings"})
+	}
+	defer rows.Close()
+
+	listings := []models.DuplicateListing{}
+	for rows.Next() {
+		var d models.DuplicateListing
+		var flags sql.NullString
+		if err := rows.Scan(&d.ProductID, &d.Title, &d.Status, &d.SellerID, &d.SellerName, &d.CreatedAt,
+			&d.DuplicateOfID, &d.DuplicateOfTitle, &d.DuplicateOfStatus, &d.Similarity, &flags); err != nil {
+			continue
+		}
+		if flags.Valid && flags.String != "" {
+			_ = d.Reasons.UnmarshalJSON([]byte(flags.String))
+		}
+		listings = append(listings, d)
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data: models.PaginatedResponse{
+			Data:       listings,
+			Total:      total,
+			Page:       pg.Page,
+			Limit:      pg.Limit,
+			TotalPages: (total + pg.Limit - 1) / pg.Limit,
+		},
+	})
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestJSONWithETag(t *testing.T) {
+	name := "first"
+	app := fiber.New()
+	app.Get("/thing", func(c *fiber.Ctx) error {
+		return jsonWithETag(c, fiber.Map{"name": name})
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/thing", nil))
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	tag := resp.Header.Get("ETag")
+	if resp.StatusCode != 200 || tag == "" {
+		t.Fatalf("first fetch = %d with ETag %q, want 200 with a tag", resp.StatusCode, tag)
+	}
+
+	req := httptest.NewRequest("GET", "/thing", nil)
+	req.Header.Set("If-None-Match", `"stale", W/`+tag)
+	resp, err = app.Test(req)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if resp.StatusCode != 304 {
+		t.Errorf("unchanged fetch = %d, want 304", resp.StatusCode)
+	}
+
+	name = "second"
+	req = httptest.NewRequest("GET", "/thing", nil)
+	req.Header.Set("If-None-Match", tag)
+	resp, err = app.Test(req)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if resp.StatusCode != 200 || resp.Header.Get("ETag") == tag {
+		t.Errorf("changed fetch = %d with ETag %q, want 200 with a new tag", resp.StatusCode, resp.Header.Get("ETag"))
+	}
+}
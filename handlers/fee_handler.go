@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/models"
+	"github.com/xashathebest/clovia/services"
+)
+
+// FeeHandler serves platform fee previews
+type FeeHandler struct{}
+
+// NewFeeHandler creates a new fee handler
+func NewFeeHandler() *FeeHandler {
+	return &FeeHandler{}
+}
+
+// GetFeeQuote previews the platform fee for ?amount= and ?kind= (order or trade)
+func (h *FeeHandler) GetFeeQuote(c *fiber.Ctx) error {
+	amount, err := strconv.ParseFloat(c.Query("amount"), 64)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "amount must be a number",
+		})
+	}
+	kind := c.Query("kind", services.FeeKindOrder)
+	if !services.IsValidFeeKind(kind) {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "kind must be one of: order, trade",
+		})
+	}
+
+	quote, err := services.ComputeFee(amount, kind)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    quote,
+	})
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/middleware"
+	"github.com/xashathebest/clovia/models"
+	"github.com/xashathebest/clovia/services"
+)
+
+// geocodeFailedWarning is returned with a listing whose location could not be mapped
+const geocodeFailedWarning = "Product created, but its location could not be found on the map. Check the address and retry geocoding."
+
+// geocoder resolves a location; swapped out in tests
+var geocoder = services.GetCoordinates
+
+// geocodeLocation resolves a listing location to coordinates. An empty location is
+// skipped; a lookup error is logged and reported as failed rather than blocking the caller.
+func geocodeLocation(location string) (*float64, *float64, string) {
+	location = strings.TrimSpace(location)
+	if location == "" {
+		return nil, nil, models.GeocodeSkipped
+	}
+	coords, err := geocoder(location)
+	if err != nil {
+		log.Printf("Geocoding %q failed: %v", location, err)
+		return nil, nil, models.GeocodeFailed
+	}
+	return &coords.Latitude, &coords.Longitude, models.GeocodeOK
+}
+
+// RegeocodeProduct retries geocoding for the owner's listing, typically after its location
+// was edited. Coordinates are replaced on success and cleared otherwise so the map never
+// shows a stale position for a changed address.
+func (h *ProductHandler) RegeocodeProduct(c *fiber.Ctx) error {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "User not authenticated"})
+	}
+
+	productID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid product ID"})
+	}
+
+	var sellerID int
+	var location sql.NullString
+	err = h.db.QueryRow("SELECT seller_id, location FROM products WHERE id = ?", productID).Scan(&sellerID, &location)
+	if err == sql.ErrNoRows {
+		return c.Status(404).JSON(models.APIResponse{Success: false, Error: "Product not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to retrieve product"})
+	}
+	if sellerID != userID {
+		return c.Status(403).JSON(models.APIResponse{Success: false, Error: "Only the owner can update this product"})
+	}
+
+	lat, lon, status := geocodeLocation(location.String)
+	_, err = h.db.Exec("UPDATE products SET latitude = ?, longitude = ?, geocode_status = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", lat, lon, status, productID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to save coordinates"})
+	}
+
+	product, err := h.loadProductDetail("p.id = ?", productID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to retrieve product"})
+	}
+
+	message := "Location geocoded"
+	switch status {
+	case models.GeocodeFailed:
+		message = "Location could not be found on the map. Check the address and try again."
+	case models.GeocodeSkipped:
+		message = "Product has no location to geocode"
+	}
+	return c.JSON(models.APIResponse{Success: true, Message: message, Data: product})
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/xashathebest/clovia/models"
+	"github.com/xashathebest/clovia/services"
+)
+
+func TestGeocodeLocation(t *testing.T) {
+	orig := geocoder
+	defer func() { geocoder = orig }()
+	geocoder = func(location string) (services.Coordinates, error) {
+		if location == "Zamboanga City" {
+			return services.Coordinates{Latitude: 6.9, Longitude: 122.07}, nil
+		}
+		return services.Coordinates{}, errors.New("no results found for the given location")
+	}
+
+	if lat, lon, status := geocodeLocation("  "); status != models.GeocodeSkipped || lat != nil || lon != nil {
+		t.Errorf("blank location = %v, %v, %q; want skipped without coordinates", lat, lon, status)
+	}
+	if lat, lon, status := geocodeLocation("Zambonga Ctiy"); status != models.GeocodeFailed || lat != nil || lon != nil {
+		t.Errorf("typo'd location = %v, %v, %q; want failed without coordinates", lat, lon, status)
+	}
+	lat, lon, status := geocodeLocation("Zamboanga City")
+	if status != models.GeocodeOK || lat == nil || lon == nil || *lat != 6.9 || *lon != 122.07 {
+		t.Errorf("valid location = %v, %v, %q; want ok with coordinates", lat, lon, status)
+	}
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/xashathebest/clovia/config"
+	"github.com/xashathebest/clovia/models"
+)
+
+// logImageURLErrors controls whether unparseable image_urls values are logged on read
+var logImageURLErrors = config.GetEnvBool("LOG_IMAGE_URL_ERRORS", true)
+
+// badImagesRawLimit caps how much of a corrupt value the admin scan echoes back
+const badImagesRawLimit = 200
+
+// logImageURLError records a corrupt image_urls value so it does not silently read as "no images"
+func logImageURLError(productID int, err error) {
+	if logImageURLErrors && err != nil {
+		log.Printf("warning: product %d has unparseable image_urls: %v", productID, err)
+	}
+}
+
+// parseImageURLs decodes a stored image_urls value. A corrupt value is logged and read as
+// an empty list so clients still get the product.
+func parseImageURLs(productID int, raw string) models.StringArray {
+	var urls models.StringArray
+	if err := urls.UnmarshalJSON([]byte(raw)); err != nil {
+		logImageURLError(productID, err)
+		return models.StringArray{}
+	}
+	return urls
+}
+
+// GetBadImageProducts scans every product and lists those whose image_urls cannot be parsed
+func (h *AdminHandler) GetBadImageProducts(c *fiber.Ctx) error {
+	rows, err := h.db.QueryContext(c.UserContext(), `
+		SELECT id, COALESCE(title, ''), seller_id, COALESCE(status, ''), CAST(image_urls AS CHAR)
+		FROM products
+		WHERE image_urls IS NOT NULL
+		ORDER BY id`)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to scan products"})
+	}
+	defer rows.Close()
+
+	scanned := 0
+	bad := []models.BadImageProduct{}
+	for rows.Next() {
+		var p models.BadImageProduct
+		if err := rows.Scan(&p.ProductID, &p.Title, &p.SellerID, &p.Status, &p.Raw); err != nil {
+			continue
+		}
+		scanned++
+		if p.Raw == "" {
+			continue
+		}
+		var urls models.StringArray
+		err := urls.UnmarshalJSON([]byte(p.Raw))
+		if err == nil {
+			continue
+		}
+		p.Error = err.Error()
+		if len(p.Raw) > badImagesRawLimit {
+			p.Raw = p.Raw[:badImagesRawLimit]
+		}
+		bad = append(bad, p)
+	}
+	if err := rows.Err(); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to scan products"})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    fiber.Map{"scanned": scanned, "bad": len(bad), "products": bad},
+	})
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"database/sql"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/middleware"
+	"github.com/xashathebest/clovia/models"
+)
+
+// inventoryScope selects a seller's available listings
+const inventoryScope = "FROM products p WHERE p.seller_id = ? AND p.status = 'available'"
+
+// GetInventoryValue returns the total suggested value and price of the caller's
+// available listings, broken down by category and condition, plus how many
+// listings are missing a condition or category.
+func (h *UserHandler) GetInventoryValue(c *fiber.Ctx) error {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "User not authenticated"})
+	}
+	inv, err := loadInventoryValue(h.db, userID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to compute inventory value"})
+	}
+	return c.JSON(models.APIResponse{Success: true, Data: inv})
+}
+
+// loadInventoryValue aggregates the stored appraisal data for one seller
+func loadInventoryValue(db *sql.DB, sellerID int) (models.InventoryValue, error) {
+	inv := models.InventoryValue{TotalPriceByCurrency: map[string]float64{}}
+	err := db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(p.suggested_value), 0), COALESCE(SUM(p.price), 0),
+			COALESCE(SUM(p.`+"`condition`"+` IS NULL OR p.`+"`condition`"+` = ''), 0),
+			COALESCE(SUM(p.category IS NULL OR p.category = ''), 0),
+			COALESCE(SUM(p.`+"`condition`"+` IS NULL OR p.`+"`condition`"+` = '' OR p.category IS NULL OR p.category = ''), 0)
+		`+inventoryScope, sellerID).Scan(&inv.ActiveListings, &inv.TotalSuggestedValue, &inv.TotalPrice,
+		&inv.MissingCondition, &inv.MissingCategory, &inv.IncompleteListings)
+	if err != nil {
+		return inv, err
+	}
+
+	rows, err := db.Query("SELECT COALESCE(p.currency, 'PHP'), COALESCE(SUM(p.price), 0) "+inventoryScope+" GROUP BY 1", sellerID)
+	if err != nil {
+		return inv, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var currency string
+		var total float64
+		if err := rows.Scan(&currency, &total); err != nil {
+			return inv, err
+		}
+		inv.TotalPriceByCurrency[currency] = total
+	}
+	if err := rows.Err(); err != nil {
+		return inv, err
+	}
+
+	if inv.ByCategory, err = inventoryBreakdown(db, sellerID, "COALESCE(NULLIF(p.category, ''), 'Uncategorized')"); err != nil {
+		return inv, err
+	}
+	if inv.ByCondition, err = inventoryBreakdown(db, sellerID, "COALESCE(NULLIF(p.`condition`, ''), 'Unspecified')"); err != nil {
+		return inv, err
+	}
+	return inv, nil
+}
+
+// inventoryBreakdown groups a seller's available listings by expr, largest suggested value first
+func inventoryBreakdown(db *sql.DB, sellerID int, expr string) ([]models.InventoryBreakdown, error) {
+	rows, err := db.Query("SELECT "+expr+" AS bucket, COUNT(*), COALESCE(SUM(p.suggested_value), 0), COALESCE(SUM(p.price), 0) "+
+		inventoryScope+" GROUP BY bucket ORDER BY SUM(p.suggested_value) DESC, bucket ASC", sellerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []models.InventoryBreakdown{}
+	for rows.Next() {
+		var b models.InventoryBreakdown
+		if err := rows.Scan(&b.Value, &b.Count, &b.SuggestedValue, &b.Price); err != nil {
+			return nil, err
+		}
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
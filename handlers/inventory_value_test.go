@@ -0,0 +1,39 @@
+package handlers
+
+import "testing"
+
+func TestLoadInventoryValue(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	sellerID := createTestUser(t, db, "Inventory Seller")
+	complete := createTestProduct(t, db, sellerID, "Complete listing")
+	gap := createTestProduct(t, db, sellerID, "Missing condition")
+	sold := createTestProduct(t, db, sellerID, "Sold listing")
+	if _, err := db.Exec("UPDATE products SET `condition` = 'New', category = 'Books', suggested_value = 100 WHERE id = ?", complete); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if _, err := db.Exec("UPDATE products SET `condition` = NULL, category = 'Books', suggested_value = 50 WHERE id = ?", gap); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if _, err := db.Exec("UPDATE products SET status = 'sold', suggested_value = 999 WHERE id = ?", sold); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	inv, err := loadInventoryValue(db, sellerID)
+	if err != nil {
+		t.Fatalf("loadInventoryValue: %v", err)
+	}
+	if inv.ActiveListings != 2 || inv.TotalSuggestedValue != 150 || inv.TotalPrice != 200 {
+		t.Errorf("totals = %d listings, %d points, %.2f price; want 2, 150, 200", inv.ActiveListings, inv.TotalSuggestedValue, inv.TotalPrice)
+	}
+	if inv.MissingCondition != 1 || inv.MissingCategory != 0 || inv.IncompleteListings != 1 {
+		t.Errorf("gaps = condition %d, category %d, incomplete %d; want 1, 0, 1", inv.MissingCondition, inv.MissingCategory, inv.IncompleteListings)
+	}
+	if len(inv.ByCategory) != 1 || inv.ByCategory[0].Value != "Books" || inv.ByCategory[0].Count != 2 {
+		t.Errorf("by category = %+v, want one Books bucket of 2", inv.ByCategory)
+	}
+	if len(inv.ByCondition) != 2 || inv.ByCondition[0].Value != "New" {
+		t.Errorf("by condition = %+v, want New first then Unspecified", inv.ByCondition)
+	}
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import "testing"
+
+// TestListingLimitBoundary verifies the active listing cap allows exactly `limit` listings
+func TestListingLimitBoundary(t *testing.T) {
+	t.Setenv("MAX_ACTIVE_LISTINGS", "3")
+	t.Setenv("MAX_ACTIVE_LISTINGS_PREMIUM", "5")
+
+	limit := activeListingLimit(false, nil)
+	if limit != 3 {
+		t.Fatalf("expected default limit 3, got %d", limit)
+	}
+	if listingLimitReached(2, limit) {
+		t.Error("listing below the limit should be allowed")
+	}
+	if !listingLimitReached(3, limit) {
+		t.Error("listing at the limit should be rejected")
+	}
+
+	if got := activeListingLimit(true, nil); got != 5 {
+		t.Errorf("expected premium limit 5, got %d", got)
+	}
+
+	override := 10
+	if got := activeListingLimit(false, &override); got != 10 {
+		t.Errorf("expected admin override 10, got %d", got)
+	}
+
+	unlimited := 0
+	if listingLimitReached(1000, activeListingLimit(false, &unlimited)) {
+		t.Error("override of 0 should mean unlimited")
+	}
+}
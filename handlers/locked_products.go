@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/middleware"
+	"github.com/xashathebest/clovia/models"
+)
+
+// GetLockedProducts lists the caller's locked listings with the open trade holding each
+// one and who it is with, so sellers can see why an item is missing from their active
+// listings. A locked product no open trade references is still listed, without a trade.
+func (h *UserHandler) GetLockedProducts(c *fiber.Ctx) error {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "User not authenticated"})
+	}
+	locked, err := loadLockedProducts(h.db, userID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to fetch locked products"})
+	}
+	return c.JSON(models.APIResponse{Success: true, Data: locked})
+}
+
+// loadLockedProducts joins each locked listing to the most recently updated open trade
+// that has it as the target or an offered item
+func loadLockedProducts(db *sql.DB, sellerID int) ([]models.LockedProduct, error) {
+	statusPlaceholders := strings.TrimSuffix(strings.Repeat("?,", len(openTradeStatuses)), ",")
+	var args []interface{}
+	for _, s := range openTradeStatuses {
+		args = append(args, s)
+	}
+	args = append(args, sellerID, sellerID)
+
+	rows, err := db.Query(`
+		SELECT p.id, p.title, COALESCE(p.slug, ''), p.image_urls,
+			t.id, t.status, cp.id, cp.name, COALESCE(cp.username, ''), t.updated_at
+		FROM products p
+		LEFT JOIN trades t ON t.id = (
+			SELECT t2.id FROM trades t2
+			WHERE t2.status IN (`+statusPlaceholders+`)
+			AND (t2.target_product_id = p.id
+				OR EXISTS (SELECT 1 FROM trade_items ti WHERE ti.trade_id = t2.id AND ti.product_id = p.id))
+			ORDER BY t2.updated_at DESC, t2.id DESC
+			LIMIT 1
+		)
+		LEFT JOIN users cp ON cp.id = CASE WHEN t.buyer_id = ? THEN t.seller_id ELSE t.buyer_id END
+		WHERE p.seller_id = ? AND p.status = 'locked'
+		ORDER BY t.updated_at DESC, p.id DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	locked := []models.LockedProduct{}
+	for rows.Next() {
+		var lp models.LockedProduct
+		var images sql.NullString
+		var tradeID, counterpartyID sql.NullInt64
+		var tradeStatus, counterpartyName, counterpartyUsername sql.NullString
+		var tradeUpdated sql.NullTime
+		if err := rows.Scan(&lp.ProductID, &lp.Title, &lp.Slug, &images,
+			&tradeID, &tradeStatus, &counterpartyID, &counterpartyName, &counterpartyUsername, &tradeUpdated); err != nil {
+			return nil, err
+		}
+		if images.Valid && images.String != "" {
+			_ = lp.ImageURLs.UnmarshalJSON([]byte(images.String))
+		}
+		if tradeID.Valid {
+			id := int(tradeID.Int64)
+			lp.TradeID = &id
+			lp.TradeStatus = tradeStatus.String
+		}
+		if counterpartyID.Valid {
+			id := int(counterpartyID.Int64)
+			lp.CounterpartyID = &id
+			lp.CounterpartyName = counterpartyName.String
+			lp.CounterpartyUsername = counterpartyUsername.String
+		}
+		if tradeUpdated.Valid {
+			t := tradeUpdated.Time
+			lp.TradeUpdatedAt = &t
+		}
+		locked = append(locked, lp)
+	}
+	return locked, rows.Err()
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import "testing"
+
+func TestLoadLockedProducts(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	sellerID := createTestUser(t, db, "locked_seller")
+	buyerID := createTestUser(t, db, "locked_buyer")
+	held := createTestProduct(t, db, sellerID, "Held by trade")
+	orphan := createTestProduct(t, db, sellerID, "Locked without trade")
+	createTestProduct(t, db, sellerID, "Still available")
+	if _, err := db.Exec("UPDATE products SET status = 'locked' WHERE id IN (?, ?)", held, orphan); err != nil {
+		t.Fatalf("lock products: %v", err)
+	}
+	res, err := db.Exec("INSERT INTO trades (buyer_id, seller_id, target_product_id, status) VALUES (?, ?, ?, 'accepted')", buyerID, sellerID, held)
+	if err != nil {
+		t.Fatalf("insert trade: %v", err)
+	}
+	id64, _ := res.LastInsertId()
+	tradeID := int(id64)
+	t.Cleanup(func() { db.Exec("DELETE FROM trades WHERE id = ?", tradeID) })
+
+	locked, err := loadLockedProducts(db, sellerID)
+	if err != nil {
+		t.Fatalf("loadLockedProducts: %v", err)
+	}
+	if len(locked) != 2 {
+		t.Fatalf("got %d locked products, want 2", len(locked))
+	}
+	for _, lp := range locked {
+		switch lp.ProductID {
+		case held:
+			if lp.TradeID == nil || *lp.TradeID != tradeID || lp.CounterpartyID == nil || *lp.CounterpartyID != buyerID {
+				t.Errorf("held product = %+v, want trade %d with buyer %d", lp, tradeID, buyerID)
+			}
+		case orphan:
+			if lp.TradeID != nil || lp.CounterpartyID != nil {
+				t.Errorf("orphan product = %+v, want no trade", lp)
+			}
+		default:
+			t.Errorf("unexpected product %d", lp.ProductID)
+		}
+	}
+}
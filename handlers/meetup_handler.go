@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"sort"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/database"
+	"github.com/xashathebest/clovia/models"
+	"github.com/xashathebest/clovia/services"
+)
+
+// MeetupHandler serves safe public meetup locations for in-person trades
+type MeetupHandler struct {
+	db *sql.DB
+}
+
+// NewMeetupHandler creates a new meetup handler
+func NewMeetupHandler() *MeetupHandler {
+	return &MeetupHandler{db: database.DB}
+}
+
+// GetMeetupSpots lists active meetup spots.
+// Supports ?city= and ?near_lat=&near_lon= (sorted by distance), plus ?limit= (default 10, max 50).
+func (h *MeetupHandler) GetMeetupSpots(c *fiber.Ctx) error {
+	city := c.Query("city", "")
+	limit, _ := strconv.Atoi(c.Query("limit", "10"))
+	if limit <= 0 {
+		limit = 10
+	}
+	if limit > 50 {
+		limit = 50
+	}
+
+	latStr, lonStr := c.Query("near_lat", ""), c.Query("near_lon", "")
+	var near *[2]float64
+	if latStr != "" || lonStr != "" {
+		lat, latErr := strconv.ParseFloat(latStr, 64)
+		lon, lonErr := strconv.ParseFloat(lonStr, 64)
+		if latErr != nil || lonErr != nil || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Error:   "near_lat and near_lon must be given together as valid coordinates",
+			})
+		}
+		near = &[2]float64{lat, lon}
+	}
+
+	spots, err := loadMeetupSpots(h.db, city)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Failed to fetch meetup spots",
+		})
+	}
+
+	if near != nil {
+		rankMeetupSpots(spots, near[0], near[1])
+	}
+	if len(spots) > limit {
+		spots = spots[:limit]
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    spots,
+	})
+}
+
+// loadMeetupSpots returns active spots, optionally restricted to a city
+func loadMeetupSpots(db *sql.DB, city string) ([]models.MeetupSpot, error) {
+	query := "SELECT id, city, name, latitude, longitude FROM meetup_spots WHERE is_active = TRUE"
+	var args []interface{}
+	if city != "" {
+		query += " AND city = ?"
+		args = append(args, city)
+	}
+	query += " ORDER BY city, name"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	spots := []models.MeetupSpot{}
+	for rows.Next() {
+		var s models.MeetupSpot
+		if err := rows.Scan(&s.ID, &s.City, &s.Name, &s.Latitude, &s.Longitude); err != nil {
+			return nil, err
+		}
+		spots = append(spots, s)
+	}
+	return spots, rows.Err()
+}
+
+// rankMeetupSpots sets each spot's distance from (lat, lon) and sorts nearest first
+func rankMeetupSpots(spots []models.MeetupSpot, lat, lon float64) {
+	for i := range spots {
+		d := services.CalculateDistance(lat, lon, spots[i].Latitude, spots[i].Longitude).DistanceKm
+		spots[i].DistanceKm = &d
+	}
+	sort.SliceStable(spots, func(i, j int) bool {
+		return *spots[i].DistanceKm < *spots[j].DistanceKm
+	})
+}
+
+// meetupMidpoint returns the point halfway between two coordinates.
+// A plain average is accurate enough at the distances people meet up over.
+func meetupMidpoint(lat1, lon1, lat2, lon2 float64) (float64, float64) {
+	return (lat1 + lat2) / 2, (lon1 + lon2) / 2
+}
+
+// suggestMeetupSpot picks the active spot nearest the midpoint of two users' saved
+// coordinates. Returns nil when either user has no location or no spots exist.
+func suggestMeetupSpot(db *sql.DB, userA, userB int) *models.MeetupSpot {
+	var latA, lonA, latB, lonB sql.NullFloat64
+	if err := db.QueryRow("SELECT latitude, longitude FROM users WHERE id = ?", userA).Scan(&latA, &lonA); err != nil {
+		return nil
+	}
+	if err := db.QueryRow("SELECT latitude, longitude FROM users WHERE id = ?", userB).Scan(&latB, &lonB); err != nil {
+		return nil
+	}
+	if !latA.Valid || !lonA.Valid || !latB.Valid || !lonB.Valid {
+		return nil
+	}
+
+	spots, err := loadMeetupSpots(db, "")
+	if err != nil {
+		log.Printf("Warning: failed to load meetup spots: %v", err)
+		return nil
+	}
+	if len(spots) == 0 {
+		return nil
+	}
+
+	midLat, midLon := meetupMidpoint(latA.Float64, lonA.Float64, latB.Float64, lonB.Float64)
+	rankMeetupSpots(spots, midLat, midLon)
+	return &spots[0]
+}
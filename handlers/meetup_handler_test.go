@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/xashathebest/clovia/models"
+)
+
+func TestRankMeetupSpotsSortsByDistance(t *testing.T) {
+	spots := []models.MeetupSpot{
+		{ID: 1, Name: "SM North EDSA", Latitude: 14.6565, Longitude: 121.0290},
+		{ID: 2, Name: "BGC High Street", Latitude: 14.5508, Longitude: 121.0510},
+		{ID: 3, Name: "Glorietta", Latitude: 14.5509, Longitude: 121.0254},
+	}
+
+	// Near Ayala Avenue, Makati
+	rankMeetupSpots(spots, 14.5564, 121.0244)
+
+	want := []int{3, 2, 1}
+	for i, id := range want {
+		if spots[i].ID != id {
+			t.Fatalf("position %d: expected spot %d, got %d", i, id, spots[i].ID)
+		}
+		if spots[i].DistanceKm == nil {
+			t.Fatalf("spot %d has no distance", spots[i].ID)
+		}
+	}
+	if *spots[0].DistanceKm > *spots[1].DistanceKm {
+		t.Errorf("distances not ascending: %v > %v", *spots[0].DistanceKm, *spots[1].DistanceKm)
+	}
+}
+
+func TestMeetupMidpoint(t *testing.T) {
+	lat, lon := meetupMidpoint(14.5, 121.0, 14.7, 121.2)
+	if lat < 14.5999 || lat > 14.6001 || lon < 121.0999 || lon > 121.1001 {
+		t.Errorf("expected midpoint (14.6, 121.1), got (%v, %v)", lat, lon)
+	}
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"fmt"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/xashathebest/clovia/config"
+)
+
+// messageRateLimiter is a per-key token bucket: each key holds up to perMinute
+// tokens and regains them continuously over a minute
+type messageRateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// messageLimiter throttles chat and trade messages per sender and thread
+var messageLimiter = &messageRateLimiter{buckets: make(map[string]*tokenBucket)}
+
+// allow takes a token for key, reporting false when the bucket is empty
+func (l *messageRateLimiter) allow(key string, perMinute int, now time.Time) bool {
+	if perMinute <= 0 {
+		return true
+	}
+	capacity := float64(perMinute)
+	refillPerSec := capacity / 60
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.buckets) > 1000 {
+		l.prune(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: capacity, updated: now}
+		l.buckets[key] = b
+	}
+	b.tokens += now.Sub(b.updated).Seconds() * refillPerSec
+	if b.tokens > capacity {
+		b.tokens = capacity
+	}
+	b.updated = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// prune drops buckets idle long enough to have refilled completely
+func (l *messageRateLimiter) prune(now time.Time) {
+	for key, b := range l.buckets {
+		if now.Sub(b.updated) > time.Minute {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// allowMessage applies MESSAGES_PER_MINUTE to a sender within one thread (kind is "chat" or "trade")
+func allowMessage(kind string, userID, threadID int) bool {
+	key := fmt.Sprintf("%s:%d:%d", kind, userID, threadID)
+	return messageLimiter.allow(key, config.MessagesPerMinute(), time.Now())
+}
+
+// messageTooLong reports whether content exceeds MAX_MESSAGE_LENGTH characters
+func messageTooLong(content string) bool {
+	max := config.MaxMessageLength()
+	return max > 0 && utf8.RuneCountInString(content) > max
+}
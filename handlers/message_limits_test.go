@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMessageRateLimiterAllowsBurstThenBlocks(t *testing.T) {
+	l := &messageRateLimiter{buckets: make(map[string]*tokenBucket)}
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if !l.allow("chat:1:10", 3, now) {
+			t.Fatalf("message %d should be allowed", i+1)
+		}
+	}
+	if l.allow("chat:1:10", 3, now) {
+		t.Fatal("fourth message within the minute should be blocked")
+	}
+	if !l.allow("chat:1:11", 3, now) {
+		t.Error("other conversations have their own budget")
+	}
+	if !l.allow("chat:1:10", 3, now.Add(20*time.Second)) {
+		t.Error("a token should refill after 20s at 3 per minute")
+	}
+}
+
+func TestMessageTooLong(t *testing.T) {
+	t.Setenv("MAX_MESSAGE_LENGTH", "5")
+	if messageTooLong("héllo") {
+		t.Error("5 characters should fit, regardless of byte length")
+	}
+	if !messageTooLong(strings.Repeat("a", 6)) {
+		t.Error("6 characters should exceed the limit")
+	}
+}
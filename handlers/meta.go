@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/config"
+	"github.com/xashathebest/clovia/middleware"
+	"github.com/xashathebest/clovia/models"
+	"github.com/xashathebest/clovia/services"
+)
+
+// metaMaxAge is how long clients and proxies may cache /api/meta, in seconds
+const metaMaxAge = "300"
+
+// MetaHandler describes the running API to clients
+type MetaHandler struct{}
+
+// NewMetaHandler creates a new meta handler
+func NewMetaHandler() *MetaHandler {
+	return &MetaHandler{}
+}
+
+// GetMeta returns the version, enabled features and the limits clients need to validate
+// input before sending it. Everything is read from the live configuration.
+func (h *MetaHandler) GetMeta(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age="+metaMaxAge)
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data: fiber.Map{
+			"version": config.APIVersion,
+			"features": fiber.Map{
+				"delivery":    config.DeliveryEnabled(),
+				"ai_features": config.AIFeaturesEnabled(),
+				// Listings are fixed-price or barter; there is no auction flow
+				"bidding":     false,
+				"maintenance": middleware.CurrentMaintenanceMode().Enabled,
+			},
+			"products": fiber.Map{
+				"statuses":   models.ProductStatuses,
+				"conditions": models.ProductConditions,
+				"categories": services.ProductCategories(),
+				"currencies": config.SupportedCurrencies(),
+			},
+			"pagination": fiber.Map{
+				"default_limit": config.DefaultPageSize(),
+				"max_limit":     config.MaxPageSize(),
+			},
+			"uploads": fiber.Map{
+				"max_images_per_product": config.MaxProductImages(),
+				"max_request_bytes":      config.BodyLimit(),
+			},
+			"delivery": config.CurrentDeliveryLimits(),
+			"messages": fiber.Map{
+				"max_length":          config.MaxMessageLength(),
+				"per_minute_per_chat": config.MessagesPerMinute(),
+			},
+		},
+	})
+}
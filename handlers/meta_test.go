@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestGetMetaReflectsConfig(t *testing.T) {
+	t.Setenv("FEATURE_DELIVERY", "false")
+	t.Setenv("MAX_PAGE_SIZE", "50")
+
+	app := fiber.New()
+	app.Get("/meta", NewMetaHandler().GetMeta)
+	resp, err := app.Test(httptest.NewRequest("GET", "/meta", nil), -1)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if cc := resp.Header.Get("Cache-Control"); cc == "" {
+		t.Error("expected a Cache-Control header")
+	}
+
+	var out struct {
+		Data struct {
+			Features   map[string]bool `json:"features"`
+			Pagination map[string]int  `json:"pagination"`
+			Products   struct {
+				Conditions []string `json:"conditions"`
+				Categories []string `json:"categories"`
+			} `json:"products"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if out.Data.Features["delivery"] || !out.Data.Features["ai_features"] {
+		t.Errorf("features = %v, want delivery off and ai_features on", out.Data.Features)
+	}
+	if out.Data.Pagination["max_limit"] != 50 {
+		t.Errorf("max_limit = %d, want 50", out.Data.Pagination["max_limit"])
+	}
+	if len(out.Data.Products.Conditions) == 0 || len(out.Data.Products.Categories) == 0 {
+		t.Errorf("missing product vocabularies: %+v", out.Data.Products)
+	}
+}
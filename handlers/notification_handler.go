@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"database/sql"
+	"strconv"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/xashathebest/clovia/database"
@@ -26,7 +27,7 @@ func (h *NotificationHandler) GetNotifications(c *fiber.Ctx) error {
 		where += " AND type = ?"
 		args = append(args, category)
 	}
-	rows, err := h.db.Query("SELECT id, user_id, type, message, is_read, created_at FROM notifications "+where+" ORDER BY created_at DESC", args...)
+	rows, err := h.db.Query("SELECT id, user_id, type, message, is_read, reference_type, reference_id, created_at FROM notifications "+where+" ORDER BY created_at DESC", args...)
 	if err != nil {
 		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to fetch notifications"})
 	}
@@ -36,9 +37,16 @@ func (h *NotificationHandler) GetNotifications(c *fiber.Ctx) error {
 		var id, uid int
 		var typ, msg string
 		var read bool
+		var refType sql.NullString
+		var refID sql.NullInt64
 		var createdAt string
-		if err := rows.Scan(&id, &uid, &typ, &msg, &read, &createdAt); err == nil {
-			list = append(list, map[string]interface{}{"id": id, "user_id": uid, "type": typ, "message": msg, "read": read, "created_at": createdAt})
+		if err := rows.Scan(&id, &uid, &typ, &msg, &read, &refType, &refID, &createdAt); err == nil {
+			item := map[string]interface{}{"id": id, "user_id": uid, "type": typ, "message": msg, "read": read, "created_at": createdAt, "reference_type": nil, "reference_id": nil}
+			if refType.Valid && refID.Valid {
+				item["reference_type"] = refType.String
+				item["reference_id"] = refID.Int64
+			}
+			list = append(list, item)
 		}
 	}
 	return c.JSON(models.APIResponse{Success: true, Data: list})
@@ -72,3 +80,42 @@ func (h *NotificationHandler) MarkAllAsRead(c *fiber.Ctx) error {
 	}
 	return c.JSON(models.APIResponse{Success: true})
 }
+
+// MarkReadByFilter marks notifications as read by ?type= and/or ?reference_type=&reference_id=,
+// e.g. opening a trade clears only that trade's notifications
+func (h *NotificationHandler) MarkReadByFilter(c *fiber.Ctx) error {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return fiber.ErrUnauthorized
+	}
+	where := "WHERE user_id = ? AND is_read = FALSE"
+	args := []interface{}{userID}
+	if typ := c.Query("type", ""); typ != "" {
+		where += " AND type = ?"
+		args = append(args, typ)
+	}
+	if refType := c.Query("reference_type", ""); refType != "" {
+		if !models.IsValidNotificationReferenceType(refType) {
+			return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid reference_type"})
+		}
+		where += " AND reference_type = ?"
+		args = append(args, refType)
+	}
+	if ref := c.Query("reference_id", ""); ref != "" {
+		refID, err := strconv.Atoi(ref)
+		if err != nil {
+			return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid reference_id"})
+		}
+		where += " AND reference_id = ?"
+		args = append(args, refID)
+	}
+	if len(args) == 1 {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Provide type, reference_type or reference_id; use /read-all to clear everything"})
+	}
+	res, err := h.db.Exec("UPDATE notifications SET is_read = TRUE "+where, args...)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to update notifications"})
+	}
+	n, _ := res.RowsAffected()
+	return c.JSON(models.APIResponse{Success: true, Data: fiber.Map{"updated": n}})
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"database/sql"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/models"
+	"github.com/xashathebest/clovia/services"
+)
+
+// notify stores a notification for userID and pushes it to their open event streams.
+// Preferences and mutes are respected and failures are logged by services.Notify, so
+// callers fire and forget.
+func notify(db *sql.DB, userID int, typ, message, refType string, refID int) {
+	id := services.Notify(db, userID, typ, message, refType, refID)
+	if id == 0 {
+		return
+	}
+	data := fiber.Map{
+		"notification_id": id,
+		"type":            typ,
+		"message":         message,
+		"reference_type":  refType,
+		"reference_id":    refID,
+	}
+	if refType == models.NotificationRefConversation {
+		data["conversation_id"] = refID
+	}
+	publishToUser(userID, sseEvent{Type: "notification", Data: data})
+}
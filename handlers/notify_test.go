@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/xashathebest/clovia/models"
+)
+
+// TestNotifyStoresPublishesAndRespectsMutes sends a trade notification and a message
+// notification for a conversation the recipient muted
+func TestNotifyStoresPublishesAndRespectsMutes(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	sellerID := createTestUser(t, db, "notify_seller")
+	buyerID := createTestUser(t, db, "notify_buyer")
+	productID := createTestProduct(t, db, sellerID, "Notify Item")
+	res, err := db.Exec("INSERT INTO conversations (product_id, buyer_id, seller_id) VALUES (?, ?, ?)", productID, buyerID, sellerID)
+	if err != nil {
+		t.Fatalf("insert conversation: %v", err)
+	}
+	convID64, _ := res.LastInsertId()
+	convID := int(convID64)
+	db.Exec("INSERT INTO conversation_mutes (conversation_id, user_id) VALUES (?, ?)", convID, buyerID)
+	t.Cleanup(func() {
+		db.Exec("DELETE FROM notifications WHERE user_id = ?", buyerID)
+		db.Exec("DELETE FROM conversations WHERE id = ?", convID)
+	})
+
+	ch := make(chan []byte, 4)
+	registerStream(buyerID, ch)
+	defer unregisterStream(buyerID, ch)
+
+	notify(db, buyerID, "trade_update", "Trade completed", models.NotificationRefTrade, 42)
+	notify(db, buyerID, "message", "seller: hi", models.NotificationRefConversation, convID)
+
+	var stored int
+	db.QueryRow("SELECT COUNT(*) FROM notifications WHERE user_id = ?", buyerID).Scan(&stored)
+	if stored != 1 {
+		t.Errorf("stored %d notifications, want 1 (muted conversation skipped)", stored)
+	}
+	if len(ch) != 1 {
+		t.Fatalf("published %d events, want 1", len(ch))
+	}
+	var evt struct {
+		Type string                 `json:"type"`
+		Data map[string]interface{} `json:"data"`
+	}
+	json.Unmarshal(<-ch, &evt)
+	if evt.Type != "notification" || evt.Data["reference_type"] != models.NotificationRefTrade || evt.Data["reference_id"] != float64(42) {
+		t.Errorf("event = %+v, want trade notification for #42", evt)
+	}
+}
@@ -2,12 +2,15 @@ package handlers
 
 import (
 	"database/sql"
+	"fmt"
+	"log"
 	"strconv"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/xashathebest/clovia/database"
 	"github.com/xashathebest/clovia/middleware"
 	"github.com/xashathebest/clovia/models"
+	"github.com/xashathebest/clovia/services"
 )
 
 // OrderHandler handles order-related HTTP requests
@@ -43,8 +46,8 @@ func (h *OrderHandler) CreateOrder(c *fiber.Ctx) error {
 	// Check if product exists and is available
 	var product models.Product
 	err := h.db.QueryRow(`
-		SELECT id, title, price, seller_id, status FROM products WHERE id = ?
-	`, orderData.ProductID).Scan(&product.ID, &product.Title, &product.Price, &product.SellerID, &product.Status)
+		SELECT id, title, price, seller_id, status, allow_buying, barter_only FROM products WHERE id = ?
+	`, orderData.ProductID).Scan(&product.ID, &product.Title, &product.Price, &product.SellerID, &product.Status, &product.AllowBuying, &product.BarterOnly)
 
 	if err != nil {
 		return c.Status(404).JSON(models.APIResponse{
@@ -75,6 +78,26 @@ func (h *OrderHandler) CreateOrder(c *fiber.Ctx) error {
 		})
 	}
 
+	if until, err := sellerAwayUntil(h.db, product.SellerID); err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Failed to check seller availability",
+		})
+	} else if until != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   sellerAwayMessage(*until),
+		})
+	}
+
+	// Trade-only listings cannot be bought outright
+	if !product.CanBuy() {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   models.TradeOnlyMessage,
+		})
+	}
+
 	// Check if user already has a pending order for this product
 	var existingOrderID int
 	err = h.db.QueryRow(`
@@ -88,6 +111,29 @@ func (h *OrderHandler) CreateOrder(c *fiber.Ctx) error {
 		})
 	}
 
+	// An accepted price offer sets the price; otherwise the order is at the list price
+	var agreedPrice *float64
+	if orderData.PriceOfferID != nil {
+		var offerBuyer, offerProduct int
+		var offerStatus string
+		var amount float64
+		err := h.db.QueryRow("SELECT buyer_id, product_id, status, amount FROM price_offers WHERE id = ?", *orderData.PriceOfferID).
+			Scan(&offerBuyer, &offerProduct, &offerStatus, &amount)
+		if err != nil || offerBuyer != userID || offerProduct != orderData.ProductID {
+			return c.Status(404).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Price offer not found",
+			})
+		}
+		if offerStatus != "accepted" {
+			return c.Status(409).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Only an accepted price offer can be ordered",
+			})
+		}
+		agreedPrice = &amount
+	}
+
 	// Start transaction
 	tx, err := h.db.Begin()
 	if err != nil {
@@ -100,8 +146,8 @@ func (h *OrderHandler) CreateOrder(c *fiber.Ctx) error {
 
 	// Create order
 	result, err := tx.Exec(`
-		INSERT INTO orders (product_id, buyer_id, status) VALUES (?, ?, 'pending')
-	`, orderData.ProductID, userID)
+		INSERT INTO orders (product_id, buyer_id, status, agreed_price) VALUES (?, ?, 'pending', ?)
+	`, orderData.ProductID, userID, agreedPrice)
 	if err != nil {
 		return c.Status(500).JSON(models.APIResponse{
 			Success: false,
@@ -111,14 +157,43 @@ func (h *OrderHandler) CreateOrder(c *fiber.Ctx) error {
 
 	orderID, _ := result.LastInsertId()
 
+	if orderData.PriceOfferID != nil {
+		res, err := tx.Exec("UPDATE price_offers SET status = 'ordered', order_id = ? WHERE id = ? AND status = 'accepted'", orderID, *orderData.PriceOfferID)
+		if err != nil {
+			return c.Status(500).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Failed to update price offer",
+			})
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return c.Status(409).JSON(models.APIResponse{
+				Success: false,
+				Error:   "The price offer was withdrawn or already used",
+			})
+		}
+	}
+	// The listing is gone, so every other open offer on it is closed
+	if _, err := tx.Exec("UPDATE price_offers SET status = 'closed' WHERE product_id = ? AND status IN ('pending', 'accepted')", orderData.ProductID); err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Failed to close open price offers",
+		})
+	}
+
 	// Update product status to sold
-	_, err = tx.Exec("UPDATE products SET status = 'sold' WHERE id = ?", orderData.ProductID)
+	_, err = tx.Exec("UPDATE products SET status = 'sold', version = version + 1 WHERE id = ?", orderData.ProductID)
 	if err != nil {
 		return c.Status(500).JSON(models.APIResponse{
 			Success: false,
 			Error:   "Failed to update product status",
 		})
 	}
+	if err := services.RecordProductStatusChange(tx, orderData.ProductID, product.Status, "sold", userID, fmt.Sprintf("order #%d", orderID)); err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Failed to record product status change",
+		})
+	}
 
 	// Commit transaction
 	if err := tx.Commit(); err != nil {
@@ -131,10 +206,10 @@ func (h *OrderHandler) CreateOrder(c *fiber.Ctx) error {
 	// Get the created order with product details
 	var order models.Order
 	err = h.db.QueryRow(`
-		SELECT o.id, o.product_id, o.buyer_id, o.status, o.created_at, o.updated_at
+		SELECT o.id, o.product_id, o.buyer_id, o.status, o.agreed_price, o.created_at, o.updated_at
 		FROM orders o
 		WHERE o.id = ?
-	`, orderID).Scan(&order.ID, &order.ProductID, &order.BuyerID, &order.Status, &order.CreatedAt, &order.UpdatedAt)
+	`, orderID).Scan(&order.ID, &order.ProductID, &order.BuyerID, &order.Status, &order.AgreedPrice, &order.CreatedAt, &order.UpdatedAt)
 
 	if err != nil {
 		return c.Status(500).JSON(models.APIResponse{
@@ -165,9 +240,11 @@ func (h *OrderHandler) GetOrders(c *fiber.Ctx) error {
 
 	// Determine if user wants to see orders they made or received
 	orderType := c.Query("type", "bought") // "bought" or "sold"
-	page, _ := strconv.Atoi(c.Query("page", "1"))
-	limit, _ := strconv.Atoi(c.Query("limit", "10"))
-	offset := (page - 1) * limit
+	pg, err := parsePaginationDefault(c, 10)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: err.Error()})
+	}
+	page, limit, offset := pg.Page, pg.Limit, pg.Offset
 
 	var query string
 	var args []interface{}
@@ -175,7 +252,7 @@ func (h *OrderHandler) GetOrders(c *fiber.Ctx) error {
 	if orderType == "sold" {
 		// Orders for products sold by the user
 		query = `
-			SELECT o.id, o.product_id, o.buyer_id, o.status, o.created_at, o.updated_at
+			SELECT o.id, o.product_id, o.buyer_id, o.status, o.agreed_price, o.created_at, o.updated_at
 			FROM orders o
 			JOIN products p ON o.product_id = p.id
 			WHERE p.seller_id = ?
@@ -184,7 +261,7 @@ func (h *OrderHandler) GetOrders(c *fiber.Ctx) error {
 	} else {
 		// Orders made by the user
 		query = `
-			SELECT o.id, o.product_id, o.buyer_id, o.status, o.created_at, o.updated_at
+			SELECT o.id, o.product_id, o.buyer_id, o.status, o.agreed_price, o.created_at, o.updated_at
 			FROM orders o
 			WHERE o.buyer_id = ?
 		`
@@ -194,7 +271,7 @@ func (h *OrderHandler) GetOrders(c *fiber.Ctx) error {
 	// Get total count
 	countQuery := "SELECT COUNT(*) FROM (" + query + ") as count_table"
 	var total int
-	err := h.db.QueryRow(countQuery, args...).Scan(&total)
+	err = h.db.QueryRow(countQuery, args...).Scan(&total)
 	if err != nil {
 		return c.Status(500).JSON(models.APIResponse{
 			Success: false,
@@ -218,7 +295,7 @@ func (h *OrderHandler) GetOrders(c *fiber.Ctx) error {
 	var orders []models.Order
 	for rows.Next() {
 		var order models.Order
-		err := rows.Scan(&order.ID, &order.ProductID, &order.BuyerID, &order.Status, &order.CreatedAt, &order.UpdatedAt)
+		err := rows.Scan(&order.ID, &order.ProductID, &order.BuyerID, &order.Status, &order.AgreedPrice, &order.CreatedAt, &order.UpdatedAt)
 		if err != nil {
 			continue
 		}
@@ -287,10 +364,10 @@ func (h *OrderHandler) GetOrder(c *fiber.Ctx) error {
 
 	var order models.Order
 	err = h.db.QueryRow(`
-		SELECT o.id, o.product_id, o.buyer_id, o.status, o.created_at, o.updated_at
+		SELECT o.id, o.product_id, o.buyer_id, o.status, o.agreed_price, o.created_at, o.updated_at
 		FROM orders o
 		WHERE o.id = ?
-	`, orderID).Scan(&order.ID, &order.ProductID, &order.BuyerID, &order.Status, &order.CreatedAt, &order.UpdatedAt)
+	`, orderID).Scan(&order.ID, &order.ProductID, &order.BuyerID, &order.Status, &order.AgreedPrice, &order.CreatedAt, &order.UpdatedAt)
 
 	if err != nil {
 		return c.Status(404).JSON(models.APIResponse{
@@ -345,6 +422,81 @@ func (h *OrderHandler) GetOrder(c *fiber.Ctx) error {
 	})
 }
 
+// GetOrderSummary returns the user's order counts by status as buyer and seller,
+// plus total earned and spent from recorded transactions
+func (h *OrderHandler) GetOrderSummary(c *fiber.Ctx) error {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{
+			Success: false,
+			Error:   "User not authenticated",
+		})
+	}
+
+	summary := models.OrderSummary{}
+
+	rows, err := h.db.Query(`
+		SELECT
+			CASE WHEN o.buyer_id = ? THEN 'buyer' ELSE 'seller' END AS side,
+			o.status,
+			COUNT(*)
+		FROM orders o
+		JOIN products p ON o.product_id = p.id
+		WHERE o.buyer_id = ? OR p.seller_id = ?
+		GROUP BY side, o.status
+	`, userID, userID, userID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Failed to fetch order counts",
+		})
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var side, status string
+		var count int
+		if err := rows.Scan(&side, &status, &count); err != nil {
+			continue
+		}
+		counts := &summary.AsSeller
+		if side == "buyer" {
+			counts = &summary.AsBuyer
+		}
+		switch status {
+		case "pending":
+			counts.Pending = count
+		case "completed":
+			counts.Completed = count
+		case "cancelled":
+			counts.Cancelled = count
+		}
+		counts.Total += count
+	}
+
+	// Sellers earn what is left after the platform fee; buyers spent the full amount
+	err = h.db.QueryRow(`
+		SELECT
+			COALESCE(SUM(CASE WHEN p.seller_id = ? THEN COALESCE(t.net_amount, t.amount) ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN o.buyer_id = ? THEN t.amount ELSE 0 END), 0)
+		FROM transactions t
+		JOIN orders o ON t.order_id = o.id
+		JOIN products p ON o.product_id = p.id
+		WHERE o.buyer_id = ? OR p.seller_id = ?
+	`, userID, userID, userID, userID).Scan(&summary.TotalEarned, &summary.TotalSpent)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Failed to fetch order totals",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    summary,
+	})
+}
+
 // UpdateOrderStatus updates the status of an order
 func (h *OrderHandler) UpdateOrderStatus(c *fiber.Ctx) error {
 	userID, ok := middleware.GetUserIDFromContext(c)
@@ -405,17 +557,39 @@ func (h *OrderHandler) UpdateOrderStatus(c *fiber.Ctx) error {
 		})
 	}
 
+	// A cancelled pending order hands its product back to the market, unless another
+	// order still holds it
+	if updateData.Status != nil && *updateData.Status == "cancelled" && order.Status == "pending" {
+		res, err := h.db.Exec(`
+			UPDATE products SET status = 'available', version = version + 1
+			WHERE id = ? AND status = 'sold'
+			  AND NOT EXISTS (SELECT 1 FROM orders WHERE product_id = ? AND status IN ('pending', 'completed'))`,
+			order.ProductID, order.ProductID)
+		if err == nil {
+			if n, _ := res.RowsAffected(); n > 0 {
+				if err := services.RecordProductStatusChange(h.db, order.ProductID, "sold", "available", userID, fmt.Sprintf("order #%d cancelled", orderID)); err != nil {
+					log.Printf("Warning: failed to record status history for product %d: %v", order.ProductID, err)
+				}
+				alertBackInStock(h.db, []int{order.ProductID})
+			}
+		}
+	}
+
 	// If order is completed, create transaction record
 	if updateData.Status != nil && *updateData.Status == "completed" {
-		// Get product price
+		// Charge the price agreed through an offer, else the list price
 		var price float64
-		err = h.db.QueryRow("SELECT price FROM products WHERE id = ?", order.ProductID).Scan(&price)
+		err = h.db.QueryRow(`
+			SELECT COALESCE(o.agreed_price, p.price) FROM orders o JOIN products p ON p.id = o.product_id WHERE o.id = ?
+		`, orderID).Scan(&price)
 		if err == nil {
-			// Create transaction record
-			_, err = h.db.Exec(`
-				INSERT INTO transactions (order_id, amount) VALUES (?, ?)
-			`, orderID, price)
+			// Create transaction record with the platform fee taken from the seller's proceeds
+			quote, err := services.ComputeFee(price, services.FeeKindOrder)
 			if err != nil {
+				log.Printf("Warning: failed to compute fee for order %d: %v", orderID, err)
+			} else if _, err = h.db.Exec(`
+				INSERT INTO transactions (order_id, amount, fee, net_amount) VALUES (?, ?, ?, ?)
+			`, orderID, price, quote.Fee, quote.NetAmount); err != nil {
 				// Log error but don't fail the request
 				// In production, you might want to handle this differently
 			}
@@ -427,3 +601,72 @@ func (h *OrderHandler) UpdateOrderStatus(c *fiber.Ctx) error {
 		Message: "Order status updated successfully",
 	})
 }
+
+// OpenOrderConversation ensures a chat between the order's buyer and the product's seller
+// so they can arrange a handoff. The first call posts a note about the order into the chat.
+func (h *OrderHandler) OpenOrderConversation(c *fiber.Ctx) error {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "User not authenticated"})
+	}
+
+	orderID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid order ID"})
+	}
+
+	var productID, buyerID, sellerID int
+	var title, status string
+	err = h.db.QueryRow(`
+		SELECT o.product_id, o.buyer_id, o.status, p.seller_id, p.title
+		FROM orders o
+		JOIN products p ON p.id = o.product_id
+		WHERE o.id = ?
+	`, orderID).Scan(&productID, &buyerID, &status, &sellerID, &title)
+	if err == sql.ErrNoRows {
+		return c.Status(404).JSON(models.APIResponse{Success: false, Error: "Order not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to fetch order"})
+	}
+	if userID != buyerID && userID != sellerID {
+		return c.Status(403).JSON(models.APIResponse{Success: false, Error: "Access denied"})
+	}
+
+	convID, err := ensureConversation(productID, buyerID, sellerID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to open conversation"})
+	}
+
+	// Post the order note once per conversation
+	note := fmt.Sprintf("Order #%d placed for %s. Use this chat to arrange the handoff.", orderID, title)
+	var noted bool
+	_ = h.db.QueryRow("SELECT EXISTS(SELECT 1 FROM messages WHERE conversation_id = ? AND content = ?)", convID, note).Scan(&noted)
+	if !noted {
+		msgID, createdAt, err := saveMessage(convID, userID, note)
+		if err == nil {
+			evt := sseEvent{Type: "message", Data: fiber.Map{
+				"id":              msgID,
+				"conversation_id": convID,
+				"sender_id":       userID,
+				"content":         note,
+				"created_at":      createdAt,
+			}}
+			for _, pid := range []int{buyerID, sellerID} {
+				publishToUser(pid, evt)
+			}
+		}
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data: fiber.Map{
+			"conversation_id": convID,
+			"order_id":        orderID,
+			"order_status":    status,
+			"product_id":      productID,
+			"buyer_id":        buyerID,
+			"seller_id":       sellerID,
+		},
+	})
+}
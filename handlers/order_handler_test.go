@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/xashathebest/clovia/database"
+	"github.com/xashathebest/clovia/models"
+)
+
+// TestCreateOrderRejectsBarterOnlyProduct tries to buy a listing marked trade-only
+func TestCreateOrderRejectsBarterOnlyProduct(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	database.DB = db
+
+	buyerID := createTestUser(t, db, "buyer")
+	sellerID := createTestUser(t, db, "seller")
+	productID := createTestProduct(t, db, sellerID, "Barter Only Item")
+	defer db.Exec("DELETE FROM products WHERE id = ?", productID)
+	if _, err := db.Exec("UPDATE products SET barter_only = TRUE WHERE id = ?", productID); err != nil {
+		t.Fatalf("Failed to mark product barter-only: %v", err)
+	}
+
+	handler := &OrderHandler{db: db}
+	currentUser := buyerID
+	app := newTestApp(&currentUser, func(app *fiber.App) {
+		app.Post("/orders", handler.CreateOrder)
+	})
+
+	req := httptest.NewRequest("POST", "/orders", strings.NewReader(fmt.Sprintf(`{"product_id": %d}`, productID)))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	var out models.APIResponse
+	_ = json.NewDecoder(resp.Body).Decode(&out)
+
+	if resp.StatusCode != 400 {
+		t.Fatalf("Expected barter-only order to be rejected with 400, got %d", resp.StatusCode)
+	}
+	if !strings.Contains(out.Error, "Propose a trade") {
+		t.Errorf("Expected error to point to trading, got %q", out.Error)
+	}
+
+	var orders int
+	if err := db.QueryRow("SELECT COUNT(*) FROM orders WHERE product_id = ?", productID).Scan(&orders); err != nil {
+		t.Fatalf("Failed to count orders: %v", err)
+	}
+	if orders != 0 {
+		t.Errorf("Expected no order to be created, found %d", orders)
+	}
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/config"
+)
+
+// pagination is the effective page window for a list request
+type pagination struct {
+	Page   int
+	Limit  int
+	Offset int
+}
+
+// parsePagination reads ?page=, ?limit= and ?offset= from the request.
+// Limit falls back to DEFAULT_PAGE_SIZE and is clamped to [1, MAX_PAGE_SIZE].
+// A valid ?offset= takes precedence over ?page=. An explicit ?page= that is not a
+// positive integer, or is too large to turn into an offset, is reported as an error.
+func parsePagination(c *fiber.Ctx) (pagination, error) {
+	return parsePaginationDefault(c, config.DefaultPageSize())
+}
+
+// parsePaginationDefault is parsePagination for lists that keep their own default page
+// size instead of DEFAULT_PAGE_SIZE
+func parsePaginationDefault(c *fiber.Ctx, defaultLimit int) (pagination, error) {
+	pg := normalizePagination(c.Query("page"), c.Query("limit"), c.Query("offset"), defaultLimit, config.MaxPageSize())
+	if err := validatePage(c.Query("page"), pg.Limit); err != nil {
+		return pg, err
+	}
+	return pg, nil
+}
+
+var (
+	errInvalidPage  = errors.New("page must be a positive integer")
+	errPageTooLarge = errors.New("page is too large")
+)
+
+// validatePage rejects an explicit page that is non-numeric, below 1, or whose
+// offset would overflow at the given limit. An empty page is always valid.
+func validatePage(pageStr string, limit int) error {
+	if pageStr == "" {
+		return nil
+	}
+	page, err := strconv.Atoi(pageStr)
+	if err != nil {
+		var numErr *strconv.NumError
+		if errors.As(err, &numErr) && errors.Is(numErr.Err, strconv.ErrRange) && pageStr[0] != '-' {
+			return errPageTooLarge
+		}
+		return errInvalidPage
+	}
+	if page < 1 {
+		return errInvalidPage
+	}
+	if limit > 0 && page-1 > math.MaxInt32/limit {
+		return errPageTooLarge
+	}
+	return nil
+}
+
+// normalizePagination applies the defaults and caps used by parsePagination
+func normalizePagination(pageStr, limitStr, offsetStr string, defaultLimit, maxLimit int) pagination {
+	if maxLimit < 1 {
+		maxLimit = 1
+	}
+	if defaultLimit < 1 || defaultLimit > maxLimit {
+		defaultLimit = maxLimit
+	}
+
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 1 {
+		limit = defaultLimit
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+
+	page, err := strconv.Atoi(pageStr)
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	if offset, err := strconv.Atoi(offsetStr); err == nil && offset >= 0 {
+		return pagination{Page: offset/limit + 1, Limit: limit, Offset: offset}
+	}
+	offset := (page - 1) * limit
+	if offset < 0 {
+		offset = 0
+	}
+	return pagination{Page: page, Limit: limit, Offset: offset}
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import "testing"
+
+func TestNormalizePagination(t *testing.T) {
+	cases := []struct {
+		name                            string
+		page, limit, offset             string
+		wantPage, wantLimit, wantOffset int
+	}{
+		{"defaults", "", "", "", 1, 20, 0},
+		{"explicit page", "3", "10", "", 3, 10, 20},
+		{"limit clamped to max", "1", "1000000", "", 1, 100, 0},
+		{"zero limit uses default", "1", "0", "", 1, 20, 0},
+		{"negative page", "-2", "10", "", 1, 10, 0},
+		{"garbage values", "abc", "xyz", "", 1, 20, 0},
+		{"offset wins over page", "5", "10", "25", 3, 10, 25},
+		{"negative offset ignored", "2", "10", "-1", 2, 10, 10},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := normalizePagination(tc.page, tc.limit, tc.offset, 20, 100)
+			if got.Page != tc.wantPage || got.Limit != tc.wantLimit || got.Offset != tc.wantOffset {
+				t.Errorf("got page=%d limit=%d offset=%d, want page=%d limit=%d offset=%d",
+					got.Page, got.Limit, got.Offset, tc.wantPage, tc.wantLimit, tc.wantOffset)
+			}
+		})
+	}
+}
+
+func TestValidatePage(t *testing.T) {
+	cases := []struct {
+		name string
+		page string
+		want error
+	}{
+		{"empty", "", nil},
+		{"first page", "1", nil},
+		{"zero", "0", errInvalidPage},
+		{"negative", "-1", errInvalidPage},
+		{"garbage", "abc", errInvalidPage},
+		{"fraction", "1.5", errInvalidPage},
+		{"offset overflow", "999999999", errPageTooLarge},
+		{"int overflow", "99999999999999999999", errPageTooLarge},
+		{"negative int overflow", "-99999999999999999999", errInvalidPage},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := validatePage(tc.page, 20); got != tc.want {
+				t.Errorf("validatePage(%q) = %v, want %v", tc.page, got, tc.want)
+			}
+		})
+	}
+}
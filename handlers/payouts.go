@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/config"
+	"github.com/xashathebest/clovia/middleware"
+	"github.com/xashathebest/clovia/models"
+	"github.com/xashathebest/clovia/services"
+)
+
+// payoutActionTransitions maps each admin payout action to the status it starts from and
+// the status it leaves the payout in
+var payoutActionTransitions = map[string]struct{ From, To string }{
+	"approve":   {"pending", "approved"},
+	"reject":    {"pending", "rejected"},
+	"mark_paid": {"approved", "paid"},
+}
+
+// payoutColumns is the SELECT list scanPayout expects, over payouts p joined to users u
+const payoutColumns = `p.id, p.user_id, u.name, p.amount, p.status, p.note, p.admin_note, p.reviewed_by,
+	p.created_at, p.approved_at, p.paid_at`
+
+func scanPayout(rows *sql.Rows) (models.Payout, error) {
+	var p models.Payout
+	var note, adminNote sql.NullString
+	var reviewedBy sql.NullInt64
+	var approvedAt, paidAt sql.NullTime
+	if err := rows.Scan(&p.ID, &p.UserID, &p.UserName, &p.Amount, &p.Status, &note, &adminNote, &reviewedBy,
+		&p.CreatedAt, &approvedAt, &paidAt); err != nil {
+		return p, err
+	}
+	p.Note = note.String
+	p.AdminNote = adminNote.String
+	if reviewedBy.Valid {
+		id := int(reviewedBy.Int64)
+		p.ReviewedBy = &id
+	}
+	if approvedAt.Valid {
+		p.ApprovedAt = &approvedAt.Time
+	}
+	if paidAt.Valid {
+		p.PaidAt = &paidAt.Time
+	}
+	return p, nil
+}
+
+// RequestPayout asks for a withdrawal of the authenticated seller's earnings.
+// Body: { "amount": 500, "note": "GCash 0917..." }
+func (h *UserHandler) RequestPayout(c *fiber.Ctx) error {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "User not authenticated"})
+	}
+	var payload struct {
+		Amount float64 `json:"amount"`
+		Note   string  `json:"note"`
+	}
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid request body"})
+	}
+	amount := math.Round(payload.Amount*100) / 100
+	if amount <= 0 {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Amount must be greater than zero"})
+	}
+	if min := config.MinPayoutAmount(); amount < min {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: fmt.Sprintf("The minimum payout is %.2f %s", min, config.DefaultCurrency)})
+	}
+	payload.Note = strings.TrimSpace(payload.Note)
+	payload.Note = services.TruncateRunes(payload.Note, 255)
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to request payout"})
+	}
+	defer tx.Rollback()
+
+	// Locking the user row serialises concurrent requests so two of them cannot both
+	// spend the same balance
+	var locked int
+	if err := tx.QueryRow("SELECT id FROM users WHERE id = ? FOR UPDATE", userID).Scan(&locked); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to request payout"})
+	}
+	balance, err := services.SellerPayoutBalance(tx, userID)
+	if err != nil {
+		log.Printf("Failed to compute payout balance for user %d: %v", userID, err)
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to compute available balance"})
+	}
+	if amount > balance.Available {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Requested %.2f but only %.2f is available for payout", amount, balance.Available),
+			Data:    balance,
+		})
+	}
+
+	res, err := tx.Exec("INSERT INTO payouts (user_id, amount, note) VALUES (?, ?, ?)", userID, amount, nullableString(&payload.Note))
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to request payout"})
+	}
+	if err := tx.Commit(); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to request payout"})
+	}
+	id, _ := res.LastInsertId()
+	balance.Requested += amount
+	balance.Available = math.Round((balance.Available-amount)*100) / 100
+
+	return c.Status(201).JSON(models.APIResponse{
+		Success: true,
+		Message: "Payout requested",
+		Data:    fiber.Map{"payout_id": id, "amount": amount, "status": "pending", "balance": balance},
+	})
+}
+
+// GetMyPayouts returns the authenticated seller's payout ledger, newest first, with their balance
+func (h *UserHandler) GetMyPayouts(c *fiber.Ctx) error {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "User not authenticated"})
+	}
+	pg, err := parsePagination(c)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: err.Error()})
+	}
+
+	balance, err := services.SellerPayoutBalance(h.db, userID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to compute available balance"})
+	}
+	var total int
+	if err := h.db.QueryRow("SELECT COUNT(*) FROM payouts WHERE user_id = ?", userID).Scan(&total); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to count payouts"})
+	}
+	rows, err := h.db.Query(
+		"SELECT "+payoutColumns+" FROM payouts p JOIN users u ON u.id = p.user_id WHERE p.user_id = ? ORDER BY p.created_at DESC, p.id DESC LIMIT ? OFFSET ?",
+		userID, pg.Limit, pg.Offset,
+	)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to fetch payouts"})
+	}
+	defer rows.Close()
+	payouts := []models.Payout{}
+	for rows.Next() {
+		p, err := scanPayout(rows)
+		if err != nil {
+			return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to read payouts"})
+		}
+		payouts = append(payouts, p)
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data: fiber.Map{
+			"balance": balance,
+			"payouts": models.PaginatedResponse{
+				Data:       payouts,
+				Total:      total,
+				Page:       pg.Page,
+				Limit:      pg.Limit,
+				TotalPages: (total + pg.Limit - 1) / pg.Limit,
+			},
+		},
+	})
+}
+
+// ListPayouts lists payout requests for review, oldest first so the queue is worked in order.
+// Optional ?status=pending|approved|paid|rejected
+func (h *AdminHandler) ListPayouts(c *fiber.Ctx) error {
+	pg, err := parsePagination(c)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: err.Error()})
+	}
+	where := "1=1"
+	var args []interface{}
+	if status := c.Query("status"); status != "" {
+		switch status {
+		case "pending", "approved", "paid", "rejected":
+		default:
+			return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid status filter"})
+		}
+		where = "p.status = ?"
+		args = append(args, status)
+	}
+
+	var total int
+	if err := h.db.QueryRow("SELECT COUNT(*) FROM payouts p WHERE "+where, args...).Scan(&total); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to count payouts"})
+	}
+	rows, err := h.db.Query(
+		"SELECT "+payoutColumns+" FROM payouts p JOIN users u ON u.id = p.user_id WHERE "+where+" ORDER BY p.created_at ASC, p.id ASC LIMIT ? OFFSET ?",
+		append(args, pg.Limit, pg.Offset)...,
+	)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to fetch payouts"})
+	}
+	defer rows.Close()
+	payouts := []models.Payout{}
+	for rows.Next() {
+		p, err := scanPayout(rows)
+		if err != nil {
+			return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to read payouts"})
+		}
+		payouts = append(payouts, p)
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data: models.PaginatedResponse{
+			Data:       payouts,
+			Total:      total,
+			Page:       pg.Page,
+			Limit:      pg.Limit,
+			TotalPages: (total + pg.Limit - 1) / pg.Limit,
+		},
+	})
+}
+
+// UpdatePayout moves a payout through review: approve or reject a pending request, then
+// mark_paid once the money has been sent. Body: { "action": "approve", "note": "..." }
+func (h *AdminHandler) UpdatePayout(c *fiber.Ctx) error {
+	adminID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "User not authenticated"})
+	}
+	payoutID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid payout ID"})
+	}
+	var payload struct {
+		Action string `json:"action"`
+		Note   string `json:"note"`
+	}
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid request body"})
+	}
+	transition, ok := payoutActionTransitions[payload.Action]
+	if !ok {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Action must be approve, reject or mark_paid"})
+	}
+	payload.Note = strings.TrimSpace(payload.Note)
+	payload.Note = services.TruncateRunes(payload.Note, 255)
+	if payload.Action == "reject" && payload.Note == "" {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "A note is required to reject a payout"})
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to update payout"})
+	}
+	defer tx.Rollback()
+
+	var sellerID int
+	var amount float64
+	var status string
+	err = tx.QueryRow("SELECT user_id, amount, status FROM payouts WHERE id = ? FOR UPDATE", payoutID).Scan(&sellerID, &amount, &status)
+	if err == sql.ErrNoRows {
+		return c.Status(404).JSON(models.APIResponse{Success: false, Error: "Payout not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to fetch payout"})
+	}
+	if status != transition.From {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Payout is already %s, so %q is no longer possible", status, payload.Action),
+		})
+	}
+
+	query := "UPDATE payouts SET status = ?, reviewed_by = ?, admin_note = COALESCE(?, admin_note)"
+	switch transition.To {
+	case "approved":
+		query += ", approved_at = CURRENT_TIMESTAMP"
+	case "paid":
+		query += ", paid_at = CURRENT_TIMESTAMP"
+	}
+	if _, err := tx.Exec(query+" WHERE id = ?", transition.To, adminID, nullableString(&payload.Note), payoutID); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to update payout"})
+	}
+	details := fiber.Map{"payout_id": payoutID, "amount": amount, "from_status": status, "to_status": transition.To, "note": payload.Note}
+	if err := recordAdminAction(tx, adminID, "payout_"+payload.Action, sellerID, details, c.IP()); err != nil {
+		log.Printf("Failed to audit payout %d %s by admin %d: %v", payoutID, payload.Action, adminID, err)
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to record payout review"})
+	}
+	if err := tx.Commit(); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to update payout"})
+	}
+
+	message := fmt.Sprintf("Your payout request of %.2f %s was %s", amount, config.DefaultCurrency, transition.To)
+	if payload.Action == "reject" {
+		message += ": " + payload.Note
+	}
+	notify(h.db, sellerID, "payout_update", message, models.NotificationRefPayout, payoutID)
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Payout " + transition.To,
+		Data:    fiber.Map{"payout_id": payoutID, "status": transition.To},
+	})
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/xashathebest/clovia/database"
+)
+
+// TestPayoutRequestsStayWithinBalance requests more than a seller earned, then walks a valid
+// request through approval and payment
+func TestPayoutRequestsStayWithinBalance(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	database.DB = db
+	t.Setenv("PAYOUT_MIN_AMOUNT", "100")
+
+	adminID := createTestUser(t, db, "payout_admin")
+	db.Exec("UPDATE users SET role = 'admin' WHERE id = ?", adminID)
+	sellerID := createTestUser(t, db, "payout_seller")
+	buyerID := createTestUser(t, db, "payout_buyer")
+	target := createTestProduct(t, db, sellerID, "Sold For Cash")
+	res, err := db.Exec("INSERT INTO trades (buyer_id, seller_id, target_product_id, status, net_amount) VALUES (?, ?, ?, 'completed', 1000)", buyerID, sellerID, target)
+	if err != nil {
+		t.Fatalf("insert trade: %v", err)
+	}
+	tradeID, _ := res.LastInsertId()
+	t.Cleanup(func() {
+		db.Exec("DELETE FROM payouts WHERE user_id = ?", sellerID)
+		db.Exec("DELETE FROM trades WHERE id = ?", tradeID)
+	})
+
+	users := &UserHandler{db: db}
+	admin := &AdminHandler{db: db}
+	currentUser := sellerID
+	app := newTestApp(&currentUser, func(app *fiber.App) {
+		app.Post("/users/me/payouts", users.RequestPayout)
+		app.Put("/admin/payouts/:id", admin.UpdatePayout)
+	})
+	do := func(asUser int, method, path, body string) int {
+		currentUser = asUser
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		return resp.StatusCode
+	}
+
+	if got := do(sellerID, "POST", "/users/me/payouts", `{"amount":50}`); got != 400 {
+		t.Errorf("below minimum: expected 400, got %d", got)
+	}
+	if got := do(sellerID, "POST", "/users/me/payouts", `{"amount":1500}`); got != 400 {
+		t.Errorf("over balance: expected 400, got %d", got)
+	}
+	if got := do(sellerID, "POST", "/users/me/payouts", `{"amount":600}`); got != 201 {
+		t.Fatalf("valid request: expected 201, got %d", got)
+	}
+	if got := do(sellerID, "POST", "/users/me/payouts", `{"amount":600}`); got != 400 {
+		t.Errorf("request against already requested balance: expected 400, got %d", got)
+	}
+
+	var payoutID int
+	db.QueryRow("SELECT id FROM payouts WHERE user_id = ?", sellerID).Scan(&payoutID)
+	path := fmt.Sprintf("/admin/payouts/%d", payoutID)
+	if got := do(adminID, "PUT", path, `{"action":"mark_paid"}`); got != 409 {
+		t.Errorf("mark_paid before approval: expected 409, got %d", got)
+	}
+	if got := do(adminID, "PUT", path, `{"action":"approve"}`); got != 200 {
+		t.Fatalf("approve: expected 200, got %d", got)
+	}
+	if got := do(adminID, "PUT", path, `{"action":"mark_paid"}`); got != 200 {
+		t.Fatalf("mark_paid: expected 200, got %d", got)
+	}
+	var status string
+	db.QueryRow("SELECT status FROM payouts WHERE id = ?", payoutID).Scan(&status)
+	if status != "paid" {
+		t.Errorf("payout status = %q, want paid", status)
+	}
+	if got := do(sellerID, "POST", "/users/me/payouts", `{"amount":400}`); got != 201 {
+		t.Errorf("request for the remaining balance: expected 201, got %d", got)
+	}
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/config"
+	"github.com/xashathebest/clovia/middleware"
+	"github.com/xashathebest/clovia/models"
+	"github.com/xashathebest/clovia/services"
+)
+
+// premiumDurationAllowed reports whether days is one of the configured premium windows
+func premiumDurationAllowed(days int) bool {
+	for _, d := range config.PremiumDurationDays() {
+		if d == days {
+			return true
+		}
+	}
+	return false
+}
+
+// premiumPrice is what a window of the given length costs, rounded to centavos
+func premiumPrice(days int) float64 {
+	return math.Round(float64(days)*config.PremiumPricePerDay()*100) / 100
+}
+
+// PurchasePremium buys premium placement on the caller's available listing for one of the
+// configured durations. The price is charged to the seller's payout balance and recorded in
+// seller_charges; a seller who cannot cover it gets 402 and no placement. Buying while a
+// window is running extends it: the new window starts where the last one ends. The listing
+// is flagged premium straight away and services.ExpirePremiumListings clears the flag once
+// every window has ended.
+func (h *ProductHandler) PurchasePremium(c *fiber.Ctx) error {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "User not authenticated"})
+	}
+	productID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid product ID"})
+	}
+	var req models.PremiumPurchase
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid request body"})
+	}
+	if !premiumDurationAllowed(req.Days) {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: fmt.Sprintf("days must be one of %v", config.PremiumDurationDays())})
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to start transaction"})
+	}
+	defer tx.Rollback()
+
+	var sellerID int
+	var status string
+	err = tx.QueryRow("SELECT seller_id, status FROM products WHERE id = ? FOR UPDATE", productID).Scan(&sellerID, &status)
+	if err == sql.ErrNoRows {
+		return c.Status(404).JSON(models.APIResponse{Success: false, Error: "Product not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to load product"})
+	}
+	if sellerID != userID {
+		return c.Status(403).JSON(models.APIResponse{Success: false, Error: "You can only buy premium placement for your own listings"})
+	}
+	if status != "available" {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Only available listings can be made premium"})
+	}
+
+	// The product row lock serializes purchases, so the latest end cannot move under us
+	var start time.Time
+	err = tx.QueryRow(`
+		SELECT GREATEST(NOW(), COALESCE(MAX(end_date), NOW()))
+		FROM premium_listings WHERE product_id = ? AND end_date > NOW()`, productID).Scan(&start)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to check premium placement"})
+	}
+	listing := models.PremiumListing{
+		ProductID: productID,
+		StartDate: start,
+		EndDate:   start.AddDate(0, 0, req.Days),
+		Amount:    premiumPrice(req.Days),
+		Currency:  config.DefaultCurrency,
+	}
+
+	// Locking the user row serialises this with payout requests spending the same balance
+	var chargeID sql.NullInt64
+	if listing.Amount > 0 {
+		var locked int
+		if err := tx.QueryRow("SELECT id FROM users WHERE id = ? FOR UPDATE", userID).Scan(&locked); err != nil {
+			return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to load your balance"})
+		}
+		balance, err := services.SellerPayoutBalance(tx, userID)
+		if err != nil {
+			log.Printf("Failed to compute balance for premium purchase by user %d: %v", userID, err)
+			return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to compute available balance"})
+		}
+		if listing.Amount > balance.Available {
+			return c.Status(402).JSON(models.APIResponse{
+				Success: false,
+				Error:   fmt.Sprintf("Premium placement costs %.2f %s but only %.2f is available in your earnings", listing.Amount, config.DefaultCurrency, balance.Available),
+				Data:    balance,
+			})
+		}
+		res, err := tx.Exec("INSERT INTO seller_charges (user_id, kind, amount, product_id) VALUES (?, 'premium', ?, ?)", userID, listing.Amount, productID)
+		if err != nil {
+			return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to charge premium placement"})
+		}
+		id, _ := res.LastInsertId()
+		chargeID = sql.NullInt64{Int64: id, Valid: true}
+	}
+
+	res, err := tx.Exec("INSERT INTO premium_listings (product_id, start_date, end_date, amount, charge_id) VALUES (?, ?, ?, ?, ?)",
+		productID, listing.StartDate, listing.EndDate, listing.Amount, chargeID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to record premium placement"})
+	}
+	id, _ := res.LastInsertId()
+	listing.ID = int(id)
+	if _, err := tx.Exec("UPDATE products SET premium = TRUE, version = version + 1 WHERE id = ?", productID); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to update product"})
+	}
+	if err := tx.Commit(); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to record premium placement"})
+	}
+	listing.CreatedAt = time.Now()
+
+	return c.Status(201).JSON(models.APIResponse{
+		Success: true,
+		Message: fmt.Sprintf("Premium placement bought for %d days", req.Days),
+		Data:    listing,
+	})
+}
+
+// attachPremiumWindow fills in when the product's premium placement ends, if it is running
+func (h *ProductHandler) attachPremiumWindow(product *models.Product) {
+	var until sql.NullTime
+	var remaining sql.NullInt64
+	err := h.db.QueryRow(`
+		SELECT MAX(end_date), TIMESTAMPDIFF(SECOND, NOW(), MAX(end_date))
+		FROM premium_listings WHERE product_id = ? AND end_date > NOW()`, product.ID).Scan(&until, &remaining)
+	if err != nil {
+		log.Printf("Warning: failed to load premium window for product %d: %v", product.ID, err)
+		return
+	}
+	if until.Valid && remaining.Valid {
+		t := until.Time
+		product.PremiumUntil = &t
+		product.PremiumSecondsRemaining = remaining.Int64
+	}
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/models"
+	"github.com/xashathebest/clovia/services"
+)
+
+func TestPremiumPricing(t *testing.T) {
+	t.Setenv("PREMIUM_DURATION_DAYS", "3, 10,x,-1")
+	t.Setenv("PREMIUM_PRICE_PER_DAY", "12.5")
+	if !premiumDurationAllowed(3) || !premiumDurationAllowed(10) {
+		t.Error("configured durations should be allowed")
+	}
+	if premiumDurationAllowed(7) || premiumDurationAllowed(-1) {
+		t.Error("unconfigured and negative durations should be refused")
+	}
+	if got := premiumPrice(3); got != 37.5 {
+		t.Errorf("premiumPrice(3) = %v, want 37.5", got)
+	}
+}
+
+// TestPurchasePremiumExtendsAndExpires buys two windows back to back, then ends them and
+// checks the expiry pass takes the flag off
+func TestPurchasePremiumExtendsAndExpires(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	t.Setenv("PREMIUM_DURATION_DAYS", "7")
+	// Free placement, so the windows are not limited by the seller's earnings
+	t.Setenv("PREMIUM_PRICE_PER_DAY", "0")
+
+	seller := createTestUser(t, db, "Premium Seller")
+	product := createTestProduct(t, db, seller, "Premium Lamp")
+	t.Cleanup(func() { db.Exec("DELETE FROM products WHERE id = ?", product) })
+
+	h := &ProductHandler{db: db}
+	app := newTestApp(&seller, func(app *fiber.App) { app.Post("/products/:id/premium", h.PurchasePremium) })
+	buy := func(days string) (int, models.PremiumListing) {
+		req := httptest.NewRequest("POST", "/products/"+strconv.Itoa(product)+"/premium", strings.NewReader(`{"days":`+days+`}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		var body struct {
+			Data models.PremiumListing `json:"data"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode, body.Data
+	}
+
+	if got, _ := buy("30"); got != 400 {
+		t.Errorf("unconfigured duration: status %d, want 400", got)
+	}
+	status, first := buy("7")
+	if status != 201 {
+		t.Fatalf("first purchase: status %d, want 201", status)
+	}
+	status, second := buy("7")
+	if status != 201 {
+		t.Fatalf("second purchase: status %d, want 201", status)
+	}
+	if !second.StartDate.Equal(first.EndDate) {
+		t.Errorf("second window starts %v, want the first's end %v", second.StartDate, first.EndDate)
+	}
+
+	var premium bool
+	db.QueryRow("SELECT premium FROM products WHERE id = ?", product).Scan(&premium)
+	if !premium {
+		t.Fatal("product not flagged premium after purchase")
+	}
+	if _, err := db.Exec("UPDATE premium_listings SET start_date = NOW() - INTERVAL 2 DAY, end_date = NOW() - INTERVAL 1 DAY WHERE product_id = ?", product); err != nil {
+		t.Fatalf("end windows: %v", err)
+	}
+	if _, err := services.ExpirePremiumListings(db); err != nil {
+		t.Fatalf("expire: %v", err)
+	}
+	db.QueryRow("SELECT premium FROM products WHERE id = ?", product).Scan(&premium)
+	if premium {
+		t.Error("product still premium after its windows ended")
+	}
+}
+
+// TestPurchasePremiumChargesEarnings checks that a seller without earnings to cover the
+// price is refused, and that a paid purchase is recorded as a charge against the balance
+func TestPurchasePremiumChargesEarnings(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	t.Setenv("PREMIUM_DURATION_DAYS", "7")
+	t.Setenv("PREMIUM_PRICE_PER_DAY", "10")
+
+	seller := createTestUser(t, db, "Premium Payer")
+	buyer := createTestUser(t, db, "Premium Payer Buyer")
+	product := createTestProduct(t, db, seller, "Paid Premium Lamp")
+	t.Cleanup(func() {
+		db.Exec("DELETE FROM seller_charges WHERE user_id = ?", seller)
+		db.Exec("DELETE FROM products WHERE id = ?", product)
+	})
+
+	h := &ProductHandler{db: db}
+	app := newTestApp(&seller, func(app *fiber.App) { app.Post("/products/:id/premium", h.PurchasePremium) })
+	buy := func() int {
+		req := httptest.NewRequest("POST", "/products/"+strconv.Itoa(product)+"/premium", strings.NewReader(`{"days":7}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		return resp.StatusCode
+	}
+
+	if got := buy(); got != 402 {
+		t.Fatalf("purchase without earnings: status %d, want 402", got)
+	}
+	var premium bool
+	var windows int
+	db.QueryRow("SELECT premium FROM products WHERE id = ?", product).Scan(&premium)
+	db.QueryRow("SELECT COUNT(*) FROM premium_listings WHERE product_id = ?", product).Scan(&windows)
+	if premium || windows != 0 {
+		t.Fatalf("unpaid purchase left premium=%v and %d window(s)", premium, windows)
+	}
+
+	// 100 earned from a completed cash trade covers the 70 price
+	sold := createTestProduct(t, db, seller, "Sold For Cash")
+	t.Cleanup(func() { db.Exec("DELETE FROM products WHERE id = ?", sold) })
+	if _, err := db.Exec(`INSERT INTO trades (buyer_id, seller_id, target_product_id, status, offered_cash_amount, net_amount)
+		VALUES (?, ?, ?, 'completed', 100, 100)`, buyer, seller, sold); err != nil {
+		t.Fatalf("insert trade: %v", err)
+	}
+	t.Cleanup(func() { db.Exec("DELETE FROM trades WHERE seller_id = ?", seller) })
+
+	if got := buy(); got != 201 {
+		t.Fatalf("funded purchase: status %d, want 201", got)
+	}
+	balance, err := services.SellerPayoutBalance(db, seller)
+	if err != nil {
+		t.Fatalf("balance: %v", err)
+	}
+	if balance.Charged != 70 || balance.Available != 30 {
+		t.Errorf("balance = %+v, want 70 charged and 30 available", balance)
+	}
+	if got := buy(); got != 402 {
+		t.Errorf("second purchase beyond the balance: status %d, want 402", got)
+	}
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/models"
+)
+
+// registerStream subscribes ch to a user's SSE events and reports whether this is
+// the user's first open stream, i.e. they just came online
+func registerStream(userID int, ch chan []byte) bool {
+	userStreams.Lock()
+	defer userStreams.Unlock()
+	userStreams.m[userID] = append(userStreams.m[userID], ch)
+	return len(userStreams.m[userID]) == 1
+}
+
+// unregisterStream removes ch and reports whether it was the user's last stream,
+// i.e. they just went offline
+func unregisterStream(userID int, ch chan []byte) bool {
+	userStreams.Lock()
+	defer userStreams.Unlock()
+	subs, ok := userStreams.m[userID]
+	if !ok {
+		return false
+	}
+	for i, c := range subs {
+		if c == ch {
+			subs = append(subs[:i], subs[i+1:]...)
+			break
+		}
+	}
+	if len(subs) == 0 {
+		delete(userStreams.m, userID)
+		return true
+	}
+	userStreams.m[userID] = subs
+	return false
+}
+
+// isUserOnline reports whether the user has at least one open SSE stream
+func isUserOnline(userID int) bool {
+	userStreams.RLock()
+	defer userStreams.RUnlock()
+	return len(userStreams.m[userID]) > 0
+}
+
+// announcePresence records a connection change and sends a presence event to everyone
+// the user has a conversation with. Going offline stamps users.last_seen_at.
+func announcePresence(db *sql.DB, userID int, online bool) {
+	if db == nil {
+		return
+	}
+	p := models.UserPresence{UserID: userID, Online: online}
+	if !online {
+		now := time.Now()
+		if _, err := db.Exec("UPDATE users SET last_seen_at = ? WHERE id = ?", now, userID); err != nil {
+			log.Printf("Warning: failed to store last seen for user %d: %v", userID, err)
+		}
+		p.LastSeenAt = &now
+	}
+
+	partners, err := conversationPartners(db, userID)
+	if err != nil {
+		log.Printf("Warning: failed to load conversation partners for user %d: %v", userID, err)
+		return
+	}
+	for _, partnerID := range partners {
+		publishToUser(partnerID, sseEvent{Type: "presence", Data: p})
+	}
+}
+
+// conversationPartners lists the distinct users who share a conversation with userID
+func conversationPartners(db *sql.DB, userID int) ([]int, error) {
+	rows, err := db.Query(`
+		SELECT DISTINCT CASE WHEN buyer_id = ? THEN seller_id ELSE buyer_id END
+		FROM conversations
+		WHERE buyer_id = ? OR seller_id = ?`, userID, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		if id != userID {
+			ids = append(ids, id)
+		}
+	}
+	return ids, rows.Err()
+}
+
+// GetUserPresence reports whether a user is connected right now and when they were last seen
+func (h *UserHandler) GetUserPresence(c *fiber.Ctx) error {
+	userID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid user ID"})
+	}
+
+	var lastSeen sql.NullTime
+	err = h.db.QueryRow("SELECT last_seen_at FROM users WHERE id = ?", userID).Scan(&lastSeen)
+	if err == sql.ErrNoRows {
+		return c.Status(404).JSON(models.APIResponse{Success: false, Error: "User not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to load presence"})
+	}
+
+	p := models.UserPresence{UserID: userID, Online: isUserOnline(userID)}
+	if lastSeen.Valid {
+		t := lastSeen.Time
+		p.LastSeenAt = &t
+	}
+	return c.JSON(models.APIResponse{Success: true, Data: p})
+}
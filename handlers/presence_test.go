@@ -0,0 +1,34 @@
+package handlers
+
+import "testing"
+
+func TestStreamRegistrationTracksPresence(t *testing.T) {
+	const userID = 987654
+	first, second := make(chan []byte, 1), make(chan []byte, 1)
+
+	if !registerStream(userID, first) {
+		t.Error("first stream should bring the user online")
+	}
+	if registerStream(userID, second) {
+		t.Error("second stream should not announce the user again")
+	}
+	if !isUserOnline(userID) {
+		t.Error("user with open streams should be online")
+	}
+
+	if unregisterStream(userID, first) {
+		t.Error("closing one of two streams should keep the user online")
+	}
+	if !isUserOnline(userID) {
+		t.Error("user with a remaining stream should be online")
+	}
+	if !unregisterStream(userID, second) {
+		t.Error("closing the last stream should take the user offline")
+	}
+	if isUserOnline(userID) {
+		t.Error("user without streams should be offline")
+	}
+	if unregisterStream(userID, second) {
+		t.Error("unregistering twice should not report another transition")
+	}
+}
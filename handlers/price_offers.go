@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/middleware"
+	"github.com/xashathebest/clovia/models"
+)
+
+// priceOfferMessageMax caps the note a buyer can attach to an offer
+const priceOfferMessageMax = 500
+
+// openPriceOfferStatuses are offers that still hold a buyer's place in the negotiation
+const openPriceOfferStatuses = "'pending', 'accepted'"
+
+// priceOfferColumns is the SELECT list scanPriceOffer expects, over price_offers o joined
+// to products p and the buyer u
+const priceOfferColumns = `o.id, o.product_id, p.title, p.price, p.status, o.buyer_id, u.name, o.seller_id, o.amount,
+	o.proposed_by, o.status, o.message, o.order_id, o.created_at, o.updated_at`
+
+const priceOfferFrom = ` FROM price_offers o JOIN products p ON p.id = o.product_id JOIN users u ON u.id = o.buyer_id`
+
+func scanPriceOffer(row interface{ Scan(...interface{}) error }) (models.PriceOffer, error) {
+	var o models.PriceOffer
+	var listPrice sql.NullFloat64
+	var message sql.NullString
+	var orderID sql.NullInt64
+	if err := row.Scan(&o.ID, &o.ProductID, &o.ProductTitle, &listPrice, &o.ProductStatus, &o.BuyerID, &o.BuyerName, &o.SellerID, &o.Amount,
+		&o.ProposedBy, &o.Status, &message, &orderID, &o.CreatedAt, &o.UpdatedAt); err != nil {
+		return o, err
+	}
+	o.ListPrice = nullFloatPtr(listPrice)
+	o.Message = message.String
+	if orderID.Valid {
+		id := int(orderID.Int64)
+		o.OrderID = &id
+	}
+	return o, nil
+}
+
+// validOfferAmount checks a proposed price: positive, whole cents, and not above the list price
+func validOfferAmount(amount float64, listPrice *float64) string {
+	if amount <= 0 || math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return "Offer amount must be greater than zero"
+	}
+	if math.Abs(amount*100-math.Round(amount*100)) > 1e-6 {
+		return "Offer amount can have at most two decimal places"
+	}
+	if listPrice != nil && amount > *listPrice {
+		return "Offer amount cannot be above the listed price"
+	}
+	return ""
+}
+
+// CreatePriceOffer lets a buyer propose a cash price on a buyable listing. A buyer has at
+// most one open offer per listing.
+// Body: { "amount": 850, "message": "Can pick up today" }
+func (h *ProductHandler) CreatePriceOffer(c *fiber.Ctx) error {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "User not authenticated"})
+	}
+	productID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid product ID"})
+	}
+	var payload struct {
+		Amount  float64 `json:"amount"`
+		Message string  `json:"message"`
+	}
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid request body"})
+	}
+	payload.Message = strings.TrimSpace(payload.Message)
+	if len([]rune(payload.Message)) > priceOfferMessageMax {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: fmt.Sprintf("Message is limited to %d characters", priceOfferMessageMax)})
+	}
+
+	var product models.Product
+	err = h.db.QueryRow("SELECT id, title, price, seller_id, status, allow_buying, barter_only FROM products WHERE id = ?", productID).
+		Scan(&product.ID, &product.Title, &product.Price, &product.SellerID, &product.Status, &product.AllowBuying, &product.BarterOnly)
+	if err == sql.ErrNoRows {
+		return c.Status(404).JSON(models.APIResponse{Success: false, Error: "Product not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to retrieve product"})
+	}
+	if product.SellerID == userID {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "You cannot make an offer on your own product"})
+	}
+	if product.Status != "available" {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Product is not available for purchase"})
+	}
+	if !product.CanBuy() {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: models.TradeOnlyMessage})
+	}
+	if until, err := sellerAwayUntil(h.db, product.SellerID); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to check seller availability"})
+	} else if until != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: sellerAwayMessage(*until)})
+	}
+	if msg := validOfferAmount(payload.Amount, product.Price); msg != "" {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: msg})
+	}
+
+	var existing int
+	err = h.db.QueryRow("SELECT id FROM price_offers WHERE product_id = ? AND buyer_id = ? AND status IN ("+openPriceOfferStatuses+") LIMIT 1",
+		productID, userID).Scan(&existing)
+	if err == nil {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Error:   "You already have an open offer on this product",
+			Data:    fiber.Map{"price_offer_id": existing},
+		})
+	}
+	if err != sql.ErrNoRows {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to check existing offers"})
+	}
+
+	res, err := h.db.Exec("INSERT INTO price_offers (product_id, buyer_id, seller_id, amount, message) VALUES (?, ?, ?, ?, ?)",
+		productID, userID, product.SellerID, payload.Amount, nullableString(&payload.Message))
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to save offer"})
+	}
+	id, _ := res.LastInsertId()
+	offer, err := scanPriceOffer(h.db.QueryRow("SELECT "+priceOfferColumns+priceOfferFrom+" WHERE o.id = ?", id))
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to load offer"})
+	}
+
+	notify(h.db, product.SellerID, "price_offer",
+		fmt.Sprintf("%s offered %.2f for \"%s\"", offer.BuyerName, payload.Amount, product.Title),
+		models.NotificationRefPriceOffer, offer.ID)
+	publishToUser(product.SellerID, sseEvent{Type: "price_offer_created", Data: offer})
+
+	return c.Status(201).JSON(models.APIResponse{Success: true, Message: "Offer sent", Data: offer})
+}
+
+// GetPriceOffers lists offers on a product: every offer for its seller, only their own for
+// anyone else. Newest first.
+func (h *ProductHandler) GetPriceOffers(c *fiber.Ctx) error {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "User not authenticated"})
+	}
+	productID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid product ID"})
+	}
+
+	var sellerID int
+	if err := h.db.QueryRow("SELECT seller_id FROM products WHERE id = ?", productID).Scan(&sellerID); err != nil {
+		if err == sql.ErrNoRows {
+			return c.Status(404).JSON(models.APIResponse{Success: false, Error: "Product not found"})
+		}
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to retrieve product"})
+	}
+
+	query := "SELECT " + priceOfferColumns + priceOfferFrom + " WHERE o.product_id = ?"
+	args := []interface{}{productID}
+	if sellerID != userID {
+		query += " AND o.buyer_id = ?"
+		args = append(args, userID)
+	}
+	rows, err := h.db.Query(query+" ORDER BY o.created_at DESC, o.id DESC", args...)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to fetch offers"})
+	}
+	defer rows.Close()
+	offers := []models.PriceOffer{}
+	for rows.Next() {
+		o, err := scanPriceOffer(rows)
+		if err != nil {
+			return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to read offers"})
+		}
+		offers = append(offers, o)
+	}
+	return c.JSON(models.APIResponse{Success: true, Data: offers})
+}
+
+// RespondToPriceOffer moves an offer along. Whoever did not make the current proposal may
+// accept, decline or counter it; the buyer may withdraw at any point before ordering.
+// Body: { "action": "accept" | "decline" | "counter" | "withdraw", "amount": 900 }
+func (h *ProductHandler) RespondToPriceOffer(c *fiber.Ctx) error {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "User not authenticated"})
+	}
+	offerID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid offer ID"})
+	}
+	var payload struct {
+		Action string  `json:"action"`
+		Amount float64 `json:"amount"`
+	}
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid request body"})
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to start transaction"})
+	}
+	defer tx.Rollback()
+
+	offer, err := scanPriceOffer(tx.QueryRow("SELECT "+priceOfferColumns+priceOfferFrom+" WHERE o.id = ? FOR UPDATE", offerID))
+	if err == sql.ErrNoRows {
+		return c.Status(404).JSON(models.APIResponse{Success: false, Error: "Offer not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to load offer"})
+	}
+	role := ""
+	switch userID {
+	case offer.BuyerID:
+		role = "buyer"
+	case offer.SellerID:
+		role = "seller"
+	default:
+		return c.Status(403).JSON(models.APIResponse{Success: false, Error: "You are not part of this offer"})
+	}
+
+	status, proposedBy := offer.Status, offer.ProposedBy
+	amount := offer.Amount
+	switch payload.Action {
+	case "withdraw":
+		if role != "buyer" {
+			return c.Status(403).JSON(models.APIResponse{Success: false, Error: "Only the buyer can withdraw an offer"})
+		}
+		if offer.Status != "pending" && offer.Status != "accepted" {
+			return c.Status(409).JSON(models.APIResponse{Success: false, Error: "This offer is no longer open"})
+		}
+		status = "withdrawn"
+	case "accept", "decline", "counter":
+		if offer.Status != "pending" {
+			return c.Status(409).JSON(models.APIResponse{Success: false, Error: "This offer is no longer awaiting a response"})
+		}
+		if role == offer.ProposedBy {
+			return c.Status(409).JSON(models.APIResponse{Success: false, Error: "Waiting for the other party to respond"})
+		}
+		// The listing row is locked with the offer; a price can only be agreed while it is
+		// for sale, but an offer on a gone listing can still be declined
+		if payload.Action != "decline" && offer.ProductStatus != "available" {
+			return c.Status(409).JSON(models.APIResponse{Success: false, Error: "This listing is no longer available"})
+		}
+		switch payload.Action {
+		case "accept":
+			status = "accepted"
+		case "decline":
+			status = "declined"
+		case "counter":
+			if msg := validOfferAmount(payload.Amount, offer.ListPrice); msg != "" {
+				return c.Status(400).JSON(models.APIResponse{Success: false, Error: msg})
+			}
+			if payload.Amount == offer.Amount {
+				return c.Status(400).JSON(models.APIResponse{Success: false, Error: "A counter-offer must change the amount; accept the offer instead"})
+			}
+			amount, proposedBy = payload.Amount, role
+		}
+	default:
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "action must be accept, decline, counter or withdraw"})
+	}
+
+	if _, err := tx.Exec("UPDATE price_offers SET status = ?, amount = ?, proposed_by = ? WHERE id = ?", status, amount, proposedBy, offerID); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to update offer"})
+	}
+	if err := tx.Commit(); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to update offer"})
+	}
+	offer.Status, offer.Amount, offer.ProposedBy = status, amount, proposedBy
+
+	other := offer.SellerID
+	if role == "seller" {
+		other = offer.BuyerID
+	}
+	var message string
+	switch payload.Action {
+	case "accept":
+		message = fmt.Sprintf("Your offer of %.2f for \"%s\" was accepted", offer.Amount, offer.ProductTitle)
+		if role == "buyer" {
+			message = fmt.Sprintf("%s accepted your counter-offer of %.2f for \"%s\"", offer.BuyerName, offer.Amount, offer.ProductTitle)
+		}
+	case "decline":
+		message = fmt.Sprintf("Your offer for \"%s\" was declined", offer.ProductTitle)
+	case "counter":
+		message = fmt.Sprintf("New counter-offer of %.2f for \"%s\"", offer.Amount, offer.ProductTitle)
+	case "withdraw":
+		message = fmt.Sprintf("%s withdrew their offer for \"%s\"", offer.BuyerName, offer.ProductTitle)
+	}
+	notify(h.db, other, "price_offer_"+payload.Action, message, models.NotificationRefPriceOffer, offer.ID)
+	publishToUser(other, sseEvent{Type: "price_offer_updated", Data: offer})
+
+	data := fiber.Map{"offer": offer}
+	if offer.Status == "accepted" {
+		// What the buyer sends to POST /api/orders to buy at the agreed price
+		data["order_request"] = models.OrderCreate{ProductID: offer.ProductID, PriceOfferID: &offer.ID}
+	}
+	return c.JSON(models.APIResponse{Success: true, Message: "Offer updated", Data: data})
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/xashathebest/clovia/database"
+)
+
+func TestValidOfferAmount(t *testing.T) {
+	price := 1000.0
+	cases := []struct {
+		amount float64
+		ok     bool
+	}{
+		{850, true},
+		{1000, true},
+		{1000.01, false},
+		{0, false},
+		{-5, false},
+		{99.999, false},
+	}
+	for _, tc := range cases {
+		if got := validOfferAmount(tc.amount, &price) == ""; got != tc.ok {
+			t.Errorf("validOfferAmount(%v) ok = %v, want %v", tc.amount, got, tc.ok)
+		}
+	}
+	if msg := validOfferAmount(5000, nil); msg != "" {
+		t.Errorf("unpriced listing should take any positive offer, got %q", msg)
+	}
+}
+
+// TestPriceOfferCounterThenOrder negotiates a price and buys at it
+func TestPriceOfferCounterThenOrder(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	database.DB = db
+
+	buyerID := createTestUser(t, db, "offer_buyer")
+	sellerID := createTestUser(t, db, "offer_seller")
+	productID := createTestProduct(t, db, sellerID, "Negotiable Lamp")
+	t.Cleanup(func() {
+		db.Exec("DELETE FROM price_offers WHERE product_id = ?", productID)
+		db.Exec("DELETE FROM orders WHERE product_id = ?", productID)
+	})
+
+	products := &ProductHandler{db: db}
+	orders := &OrderHandler{db: db}
+	currentUser := buyerID
+	app := newTestApp(&currentUser, func(app *fiber.App) {
+		app.Post("/products/:id/price-offers", products.CreatePriceOffer)
+		app.Put("/price-offers/:id", products.RespondToPriceOffer)
+		app.Post("/orders", orders.CreateOrder)
+	})
+	do := func(asUser int, method, path, body string) int {
+		currentUser = asUser
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		return resp.StatusCode
+	}
+
+	offersPath := fmt.Sprintf("/products/%d/price-offers", productID)
+	if got := do(buyerID, "POST", offersPath, `{"amount":150}`); got != 400 {
+		t.Errorf("offer above the list price: expected 400, got %d", got)
+	}
+	if got := do(buyerID, "POST", offersPath, `{"amount":70}`); got != 201 {
+		t.Fatalf("offer: expected 201, got %d", got)
+	}
+	if got := do(buyerID, "POST", offersPath, `{"amount":75}`); got != 409 {
+		t.Errorf("second open offer: expected 409, got %d", got)
+	}
+	var offerID int
+	db.QueryRow("SELECT id FROM price_offers WHERE product_id = ?", productID).Scan(&offerID)
+	offerPath := fmt.Sprintf("/price-offers/%d", offerID)
+
+	if got := do(buyerID, "PUT", offerPath, `{"action":"accept"}`); got != 409 {
+		t.Errorf("buyer accepting their own offer: expected 409, got %d", got)
+	}
+	if got := do(sellerID, "PUT", offerPath, `{"action":"counter","amount":85}`); got != 200 {
+		t.Fatalf("seller counter: expected 200, got %d", got)
+	}
+	orderBody := fmt.Sprintf(`{"product_id":%d,"price_offer_id":%d}`, productID, offerID)
+	if got := do(buyerID, "POST", "/orders", orderBody); got != 409 {
+		t.Errorf("ordering before acceptance: expected 409, got %d", got)
+	}
+	if got := do(buyerID, "PUT", offerPath, `{"action":"accept"}`); got != 200 {
+		t.Fatalf("buyer accepting counter: expected 200, got %d", got)
+	}
+	if got := do(buyerID, "POST", "/orders", orderBody); got != 201 {
+		t.Fatalf("order at agreed price: expected 201, got %d", got)
+	}
+
+	var agreed float64
+	var status string
+	db.QueryRow("SELECT o.agreed_price, po.status FROM orders o JOIN price_offers po ON po.order_id = o.id WHERE po.id = ?", offerID).Scan(&agreed, &status)
+	if agreed != 85 || status != "ordered" {
+		t.Errorf("agreed_price = %v, offer status = %q; want 85 and ordered", agreed, status)
+	}
+}
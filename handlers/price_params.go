@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// parsePrice reads an optional price parameter. An empty value yields nil; anything
+// that is not a finite, non-negative number is an error naming the field.
+func parsePrice(field, value string) (*float64, error) {
+	if value == "" {
+		return nil, nil
+	}
+	p, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(p) || math.IsInf(p, 0) {
+		return nil, fmt.Errorf("%s must be a number", field)
+	}
+	if p < 0 {
+		return nil, fmt.Errorf("%s must not be negative", field)
+	}
+	return &p, nil
+}
+
+// parsePriceRange validates ?min_price= and ?max_price= together, rejecting an
+// inverted range so it is reported instead of silently matching nothing.
+func parsePriceRange(minStr, maxStr string) (*float64, *float64, error) {
+	minPrice, err := parsePrice("min_price", minStr)
+	if err != nil {
+		return nil, nil, err
+	}
+	maxPrice, err := parsePrice("max_price", maxStr)
+	if err != nil {
+		return nil, nil, err
+	}
+	if minPrice != nil && maxPrice != nil && *minPrice > *maxPrice {
+		return nil, nil, fmt.Errorf("min_price must not be greater than max_price")
+	}
+	return minPrice, maxPrice, nil
+}
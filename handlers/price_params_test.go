@@ -0,0 +1,44 @@
+package handlers
+
+import "testing"
+
+func TestParsePriceRange(t *testing.T) {
+	cases := []struct {
+		name     string
+		min, max string
+		wantErr  bool
+	}{
+		{"both empty", "", "", false},
+		{"valid range", "10", "50", false},
+		{"equal bounds", "25", "25", false},
+		{"zero min", "0", "", false},
+		{"negative min", "-5", "", true},
+		{"negative max", "", "-1", true},
+		{"inverted range", "50", "10", true},
+		{"not a number", "cheap", "", true},
+		{"nan", "NaN", "", true},
+		{"infinity", "", "Inf", true},
+		{"overflow", "1e400", "", true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := parsePriceRange(tc.min, tc.max)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("parsePriceRange(%q, %q) err = %v, wantErr %v", tc.min, tc.max, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestParsePriceRangeValues(t *testing.T) {
+	minPrice, maxPrice, err := parsePriceRange("10.5", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if minPrice == nil || *minPrice != 10.5 {
+		t.Errorf("min = %v, want 10.5", minPrice)
+	}
+	if maxPrice != nil {
+		t.Errorf("max = %v, want nil", *maxPrice)
+	}
+}
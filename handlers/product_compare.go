@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/xashathebest/clovia/middleware"
+	"github.com/xashathebest/clovia/models"
+)
+
+// maxCompareProducts caps how many products one comparison can include
+const maxCompareProducts = 4
+
+// parseCompareIDs reads a comma-separated id list, dropping repeats and keeping order
+func parseCompareIDs(raw string) ([]int, error) {
+	var ids []int
+	seen := map[int]bool{}
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.Atoi(part)
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("invalid product id %q", part)
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) < 2 {
+		return nil, fmt.Errorf("ids must list at least 2 products")
+	}
+	if len(ids) > maxCompareProducts {
+		return nil, fmt.Errorf("at most %d products can be compared", maxCompareProducts)
+	}
+	return ids, nil
+}
+
+// compareOrigin is where distances are measured from: lat/lng query params, else the
+// signed-in user's saved location
+func compareOrigin(c *fiber.Ctx, db *sql.DB, userID int) (lat, lng float64, ok bool) {
+	if qLat, qLng := c.Query("lat"), c.Query("lng"); qLat != "" && qLng != "" {
+		la, errLat := strconv.ParseFloat(qLat, 64)
+		lo, errLng := strconv.ParseFloat(qLng, 64)
+		if errLat == nil && errLng == nil {
+			return la, lo, true
+		}
+	}
+	if userID == 0 {
+		return 0, 0, false
+	}
+	var uLat, uLng sql.NullFloat64
+	if err := db.QueryRow("SELECT latitude, longitude FROM users WHERE id = ?", userID).Scan(&uLat, &uLng); err != nil {
+		return 0, 0, false
+	}
+	if !uLat.Valid || !uLng.Valid {
+		return 0, 0, false
+	}
+	return uLat.Float64, uLng.Float64, true
+}
+
+// CompareProducts returns the comparable fields of 2-4 products side by side.
+// GET /api/products/compare?ids=1,2,3
+func (h *ProductHandler) CompareProducts(c *fiber.Ctx) error {
+	ids, err := parseCompareIDs(c.Query("ids"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: err.Error()})
+	}
+	userID, _ := middleware.GetUserIDFromContext(c)
+	originLat, originLng, hasOrigin := compareOrigin(c, h.db, userID)
+
+	ratings := map[int]models.RatingBreakdown{}
+	compared := make([]models.ProductComparison, 0, len(ids))
+	for _, id := range ids {
+		product, err := h.loadProductDetail("p.id = ?", id)
+		if err == sql.ErrNoRows {
+			return c.Status(404).JSON(models.APIResponse{Success: false, Error: fmt.Sprintf("Product %d not found", id)})
+		}
+		if err != nil {
+			return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to load products"})
+		}
+		// Same visibility rules as GetProduct
+		switch h.productVisibleTo(userID, product.Status, product.SellerID) {
+		case 410:
+			return c.Status(410).JSON(models.APIResponse{Success: false, Error: fmt.Sprintf("Product %d was removed by a moderator", id)})
+		case 404:
+			return c.Status(404).JSON(models.APIResponse{Success: false, Error: fmt.Sprintf("Product %d not found", id)})
+		case 403:
+			return c.Status(403).JSON(models.APIResponse{Success: false, Error: fmt.Sprintf("Product %d is no longer available", id)})
+		}
+
+		row := models.ProductComparison{
+			ProductID:      product.ID,
+			Title:          product.Title,
+			Slug:           product.Slug,
+			Status:         product.Status,
+			ImageURLs:      product.ImageURLs,
+			Price:          product.Price,
+			Currency:       product.Currency,
+			SuggestedValue: product.SuggestedValue,
+			Condition:      product.Condition,
+			Location:       product.Location,
+			SellerID:       product.SellerID,
+			SellerName:     product.SellerName,
+			WishlistCount:  product.WishlistCount,
+		}
+		if hasOrigin && product.Latitude != nil && product.Longitude != nil {
+			km := math.Round(calculateDistance(originLat, originLng, *product.Latitude, *product.Longitude)*10) / 10
+			row.DistanceKm = &km
+		}
+		breakdown, seen := ratings[product.SellerID]
+		if !seen {
+			breakdown, _ = loadRatingBreakdown(h.db, product.SellerID)
+			ratings[product.SellerID] = breakdown
+		}
+		row.SellerRating = breakdown.Average
+		row.SellerRatingCount = breakdown.Total
+		if votes, err := productVoteCounts(h.db, product.ID); err == nil {
+			row.VotesUnder, row.VotesOver = votes.Under, votes.Over
+		}
+		compared = append(compared, row)
+	}
+
+	return c.JSON(models.APIResponse{Success: true, Data: compared})
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCompareIDs(t *testing.T) {
+	ids, err := parseCompareIDs(" 3, 1,3 ,2")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []int{3, 1, 2}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("ids = %v, want %v", ids, want)
+	}
+
+	for _, raw := range []string{"", "1", "1,1", "1,abc", "1,-2", "1,2,3,4,5"} {
+		if _, err := parseCompareIDs(raw); err == nil {
+			t.Errorf("parseCompareIDs(%q) accepted, want error", raw)
+		}
+	}
+}
+
+// TestProductVisibleTo covers the rules that need no admin lookup
+func TestProductVisibleTo(t *testing.T) {
+	h := &ProductHandler{}
+	cases := []struct {
+		userID, sellerID int
+		status           string
+		want             int
+	}{
+		{0, 5, "available", 0},
+		{0, 5, "removed", 410},
+		{7, 5, "draft", 404},
+		{5, 5, "draft", 0},
+		{7, 5, "locked", 403},
+		{5, 5, "traded", 0},
+	}
+	for _, tc := range cases {
+		if got := h.productVisibleTo(tc.userID, tc.status, tc.sellerID); got != tc.want {
+			t.Errorf("productVisibleTo(%d, %q, %d) = %d, want %d", tc.userID, tc.status, tc.sellerID, got, tc.want)
+		}
+	}
+}
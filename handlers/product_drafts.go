@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/xashathebest/clovia/config"
+	"github.com/xashathebest/clovia/middleware"
+	"github.com/xashathebest/clovia/models"
+	"github.com/xashathebest/clovia/services"
+)
+
+// listingChecks is what the appraisal, geocoding and counterfeit passes derive
+// from a listing before it goes public
+type listingChecks struct {
+	Category       string
+	Condition      string
+	Description    string
+	Latitude       *float64
+	Longitude      *float64
+	GeocodeStatus  string
+	SuggestedValue int
+	Report         services.CounterfeitReport
+}
+
+// runListingChecks appraises, geocodes and screens a listing. An explicit
+// category or condition from the seller wins over the appraised one.
+func (h *ProductHandler) runListingChecks(title, description, category, condition, location string, price float64, currency string) listingChecks {
+	appraisal := services.AppraiseProduct(title, description)
+	checks := listingChecks{Category: category, Condition: condition, Description: description}
+	if checks.Category == "" {
+		checks.Category = appraisal.Category
+	}
+	if checks.Condition == "" {
+		checks.Condition = appraisal.Condition
+	}
+	checks.Latitude, checks.Longitude, checks.GeocodeStatus = geocodeLocation(location)
+	checks.SuggestedValue = calculateSuggestedValue(price, checks.Condition, currency)
+
+	checks.Report = services.DetectCounterfeit(h.db, title, description, checks.Category, config.InDefaultCurrency(price, currency))
+	if checks.Report.IsSuspicious {
+		checks.Description = "[SUSPICIOUS] " + checks.Report.Reason + ". " + description
+	}
+	return checks
+}
+
+// storeCounterfeitReport records the outcome of a counterfeit screen on the product row
+func storeCounterfeitReport(db execer, productID int64, report services.CounterfeitReport) {
+	if report.IsSuspicious {
+		flagsJSON, _ := json.Marshal(report.Flags)
+		_, _ = db.Exec(
+			"UPDATE products SET counterfeit_confidence = ?, counterfeit_flags = ?, last_counterfeit_check_at = CURRENT_TIMESTAMP WHERE id = ?",
+			report.Confidence, string(flagsJSON), productID,
+		)
+		return
+	}
+	_, _ = db.Exec(
+		"UPDATE products SET counterfeit_confidence = 0, last_counterfeit_check_at = CURRENT_TIMESTAMP WHERE id = ?",
+		productID,
+	)
+}
+
+// GetMyDrafts lists the authenticated seller's unpublished listings, most recently edited first
+func (h *ProductHandler) GetMyDrafts(c *fiber.Ctx) error {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "User not authenticated"})
+	}
+	pg, err := parsePagination(c)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: err.Error()})
+	}
+
+	var total int
+	if err := h.db.QueryRow("SELECT COUNT(*) FROM products WHERE seller_id = ? AND status = 'draft'", userID).Scan(&total); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to count drafts"})
+	}
+
+	rows, err := h.db.Query(
+		"SELECT id FROM products WHERE seller_id = ? AND status = 'draft' ORDER BY updated_at DESC, id DESC LIMIT ? OFFSET ?",
+		userID, pg.Limit, pg.Offset,
+	)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to fetch drafts"})
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+
+	drafts := []models.Product{}
+	for _, id := range ids {
+		product, err := h.loadProductDetail("p.id = ?", id)
+		if err != nil {
+			log.Printf("Warning: failed to load draft %d: %v", id, err)
+			continue
+		}
+		drafts = append(drafts, product)
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data: models.PaginatedResponse{
+			Data:       drafts,
+			Total:      total,
+			Page:       pg.Page,
+			Limit:      pg.Limit,
+			TotalPages: (total + pg.Limit - 1) / pg.Limit,
+		},
+	})
+}
+
+// PublishProduct takes a draft live: the listing limit, appraisal, geocoding and
+// counterfeit checks that CreateProduct skipped for the draft run here instead
+func (h *ProductHandler) PublishProduct(c *fiber.Ctx) error {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "User not authenticated"})
+	}
+	productID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid product ID"})
+	}
+
+	var (
+		sellerID                      int
+		status, title                 string
+		description, location         sql.NullString
+		condition, category, currency sql.NullString
+		price                         sql.NullFloat64
+	)
+	err = h.db.QueryRow(
+		"SELECT seller_id, status, title, description, price, location, `condition`, category, currency FROM products WHERE id = ?",
+		productID,
+	).Scan(&sellerID, &status, &title, &description, &price, &location, &condition, &category, &currency)
+	if err == sql.ErrNoRows || (err == nil && status == "draft" && sellerID != userID) {
+		return c.Status(404).JSON(models.APIResponse{Success: false, Error: "Product not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to retrieve product details"})
+	}
+	if sellerID != userID {
+		return c.Status(403).JSON(models.APIResponse{Success: false, Error: "You can only publish your own products"})
+	}
+	if status != "draft" {
+		return c.Status(409).JSON(models.APIResponse{Success: false, Error: "Only drafts can be published"})
+	}
+
+	activeListings, listingLimit, err := h.checkListingLimit(userID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to check listing limit"})
+	}
+	if listingLimitReached(activeListings, listingLimit) {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("You have reached the limit of %d active listings. Mark items as sold or remove old listings to post more", listingLimit),
+		})
+	}
+
+	cur, _ := config.NormalizeCurrency(currency.String)
+	checks := h.runListingChecks(title, description.String, category.String, condition.String, location.String, price.Float64, cur)
+
+	// The status guard keeps a double submit from publishing (and counting) twice
+	res, err := h.db.Exec(
+		"UPDATE products SET status = 'available', description = ?, category = ?, `condition` = ?, suggested_value = ?, latitude = ?, longitude = ?, geocode_status = ?, version = version + 1 WHERE id = ? AND status = 'draft'",
+		checks.Description, checks.Category, checks.Condition, checks.SuggestedValue, checks.Latitude, checks.Longitude, checks.GeocodeStatus, productID,
+	)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to publish product"})
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return c.Status(409).JSON(models.APIResponse{Success: false, Error: "Only drafts can be published"})
+	}
+
+	if err := services.RecordProductStatusChange(h.db, productID, "draft", "available", userID, "draft published"); err != nil {
+		log.Printf("Warning: failed to record status history for product %d: %v", productID, err)
+	}
+	storeCounterfeitReport(h.db, int64(productID), checks.Report)
+
+	product, err := h.loadProductDetail("p.id = ?", productID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to retrieve published product"})
+	}
+	h.attachCounterfeitSignals(&product)
+
+	message := "Product published successfully"
+	if checks.GeocodeStatus == models.GeocodeFailed {
+		message = geocodeFailedWarning
+	}
+	return c.JSON(models.APIResponse{Success: true, Message: message, Data: product})
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/xashathebest/clovia/database"
+	"github.com/xashathebest/clovia/models"
+)
+
+// TestDraftsStayPrivateUntilPublished hides a draft from feeds and strangers, then publishes it
+func TestDraftsStayPrivateUntilPublished(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	database.DB = db
+
+	ownerID := createTestUser(t, db, "draft_owner")
+	strangerID := createTestUser(t, db, "draft_stranger")
+	productID := createTestProduct(t, db, ownerID, "Draft Item")
+	defer db.Exec("DELETE FROM products WHERE id = ?", productID)
+	if _, err := db.Exec("UPDATE products SET status = 'draft' WHERE id = ?", productID); err != nil {
+		t.Fatalf("mark draft: %v", err)
+	}
+
+	handler := NewProductHandler()
+	currentUser := ownerID
+	app := newTestApp(&currentUser, func(app *fiber.App) {
+		app.Get("/users/me/drafts", handler.GetMyDrafts)
+		app.Get("/products", handler.GetProducts)
+		app.Post("/products/:id/publish", handler.PublishProduct)
+		app.Get("/products/:id", handler.GetProduct)
+	})
+	do := func(asUser int, method, path string) (int, models.APIResponse) {
+		currentUser = asUser
+		resp, err := app.Test(httptest.NewRequest(method, path, nil), -1)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		var body models.APIResponse
+		json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode, body
+	}
+	feedTotal := func() float64 {
+		_, body := do(ownerID, "GET", fmt.Sprintf("/products?seller_id=%d", ownerID))
+		data, _ := body.Data.(map[string]interface{})
+		total, _ := data["total"].(float64)
+		return total
+	}
+	productPath := fmt.Sprintf("/products/%d", productID)
+
+	if got := feedTotal(); got != 0 {
+		t.Errorf("seller feed lists %v products, want the draft hidden", got)
+	}
+	if code, _ := do(strangerID, "GET", productPath); code != 404 {
+		t.Errorf("stranger GET draft: expected 404, got %d", code)
+	}
+	if code, body := do(ownerID, "GET", "/users/me/drafts"); code != 200 || body.Data.(map[string]interface{})["total"] != float64(1) {
+		t.Errorf("owner drafts: got %d %+v, want one draft", code, body.Data)
+	}
+
+	if code, _ := do(strangerID, "POST", productPath+"/publish"); code != 404 {
+		t.Errorf("stranger publish: expected 404, got %d", code)
+	}
+	if code, body := do(ownerID, "POST", productPath+"/publish"); code != 200 {
+		t.Fatalf("publish: expected 200, got %d (%s)", code, body.Error)
+	}
+	var status string
+	db.QueryRow("SELECT status FROM products WHERE id = ?", productID).Scan(&status)
+	if status != "available" {
+		t.Errorf("status after publish = %q, want available", status)
+	}
+	if code, _ := do(ownerID, "POST", productPath+"/publish"); code != 409 {
+		t.Errorf("second publish: expected 409, got %d", code)
+	}
+	if got := feedTotal(); got != 1 {
+		t.Errorf("seller feed lists %v products after publish, want 1", got)
+	}
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"database/sql"
+
+	"github.com/xashathebest/clovia/models"
+)
+
+// Facet names accepted by productFilters.where
+const (
+	facetCategory  = "category"
+	facetCondition = "condition"
+)
+
+// productFilters is the WHERE clause shared by product search and its facet counts.
+// The category and condition filters are kept apart from the rest so each facet can
+// be counted against every filter except its own.
+type productFilters struct {
+	base      string
+	baseArgs  []interface{}
+	category  string
+	condition string
+}
+
+// where returns the clause and args with every filter applied except skip
+func (f productFilters) where(skip string) (string, []interface{}) {
+	clause := f.base
+	args := append([]interface{}{}, f.baseArgs...)
+	if f.category != "" && skip != facetCategory {
+		clause += " AND p.category = ?"
+		args = append(args, f.category)
+	}
+	if f.condition != "" && skip != facetCondition {
+		clause += " AND p.`condition` = ?"
+		args = append(args, f.condition)
+	}
+	return clause, args
+}
+
+// loadProductFacets counts the current result set grouped by category and by condition
+func loadProductFacets(db *sql.DB, filters productFilters) (*models.ProductFacets, error) {
+	categories, err := countProductFacet(db, filters, facetCategory, "COALESCE(NULLIF(p.category, ''), 'Uncategorized')")
+	if err != nil {
+		return nil, err
+	}
+	conditions, err := countProductFacet(db, filters, facetCondition, "COALESCE(NULLIF(p.`condition`, ''), 'Unspecified')")
+	if err != nil {
+		return nil, err
+	}
+	return &models.ProductFacets{Categories: categories, Conditions: conditions}, nil
+}
+
+// countProductFacet runs one grouped count, ignoring the filter on the facet being counted
+func countProductFacet(db *sql.DB, filters productFilters, facet, expr string) ([]models.FacetCount, error) {
+	whereClause, args := filters.where(facet)
+	query := "SELECT " + expr + " AS facet_value, COUNT(*) FROM products p LEFT JOIN users u ON p.seller_id = u.id " +
+		whereClause + " GROUP BY facet_value ORDER BY COUNT(*) DESC, facet_value ASC"
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []models.FacetCount{}
+	for rows.Next() {
+		var fc models.FacetCount
+		if err := rows.Scan(&fc.Value, &fc.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, fc)
+	}
+	return counts, rows.Err()
+}
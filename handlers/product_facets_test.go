@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestProductFiltersWhereSkipsOwnFacet(t *testing.T) {
+	f := productFilters{
+		base:      "WHERE 1=1 AND p.status = 'available'",
+		baseArgs:  []interface{}{},
+		category:  "Books",
+		condition: "Used",
+	}
+
+	clause, args := f.where("")
+	if clause != "WHERE 1=1 AND p.status = 'available' AND p.category = ? AND p.`condition` = ?" {
+		t.Fatalf("unexpected clause: %s", clause)
+	}
+	if !reflect.DeepEqual(args, []interface{}{"Books", "Used"}) {
+		t.Fatalf("unexpected args: %v", args)
+	}
+
+	clause, args = f.where(facetCategory)
+	if clause != "WHERE 1=1 AND p.status = 'available' AND p.`condition` = ?" || !reflect.DeepEqual(args, []interface{}{"Used"}) {
+		t.Fatalf("category facet should drop only the category filter: %s %v", clause, args)
+	}
+
+	clause, args = f.where(facetCondition)
+	if clause != "WHERE 1=1 AND p.status = 'available' AND p.category = ?" || !reflect.DeepEqual(args, []interface{}{"Books"}) {
+		t.Fatalf("condition facet should drop only the condition filter: %s %v", clause, args)
+	}
+}
+
+func TestProductFiltersWhereDoesNotAliasBaseArgs(t *testing.T) {
+	base := make([]interface{}, 1, 4)
+	base[0] = 10.0
+	f := productFilters{base: "WHERE p.price >= ?", baseArgs: base, category: "Books", condition: "New"}
+
+	_, first := f.where(facetCondition)
+	_, second := f.where(facetCategory)
+	if first[1] != "Books" || second[1] != "New" {
+		t.Fatalf("facet queries share argument storage: %v %v", first, second)
+	}
+}
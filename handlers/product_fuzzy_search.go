@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// fuzzySearchCandidates caps how many of the newest matching listings' titles are read
+// when looking for a correction
+const fuzzySearchCandidates = 1000
+
+// keywordSearchClause is the product search keyword filter: the keyword anywhere in the
+// listing's text or its seller's name, organization or department
+func keywordSearchClause(keyword string) (string, []interface{}) {
+	// Broaden keyword search across product attributes and seller/org details
+	clause := " AND ("
+	clause += "p.title LIKE ? OR p.description LIKE ?"
+	clause += " OR p.location LIKE ? OR p.category LIKE ? OR p.`condition` LIKE ?"
+	clause += " OR u.name LIKE ? OR u.org_name LIKE ? OR u.department LIKE ?"
+	clause += ")"
+	like := "%" + keyword + "%"
+	args := []interface{}{like, like, like, like, like, like, like, like}
+	searchPattern := "%" + keyword + "%"
+	clause += " AND (p.title LIKE ? OR p.description LIKE ? OR p.category LIKE ? OR p.condition LIKE ? OR u.name LIKE ?)"
+	args = append(args, searchPattern, searchPattern, searchPattern, searchPattern, searchPattern)
+	return clause, args
+}
+
+// suggestSearchKeyword reads the titles of listings matching every filter but the keyword
+// and returns the keyword with misspelt words replaced by the closest title words, or ""
+// when nothing close enough was found
+func suggestSearchKeyword(ctx context.Context, db *sql.DB, filters productFilters, keyword string) (string, error) {
+	where, args := filters.where("")
+	rows, err := db.QueryContext(ctx, "SELECT p.title FROM products p LEFT JOIN users u ON p.seller_id = u.id "+where+
+		" ORDER BY p.created_at DESC LIMIT ?", append(args, fuzzySearchCandidates)...)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	var titles []string
+	for rows.Next() {
+		var title sql.NullString
+		if err := rows.Scan(&title); err != nil {
+			return "", err
+		}
+		titles = append(titles, title.String)
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	return correctKeyword(keyword, titles), nil
+}
+
+// searchWords splits text into lower-cased runs of letters and digits
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// fuzzyAllowance is how many edits a word of n runes may be off by. Short words are
+// never corrected: too many other words are one edit away.
+func fuzzyAllowance(n int) int {
+	switch {
+	case n < 4:
+		return 0
+	case n < 6:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// correctKeyword replaces each keyword word missing from the titles with the title word
+// fewest edits away, preferring the more common word on a tie. It returns "" when no word
+// was replaced.
+func correctKeyword(keyword string, titles []string) string {
+	vocabulary := map[string]int{}
+	for _, title := range titles {
+		for _, w := range searchWords(title) {
+			vocabulary[w]++
+		}
+	}
+	words := make([]string, 0, len(vocabulary))
+	for w := range vocabulary {
+		words = append(words, w)
+	}
+	sort.Strings(words)
+
+	changed := false
+	tokens := searchWords(keyword)
+	for i, token := range tokens {
+		allowance := fuzzyAllowance(len([]rune(token)))
+		if vocabulary[token] > 0 || allowance == 0 {
+			continue
+		}
+		best, bestDistance := "", allowance+1
+		for _, w := range words {
+			d := levenshtein(token, w)
+			if d < bestDistance || (d == bestDistance && best != "" && vocabulary[w] > vocabulary[best]) {
+				best, bestDistance = w, d
+			}
+		}
+		if best != "" {
+			tokens[i] = best
+			changed = true
+		}
+	}
+	if !changed {
+		return ""
+	}
+	return strings.Join(tokens, " ")
+}
+
+// levenshtein is the number of single-rune insertions, deletions and substitutions
+// that turn a into b
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import "testing"
+
+func TestLevenshtein(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"laptop", "laptop", 0},
+		{"labtop", "laptop", 1},
+		{"lptop", "laptop", 1},
+		{"kitten", "sitting", 3},
+		{"café", "cafe", 1},
+	}
+	for _, tc := range cases {
+		if got := levenshtein(tc.a, tc.b); got != tc.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestCorrectKeyword(t *testing.T) {
+	titles := []string{"Gaming Laptop 15\"", "Laptop stand", "Lamp, desk", "Calculus textbook"}
+	cases := map[string]string{
+		"labtop":          "laptop",
+		"gamming labtop":  "gaming laptop",
+		"calculs":         "calculus",
+		"laptop":          "", // already matches
+		"lap":             "", // too short to correct
+		"zzzzzzzz":        "", // nothing close
+		"textbok, cheap!": "textbook cheap",
+	}
+	for keyword, want := range cases {
+		if got := correctKeyword(keyword, titles); got != want {
+			t.Errorf("correctKeyword(%q) = %q, want %q", keyword, got, want)
+		}
+	}
+}
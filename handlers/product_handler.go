@@ -4,28 +4,34 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"log"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
+	"github.com/xashathebest/clovia/config"
 	"github.com/xashathebest/clovia/database"
 	"github.com/xashathebest/clovia/middleware"
 	"github.com/xashathebest/clovia/models"
 	"github.com/xashathebest/clovia/services"
+	"github.com/xashathebest/clovia/storage"
 )
 
 // ProductHandler handles product-related HTTP requests
 type ProductHandler struct {
-	db *sql.DB
+	db    *sql.DB
+	files storage.Storage
 }
 
 // NewProductHandler creates a new product handler
 func NewProductHandler() *ProductHandler {
 	return &ProductHandler{
-		db: database.DB,
+		db:    database.DB,
+		files: storage.Default(),
 	}
 }
 
@@ -37,18 +43,73 @@ var conditionMultipliers = map[string]float64{
 	"Fair":     0.4,
 }
 
-// calculateSuggestedValue calculates the value in points based on price and condition.
-func calculateSuggestedValue(price float64, condition string) int {
+// calculateSuggestedValue calculates the value in points based on price, currency and condition.
+func calculateSuggestedValue(price float64, condition, currency string) int {
 	multiplier, ok := conditionMultipliers[condition]
 	if !ok {
 		multiplier = 0.5 // Default multiplier for unknown conditions
 	}
-	// Assuming 1 PHP = 1 point for simplicity, then apply multiplier
-	return int(price * multiplier)
+	// Convert to points (1 PHP = 1 point), then apply multiplier
+	return int(price * config.PointsPerUnit(currency) * multiplier)
+}
+
+// activeListingLimit returns the active listing cap for an account. An admin
+// override takes precedence; a limit of 0 or less means unlimited.
+func activeListingLimit(elevated bool, override *int) int {
+	if override != nil {
+		return *override
+	}
+	if elevated {
+		return config.MaxActiveListingsPremium()
+	}
+	return config.MaxActiveListings()
+}
+
+// listingLimitReached reports whether another listing would exceed the cap
+func listingLimitReached(active, limit int) bool {
+	return limit > 0 && active >= limit
+}
+
+// checkListingLimit loads the seller's active listing count and cap.
+// Premium sellers (an active premium listing) and verified organizations get the higher cap.
+func (h *ProductHandler) checkListingLimit(userID int) (active int, limit int, err error) {
+	var orgVerified, hasPremium bool
+	var override sql.NullInt64
+	err = h.db.QueryRow(`
+		SELECT u.org_verified, u.listing_limit_override,
+			EXISTS(
+				SELECT 1 FROM premium_listings pl
+				JOIN products p ON p.id = pl.product_id
+				WHERE p.seller_id = u.id AND pl.end_date > NOW()
+			)
+		FROM users u WHERE u.id = ?
+	`, userID).Scan(&orgVerified, &override, &hasPremium)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var overridePtr *int
+	if override.Valid {
+		v := int(override.Int64)
+		overridePtr = &v
+	}
+	limit = activeListingLimit(orgVerified || hasPremium, overridePtr)
+
+	err = h.db.QueryRow("SELECT COUNT(*) FROM products WHERE seller_id = ? AND status IN ('available', 'locked')", userID).Scan(&active)
+	return active, limit, err
 }
 
 // generateSlug creates a URL-friendly slug from title and appends a short UUID
 func generateSlug(title string) string {
+	// Generate short UUID (first 8 characters)
+	shortUUID := uuid.New().String()[:8]
+
+	// Combine slug with UUID: "eco-bag-3f8a9d2a"
+	return fmt.Sprintf("%s-%s", slugBase(title), shortUUID)
+}
+
+// slugBase is the URL-friendly part of a slug derived from title, before the short UUID
+func slugBase(title string) string {
 	// Convert to lowercase
 	slug := strings.ToLower(title)
 
@@ -72,11 +133,7 @@ func generateSlug(title string) string {
 		slug = strings.TrimRight(slug, "-")
 	}
 
-	// Generate short UUID (first 8 characters)
-	shortUUID := uuid.New().String()[:8]
-
-	// Combine slug with UUID: "eco-bag-3f8a9d2a"
-	return fmt.Sprintf("%s-%s", slug, shortUUID)
+	return slug
 }
 
 // CreateProduct creates a new product
@@ -93,18 +150,24 @@ func (h *ProductHandler) CreateProduct(c *fiber.Ctx) error {
 	title := c.FormValue("title")
 	description := c.FormValue("description")
 	priceStr := c.FormValue("price")
-	var price *float64
-	if priceStr != "" {
-		p, err := strconv.ParseFloat(priceStr, 64)
-		if err == nil {
-			price = &p
-		}
+	price, err := parsePrice("price", priceStr)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
 	}
-	premium := c.FormValue("premium") == "true"
 	allowBuying := c.FormValue("allow_buying") == "true"
 	barterOnly := c.FormValue("barter_only") == "true"
 	location := c.FormValue("location")
 	condition := c.FormValue("condition")
+	currency, ok := config.NormalizeCurrency(c.FormValue("currency"))
+	if !ok {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "currency must be one of " + strings.Join(config.SupportedCurrencies(), ", "),
+		})
+	}
 	// Optional category override from client
 	categoryOverride := c.FormValue("category")
 
@@ -117,20 +180,60 @@ func (h *ProductHandler) CreateProduct(c *fiber.Ctx) error {
 		})
 	}
 	files := form.File["images"]
-	// Enforce maximum of 8 images per item
-	if len(files) > 8 {
+	// Enforce maximum images per item
+	maxImages := config.MaxProductImages()
+	if len(files) > maxImages {
 		return c.Status(400).JSON(models.APIResponse{
 			Success: false,
-			Error:   "You can upload up to 8 images per product",
+			Error:   fmt.Sprintf("You can upload up to %d images per product", maxImages),
 		})
 	}
+
+	// publish=false saves a draft: it stays out of every public feed and skips the
+	// listing limit and listing checks until PublishProduct takes it live
+	draft := c.FormValue("publish") == "false"
+
+	// Enforce per-account active listing limit
+	if !draft {
+		activeListings, listingLimit, err := h.checkListingLimit(userID)
+		if err != nil {
+			return c.Status(500).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Failed to check listing limit",
+			})
+		}
+		if listingLimitReached(activeListings, listingLimit) {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Error:   fmt.Sprintf("You have reached the limit of %d active listings. Mark items as sold or remove old listings to post more", listingLimit),
+			})
+		}
+	}
+
+	// Compare with the seller's recent listings before storing anything
+	imageHashes := hashUploads(files)
+	duplicateSettings := services.DefaultDuplicateSettings()
+	duplicate, err := services.FindDuplicateListing(h.db, userID, title, description, imageHashes, duplicateSettings)
+	if err != nil {
+		log.Printf("Warning: duplicate listing check failed for user %d: %v", userID, err)
+		duplicate = nil
+	}
+	if duplicate != nil && duplicateSettings.Action == services.DuplicateActionBlock {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("This looks like a duplicate of your listing \"%s\". Edit or relist that one instead", duplicate.Title),
+			Data:    duplicate,
+		})
+	}
+
 	var imagePaths []string
 	for _, file := range files {
-		savePath := fmt.Sprintf("uploads/%d_%s", time.Now().UnixNano(), file.Filename)
-		if err := c.SaveFile(file, savePath); err != nil {
+		url, err := storage.SaveUpload(h.files, file)
+		if err != nil {
+			log.Printf("Failed to store product image %q: %v", file.Filename, err)
 			continue // skip failed uploads
 		}
-		imagePaths = append(imagePaths, "/"+savePath)
+		imagePaths = append(imagePaths, url)
 	}
 
 	// Convert imagePaths to JSON
@@ -145,61 +248,28 @@ func (h *ProductHandler) CreateProduct(c *fiber.Ctx) error {
 		insertPrice = *price
 	}
 
-	// Appraise product based on title and description
-	appraisal := services.AppraiseProduct(title, description)
-	category := appraisal.Category
-	if categoryOverride != "" {
-		category = categoryOverride
-	}
-
-	// If user did not specify a condition, use the appraised one
-	finalCondition := condition
-	if finalCondition == "" {
-		finalCondition = appraisal.Condition
-	}
-
-	// Geocode location
-	var lat, lon *float64
-	if location != "" {
-		coords, err := services.GetCoordinates(location)
-		if err == nil {
-			lat = &coords.Latitude
-			lon = &coords.Longitude
-		}
+	// Appraise, geocode and screen the listing; drafts defer all of it to publish time
+	status := "available"
+	checks := listingChecks{Category: categoryOverride, Condition: condition, Description: description}
+	if draft {
+		status = "draft"
+	} else {
+		checks = h.runListingChecks(title, description, categoryOverride, condition, location, insertPrice, currency)
 	}
-
-	// Calculate suggested value
-	suggestedValue := calculateSuggestedValue(insertPrice, finalCondition)
-
-	// Detect counterfeit
-	report := services.DetectCounterfeit(title, description, insertPrice)
-	finalDescription := description
-	if report.IsSuspicious {
-		finalDescription = "[SUSPICIOUS] " + report.Reason + ". " + finalDescription
+	lat, lon, geocodeStatus := checks.Latitude, checks.Longitude, checks.GeocodeStatus
+	var geocodeValue interface{}
+	if geocodeStatus != "" {
+		geocodeValue = geocodeStatus
 	}
 
 	// Generate unique slug
-	slug := generateSlug(title)
-
-	// Ensure slug is unique by checking and appending number if needed
-	baseSlug := slug
-	counter := 1
-	for {
-		var exists int
-		err := h.db.QueryRow("SELECT COUNT(*) FROM products WHERE slug = ?", slug).Scan(&exists)
-		if err != nil || exists == 0 {
-			break
-		}
-		// If slug exists, append counter
-		slug = fmt.Sprintf("%s-%d", baseSlug, counter)
-		counter++
-	}
+	slug := uniqueSlug(h.db, title, 0)
 
 	// Insert new product with slug. Build SQL dynamically so it's tolerant
 	// to missing latitude/longitude columns (some DBs may not have applied migrations).
-	cols := []string{"slug", "title", "description", "price", "image_urls", "seller_id", "premium", "allow_buying", "barter_only", "location", "status", "`condition`", "suggested_value", "category"}
-	placeholders := []string{"?", "?", "?", "?", "?", "?", "?", "?", "?", "?", "?", "?", "?", "?"}
-	args := []interface{}{slug, title, finalDescription, insertPrice, string(imageURLsJSONBytes), userID, premium, allowBuying, barterOnly, location, "available", finalCondition, suggestedValue, category}
+	cols := []string{"slug", "title", "description", "price", "image_urls", "seller_id", "premium", "allow_buying", "barter_only", "location", "status", "`condition`", "suggested_value", "category", "currency", "geocode_status"}
+	placeholders := []string{"?", "?", "?", "?", "?", "?", "?", "?", "?", "?", "?", "?", "?", "?", "?", "?"}
+	args := []interface{}{slug, title, checks.Description, insertPrice, string(imageURLsJSONBytes), userID, false, allowBuying, barterOnly, location, status, checks.Condition, checks.SuggestedValue, checks.Category, currency, geocodeValue}
 
 	// Only include latitude/longitude if geocoding produced values
 	if lat != nil && lon != nil {
@@ -227,45 +297,42 @@ func (h *ProductHandler) CreateProduct(c *fiber.Ctx) error {
 
 	productID, _ := result.LastInsertId()
 
-	// Store counterfeit detection results
-	if report.IsSuspicious {
-		flagsJSON, _ := json.Marshal(report.Flags)
-		_, _ = h.db.Exec(
-			"UPDATE products SET counterfeit_confidence = ?, counterfeit_flags = ?, last_counterfeit_check_at = CURRENT_TIMESTAMP WHERE id = ?",
-			report.Confidence, string(flagsJSON), productID,
-		)
-	} else {
-		_, _ = h.db.Exec(
-			"UPDATE products SET counterfeit_confidence = 0, last_counterfeit_check_at = CURRENT_TIMESTAMP WHERE id = ?",
-			productID,
-		)
+	note := "listing created"
+	if draft {
+		note = "draft saved"
+	}
+	if err := services.RecordProductStatusChange(h.db, int(productID), "", status, userID, note); err != nil {
+		log.Printf("Warning: failed to record status history for product %d: %v", productID, err)
 	}
 
-	// Get the created product
-	var createdProduct models.Product
-	var slugNull sql.NullString
-	err = h.db.QueryRow(
-		"SELECT id, slug, title, description, price, image_urls, seller_id, premium, status, allow_buying, barter_only, location, `condition`, suggested_value, category, created_at, updated_at FROM products WHERE id = ?",
-		productID,
-	).Scan(&createdProduct.ID, &slugNull, &createdProduct.Title, &createdProduct.Description, &createdProduct.Price,
-		&createdProduct.ImageURLs, &createdProduct.SellerID, &createdProduct.Premium, &createdProduct.Status,
-		&createdProduct.AllowBuying, &createdProduct.BarterOnly, &createdProduct.Location,
-		&createdProduct.Condition, &createdProduct.SuggestedValue, &createdProduct.Category, &createdProduct.CreatedAt, &createdProduct.UpdatedAt)
+	// Store counterfeit detection results
+	if !draft {
+		storeCounterfeitReport(h.db, productID, checks.Report)
+	}
 
-	if slugNull.Valid {
-		createdProduct.Slug = slugNull.String
+	if err := recordListingDuplicates(h.db, int(productID), imageHashes, duplicate); err != nil {
+		log.Printf("Warning: failed to store duplicate check for product %d: %v", productID, err)
 	}
 
+	// Return the created product hydrated the same way GetProduct reads it
+	createdProduct, err := h.loadProductDetail("p.id = ?", productID)
 	if err != nil {
 		return c.Status(500).JSON(models.APIResponse{
 			Success: false,
 			Error:   "Failed to retrieve created product",
 		})
 	}
+	h.attachCounterfeitSignals(&createdProduct)
 
+	message := "Product created successfully"
+	if draft {
+		message = "Draft saved"
+	} else if geocodeStatus == models.GeocodeFailed {
+		message = geocodeFailedWarning
+	}
 	return c.Status(201).JSON(models.APIResponse{
 		Success: true,
-		Message: "Product created successfully",
+		Message: message,
 		Data:    createdProduct,
 	})
 }
@@ -279,62 +346,44 @@ func (h *ProductHandler) GetProducts(c *fiber.Ctx) error {
 	premiumStr := c.Query("premium", "")
 	status := c.Query("status", "")
 	sellerIDStr := c.Query("seller_id", "")
+	sellerUsername := normalizeUsername(c.Query("seller_username", ""))
 	barterOnlyStr := c.Query("barter_only", "")
 	allowBuyingStr := c.Query("allow_buying", "")
 	location := c.Query("location", "")
-	page, _ := strconv.Atoi(c.Query("page", "1"))
-	limit, _ := strconv.Atoi(c.Query("limit", "20"))
-	// Support optional offset-based pagination (limit & offset)
-	if limit <= 0 {
-		limit = 20
-	}
-	offsetParam := c.Query("offset", "")
-	var offset int
-	if offsetParam != "" {
-		if o, err := strconv.Atoi(offsetParam); err == nil && o >= 0 {
-			offset = o
-			if limit > 0 {
-				page = (offset / limit) + 1
-			} else {
-				page = 1
-			}
-		} else {
-			offset = (page - 1) * limit
-		}
-	} else {
-		offset = (page - 1) * limit
+	category := c.Query("category", "")
+	condition := c.Query("condition", "")
+	withFacets := c.QueryBool("facets", false)
+	// ?sort=popular ranks by wishlist count; the default is newest first
+	sortBy := c.Query("sort", "newest")
+	if sortBy != "newest" && sortBy != "popular" {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "sort must be newest or popular"})
+	}
+	pg, err := parsePaginationDefault(c, 20)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: err.Error()})
+	}
+	page, limit, offset := pg.Page, pg.Limit, pg.Offset
+	minPrice, maxPrice, err := parsePriceRange(minPriceStr, maxPriceStr)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: err.Error()})
+	}
+	statuses, err := parseStatusFilter(status)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: err.Error()})
 	}
 
 	// Build WHERE clause
 	whereClause := "WHERE 1=1"
 	var args []interface{}
 
-	if keyword != "" {
-		// Broaden keyword search across product attributes and seller/org details
-		whereClause += " AND ("
-		whereClause += "p.title LIKE ? OR p.description LIKE ?"
-		whereClause += " OR p.location LIKE ? OR p.category LIKE ? OR p.`condition` LIKE ?"
-		whereClause += " OR u.name LIKE ? OR u.org_name LIKE ? OR u.department LIKE ?"
-		whereClause += ")"
-		like := "%" + keyword + "%"
-		args = append(args, like, like, like, like, like, like, like, like)
-		searchPattern := "%" + keyword + "%"
-		whereClause += " AND (p.title LIKE ? OR p.description LIKE ? OR p.category LIKE ? OR p.condition LIKE ? OR u.name LIKE ?)"
-		args = append(args, searchPattern, searchPattern, searchPattern, searchPattern, searchPattern)
-	}
-
-	if minPriceStr != "" {
-		if minPrice, err := strconv.ParseFloat(minPriceStr, 64); err == nil {
-			whereClause += " AND p.price >= ?"
-			args = append(args, minPrice)
-		}
+	if minPrice != nil {
+		whereClause += " AND p.price >= ?"
+		args = append(args, *minPrice)
 	}
 
-	if maxPriceStr != "" {
-		if maxPrice, err := strconv.ParseFloat(maxPriceStr, 64); err == nil {
-			whereClause += " AND p.price <= ?"
-			args = append(args, maxPrice)
-		}
+	if maxPrice != nil {
+		whereClause += " AND p.price <= ?"
+		args = append(args, *maxPrice)
 	}
 
 	if premiumStr != "" {
@@ -344,6 +393,14 @@ func (h *ProductHandler) GetProducts(c *fiber.Ctx) error {
 		}
 	}
 
+	// ?status= takes a comma-separated list and applies to seller listings too, e.g.
+	// seller_id=7&status=sold,traded
+	if len(statuses) > 0 {
+		clause, statusArgs := statusInClause(statuses)
+		whereClause += " AND " + clause
+		args = append(args, statusArgs...)
+	}
+
 	// Only apply the default 'available' status filter if no specific seller is requested.
 	// This allows a user to see all of their own products (sold, traded, etc.).
 	if sellerIDStr != "" {
@@ -353,12 +410,20 @@ func (h *ProductHandler) GetProducts(c *fiber.Ctx) error {
 		}
 	} else {
 		// For the general public feed, default to 'available' if no status is specified.
-		if status != "" {
-			whereClause += " AND p.status = ?"
-			args = append(args, status)
-		} else {
+		if len(statuses) == 0 {
 			whereClause += " AND p.status = 'available'"
 		}
+		// Sellers on vacation drop out of the public feed; their own listing views are unaffected
+		whereClause += " AND " + sellerNotAwayClause
+	}
+	// Drafts never show in a feed, not even a seller's own; they live under /users/me/drafts.
+	// Listings taken down by a moderator are gone for good.
+	whereClause += " AND p.status NOT IN ('draft', 'removed')"
+
+	// Seller handle lookup; combines with the status default above like any other filter
+	if sellerUsername != "" {
+		whereClause += " AND u.username = ?"
+		args = append(args, sellerUsername)
 	}
 
 	if barterOnlyStr != "" {
@@ -380,11 +445,23 @@ func (h *ProductHandler) GetProducts(c *fiber.Ctx) error {
 		args = append(args, "%"+location+"%")
 	}
 
+	// The keyword goes on last among the base filters so a fuzzy retry can swap it out
+	baseWhere, baseArgs := whereClause, args
+	if keyword != "" {
+		clause, keywordArgs := keywordSearchClause(keyword)
+		whereClause += clause
+		args = append(args, keywordArgs...)
+	}
+
+	// Category and condition are applied last so facet counts can drop them one at a time
+	filters := productFilters{base: whereClause, baseArgs: args, category: category, condition: condition}
+	whereClause, args = filters.where("")
+
 	// Get total count
 	// NOTE: join users table here because WHERE can reference u.* fields
 	countQuery := "SELECT COUNT(*) FROM products p LEFT JOIN users u ON p.seller_id = u.id " + whereClause
 	var total int
-	err := h.db.QueryRow(countQuery, args...).Scan(&total)
+	err = h.db.QueryRowContext(c.UserContext(), countQuery, args...).Scan(&total)
 	if err != nil {
 		// Enhanced debugging: print query and args
 		fmt.Println("❌ Count query failed!")
@@ -397,6 +474,31 @@ func (h *ProductHandler) GetProducts(c *fiber.Ctx) error {
 		})
 	}
 
+	// A keyword matching nothing may be a typo: retry once with the closest title words.
+	// The fuzzy pass only runs on empty results, so ordinary searches never pay for it.
+	var didYouMean string
+	if total == 0 && keyword != "" {
+		suggestion, err := suggestSearchKeyword(c.UserContext(), h.db,
+			productFilters{base: baseWhere, baseArgs: baseArgs, category: category, condition: condition}, keyword)
+		if err != nil {
+			log.Printf("Warning: fuzzy search for %q failed: %v", keyword, err)
+		} else if suggestion != "" {
+			clause, keywordArgs := keywordSearchClause(suggestion)
+			filters.base = baseWhere + clause
+			filters.baseArgs = append(append([]interface{}{}, baseArgs...), keywordArgs...)
+			whereClause, args = filters.where("")
+			countQuery = "SELECT COUNT(*) FROM products p LEFT JOIN users u ON p.seller_id = u.id " + whereClause
+			if err := h.db.QueryRowContext(c.UserContext(), countQuery, args...).Scan(&total); err != nil {
+				return c.Status(500).JSON(models.APIResponse{
+					Success: false,
+					Error:   "Failed to get product count: " + err.Error(),
+				})
+			}
+			keyword = suggestion
+			didYouMean = suggestion
+		}
+	}
+
 	// Use the full query with proper WHERE clause handling
 	// Check if optional columns exist (slug, latitude, longitude). If migrations haven't been applied,
 	// avoid selecting missing columns to prevent SQL errors.
@@ -412,6 +514,7 @@ func (h *ProductHandler) GetProducts(c *fiber.Ctx) error {
 	slugOK := hasCol("slug")
 	latOK := hasCol("latitude")
 	lngOK := hasCol("longitude")
+	currencyOK := hasCol("currency")
 
 	// Build select column list dynamically to match available schema
 	selectCols := []string{"p.id"}
@@ -425,12 +528,17 @@ func (h *ProductHandler) GetProducts(c *fiber.Ctx) error {
 	if lngOK {
 		selectCols = append(selectCols, "p.longitude")
 	}
-	selectCols = append(selectCols, []string{"p.created_at", "p.updated_at", "COALESCE(u.name, 'Unknown') as seller_name", "p.image_urls"}...)
+	if currencyOK {
+		selectCols = append(selectCols, "COALESCE(p.currency, 'PHP')")
+	}
+	selectCols = append(selectCols, []string{"p.created_at", "p.updated_at", "COALESCE(u.name, 'Unknown') as seller_name", "p.image_urls", "p.wishlist_count"}...)
 
 	cols := strings.Join(selectCols, ", ")
 
 	var query string
-	if keyword == "" {
+	if sortBy == "popular" {
+		query = fmt.Sprintf(`SELECT %s FROM products p LEFT JOIN users u ON p.seller_id = u.id %s ORDER BY p.wishlist_count DESC, p.created_at DESC LIMIT ? OFFSET ?`, cols, whereClause)
+	} else if keyword == "" {
 		query = fmt.Sprintf(`SELECT %s FROM products p LEFT JOIN users u ON p.seller_id = u.id %s ORDER BY p.created_at DESC LIMIT ? OFFSET ?`, cols, whereClause)
 	} else {
 		query = fmt.Sprintf(`SELECT %s FROM products p LEFT JOIN users u ON p.seller_id = u.id %s ORDER BY p.premium DESC, p.created_at DESC LIMIT ? OFFSET ?`, cols, whereClause)
@@ -446,7 +554,7 @@ func (h *ProductHandler) GetProducts(c *fiber.Ctx) error {
 			Error:   "Database connection test failed: " + err.Error(),
 		})
 	}
-	rows, err := h.db.Query(query, args...)
+	rows, err := h.db.QueryContext(c.UserContext(), query, args...)
 	if err != nil {
 		// Enhanced debugging: print query and args
 		fmt.Printf("❌ Products query failed!\n")
@@ -488,6 +596,7 @@ func (h *ProductHandler) GetProducts(c *fiber.Ctx) error {
 		var updatedAt sql.NullTime
 		var sellerName string
 		var imageURLsJSON sql.NullString
+		var wishlistCount int
 
 		// Optional holders
 		var slugNull sql.NullString
@@ -505,7 +614,11 @@ func (h *ProductHandler) GetProducts(c *fiber.Ctx) error {
 		if lngOK {
 			scanTargets = append(scanTargets, &longitudeNull)
 		}
-		scanTargets = append(scanTargets, &createdAt, &updatedAt, &sellerName, &imageURLsJSON)
+		currency := config.DefaultCurrency
+		if currencyOK {
+			scanTargets = append(scanTargets, &currency)
+		}
+		scanTargets = append(scanTargets, &createdAt, &updatedAt, &sellerName, &imageURLsJSON, &wishlistCount)
 
 		if err := rows.Scan(scanTargets...); err != nil {
 			// Log the error but continue processing other rows
@@ -515,13 +628,15 @@ func (h *ProductHandler) GetProducts(c *fiber.Ctx) error {
 
 		// Create a complete product struct
 		product := models.Product{
-			ID:          id,
-			Title:       title,
-			Description: description,
-			SellerID:    sellerID,
-			Status:      status,
-			SellerName:  sellerName,
-			ImageURLs:   models.StringArray{},
+			ID:            id,
+			Title:         title,
+			Description:   description,
+			SellerID:      sellerID,
+			Status:        status,
+			SellerName:    sellerName,
+			Currency:      currency,
+			ImageURLs:     models.StringArray{},
+			WishlistCount: wishlistCount,
 		}
 
 		// Handle slug
@@ -569,10 +684,7 @@ func (h *ProductHandler) GetProducts(c *fiber.Ctx) error {
 
 		// Parse image URLs JSON if present
 		if imageURLsJSON.Valid && imageURLsJSON.String != "" {
-			var urls []string
-			if err := json.Unmarshal([]byte(imageURLsJSON.String), &urls); err == nil {
-				product.ImageURLs = models.StringArray(urls)
-			}
+			product.ImageURLs = parseImageURLs(id, imageURLsJSON.String)
 		}
 
 		products = append(products, product)
@@ -584,6 +696,18 @@ func (h *ProductHandler) GetProducts(c *fiber.Ctx) error {
 	if products == nil {
 		products = []models.Product{}
 	}
+
+	var facets *models.ProductFacets
+	if withFacets {
+		facets, err = loadProductFacets(h.db, filters)
+		if err != nil {
+			return c.Status(500).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Failed to load facets: " + err.Error(),
+			})
+		}
+	}
+
 	return c.JSON(models.APIResponse{
 		Success: true,
 		Data: models.PaginatedResponse{
@@ -592,6 +716,8 @@ func (h *ProductHandler) GetProducts(c *fiber.Ctx) error {
 			Page:       page,
 			Limit:      limit,
 			TotalPages: totalPages,
+			Facets:     facets,
+			DidYouMean: didYouMean,
 		},
 	})
 }
@@ -608,16 +734,10 @@ func (h *ProductHandler) WishlistProduct(c *fiber.Ctx) error {
 		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid product ID"})
 	}
 
-	// Check if the product exists
-	var exists int
-	err = h.db.QueryRow("SELECT COUNT(*) FROM products WHERE id = ?", productID).Scan(&exists)
-	if err != nil || exists == 0 {
+	// Adding twice is a no-op
+	if _, err := addToWishlist(h.db, userID, productID); err == errNotWishlistable {
 		return c.Status(404).JSON(models.APIResponse{Success: false, Error: "Product not found"})
-	}
-
-	// Insert into wishlists, ignoring if it already exists
-	_, err = h.db.Exec("INSERT IGNORE INTO wishlists (user_id, product_id) VALUES (?, ?)", userID, productID)
-	if err != nil {
+	} else if err != nil {
 		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to add to wishlist"})
 	}
 
@@ -636,8 +756,7 @@ func (h *ProductHandler) UnwishlistProduct(c *fiber.Ctx) error {
 		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid product ID"})
 	}
 
-	_, err = h.db.Exec("DELETE FROM wishlists WHERE user_id = ? AND product_id = ?", userID, productID)
-	if err != nil {
+	if _, err := removeFromWishlist(h.db, userID, productID); err != nil {
 		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to remove from wishlist"})
 	}
 
@@ -652,7 +771,10 @@ func (h *ProductHandler) GetWishlistCount(c *fiber.Ctx) error {
 	}
 
 	var count int
-	err = h.db.QueryRow("SELECT COUNT(*) FROM wishlists WHERE product_id = ?", productID).Scan(&count)
+	err = h.db.QueryRow("SELECT wishlist_count FROM products WHERE id = ? AND "+visibleStatusClause("status"), productID).Scan(&count)
+	if err == sql.ErrNoRows {
+		return c.Status(404).JSON(models.APIResponse{Success: false, Error: "Product not found"})
+	}
 	if err != nil {
 		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to get wishlist count"})
 	}
@@ -681,13 +803,10 @@ func (h *ProductHandler) GetUserWishlistStatus(c *fiber.Ctx) error {
 	return c.JSON(models.APIResponse{Success: true, Data: fiber.Map{"is_wishlisted": exists > 0}})
 }
 
-// GetProduct gets a product by ID or slug with visibility checks
-func (h *ProductHandler) GetProduct(c *fiber.Ctx) error {
-	identifier := c.Params("id") // Can be ID or slug
-
-	// Get current user ID (may be 0 if not authenticated)
-	userID, _ := middleware.GetUserIDFromContext(c)
-
+// loadProductDetail reads one product with its seller name and wishlist count and
+// normalizes nullable columns. where is a condition on p such as "p.id = ?".
+// It returns sql.ErrNoRows when no product matches.
+func (h *ProductHandler) loadProductDetail(where string, arg interface{}) (models.Product, error) {
 	var product models.Product
 	var priceNull sql.NullFloat64
 	var imageURLsJSONStr sql.NullString
@@ -703,53 +822,28 @@ func (h *ProductHandler) GetProduct(c *fiber.Ctx) error {
 	var createdAtNull sql.NullTime
 	var updatedAtNull sql.NullTime
 	var statusNull sql.NullString
-
-	// Try to parse as integer ID first, otherwise treat as slug
-	var query string
-	var queryArg interface{}
-	productID, err := strconv.Atoi(identifier)
-	if err == nil {
-		// It's a numeric ID
-		query = `SELECT p.id, p.slug, p.title, p.description, p.price, p.image_urls, p.seller_id,
-			   p.premium, p.status, p.allow_buying, p.barter_only, p.location,
-			   p.created_at, p.updated_at, u.name as seller_name,
-			   (SELECT COUNT(*) FROM wishlists WHERE product_id = p.id) as wishlist_count
-		FROM products p
-		LEFT JOIN users u ON p.seller_id = u.id
-		WHERE p.id = ?`
-		queryArg = productID
-	} else {
-		// It's a slug
-		query = `SELECT p.id, p.slug, p.title, p.description, p.price, p.image_urls, p.seller_id,
-			   p.premium, p.status, p.allow_buying, p.barter_only, p.location,
-			   p.created_at, p.updated_at, u.name as seller_name,
-			   (SELECT COUNT(*) FROM wishlists WHERE product_id = p.id) as wishlist_count
-		FROM products p
-		LEFT JOIN users u ON p.seller_id = u.id
-		WHERE p.slug = ?`
-		queryArg = identifier
-	}
-
-	err = h.db.QueryRow(query, queryArg).Scan(&product.ID, &slugNull, &titleNull, &descriptionNull, &priceNull,
+	var conditionNull, categoryNull sql.NullString
+	var suggestedValueNull sql.NullInt64
+	var latNull, lonNull sql.NullFloat64
+
+	query := `SELECT p.id, p.slug, p.title, p.description, p.price, p.image_urls, p.seller_id,
+		   p.premium, p.status, p.allow_buying, p.barter_only, p.location,
+		   p.created_at, p.updated_at, u.name as seller_name,
+		   p.wishlist_count,
+		   p.` + "`condition`" + `, p.category, p.suggested_value, p.latitude, p.longitude,
+		   COALESCE(p.currency, 'PHP'), COALESCE(p.geocode_status, ''), p.version
+	FROM products p
+	LEFT JOIN users u ON p.seller_id = u.id
+	WHERE ` + where
+
+	err := h.db.QueryRow(query, arg).Scan(&product.ID, &slugNull, &titleNull, &descriptionNull, &priceNull,
 		&imageURLsJSONStr, &product.SellerID, &premiumInt, &statusNull,
 		&allowBuyingInt, &barterOnlyInt, &locationNull,
-		&createdAtNull, &updatedAtNull, &sellerName, &wishlistCount)
-
+		&createdAtNull, &updatedAtNull, &sellerName, &wishlistCount,
+		&conditionNull, &categoryNull, &suggestedValueNull, &latNull, &lonNull,
+		&product.Currency, &product.GeocodeStatus, &product.Version)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return c.Status(404).JSON(models.APIResponse{
-				Success: false,
-				Error:   "Product not found",
-			})
-		}
-		// Log the actual error for debugging with more details
-		fmt.Printf("❌ Error scanning product %v: %v\n", identifier, err)
-		fmt.Printf("   Error type: %T\n", err)
-		// Return error but don't expose internal details in production
-		return c.Status(500).JSON(models.APIResponse{
-			Success: false,
-			Error:   "Failed to retrieve product",
-		})
+		return product, err
 	}
 
 	// Handle nullable string fields
@@ -775,6 +869,15 @@ func (h *ProductHandler) GetProduct(c *fiber.Ctx) error {
 		product.Location = ""
 	}
 
+	product.Condition = conditionNull.String
+	product.Category = categoryNull.String
+	product.SuggestedValue = int(suggestedValueNull.Int64)
+	if latNull.Valid && lonNull.Valid {
+		lat, lon := latNull.Float64, lonNull.Float64
+		product.Latitude = &lat
+		product.Longitude = &lon
+	}
+
 	// Convert boolean integers to bool
 	product.Premium = premiumInt != 0
 	product.AllowBuying = allowBuyingInt != 0
@@ -787,15 +890,6 @@ func (h *ProductHandler) GetProduct(c *fiber.Ctx) error {
 		product.Status = "available" // Default value from schema
 	}
 
-	// SECURITY: Enforce visibility rules
-	// If product is traded or locked, only the owner can view it
-	if (product.Status == "traded" || product.Status == "locked") && product.SellerID != userID {
-		return c.Status(403).JSON(models.APIResponse{
-			Success: false,
-			Error:   "This item is no longer available",
-		})
-	}
-
 	// Handle timestamps
 	if createdAtNull.Valid {
 		product.CreatedAt = createdAtNull.Time
@@ -841,6 +935,7 @@ func (h *ProductHandler) GetProduct(c *fiber.Ctx) error {
 			product.ImageURLs = models.StringArray(cleaned)
 		} else {
 			// If unmarshalling fails, avoid returning an error to the client; set to empty
+			logImageURLError(product.ID, err)
 			product.ImageURLs = models.StringArray{}
 		}
 	} else {
@@ -859,10 +954,94 @@ func (h *ProductHandler) GetProduct(c *fiber.Ctx) error {
 		product.Price = nil
 	}
 
+	return product, nil
+}
+
+// productVisibleTo applies the listing visibility rules for a viewer (0 when anonymous)
+// and returns 0 when the listing may be shown, else the HTTP status to refuse it with:
+// 410 for moderated listings (admins excepted), 404 for someone else's draft and 403 for
+// someone else's traded or locked item.
+func (h *ProductHandler) productVisibleTo(userID int, status string, sellerID int) int {
+	switch {
+	case status == "removed" && !(userID != 0 && isAdminUser(h.db, userID)):
+		return 410
+	case status == "draft" && sellerID != userID:
+		return 404
+	case (status == "traded" || status == "locked") && sellerID != userID:
+		return 403
+	}
+	return 0
+}
+
+// GetProduct gets a product by ID or slug with visibility checks
+func (h *ProductHandler) GetProduct(c *fiber.Ctx) error {
+	identifier := c.Params("id") // Can be ID or slug
+
+	// Get current user ID (may be 0 if not authenticated)
+	userID, _ := middleware.GetUserIDFromContext(c)
+
+	// Try to parse as integer ID first, otherwise treat as slug
+	var product models.Product
+	productID, err := strconv.Atoi(identifier)
+	if err == nil {
+		product, err = h.loadProductDetail("p.id = ?", productID)
+	} else {
+		product, err = h.loadProductDetail("p.slug = ?", identifier)
+		if err == sql.ErrNoRows {
+			// A retired slug keeps working; the product carries the current one
+			if retiredID, rerr := resolveRetiredSlug(h.db, identifier); rerr == nil {
+				if product, err = h.loadProductDetail("p.id = ?", retiredID); err == nil {
+					c.Set("Content-Location", "/api/products/"+product.Slug)
+				}
+			}
+		}
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return c.Status(404).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Product not found",
+			})
+		}
+		// Log the actual error for debugging with more details
+		fmt.Printf("❌ Error scanning product %v: %v\n", identifier, err)
+		fmt.Printf("   Error type: %T\n", err)
+		// Return error but don't expose internal details in production
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Failed to retrieve product",
+		})
+	}
+
+	// SECURITY: Enforce visibility rules
+	switch h.productVisibleTo(userID, product.Status, product.SellerID) {
+	case 410:
+		return c.Status(410).JSON(models.APIResponse{
+			Success: false,
+			Error:   removedListingMessage,
+		})
+	case 404:
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Product not found",
+		})
+	case 403:
+		return c.Status(403).JSON(models.APIResponse{
+			Success: false,
+			Error:   "This item is no longer available",
+		})
+	}
+
+	// Counterfeit signals are moderation data: only the seller and admins see them
+	if userID != 0 && (userID == product.SellerID || isAdminUser(h.db, userID)) {
+		h.attachCounterfeitSignals(&product)
+	}
+	if userID != 0 && userID == product.SellerID {
+		h.attachPremiumWindow(&product)
+	}
+
 	// Compute vote counts for this product
-	var underCount int
-	var overCount int
-	_ = h.db.QueryRow("SELECT COALESCE(SUM(CASE WHEN vote = 'under' THEN 1 ELSE 0 END),0), COALESCE(SUM(CASE WHEN vote = 'over' THEN 1 ELSE 0 END),0) FROM product_votes WHERE product_id = ?", product.ID).Scan(&underCount, &overCount)
+	votes, _ := productVoteCounts(h.db, product.ID)
 
 	// Find current user's vote, if authenticated
 	var userVote string
@@ -872,17 +1051,34 @@ func (h *ProductHandler) GetProduct(c *fiber.Ctx) error {
 		}
 	}
 
-	return c.JSON(models.APIResponse{
+	return jsonWithETag(c, models.APIResponse{
 		Success: true,
 		Data: fiber.Map{
 			"product":   product,
-			"votes":     fiber.Map{"under": underCount, "over": overCount},
+			"votes":     votes,
 			"user_vote": userVote,
 		},
 	})
 }
 
-// VoteProduct lets an authenticated user mark a product as under- or overpriced
+// attachCounterfeitSignals loads the stored counterfeit confidence and flags for a product
+func (h *ProductHandler) attachCounterfeitSignals(product *models.Product) {
+	var confidence sql.NullFloat64
+	var flags models.StringArray
+	err := h.db.QueryRow("SELECT counterfeit_confidence, counterfeit_flags FROM products WHERE id = ?", product.ID).Scan(&confidence, &flags)
+	if err != nil {
+		log.Printf("Warning: failed to load counterfeit signals for product %d: %v", product.ID, err)
+		return
+	}
+	if confidence.Valid {
+		v := confidence.Float64
+		product.CounterfeitConfidence = &v
+	}
+	product.CounterfeitFlags = flags
+}
+
+// VoteProduct lets an authenticated user mark a product as under- or overpriced.
+// A vote of "none" (or empty) clears the user's existing vote.
 func (h *ProductHandler) VoteProduct(c *fiber.Ctx) error {
 	userID, ok := middleware.GetUserIDFromContext(c)
 	if !ok {
@@ -900,9 +1096,9 @@ func (h *ProductHandler) VoteProduct(c *fiber.Ctx) error {
 	if err := c.BodyParser(&body); err != nil {
 		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid request body"})
 	}
-	v := strings.ToLower(body.Vote)
-	if v != "under" && v != "over" {
-		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "vote must be 'under' or 'over'"})
+	v, err := normalizeVote(body.Vote)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: err.Error()})
 	}
 
 	// Ensure product exists and has a price (only allow voting for items with price)
@@ -914,22 +1110,18 @@ func (h *ProductHandler) VoteProduct(c *fiber.Ctx) error {
 		}
 		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to check product"})
 	}
-	if !price.Valid {
+	if !price.Valid && v != "" {
 		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Voting allowed only for items with a price"})
 	}
 
-	// Insert or update vote (unique constraint on product_id,user_id)
-	_, err = h.db.Exec("INSERT INTO product_votes (product_id, user_id, vote, created_at) VALUES (?, ?, ?, CURRENT_TIMESTAMP) ON DUPLICATE KEY UPDATE vote = VALUES(vote), created_at = VALUES(created_at)", productID, userID, v)
+	// Insert, update or remove the vote and read the new tally
+	votes, err := recordProductVote(h.db, productID, userID, v)
 	if err != nil {
 		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to record vote"})
 	}
+	publishProductVotes(productID, votes)
 
-	// Return updated counts
-	var underCount int
-	var overCount int
-	_ = h.db.QueryRow("SELECT COALESCE(SUM(CASE WHEN vote = 'under' THEN 1 ELSE 0 END),0), COALESCE(SUM(CASE WHEN vote = 'over' THEN 1 ELSE 0 END),0) FROM product_votes WHERE product_id = ?", productID).Scan(&underCount, &overCount)
-
-	return c.JSON(models.APIResponse{Success: true, Data: fiber.Map{"votes": fiber.Map{"under": underCount, "over": overCount}, "user_vote": v}})
+	return c.JSON(models.APIResponse{Success: true, Data: fiber.Map{"votes": votes, "user_vote": v}})
 }
 
 // UpdateProduct updates a product (only by seller)
@@ -952,7 +1144,7 @@ func (h *ProductHandler) UpdateProduct(c *fiber.Ctx) error {
 
 	// Check if user owns the product and get its current state
 	var p models.Product
-	err = h.db.QueryRow("SELECT seller_id, status, price, `condition` FROM products WHERE id = ?", productID).Scan(&p.SellerID, &p.Status, &p.Price, &p.Condition)
+	err = h.db.QueryRow("SELECT seller_id, status, price, `condition`, COALESCE(currency, 'PHP'), version, COALESCE(premium, FALSE) FROM products WHERE id = ?", productID).Scan(&p.SellerID, &p.Status, &p.Price, &p.Condition, &p.Currency, &p.Version, &p.Premium)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return c.Status(404).JSON(models.APIResponse{
@@ -981,6 +1173,16 @@ func (h *ProductHandler) UpdateProduct(c *fiber.Ctx) error {
 		})
 	}
 
+	// The write below only lands on the version the client saw (or, without one, the
+	// version read above), so a concurrent edit or trade lock is never overwritten
+	expectedVersion := p.Version
+	if updateData.Version != nil {
+		expectedVersion = *updateData.Version
+	}
+	if expectedVersion != p.Version {
+		return productChangedResponse(c, p.Version)
+	}
+
 	// Prevent editing of products that are already sold or traded
 	if p.Status == "sold" || p.Status == "traded" {
 		return c.Status(403).JSON(models.APIResponse{
@@ -988,10 +1190,54 @@ func (h *ProductHandler) UpdateProduct(c *fiber.Ctx) error {
 			Error:   "Cannot edit a product that has been sold or traded",
 		})
 	}
+	if p.Status == "removed" {
+		return c.Status(403).JSON(models.APIResponse{
+			Success: false,
+			Error:   removedListingMessage,
+		})
+	}
+	if updateData.Status != nil && *updateData.Status == "removed" {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Only moderators can remove a listing; delete it instead",
+		})
+	}
+	// Premium placement is bought, not set; it can only be given up early
+	if updateData.Premium != nil && *updateData.Premium && !p.Premium {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Use POST /api/products/:id/premium to buy premium placement",
+		})
+	}
+	// Going live has to pass the publish checks, and a live listing can't slip back into drafts
+	if updateData.Status != nil && *updateData.Status != p.Status && (p.Status == "draft" || *updateData.Status == "draft") {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Use POST /api/products/:id/publish to publish a draft; published listings cannot return to draft",
+		})
+	}
+
+	if updateData.Price != nil && *updateData.Price < 0 {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "price must not be negative",
+		})
+	}
+	if updateData.Currency != nil {
+		currency, ok := config.NormalizeCurrency(*updateData.Currency)
+		if !ok {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Error:   "currency must be one of " + strings.Join(config.SupportedCurrencies(), ", "),
+			})
+		}
+		updateData.Currency = &currency
+	}
 
 	// Build update query dynamically
 	query := "UPDATE products SET updated_at = CURRENT_TIMESTAMP"
 	var args []interface{}
+	var previousImages, nextImages []string
 
 	if updateData.Title != nil {
 		query += ", title = ?"
@@ -1022,6 +1268,8 @@ func (h *ProductHandler) UpdateProduct(c *fiber.Ctx) error {
 			}
 			safeList = append(safeList, u)
 		}
+		previousImages = productImageURLs(h.db, productID)
+		nextImages = safeList
 		// Marshal safeList to JSON string to store
 		imgJSON, _ := json.Marshal(safeList)
 		query += ", image_urls = ?"
@@ -1051,10 +1299,14 @@ func (h *ProductHandler) UpdateProduct(c *fiber.Ctx) error {
 		query += ", `condition` = ?"
 		args = append(args, *updateData.Condition)
 	}
+	if updateData.Currency != nil {
+		query += ", currency = ?"
+		args = append(args, *updateData.Currency)
+	}
 	// bidding_type column doesn't exist in database, so skip it
 
-	// Recalculate suggested value if price or condition changed
-	if updateData.Price != nil || updateData.Condition != nil {
+	// Recalculate suggested value if price, condition or currency changed
+	if updateData.Price != nil || updateData.Condition != nil || updateData.Currency != nil {
 		newPrice := p.Price
 		if updateData.Price != nil {
 			newPrice = updateData.Price
@@ -1070,7 +1322,12 @@ func (h *ProductHandler) UpdateProduct(c *fiber.Ctx) error {
 			priceValue = *newPrice
 		}
 
-		newSuggestedValue := calculateSuggestedValue(priceValue, newCondition)
+		newCurrency := p.Currency
+		if updateData.Currency != nil {
+			newCurrency = *updateData.Currency
+		}
+
+		newSuggestedValue := calculateSuggestedValue(priceValue, newCondition, newCurrency)
 		query += ", suggested_value = ?"
 		args = append(args, newSuggestedValue)
 	}
@@ -1080,23 +1337,75 @@ func (h *ProductHandler) UpdateProduct(c *fiber.Ctx) error {
 		return c.JSON(models.APIResponse{
 			Success: true,
 			Message: "No fields to update",
+			Data:    fiber.Map{"version": p.Version},
 		})
 	}
 
-	query += " WHERE id = ?"
-	args = append(args, productID)
+	query += ", version = version + 1 WHERE id = ? AND version = ?"
+	args = append(args, productID, expectedVersion)
+
+	// The status history entry commits with the change it describes
+	tx, err := h.db.Begin()
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Failed to update product",
+		})
+	}
+	defer tx.Rollback()
 
-	_, err = h.db.Exec(query, args...)
+	result, err := tx.Exec(query, args...)
 	if err != nil {
 		return c.Status(500).JSON(models.APIResponse{
 			Success: false,
 			Error:   "Failed to update product",
 		})
 	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		var current int
+		if err := tx.QueryRow("SELECT version FROM products WHERE id = ?", productID).Scan(&current); err != nil {
+			return c.Status(500).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Failed to update product",
+			})
+		}
+		return productChangedResponse(c, current)
+	}
+
+	if updateData.Status != nil {
+		if err := services.RecordProductStatusChange(tx, productID, p.Status, *updateData.Status, userID, "updated by owner"); err != nil {
+			return c.Status(500).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Failed to update product",
+			})
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Failed to update product",
+		})
+	}
+
+	// Drop image files this edit removed, unless another listing shares them
+	if updateData.ImageURLs != nil {
+		releaseUploads(h.db, h.files, removedURLs(previousImages, nextImages))
+	}
 
 	return c.JSON(models.APIResponse{
 		Success: true,
 		Message: "Product updated successfully",
+		Data:    fiber.Map{"version": expectedVersion + 1},
+	})
+}
+
+// productChangedResponse refuses an update made against an outdated version, returning
+// the current one so the client knows what to reload
+func productChangedResponse(c *fiber.Ctx, current int) error {
+	return c.Status(409).JSON(models.APIResponse{
+		Success: false,
+		Error:   "Product changed, please refresh",
+		Data:    fiber.Map{"version": current},
 	})
 }
 
@@ -1120,7 +1429,8 @@ func (h *ProductHandler) DeleteProduct(c *fiber.Ctx) error {
 
 	// Check if user owns the product
 	var sellerID int
-	err = h.db.QueryRow("SELECT seller_id FROM products WHERE id = ?", productID).Scan(&sellerID)
+	var title, status string
+	err = h.db.QueryRow("SELECT seller_id, title, status FROM products WHERE id = ?", productID).Scan(&sellerID, &title, &status)
 	if err != nil {
 		return c.Status(404).JSON(models.APIResponse{
 			Success: false,
@@ -1134,6 +1444,13 @@ func (h *ProductHandler) DeleteProduct(c *fiber.Ctx) error {
 			Error:   "You can only delete your own products",
 		})
 	}
+	// Deleting would cascade away the status history kept as evidence of the takedown
+	if status == "removed" && !isAdminUser(h.db, userID) {
+		return c.Status(403).JSON(models.APIResponse{
+			Success: false,
+			Error:   removedListingMessage,
+		})
+	}
 
 	// Check if product has orders
 	var orderCount int
@@ -1145,14 +1462,39 @@ func (h *ProductHandler) DeleteProduct(c *fiber.Ctx) error {
 		})
 	}
 
-	// Delete the product
-	_, err = h.db.Exec("DELETE FROM products WHERE id = ?", productID)
+	// Delete the product. Its trades keep their snapshots, so open ones are closed first
+	// rather than left pointing at nothing.
+	images := productImageURLs(h.db, productID)
+	tx, err := h.db.Begin()
 	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to delete product"})
+	}
+	defer tx.Rollback()
+	trades, err := closeTradesForProduct(tx, productID, userID, fmt.Sprintf("listing #%d deleted by its seller", productID))
+	if err != nil {
+		log.Printf("Failed to close trades for deleted product %d: %v", productID, err)
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to close open trades"})
+	}
+	if _, err := tx.Exec("DELETE FROM products WHERE id = ?", productID); err != nil {
 		return c.Status(500).JSON(models.APIResponse{
 			Success: false,
 			Error:   "Failed to delete product",
 		})
 	}
+	if err := tx.Commit(); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to delete product"})
+	}
+	releaseUploads(h.db, h.files, images)
+	for _, t := range trades {
+		counterparty := t.BuyerID
+		if counterparty == sellerID {
+			counterparty = t.SellerID
+		}
+		notify(h.db, counterparty, "trade_update", fmt.Sprintf("A trade was %s because \"%s\" was deleted by its seller", t.To, title), models.NotificationRefTrade, t.ID)
+		publishToUser(t.BuyerID, sseEvent{Type: "trade_updated", Data: fiber.Map{"trade_id": t.ID, "status": t.To}})
+		publishToUser(t.SellerID, sseEvent{Type: "trade_updated", Data: fiber.Map{"trade_id": t.ID, "status": t.To}})
+		alertBackInStock(h.db, t.Unlocked)
+	}
 
 	return c.JSON(models.APIResponse{
 		Success: true,
@@ -1170,13 +1512,15 @@ func (h *ProductHandler) GetUserProducts(c *fiber.Ctx) error {
 		})
 	}
 
-	page, _ := strconv.Atoi(c.Query("page", "1"))
-	limit, _ := strconv.Atoi(c.Query("limit", "10"))
-	offset := (page - 1) * limit
+	pg, err := parsePaginationDefault(c, 10)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: err.Error()})
+	}
+	page, limit, offset := pg.Page, pg.Limit, pg.Offset
 
 	// Get total count
 	var total int
-	err = h.db.QueryRow("SELECT COUNT(*) FROM products WHERE seller_id = ?", userID).Scan(&total)
+	err = h.db.QueryRow("SELECT COUNT(*) FROM products WHERE seller_id = ? AND status NOT IN ('draft', 'removed')", userID).Scan(&total)
 	if err != nil {
 		return c.Status(500).JSON(models.APIResponse{
 			Success: false,
@@ -1186,7 +1530,7 @@ func (h *ProductHandler) GetUserProducts(c *fiber.Ctx) error {
 
 	// Get products (use image_urls)
 	active := c.Query("active", "") == "true"
-	where := "WHERE p.seller_id = ?"
+	where := "WHERE p.seller_id = ? AND p.status NOT IN ('draft', 'removed')"
 	if active {
 		where += " AND p.status = 'available'"
 	}
@@ -1232,10 +1576,7 @@ func (h *ProductHandler) GetUserProducts(c *fiber.Ctx) error {
 
 		// Parse image URLs from JSON
 		if imageURLsJSONStr != "" {
-			var imageURLs []string
-			if err := json.Unmarshal([]byte(imageURLsJSONStr), &imageURLs); err == nil {
-				product.ImageURLs = models.StringArray(imageURLs)
-			}
+			product.ImageURLs = parseImageURLs(product.ID, imageURLsJSONStr)
 		}
 
 		products = append(products, product)
@@ -1254,3 +1595,111 @@ func (h *ProductHandler) GetUserProducts(c *fiber.Ctx) error {
 		},
 	})
 }
+
+// GetProductStatusHistory returns the status-change timeline for a product (owner/admin only)
+func (h *ProductHandler) GetProductStatusHistory(c *fiber.Ctx) error {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "User not authenticated"})
+	}
+
+	productID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid product ID"})
+	}
+
+	var sellerID int
+	err = h.db.QueryRow("SELECT seller_id FROM products WHERE id = ?", productID).Scan(&sellerID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return c.Status(404).JSON(models.APIResponse{Success: false, Error: "Product not found"})
+		}
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to retrieve product"})
+	}
+	if sellerID != userID && !isAdminUser(h.db, userID) {
+		return c.Status(403).JSON(models.APIResponse{Success: false, Error: "Not authorized to view this product's history"})
+	}
+
+	rows, err := h.db.Query(`
+		SELECT h.id, h.product_id, h.from_status, h.to_status, h.actor_id, u.name, h.reason, h.created_at
+		FROM product_status_history h
+		LEFT JOIN users u ON u.id = h.actor_id
+		WHERE h.product_id = ?
+		ORDER BY h.created_at ASC, h.id ASC
+	`, productID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to fetch product history"})
+	}
+	defer rows.Close()
+
+	history := []models.ProductStatusChange{}
+	for rows.Next() {
+		var entry models.ProductStatusChange
+		if err := rows.Scan(&entry.ID, &entry.ProductID, &entry.FromStatus, &entry.ToStatus, &entry.ActorID, &entry.ActorName, &entry.Reason, &entry.CreatedAt); err != nil {
+			continue
+		}
+		history = append(history, entry)
+	}
+
+	return c.JSON(models.APIResponse{Success: true, Data: history})
+}
+
+// productStatsCache holds the last public feed stats so the endpoint stays cheap
+var productStatsCache struct {
+	sync.Mutex
+	data      fiber.Map
+	expiresAt time.Time
+}
+
+// GetProductStats returns public counts of available listings, by top category and by condition.
+// Results are cached for PRODUCT_STATS_CACHE_TTL (default 60s).
+func (h *ProductHandler) GetProductStats(c *fiber.Ctx) error {
+	// The lock only guards the cached value; the queries run without it so a slow
+	// refresh never queues every other request behind it
+	productStatsCache.Lock()
+	cached, fresh := productStatsCache.data, time.Now().Before(productStatsCache.expiresAt)
+	productStatsCache.Unlock()
+	if cached != nil && fresh {
+		return c.JSON(models.APIResponse{Success: true, Data: cached})
+	}
+
+	// Listings of sellers on vacation are hidden from the feed, so they are not counted either
+	const availableFilter = "status = 'available' AND EXISTS (SELECT 1 FROM users u WHERE u.id = products.seller_id AND " + sellerNotAwayClause + ")"
+
+	var total int
+	if err := h.db.QueryRow("SELECT COUNT(*) FROM products WHERE " + availableFilter).Scan(&total); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to fetch product stats"})
+	}
+
+	categoryBuckets, err := groupProductCounts(c.UserContext(), h.db, "category", "Uncategorized", availableFilter, 10)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to fetch category stats"})
+	}
+	conditionBuckets, err := groupProductCounts(c.UserContext(), h.db, "`condition`", "Not Specified", availableFilter, 0)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to fetch condition stats"})
+	}
+
+	categories := make([]fiber.Map, 0, len(categoryBuckets))
+	for _, b := range categoryBuckets {
+		categories = append(categories, fiber.Map{"category": b.Label, "count": b.Count})
+	}
+	conditions := make([]fiber.Map, 0, len(conditionBuckets))
+	for _, b := range conditionBuckets {
+		conditions = append(conditions, fiber.Map{"condition": b.Label, "count": b.Count})
+	}
+
+	now := time.Now()
+	data := fiber.Map{
+		"total_available": total,
+		"categories":      categories,
+		"conditions":      conditions,
+		"generated_at":    now,
+	}
+	productStatsCache.Lock()
+	productStatsCache.data = data
+	productStatsCache.expiresAt = now.Add(config.GetEnvDuration("PRODUCT_STATS_CACHE_TTL", time.Minute))
+	productStatsCache.Unlock()
+
+	return c.JSON(models.APIResponse{Success: true, Data: data})
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/middleware"
+	"github.com/xashathebest/clovia/models"
+)
+
+// uniqueSlug derives a slug from title that no other product uses now and no product used
+// before. Retired slugs stay reserved so old links keep resolving to the product that had
+// them. productID is the product being renamed, so it can keep its own slug; 0 for a new one.
+func uniqueSlug(db queryRower, title string, productID int) string {
+	slug := generateSlug(title)
+	baseSlug := slug
+	for counter := 1; ; counter++ {
+		var exists int
+		err := db.QueryRow(`SELECT (SELECT COUNT(*) FROM products WHERE slug = ? AND id <> ?)
+			+ (SELECT COUNT(*) FROM product_slug_history WHERE slug = ?)`, slug, productID, slug).Scan(&exists)
+		if err != nil || exists == 0 {
+			return slug
+		}
+		slug = fmt.Sprintf("%s-%d", baseSlug, counter)
+	}
+}
+
+// generatedSlugSuffix matches what generateSlug and uniqueSlug add after the title part
+var generatedSlugSuffix = regexp.MustCompile(`^-[0-9a-f]{8}(-[0-9]+)?$`)
+
+// slugFromTitle reports whether slug was generated from title, so regenerating it would
+// only swap the random suffix
+func slugFromTitle(slug, title string) bool {
+	base := slugBase(title)
+	return strings.HasPrefix(slug, base) && generatedSlugSuffix.MatchString(slug[len(base):])
+}
+
+// resolveRetiredSlug returns the product that used to be reachable at slug
+func resolveRetiredSlug(db queryRower, slug string) (int, error) {
+	var productID int
+	err := db.QueryRow("SELECT product_id FROM product_slug_history WHERE slug = ?", slug).Scan(&productID)
+	return productID, err
+}
+
+// RegenerateSlug gives a product a fresh slug from its current title, for when the title
+// changed enough that the old one is misleading. The old slug is kept in the history and
+// still resolves in GetProduct. A slug already generated from the current title is kept.
+func (h *ProductHandler) RegenerateSlug(c *fiber.Ctx) error {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "User not authenticated"})
+	}
+	productID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid product ID"})
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to start transaction"})
+	}
+	defer tx.Rollback()
+
+	var sellerID int
+	var title, status string
+	var oldSlug sql.NullString
+	err = tx.QueryRow("SELECT seller_id, title, status, slug FROM products WHERE id = ? FOR UPDATE", productID).
+		Scan(&sellerID, &title, &status, &oldSlug)
+	if err == sql.ErrNoRows {
+		return c.Status(404).JSON(models.APIResponse{Success: false, Error: "Product not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to retrieve product"})
+	}
+	if sellerID != userID {
+		return c.Status(403).JSON(models.APIResponse{Success: false, Error: "Only the owner can update this product"})
+	}
+	if status == "removed" {
+		return c.Status(410).JSON(models.APIResponse{Success: false, Error: removedListingMessage})
+	}
+
+	if oldSlug.Valid && slugFromTitle(oldSlug.String, title) {
+		// The title still maps to the current slug; there is nothing to retire
+		return c.JSON(models.APIResponse{
+			Success: true,
+			Message: "Slug unchanged",
+			Data:    fiber.Map{"product_id": productID, "slug": oldSlug.String, "previous_slug": oldSlug.String},
+		})
+	}
+	slug := uniqueSlug(tx, title, productID)
+	if oldSlug.Valid && oldSlug.String != "" {
+		if _, err := tx.Exec("INSERT INTO product_slug_history (product_id, slug) VALUES (?, ?)", productID, oldSlug.String); err != nil {
+			return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to keep the old link"})
+		}
+	}
+	if _, err := tx.Exec("UPDATE products SET slug = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", slug, productID); err != nil {
+		if isDuplicateEntry(err) {
+			return c.Status(409).JSON(models.APIResponse{Success: false, Error: "Slug was taken, please try again"})
+		}
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to update slug"})
+	}
+	if err := tx.Commit(); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to update slug"})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Slug regenerated",
+		Data:    fiber.Map{"product_id": productID, "slug": slug, "previous_slug": oldSlug.String},
+	})
+}
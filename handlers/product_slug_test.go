@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/xashathebest/clovia/database"
+)
+
+// TestRegenerateSlugKeepsOldLinkWorking renames a listing's slug and opens it by the old one
+func TestRegenerateSlugKeepsOldLinkWorking(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	database.DB = db
+
+	sellerID := createTestUser(t, db, "slug_seller")
+	otherID := createTestUser(t, db, "slug_other")
+	productID := createTestProduct(t, db, sellerID, "Renamed Bike")
+	oldSlug := fmt.Sprintf("old-bike-%d", productID)
+	db.Exec("UPDATE products SET slug = ? WHERE id = ?", oldSlug, productID)
+
+	handler := &ProductHandler{db: db}
+	currentUser := otherID
+	app := newTestApp(&currentUser, func(app *fiber.App) {
+		app.Post("/products/:id/regenerate-slug", handler.RegenerateSlug)
+		app.Get("/products/:id", handler.GetProduct)
+	})
+	path := fmt.Sprintf("/products/%d/regenerate-slug", productID)
+
+	resp, err := app.Test(httptest.NewRequest("POST", path, nil), -1)
+	if err != nil || resp.StatusCode != 403 {
+		t.Fatalf("non-owner: expected 403, got %v (%v)", resp.StatusCode, err)
+	}
+
+	currentUser = sellerID
+	resp, err = app.Test(httptest.NewRequest("POST", path, nil), -1)
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatalf("owner: expected 200, got %v (%v)", resp.StatusCode, err)
+	}
+	var body struct {
+		Data struct {
+			Slug string `json:"slug"`
+		} `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	if body.Data.Slug == "" || body.Data.Slug == oldSlug {
+		t.Fatalf("expected a new slug, got %q", body.Data.Slug)
+	}
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/products/"+oldSlug, nil), -1)
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatalf("old slug: expected 200, got %v (%v)", resp.StatusCode, err)
+	}
+	if got := resp.Header.Get("Content-Location"); got != "/api/products/"+body.Data.Slug {
+		t.Errorf("Content-Location = %q, want the new slug", got)
+	}
+}
+
+func TestSlugFromTitle(t *testing.T) {
+	cases := []struct {
+		slug, title string
+		want        bool
+	}{
+		{"eco-bag-3f8a9d2a", "Eco Bag", true},
+		{"eco-bag-3f8a9d2a-2", "Eco Bag", true},
+		{"eco-bag-3f8a9d2a", "Eco Bag Large", false},
+		{"eco-bag-large-3f8a9d2a", "Eco Bag", false},
+		{"old-bike-12", "Old Bike", false},
+	}
+	for _, tc := range cases {
+		if got := slugFromTitle(tc.slug, tc.title); got != tc.want {
+			t.Errorf("slugFromTitle(%q, %q) = %v, want %v", tc.slug, tc.title, got, tc.want)
+		}
+	}
+}
+
+// TestRegenerateSlugKeepsSlugForUnchangedTitle regenerates without renaming the listing
+func TestRegenerateSlugKeepsSlugForUnchangedTitle(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	database.DB = db
+
+	sellerID := createTestUser(t, db, "slug_keep_seller")
+	productID := createTestProduct(t, db, sellerID, "Unchanged Lamp")
+	slug := generateSlug("Unchanged Lamp")
+	db.Exec("UPDATE products SET slug = ? WHERE id = ?", slug, productID)
+
+	handler := &ProductHandler{db: db}
+	app := newTestApp(&sellerID, func(app *fiber.App) {
+		app.Post("/products/:id/regenerate-slug", handler.RegenerateSlug)
+	})
+	resp, err := app.Test(httptest.NewRequest("POST", fmt.Sprintf("/products/%d/regenerate-slug", productID), nil), -1)
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %v (%v)", resp.StatusCode, err)
+	}
+
+	var current string
+	db.QueryRow("SELECT slug FROM products WHERE id = ?", productID).Scan(&current)
+	if current != slug {
+		t.Errorf("slug = %q, want it kept as %q", current, slug)
+	}
+	var retired int
+	db.QueryRow("SELECT COUNT(*) FROM product_slug_history WHERE product_id = ?", productID).Scan(&retired)
+	if retired != 0 {
+		t.Errorf("retired %d slugs, want 0", retired)
+	}
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/models"
+)
+
+const (
+	// suggestMinRunes is how much must be typed before suggestions are looked up; a
+	// single letter matches too much of the catalog to be useful or cheap
+	suggestMinRunes = 2
+	suggestMaxRunes = 50
+	suggestLimit    = 10
+)
+
+// likeEscaper escapes LIKE wildcards so typed % and _ match literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// suggestSources are the normalized product columns suggestions are drawn from. column and
+// display must be trusted SQL fragments.
+var suggestSources = []struct {
+	Type, Column, Display string
+}{
+	{"title", "p.title_normalized", "p.title"},
+	{"category", "p.category_normalized", "p.category"},
+	{"location", "p.location_normalized", "p.location"},
+}
+
+// SuggestProducts returns up to 10 typeahead suggestions for ?q=: available listing titles,
+// categories and locations that start with the typed text, most common first. It is a
+// prefix match on indexed lowercase columns, not a full search; use GetProducts for that.
+func (h *ProductHandler) SuggestProducts(c *fiber.Ctx) error {
+	q := strings.ToLower(strings.TrimSpace(c.Query("q")))
+	if len([]rune(q)) > suggestMaxRunes {
+		q = string([]rune(q)[:suggestMaxRunes])
+	}
+	suggestions := []models.ProductSuggestion{}
+	if len([]rune(q)) >= suggestMinRunes {
+		prefix := likeEscaper.Replace(q) + "%"
+		groups := make([][]models.ProductSuggestion, 0, len(suggestSources))
+		for _, src := range suggestSources {
+			group, err := suggestFrom(c.UserContext(), h.db, src.Type, src.Column, src.Display, prefix)
+			if err != nil {
+				return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to load suggestions"})
+			}
+			groups = append(groups, group)
+		}
+		suggestions = rankSuggestions(suggestLimit, groups...)
+	}
+
+	c.Set(fiber.HeaderCacheControl, "public, max-age=60")
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    fiber.Map{"query": q, "suggestions": suggestions},
+	})
+}
+
+// suggestFrom counts available listings per distinct value of column starting with prefix
+func suggestFrom(ctx context.Context, db *sql.DB, typ, column, display, prefix string) ([]models.ProductSuggestion, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT MIN(TRIM(`+display+`)), COUNT(*) AS n
+		FROM products p
+		JOIN users u ON u.id = p.seller_id
+		WHERE `+column+` LIKE ? AND p.status = 'available' AND `+sellerNotAwayClause+`
+		GROUP BY `+column+`
+		ORDER BY n DESC, `+column+`
+		LIMIT ?`, prefix, suggestLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var group []models.ProductSuggestion
+	for rows.Next() {
+		s := models.ProductSuggestion{Type: typ}
+		if err := rows.Scan(&s.Text, &s.Count); err != nil {
+			return nil, err
+		}
+		group = append(group, s)
+	}
+	return group, rows.Err()
+}
+
+// rankSuggestions merges the groups by count, most common first, dropping any text already
+// suggested under another type, and keeps the first limit. Ties keep group order, so titles
+// come before categories and categories before locations.
+func rankSuggestions(limit int, groups ...[]models.ProductSuggestion) []models.ProductSuggestion {
+	merged := []models.ProductSuggestion{}
+	for _, g := range groups {
+		merged = append(merged, g...)
+	}
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].Count > merged[j].Count })
+
+	ranked := []models.ProductSuggestion{}
+	seen := map[string]bool{}
+	for _, s := range merged {
+		key := strings.ToLower(s.Text)
+		if s.Text == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		ranked = append(ranked, s)
+		if len(ranked) == limit {
+			break
+		}
+	}
+	return ranked
+}
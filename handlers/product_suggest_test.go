@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/models"
+)
+
+// TestRankSuggestionsMergesByCount checks ordering, cross-type dedupe and the cap
+func TestRankSuggestionsMergesByCount(t *testing.T) {
+	titles := []models.ProductSuggestion{{Type: "title", Text: "Calculator", Count: 2}, {Type: "title", Text: "Camera", Count: 1}}
+	categories := []models.ProductSuggestion{{Type: "category", Text: "Calculators", Count: 5}, {Type: "category", Text: "camera", Count: 4}}
+	locations := []models.ProductSuggestion{{Type: "location", Text: "Campus B", Count: 2}}
+
+	got := rankSuggestions(3, titles, categories, locations)
+	want := []string{"category:Calculators", "category:camera", "title:Calculator"}
+	if len(got) != len(want) {
+		t.Fatalf("got %d suggestions, want %d: %+v", len(got), len(want), got)
+	}
+	for i, s := range got {
+		if s.Type+":"+s.Text != want[i] {
+			t.Errorf("suggestion %d = %s:%s, want %s", i, s.Type, s.Text, want[i])
+		}
+	}
+}
+
+// TestSuggestProductsPrefixMatch checks that only available listings starting with q are suggested
+func TestSuggestProductsPrefixMatch(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	seller := createTestUser(t, db, "Suggest Seller")
+	prefix := fmt.Sprintf("zq%d", time.Now().UnixNano())
+	for i := 0; i < 2; i++ {
+		id := createTestProduct(t, db, seller, prefix+" Lamp")
+		t.Cleanup(func() { db.Exec("DELETE FROM products WHERE id = ?", id) })
+	}
+	sold := createTestProduct(t, db, seller, prefix+" Sold Desk")
+	t.Cleanup(func() { db.Exec("DELETE FROM products WHERE id = ?", sold) })
+	if _, err := db.Exec("UPDATE products SET status = 'sold' WHERE id = ?", sold); err != nil {
+		t.Fatalf("mark sold: %v", err)
+	}
+	inside := createTestProduct(t, db, seller, "Lamp "+prefix)
+	t.Cleanup(func() { db.Exec("DELETE FROM products WHERE id = ?", inside) })
+
+	h := &ProductHandler{db: db}
+	viewer := 0
+	app := newTestApp(&viewer, func(app *fiber.App) { app.Get("/products/suggest", h.SuggestProducts) })
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/products/suggest?q="+url.QueryEscape(" "+prefix[:len(prefix)-3]), nil))
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	var body struct {
+		Data struct {
+			Suggestions []models.ProductSuggestion `json:"suggestions"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(body.Data.Suggestions) != 1 {
+		t.Fatalf("got %+v, want only the available title", body.Data.Suggestions)
+	}
+	if s := body.Data.Suggestions[0]; s.Type != "title" || s.Text != prefix+" Lamp" || s.Count != 2 {
+		t.Errorf("suggestion = %+v, want title %q with count 2", s, prefix+" Lamp")
+	}
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/products/suggest?q=z", nil))
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	body.Data.Suggestions = nil
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.StatusCode != 200 || len(body.Data.Suggestions) != 0 {
+		t.Errorf("one-letter query: status %d, %d suggestions; want 200 and none", resp.StatusCode, len(body.Data.Suggestions))
+	}
+}
+
+// TestSuggestProductsHiddenListings checks that drafts and removed listings never surface,
+// since status is the only thing that hides a listing from suggestions
+func TestSuggestProductsHiddenListings(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	seller := createTestUser(t, db, "Suggest Hidden Seller")
+	prefix := fmt.Sprintf("zh%d", time.Now().UnixNano())
+	for _, status := range []string{"available", "draft", "removed"} {
+		id := createTestProduct(t, db, seller, prefix+" "+status)
+		t.Cleanup(func() { db.Exec("DELETE FROM products WHERE id = ?", id) })
+		if _, err := db.Exec("UPDATE products SET status = ? WHERE id = ?", status, id); err != nil {
+			t.Fatalf("set status %s: %v", status, err)
+		}
+	}
+
+	group, err := suggestFrom(context.Background(), db, "title", "p.title_normalized", "p.title", likeEscaper.Replace(prefix)+"%")
+	if err != nil {
+		t.Fatalf("suggestFrom: %v", err)
+	}
+	if len(group) != 1 || group[0].Text != prefix+" available" {
+		t.Errorf("got %+v, want only the available listing", group)
+	}
+}
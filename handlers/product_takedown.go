@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/middleware"
+	"github.com/xashathebest/clovia/models"
+	"github.com/xashathebest/clovia/services"
+)
+
+// removedListingMessage is what everyone but an admin sees for a taken-down listing
+const removedListingMessage = "This listing was removed by a moderator"
+
+// closedTrade is an open trade closed because one of its products was taken down or deleted
+type closedTrade struct {
+	ID       int    `json:"trade_id"`
+	BuyerID  int    `json:"-"`
+	SellerID int    `json:"-"`
+	From     string `json:"from_status"`
+	To       string `json:"to_status"`
+	Unlocked []int  `json:"-"`
+}
+
+// takedownTradeStatus is where an open trade ends up when its product is removed: offers
+// nobody accepted yet are declined, agreed trades are cancelled
+func takedownTradeStatus(status string) string {
+	if status == "pending" || status == "countered" {
+		return "declined"
+	}
+	return "cancelled"
+}
+
+// closeTradesForProduct closes every open trade the product is part of, as target or as
+// an offered item, and hands the other products in those trades back to their owners.
+// note is recorded on each trade event and status change.
+func closeTradesForProduct(tx *sql.Tx, productID, actorID int, note string) ([]closedTrade, error) {
+	statusPlaceholders := strings.TrimSuffix(strings.Repeat("?,", len(openTradeStatuses)), ",")
+	args := []interface{}{productID, productID}
+	for _, s := range openTradeStatuses {
+		args = append(args, s)
+	}
+	rows, err := tx.Query(`
+		SELECT id, buyer_id, seller_id, status FROM trades
+		WHERE (target_product_id = ? OR id IN (SELECT trade_id FROM trade_items WHERE product_id = ?))
+		  AND status IN (`+statusPlaceholders+`)
+		FOR UPDATE`, args...)
+	if err != nil {
+		return nil, err
+	}
+	var trades []closedTrade
+	for rows.Next() {
+		var t closedTrade
+		if err := rows.Scan(&t.ID, &t.BuyerID, &t.SellerID, &t.From); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		t.To = takedownTradeStatus(t.From)
+		trades = append(trades, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range trades {
+		t := &trades[i]
+		if _, err := tx.Exec("UPDATE trades SET status = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", t.To, t.ID); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec("INSERT INTO trade_events (trade_id, actor_id, from_status, to_status, note) VALUES (?, ?, ?, ?, ?)", t.ID, actorID, t.From, t.To, note); err != nil {
+			return nil, err
+		}
+		// Only products this trade locked go back on the market
+		locked, err := tx.Query(`
+			SELECT id FROM products
+			WHERE id <> ? AND status = 'locked'
+			  AND (id = (SELECT target_product_id FROM trades WHERE id = ?) OR id IN (SELECT product_id FROM trade_items WHERE trade_id = ?))`,
+			productID, t.ID, t.ID)
+		if err != nil {
+			return nil, err
+		}
+		var unlock []int
+		for locked.Next() {
+			var pid int
+			if err := locked.Scan(&pid); err == nil {
+				unlock = append(unlock, pid)
+			}
+		}
+		locked.Close()
+		for _, pid := range unlock {
+			if _, err := tx.Exec("UPDATE products SET status = 'available', version = version + 1 WHERE id = ?", pid); err != nil {
+				return nil, err
+			}
+			if err := services.RecordProductStatusChange(tx, pid, "locked", "available", actorID, fmt.Sprintf("trade #%d %s: %s", t.ID, t.To, note)); err != nil {
+				return nil, err
+			}
+			t.Unlocked = append(t.Unlocked, pid)
+		}
+	}
+	return trades, nil
+}
+
+// TakedownProduct removes a listing immediately for moderation. Unlike a seller deleting
+// their listing, the product stays in the database as 'removed' (410 for non-admins), its
+// open trades are closed and the other products in them unlocked, and the seller is told why.
+// Body: { "reason": "..." }
+func (h *AdminHandler) TakedownProduct(c *fiber.Ctx) error {
+	adminID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "User not authenticated"})
+	}
+	productID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid product ID"})
+	}
+	var payload struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid request body"})
+	}
+	payload.Reason = strings.TrimSpace(payload.Reason)
+	payload.Reason = services.TruncateRunes(payload.Reason, 255)
+	if payload.Reason == "" {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "A reason is required to take down a listing"})
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to take down listing"})
+	}
+	defer tx.Rollback()
+
+	var sellerID int
+	var status, title string
+	err = tx.QueryRow("SELECT seller_id, status, title FROM products WHERE id = ? FOR UPDATE", productID).Scan(&sellerID, &status, &title)
+	if err == sql.ErrNoRows {
+		return c.Status(404).JSON(models.APIResponse{Success: false, Error: "Product not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to fetch product"})
+	}
+	if status == "removed" {
+		return c.Status(409).JSON(models.APIResponse{Success: false, Error: "Listing is already taken down"})
+	}
+
+	trades, err := closeTradesForProduct(tx, productID, adminID, fmt.Sprintf("listing #%d taken down", productID))
+	if err != nil {
+		log.Printf("Failed to close trades for takedown of product %d: %v", productID, err)
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to close open trades"})
+	}
+	if _, err := tx.Exec("UPDATE products SET status = 'removed', version = version + 1 WHERE id = ?", productID); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to take down listing"})
+	}
+	if err := services.RecordProductStatusChange(tx, productID, status, "removed", adminID, "taken down by moderator: "+payload.Reason); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to record status history"})
+	}
+	details := fiber.Map{"product_id": productID, "from_status": status, "reason": payload.Reason, "trades_closed": trades}
+	if err := recordAdminAction(tx, adminID, "product_takedown", sellerID, details, c.IP()); err != nil {
+		log.Printf("Failed to audit takedown of product %d by admin %d: %v", productID, adminID, err)
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to record moderation"})
+	}
+	if err := tx.Commit(); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to take down listing"})
+	}
+
+	notify(h.db, sellerID, "listing_removed", fmt.Sprintf("Your listing \"%s\" was removed by a moderator: %s", title, payload.Reason), models.NotificationRefProduct, productID)
+	publishToUser(sellerID, sseEvent{Type: "product_removed", Data: fiber.Map{"product_id": productID, "reason": payload.Reason}})
+	for _, t := range trades {
+		counterparty := t.BuyerID
+		if counterparty == sellerID {
+			counterparty = t.SellerID
+		}
+		notify(h.db, counterparty, "trade_update", fmt.Sprintf("A trade was %s because \"%s\" was removed by a moderator", t.To, title), models.NotificationRefTrade, t.ID)
+		publishToUser(t.BuyerID, sseEvent{Type: "trade_updated", Data: fiber.Map{"trade_id": t.ID, "status": t.To}})
+		publishToUser(t.SellerID, sseEvent{Type: "trade_updated", Data: fiber.Map{"trade_id": t.ID, "status": t.To}})
+		alertBackInStock(h.db, t.Unlocked)
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: fmt.Sprintf("Listing taken down; %d open trade(s) closed", len(trades)),
+		Data:    fiber.Map{"product_id": productID, "status": "removed", "trades_closed": trades},
+	})
+}
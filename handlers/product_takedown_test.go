@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/xashathebest/clovia/database"
+)
+
+func TestTakedownTradeStatus(t *testing.T) {
+	for from, want := range map[string]string{
+		"pending":               "declined",
+		"countered":             "declined",
+		"accepted":              "cancelled",
+		"active":                "cancelled",
+		"awaiting_confirmation": "cancelled",
+	} {
+		if got := takedownTradeStatus(from); got != want {
+			t.Errorf("takedownTradeStatus(%q) = %q, want %q", from, got, want)
+		}
+	}
+}
+
+// TestTakedownClosesTradesAndHidesListing removes a listing with an accepted trade on it
+func TestTakedownClosesTradesAndHidesListing(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	database.DB = db
+
+	adminID := createTestUser(t, db, "takedown_admin")
+	db.Exec("UPDATE users SET role = 'admin' WHERE id = ?", adminID)
+	sellerID := createTestUser(t, db, "takedown_seller")
+	buyerID := createTestUser(t, db, "takedown_buyer")
+	target := createTestProduct(t, db, sellerID, "Dangerous Item")
+	offered := createTestProduct(t, db, buyerID, "Innocent Offer")
+	db.Exec("UPDATE products SET status = 'locked' WHERE id IN (?, ?)", target, offered)
+	res, err := db.Exec("INSERT INTO trades (buyer_id, seller_id, target_product_id, status) VALUES (?, ?, ?, 'accepted')", buyerID, sellerID, target)
+	if err != nil {
+		t.Fatalf("insert trade: %v", err)
+	}
+	id64, _ := res.LastInsertId()
+	tradeID := int(id64)
+	t.Cleanup(func() { db.Exec("DELETE FROM trades WHERE id = ?", tradeID) })
+	db.Exec("INSERT INTO trade_items (trade_id, product_id, offered_by) VALUES (?, ?, 'buyer')", tradeID, offered)
+
+	admin := &AdminHandler{db: db}
+	products := NewProductHandler()
+	currentUser := adminID
+	app := newTestApp(&currentUser, func(app *fiber.App) {
+		app.Post("/admin/products/:id/takedown", admin.TakedownProduct)
+		app.Get("/products/:id", products.GetProduct)
+		app.Delete("/products/:id", products.DeleteProduct)
+	})
+	takedown := func(body string) int {
+		currentUser = adminID
+		req := httptest.NewRequest("POST", fmt.Sprintf("/admin/products/%d/takedown", target), strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		return resp.StatusCode
+	}
+
+	if got := takedown(`{"reason":""}`); got != 400 {
+		t.Errorf("takedown without reason: expected 400, got %d", got)
+	}
+	if got := takedown(`{"reason":"prohibited item"}`); got != 200 {
+		t.Fatalf("takedown: expected 200, got %d", got)
+	}
+	if got := takedown(`{"reason":"again"}`); got != 409 {
+		t.Errorf("second takedown: expected 409, got %d", got)
+	}
+
+	var tradeStatus, offeredStatus string
+	db.QueryRow("SELECT status FROM trades WHERE id = ?", tradeID).Scan(&tradeStatus)
+	db.QueryRow("SELECT status FROM products WHERE id = ?", offered).Scan(&offeredStatus)
+	if tradeStatus != "cancelled" || offeredStatus != "available" {
+		t.Errorf("after takedown trade=%q offered product=%q, want cancelled/available", tradeStatus, offeredStatus)
+	}
+
+	for _, tc := range []struct {
+		user int
+		want int
+	}{{sellerID, 410}, {buyerID, 410}, {adminID, 200}} {
+		currentUser = tc.user
+		resp, _ := app.Test(httptest.NewRequest("GET", fmt.Sprintf("/products/%d", target), nil), -1)
+		if resp.StatusCode != tc.want {
+			t.Errorf("GET removed product as user %d: expected %d, got %d", tc.user, tc.want, resp.StatusCode)
+		}
+	}
+
+	currentUser = sellerID
+	resp, _ := app.Test(httptest.NewRequest("DELETE", fmt.Sprintf("/products/%d", target), nil), -1)
+	if resp.StatusCode != 403 {
+		t.Errorf("seller deleting a removed listing: expected 403, got %d", resp.StatusCode)
+	}
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -10,6 +11,7 @@ import (
 	"github.com/xashathebest/clovia/database"
 	"github.com/xashathebest/clovia/middleware"
 	"github.com/xashathebest/clovia/models"
+	"github.com/xashathebest/clovia/services"
 )
 
 // ProductTransactionHandler handles product status updates with race condition protection
@@ -72,8 +74,14 @@ func (h *ProductTransactionHandler) ReserveProduct(productID int, userID int, du
 	return tx.Commit()
 }
 
-// CompleteProductSale marks a product as unavailable with optimistic locking
+// CompleteProductSale marks a product as unavailable with optimistic locking,
+// retrying on deadlock
 func (h *ProductTransactionHandler) CompleteProductSale(productID int, buyerID int) error {
+	return services.WithRetry(func() error { return h.completeProductSaleOnce(productID, buyerID) })
+}
+
+// completeProductSaleOnce runs a single attempt of CompleteProductSale
+func (h *ProductTransactionHandler) completeProductSaleOnce(productID int, buyerID int) error {
 	tx, err := h.db.Begin()
 	if err != nil {
 		return fmt.Errorf("failed to start transaction: %w", err)
@@ -202,6 +210,10 @@ func (h *ProductTransactionHandler) CompleteProductTrade(tradeID int) error {
 		return fmt.Errorf("failed to update trade status: %w", err)
 	}
 
+	if err := services.RecordTradeFee(tx, tradeID); err != nil {
+		return fmt.Errorf("failed to record trade fee: %w", err)
+	}
+
 	return tx.Commit()
 }
 
@@ -287,6 +299,21 @@ func (h *ProductTransactionHandler) PurchaseProduct(c *fiber.Ctx) error {
 		})
 	}
 
+	// Trade-only listings cannot be bought outright
+	var allowBuying, barterOnly bool
+	if err := h.db.QueryRow("SELECT allow_buying, barter_only FROM products WHERE id = ?", req.ProductID).Scan(&allowBuying, &barterOnly); err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Product not found",
+		})
+	}
+	if !models.PurchaseAllowed(allowBuying, barterOnly) {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   models.TradeOnlyMessage,
+		})
+	}
+
 	// First, try to reserve the product for 10 minutes
 	if err := h.ReserveProduct(req.ProductID, userID, 10); err != nil {
 		return c.Status(400).JSON(models.APIResponse{
@@ -299,6 +326,12 @@ func (h *ProductTransactionHandler) PurchaseProduct(c *fiber.Ctx) error {
 	// For now, we'll directly complete the sale
 
 	if err := h.CompleteProductSale(req.ProductID, userID); err != nil {
+		if errors.Is(err, services.ErrTransactionConflict) {
+			return c.Status(409).JSON(models.APIResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+		}
 		return c.Status(400).JSON(models.APIResponse{
 			Success: false,
 			Error:   err.Error(),
@@ -0,0 +1,327 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/config"
+	"github.com/xashathebest/clovia/middleware"
+	"github.com/xashathebest/clovia/models"
+	"github.com/xashathebest/clovia/services"
+)
+
+// productTransferExpiry is how long the recipient has to confirm a transfer
+func productTransferExpiry() time.Duration {
+	return config.GetEnvDuration("PRODUCT_TRANSFER_EXPIRY", 72*time.Hour)
+}
+
+// newTransferToken returns a random hex token for confirming a transfer
+func newTransferToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// productTransferBlocker explains why a product cannot be transferred, or returns ""
+// when it has no open trade (as target or offered item) and no pending order.
+func productTransferBlocker(q queryRower, productID int) (string, error) {
+	statusPlaceholders := strings.TrimSuffix(strings.Repeat("?,", len(openTradeStatuses)), ",")
+	args := []interface{}{productID, productID}
+	for _, s := range openTradeStatuses {
+		args = append(args, s)
+	}
+
+	var tradeID int
+	err := q.QueryRow(`
+		SELECT t.id
+		FROM trades t
+		LEFT JOIN trade_items ti ON ti.trade_id = t.id
+		WHERE (t.target_product_id = ? OR ti.product_id = ?)
+		  AND t.status IN (`+statusPlaceholders+`)
+		LIMIT 1
+	`, args...).Scan(&tradeID)
+	if err == nil {
+		return fmt.Sprintf("Product is part of open trade #%d", tradeID), nil
+	}
+	if err != sql.ErrNoRows {
+		return "", err
+	}
+
+	var pendingOrders int
+	if err := q.QueryRow("SELECT COUNT(*) FROM orders WHERE product_id = ? AND status = 'pending'", productID).Scan(&pendingOrders); err != nil {
+		return "", err
+	}
+	if pendingOrders > 0 {
+		return "Product has pending orders", nil
+	}
+	return "", nil
+}
+
+// TransferProduct starts handing a listing over to another user (owner only).
+// seller_id only changes once the recipient confirms with the transfer token.
+func (h *ProductHandler) TransferProduct(c *fiber.Ctx) error {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "User not authenticated"})
+	}
+
+	productID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid product ID"})
+	}
+
+	var payload models.ProductTransferCreate
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid request body"})
+	}
+
+	var sellerID int
+	var title string
+	err = h.db.QueryRow("SELECT seller_id, title FROM products WHERE id = ?", productID).Scan(&sellerID, &title)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return c.Status(404).JSON(models.APIResponse{Success: false, Error: "Product not found"})
+		}
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to retrieve product"})
+	}
+	if sellerID != userID {
+		return c.Status(403).JSON(models.APIResponse{Success: false, Error: "Only the owner can transfer this product"})
+	}
+
+	// Resolve the recipient by id or by username
+	var toUserID int
+	var toName string
+	switch {
+	case payload.ToUserID > 0:
+		err = h.db.QueryRow("SELECT id, name FROM users WHERE id = ?", payload.ToUserID).Scan(&toUserID, &toName)
+	case normalizeUsername(payload.ToUsername) != "":
+		err = h.db.QueryRow("SELECT id, name FROM users WHERE username = ?", normalizeUsername(payload.ToUsername)).Scan(&toUserID, &toName)
+	default:
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "to_user_id or to_username is required"})
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return c.Status(404).JSON(models.APIResponse{Success: false, Error: "Recipient not found"})
+		}
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to look up recipient"})
+	}
+	if toUserID == userID {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Cannot transfer a product to yourself"})
+	}
+
+	blocker, err := productTransferBlocker(h.db, productID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to check product activity"})
+	}
+	if blocker != "" {
+		return c.Status(409).JSON(models.APIResponse{Success: false, Error: blocker})
+	}
+
+	token, err := newTransferToken()
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to create transfer"})
+	}
+	expiresAt := time.Now().Add(productTransferExpiry())
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to create transfer"})
+	}
+	defer tx.Rollback()
+
+	// Only one pending transfer per product; a new request replaces the old one
+	if _, err := tx.Exec("UPDATE product_transfers SET status = 'cancelled', responded_at = NOW() WHERE product_id = ? AND status = 'pending'", productID); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to create transfer"})
+	}
+	res, err := tx.Exec(
+		"INSERT INTO product_transfers (product_id, from_user_id, to_user_id, token, expires_at) VALUES (?, ?, ?, ?, ?)",
+		productID, userID, toUserID, token, expiresAt,
+	)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to create transfer"})
+	}
+	transferID, _ := res.LastInsertId()
+	if err := tx.Commit(); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to create transfer"})
+	}
+
+	notify(h.db, toUserID, "product_transfer", "A listing is being transferred to you: "+title, models.NotificationRefProduct, productID)
+	publishToUser(toUserID, sseEvent{Type: "product_transfer", Data: fiber.Map{"transfer_id": transferID, "product_id": productID, "status": "pending"}})
+
+	return c.Status(201).JSON(models.APIResponse{
+		Success: true,
+		Message: "Transfer requested; waiting for the recipient to confirm",
+		Data: models.ProductTransfer{
+			ID:           int(transferID),
+			ProductID:    productID,
+			ProductTitle: title,
+			FromUserID:   userID,
+			ToUserID:     toUserID,
+			ToUserName:   toName,
+			Status:       "pending",
+			ExpiresAt:    expiresAt,
+			CreatedAt:    time.Now(),
+		},
+	})
+}
+
+// GetIncomingTransfers lists pending transfers addressed to the current user, with their tokens
+func (h *ProductHandler) GetIncomingTransfers(c *fiber.Ctx) error {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "User not authenticated"})
+	}
+
+	rows, err := h.db.Query(`
+		SELECT pt.id, pt.product_id, p.title, pt.from_user_id, u.name, pt.to_user_id, pt.token, pt.status, pt.expires_at, pt.created_at
+		FROM product_transfers pt
+		JOIN products p ON p.id = pt.product_id
+		JOIN users u ON u.id = pt.from_user_id
+		WHERE pt.to_user_id = ? AND pt.status = 'pending' AND pt.expires_at > NOW()
+		ORDER BY pt.created_at DESC
+	`, userID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to fetch transfers"})
+	}
+	defer rows.Close()
+
+	transfers := []models.ProductTransfer{}
+	for rows.Next() {
+		var t models.ProductTransfer
+		if err := rows.Scan(&t.ID, &t.ProductID, &t.ProductTitle, &t.FromUserID, &t.FromUserName, &t.ToUserID, &t.Token, &t.Status, &t.ExpiresAt, &t.CreatedAt); err != nil {
+			continue
+		}
+		transfers = append(transfers, t)
+	}
+
+	return c.JSON(models.APIResponse{Success: true, Data: transfers})
+}
+
+// AcceptProductTransfer lets the recipient confirm a transfer. Ownership, open trades,
+// pending orders and the recipient's listing limit are re-checked under a row lock.
+func (h *ProductHandler) AcceptProductTransfer(c *fiber.Ctx) error {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "User not authenticated"})
+	}
+	token := c.Params("token")
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to accept transfer"})
+	}
+	defer tx.Rollback()
+
+	var t models.ProductTransfer
+	err = tx.QueryRow(`
+		SELECT id, product_id, from_user_id, to_user_id, status, expires_at
+		FROM product_transfers WHERE token = ? FOR UPDATE
+	`, token).Scan(&t.ID, &t.ProductID, &t.FromUserID, &t.ToUserID, &t.Status, &t.ExpiresAt)
+	if err == sql.ErrNoRows || (err == nil && t.ToUserID != userID) {
+		return c.Status(404).JSON(models.APIResponse{Success: false, Error: "Transfer not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to accept transfer"})
+	}
+	if t.Status != "pending" {
+		return c.Status(409).JSON(models.APIResponse{Success: false, Error: "Transfer is already " + t.Status})
+	}
+	if time.Now().After(t.ExpiresAt) {
+		_, _ = tx.Exec("UPDATE product_transfers SET status = 'expired' WHERE id = ?", t.ID)
+		_ = tx.Commit()
+		return c.Status(410).JSON(models.APIResponse{Success: false, Error: "Transfer has expired"})
+	}
+
+	var sellerID int
+	var status, title string
+	err = tx.QueryRow("SELECT seller_id, status, title FROM products WHERE id = ? FOR UPDATE", t.ProductID).Scan(&sellerID, &status, &title)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to retrieve product"})
+	}
+	if sellerID != t.FromUserID {
+		_, _ = tx.Exec("UPDATE product_transfers SET status = 'cancelled', responded_at = NOW() WHERE id = ?", t.ID)
+		_ = tx.Commit()
+		return c.Status(409).JSON(models.APIResponse{Success: false, Error: "Product owner has changed since the transfer was requested"})
+	}
+
+	blocker, err := productTransferBlocker(tx, t.ProductID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to check product activity"})
+	}
+	if blocker != "" {
+		return c.Status(409).JSON(models.APIResponse{Success: false, Error: blocker})
+	}
+
+	if status == "available" || status == "locked" {
+		active, limit, err := h.checkListingLimit(userID)
+		if err != nil {
+			return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to check listing limit"})
+		}
+		if listingLimitReached(active, limit) {
+			return c.Status(409).JSON(models.APIResponse{Success: false, Error: fmt.Sprintf("You have reached your limit of %d active listings", limit)})
+		}
+	}
+
+	if _, err := tx.Exec("UPDATE products SET seller_id = ?, version = version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = ?", userID, t.ProductID); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to transfer product"})
+	}
+	if _, err := tx.Exec("UPDATE product_transfers SET status = 'accepted', responded_at = NOW() WHERE id = ?", t.ID); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to transfer product"})
+	}
+	if err := services.RecordProductOwnerChange(tx, t.ProductID, status, t.FromUserID, userID, userID); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to record product history"})
+	}
+	if err := tx.Commit(); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to transfer product"})
+	}
+
+	notify(h.db, t.FromUserID, "product_transfer", "Your listing transfer was accepted: "+title, models.NotificationRefProduct, t.ProductID)
+	notify(h.db, userID, "product_transfer", "You now own the listing: "+title, models.NotificationRefProduct, t.ProductID)
+	for _, uid := range []int{t.FromUserID, userID} {
+		publishToUser(uid, sseEvent{Type: "product_transfer", Data: fiber.Map{"transfer_id": t.ID, "product_id": t.ProductID, "status": "accepted"}})
+	}
+
+	return c.JSON(models.APIResponse{Success: true, Message: "Product transferred", Data: fiber.Map{"product_id": t.ProductID, "seller_id": userID}})
+}
+
+// DeclineProductTransfer lets the recipient refuse a pending transfer
+func (h *ProductHandler) DeclineProductTransfer(c *fiber.Ctx) error {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "User not authenticated"})
+	}
+
+	var transferID, productID, fromUserID int
+	err := h.db.QueryRow(
+		"SELECT id, product_id, from_user_id FROM product_transfers WHERE token = ? AND to_user_id = ? AND status = 'pending'",
+		c.Params("token"), userID,
+	).Scan(&transferID, &productID, &fromUserID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return c.Status(404).JSON(models.APIResponse{Success: false, Error: "Transfer not found"})
+		}
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to decline transfer"})
+	}
+
+	res, err := h.db.Exec("UPDATE product_transfers SET status = 'declined', responded_at = NOW() WHERE id = ? AND status = 'pending'", transferID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to decline transfer"})
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return c.Status(409).JSON(models.APIResponse{Success: false, Error: "Transfer is no longer pending"})
+	}
+
+	notify(h.db, fromUserID, "product_transfer", "Your listing transfer was declined", models.NotificationRefProduct, productID)
+	publishToUser(fromUserID, sseEvent{Type: "product_transfer", Data: fiber.Map{"transfer_id": transferID, "product_id": productID, "status": "declined"}})
+
+	return c.JSON(models.APIResponse{Success: true, Message: "Transfer declined"})
+}
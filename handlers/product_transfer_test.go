@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/xashathebest/clovia/database"
+)
+
+// TestProductTransferRequiresRecipientAndIdleProduct covers owner checks, pending orders and the token hand-over
+func TestProductTransferRequiresRecipientAndIdleProduct(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	database.DB = db
+
+	ownerID := createTestUser(t, db, "owner")
+	recipientID := createTestUser(t, db, "recipient")
+	buyerID := createTestUser(t, db, "buyer")
+	productID := createTestProduct(t, db, ownerID, "Transfer Item")
+	defer db.Exec("DELETE FROM products WHERE id = ?", productID)
+
+	handler := NewProductHandler()
+	currentUser := ownerID
+	app := newTestApp(&currentUser, func(app *fiber.App) {
+		app.Post("/products/transfers/:token/accept", handler.AcceptProductTransfer)
+		app.Post("/products/:id/transfer", handler.TransferProduct)
+	})
+	post := func(asUser int, path, body string) int {
+		currentUser = asUser
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		return resp.StatusCode
+	}
+	transferPath := fmt.Sprintf("/products/%d/transfer", productID)
+	body := fmt.Sprintf(`{"to_user_id":%d}`, recipientID)
+
+	if got := post(recipientID, transferPath, body); got != 403 {
+		t.Errorf("non-owner transfer: expected 403, got %d", got)
+	}
+
+	if _, err := db.Exec("INSERT INTO orders (product_id, buyer_id, status) VALUES (?, ?, 'pending')", productID, buyerID); err != nil {
+		t.Fatalf("failed to create order: %v", err)
+	}
+	if got := post(ownerID, transferPath, body); got != 409 {
+		t.Errorf("transfer with pending order: expected 409, got %d", got)
+	}
+	db.Exec("DELETE FROM orders WHERE product_id = ?", productID)
+
+	if got := post(ownerID, transferPath, body); got != 201 {
+		t.Fatalf("transfer: expected 201, got %d", got)
+	}
+	var token string
+	if err := db.QueryRow("SELECT token FROM product_transfers WHERE product_id = ? AND status = 'pending'", productID).Scan(&token); err != nil {
+		t.Fatalf("pending transfer not stored: %v", err)
+	}
+
+	if got := post(buyerID, "/products/transfers/"+token+"/accept", ""); got != 404 {
+		t.Errorf("accept by someone else: expected 404, got %d", got)
+	}
+	if got := post(recipientID, "/products/transfers/"+token+"/accept", ""); got != 200 {
+		t.Fatalf("accept: expected 200, got %d", got)
+	}
+
+	var sellerID int
+	db.QueryRow("SELECT seller_id FROM products WHERE id = ?", productID).Scan(&sellerID)
+	if sellerID != recipientID {
+		t.Errorf("expected seller %d after transfer, got %d", recipientID, sellerID)
+	}
+	var historyRows int
+	db.QueryRow("SELECT COUNT(*) FROM product_status_history WHERE product_id = ? AND reason LIKE 'ownership transferred%'", productID).Scan(&historyRows)
+	if historyRows != 1 {
+		t.Errorf("expected one ownership history entry, got %d", historyRows)
+	}
+}
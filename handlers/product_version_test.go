@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestUpdateProductRejectsStaleVersion saves an edit, then replays it with the version it
+// was based on and expects a conflict carrying the current version
+func TestUpdateProductRejectsStaleVersion(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	seller := createTestUser(t, db, "Version Seller")
+	product := createTestProduct(t, db, seller, "Versioned Lamp")
+	t.Cleanup(func() { db.Exec("DELETE FROM products WHERE id = ?", product) })
+	var version int
+	if err := db.QueryRow("SELECT version FROM products WHERE id = ?", product).Scan(&version); err != nil {
+		t.Fatalf("read version: %v", err)
+	}
+
+	h := &ProductHandler{db: db}
+	app := newTestApp(&seller, func(app *fiber.App) { app.Put("/products/:id", h.UpdateProduct) })
+	update := func(body string) (int, int) {
+		req := httptest.NewRequest("PUT", "/products/"+strconv.Itoa(product), strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		var out struct {
+			Data struct {
+				Version int `json:"version"`
+			} `json:"data"`
+		}
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out.Data.Version
+	}
+
+	stale := `{"title":"Versioned Lamp v2","version":` + strconv.Itoa(version) + `}`
+	if status, got := update(stale); status != 200 || got != version+1 {
+		t.Fatalf("first edit: status %d version %d, want 200 and %d", status, got, version+1)
+	}
+	if status, got := update(stale); status != 409 || got != version+1 {
+		t.Errorf("stale edit: status %d version %d, want 409 and %d", status, got, version+1)
+	}
+
+	// A trade lock bumps the version too, so an edit made before it is refused
+	if _, err := db.Exec("UPDATE products SET status = 'locked', version = version + 1 WHERE id = ?", product); err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	if status, _ := update(`{"status":"available","version":` + strconv.Itoa(version+1) + `}`); status != 409 {
+		t.Errorf("edit racing a lock: status %d, want 409", status)
+	}
+}
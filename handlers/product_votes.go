@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/config"
+	"github.com/xashathebest/clovia/middleware"
+	"github.com/xashathebest/clovia/models"
+)
+
+// SSE subscribers map: productID -> list of channels (viewers of a product page)
+var productStreams = struct {
+	sync.RWMutex
+	m map[int][]chan []byte
+}{m: make(map[int][]chan []byte)}
+
+// voteTally is the under/over price vote count for a product
+type voteTally struct {
+	Under int `json:"under"`
+	Over  int `json:"over"`
+}
+
+// queryRower is satisfied by *sql.DB and *sql.Tx
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// productVoteCounts tallies the current votes for a product
+func productVoteCounts(q queryRower, productID int) (voteTally, error) {
+	var t voteTally
+	err := q.QueryRow(
+		"SELECT COALESCE(SUM(CASE WHEN vote = 'under' THEN 1 ELSE 0 END),0), COALESCE(SUM(CASE WHEN vote = 'over' THEN 1 ELSE 0 END),0) FROM product_votes WHERE product_id = ?",
+		productID,
+	).Scan(&t.Under, &t.Over)
+	return t, err
+}
+
+// recordProductVote upserts a user's vote (or deletes it when vote is empty) and
+// reads the new tally in one transaction.
+// The product row is locked first so concurrent votes on the same product are
+// serialized and each caller sees a tally that includes its own vote.
+func recordProductVote(db *sql.DB, productID, userID int, vote string) (voteTally, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return voteTally{}, err
+	}
+	defer tx.Rollback()
+
+	var id int
+	if err := tx.QueryRow("SELECT id FROM products WHERE id = ? FOR UPDATE", productID).Scan(&id); err != nil {
+		return voteTally{}, err
+	}
+	if vote == "" {
+		_, err = tx.Exec("DELETE FROM product_votes WHERE product_id = ? AND user_id = ?", productID, userID)
+	} else {
+		_, err = tx.Exec(
+			"INSERT INTO product_votes (product_id, user_id, vote, created_at) VALUES (?, ?, ?, CURRENT_TIMESTAMP) ON DUPLICATE KEY UPDATE vote = VALUES(vote), created_at = VALUES(created_at)",
+			productID, userID, vote,
+		)
+	}
+	if err != nil {
+		return voteTally{}, err
+	}
+	tally, err := productVoteCounts(tx, productID)
+	if err != nil {
+		return voteTally{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return voteTally{}, err
+	}
+	return tally, nil
+}
+
+// GetProductVotes returns a product's under/over tally and, for a signed-in caller,
+// their own vote
+func (h *ProductHandler) GetProductVotes(c *fiber.Ctx) error {
+	productID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid product ID"})
+	}
+	var exists bool
+	if err := h.db.QueryRow("SELECT COUNT(*) > 0 FROM products WHERE id = ?", productID).Scan(&exists); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to check product"})
+	}
+	if !exists {
+		return c.Status(404).JSON(models.APIResponse{Success: false, Error: "Product not found"})
+	}
+
+	votes, err := productVoteCounts(h.db, productID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to count votes"})
+	}
+	userVote := ""
+	if userID, ok := middleware.GetUserIDFromContext(c); ok && userID != 0 {
+		_ = h.db.QueryRow("SELECT vote FROM product_votes WHERE product_id = ? AND user_id = ?", productID, userID).Scan(&userVote)
+	}
+	return c.JSON(models.APIResponse{Success: true, Data: fiber.Map{"votes": votes, "user_vote": userVote}})
+}
+
+// publishToProduct sends an event to everyone subscribed to a product's stream
+func publishToProduct(productID int, evt sseEvent) {
+	productStreams.RLock()
+	subs := productStreams.m[productID]
+	productStreams.RUnlock()
+	if len(subs) == 0 {
+		return
+	}
+	payload, _ := json.Marshal(evt)
+	// Only the latest tally matters, so a slow viewer loses the oldest ones
+	for _, ch := range subs {
+		offerSSE(ch, evt.Type, payload, config.SSEOverflowDropOldest)
+	}
+}
+
+// publishProductVotes broadcasts the latest vote tally for a product
+func publishProductVotes(productID int, tally voteTally) {
+	publishToProduct(productID, sseEvent{Type: "product_vote", Data: fiber.Map{"product_id": productID, "votes": tally}})
+}
+
+// StreamProductVotes provides a public SSE stream of product_vote events for one product
+func (h *ProductHandler) StreamProductVotes(c *fiber.Ctx) error {
+	productID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid product ID"})
+	}
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+
+	msgCh := newSSEChannel()
+	if !subscribeProduct(productID, msgCh) {
+		return c.Status(503).JSON(models.APIResponse{Success: false, Error: "Too many viewers on this product's live votes. Please try again later."})
+	}
+
+	// The body writer runs after this handler returns, so unsubscribe from inside it once
+	// a write fails. Heartbeats make a client that left an idle product show up promptly.
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer unsubscribeProduct(productID, msgCh)
+		heartbeat := time.NewTicker(config.GetEnvDuration("SSE_HEARTBEAT_INTERVAL", 25*time.Second))
+		defer heartbeat.Stop()
+		for {
+			select {
+			case b := <-msgCh:
+				w.WriteString("data: ")
+				w.Write(b)
+				w.WriteString("\n\n")
+			case <-heartbeat.C:
+				w.WriteString(": ping\n\n")
+			}
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	})
+	return nil
+}
+
+// subscribeProduct adds a subscriber to a product's stream unless it already has
+// config.ProductStreamSubscribers of them
+func subscribeProduct(productID int, msgCh chan []byte) bool {
+	productStreams.Lock()
+	defer productStreams.Unlock()
+	if len(productStreams.m[productID]) >= config.ProductStreamSubscribers() {
+		return false
+	}
+	productStreams.m[productID] = append(productStreams.m[productID], msgCh)
+	return true
+}
+
+// unsubscribeProduct removes a subscriber channel from a product's stream
+func unsubscribeProduct(productID int, msgCh chan []byte) {
+	productStreams.Lock()
+	defer productStreams.Unlock()
+	subs := productStreams.m[productID]
+	for i, ch := range subs {
+		if ch == msgCh {
+			productStreams.m[productID] = append(subs[:i], subs[i+1:]...)
+			break
+		}
+	}
+	if len(productStreams.m[productID]) == 0 {
+		delete(productStreams.m, productID)
+	}
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/xashathebest/clovia/database"
+)
+
+// TestVoteProductConcurrentVotesTallyCorrectly has two users vote on the same product at once
+func TestVoteProductConcurrentVotesTallyCorrectly(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	database.DB = db
+
+	sellerID := createTestUser(t, db, "seller")
+	voterA := createTestUser(t, db, "voter_a")
+	voterB := createTestUser(t, db, "voter_b")
+	productID := createTestProduct(t, db, sellerID, "Voted Item")
+	defer db.Exec("DELETE FROM products WHERE id = ?", productID)
+
+	handler := NewProductHandler()
+	register := func(app *fiber.App) { app.Post("/products/:id/vote", handler.VoteProduct) }
+	votes := map[int]string{voterA: "under", voterB: "over"}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(votes))
+	for uid, vote := range votes {
+		uid, vote := uid, vote
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			app := newTestApp(&uid, register)
+			req := httptest.NewRequest("POST", fmt.Sprintf("/products/%d/vote", productID), strings.NewReader(`{"vote":"`+vote+`"}`))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if resp.StatusCode != 200 {
+				errs <- fmt.Errorf("user %d: expected 200, got %d", uid, resp.StatusCode)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	tally, err := productVoteCounts(db, productID)
+	if err != nil {
+		t.Fatalf("Failed to count votes: %v", err)
+	}
+	if tally.Under != 1 || tally.Over != 1 {
+		t.Errorf("expected 1 under and 1 over, got %+v", tally)
+	}
+}
+
+// TestPublishProductVotesReachesSubscribers checks product_vote events fan out per product
+func TestPublishProductVotesReachesSubscribers(t *testing.T) {
+	watching := make(chan []byte, 1)
+	other := make(chan []byte, 1)
+	productStreams.Lock()
+	productStreams.m[9001] = append(productStreams.m[9001], watching)
+	productStreams.m[9002] = append(productStreams.m[9002], other)
+	productStreams.Unlock()
+	defer unsubscribeProduct(9001, watching)
+	defer unsubscribeProduct(9002, other)
+
+	publishProductVotes(9001, voteTally{Under: 2, Over: 1})
+
+	select {
+	case b := <-watching:
+		var evt struct {
+			Type string `json:"type"`
+			Data struct {
+				ProductID int       `json:"product_id"`
+				Votes     voteTally `json:"votes"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(b, &evt); err != nil {
+			t.Fatalf("invalid event payload: %v", err)
+		}
+		if evt.Type != "product_vote" || evt.Data.ProductID != 9001 || evt.Data.Votes.Under != 2 || evt.Data.Votes.Over != 1 {
+			t.Errorf("unexpected event: %s", b)
+		}
+	default:
+		t.Fatal("subscriber did not receive product_vote event")
+	}
+
+	select {
+	case b := <-other:
+		t.Errorf("subscriber of another product received %s", b)
+	default:
+	}
+}
+
+// TestVoteProductNoneRemovesVote sets a vote and then clears it with "none"
+func TestVoteProductNoneRemovesVote(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	database.DB = db
+
+	sellerID := createTestUser(t, db, "seller")
+	voterID := createTestUser(t, db, "voter")
+	productID := createTestProduct(t, db, sellerID, "Voted Item")
+	defer db.Exec("DELETE FROM products WHERE id = ?", productID)
+
+	handler := NewProductHandler()
+	app := newTestApp(&voterID, func(app *fiber.App) { app.Post("/products/:id/vote", handler.VoteProduct) })
+	vote := func(v string) map[string]interface{} {
+		req := httptest.NewRequest("POST", fmt.Sprintf("/products/%d/vote", productID), strings.NewReader(`{"vote":"`+v+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("vote %q: %v", v, err)
+		}
+		if resp.StatusCode != 200 {
+			t.Fatalf("vote %q: expected 200, got %d", v, resp.StatusCode)
+		}
+		var body struct {
+			Data map[string]interface{} `json:"data"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		return body.Data
+	}
+
+	vote("over")
+	data := vote("none")
+	if data["user_vote"] != "" {
+		t.Errorf("expected empty user_vote after removal, got %v", data["user_vote"])
+	}
+
+	tally, err := productVoteCounts(db, productID)
+	if err != nil {
+		t.Fatalf("Failed to count votes: %v", err)
+	}
+	if tally.Under != 0 || tally.Over != 0 {
+		t.Errorf("expected no votes after removal, got %+v", tally)
+	}
+}
+
+func TestSubscribeProductCapsViewers(t *testing.T) {
+	t.Setenv("PRODUCT_STREAM_MAX_SUBSCRIBERS", "2")
+	const productID = 9003
+	first, second, third := newSSEChannel(), newSSEChannel(), newSSEChannel()
+	defer unsubscribeProduct(productID, first)
+	defer unsubscribeProduct(productID, second)
+
+	if !subscribeProduct(productID, first) || !subscribeProduct(productID, second) {
+		t.Fatal("subscribers under the cap were refused")
+	}
+	if subscribeProduct(productID, third) {
+		unsubscribeProduct(productID, third)
+		t.Fatal("a subscriber over the cap was accepted")
+	}
+
+	// A viewer leaving frees its slot
+	unsubscribeProduct(productID, first)
+	if !subscribeProduct(productID, third) {
+		t.Error("slot freed by a leaving viewer was not reused")
+	}
+	unsubscribeProduct(productID, third)
+}
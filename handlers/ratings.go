@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/models"
+)
+
+// Trade ratings are whole stars in this range
+const (
+	minRating = 1
+	maxRating = 5
+)
+
+// Price votes a user can cast on a listing; an empty vote clears theirs
+const (
+	voteUnder = "under"
+	voteOver  = "over"
+)
+
+var (
+	errInvalidRating = fmt.Errorf("Rating must be between %d and %d", minRating, maxRating)
+	errInvalidVote   = errors.New("vote must be 'under', 'over' or 'none'")
+)
+
+// validateRating checks a trade rating is a whole star count in range
+func validateRating(rating int) error {
+	if rating < minRating || rating > maxRating {
+		return errInvalidRating
+	}
+	return nil
+}
+
+// normalizeVote canonicalizes a price vote. "none" and blank clear the vote and come
+// back as "".
+func normalizeVote(raw string) (string, error) {
+	v := strings.ToLower(strings.TrimSpace(raw))
+	switch v {
+	case voteUnder, voteOver:
+		return v, nil
+	case "", "none":
+		return "", nil
+	}
+	return "", errInvalidVote
+}
+
+// GetRatingBreakdown returns how many trade ratings of each star a user received, with
+// the total and the average
+func (h *UserHandler) GetRatingBreakdown(c *fiber.Ctx) error {
+	userID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid user ID"})
+	}
+	var exists bool
+	if err := h.db.QueryRow("SELECT COUNT(*) > 0 FROM users WHERE id = ?", userID).Scan(&exists); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to fetch user"})
+	}
+	if !exists {
+		return c.Status(404).JSON(models.APIResponse{Success: false, Error: "User not found"})
+	}
+
+	breakdown, err := loadRatingBreakdown(h.db, userID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to load ratings"})
+	}
+	return c.JSON(models.APIResponse{Success: true, Data: breakdown})
+}
+
+// loadRatingBreakdown counts the ratings a user received on completed trades. Ratings are
+// stored by the rater: buyer_rating is what the buyer gave the seller.
+func loadRatingBreakdown(db *sql.DB, userID int) (models.RatingBreakdown, error) {
+	breakdown := models.RatingBreakdown{UserID: userID, Counts: map[int]int{}}
+	for star := minRating; star <= maxRating; star++ {
+		breakdown.Counts[star] = 0
+	}
+
+	rows, err := db.Query(`
+		SELECT r.rating, COUNT(*) FROM (
+			SELECT CASE WHEN seller_id = ? THEN buyer_rating ELSE seller_rating END AS rating
+			FROM trades
+			WHERE (buyer_id = ? OR seller_id = ?) AND status IN ('completed', 'auto_completed')
+		) r
+		WHERE r.rating BETWEEN ? AND ?
+		GROUP BY r.rating`, userID, userID, userID, minRating, maxRating)
+	if err != nil {
+		return breakdown, err
+	}
+	defer rows.Close()
+
+	sum := 0
+	for rows.Next() {
+		var star, count int
+		if err := rows.Scan(&star, &count); err != nil {
+			return breakdown, err
+		}
+		breakdown.Counts[star] = count
+		breakdown.Total += count
+		sum += star * count
+	}
+	if breakdown.Total > 0 {
+		avg := math.Round(float64(sum)/float64(breakdown.Total)*100) / 100
+		breakdown.Average = &avg
+	}
+	return breakdown, rows.Err()
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import "testing"
+
+func TestValidateRating(t *testing.T) {
+	for _, r := range []int{1, 3, 5} {
+		if err := validateRating(r); err != nil {
+			t.Errorf("validateRating(%d) = %v, want nil", r, err)
+		}
+	}
+	for _, r := range []int{0, -1, 6} {
+		if err := validateRating(r); err != errInvalidRating {
+			t.Errorf("validateRating(%d) = %v, want errInvalidRating", r, err)
+		}
+	}
+}
+
+func TestNormalizeVote(t *testing.T) {
+	cases := map[string]string{" Under ": "under", "OVER": "over", "none": "", "": ""}
+	for raw, want := range cases {
+		if got, err := normalizeVote(raw); err != nil || got != want {
+			t.Errorf("normalizeVote(%q) = %q, %v; want %q", raw, got, err, want)
+		}
+	}
+	if _, err := normalizeVote("fair"); err != errInvalidVote {
+		t.Errorf("normalizeVote(fair) error = %v, want errInvalidVote", err)
+	}
+}
+
+func TestLoadRatingBreakdown(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	sellerID := createTestUser(t, db, "rated_seller")
+	buyerID := createTestUser(t, db, "rating_buyer")
+	for _, rating := range []int{5, 5, 3} {
+		productID := createTestProduct(t, db, sellerID, "Rated Item")
+		res, err := db.Exec("INSERT INTO trades (buyer_id, seller_id, target_product_id, status, buyer_rating) VALUES (?, ?, ?, 'completed', ?)", buyerID, sellerID, productID, rating)
+		if err != nil {
+			t.Fatalf("insert trade: %v", err)
+		}
+		id, _ := res.LastInsertId()
+		t.Cleanup(func() { db.Exec("DELETE FROM trades WHERE id = ?", id) })
+	}
+
+	b, err := loadRatingBreakdown(db, sellerID)
+	if err != nil {
+		t.Fatalf("loadRatingBreakdown: %v", err)
+	}
+	if b.Total != 3 || b.Counts[5] != 2 || b.Counts[3] != 1 || b.Counts[1] != 0 || len(b.Counts) != 5 {
+		t.Errorf("breakdown = %+v, want two 5s and one 3 across all five stars", b)
+	}
+	if b.Average == nil || *b.Average != 4.33 {
+		t.Errorf("average = %v, want 4.33", b.Average)
+	}
+}
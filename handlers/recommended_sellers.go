@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"math"
+	"sort"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/config"
+	"github.com/xashathebest/clovia/middleware"
+	"github.com/xashathebest/clovia/models"
+	"github.com/xashathebest/clovia/services"
+)
+
+// Weights of the recommended-sellers ranking signals; they sum to 1
+const (
+	sellerWeightProximity  = 0.35
+	sellerWeightCategories = 0.30
+	sellerWeightRating     = 0.20
+	sellerWeightResponse   = 0.15
+)
+
+// sellerProximityHalfKm is the distance at which the proximity signal drops to one half
+const sellerProximityHalfKm = 5.0
+
+// sellerSampleListings is how many listings are previewed per recommended seller
+const sellerSampleListings = 3
+
+// sellerCandidate is a seller with available listings, as read for ranking
+type sellerCandidate struct {
+	ID            int
+	Lat, Lon      *float64
+	ResponseScore *float64
+	AverageRating *float64
+	Categories    map[string]int // available listings per category
+}
+
+// buyerTaste is where the buyer is and what they wishlist or save, per category
+type buyerTaste struct {
+	Lat, Lon   *float64
+	Categories map[string]int
+}
+
+// categoryShares turns per-category counts into fractions of the total
+func categoryShares(counts map[string]int) map[string]float64 {
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	shares := make(map[string]float64, len(counts))
+	if total == 0 {
+		return shares
+	}
+	for c, n := range counts {
+		shares[c] = float64(n) / float64(total)
+	}
+	return shares
+}
+
+// scoreSeller rates a candidate for the buyer between 0 and 1. Category overlap is the
+// histogram intersection of the seller's listings and the buyer's saved items. Sellers with
+// no ratings or response history yet get a neutral score for that signal rather than zero.
+func scoreSeller(s sellerCandidate, buyer buyerTaste) (score float64, distanceKm *float64, shared []string) {
+	proximity := 0.0
+	if s.Lat != nil && s.Lon != nil && buyer.Lat != nil && buyer.Lon != nil {
+		d := services.CalculateDistance(*buyer.Lat, *buyer.Lon, *s.Lat, *s.Lon).DistanceKm
+		d = math.Round(d*10) / 10
+		distanceKm = &d
+		proximity = sellerProximityHalfKm / (sellerProximityHalfKm + d)
+	}
+
+	overlap := 0.0
+	sellerShares := categoryShares(s.Categories)
+	for c, want := range categoryShares(buyer.Categories) {
+		if have, ok := sellerShares[c]; ok {
+			overlap += math.Min(have, want)
+			shared = append(shared, c)
+		}
+	}
+	sort.Strings(shared)
+
+	rating := 0.6
+	if s.AverageRating != nil {
+		rating = *s.AverageRating / float64(maxRating)
+	}
+	response := 0.5
+	if s.ResponseScore != nil {
+		response = *s.ResponseScore
+	}
+
+	score = sellerWeightProximity*proximity + sellerWeightCategories*overlap +
+		sellerWeightRating*rating + sellerWeightResponse*response
+	return math.Round(score*1000) / 1000, distanceKm, shared
+}
+
+// loadSellerCandidates reads every seller other than the buyer who has available listings
+// and is not on vacation, with their location, response score and average received rating
+func loadSellerCandidates(db *sql.DB, buyerID int) ([]*sellerCandidate, error) {
+	rows, err := db.Query(`
+		SELECT u.id, u.latitude, u.longitude, u.response_score, COALESCE(p.category, ''), COUNT(*)
+		FROM products p JOIN users u ON u.id = p.seller_id
+		WHERE p.status = 'available' AND p.seller_id <> ? AND `+sellerNotAwayClause+`
+		GROUP BY u.id, u.latitude, u.longitude, u.response_score, COALESCE(p.category, '')`, buyerID)
+	if err != nil {
+		return nil, err
+	}
+	byID := map[int]*sellerCandidate{}
+	var candidates []*sellerCandidate
+	for rows.Next() {
+		var id, count int
+		var lat, lon, response sql.NullFloat64
+		var category string
+		if err := rows.Scan(&id, &lat, &lon, &response, &category, &count); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		s, ok := byID[id]
+		if !ok {
+			s = &sellerCandidate{ID: id, Lat: nullFloatPtr(lat), Lon: nullFloatPtr(lon), ResponseScore: nullFloatPtr(response), Categories: map[string]int{}}
+			byID[id] = s
+			candidates = append(candidates, s)
+		}
+		if category != "" {
+			s.Categories[category] += count
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// buyer_rating is what the buyer gave the seller and vice versa; see loadRatingBreakdown
+	ratings, err := db.Query(`
+		SELECT r.rated_id, AVG(r.rating) FROM (
+			SELECT seller_id AS rated_id, buyer_rating AS rating FROM trades
+			WHERE status IN ('completed', 'auto_completed') AND buyer_rating BETWEEN ? AND ?
+			UNION ALL
+			SELECT buyer_id, seller_rating FROM trades
+			WHERE status IN ('completed', 'auto_completed') AND seller_rating BETWEEN ? AND ?
+		) r GROUP BY r.rated_id`, minRating, maxRating, minRating, maxRating)
+	if err != nil {
+		return nil, err
+	}
+	defer ratings.Close()
+	for ratings.Next() {
+		var id int
+		var avg float64
+		if err := ratings.Scan(&id, &avg); err != nil {
+			return nil, err
+		}
+		if s, ok := byID[id]; ok {
+			avg = math.Round(avg*100) / 100
+			s.AverageRating = &avg
+		}
+	}
+	return candidates, ratings.Err()
+}
+
+// loadBuyerTaste reads the buyer's location and the categories of what they wishlisted or saved
+func loadBuyerTaste(db *sql.DB, buyerID int) (buyerTaste, error) {
+	taste := buyerTaste{Categories: map[string]int{}}
+	var lat, lon sql.NullFloat64
+	if err := db.QueryRow("SELECT latitude, longitude FROM users WHERE id = ?", buyerID).Scan(&lat, &lon); err != nil {
+		return taste, err
+	}
+	taste.Lat, taste.Lon = nullFloatPtr(lat), nullFloatPtr(lon)
+
+	rows, err := db.Query(`
+		SELECT p.category, COUNT(*) FROM (
+			SELECT product_id FROM wishlists WHERE user_id = ?
+			UNION
+			SELECT product_id FROM saved_products WHERE user_id = ?
+			  AND deleted_at IS NULL
+		) w JOIN products p ON p.id = w.product_id
+		WHERE p.category IS NOT NULL AND p.category <> ''
+		GROUP BY p.category`, buyerID, buyerID)
+	if err != nil {
+		return taste, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var category string
+		var count int
+		if err := rows.Scan(&category, &count); err != nil {
+			return taste, err
+		}
+		taste.Categories[category] = count
+	}
+	return taste, rows.Err()
+}
+
+func nullFloatPtr(v sql.NullFloat64) *float64 {
+	if !v.Valid {
+		return nil
+	}
+	f := v.Float64
+	return &f
+}
+
+// GetRecommendedSellers suggests shops to the authenticated buyer, ranked by how close they
+// are, how well their listings match the buyer's wishlist and saved items, their ratings and
+// how quickly they answer chats. Sellers on vacation are left out.
+func (h *UserHandler) GetRecommendedSellers(c *fiber.Ctx) error {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "User not authenticated"})
+	}
+	pg, err := parsePagination(c)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: err.Error()})
+	}
+
+	taste, err := loadBuyerTaste(h.db, userID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to load your preferences"})
+	}
+	candidates, err := loadSellerCandidates(h.db, userID)
+	if err != nil {
+		log.Printf("Failed to load seller candidates for user %d: %v", userID, err)
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to load sellers"})
+	}
+
+	ranked := make([]models.RecommendedSeller, 0, len(candidates))
+	for _, s := range candidates {
+		score, distance, shared := scoreSeller(*s, taste)
+		ranked = append(ranked, models.RecommendedSeller{
+			Seller:           models.UserSearchResult{ID: s.ID},
+			Score:            score,
+			DistanceKm:       distance,
+			SharedCategories: shared,
+			ResponseScore:    s.ResponseScore,
+			AverageRating:    s.AverageRating,
+		})
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].Seller.ID < ranked[j].Seller.ID
+	})
+
+	total := len(ranked)
+	page := []models.RecommendedSeller{}
+	if pg.Offset < total {
+		page = ranked[pg.Offset:min(pg.Offset+pg.Limit, total)]
+	}
+	for i := range page {
+		if err := h.fillRecommendedSeller(&page[i]); err != nil {
+			log.Printf("Warning: failed to load recommended seller %d: %v", page[i].Seller.ID, err)
+		}
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data: models.PaginatedResponse{
+			Data:       page,
+			Total:      total,
+			Page:       pg.Page,
+			Limit:      pg.Limit,
+			TotalPages: (total + pg.Limit - 1) / pg.Limit,
+		},
+	})
+}
+
+// fillRecommendedSeller attaches the public profile card and a few newest listings
+func (h *UserHandler) fillRecommendedSeller(r *models.RecommendedSeller) error {
+	s := &r.Seller
+	err := h.db.QueryRow(`
+		SELECT u.name, COALESCE(u.username, ''), u.verified, u.is_organization, u.org_verified,
+			COALESCE(u.org_name, ''), COALESCE(u.org_logo_url, ''), COALESCE(u.department, ''),
+			COALESCE(u.profile_picture, ''),
+			(SELECT COUNT(*) FROM products p WHERE p.seller_id = u.id AND p.status = 'available')
+		FROM users u WHERE u.id = ?`, s.ID).Scan(&s.Name, &s.Username, &s.Verified, &s.IsOrganization, &s.OrgVerified,
+		&s.OrgName, &s.OrgLogoURL, &s.Department, &s.ProfilePicture, &s.ActiveListings)
+	if err != nil {
+		return err
+	}
+
+	r.SampleListings = []models.SellerListingSample{}
+	rows, err := h.db.Query(`
+		SELECT id, COALESCE(slug, ''), title, price, COALESCE(currency, ?), image_urls, COALESCE(category, '')
+		FROM products WHERE seller_id = ? AND status = 'available'
+		ORDER BY created_at DESC, id DESC LIMIT ?`, config.DefaultCurrency, s.ID, sellerSampleListings)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var l models.SellerListingSample
+		var price sql.NullFloat64
+		var images sql.NullString
+		if err := rows.Scan(&l.ID, &l.Slug, &l.Title, &price, &l.Currency, &images, &l.Category); err != nil {
+			return err
+		}
+		l.Price = nullFloatPtr(price)
+		if images.Valid {
+			if urls := parseImageURLs(l.ID, images.String); len(urls) > 0 {
+				l.ImageURL = urls[0]
+			}
+		}
+		r.SampleListings = append(r.SampleListings, l)
+	}
+	return rows.Err()
+}
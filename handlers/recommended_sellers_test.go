@@ -0,0 +1,44 @@
+package handlers
+
+import "testing"
+
+func TestScoreSellerPrefersNearbyMatchingShops(t *testing.T) {
+	lat, lon := 6.9214, 122.0790
+	farLat, farLon := 7.8257, 123.4370
+	buyer := buyerTaste{Lat: &lat, Lon: &lon, Categories: map[string]int{"books": 3, "electronics": 1}}
+
+	near := sellerCandidate{ID: 1, Lat: &lat, Lon: &lon, Categories: map[string]int{"books": 5}}
+	far := sellerCandidate{ID: 2, Lat: &farLat, Lon: &farLon, Categories: map[string]int{"books": 5}}
+	unrelated := sellerCandidate{ID: 3, Lat: &lat, Lon: &lon, Categories: map[string]int{"furniture": 5}}
+
+	nearScore, distance, shared := scoreSeller(near, buyer)
+	if distance == nil || *distance != 0 {
+		t.Errorf("distance to a seller at the same spot = %v, want 0", distance)
+	}
+	if len(shared) != 1 || shared[0] != "books" {
+		t.Errorf("shared categories = %v, want [books]", shared)
+	}
+	farScore, _, _ := scoreSeller(far, buyer)
+	unrelatedScore, _, _ := scoreSeller(unrelated, buyer)
+	if nearScore <= farScore {
+		t.Errorf("near seller scored %v, not above far seller %v", nearScore, farScore)
+	}
+	if nearScore <= unrelatedScore {
+		t.Errorf("matching seller scored %v, not above unrelated seller %v", nearScore, unrelatedScore)
+	}
+}
+
+func TestScoreSellerNeutralWithoutHistory(t *testing.T) {
+	fresh := sellerCandidate{ID: 1}
+	poor, rating := 0.1, 1.0
+	struggling := sellerCandidate{ID: 2, ResponseScore: &poor, AverageRating: &rating}
+
+	freshScore, distance, _ := scoreSeller(fresh, buyerTaste{})
+	if distance != nil {
+		t.Errorf("distance without coordinates = %v, want nil", *distance)
+	}
+	strugglingScore, _, _ := scoreSeller(struggling, buyerTaste{})
+	if freshScore <= strugglingScore {
+		t.Errorf("new seller scored %v, not above a poorly rated one %v", freshScore, strugglingScore)
+	}
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// openStrictTestDB is openTestDB with every connection in strict SQL mode, where the zero
+// date is rejected
+func openStrictTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("mysql", "test_user:test_pass@tcp(localhost:3306)/clovia_test?parseTime=true&sql_mode=%27STRICT_ALL_TABLES,NO_ZERO_DATE,NO_ZERO_IN_DATE%27")
+	if err != nil {
+		t.Skip("Test database not available")
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		t.Skip("Test database not available")
+	}
+	return db
+}
+
+// TestSaveUnsaveResaveStrictMode saves, unsaves and saves a product again under strict SQL
+// mode, checking the saved state after each step
+func TestSaveUnsaveResaveStrictMode(t *testing.T) {
+	db := openStrictTestDB(t)
+	defer db.Close()
+
+	user := createTestUser(t, db, "Strict Saver")
+	seller := createTestUser(t, db, "Strict Seller")
+	product := createTestProduct(t, db, seller, "Strict Lamp")
+	t.Cleanup(func() { db.Exec("DELETE FROM products WHERE id = ?", product) })
+
+	h := &UserHandler{db: db}
+	app := newTestApp(&user, func(app *fiber.App) {
+		app.Post("/users/saved-products", h.SaveProduct)
+		app.Delete("/users/saved-products/:id", h.UnsaveProduct)
+		app.Get("/users/saved-products", h.GetSavedProducts)
+	})
+	do := func(method, path, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		return resp.StatusCode
+	}
+	saved := func() int {
+		var n int
+		if err := db.QueryRow("SELECT COUNT(*) FROM saved_products WHERE user_id = ? AND product_id = ? AND deleted_at IS NULL", user, product).Scan(&n); err != nil {
+			t.Fatalf("count saves: %v", err)
+		}
+		return n
+	}
+	saveBody := `{"product_id":` + strconv.Itoa(product) + `}`
+	itemPath := "/users/saved-products/" + strconv.Itoa(product)
+
+	if got := do("POST", "/users/saved-products", saveBody); got != 200 || saved() != 1 {
+		t.Fatalf("save: status %d, %d active saves", got, saved())
+	}
+	if got := do("POST", "/users/saved-products", saveBody); got != 409 {
+		t.Errorf("second save: status %d, want 409", got)
+	}
+	if got := do("GET", "/users/saved-products", ""); got != 200 {
+		t.Errorf("list: status %d, want 200", got)
+	}
+	if got := do("DELETE", itemPath, ""); got != 200 || saved() != 0 {
+		t.Fatalf("unsave: status %d, %d active saves", got, saved())
+	}
+	if got := do("DELETE", itemPath, ""); got != 404 {
+		t.Errorf("second unsave: status %d, want 404", got)
+	}
+	if got := do("POST", "/users/saved-products", saveBody); got != 200 || saved() != 1 {
+		t.Fatalf("re-save: status %d, %d active saves", got, saved())
+	}
+	var rows int
+	db.QueryRow("SELECT COUNT(*) FROM saved_products WHERE user_id = ? AND product_id = ?", user, product).Scan(&rows)
+	if rows != 1 {
+		t.Errorf("%d saved_products rows, want the one row restored", rows)
+	}
+}
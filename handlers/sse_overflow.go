@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/xashathebest/clovia/config"
+)
+
+// sseResyncEvent tells a client it missed state changes and should refetch the listed kinds
+const sseResyncEvent = "resync"
+
+// laggingStreams records subscribers that missed a critical event: channel -> set of
+// missed event kinds. The stream writer turns the entry into one resync event.
+var laggingStreams = struct {
+	sync.Mutex
+	m map[chan []byte]map[string]bool
+}{m: make(map[chan []byte]map[string]bool)}
+
+// isCriticalSSEEvent reports whether losing the event would leave the client showing the
+// wrong trade or delivery state, as opposed to transient presence or typing updates
+func isCriticalSSEEvent(eventType string) bool {
+	return strings.HasPrefix(eventType, "trade") || strings.HasPrefix(eventType, "delivery")
+}
+
+// sseEventKind is the resource a critical event belongs to, which is what the client refetches
+func sseEventKind(eventType string) string {
+	if strings.HasPrefix(eventType, "delivery") {
+		return "delivery"
+	}
+	return "trade"
+}
+
+// newSSEChannel makes a subscriber channel with the configured buffer
+func newSSEChannel() chan []byte {
+	return make(chan []byte, config.SSEBufferSize())
+}
+
+// offerSSE delivers payload to ch without blocking. When the buffer is full the
+// strategy decides: drop the oldest queued event to make room, or keep the queue and
+// mark the subscriber behind so it is told to resync.
+func offerSSE(ch chan []byte, eventType string, payload []byte, strategy string) {
+	select {
+	case ch <- payload:
+		return
+	default:
+	}
+
+	if strategy == config.SSEOverflowResync || (strategy == config.SSEOverflowAdaptive && isCriticalSSEEvent(eventType)) {
+		markLagging(ch, sseEventKind(eventType))
+		return
+	}
+
+	// Drop the oldest. A critical event lost this way still earns the client a resync.
+	select {
+	case old := <-ch:
+		var dropped struct {
+			Type string `json:"type"`
+		}
+		if json.Unmarshal(old, &dropped) == nil && isCriticalSSEEvent(dropped.Type) {
+			markLagging(ch, sseEventKind(dropped.Type))
+		}
+	default:
+	}
+	select {
+	case ch <- payload:
+	default:
+		// Another publisher refilled the slot; this event is the one that goes
+		if isCriticalSSEEvent(eventType) {
+			markLagging(ch, sseEventKind(eventType))
+		}
+	}
+}
+
+func markLagging(ch chan []byte, kind string) {
+	laggingStreams.Lock()
+	defer laggingStreams.Unlock()
+	if laggingStreams.m[ch] == nil {
+		laggingStreams.m[ch] = make(map[string]bool)
+	}
+	laggingStreams.m[ch][kind] = true
+}
+
+// takeResync returns the resync event owed to ch, if any, and clears the subscriber's
+// lagging state
+func takeResync(ch chan []byte) ([]byte, bool) {
+	laggingStreams.Lock()
+	kinds, ok := laggingStreams.m[ch]
+	delete(laggingStreams.m, ch)
+	laggingStreams.Unlock()
+	if !ok {
+		return nil, false
+	}
+	missed := make([]string, 0, len(kinds))
+	for _, k := range []string{"trade", "delivery"} {
+		if kinds[k] {
+			missed = append(missed, k)
+		}
+	}
+	payload, _ := json.Marshal(sseEvent{Type: sseResyncEvent, Data: map[string]interface{}{"reason": "overflow", "kinds": missed}})
+	return payload, true
+}
+
+// forgetStream drops any lagging state for a closed subscriber
+func forgetStream(ch chan []byte) {
+	laggingStreams.Lock()
+	delete(laggingStreams.m, ch)
+	laggingStreams.Unlock()
+}
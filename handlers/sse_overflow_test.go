@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/xashathebest/clovia/config"
+)
+
+func ssePayload(eventType string) []byte {
+	b, _ := json.Marshal(sseEvent{Type: eventType})
+	return b
+}
+
+func TestOfferSSEDropsOldestTransientEvent(t *testing.T) {
+	ch := make(chan []byte, 2)
+	defer forgetStream(ch)
+	offerSSE(ch, "typing", ssePayload("typing"), config.SSEOverflowAdaptive)
+	offerSSE(ch, "presence", ssePayload("presence"), config.SSEOverflowAdaptive)
+	offerSSE(ch, "user_presence", ssePayload("user_presence"), config.SSEOverflowAdaptive)
+
+	if got := string(<-ch); got != string(ssePayload("presence")) {
+		t.Errorf("oldest queued event = %s, want presence once typing was dropped", got)
+	}
+	if got := string(<-ch); got != string(ssePayload("user_presence")) {
+		t.Errorf("newest event = %s, want user_presence", got)
+	}
+	if _, ok := takeResync(ch); ok {
+		t.Error("dropping transient events should not ask for a resync")
+	}
+}
+
+func TestOfferSSEMarksSubscriberBehindForCriticalEvent(t *testing.T) {
+	ch := make(chan []byte, 1)
+	defer forgetStream(ch)
+	offerSSE(ch, "typing", ssePayload("typing"), config.SSEOverflowAdaptive)
+	offerSSE(ch, "delivery_update", ssePayload("delivery_update"), config.SSEOverflowAdaptive)
+
+	if got := string(<-ch); got != string(ssePayload("typing")) {
+		t.Errorf("queue = %s, want it left untouched", got)
+	}
+	b, ok := takeResync(ch)
+	if !ok {
+		t.Fatal("a dropped delivery event should owe the subscriber a resync")
+	}
+	var evt struct {
+		Type string `json:"type"`
+		Data struct {
+			Kinds []string `json:"kinds"`
+		} `json:"data"`
+	}
+	json.Unmarshal(b, &evt)
+	if evt.Type != sseResyncEvent || len(evt.Data.Kinds) != 1 || evt.Data.Kinds[0] != "delivery" {
+		t.Errorf("resync event = %s", b)
+	}
+	if _, ok := takeResync(ch); ok {
+		t.Error("resync should be sent once")
+	}
+}
+
+func TestOfferSSEResyncWhenDroppingQueuedCriticalEvent(t *testing.T) {
+	ch := make(chan []byte, 1)
+	defer forgetStream(ch)
+	offerSSE(ch, "trade_updated", ssePayload("trade_updated"), config.SSEOverflowDropOldest)
+	offerSSE(ch, "typing", ssePayload("typing"), config.SSEOverflowDropOldest)
+
+	if got := string(<-ch); got != string(ssePayload("typing")) {
+		t.Errorf("queue = %s, want the newest event", got)
+	}
+	if _, ok := takeResync(ch); !ok {
+		t.Error("discarding a queued trade event should owe the subscriber a resync")
+	}
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/xashathebest/clovia/models"
+)
+
+// hiddenProductStatuses never appear in a product feed, whatever ?status= asks for
+var hiddenProductStatuses = map[string]bool{"draft": true, "removed": true}
+
+// visibleStatusClause is a SQL condition on column that leaves out hiddenProductStatuses
+func visibleStatusClause(column string) string {
+	hidden := make([]string, 0, len(hiddenProductStatuses))
+	for s := range hiddenProductStatuses {
+		hidden = append(hidden, "'"+s+"'")
+	}
+	sort.Strings(hidden)
+	return column + " NOT IN (" + strings.Join(hidden, ", ") + ")"
+}
+
+// parseStatusFilter reads a comma-separated ?status= list such as "sold,traded".
+// Blank entries and repeats are dropped; an unknown or hidden status is an error
+// naming it, so a typo is reported instead of silently matching nothing.
+func parseStatusFilter(raw string) ([]string, error) {
+	valid := make(map[string]bool, len(models.ProductStatuses))
+	var allowed []string
+	for _, s := range models.ProductStatuses {
+		if !hiddenProductStatuses[s] {
+			valid[s] = true
+			allowed = append(allowed, s)
+		}
+	}
+
+	var statuses []string
+	seen := map[string]bool{}
+	for _, part := range strings.Split(raw, ",") {
+		s := strings.ToLower(strings.TrimSpace(part))
+		if s == "" || seen[s] {
+			continue
+		}
+		if !valid[s] {
+			return nil, fmt.Errorf("invalid status %q: must be one of %s", s, strings.Join(allowed, ", "))
+		}
+		seen[s] = true
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+// statusInClause builds "p.status IN (?, ...)" and its arguments for a parsed status list
+func statusInClause(statuses []string) (string, []interface{}) {
+	args := make([]interface{}, len(statuses))
+	for i, s := range statuses {
+		args[i] = s
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(statuses)), ", ")
+	return "p.status IN (" + placeholders + ")", args
+}
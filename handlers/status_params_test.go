@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseStatusFilter(t *testing.T) {
+	cases := []struct {
+		raw     string
+		want    []string
+		wantErr string
+	}{
+		{"", nil, ""},
+		{"sold", []string{"sold"}, ""},
+		{"sold, traded", []string{"sold", "traded"}, ""},
+		{"Sold,sold,,traded", []string{"sold", "traded"}, ""},
+		{"sold,bogus", nil, `"bogus"`},
+		{"available,draft", nil, `"draft"`},
+		{"removed", nil, `"removed"`},
+		{"sold;traded", nil, `"sold;traded"`},
+	}
+	for _, tc := range cases {
+		got, err := parseStatusFilter(tc.raw)
+		if tc.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("parseStatusFilter(%q) err = %v, want one naming %s", tc.raw, err, tc.wantErr)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("parseStatusFilter(%q) = %v, %v; want %v", tc.raw, got, err, tc.want)
+		}
+	}
+}
+
+func TestStatusInClause(t *testing.T) {
+	clause, args := statusInClause([]string{"sold", "traded"})
+	if clause != "p.status IN (?, ?)" || !reflect.DeepEqual(args, []interface{}{"sold", "traded"}) {
+		t.Errorf("statusInClause = %q %v", clause, args)
+	}
+}
+
+func TestVisibleStatusClause(t *testing.T) {
+	if got, want := visibleStatusClause("p.status"), "p.status NOT IN ('draft', 'removed')"; got != want {
+		t.Errorf("visibleStatusClause = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,26 @@
+package handlers
+
+import "testing"
+
+func TestCalculateSuggestedValueCurrency(t *testing.T) {
+	cases := []struct {
+		name      string
+		price     float64
+		condition string
+		currency  string
+		want      int
+	}{
+		{"php new", 1000, "New", "PHP", 1000},
+		{"empty currency is php", 1000, "Used", "", 600},
+		{"lowercase code", 10, "New", "usd", 560},
+		{"usd used", 10, "Used", "USD", 336},
+		{"unknown currency treated as php", 100, "New", "XYZ", 100},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := calculateSuggestedValue(tc.price, tc.condition, tc.currency); got != tc.want {
+				t.Errorf("calculateSuggestedValue(%v, %q, %q) = %d, want %d", tc.price, tc.condition, tc.currency, got, tc.want)
+			}
+		})
+	}
+}
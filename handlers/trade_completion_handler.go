@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 
@@ -9,6 +10,7 @@ import (
 	"github.com/xashathebest/clovia/database"
 	"github.com/xashathebest/clovia/middleware"
 	"github.com/xashathebest/clovia/models"
+	"github.com/xashathebest/clovia/services"
 )
 
 // TradeCompletionHandler handles trade completion with race condition protection
@@ -23,8 +25,14 @@ func NewTradeCompletionHandler() *TradeCompletionHandler {
 	}
 }
 
-// CompleteTradeTransaction safely completes a trade and marks all products as unavailable
+// CompleteTradeTransaction safely completes a trade and marks all products as unavailable,
+// retrying on deadlock
 func (h *TradeCompletionHandler) CompleteTradeTransaction(tradeID int) error {
+	return services.WithRetry(func() error { return h.completeTradeTransactionOnce(tradeID) })
+}
+
+// completeTradeTransactionOnce runs a single attempt of CompleteTradeTransaction
+func (h *TradeCompletionHandler) completeTradeTransactionOnce(tradeID int) error {
 	tx, err := h.db.Begin()
 	if err != nil {
 		return fmt.Errorf("failed to start transaction: %w", err)
@@ -109,6 +117,10 @@ func (h *TradeCompletionHandler) CompleteTradeTransaction(tradeID int) error {
 		return fmt.Errorf("trade was already completed by another process")
 	}
 
+	if err := services.RecordTradeFee(tx, tradeID); err != nil {
+		return fmt.Errorf("failed to record trade fee: %w", err)
+	}
+
 	return tx.Commit()
 }
 
@@ -252,6 +264,12 @@ func (h *TradeCompletionHandler) CompleteTrade(c *fiber.Ctx) error {
 
 	// Complete the trade transaction
 	if err := h.CompleteTradeTransaction(req.TradeID); err != nil {
+		if errors.Is(err, services.ErrTransactionConflict) {
+			return c.Status(409).JSON(models.APIResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+		}
 		log.Printf("Failed to complete trade %d: %v", req.TradeID, err)
 		return c.Status(500).JSON(models.APIResponse{
 			Success: false,
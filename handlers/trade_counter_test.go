@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/xashathebest/clovia/database"
+	"github.com/xashathebest/clovia/models"
+)
+
+func TestValidateCounterItems(t *testing.T) {
+	if err := validateCounterItems([]int{2, 3}, 1); err != nil {
+		t.Errorf("distinct items rejected: %v", err)
+	}
+	if err := validateCounterItems(nil, 1); err != nil {
+		t.Errorf("cash-only counter rejected: %v", err)
+	}
+	err := validateCounterItems([]int{2, 3, 2}, 1)
+	if err == nil || !strings.Contains(err.Error(), "Product 2 is listed more than once") {
+		t.Errorf("duplicate id error = %v", err)
+	}
+	err = validateCounterItems([]int{2, 1}, 1)
+	if err == nil || !strings.Contains(err.Error(), "Product 1 is the item being traded for") {
+		t.Errorf("target id error = %v", err)
+	}
+}
+
+// TestCounterRejectsDuplicateProductIDs sends a counter listing the same product twice
+func TestCounterRejectsDuplicateProductIDs(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	database.DB = db
+
+	buyerID := createTestUser(t, db, "counter_buyer")
+	sellerID := createTestUser(t, db, "counter_seller")
+	target := createTestProduct(t, db, sellerID, "Counter target")
+	sellerItem := createTestProduct(t, db, sellerID, "Counter extra")
+	res, err := db.Exec("INSERT INTO trades (buyer_id, seller_id, target_product_id, status) VALUES (?, ?, ?, 'pending')", buyerID, sellerID, target)
+	if err != nil {
+		t.Fatalf("insert trade: %v", err)
+	}
+	id64, _ := res.LastInsertId()
+	tradeID := int(id64)
+	t.Cleanup(func() { db.Exec("DELETE FROM trades WHERE id = ?", tradeID) })
+
+	handler := &TradeHandler{db: db}
+	currentUser := sellerID
+	app := newTestApp(&currentUser, func(app *fiber.App) {
+		app.Put("/trades/:id", handler.UpdateTrade)
+	})
+
+	body := fmt.Sprintf(`{"action": "counter", "counter_offered_product_ids": [%d, %d]}`, sellerItem, sellerItem)
+	req := httptest.NewRequest("PUT", fmt.Sprintf("/trades/%d", tradeID), strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	var out models.APIResponse
+	_ = json.NewDecoder(resp.Body).Decode(&out)
+	if resp.StatusCode != 400 || !strings.Contains(out.Error, "listed more than once") {
+		t.Fatalf("duplicate counter = %d %q, want 400 naming the duplicate", resp.StatusCode, out.Error)
+	}
+
+	var status string
+	var items int
+	db.QueryRow("SELECT status FROM trades WHERE id = ?", tradeID).Scan(&status)
+	db.QueryRow("SELECT COUNT(*) FROM trade_items WHERE trade_id = ?", tradeID).Scan(&items)
+	if status != "pending" || items != 0 {
+		t.Errorf("rejected counter changed the trade: status %q, %d items", status, items)
+	}
+}
+
+func TestValidateCounterChange(t *testing.T) {
+	cash := func(v float64) *float64 { return &v }
+	withItem := models.TradeOfferSnapshot{Items: []models.TradeSnapshotItem{{ProductID: 7, OfferedBy: "buyer"}}, Cash: cash(100)}
+	cases := []struct {
+		name     string
+		products []int
+		cash     *float64
+		current  models.TradeOfferSnapshot
+		wantErr  bool
+	}{
+		{"new items", []int{2}, nil, withItem, false},
+		{"same items and cash", []int{7}, cash(100), withItem, true},
+		{"same items new cash", []int{7}, cash(120), withItem, false},
+		{"same items dropping cash", []int{7}, nil, withItem, false},
+		{"new cash keeps items", nil, cash(150), withItem, false},
+		{"nothing given", nil, nil, withItem, true},
+		{"same cash", nil, cash(100), withItem, true},
+		{"negative cash", []int{2}, cash(-1), withItem, true},
+		{"zero cash with no items left", nil, cash(0), models.TradeOfferSnapshot{Cash: cash(50)}, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := validateCounterChange(tc.products, tc.cash, tc.current); (err != nil) != tc.wantErr {
+				t.Errorf("validateCounterChange err = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+// TestCashOnlyCounterKeepsItems counters with a new cash amount alone and checks the
+// buyer's offered item is still part of the trade
+func TestCashOnlyCounterKeepsItems(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	database.DB = db
+
+	buyerID := createTestUser(t, db, "cash_counter_buyer")
+	sellerID := createTestUser(t, db, "cash_counter_seller")
+	target := createTestProduct(t, db, sellerID, "Cash counter target")
+	offered := createTestProduct(t, db, buyerID, "Cash counter offered")
+	res, err := db.Exec("INSERT INTO trades (buyer_id, seller_id, target_product_id, status, offered_cash_amount) VALUES (?, ?, ?, 'pending', 100)", buyerID, sellerID, target)
+	if err != nil {
+		t.Fatalf("insert trade: %v", err)
+	}
+	id64, _ := res.LastInsertId()
+	tradeID := int(id64)
+	t.Cleanup(func() {
+		db.Exec("DELETE FROM trades WHERE id = ?", tradeID)
+		db.Exec("DELETE FROM products WHERE id IN (?, ?)", target, offered)
+	})
+	if _, err := db.Exec("INSERT INTO trade_items (trade_id, product_id, offered_by) VALUES (?, ?, 'buyer')", tradeID, offered); err != nil {
+		t.Fatalf("insert trade item: %v", err)
+	}
+
+	handler := &TradeHandler{db: db}
+	currentUser := sellerID
+	app := newTestApp(&currentUser, func(app *fiber.App) {
+		app.Put("/trades/:id", handler.UpdateTrade)
+	})
+	counter := func(body string) int {
+		req := httptest.NewRequest("PUT", fmt.Sprintf("/trades/%d", tradeID), strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		return resp.StatusCode
+	}
+
+	if got := counter(`{"action": "counter"}`); got != 400 {
+		t.Errorf("empty counter = %d, want 400", got)
+	}
+	if got := counter(`{"action": "counter", "counter_offered_cash_amount": 250}`); got != 200 {
+		t.Fatalf("cash-only counter = %d, want 200", got)
+	}
+
+	var status string
+	var cash float64
+	var items int
+	db.QueryRow("SELECT status, offered_cash_amount FROM trades WHERE id = ?", tradeID).Scan(&status, &cash)
+	db.QueryRow("SELECT COUNT(*) FROM trade_items WHERE trade_id = ? AND product_id = ?", tradeID, offered).Scan(&items)
+	if status != "countered" || cash != 250 || items != 1 {
+		t.Errorf("after cash counter: status %q, cash %v, offered item kept %d; want countered, 250, 1", status, cash, items)
+	}
+}
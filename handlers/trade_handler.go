@@ -2,12 +2,16 @@ package handlers
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/xashathebest/clovia/config"
 	"github.com/xashathebest/clovia/database"
 	"github.com/xashathebest/clovia/middleware"
 	"github.com/xashathebest/clovia/models"
@@ -22,6 +26,141 @@ func NewTradeHandler() *TradeHandler {
 	return &TradeHandler{db: database.DB}
 }
 
+// openTradeStatuses are trade statuses that still hold a claim on their products
+var openTradeStatuses = []string{"pending", "accepted", "countered", "active", "awaiting_confirmation"}
+
+// findConflictingTrade returns the first open trade (other than excludeTradeID) whose
+// offered items include one of productIDs, locking those product rows for the
+// rest of the transaction. It returns 0, 0 when there is no conflict.
+func findConflictingTrade(tx *sql.Tx, productIDs []int, excludeTradeID int) (tradeID int, productID int, err error) {
+	if len(productIDs) == 0 {
+		return 0, 0, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(productIDs)), ",")
+	args := make([]interface{}, 0, len(productIDs)+len(openTradeStatuses)+1)
+	for _, id := range productIDs {
+		args = append(args, id)
+	}
+
+	// Serialize concurrent offers of the same products
+	lockRows, err := tx.Query("SELECT id FROM products WHERE id IN ("+placeholders+") FOR UPDATE", args...)
+	if err != nil {
+		return 0, 0, err
+	}
+	lockRows.Close()
+
+	for _, s := range openTradeStatuses {
+		args = append(args, s)
+	}
+	args = append(args, excludeTradeID)
+	statusPlaceholders := strings.TrimSuffix(strings.Repeat("?,", len(openTradeStatuses)), ",")
+	err = tx.QueryRow(`
+		SELECT t.id, ti.product_id
+		FROM trade_items ti
+		JOIN trades t ON t.id = ti.trade_id
+		WHERE ti.product_id IN (`+placeholders+`)
+		  AND t.status IN (`+statusPlaceholders+`)
+		  AND t.id <> ?
+		ORDER BY t.id ASC
+		LIMIT 1
+	`, args...).Scan(&tradeID, &productID)
+	if err == sql.ErrNoRows {
+		return 0, 0, nil
+	}
+	return tradeID, productID, err
+}
+
+// validateTradeOffer requires an offer to include at least one product or a positive
+// cash amount, and rejects a negative or zero cash amount when one is given.
+func validateTradeOffer(productIDs []int, cash *float64) error {
+	for _, id := range productIDs {
+		if id <= 0 {
+			return errors.New("Invalid product IDs")
+		}
+	}
+	if cash != nil && *cash <= 0 {
+		return errors.New("Offered cash amount must be greater than zero")
+	}
+	if len(productIDs) == 0 && cash == nil {
+		return errors.New("Offer at least one product or a cash amount")
+	}
+	return nil
+}
+
+// validateCounterItems rejects a counter-offer that lists a product twice or offers the
+// product the trade is for
+func validateCounterItems(productIDs []int, targetProductID int) error {
+	seen := make(map[int]bool, len(productIDs))
+	for _, id := range productIDs {
+		if id == targetProductID {
+			return fmt.Errorf("Product %d is the item being traded for and cannot be offered in the counter", id)
+		}
+		if seen[id] {
+			return fmt.Errorf("Product %d is listed more than once in the counter offer", id)
+		}
+		seen[id] = true
+	}
+	return nil
+}
+
+// errEmptyCounter is returned for a counter-offer that changes nothing
+var errEmptyCounter = errors.New("A counter-offer must change the offered items or the cash amount")
+
+// validateCounterChange checks a counter against the offer it replaces. With products the
+// counter replaces both the items and the cash, so one of them must differ. Without products
+// the counter only sets the cash and the current items stay, so the cash must be given and
+// differ from the current amount, and the offer must not end up empty.
+func validateCounterChange(productIDs []int, cash *float64, current models.TradeOfferSnapshot) error {
+	if cash != nil && *cash < 0 {
+		return errors.New("Cash amount cannot be negative")
+	}
+	if len(productIDs) > 0 {
+		if sameProductSet(productIDs, current.Items) && sameCash(cash, current.Cash) {
+			return errEmptyCounter
+		}
+		return nil
+	}
+	if cash == nil || (current.Cash != nil && *current.Cash == *cash) {
+		return errEmptyCounter
+	}
+	if len(current.Items) == 0 && *cash == 0 {
+		return errors.New("A counter-offer must include at least one product or a cash amount")
+	}
+	return nil
+}
+
+// sameProductSet reports whether ids names exactly the products in items, in any order
+func sameProductSet(ids []int, items []models.TradeSnapshotItem) bool {
+	want := make(map[int]bool, len(items))
+	for _, it := range items {
+		want[it.ProductID] = true
+	}
+	got := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if !want[id] {
+			return false
+		}
+		got[id] = true
+	}
+	return len(got) == len(want)
+}
+
+// isCashOnlyOffer reports whether the buyer's side of an offer is money alone
+func isCashOnlyOffer(buyerItems int, cash *float64) bool {
+	return buyerItems == 0 && cash != nil && *cash > 0
+}
+
+// countBuyerItems counts the items the buyer put into a trade
+func countBuyerItems(items []models.TradeItem) int {
+	n := 0
+	for _, it := range items {
+		if it.OfferedBy == "buyer" {
+			n++
+		}
+	}
+	return n
+}
+
 // CreateTrade creates a new trade proposal
 func (h *TradeHandler) CreateTrade(c *fiber.Ctx) error {
 	userID, ok := middleware.GetUserIDFromContext(c)
@@ -33,19 +172,29 @@ func (h *TradeHandler) CreateTrade(c *fiber.Ctx) error {
 	if err := c.BodyParser(&payload); err != nil {
 		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid request body"})
 	}
-	if payload.TargetProductID <= 0 || len(payload.OfferedProductIDs) == 0 {
+	if payload.TargetProductID <= 0 {
 		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid product IDs"})
 	}
+	if err := validateTradeOffer(payload.OfferedProductIDs, payload.OfferedCashAmount); err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: err.Error()})
+	}
+	cashOnly := isCashOnlyOffer(len(payload.OfferedProductIDs), payload.OfferedCashAmount)
 
 	// Check if target product is still available
 	var targetStatus string
-	err := h.db.QueryRow("SELECT status FROM products WHERE id = ?", payload.TargetProductID).Scan(&targetStatus)
+	var targetAllowBuying, targetBarterOnly bool
+	err := h.db.QueryRow("SELECT status, COALESCE(allow_buying, FALSE), COALESCE(barter_only, FALSE) FROM products WHERE id = ?", payload.TargetProductID).Scan(&targetStatus, &targetAllowBuying, &targetBarterOnly)
 	if err != nil {
 		return c.Status(404).JSON(models.APIResponse{Success: false, Error: "Target product not found"})
 	}
 	if targetStatus != "available" {
 		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "This product is no longer available for trading"})
 	}
+	// A cash-only offer is a purchase, so it follows the same rule as orders.
+	// Offers that include products are trades and are allowed on every listing.
+	if cashOnly && !models.PurchaseAllowed(targetAllowBuying, targetBarterOnly) {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "This listing does not accept cash-only offers; offer at least one of your products"})
+	}
 
 	// Check if offered products are still available
 	for _, productID := range payload.OfferedProductIDs {
@@ -75,6 +224,24 @@ func (h *TradeHandler) CreateTrade(c *fiber.Ctx) error {
 		_ = tx.Rollback()
 		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Cannot propose a trade on your own product"})
 	}
+	if until, err := sellerAwayUntil(tx, sellerID); err != nil {
+		_ = tx.Rollback()
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to check seller availability"})
+	} else if until != nil {
+		_ = tx.Rollback()
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: sellerAwayMessage(*until)})
+	}
+
+	// Offered products must not already be part of another open trade
+	conflictTradeID, conflictProductID, err := findConflictingTrade(tx, payload.OfferedProductIDs, 0)
+	if err != nil {
+		_ = tx.Rollback()
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to check offered products"})
+	}
+	if conflictTradeID != 0 {
+		_ = tx.Rollback()
+		return c.Status(409).JSON(models.APIResponse{Success: false, Error: fmt.Sprintf("Product %d is already offered in trade #%d", conflictProductID, conflictTradeID)})
+	}
 
 	// Insert trade
 	res, err := tx.Exec(`INSERT INTO trades (buyer_id, seller_id, target_product_id, status, message, offered_cash_amount) VALUES (?, ?, ?, 'pending', ?, ?)`, userID, sellerID, payload.TargetProductID, payload.Message, payload.OfferedCashAmount)
@@ -84,6 +251,10 @@ func (h *TradeHandler) CreateTrade(c *fiber.Ctx) error {
 	}
 	tradeID64, _ := res.LastInsertId()
 	tradeID := int(tradeID64)
+	if err := snapshotTradeTarget(tx, tradeID, payload.TargetProductID); err != nil {
+		_ = tx.Rollback()
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to create trade"})
+	}
 
 	// Validate and insert offered items (buyer side)
 	for _, pid := range payload.OfferedProductIDs {
@@ -96,7 +267,7 @@ func (h *TradeHandler) CreateTrade(c *fiber.Ctx) error {
 			_ = tx.Rollback()
 			return c.Status(400).JSON(models.APIResponse{Success: false, Error: "You can only offer your own products"})
 		}
-		if _, err := tx.Exec("INSERT INTO trade_items (trade_id, product_id, offered_by) VALUES (?, ?, 'buyer')", tradeID, pid); err != nil {
+		if err := insertTradeItem(tx, tradeID, pid, "buyer"); err != nil {
 			_ = tx.Rollback()
 			return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to attach offered items"})
 		}
@@ -114,15 +285,14 @@ func (h *TradeHandler) CreateTrade(c *fiber.Ctx) error {
 	var productTitle string
 	_ = h.db.QueryRow("SELECT title FROM products WHERE id = ?", payload.TargetProductID).Scan(&productTitle)
 	notifMsg := "You received a trade offer from " + buyerName + " for " + productTitle
-	_, _ = h.db.Exec("INSERT INTO notifications (user_id, type, message, is_read) VALUES (?, 'trade_offer', ?, FALSE)", sellerID, notifMsg)
-	publishNotification(sellerID, notifMsg)
+	notify(h.db, sellerID, "trade_offer", notifMsg, models.NotificationRefTrade, tradeID)
 
 	// Ensure chat conversation exists and add a system message
 	convID, _ := ensureConversation(payload.TargetProductID, userID, sellerID)
 	_, _, _ = saveMessage(convID, userID, "Trade offer started for "+productTitle+".")
 
 	// Return created trade (items will appear when listing/fetching details)
-	trade := models.Trade{ID: tradeID, BuyerID: userID, SellerID: sellerID, TargetProductID: payload.TargetProductID, Status: "pending", Message: payload.Message, OfferedCash: payload.OfferedCashAmount, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	trade := models.Trade{ID: tradeID, BuyerID: userID, SellerID: sellerID, TargetProductID: payload.TargetProductID, Status: "pending", Message: payload.Message, OfferedCash: payload.OfferedCashAmount, CashOnly: cashOnly, CreatedAt: time.Now(), UpdatedAt: time.Now()}
 
 	// Realtime notify seller via SSE
 	publishToUser(sellerID, sseEvent{Type: "trade_created", Data: fiber.Map{
@@ -131,6 +301,7 @@ func (h *TradeHandler) CreateTrade(c *fiber.Ctx) error {
 		"target_product_id":   payload.TargetProductID,
 		"message":             payload.Message,
 		"offered_cash_amount": payload.OfferedCashAmount,
+		"cash_only":           cashOnly,
 	}})
 
 	// After creating a trade, check for loops
@@ -151,25 +322,25 @@ func (h *TradeHandler) CheckForTradeLoops() {
 	loops := tradeGraph.FindTradeLoops()
 	if len(loops) > 0 {
 		log.Printf("Found %d trade loops.", len(loops))
-		for _, loop := range loops {
-			// Notify all users in the loop
-			for _, edge := range loop {
-				notifMsg := "Loop Trade Found! A potential multi-way trade is available."
-				_, _ = h.db.Exec("INSERT INTO notifications (user_id, type, message, is_read) VALUES (?, 'trade_loop', ?, FALSE)", edge.FromUser, notifMsg)
-				publishNotification(edge.FromUser, notifMsg)
-			}
+		// Notify every user in any loop exactly once
+		if err := notifyTradeLoops(h.db, loops, publishNotification); err != nil {
+			log.Printf("Error notifying trade loop participants: %v", err)
 		}
 	} else {
 		log.Println("No trade loops found.")
 	}
 }
 
-// GetTrades lists trades for the current user (as buyer or seller)
+// GetTrades lists trades for the current user (as buyer or seller), newest first, one page at a time
 func (h *TradeHandler) GetTrades(c *fiber.Ctx) error {
 	userID, ok := middleware.GetUserIDFromContext(c)
 	if !ok {
 		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "User not authenticated"})
 	}
+	pg, err := parsePagination(c)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: err.Error()})
+	}
 
 	status := c.Query("status", "")
 	direction := c.Query("direction", "")
@@ -188,108 +359,73 @@ func (h *TradeHandler) GetTrades(c *fiber.Ctx) error {
 		args = append(args, status)
 	}
 
+	var total int
+	if err := h.db.QueryRow("SELECT COUNT(*) FROM trades t "+where, args...).Scan(&total); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to count trades"})
+	}
+
+	queryArgs := append([]interface{}{userID}, args...)
+	queryArgs = append(queryArgs, pg.Limit, pg.Offset)
 	rows, err := h.db.Query(`
         SELECT 
           t.id, t.buyer_id, t.seller_id, t.target_product_id, t.status, t.message, t.offered_cash_amount, t.created_at, t.updated_at,
           t.buyer_completed, t.seller_completed, t.completed_at,
-          ub.name AS buyer_name, us.name AS seller_name, p.title AS product_title
+          ub.name AS buyer_name, us.name AS seller_name, COALESCE(t.target_snapshot_title, p.title, '') AS product_title,
+          COALESCE(t.target_snapshot_price, p.price), COALESCE(t.target_snapshot_image_url, p.image_url, ''),
+          (SELECT COUNT(*) FROM trade_messages tm WHERE tm.trade_id = t.id AND tm.sender_id <> ? AND tm.read_at IS NULL) AS unread_count
         FROM trades t
         JOIN users ub ON ub.id = t.buyer_id
         JOIN users us ON us.id = t.seller_id
-        JOIN products p ON p.id = t.target_product_id
+        LEFT JOIN products p ON p.id = t.target_product_id
         `+where+`
-        ORDER BY t.created_at DESC
-    `, args...)
+        ORDER BY t.created_at DESC, t.id DESC
+        LIMIT ? OFFSET ?
+    `, queryArgs...)
 	if err != nil {
 		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to fetch trades"})
 	}
 	defer rows.Close()
 
 	trades := []models.Trade{}
+	var tradeIDs []int
 	for rows.Next() {
 		var tr models.Trade
-		if err := rows.Scan(&tr.ID, &tr.BuyerID, &tr.SellerID, &tr.TargetProductID, &tr.Status, &tr.Message, &tr.OfferedCash, &tr.CreatedAt, &tr.UpdatedAt, &tr.BuyerCompleted, &tr.SellerCompleted, &tr.CompletedAt, &tr.BuyerName, &tr.SellerName, &tr.ProductTitle); err == nil {
-			// Load items
-			itemRows, qerr := h.db.Query(`
-                SELECT ti.id, ti.trade_id, ti.product_id, ti.offered_by, ti.created_at,
-                       p.title, p.status, p.image_url
-                FROM trade_items ti
-                LEFT JOIN products p ON p.id = ti.product_id
-                WHERE ti.trade_id = ?
-            `, tr.ID)
-			items := []models.TradeItem{}
-			if qerr != nil {
-				log.Printf("trade %d: joined items query error: %v", tr.ID, qerr)
-			} else if itemRows != nil {
-				for itemRows.Next() {
-					var it models.TradeItem
-					var offeredBy sql.NullString
-					var title, pstatus, pimg sql.NullString
-					if err := itemRows.Scan(&it.ID, &it.TradeID, &it.ProductID, &offeredBy, &it.CreatedAt, &title, &pstatus, &pimg); err == nil {
-						if offeredBy.Valid {
-							it.OfferedBy = offeredBy.String
-						} else {
-							it.OfferedBy = ""
-						}
-						if title.Valid {
-							it.ProductTitle = title.String
-						}
-						if pstatus.Valid {
-							it.ProductStatus = pstatus.String
-						}
-						if pimg.Valid {
-							it.ProductImageURL = pimg.String
-						}
-						items = append(items, it)
-					} else {
-						log.Printf("trade %d: item row scan error: %v", tr.ID, err)
-					}
-				}
-				itemRows.Close()
-			}
-
-			// Fallback: if no items found via join, fetch basic trade_items and enrich individually
-			if len(items) == 0 {
-				rows2, err2 := h.db.Query("SELECT id, trade_id, product_id, offered_by, created_at FROM trade_items WHERE trade_id = ?", tr.ID)
-				if err2 != nil {
-					log.Printf("trade %d: fallback items query error: %v", tr.ID, err2)
-				} else {
-					for rows2.Next() {
-						var it models.TradeItem
-						var offeredBy sql.NullString
-						if err := rows2.Scan(&it.ID, &it.TradeID, &it.ProductID, &offeredBy, &it.CreatedAt); err == nil {
-							if offeredBy.Valid {
-								it.OfferedBy = offeredBy.String
-							}
-							// try to enrich product info
-							var title, pstatus, pimg sql.NullString
-							_ = h.db.QueryRow("SELECT title, status, image_url FROM products WHERE id = ?", it.ProductID).Scan(&title, &pstatus, &pimg)
-							if title.Valid {
-								it.ProductTitle = title.String
-							}
-							if pstatus.Valid {
-								it.ProductStatus = pstatus.String
-							}
-							if pimg.Valid {
-								it.ProductImageURL = pimg.String
-							}
-							items = append(items, it)
-						} else {
-							log.Printf("trade %d: fallback item scan error: %v", tr.ID, err)
-						}
-					}
-					rows2.Close()
-				}
-			}
-
-			tr.Items = items
-			trades = append(trades, tr)
-		} else {
+		var unread int
+		var targetID sql.NullInt64
+		if err := rows.Scan(&tr.ID, &tr.BuyerID, &tr.SellerID, &targetID, &tr.Status, &tr.Message, &tr.OfferedCash, &tr.CreatedAt, &tr.UpdatedAt, &tr.BuyerCompleted, &tr.SellerCompleted, &tr.CompletedAt, &tr.BuyerName, &tr.SellerName, &tr.ProductTitle, &tr.ProductPrice, &tr.ProductImageURL, &unread); err != nil {
 			log.Printf("trade row scan error: %v", err)
+			continue
 		}
+		tr.TargetProductID = int(targetID.Int64)
+		tr.UnreadCount = &unread
+		trades = append(trades, tr)
+		tradeIDs = append(tradeIDs, tr.ID)
 	}
+	rows.Close()
 
-	return c.JSON(models.APIResponse{Success: true, Data: trades})
+	itemsByTrade, err := loadTradeItems(h.db, tradeIDs)
+	if err != nil {
+		log.Printf("trades %v: items query error: %v", tradeIDs, err)
+	}
+	for i := range trades {
+		items := itemsByTrade[trades[i].ID]
+		if items == nil {
+			items = []models.TradeItem{}
+		}
+		trades[i].Items = items
+		trades[i].CashOnly = isCashOnlyOffer(countBuyerItems(items), trades[i].OfferedCash)
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data: models.PaginatedResponse{
+			Data:       trades,
+			Total:      total,
+			Page:       pg.Page,
+			Limit:      pg.Limit,
+			TotalPages: (total + pg.Limit - 1) / pg.Limit,
+		},
+	})
 }
 
 // UpdateTrade allows seller or buyer to accept, decline, or counter
@@ -332,6 +468,24 @@ func (h *TradeHandler) UpdateTrade(c *fiber.Ctx) error {
 			return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to start transaction"})
 		}
 
+		currentStatus, err = lockTradeForAction(tx, tradeID, "accept")
+		if err == nil {
+			err = checkOfferResponder(tx, tradeID, userID)
+		}
+		if err != nil {
+			_ = tx.Rollback()
+			return tradeActionFailed(c, err)
+		}
+
+		// Re-check every involved product under a row lock; a concurrent order may have sold one
+		if unavailable, err := h.lockTradeProductsForAccept(tx, tradeID); err != nil {
+			_ = tx.Rollback()
+			return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to verify product availability"})
+		} else if unavailable != "" {
+			_ = tx.Rollback()
+			return c.Status(409).JSON(models.APIResponse{Success: false, Error: fmt.Sprintf("Product %s is no longer available", unavailable)})
+		}
+
 		// Update trade status to active
 		_, err = tx.Exec("UPDATE trades SET status='active', updated_at=CURRENT_TIMESTAMP WHERE id = ?", tradeID)
 		if err != nil {
@@ -340,7 +494,7 @@ func (h *TradeHandler) UpdateTrade(c *fiber.Ctx) error {
 		}
 
 		// Soft-lock all products in the trade
-		if err := h.setProductStatusForTrade(tx, tradeID, "locked"); err != nil {
+		if _, err := h.setProductStatusForTrade(tx, tradeID, "locked", userID); err != nil {
 			_ = tx.Rollback()
 			return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to lock products for trade"})
 		}
@@ -360,16 +514,23 @@ func (h *TradeHandler) UpdateTrade(c *fiber.Ctx) error {
 		_, _ = h.db.Exec("INSERT INTO trade_events (trade_id, actor_id, from_status, to_status, note) VALUES (?, ?, ?, 'accepted', ?)", tradeID, userID, currentStatus, payload.Message)
 		publishToUser(buyerID, sseEvent{Type: "trade_updated", Data: fiber.Map{"trade_id": tradeID, "status": "accepted"}})
 		publishToUser(sellerID, sseEvent{Type: "trade_updated", Data: fiber.Map{"trade_id": tradeID, "status": "accepted"}})
-		_, _ = h.db.Exec("INSERT INTO notifications (user_id, type, message, is_read) VALUES (?, 'trade_update', ?, FALSE)", buyerID, "Your trade offer was accepted: "+productTitle)
-		_, _ = h.db.Exec("INSERT INTO notifications (user_id, type, message, is_read) VALUES (?, 'trade_update', ?, FALSE)", sellerID, "You accepted a trade offer: "+productTitle)
+		notify(h.db, buyerID, "trade_update", "Your trade offer was accepted: "+productTitle, models.NotificationRefTrade, tradeID)
+		notify(h.db, sellerID, "trade_update", "You accepted a trade offer: "+productTitle, models.NotificationRefTrade, tradeID)
 	case "decline":
 		tx, err := h.db.Begin()
 		if err != nil {
 			return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to start transaction"})
 		}
 
+		currentStatus, err = lockTradeForAction(tx, tradeID, "decline")
+		if err != nil {
+			_ = tx.Rollback()
+			return tradeActionFailed(c, err)
+		}
+
 		// Unlock products
-		if err := h.setProductStatusForTrade(tx, tradeID, "available"); err != nil {
+		unlocked, err := h.setProductStatusForTrade(tx, tradeID, "available", userID)
+		if err != nil {
 			_ = tx.Rollback()
 			return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to unlock products"})
 		}
@@ -392,8 +553,9 @@ func (h *TradeHandler) UpdateTrade(c *fiber.Ctx) error {
 		_ = h.db.QueryRow("SELECT title FROM products WHERE id = ?", pid).Scan(&productTitle)
 		publishToUser(buyerID, sseEvent{Type: "trade_updated", Data: fiber.Map{"trade_id": tradeID, "status": "declined"}})
 		publishToUser(sellerID, sseEvent{Type: "trade_updated", Data: fiber.Map{"trade_id": tradeID, "status": "declined"}})
-		_, _ = h.db.Exec("INSERT INTO notifications (user_id, type, message, is_read) VALUES (?, 'trade_update', ?, FALSE)", buyerID, "Your trade offer was declined: "+productTitle)
-		_, _ = h.db.Exec("INSERT INTO notifications (user_id, type, message, is_read) VALUES (?, 'trade_update', ?, FALSE)", sellerID, "You declined a trade offer: "+productTitle)
+		notify(h.db, buyerID, "trade_update", "Your trade offer was declined: "+productTitle, models.NotificationRefTrade, tradeID)
+		notify(h.db, sellerID, "trade_update", "You declined a trade offer: "+productTitle, models.NotificationRefTrade, tradeID)
+		alertBackInStock(h.db, unlocked)
 		_, _ = h.db.Exec("INSERT INTO trade_events (trade_id, actor_id, from_status, to_status, note) VALUES (?, ?, ?, 'declined', ?)", tradeID, userID, currentStatus, payload.Message)
 	case "counter":
 		tx, err := h.db.Begin()
@@ -401,8 +563,36 @@ func (h *TradeHandler) UpdateTrade(c *fiber.Ctx) error {
 			return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to start transaction"})
 		}
 
+		currentStatus, err = lockTradeForAction(tx, tradeID, "counter")
+		if err != nil {
+			_ = tx.Rollback()
+			return tradeActionFailed(c, err)
+		}
+
+		var targetProductID int
+		if err := tx.QueryRow("SELECT target_product_id FROM trades WHERE id = ?", tradeID).Scan(&targetProductID); err != nil {
+			_ = tx.Rollback()
+			return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to load trade"})
+		}
+		if err := validateCounterItems(payload.CounterOfferedProductIDs, targetProductID); err != nil {
+			_ = tx.Rollback()
+			return c.Status(400).JSON(models.APIResponse{Success: false, Error: err.Error()})
+		}
+
+		// Snapshot the offer before replacing it so history can show what changed
+		before, err := snapshotTradeOffer(tx, tradeID)
+		if err != nil {
+			_ = tx.Rollback()
+			return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to read current offer"})
+		}
+		if err := validateCounterChange(payload.CounterOfferedProductIDs, payload.CounterOfferedCashAmount, before); err != nil {
+			_ = tx.Rollback()
+			return c.Status(400).JSON(models.APIResponse{Success: false, Error: err.Error()})
+		}
+
 		// Unlock products from the previous state of the trade before applying the counter
-		if err := h.setProductStatusForTrade(tx, tradeID, "available"); err != nil {
+		unlocked, err := h.setProductStatusForTrade(tx, tradeID, "available", userID)
+		if err != nil {
 			_ = tx.Rollback()
 			return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to unlock products for counter-offer"})
 		}
@@ -413,29 +603,54 @@ func (h *TradeHandler) UpdateTrade(c *fiber.Ctx) error {
 			offeredBy = "seller"
 		}
 
-		// Replace items in the trade
-		if _, err := tx.Exec("DELETE FROM trade_items WHERE trade_id = ?", tradeID); err != nil {
+		conflictTradeID, conflictProductID, err := findConflictingTrade(tx, payload.CounterOfferedProductIDs, tradeID)
+		if err != nil {
 			_ = tx.Rollback()
-			return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to update trade items"})
+			return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to check offered products"})
+		}
+		if conflictTradeID != 0 {
+			_ = tx.Rollback()
+			return c.Status(409).JSON(models.APIResponse{Success: false, Error: fmt.Sprintf("Product %d is already offered in trade #%d", conflictProductID, conflictTradeID)})
+		}
+
+		// Replace items in the trade; a cash-only counter keeps the current ones
+		if len(payload.CounterOfferedProductIDs) > 0 {
+			if _, err := tx.Exec("DELETE FROM trade_items WHERE trade_id = ?", tradeID); err != nil {
+				_ = tx.Rollback()
+				return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to update trade items"})
+			}
 		}
 		for _, pid := range payload.CounterOfferedProductIDs {
 			var ownerID int
-			if err := tx.QueryRow("SELECT seller_id FROM products WHERE id = ?", pid).Scan(&ownerID); err != nil || ownerID != userID {
+			if err := tx.QueryRow("SELECT seller_id FROM products WHERE id = ?", pid).Scan(&ownerID); err == sql.ErrNoRows {
+				_ = tx.Rollback()
+				return c.Status(400).JSON(models.APIResponse{Success: false, Error: fmt.Sprintf("Product %d does not exist", pid)})
+			} else if err != nil {
+				_ = tx.Rollback()
+				return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to check counter offer items"})
+			}
+			if ownerID != userID {
 				_ = tx.Rollback()
-				return c.Status(400).JSON(models.APIResponse{Success: false, Error: fmt.Sprintf("You do not own product %d or it does not exist.", pid)})
+				return c.Status(400).JSON(models.APIResponse{Success: false, Error: fmt.Sprintf("You do not own product %d", pid)})
 			}
-			if _, err := tx.Exec("INSERT INTO trade_items (trade_id, product_id, offered_by) VALUES (?, ?, ?)", tradeID, pid, offeredBy); err != nil {
+			if err := insertTradeItem(tx, tradeID, pid, offeredBy); err != nil {
 				_ = tx.Rollback()
 				return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to add counter offer items"})
 			}
 		}
 
 		// Update trade status, message, and cash amount
-		if _, err := tx.Exec("UPDATE trades SET status='countered', message=?, offered_cash_amount=?, updated_at=CURRENT_TIMESTAMP WHERE id = ?", payload.Message, payload.CounterOfferedCashAmount, tradeID); err != nil {
+		if _, err := tx.Exec("UPDATE trades SET status='countered', countered_by=?, message=?, offered_cash_amount=?, updated_at=CURRENT_TIMESTAMP WHERE id = ?", userID, payload.Message, payload.CounterOfferedCashAmount, tradeID); err != nil {
 			_ = tx.Rollback()
 			return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to update trade for counter offer"})
 		}
 
+		after, err := snapshotTradeOffer(tx, tradeID)
+		if err != nil {
+			_ = tx.Rollback()
+			return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to read counter offer"})
+		}
+
 		if err := tx.Commit(); err != nil {
 			return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to commit counter offer"})
 		}
@@ -447,8 +662,13 @@ func (h *TradeHandler) UpdateTrade(c *fiber.Ctx) error {
 		_ = h.db.QueryRow("SELECT target_product_id FROM trades WHERE id = ?", tradeID).Scan(&targetPid)
 		var productTitle string
 		_ = h.db.QueryRow("SELECT title FROM products WHERE id = ?", targetPid).Scan(&productTitle)
-		_, _ = h.db.Exec("INSERT INTO notifications (user_id, type, message, is_read) VALUES (?, 'trade_update', ?, FALSE)", buyerID, "Your trade offer was countered: "+productTitle)
-		_, _ = h.db.Exec("INSERT INTO trade_events (trade_id, actor_id, from_status, to_status, note) VALUES (?, ?, ?, 'countered', ?)", tradeID, userID, currentStatus, payload.Message)
+		notify(h.db, buyerID, "trade_update", "Your trade offer was countered: "+productTitle, models.NotificationRefTrade, tradeID)
+		alertBackInStock(h.db, unlocked)
+		details, _ := json.Marshal(models.TradeOfferChange{Before: before, After: after})
+		_, _ = h.db.Exec("INSERT INTO trade_events (trade_id, actor_id, from_status, to_status, note, details) VALUES (?, ?, ?, 'countered', ?, ?)", tradeID, userID, currentStatus, payload.Message, string(details))
+
+	case "accept_with_removal":
+		return h.acceptWithRemoval(c, tradeID, userID, buyerID, sellerID, payload)
 
 	case "complete":
 		log.Printf("=== TRADE COMPLETION REQUEST ===")
@@ -458,7 +678,24 @@ func (h *TradeHandler) UpdateTrade(c *fiber.Ctx) error {
 			column = "seller_completed"
 		}
 		log.Printf("Setting %s=TRUE for trade %d", column, tradeID)
-		_, err = h.db.Exec("UPDATE trades SET "+column+"=TRUE, updated_at=CURRENT_TIMESTAMP WHERE id = ?", tradeID)
+		// Only the first mark from an open trade counts; repeats must not re-notify
+		sourceClause, sourceArgs := tradeActionSourceClause("complete")
+		var res sql.Result
+		res, err = h.db.Exec("UPDATE trades SET "+column+"=TRUE, updated_at=CURRENT_TIMESTAMP WHERE id = ? AND "+column+" = FALSE AND "+sourceClause, append([]interface{}{tradeID}, sourceArgs...)...)
+		var marked int64
+		if err == nil {
+			marked, err = res.RowsAffected()
+		}
+		if err == nil && marked == 0 {
+			var status string
+			if err := h.db.QueryRow("SELECT status FROM trades WHERE id = ?", tradeID).Scan(&status); err != nil {
+				return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to load trade"})
+			}
+			if !canApplyTradeAction("complete", status) {
+				return tradeActionFailed(c, &tradeStateError{Action: "complete", Status: status})
+			}
+			return c.JSON(models.APIResponse{Success: true, Message: "You already marked this trade completed"})
+		}
 		if err == nil {
 			log.Printf("Updated %s=TRUE for trade %d", column, tradeID)
 			var bc, sc bool
@@ -467,6 +704,12 @@ func (h *TradeHandler) UpdateTrade(c *fiber.Ctx) error {
 			if bc && sc {
 				log.Printf("Both parties completed trade %d, starting completion process", tradeID)
 				err = h.completeTradeTransaction(tradeID)
+				if errors.Is(err, errTradeAlreadyCompleted) {
+					return c.JSON(models.APIResponse{Success: true, Message: "Trade completed"})
+				}
+				if errors.Is(err, services.ErrTransactionConflict) {
+					return c.Status(409).JSON(models.APIResponse{Success: false, Error: err.Error()})
+				}
 				if err != nil {
 					log.Printf("Failed to complete product trade: %v", err)
 					return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to complete trade"})
@@ -475,8 +718,9 @@ func (h *TradeHandler) UpdateTrade(c *fiber.Ctx) error {
 				publishToUser(buyerID, sseEvent{Type: "trade_updated", Data: fiber.Map{"trade_id": tradeID, "status": "completed"}})
 				publishToUser(sellerID, sseEvent{Type: "trade_updated", Data: fiber.Map{"trade_id": tradeID, "status": "completed"}})
 				_, _ = h.db.Exec("INSERT INTO trade_events (trade_id, actor_id, from_status, to_status, note) VALUES (?, ?, 'active', 'completed', ?)", tradeID, userID, payload.Message)
-				_, _ = h.db.Exec("INSERT INTO notifications (user_id, type, message, is_read) VALUES (?, 'trade_update', ?, FALSE)", buyerID, "Trade completed")
-				_, _ = h.db.Exec("INSERT INTO notifications (user_id, type, message, is_read) VALUES (?, 'trade_update', ?, FALSE)", sellerID, "Trade completed")
+				notify(h.db, buyerID, "trade_update", "Trade completed", models.NotificationRefTrade, tradeID)
+				notify(h.db, sellerID, "trade_update", "Trade completed", models.NotificationRefTrade, tradeID)
+				promptTradeHandoff(h.db, tradeID, buyerID, sellerID)
 			} else {
 				// First completion: set first_completion_at if not set
 				_, _ = h.db.Exec("UPDATE trades SET first_completion_at = COALESCE(first_completion_at, CURRENT_TIMESTAMP) WHERE id = ?", tradeID)
@@ -484,8 +728,10 @@ func (h *TradeHandler) UpdateTrade(c *fiber.Ctx) error {
 				publishToUser(sellerID, sseEvent{Type: "trade_updated", Data: fiber.Map{"trade_id": tradeID, "status": "awaiting_other_party"}})
 				_, _ = h.db.Exec("INSERT INTO trade_events (trade_id, actor_id, from_status, to_status, note) VALUES (?, ?, 'active', 'awaiting_other_party', ?)", tradeID, userID, payload.Message)
 				// Soft reminders
-				_, _ = h.db.Exec("INSERT INTO notifications (user_id, type, message, is_read) VALUES (?, 'trade_update', ?, FALSE)", buyerID, "One party marked the trade completed. Please confirm within 24 hours.")
-				_, _ = h.db.Exec("INSERT INTO notifications (user_id, type, message, is_read) VALUES (?, 'trade_update', ?, FALSE)", sellerID, "One party marked the trade completed. Please confirm within 24 hours.")
+				reminder := fmt.Sprintf("One party marked the trade completed. Please confirm within %s.",
+					services.FormatTradeWindow(services.DefaultTradeTimeoutSettings().ConfirmWindow))
+				notify(h.db, buyerID, "trade_update", reminder, models.NotificationRefTrade, tradeID)
+				notify(h.db, sellerID, "trade_update", reminder, models.NotificationRefTrade, tradeID)
 			}
 		}
 	case "cancel":
@@ -494,8 +740,15 @@ func (h *TradeHandler) UpdateTrade(c *fiber.Ctx) error {
 			return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to start transaction"})
 		}
 
+		currentStatus, err = lockTradeForAction(tx, tradeID, "cancel")
+		if err != nil {
+			_ = tx.Rollback()
+			return tradeActionFailed(c, err)
+		}
+
 		// Unlock products
-		if err := h.setProductStatusForTrade(tx, tradeID, "available"); err != nil {
+		unlocked, err := h.setProductStatusForTrade(tx, tradeID, "available", userID)
+		if err != nil {
 			_ = tx.Rollback()
 			return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to unlock products"})
 		}
@@ -515,6 +768,7 @@ func (h *TradeHandler) UpdateTrade(c *fiber.Ctx) error {
 		publishToUser(buyerID, sseEvent{Type: "trade_updated", Data: fiber.Map{"trade_id": tradeID, "status": "cancelled"}})
 		publishToUser(sellerID, sseEvent{Type: "trade_updated", Data: fiber.Map{"trade_id": tradeID, "status": "cancelled"}})
 		_, _ = h.db.Exec("INSERT INTO trade_events (trade_id, actor_id, from_status, to_status, note) VALUES (?, ?, ?, 'cancelled', ?)", tradeID, userID, currentStatus, payload.Message)
+		alertBackInStock(h.db, unlocked)
 	default:
 		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid action"})
 	}
@@ -526,8 +780,18 @@ func (h *TradeHandler) UpdateTrade(c *fiber.Ctx) error {
 	return c.JSON(models.APIResponse{Success: true, Message: "Trade updated"})
 }
 
-// completeTradeTransaction safely completes a trade and marks all products as traded
+// errTradeAlreadyCompleted is returned by completeTradeTransaction when another request
+// finished the trade first; callers treat it as success and skip the completion side effects
+var errTradeAlreadyCompleted = errors.New("trade was already completed")
+
+// completeTradeTransaction safely completes a trade and marks all products as traded,
+// retrying on deadlock
 func (h *TradeHandler) completeTradeTransaction(tradeID int) error {
+	return services.WithRetry(func() error { return h.completeTradeTransactionOnce(tradeID) })
+}
+
+// completeTradeTransactionOnce runs a single attempt of completeTradeTransaction
+func (h *TradeHandler) completeTradeTransactionOnce(tradeID int) error {
 	log.Printf("Starting trade completion for trade ID: %d", tradeID)
 
 	tx, err := h.db.Begin()
@@ -539,7 +803,7 @@ func (h *TradeHandler) completeTradeTransaction(tradeID int) error {
 
 	// Lock the trade row to prevent concurrent completions
 	var currentStatus string
-	var targetProductID int
+	var targetProductID sql.NullInt64 // NULL once the listing was deleted
 	var buyerCompleted, sellerCompleted bool
 
 	err = tx.QueryRow(`
@@ -555,6 +819,11 @@ func (h *TradeHandler) completeTradeTransaction(tradeID int) error {
 
 	log.Printf("Trade %d status: %s, buyer_completed: %t, seller_completed: %t", tradeID, currentStatus, buyerCompleted, sellerCompleted)
 
+	// A concurrent request got here first; completing again would repeat its side effects
+	if currentStatus == "completed" || currentStatus == "auto_completed" {
+		return errTradeAlreadyCompleted
+	}
+
 	// Verify both parties have completed
 	if !buyerCompleted || !sellerCompleted {
 		log.Printf("Trade %d: Both parties must complete - buyer: %t, seller: %t", tradeID, buyerCompleted, sellerCompleted)
@@ -575,26 +844,31 @@ func (h *TradeHandler) completeTradeTransaction(tradeID int) error {
 
 	var offeredProductIDs []int
 	for rows.Next() {
-		var productID int
+		var productID sql.NullInt64
 		if err := rows.Scan(&productID); err != nil {
 			log.Printf("Failed to scan product ID for trade %d: %v", tradeID, err)
 			return fmt.Errorf("failed to scan product ID: %w", err)
 		}
-		offeredProductIDs = append(offeredProductIDs, productID)
+		// Deleted products have nothing left to mark
+		if productID.Valid {
+			offeredProductIDs = append(offeredProductIDs, int(productID.Int64))
+		}
 	}
 
-	log.Printf("Trade %d: Target product: %d, Offered products: %v", tradeID, targetProductID, offeredProductIDs)
+	log.Printf("Trade %d: Target product: %v, Offered products: %v", tradeID, targetProductID, offeredProductIDs)
 
 	// Mark target product as traded with locking
-	err = h.markProductUnavailable(tx, targetProductID)
-	if err != nil {
-		log.Printf("Failed to mark target product %d as traded: %v", targetProductID, err)
-		return fmt.Errorf("failed to mark target product as traded: %w", err)
+	if targetProductID.Valid {
+		err = h.markProductUnavailable(tx, int(targetProductID.Int64), tradeID)
+		if err != nil {
+			log.Printf("Failed to mark target product %d as traded: %v", targetProductID.Int64, err)
+			return fmt.Errorf("failed to mark target product as traded: %w", err)
+		}
 	}
 
 	// Mark all offered products as traded
 	for _, productID := range offeredProductIDs {
-		err = h.markProductUnavailable(tx, productID)
+		err = h.markProductUnavailable(tx, productID, tradeID)
 		if err != nil {
 			log.Printf("Failed to mark offered product %d as traded: %v", productID, err)
 			return fmt.Errorf("failed to mark offered product %d as traded: %w", productID, err)
@@ -612,6 +886,11 @@ func (h *TradeHandler) completeTradeTransaction(tradeID int) error {
 		return fmt.Errorf("failed to update trade status: %w", err)
 	}
 
+	if err := services.RecordTradeFee(tx, tradeID); err != nil {
+		log.Printf("Failed to record fee for trade %d: %v", tradeID, err)
+		return fmt.Errorf("failed to record trade fee: %w", err)
+	}
+
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
 		log.Printf("Failed to check trade update result for trade %d: %v", tradeID, err)
@@ -620,7 +899,7 @@ func (h *TradeHandler) completeTradeTransaction(tradeID int) error {
 
 	if rowsAffected == 0 {
 		log.Printf("Trade %d was already completed by another process", tradeID)
-		return fmt.Errorf("trade was already completed by another process")
+		return errTradeAlreadyCompleted
 	}
 
 	log.Printf("Successfully completed trade %d and marked products as traded", tradeID)
@@ -628,7 +907,7 @@ func (h *TradeHandler) completeTradeTransaction(tradeID int) error {
 }
 
 // markProductUnavailable marks a product as traded with row locking
-func (h *TradeHandler) markProductUnavailable(tx *sql.Tx, productID int) error {
+func (h *TradeHandler) markProductUnavailable(tx *sql.Tx, productID, tradeID int) error {
 	log.Printf("Attempting to mark product %d as traded", productID)
 
 	// Lock and verify product
@@ -656,7 +935,7 @@ func (h *TradeHandler) markProductUnavailable(tx *sql.Tx, productID int) error {
 	// Update product status to traded
 	result, err := tx.Exec(`
 		UPDATE products 
-		SET status = 'traded', updated_at = CURRENT_TIMESTAMP 
+		SET status = 'traded', version = version + 1, updated_at = CURRENT_TIMESTAMP 
 		WHERE id = ? AND status = 'available'`,
 		productID)
 
@@ -676,6 +955,10 @@ func (h *TradeHandler) markProductUnavailable(tx *sql.Tx, productID int) error {
 		return fmt.Errorf("product %d was modified by another transaction", productID)
 	}
 
+	if err := services.RecordProductStatusChange(tx, productID, currentStatus, "traded", 0, fmt.Sprintf("trade #%d completed", tradeID)); err != nil {
+		return fmt.Errorf("failed to record status history for product %d: %w", productID, err)
+	}
+
 	log.Printf("Successfully marked product %d as traded", productID)
 	return nil
 }
@@ -699,22 +982,23 @@ func (h *TradeHandler) GetTradeMessages(c *fiber.Ctx) error {
 	if userID != buyerID && userID != sellerID {
 		return c.Status(403).JSON(models.APIResponse{Success: false, Error: "Not authorized for this trade"})
 	}
-	rows, err := h.db.Query("SELECT id, trade_id, sender_id, content, created_at FROM trade_messages WHERE trade_id = ? ORDER BY created_at ASC", tradeID)
+	rows, err := h.db.Query("SELECT id, trade_id, sender_id, content, created_at, read_at FROM trade_messages WHERE trade_id = ? ORDER BY created_at ASC", tradeID)
 	if err != nil {
 		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to fetch messages"})
 	}
 	defer rows.Close()
 	type msg struct {
-		ID        int       `json:"id"`
-		TradeID   int       `json:"trade_id"`
-		SenderID  int       `json:"sender_id"`
-		Content   string    `json:"content"`
-		CreatedAt time.Time `json:"created_at"`
+		ID        int        `json:"id"`
+		TradeID   int        `json:"trade_id"`
+		SenderID  int        `json:"sender_id"`
+		Content   string     `json:"content"`
+		CreatedAt time.Time  `json:"created_at"`
+		ReadAt    *time.Time `json:"read_at,omitempty"`
 	}
 	list := []msg{}
 	for rows.Next() {
 		var m msg
-		if err := rows.Scan(&m.ID, &m.TradeID, &m.SenderID, &m.Content, &m.CreatedAt); err == nil {
+		if err := rows.Scan(&m.ID, &m.TradeID, &m.SenderID, &m.Content, &m.CreatedAt, &m.ReadAt); err == nil {
 			list = append(list, m)
 		}
 	}
@@ -731,27 +1015,31 @@ func (h *TradeHandler) GetTrade(c *fiber.Ctx) error {
 	if err != nil {
 		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid trade id"})
 	}
+	// The target listing may have been deleted since; the trade's snapshot still describes it
 	var tr models.Trade
+	var targetID sql.NullInt64
 	err = h.db.QueryRow(`
         SELECT 
           t.id, t.buyer_id, t.seller_id, t.target_product_id, t.status, t.message, t.offered_cash_amount, t.created_at, t.updated_at,
           t.buyer_completed, t.seller_completed, t.completed_at,
-          ub.name AS buyer_name, us.name AS seller_name, p.title AS product_title
+          ub.name AS buyer_name, us.name AS seller_name, COALESCE(t.target_snapshot_title, p.title, '') AS product_title,
+          COALESCE(t.target_snapshot_price, p.price), COALESCE(t.target_snapshot_image_url, p.image_url, '')
         FROM trades t
         JOIN users ub ON ub.id = t.buyer_id
         JOIN users us ON us.id = t.seller_id
-        JOIN products p ON p.id = t.target_product_id
+        LEFT JOIN products p ON p.id = t.target_product_id
         WHERE t.id = ?
-    `, tradeID).Scan(&tr.ID, &tr.BuyerID, &tr.SellerID, &tr.TargetProductID, &tr.Status, &tr.Message, &tr.OfferedCash, &tr.CreatedAt, &tr.UpdatedAt, &tr.BuyerCompleted, &tr.SellerCompleted, &tr.CompletedAt, &tr.BuyerName, &tr.SellerName, &tr.ProductTitle)
+    `, tradeID).Scan(&tr.ID, &tr.BuyerID, &tr.SellerID, &targetID, &tr.Status, &tr.Message, &tr.OfferedCash, &tr.CreatedAt, &tr.UpdatedAt, &tr.BuyerCompleted, &tr.SellerCompleted, &tr.CompletedAt, &tr.BuyerName, &tr.SellerName, &tr.ProductTitle, &tr.ProductPrice, &tr.ProductImageURL)
 	if err != nil {
 		return c.Status(404).JSON(models.APIResponse{Success: false, Error: "Trade not found"})
 	}
+	tr.TargetProductID = int(targetID.Int64)
 	if userID != tr.BuyerID && userID != tr.SellerID {
 		return c.Status(403).JSON(models.APIResponse{Success: false, Error: "Not authorized for this trade"})
 	}
 	itemRows, qerr := h.db.Query(`
         SELECT ti.id, ti.trade_id, ti.product_id, ti.offered_by, ti.created_at,
-               p.title, p.status, p.image_url
+               p.title, p.status, p.image_url, ti.snapshot_title, ti.snapshot_price, ti.snapshot_image_url
         FROM trade_items ti
         LEFT JOIN products p ON p.id = ti.product_id
         WHERE ti.trade_id = ?
@@ -764,7 +1052,11 @@ func (h *TradeHandler) GetTrade(c *fiber.Ctx) error {
 			var it models.TradeItem
 			var offeredBy sql.NullString
 			var title, pstatus, pimg sql.NullString
-			if err := itemRows.Scan(&it.ID, &it.TradeID, &it.ProductID, &offeredBy, &it.CreatedAt, &title, &pstatus, &pimg); err == nil {
+			var snapTitle, snapImage sql.NullString
+			var snapPrice sql.NullFloat64
+			var productID sql.NullInt64
+			if err := itemRows.Scan(&it.ID, &it.TradeID, &productID, &offeredBy, &it.CreatedAt, &title, &pstatus, &pimg, &snapTitle, &snapPrice, &snapImage); err == nil {
+				it.ProductID = int(productID.Int64)
 				if offeredBy.Valid {
 					it.OfferedBy = offeredBy.String
 				} else {
@@ -779,6 +1071,7 @@ func (h *TradeHandler) GetTrade(c *fiber.Ctx) error {
 				if pimg.Valid {
 					it.ProductImageURL = pimg.String
 				}
+				applyItemSnapshot(&it, snapTitle, snapImage, snapPrice)
 				items = append(items, it)
 			} else {
 				log.Printf("trade %d: item row scan error: %v", tr.ID, err)
@@ -789,14 +1082,18 @@ func (h *TradeHandler) GetTrade(c *fiber.Ctx) error {
 
 	// Fallback like above
 	if len(items) == 0 {
-		rows2, err2 := h.db.Query("SELECT id, trade_id, product_id, offered_by, created_at FROM trade_items WHERE trade_id = ?", tr.ID)
+		rows2, err2 := h.db.Query("SELECT id, trade_id, product_id, offered_by, created_at, snapshot_title, snapshot_price, snapshot_image_url FROM trade_items WHERE trade_id = ?", tr.ID)
 		if err2 != nil {
 			log.Printf("trade %d: fallback items query error: %v", tr.ID, err2)
 		} else {
 			for rows2.Next() {
 				var it models.TradeItem
 				var offeredBy sql.NullString
-				if err := rows2.Scan(&it.ID, &it.TradeID, &it.ProductID, &offeredBy, &it.CreatedAt); err == nil {
+				var snapTitle, snapImage sql.NullString
+				var snapPrice sql.NullFloat64
+				var productID sql.NullInt64
+				if err := rows2.Scan(&it.ID, &it.TradeID, &productID, &offeredBy, &it.CreatedAt, &snapTitle, &snapPrice, &snapImage); err == nil {
+					it.ProductID = int(productID.Int64)
 					if offeredBy.Valid {
 						it.OfferedBy = offeredBy.String
 					}
@@ -811,6 +1108,7 @@ func (h *TradeHandler) GetTrade(c *fiber.Ctx) error {
 					if pimg.Valid {
 						it.ProductImageURL = pimg.String
 					}
+					applyItemSnapshot(&it, snapTitle, snapImage, snapPrice)
 					items = append(items, it)
 				} else {
 					log.Printf("trade %d: fallback item scan error: %v", tr.ID, err)
@@ -821,6 +1119,27 @@ func (h *TradeHandler) GetTrade(c *fiber.Ctx) error {
 	}
 
 	tr.Items = items
+	tr.BuyerItems, tr.SellerItems = splitItemsBySide(items)
+	tr.CashOnly = isCashOnlyOffer(countBuyerItems(items), tr.OfferedCash)
+
+	// Attach the most recent non-cancelled delivery linked to this trade
+	var d models.TradeDeliverySummary
+	err = h.db.QueryRow(`
+		SELECT d.id, d.delivery_type, d.status, d.estimated_eta, r.name
+		FROM deliveries d
+		LEFT JOIN riders r ON r.id = d.rider_id
+		WHERE d.trade_id = ? AND d.status <> 'cancelled'
+		ORDER BY d.created_at DESC
+		LIMIT 1
+	`, tr.ID).Scan(&d.ID, &d.DeliveryType, &d.Status, &d.EstimatedETA, &d.RiderName)
+	if err == nil {
+		tr.Delivery = &d
+	} else if err != sql.ErrNoRows {
+		log.Printf("trade %d: delivery lookup error: %v", tr.ID, err)
+	}
+
+	tr.SuggestedMeetup = suggestMeetupSpot(h.db, tr.BuyerID, tr.SellerID)
+
 	return c.JSON(models.APIResponse{Success: true, Data: tr})
 }
 
@@ -841,7 +1160,7 @@ func (h *TradeHandler) GetTradeHistory(c *fiber.Ctx) error {
 	if userID != buyerID && userID != sellerID {
 		return c.Status(403).JSON(models.APIResponse{Success: false, Error: "Not authorized for this trade"})
 	}
-	rows, err := h.db.Query("SELECT id, trade_id, actor_id, from_status, to_status, note, created_at FROM trade_events WHERE trade_id = ? ORDER BY created_at ASC", tradeID)
+	rows, err := h.db.Query("SELECT id, trade_id, actor_id, from_status, to_status, note, details, created_at FROM trade_events WHERE trade_id = ? ORDER BY created_at ASC", tradeID)
 	if err != nil {
 		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to fetch history"})
 	}
@@ -854,13 +1173,15 @@ func (h *TradeHandler) GetTradeHistory(c *fiber.Ctx) error {
 		ToStatus   *string   `json:"to_status,omitempty"`
 		Note       *string   `json:"note,omitempty"`
 		CreatedAt  time.Time `json:"created_at"`
+		// What a counter-offer changed, when recorded
+		Changes *models.TradeOfferDiff `json:"changes,omitempty"`
 	}
 	list := []ev{}
 	for rows.Next() {
 		var e ev
 		var actorID sql.NullInt64
-		var fromSt, toSt, note sql.NullString
-		if err := rows.Scan(&e.ID, &e.TradeID, &actorID, &fromSt, &toSt, &note, &e.CreatedAt); err == nil {
+		var fromSt, toSt, note, details sql.NullString
+		if err := rows.Scan(&e.ID, &e.TradeID, &actorID, &fromSt, &toSt, &note, &details, &e.CreatedAt); err == nil {
 			if actorID.Valid {
 				v := int(actorID.Int64)
 				e.ActorID = &v
@@ -877,12 +1198,64 @@ func (h *TradeHandler) GetTradeHistory(c *fiber.Ctx) error {
 				v := note.String
 				e.Note = &v
 			}
+			if details.Valid {
+				var change models.TradeOfferChange
+				if err := json.Unmarshal([]byte(details.String), &change); err == nil {
+					diff := diffTradeOffers(change.Before, change.After)
+					diff.Kind = change.Kind
+					diff.Balance = change.Balance
+					e.Changes = &diff
+				}
+			}
 			list = append(list, e)
 		}
 	}
 	return c.JSON(models.APIResponse{Success: true, Data: list})
 }
 
+// MarkTradeMessagesRead marks the other party's messages in a trade as read
+// and notifies them with a trade_message_read event
+func (h *TradeHandler) MarkTradeMessagesRead(c *fiber.Ctx) error {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "User not authenticated"})
+	}
+	tradeID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid trade id"})
+	}
+	// authorize
+	var buyerID, sellerID int
+	err = h.db.QueryRow("SELECT buyer_id, seller_id FROM trades WHERE id = ?", tradeID).Scan(&buyerID, &sellerID)
+	if err != nil {
+		return c.Status(404).JSON(models.APIResponse{Success: false, Error: "Trade not found"})
+	}
+	if userID != buyerID && userID != sellerID {
+		return c.Status(403).JSON(models.APIResponse{Success: false, Error: "Not authorized for this trade"})
+	}
+
+	readAt := time.Now()
+	res, err := h.db.Exec("UPDATE trade_messages SET read_at = ? WHERE trade_id = ? AND sender_id <> ? AND read_at IS NULL", readAt, tradeID, userID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to mark messages as read"})
+	}
+	updated, _ := res.RowsAffected()
+
+	if updated > 0 {
+		otherID := buyerID
+		if userID == buyerID {
+			otherID = sellerID
+		}
+		publishToUser(otherID, sseEvent{Type: "trade_message_read", Data: fiber.Map{
+			"trade_id":  tradeID,
+			"reader_id": userID,
+			"read_at":   readAt,
+		}})
+	}
+
+	return c.JSON(models.APIResponse{Success: true, Data: fiber.Map{"updated": updated}})
+}
+
 // SendTradeMessage posts a new message for a trade and notifies participants
 func (h *TradeHandler) SendTradeMessage(c *fiber.Ctx) error {
 	userID, ok := middleware.GetUserIDFromContext(c)
@@ -908,6 +1281,12 @@ func (h *TradeHandler) SendTradeMessage(c *fiber.Ctx) error {
 	if userID != buyerID && userID != sellerID {
 		return c.Status(403).JSON(models.APIResponse{Success: false, Error: "Not authorized for this trade"})
 	}
+	if messageTooLong(payload.Content) {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: fmt.Sprintf("Message exceeds %d characters", config.MaxMessageLength())})
+	}
+	if !allowMessage("trade", userID, tradeID) {
+		return c.Status(429).JSON(models.APIResponse{Success: false, Error: "You are sending messages too quickly. Please wait a moment."})
+	}
 	// insert message
 	res, err := h.db.Exec("INSERT INTO trade_messages (trade_id, sender_id, content) VALUES (?, ?, ?)", tradeID, userID, payload.Content)
 	if err != nil {
@@ -955,6 +1334,19 @@ func (h *TradeHandler) CountTrades(c *fiber.Ctx) error {
 		status = ""
 	}
 
+	count, err := countTrades(h.db, userID, direction, status)
+	if err != nil {
+		// Log and return zero as a safe fallback to avoid 400 responses for UI polling
+		fmt.Printf("CountTrades: db query error for user=%d direction=%s status=%q: %v - returning count=0\n", userID, direction, status, err)
+		return c.JSON(models.APIResponse{Success: true, Data: fiber.Map{"count": 0}})
+	}
+
+	return c.JSON(models.APIResponse{Success: true, Data: fiber.Map{"count": count}})
+}
+
+// countTrades counts a user's trades as seller ("incoming") or buyer ("outgoing"),
+// optionally of one status. Callers validate direction and status.
+func countTrades(db *sql.DB, userID int, direction, status string) (int, error) {
 	where := "WHERE t.seller_id = ?"
 	args := []interface{}{userID}
 	if direction == "outgoing" {
@@ -966,15 +1358,8 @@ func (h *TradeHandler) CountTrades(c *fiber.Ctx) error {
 	}
 
 	var count int
-	// Use a prepared-like query with args to avoid injection and driver issues
-	query := "SELECT COUNT(*) FROM trades t " + where
-	if err := h.db.QueryRow(query, args...).Scan(&count); err != nil {
-		// Log and return zero as a safe fallback to avoid 400 responses for UI polling
-		fmt.Printf("CountTrades: db query error for user=%d query='%s' args=%v: %v - returning count=0\n", userID, query, args, err)
-		return c.JSON(models.APIResponse{Success: true, Data: fiber.Map{"count": 0}})
-	}
-
-	return c.JSON(models.APIResponse{Success: true, Data: fiber.Map{"count": count}})
+	err := db.QueryRow("SELECT COUNT(*) FROM trades t "+where, args...).Scan(&count)
+	return count, err
 }
 
 // CompleteTrade handles trade completion with rating and feedback
@@ -997,9 +1382,8 @@ func (h *TradeHandler) CompleteTrade(c *fiber.Ctx) error {
 		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid request body"})
 	}
 
-	// Validate rating
-	if payload.Rating < 1 || payload.Rating > 5 {
-		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Rating must be between 1 and 5"})
+	if err := validateRating(payload.Rating); err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: err.Error()})
 	}
 
 	// Fetch trade and verify authorization
@@ -1042,6 +1426,12 @@ func (h *TradeHandler) CompleteTrade(c *fiber.Ctx) error {
 	// If both completed, finalize the trade
 	if buyerCompleted && sellerCompleted {
 		err = h.completeTradeTransaction(tradeID)
+		if errors.Is(err, errTradeAlreadyCompleted) {
+			return c.JSON(models.APIResponse{Success: true, Message: "Trade completion submitted successfully"})
+		}
+		if errors.Is(err, services.ErrTransactionConflict) {
+			return c.Status(409).JSON(models.APIResponse{Success: false, Error: err.Error()})
+		}
 		if err != nil {
 			log.Printf("Failed to complete trade transaction: %v", err)
 			return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to finalize trade"})
@@ -1052,8 +1442,9 @@ func (h *TradeHandler) CompleteTrade(c *fiber.Ctx) error {
 		publishToUser(sellerID, sseEvent{Type: "trade_completed", Data: fiber.Map{"trade_id": tradeID}})
 
 		// Add notifications
-		_, _ = h.db.Exec("INSERT INTO notifications (user_id, type, message, is_read) VALUES (?, 'trade_update', ?, FALSE)", buyerID, "Trade completed successfully!")
-		_, _ = h.db.Exec("INSERT INTO notifications (user_id, type, message, is_read) VALUES (?, 'trade_update', ?, FALSE)", sellerID, "Trade completed successfully!")
+		notify(h.db, buyerID, "trade_update", "Trade completed successfully!", models.NotificationRefTrade, tradeID)
+		notify(h.db, sellerID, "trade_update", "Trade completed successfully!", models.NotificationRefTrade, tradeID)
+		promptTradeHandoff(h.db, tradeID, buyerID, sellerID)
 	}
 
 	return c.JSON(models.APIResponse{Success: true, Message: "Trade completion submitted successfully"})
@@ -1076,13 +1467,14 @@ func (h *TradeHandler) GetTradeCompletionStatus(c *fiber.Ctx) error {
 	var buyerCompleted, sellerCompleted bool
 	var buyerRating, sellerRating sql.NullInt64
 	var buyerFeedback, sellerFeedback sql.NullString
+	var firstCompletionAt sql.NullTime
 
 	err = h.db.QueryRow(`
 		SELECT buyer_id, seller_id, buyer_completed, seller_completed, 
-		       buyer_rating, seller_rating, buyer_feedback, seller_feedback
+		       buyer_rating, seller_rating, buyer_feedback, seller_feedback, first_completion_at
 		FROM trades WHERE id = ?`, tradeID).Scan(
 		&buyerID, &sellerID, &buyerCompleted, &sellerCompleted,
-		&buyerRating, &sellerRating, &buyerFeedback, &sellerFeedback)
+		&buyerRating, &sellerRating, &buyerFeedback, &sellerFeedback, &firstCompletionAt)
 
 	if err != nil {
 		return c.Status(404).JSON(models.APIResponse{Success: false, Error: "Trade not found"})
@@ -1111,43 +1503,104 @@ func (h *TradeHandler) GetTradeCompletionStatus(c *fiber.Ctx) error {
 	if sellerFeedback.Valid {
 		status["seller_feedback"] = sellerFeedback.String
 	}
+	if firstCompletionAt.Valid && buyerCompleted != sellerCompleted {
+		for k, v := range confirmationDeadlines(firstCompletionAt.Time, time.Now(), services.DefaultTradeTimeoutSettings()) {
+			status[k] = v
+		}
+	}
 
 	return c.JSON(models.APIResponse{Success: true, Data: status})
 }
 
-// setProductStatusForTrade updates the status of all products involved in a trade.
-func (h *TradeHandler) setProductStatusForTrade(tx *sql.Tx, tradeID int, status string) error {
-	// Get target product ID
-	var targetProductID int
-	err := tx.QueryRow("SELECT target_product_id FROM trades WHERE id = ?", tradeID).Scan(&targetProductID)
-	if err != nil {
-		return fmt.Errorf("failed to get target product for trade %d: %w", tradeID, err)
+// confirmationDeadlines describes how long the other party has left to confirm a one-sided completion
+func confirmationDeadlines(firstCompletion, now time.Time, settings services.TradeTimeoutSettings) fiber.Map {
+	confirmBy := firstCompletion.Add(settings.ConfirmWindow)
+	autoCompleteAt := firstCompletion.Add(settings.AutoCompleteAfter)
+	remaining := confirmBy.Sub(now)
+	if remaining < 0 {
+		remaining = 0
+	}
+	return fiber.Map{
+		"first_completion_at":    firstCompletion,
+		"confirm_by":             confirmBy,
+		"auto_complete_at":       autoCompleteAt,
+		"confirm_window_seconds": int(settings.ConfirmWindow.Seconds()),
+		"remaining_seconds":      int(remaining.Seconds()),
 	}
+}
 
-	// Get all offered product IDs
-	rows, err := tx.Query("SELECT product_id FROM trade_items WHERE trade_id = ?", tradeID)
+// lockTradeProductsForAccept locks the target and offered products of a trade with
+// SELECT ... FOR UPDATE and returns the title of the first one that is no longer available.
+func (h *TradeHandler) lockTradeProductsForAccept(tx *sql.Tx, tradeID int) (string, error) {
+	rows, err := tx.Query(`
+		SELECT p.id, p.title, p.status
+		FROM products p
+		WHERE p.id = (SELECT target_product_id FROM trades WHERE id = ?)
+		   OR p.id IN (SELECT product_id FROM trade_items WHERE trade_id = ?)
+		ORDER BY p.id
+		FOR UPDATE
+	`, tradeID, tradeID)
 	if err != nil {
-		return fmt.Errorf("failed to get offered items for trade %d: %w", tradeID, err)
+		return "", err
 	}
 	defer rows.Close()
 
-	var productIDs []int
-	productIDs = append(productIDs, targetProductID)
+	unavailable := ""
+	for rows.Next() {
+		var id int
+		var title, status string
+		if err := rows.Scan(&id, &title, &status); err != nil {
+			return "", err
+		}
+		if status != "available" && unavailable == "" {
+			unavailable = fmt.Sprintf("%q (#%d)", title, id)
+		}
+	}
+	return unavailable, rows.Err()
+}
+
+// tradeStatusFrom is the status a product must have for setProductStatusForTrade to move
+// it to the given status: accepting locks available products, closing unlocks locked ones
+var tradeStatusFrom = map[string]string{"locked": "available", "available": "locked"}
+
+// setProductStatusForTrade moves the products involved in a trade to status and returns
+// the ones it changed. Only products in the matching from-status are touched, so a listing
+// sold or traded elsewhere in the meantime keeps its status. Deleted products are skipped.
+func (h *TradeHandler) setProductStatusForTrade(tx *sql.Tx, tradeID int, status string, actorID int) ([]int, error) {
+	from, ok := tradeStatusFrom[status]
+	if !ok {
+		return nil, fmt.Errorf("unsupported trade product status %q", status)
+	}
+	rows, err := tx.Query(`
+		SELECT id FROM products
+		WHERE status = ?
+		  AND (id = (SELECT target_product_id FROM trades WHERE id = ?) OR id IN (SELECT product_id FROM trade_items WHERE trade_id = ?))
+		FOR UPDATE`, from, tradeID, tradeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get products for trade %d: %w", tradeID, err)
+	}
+	var changed []int
 	for rows.Next() {
 		var pid int
 		if err := rows.Scan(&pid); err != nil {
-			return fmt.Errorf("failed to scan offered item for trade %d: %w", tradeID, err)
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan product for trade %d: %w", tradeID, err)
 		}
-		productIDs = append(productIDs, pid)
+		changed = append(changed, pid)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get products for trade %d: %w", tradeID, err)
 	}
 
-	// Update status for all products
-	for _, pid := range productIDs {
-		_, err := tx.Exec("UPDATE products SET status = ? WHERE id = ?", status, pid)
-		if err != nil {
-			return fmt.Errorf("failed to update status for product %d: %w", pid, err)
+	for _, pid := range changed {
+		if _, err := tx.Exec("UPDATE products SET status = ?, version = version + 1 WHERE id = ?", status, pid); err != nil {
+			return nil, fmt.Errorf("failed to update status for product %d: %w", pid, err)
+		}
+		if err := services.RecordProductStatusChange(tx, pid, from, status, actorID, fmt.Sprintf("trade #%d", tradeID)); err != nil {
+			return nil, fmt.Errorf("failed to record status history for product %d: %w", pid, err)
 		}
 	}
 
-	return nil
+	return changed, nil
 }
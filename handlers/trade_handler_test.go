@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/gofiber/fiber/v2"
+	"github.com/xashathebest/clovia/database"
+	"github.com/xashathebest/clovia/models"
+)
+
+// openTestDB connects to the test database, skipping the test when it is unreachable
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("mysql", "test_user:test_pass@tcp(localhost:3306)/clovia_test?parseTime=true")
+	if err != nil {
+		t.Skip("Test database not available")
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		t.Skip("Test database not available")
+	}
+	return db
+}
+
+// createTestUser inserts a throwaway user and returns its id
+func createTestUser(t *testing.T, db *sql.DB, name string) int {
+	t.Helper()
+	email := fmt.Sprintf("%s_%d@wmsu.edu.ph", name, time.Now().UnixNano())
+	res, err := db.Exec("INSERT INTO users (name, email, password_hash) VALUES (?, ?, 'x')", name, email)
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+	id, _ := res.LastInsertId()
+	t.Cleanup(func() { db.Exec("DELETE FROM users WHERE id = ?", id) })
+	return int(id)
+}
+
+// createTestProduct inserts an available product owned by sellerID and returns its id
+func createTestProduct(t *testing.T, db *sql.DB, sellerID int, title string) int {
+	t.Helper()
+	res, err := db.Exec(`
+		INSERT INTO products (title, description, price, seller_id, status, allow_buying, barter_only, location)
+		VALUES (?, 'Test Description', 100.00, ?, 'available', TRUE, FALSE, 'Test Location')`, title, sellerID)
+	if err != nil {
+		t.Fatalf("Failed to create test product: %v", err)
+	}
+	id, _ := res.LastInsertId()
+	return int(id)
+}
+
+// newTestApp wires a handler behind a stub auth middleware that authenticates as userID
+func newTestApp(userID *int, register func(app *fiber.App)) *fiber.App {
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user_id", *userID)
+		return c.Next()
+	})
+	register(app)
+	return app
+}
+
+// TestCreateTradeRejectsProductInAnotherOpenTrade offers the same product in two trades
+func TestCreateTradeRejectsProductInAnotherOpenTrade(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	database.DB = db
+
+	buyerID := createTestUser(t, db, "buyer")
+	sellerID := createTestUser(t, db, "seller")
+	target1 := createTestProduct(t, db, sellerID, "Target One")
+	target2 := createTestProduct(t, db, sellerID, "Target Two")
+	offered := createTestProduct(t, db, buyerID, "Offered Item")
+
+	handler := &TradeHandler{db: db}
+	currentUser := buyerID
+	app := newTestApp(&currentUser, func(app *fiber.App) {
+		app.Post("/trades", handler.CreateTrade)
+	})
+
+	post := func(target int) (int, models.APIResponse) {
+		body := fmt.Sprintf(`{"target_product_id": %d, "offered_product_ids": [%d]}`, target, offered)
+		req := httptest.NewRequest("POST", "/trades", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		var out models.APIResponse
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+
+	status, _ := post(target1)
+	if status != 201 {
+		t.Fatalf("Expected first offer to succeed with 201, got %d", status)
+	}
+
+	var firstTradeID int
+	if err := db.QueryRow("SELECT trade_id FROM trade_items WHERE product_id = ?", offered).Scan(&firstTradeID); err != nil {
+		t.Fatalf("Failed to find first trade: %v", err)
+	}
+
+	status, out := post(target2)
+	if status != 409 {
+		t.Fatalf("Expected overlapping offer to be rejected with 409, got %d", status)
+	}
+	if !strings.Contains(out.Error, fmt.Sprintf("trade #%d", firstTradeID)) {
+		t.Errorf("Expected error to identify trade #%d, got %q", firstTradeID, out.Error)
+	}
+
+	// Cleanup
+	db.Exec("DELETE FROM trades WHERE buyer_id = ?", buyerID)
+	db.Exec("DELETE FROM products WHERE id IN (?, ?, ?)", target1, target2, offered)
+}
+
+func TestValidateTradeOffer(t *testing.T) {
+	cash := func(v float64) *float64 { return &v }
+	cases := []struct {
+		name     string
+		products []int
+		cash     *float64
+		wantErr  bool
+		cashOnly bool
+	}{
+		{"products only", []int{1, 2}, nil, false, false},
+		{"cash only", nil, cash(500), false, true},
+		{"products and cash", []int{1}, cash(100), false, false},
+		{"nothing offered", nil, nil, true, false},
+		{"zero cash alone", nil, cash(0), true, false},
+		{"negative cash", []int{1}, cash(-10), true, false},
+		{"invalid product id", []int{0}, nil, true, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateTradeOffer(tc.products, tc.cash)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("validateTradeOffer err = %v, wantErr %v", err, tc.wantErr)
+			}
+			if err == nil && isCashOnlyOffer(len(tc.products), tc.cash) != tc.cashOnly {
+				t.Errorf("isCashOnlyOffer = %v, want %v", !tc.cashOnly, tc.cashOnly)
+			}
+		})
+	}
+}
+
+// TestGetTradesPaginates pages through a buyer's trades and checks the total follows the
+// direction and status filters
+func TestGetTradesPaginates(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	buyerID := createTestUser(t, db, "paging buyer")
+	sellerID := createTestUser(t, db, "paging seller")
+	target := createTestProduct(t, db, sellerID, "Paged Target")
+	for _, status := range []string{"pending", "pending", "declined"} {
+		if _, err := db.Exec("INSERT INTO trades (buyer_id, seller_id, target_product_id, status) VALUES (?, ?, ?, ?)", buyerID, sellerID, target, status); err != nil {
+			t.Fatalf("insert trade: %v", err)
+		}
+	}
+	t.Cleanup(func() {
+		db.Exec("DELETE FROM trades WHERE buyer_id = ?", buyerID)
+		db.Exec("DELETE FROM products WHERE id = ?", target)
+	})
+
+	handler := &TradeHandler{db: db}
+	app := newTestApp(&buyerID, func(app *fiber.App) { app.Get("/trades", handler.GetTrades) })
+	get := func(query string) (total, pages int, trades []models.Trade) {
+		t.Helper()
+		resp, err := app.Test(httptest.NewRequest("GET", "/trades?"+query, nil), -1)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		if resp.StatusCode != 200 {
+			t.Fatalf("GET /trades?%s: status %d, want 200", query, resp.StatusCode)
+		}
+		var out struct {
+			Data struct {
+				Data       []models.Trade `json:"data"`
+				Total      int            `json:"total"`
+				TotalPages int            `json:"total_pages"`
+			} `json:"data"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return out.Data.Total, out.Data.TotalPages, out.Data.Data
+	}
+
+	total, pages, trades := get("limit=2&page=2")
+	if total != 3 || pages != 2 || len(trades) != 1 {
+		t.Errorf("page 2 of 2: total %d, pages %d, %d trades; want 3, 2, 1", total, pages, len(trades))
+	}
+	if len(trades) == 1 && trades[0].Items == nil {
+		t.Error("trade items not loaded for the page")
+	}
+	if total, _, trades := get("direction=outgoing&status=pending"); total != 2 || len(trades) != 2 {
+		t.Errorf("outgoing pending: total %d with %d trades, want 2", total, len(trades))
+	}
+	if total, _, _ := get("direction=incoming"); total != 0 {
+		t.Errorf("incoming for the buyer: total %d, want 0", total)
+	}
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/config"
+	"github.com/xashathebest/clovia/models"
+	"github.com/xashathebest/clovia/services"
+)
+
+// tradeRoute is where a trade's items move: pickup is the target product's location and
+// drop-off is the location of the buyer's first offered item
+type tradeRoute struct {
+	ProductIDs     []int
+	PickupAddress  string
+	PickupLat      *float64
+	PickupLon      *float64
+	DropoffAddress string
+	DropoffLat     *float64
+	DropoffLon     *float64
+}
+
+// loadTradeRoute collects the trade's products (target first) and the default pickup and
+// drop-off points used when arranging its delivery. Deleted products are left out; a
+// targetProductID of 0 means the target was deleted.
+func loadTradeRoute(db *sql.DB, tradeID, targetProductID int) (tradeRoute, error) {
+	route := tradeRoute{ProductIDs: []int{}}
+	if targetProductID > 0 {
+		route.ProductIDs = append(route.ProductIDs, targetProductID)
+		var pickupLocation sql.NullString
+		_ = db.QueryRow("SELECT location, latitude, longitude FROM products WHERE id = ?", targetProductID).Scan(&pickupLocation, &route.PickupLat, &route.PickupLon)
+		route.PickupAddress = pickupLocation.String
+	}
+
+	rows, err := db.Query(`
+		SELECT ti.product_id, ti.offered_by, p.location, p.latitude, p.longitude
+		FROM trade_items ti
+		JOIN products p ON p.id = ti.product_id
+		WHERE ti.trade_id = ?
+		ORDER BY ti.id ASC
+	`, tradeID)
+	if err != nil {
+		return route, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var pid int
+		var offeredBy string
+		var location sql.NullString
+		var lat, lon *float64
+		if err := rows.Scan(&pid, &offeredBy, &location, &lat, &lon); err != nil {
+			continue
+		}
+		route.ProductIDs = append(route.ProductIDs, pid)
+		if offeredBy == "buyer" && route.DropoffAddress == "" && location.Valid && location.String != "" {
+			route.DropoffAddress = location.String
+			route.DropoffLat, route.DropoffLon = lat, lon
+		}
+	}
+	return route, rows.Err()
+}
+
+// handoffMessage is the completion notification pointing both parties at the next step
+func handoffMessage(spot *models.MeetupSpot, deliveryID int) string {
+	switch {
+	case deliveryID > 0:
+		return fmt.Sprintf("Trade completed. A pending delivery #%d was created for the handoff.", deliveryID)
+	case spot != nil:
+		return fmt.Sprintf("Trade completed. Arrange a delivery or meet up at %s, %s.", spot.Name, spot.City)
+	default:
+		return "Trade completed. Arrange a delivery or agree on a meetup spot for the handoff."
+	}
+}
+
+// promptTradeHandoff follows a completed trade with its logistics: a pending delivery when
+// either party opted into auto_arrange_delivery, otherwise a prompt to arrange one together
+// with the meetup spot nearest both parties. Failures are logged; completion already succeeded.
+func promptTradeHandoff(db *sql.DB, tradeID, buyerID, sellerID int) {
+	var targetProductID sql.NullInt64
+	var buyerAuto, sellerAuto bool
+	err := db.QueryRow(`
+		SELECT t.target_product_id,
+			COALESCE((SELECT auto_arrange_delivery FROM users WHERE id = t.buyer_id), FALSE),
+			COALESCE((SELECT auto_arrange_delivery FROM users WHERE id = t.seller_id), FALSE)
+		FROM trades t WHERE t.id = ?`, tradeID).Scan(&targetProductID, &buyerAuto, &sellerAuto)
+	if err != nil {
+		log.Printf("Warning: failed to load trade %d for handoff: %v", tradeID, err)
+		return
+	}
+
+	deliveryID := 0
+	if buyerAuto || sellerAuto {
+		owner := buyerID
+		if !buyerAuto {
+			owner = sellerID
+		}
+		deliveryID, err = createTradeDeliveryDraft(db, tradeID, int(targetProductID.Int64), owner)
+		if err != nil {
+			log.Printf("Warning: failed to create delivery for trade %d: %v", tradeID, err)
+		}
+	}
+
+	spot := suggestMeetupSpot(db, buyerID, sellerID)
+	message := handoffMessage(spot, deliveryID)
+	data := fiber.Map{
+		"trade_id":         tradeID,
+		"arrange_delivery": fmt.Sprintf("/api/trades/%d/arrange-delivery", tradeID),
+		"meetup_spot":      spot,
+	}
+	if deliveryID > 0 {
+		data["delivery_id"] = deliveryID
+	}
+	for _, uid := range []int{buyerID, sellerID} {
+		// The delivery_prompt event below carries the links, so only the row goes through Notify
+		services.Notify(db, uid, "delivery_prompt", message, models.NotificationRefTrade, tradeID)
+		publishToUser(uid, sseEvent{Type: "delivery_prompt", Data: data})
+	}
+}
+
+// createTradeDeliveryDraft inserts a pending standard delivery for the trade on behalf of
+// userID (targetProductID is 0 when the target was deleted), reusing an existing one if the trade already has a delivery. It applies the same
+// item cap and fragile marking as a requested delivery, so a trade with more items than a
+// standard batch gets no draft rather than one no rider could claim.
+func createTradeDeliveryDraft(db *sql.DB, tradeID, targetProductID, userID int) (int, error) {
+	existing, err := activeTradeDelivery(db, tradeID)
+	if err != nil || existing != 0 {
+		return existing, err
+	}
+
+	route, err := loadTradeRoute(db, tradeID, targetProductID)
+	if err != nil {
+		return 0, err
+	}
+	if len(route.ProductIDs) == 0 {
+		// Every listing in the trade was deleted; there is nothing to carry
+		return 0, nil
+	}
+	if err := checkDeliveryItems(config.CurrentDeliveryLimits(), "standard", 0, len(route.ProductIDs)); err != nil {
+		return 0, err
+	}
+	fragileItems, err := checkFragileItems(db, route.ProductIDs)
+	if err != nil {
+		log.Printf("Warning: failed to check fragile items for trade %d: %v", tradeID, err)
+		fragileItems = map[int]bool{}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		INSERT INTO deliveries (
+			user_id, trade_id, delivery_type, status,
+			pickup_latitude, pickup_longitude, pickup_address,
+			delivery_latitude, delivery_longitude, delivery_address,
+			total_cost, item_count, is_fragile
+		) VALUES (?, ?, 'standard', 'pending', ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, userID, tradeID,
+		route.PickupLat, route.PickupLon, route.PickupAddress,
+		route.DropoffLat, route.DropoffLon, route.DropoffAddress,
+		calculateCost("standard"), len(route.ProductIDs), len(fragileItems) > 0)
+	if isDuplicateEntry(err) {
+		// The other party arranged one in the meantime
+		tx.Rollback()
+		return activeTradeDelivery(db, tradeID)
+	}
+	if err != nil {
+		return 0, err
+	}
+	id64, _ := res.LastInsertId()
+	deliveryID := int(id64)
+
+	for _, productID := range route.ProductIDs {
+		if _, err := tx.Exec(`
+			INSERT INTO delivery_items (delivery_id, product_id, product_name, is_fragile)
+			SELECT ?, id, title, ? FROM products WHERE id = ?
+		`, deliveryID, fragileItems[productID], productID); err != nil {
+			return 0, err
+		}
+	}
+	return deliveryID, tx.Commit()
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/xashathebest/clovia/models"
+)
+
+func TestHandoffMessage(t *testing.T) {
+	spot := &models.MeetupSpot{Name: "Main Gate", City: "Zamboanga"}
+
+	if got := handoffMessage(spot, 7); !strings.Contains(got, "delivery #7") {
+		t.Errorf("with delivery: %q should mention the created delivery", got)
+	}
+	if got := handoffMessage(spot, 0); !strings.Contains(got, "Main Gate, Zamboanga") {
+		t.Errorf("with spot: %q should suggest the meetup spot", got)
+	}
+	if got := handoffMessage(nil, 0); !strings.Contains(got, "Arrange a delivery") {
+		t.Errorf("fallback: %q should prompt to arrange a delivery", got)
+	}
+}
+
+// TestCreateTradeDeliveryDraftReusesExisting creates the draft twice for one trade
+func TestCreateTradeDeliveryDraftReusesExisting(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	buyerID := createTestUser(t, db, "handoff_buyer")
+	sellerID := createTestUser(t, db, "handoff_seller")
+	target := createTestProduct(t, db, sellerID, "Handoff Target")
+	offered := createTestProduct(t, db, buyerID, "Handoff Offer")
+
+	res, err := db.Exec("INSERT INTO trades (buyer_id, seller_id, target_product_id, status) VALUES (?, ?, ?, 'completed')", buyerID, sellerID, target)
+	if err != nil {
+		t.Fatalf("insert trade: %v", err)
+	}
+	id64, _ := res.LastInsertId()
+	tradeID := int(id64)
+	t.Cleanup(func() { db.Exec("DELETE FROM trades WHERE id = ?", tradeID) })
+	if _, err := db.Exec("INSERT INTO trade_items (trade_id, product_id, offered_by) VALUES (?, ?, 'buyer')", tradeID, offered); err != nil {
+		t.Fatalf("insert trade item: %v", err)
+	}
+
+	first, err := createTradeDeliveryDraft(db, tradeID, target, buyerID)
+	if err != nil {
+		t.Fatalf("first draft: %v", err)
+	}
+	t.Cleanup(func() { db.Exec("DELETE FROM deliveries WHERE id = ?", first) })
+	second, err := createTradeDeliveryDraft(db, tradeID, target, sellerID)
+	if err != nil {
+		t.Fatalf("second draft: %v", err)
+	}
+	if first != second {
+		t.Errorf("second draft = %d, want existing delivery %d", second, first)
+	}
+
+	var items int
+	if err := db.QueryRow("SELECT COUNT(*) FROM delivery_items WHERE delivery_id = ?", first).Scan(&items); err != nil {
+		t.Fatalf("count items: %v", err)
+	}
+	if items != 2 {
+		t.Errorf("delivery items = %d, want 2", items)
+	}
+}
+
+// TestCreateTradeDeliveryDraftRespectsItemCap refuses to draft a delivery for a trade with
+// more items than a standard batch allows
+func TestCreateTradeDeliveryDraftRespectsItemCap(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	t.Setenv("DELIVERY_STANDARD_MAX_ITEMS", "2")
+
+	buyerID := createTestUser(t, db, "handoff_cap_buyer")
+	sellerID := createTestUser(t, db, "handoff_cap_seller")
+	target := createTestProduct(t, db, sellerID, "Handoff Cap Target")
+	res, err := db.Exec("INSERT INTO trades (buyer_id, seller_id, target_product_id, status) VALUES (?, ?, ?, 'completed')", buyerID, sellerID, target)
+	if err != nil {
+		t.Fatalf("insert trade: %v", err)
+	}
+	id64, _ := res.LastInsertId()
+	tradeID := int(id64)
+	t.Cleanup(func() { db.Exec("DELETE FROM trades WHERE id = ?", tradeID) })
+	for _, title := range []string{"Handoff Cap Offer 1", "Handoff Cap Offer 2"} {
+		offered := createTestProduct(t, db, buyerID, title)
+		if _, err := db.Exec("INSERT INTO trade_items (trade_id, product_id, offered_by) VALUES (?, ?, 'buyer')", tradeID, offered); err != nil {
+			t.Fatalf("insert trade item: %v", err)
+		}
+	}
+
+	if id, err := createTradeDeliveryDraft(db, tradeID, target, buyerID); err == nil {
+		db.Exec("DELETE FROM deliveries WHERE id = ?", id)
+		t.Fatalf("drafted delivery %d for 3 items with a cap of 2", id)
+	}
+	var deliveries int
+	db.QueryRow("SELECT COUNT(*) FROM deliveries WHERE trade_id = ?", tradeID).Scan(&deliveries)
+	if deliveries != 0 {
+		t.Errorf("deliveries for trade = %d, want none", deliveries)
+	}
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/xashathebest/clovia/middleware"
+	"github.com/xashathebest/clovia/models"
+)
+
+// Sides of a trade an item can be offered from
+const (
+	sideBuyer  = "buyer"
+	sideSeller = "seller"
+)
+
+// errTradeItemSide is returned when an item's offered_by doesn't match its owner's role
+var errTradeItemSide = errors.New("offered item does not belong to that side of the trade")
+
+// expectedOfferedBy is the side a product's owner is on in a trade, or "" when the owner
+// is neither party
+func expectedOfferedBy(ownerID, buyerID, sellerID int) string {
+	switch ownerID {
+	case buyerID:
+		return sideBuyer
+	case sellerID:
+		return sideSeller
+	}
+	return ""
+}
+
+// checkItemSide verifies that productOwner may offer an item as offeredBy in the trade
+func checkItemSide(q queryRower, tradeID, productOwner int, offeredBy string) error {
+	var buyerID, sellerID int
+	if err := q.QueryRow("SELECT buyer_id, seller_id FROM trades WHERE id = ?", tradeID).Scan(&buyerID, &sellerID); err != nil {
+		return err
+	}
+	if expectedOfferedBy(productOwner, buyerID, sellerID) != offeredBy {
+		return errTradeItemSide
+	}
+	return nil
+}
+
+// tradeItemMismatch is an item whose offered_by disagrees with who owns the product
+type tradeItemMismatch struct {
+	ItemID    int    `json:"item_id"`
+	ProductID int    `json:"product_id"`
+	OfferedBy string `json:"offered_by"`
+	// Expected is the owner's side, or empty when the owner is no longer a party to the trade
+	Expected string `json:"expected,omitempty"`
+}
+
+// findTradeItemMismatches lists items of a trade whose offered_by doesn't match the owner
+func findTradeItemMismatches(db *sql.DB, tradeID int) ([]tradeItemMismatch, error) {
+	rows, err := db.Query(`
+		SELECT ti.id, ti.product_id, ti.offered_by, p.seller_id, t.buyer_id, t.seller_id
+		FROM trade_items ti
+		JOIN trades t ON t.id = ti.trade_id
+		LEFT JOIN products p ON p.id = ti.product_id
+		WHERE ti.trade_id = ?
+		ORDER BY ti.id`, tradeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mismatches := []tradeItemMismatch{}
+	for rows.Next() {
+		var m tradeItemMismatch
+		var productID, owner sql.NullInt64
+		var buyerID, sellerID int
+		if err := rows.Scan(&m.ItemID, &productID, &m.OfferedBy, &owner, &buyerID, &sellerID); err != nil {
+			return nil, err
+		}
+		// A deleted product has no owner to check against; its snapshot keeps the side
+		if !productID.Valid {
+			continue
+		}
+		m.ProductID = int(productID.Int64)
+		if owner.Valid {
+			m.Expected = expectedOfferedBy(int(owner.Int64), buyerID, sellerID)
+		}
+		if m.Expected != m.OfferedBy {
+			mismatches = append(mismatches, m)
+		}
+	}
+	return mismatches, rows.Err()
+}
+
+// repairTradeItemSides corrects offered_by on items whose owner is still a party to the
+// trade. Items owned by neither party can't be assigned a side and are only reported.
+func repairTradeItemSides(db *sql.DB, tradeID int) (fixed int, unresolved []tradeItemMismatch, err error) {
+	mismatches, err := findTradeItemMismatches(db, tradeID)
+	if err != nil {
+		return 0, nil, err
+	}
+	unresolved = []tradeItemMismatch{}
+	for _, m := range mismatches {
+		if m.Expected == "" {
+			unresolved = append(unresolved, m)
+			continue
+		}
+		if _, err := db.Exec("UPDATE trade_items SET offered_by = ? WHERE id = ?", m.Expected, m.ItemID); err != nil {
+			return fixed, unresolved, err
+		}
+		fixed++
+	}
+	return fixed, unresolved, nil
+}
+
+// splitItemsBySide separates a trade's items into the buyer's and the seller's contributions
+func splitItemsBySide(items []models.TradeItem) (buyer, seller []models.TradeItem) {
+	buyer, seller = []models.TradeItem{}, []models.TradeItem{}
+	for _, it := range items {
+		if it.OfferedBy == sideSeller {
+			seller = append(seller, it)
+		} else {
+			buyer = append(buyer, it)
+		}
+	}
+	return buyer, seller
+}
+
+// RepairTradeItems fixes offered_by on a trade's items to match each product's owner.
+// POST /api/admin/trades/:id/repair-items
+func (h *AdminHandler) RepairTradeItems(c *fiber.Ctx) error {
+	adminID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "User not authenticated"})
+	}
+	tradeID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid trade id"})
+	}
+	var buyerID int
+	if err := h.db.QueryRow("SELECT buyer_id FROM trades WHERE id = ?", tradeID).Scan(&buyerID); err != nil {
+		return c.Status(404).JSON(models.APIResponse{Success: false, Error: "Trade not found"})
+	}
+
+	fixed, unresolved, err := repairTradeItemSides(h.db, tradeID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to repair trade items"})
+	}
+	if fixed > 0 {
+		details := fiber.Map{"trade_id": tradeID, "fixed": fixed}
+		if err := recordAdminAction(h.db, adminID, "repair_trade_items", buyerID, details, c.IP()); err != nil {
+			return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to record admin action"})
+		}
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: fmt.Sprintf("Repaired %d item(s)", fixed),
+		Data:    fiber.Map{"trade_id": tradeID, "fixed": fixed, "unresolved": unresolved},
+	})
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/xashathebest/clovia/models"
+)
+
+func TestExpectedOfferedBy(t *testing.T) {
+	if got := expectedOfferedBy(1, 1, 2); got != sideBuyer {
+		t.Errorf("buyer-owned = %q, want buyer", got)
+	}
+	if got := expectedOfferedBy(2, 1, 2); got != sideSeller {
+		t.Errorf("seller-owned = %q, want seller", got)
+	}
+	if got := expectedOfferedBy(3, 1, 2); got != "" {
+		t.Errorf("outsider-owned = %q, want empty", got)
+	}
+}
+
+func TestSplitItemsBySide(t *testing.T) {
+	buyer, seller := splitItemsBySide([]models.TradeItem{
+		{ProductID: 1, OfferedBy: sideBuyer},
+		{ProductID: 2, OfferedBy: sideSeller},
+		{ProductID: 3, OfferedBy: sideBuyer},
+	})
+	if len(buyer) != 2 || len(seller) != 1 || seller[0].ProductID != 2 {
+		t.Errorf("split = %v / %v", buyer, seller)
+	}
+}
+
+func TestRepairTradeItemSides(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	buyerID := createTestUser(t, db, "sides_buyer")
+	sellerID := createTestUser(t, db, "sides_seller")
+	outsiderID := createTestUser(t, db, "sides_outsider")
+	target := createTestProduct(t, db, sellerID, "Sides target")
+	buyerItem := createTestProduct(t, db, buyerID, "Buyer item")
+	sellerItem := createTestProduct(t, db, sellerID, "Seller item")
+	strayItem := createTestProduct(t, db, outsiderID, "Stray item")
+
+	res, err := db.Exec("INSERT INTO trades (buyer_id, seller_id, target_product_id, status) VALUES (?, ?, ?, 'countered')", buyerID, sellerID, target)
+	if err != nil {
+		t.Fatalf("insert trade: %v", err)
+	}
+	id64, _ := res.LastInsertId()
+	tradeID := int(id64)
+	t.Cleanup(func() { db.Exec("DELETE FROM trades WHERE id = ?", tradeID) })
+
+	// The seller's item is recorded on the buyer's side, and a stranger's item slipped in
+	db.Exec("INSERT INTO trade_items (trade_id, product_id, offered_by) VALUES (?, ?, 'buyer'), (?, ?, 'buyer'), (?, ?, 'seller')",
+		tradeID, buyerItem, tradeID, sellerItem, tradeID, strayItem)
+
+	fixed, unresolved, err := repairTradeItemSides(db, tradeID)
+	if err != nil {
+		t.Fatalf("repairTradeItemSides: %v", err)
+	}
+	if fixed != 1 || len(unresolved) != 1 || unresolved[0].ProductID != strayItem {
+		t.Fatalf("fixed %d, unresolved %+v; want 1 fixed and the stray item unresolved", fixed, unresolved)
+	}
+	var side string
+	db.QueryRow("SELECT offered_by FROM trade_items WHERE trade_id = ? AND product_id = ?", tradeID, sellerItem).Scan(&side)
+	if side != sideSeller {
+		t.Errorf("seller item side = %q after repair, want seller", side)
+	}
+	if remaining, _ := findTradeItemMismatches(db, tradeID); len(remaining) != 1 {
+		t.Errorf("mismatches after repair = %+v, want only the stray item", remaining)
+	}
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import "testing"
+
+func TestLoadTradeItemsGroupsByTrade(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	sellerID := createTestUser(t, db, "items_seller")
+	buyerID := createTestUser(t, db, "items_buyer")
+	target := createTestProduct(t, db, sellerID, "Target")
+	offerA := createTestProduct(t, db, buyerID, "Offer A")
+	offerB := createTestProduct(t, db, buyerID, "Offer B")
+
+	var tradeIDs []int
+	for i := 0; i < 2; i++ {
+		res, err := db.Exec("INSERT INTO trades (buyer_id, seller_id, target_product_id, status) VALUES (?, ?, ?, 'pending')", buyerID, sellerID, target)
+		if err != nil {
+			t.Fatalf("insert trade: %v", err)
+		}
+		id64, _ := res.LastInsertId()
+		tradeIDs = append(tradeIDs, int(id64))
+	}
+	t.Cleanup(func() { db.Exec("DELETE FROM trades WHERE buyer_id = ?", buyerID) })
+	db.Exec("INSERT INTO trade_items (trade_id, product_id, offered_by, snapshot_title) VALUES (?, ?, 'buyer', 'Offer A (original)')", tradeIDs[0], offerA)
+	db.Exec("INSERT INTO trade_items (trade_id, product_id, offered_by) VALUES (?, ?, 'buyer')", tradeIDs[0], offerB)
+
+	items, err := loadTradeItems(db, tradeIDs)
+	if err != nil {
+		t.Fatalf("loadTradeItems: %v", err)
+	}
+	if got := len(items[tradeIDs[0]]); got != 2 {
+		t.Fatalf("trade %d has %d items, want 2", tradeIDs[0], got)
+	}
+	if got := len(items[tradeIDs[1]]); got != 0 {
+		t.Errorf("trade %d has %d items, want 0", tradeIDs[1], got)
+	}
+	first := items[tradeIDs[0]][0]
+	if first.ProductTitle != "Offer A (original)" || !first.ProductChanged {
+		t.Errorf("first item = %+v, want snapshot title and changed flag", first)
+	}
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/xashathebest/clovia/models"
+	"github.com/xashathebest/clovia/services"
+)
+
+const tradeLoopMessage = "Loop Trade Found! A potential multi-way trade is available."
+
+// loopRecipient is a user to notify about trade loops and the trade the notification links to
+type loopRecipient struct {
+	UserID  int
+	TradeID int
+}
+
+// loopRecipients flattens every found loop into one entry per user, in order of first
+// appearance. A user in several loops is linked to the first of their trades seen.
+func loopRecipients(loops [][]services.TradeEdge) []loopRecipient {
+	seen := make(map[int]bool)
+	var recipients []loopRecipient
+	for _, loop := range loops {
+		for _, edge := range loop {
+			if seen[edge.FromUser] {
+				continue
+			}
+			seen[edge.FromUser] = true
+			recipients = append(recipients, loopRecipient{UserID: edge.FromUser, TradeID: edge.TradeID})
+		}
+	}
+	return recipients
+}
+
+// notifyTradeLoops stores one trade_loop notification per recipient in a single
+// multi-row INSERT, then publishes once per user.
+func notifyTradeLoops(db execer, loops [][]services.TradeEdge, publish func(userID int, message string)) error {
+	recipients := loopRecipients(loops)
+	if len(recipients) == 0 {
+		return nil
+	}
+
+	rows := make([]string, 0, len(recipients))
+	args := make([]interface{}, 0, len(recipients)*4)
+	for _, r := range recipients {
+		rows = append(rows, "(?, 'trade_loop', ?, FALSE, ?, ?)")
+		args = append(args, r.UserID, tradeLoopMessage, models.NotificationRefTrade, r.TradeID)
+	}
+	query := "INSERT INTO notifications (user_id, type, message, is_read, reference_type, reference_id) VALUES " + strings.Join(rows, ", ")
+	if _, err := db.Exec(query, args...); err != nil {
+		return err
+	}
+
+	for _, r := range recipients {
+		publish(r.UserID, tradeLoopMessage)
+	}
+	return nil
+}
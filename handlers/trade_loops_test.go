@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/xashathebest/clovia/services"
+)
+
+// recordingExecer captures statements instead of running them
+type recordingExecer struct {
+	queries []string
+	args    [][]interface{}
+}
+
+func (r *recordingExecer) Exec(query string, args ...interface{}) (sql.Result, error) {
+	r.queries = append(r.queries, query)
+	r.args = append(r.args, args)
+	return nil, nil
+}
+
+func TestNotifyTradeLoopsOverlappingLoops(t *testing.T) {
+	// Users 1 and 2 sit in both loops; user 4 only in the second
+	loops := [][]services.TradeEdge{
+		{{FromUser: 1, ToUser: 2, TradeID: 10}, {FromUser: 2, ToUser: 3, TradeID: 11}, {FromUser: 3, ToUser: 1, TradeID: 12}},
+		{{FromUser: 2, ToUser: 1, TradeID: 13}, {FromUser: 1, ToUser: 4, TradeID: 14}, {FromUser: 4, ToUser: 2, TradeID: 15}},
+	}
+
+	db := &recordingExecer{}
+	published := map[int]int{}
+	if err := notifyTradeLoops(db, loops, func(userID int, _ string) { published[userID]++ }); err != nil {
+		t.Fatalf("notifyTradeLoops: %v", err)
+	}
+
+	if len(db.queries) != 1 {
+		t.Fatalf("expected a single INSERT, got %d statements", len(db.queries))
+	}
+	if got := strings.Count(db.queries[0], "(?, 'trade_loop'"); got != 4 {
+		t.Errorf("expected 4 value rows, got %d: %s", got, db.queries[0])
+	}
+
+	inserted := map[int]int{}
+	for i := 0; i < len(db.args[0]); i += 4 {
+		inserted[db.args[0][i].(int)]++
+	}
+	for _, user := range []int{1, 2, 3, 4} {
+		if inserted[user] != 1 {
+			t.Errorf("user %d: %d notification rows, want 1", user, inserted[user])
+		}
+		if published[user] != 1 {
+			t.Errorf("user %d: published %d times, want 1", user, published[user])
+		}
+	}
+	if len(published) != 4 {
+		t.Errorf("published to %d users, want 4", len(published))
+	}
+}
+
+func TestNotifyTradeLoopsNoLoops(t *testing.T) {
+	db := &recordingExecer{}
+	if err := notifyTradeLoops(db, nil, func(int, string) { t.Error("unexpected publish") }); err != nil {
+		t.Fatalf("notifyTradeLoops: %v", err)
+	}
+	if len(db.queries) != 0 {
+		t.Errorf("expected no statements, got %d", len(db.queries))
+	}
+}
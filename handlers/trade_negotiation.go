@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/models"
+)
+
+// snapshotTradeOffer captures the items and cash currently on the table for a trade
+func snapshotTradeOffer(tx *sql.Tx, tradeID int) (models.TradeOfferSnapshot, error) {
+	snap := models.TradeOfferSnapshot{Items: []models.TradeSnapshotItem{}}
+	if err := tx.QueryRow("SELECT offered_cash_amount FROM trades WHERE id = ?", tradeID).Scan(&snap.Cash); err != nil {
+		return snap, err
+	}
+	rows, err := tx.Query(`
+		SELECT ti.product_id, COALESCE(p.title, ''), ti.offered_by
+		FROM trade_items ti
+		LEFT JOIN products p ON p.id = ti.product_id
+		WHERE ti.trade_id = ?
+		ORDER BY ti.product_id
+	`, tradeID)
+	if err != nil {
+		return snap, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var it models.TradeSnapshotItem
+		if err := rows.Scan(&it.ProductID, &it.Title, &it.OfferedBy); err != nil {
+			return snap, err
+		}
+		snap.Items = append(snap.Items, it)
+	}
+	return snap, rows.Err()
+}
+
+// diffTradeOffers compares two offer snapshots and describes what changed
+func diffTradeOffers(before, after models.TradeOfferSnapshot) models.TradeOfferDiff {
+	diff := models.TradeOfferDiff{
+		AddedItems:   []models.TradeSnapshotItem{},
+		RemovedItems: []models.TradeSnapshotItem{},
+		CashBefore:   before.Cash,
+		CashAfter:    after.Cash,
+	}
+
+	inBefore := make(map[int]bool, len(before.Items))
+	for _, it := range before.Items {
+		inBefore[it.ProductID] = true
+	}
+	inAfter := make(map[int]bool, len(after.Items))
+	for _, it := range after.Items {
+		inAfter[it.ProductID] = true
+		if !inBefore[it.ProductID] {
+			diff.AddedItems = append(diff.AddedItems, it)
+		}
+	}
+	for _, it := range before.Items {
+		if !inAfter[it.ProductID] {
+			diff.RemovedItems = append(diff.RemovedItems, it)
+		}
+	}
+
+	var parts []string
+	if len(diff.AddedItems) > 0 {
+		parts = append(parts, "Added "+describeSnapshotItems(diff.AddedItems))
+	}
+	if len(diff.RemovedItems) > 0 {
+		parts = append(parts, "Removed "+describeSnapshotItems(diff.RemovedItems))
+	}
+	if !sameCash(before.Cash, after.Cash) {
+		parts = append(parts, fmt.Sprintf("Cash %s → %s", formatCash(before.Cash), formatCash(after.Cash)))
+	}
+	if len(parts) == 0 {
+		diff.Summary = "No changes to items or cash"
+	} else {
+		diff.Summary = strings.Join(parts, "; ")
+	}
+	return diff
+}
+
+func describeSnapshotItems(items []models.TradeSnapshotItem) string {
+	names := make([]string, 0, len(items))
+	for _, it := range items {
+		if it.Title != "" {
+			names = append(names, fmt.Sprintf("%s (#%d)", it.Title, it.ProductID))
+		} else {
+			names = append(names, fmt.Sprintf("#%d", it.ProductID))
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+func sameCash(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+func formatCash(v *float64) string {
+	if v == nil {
+		return "none"
+	}
+	return fmt.Sprintf("%.2f", *v)
+}
+
+// planItemRemoval validates an accept_with_removal request against the current offer and
+// returns the de-duplicated product ids to drop. Only the buyer's items can be removed and
+// something (an item or cash) must remain on the table.
+func planItemRemoval(items []models.TradeSnapshotItem, remove []int, cash *float64) ([]int, error) {
+	if len(remove) == 0 {
+		return nil, errors.New("remove_product_ids is required")
+	}
+	buyerItems := make(map[int]bool)
+	for _, it := range items {
+		if it.OfferedBy == "buyer" {
+			buyerItems[it.ProductID] = true
+		}
+	}
+
+	seen := make(map[int]bool, len(remove))
+	ids := make([]int, 0, len(remove))
+	for _, pid := range remove {
+		if seen[pid] {
+			continue
+		}
+		if !buyerItems[pid] {
+			return nil, fmt.Errorf("Product %d is not one of the buyer's offered items", pid)
+		}
+		seen[pid] = true
+		ids = append(ids, pid)
+	}
+
+	hasCash := cash != nil && *cash > 0
+	if len(items)-len(ids) == 0 && !hasCash {
+		return nil, errors.New("Removing every item leaves nothing to trade; decline the offer instead")
+	}
+	return ids, nil
+}
+
+// tradeOfferBalance compares the offered items and cash against the target product's price
+func tradeOfferBalance(tx *sql.Tx, tradeID int) (models.TradeBalance, error) {
+	var b models.TradeBalance
+	var cash sql.NullFloat64
+	err := tx.QueryRow(`
+		SELECT COALESCE(p.price, 0), t.offered_cash_amount
+		FROM trades t
+		JOIN products p ON p.id = t.target_product_id
+		WHERE t.id = ?
+	`, tradeID).Scan(&b.TargetValue, &cash)
+	if err != nil {
+		return b, err
+	}
+	var buyerItems int
+	err = tx.QueryRow(`
+		SELECT COALESCE(SUM(p.price), 0), COALESCE(SUM(ti.offered_by = 'buyer'), 0)
+		FROM trade_items ti
+		JOIN products p ON p.id = ti.product_id
+		WHERE ti.trade_id = ?
+	`, tradeID).Scan(&b.OfferedValue, &buyerItems)
+	if err != nil {
+		return b, err
+	}
+	if cash.Valid {
+		b.Cash = cash.Float64
+	}
+	b.CashOnly = buyerItems == 0 && b.Cash > 0
+	b.Balance = b.OfferedValue + b.Cash - b.TargetValue
+	return b, nil
+}
+
+// acceptWithRemoval lets the seller drop some of the buyer's offered items and accept the rest.
+// It is a constrained counter: the remaining offer is kept, the trade moves to countered and
+// nothing is locked until the buyer accepts the trimmed offer.
+func (h *TradeHandler) acceptWithRemoval(c *fiber.Ctx, tradeID, userID, buyerID, sellerID int, payload models.TradeAction) error {
+	if userID != sellerID {
+		return c.Status(403).JSON(models.APIResponse{Success: false, Error: "Only the seller can accept with removal"})
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to start transaction"})
+	}
+	defer tx.Rollback()
+
+	// Re-read the status under a row lock so a concurrent accept or cancel wins cleanly
+	currentStatus, err := lockTradeForAction(tx, tradeID, "accept_with_removal")
+	if err == nil {
+		// A seller cannot trim and then accept their own counter
+		err = checkOfferResponder(tx, tradeID, userID)
+	}
+	if err != nil {
+		return tradeActionFailed(c, err)
+	}
+
+	before, err := snapshotTradeOffer(tx, tradeID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to read current offer"})
+	}
+	removeIDs, err := planItemRemoval(before.Items, payload.RemoveProductIDs, before.Cash)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: err.Error()})
+	}
+
+	for _, pid := range removeIDs {
+		if _, err := tx.Exec("DELETE FROM trade_items WHERE trade_id = ? AND product_id = ? AND offered_by = 'buyer'", tradeID, pid); err != nil {
+			return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to remove offered items"})
+		}
+	}
+	if _, err := tx.Exec("UPDATE trades SET status='countered', countered_by=?, updated_at=CURRENT_TIMESTAMP WHERE id = ?", userID, tradeID); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to update trade"})
+	}
+
+	after, err := snapshotTradeOffer(tx, tradeID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to read updated offer"})
+	}
+	balance, err := tradeOfferBalance(tx, tradeID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to compute trade balance"})
+	}
+
+	change := models.TradeOfferChange{Kind: "accept_with_removal", Before: before, After: after, Balance: &balance}
+	details, _ := json.Marshal(change)
+	if _, err := tx.Exec("INSERT INTO trade_events (trade_id, actor_id, from_status, to_status, note, details) VALUES (?, ?, ?, 'countered', ?, ?)", tradeID, userID, currentStatus, payload.Message, string(details)); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to record trade history"})
+	}
+	if err := tx.Commit(); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to commit trade update"})
+	}
+
+	diff := diffTradeOffers(before, after)
+	diff.Kind = change.Kind
+	diff.Balance = &balance
+
+	publishToUser(buyerID, sseEvent{Type: "trade_updated", Data: fiber.Map{"trade_id": tradeID, "status": "countered"}})
+	publishToUser(sellerID, sseEvent{Type: "trade_updated", Data: fiber.Map{"trade_id": tradeID, "status": "countered"}})
+	notify(h.db, buyerID, "trade_update", "The seller will accept your offer without some items. Please confirm: "+diff.Summary, models.NotificationRefTrade, tradeID)
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Offer trimmed; waiting for the buyer to confirm",
+		Data:    diff,
+	})
+}
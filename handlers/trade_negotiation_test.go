@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/database"
+	"github.com/xashathebest/clovia/models"
+)
+
+func TestDiffTradeOffers(t *testing.T) {
+	cash100, cash250 := 100.0, 250.0
+	before := models.TradeOfferSnapshot{
+		Items: []models.TradeSnapshotItem{
+			{ProductID: 9, Title: "Phone", OfferedBy: "buyer"},
+			{ProductID: 10, Title: "Case", OfferedBy: "buyer"},
+		},
+		Cash: &cash100,
+	}
+	after := models.TradeOfferSnapshot{
+		Items: []models.TradeSnapshotItem{
+			{ProductID: 10, Title: "Case", OfferedBy: "seller"},
+			{ProductID: 12, Title: "Bike", OfferedBy: "seller"},
+		},
+		Cash: &cash250,
+	}
+
+	diff := diffTradeOffers(before, after)
+	if len(diff.AddedItems) != 1 || diff.AddedItems[0].ProductID != 12 {
+		t.Errorf("expected product 12 added, got %+v", diff.AddedItems)
+	}
+	if len(diff.RemovedItems) != 1 || diff.RemovedItems[0].ProductID != 9 {
+		t.Errorf("expected product 9 removed, got %+v", diff.RemovedItems)
+	}
+	want := "Added Bike (#12); Removed Phone (#9); Cash 100.00 → 250.00"
+	if diff.Summary != want {
+		t.Errorf("summary = %q, want %q", diff.Summary, want)
+	}
+}
+
+func TestDiffTradeOffersNoChanges(t *testing.T) {
+	snap := models.TradeOfferSnapshot{Items: []models.TradeSnapshotItem{{ProductID: 1, Title: "Lamp"}}}
+	diff := diffTradeOffers(snap, snap)
+	if len(diff.AddedItems) != 0 || len(diff.RemovedItems) != 0 {
+		t.Errorf("expected no item changes, got %+v", diff)
+	}
+	if diff.Summary != "No changes to items or cash" {
+		t.Errorf("unexpected summary %q", diff.Summary)
+	}
+}
+
+func TestPlanItemRemoval(t *testing.T) {
+	items := []models.TradeSnapshotItem{
+		{ProductID: 1, OfferedBy: "buyer"},
+		{ProductID: 2, OfferedBy: "buyer"},
+		{ProductID: 3, OfferedBy: "buyer"},
+	}
+
+	ids, err := planItemRemoval(items, []int{2, 2}, nil)
+	if err != nil || len(ids) != 1 || ids[0] != 2 {
+		t.Fatalf("expected [2], got %v (%v)", ids, err)
+	}
+
+	if _, err := planItemRemoval(items, nil, nil); err == nil {
+		t.Error("expected an error when nothing is removed")
+	}
+	if _, err := planItemRemoval(items, []int{9}, nil); err == nil {
+		t.Error("expected an error for a product outside the offer")
+	}
+	if _, err := planItemRemoval([]models.TradeSnapshotItem{{ProductID: 4, OfferedBy: "seller"}}, []int{4}, nil); err == nil {
+		t.Error("expected an error when removing a seller-offered item")
+	}
+	if _, err := planItemRemoval(items, []int{1, 2, 3}, nil); err == nil {
+		t.Error("expected an error when removing every item without cash")
+	}
+	cash := 50.0
+	if _, err := planItemRemoval(items, []int{1, 2, 3}, &cash); err != nil {
+		t.Errorf("cash alone should keep the offer alive: %v", err)
+	}
+}
+
+// TestAcceptWithRemovalNeedsBuyerConfirmation trims the buyer's offer as the seller and
+// checks the seller can neither accept nor trim their own counter; only the buyer can accept
+func TestAcceptWithRemovalNeedsBuyerConfirmation(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	database.DB = db
+
+	buyerID := createTestUser(t, db, "trim_buyer")
+	sellerID := createTestUser(t, db, "trim_seller")
+	target := createTestProduct(t, db, sellerID, "Trim target")
+	keep := createTestProduct(t, db, buyerID, "Trim kept")
+	drop := createTestProduct(t, db, buyerID, "Trim dropped")
+	res, err := db.Exec("INSERT INTO trades (buyer_id, seller_id, target_product_id, status) VALUES (?, ?, ?, 'pending')", buyerID, sellerID, target)
+	if err != nil {
+		t.Fatalf("insert trade: %v", err)
+	}
+	id64, _ := res.LastInsertId()
+	tradeID := int(id64)
+	t.Cleanup(func() {
+		db.Exec("DELETE FROM trades WHERE id = ?", tradeID)
+		db.Exec("DELETE FROM products WHERE id IN (?, ?, ?)", target, keep, drop)
+	})
+	for _, pid := range []int{keep, drop} {
+		if _, err := db.Exec("INSERT INTO trade_items (trade_id, product_id, offered_by) VALUES (?, ?, 'buyer')", tradeID, pid); err != nil {
+			t.Fatalf("insert trade item: %v", err)
+		}
+	}
+
+	handler := &TradeHandler{db: db}
+	currentUser := sellerID
+	app := newTestApp(&currentUser, func(app *fiber.App) {
+		app.Put("/trades/:id", handler.UpdateTrade)
+	})
+	act := func(user int, body string) int {
+		currentUser = user
+		req := httptest.NewRequest("PUT", fmt.Sprintf("/trades/%d", tradeID), strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		return resp.StatusCode
+	}
+
+	if got := act(buyerID, `{"action": "accept"}`); got != 403 {
+		t.Errorf("buyer accepting their own proposal = %d, want 403", got)
+	}
+	removal := fmt.Sprintf(`{"action": "accept_with_removal", "remove_product_ids": [%d]}`, drop)
+	if got := act(sellerID, removal); got != 200 {
+		t.Fatalf("accept_with_removal = %d, want 200", got)
+	}
+	if got := act(sellerID, `{"action": "accept"}`); got != 403 {
+		t.Errorf("seller accepting their own trimmed counter = %d, want 403", got)
+	}
+	if got := act(sellerID, fmt.Sprintf(`{"action": "accept_with_removal", "remove_product_ids": [%d]}`, keep)); got != 403 {
+		t.Errorf("seller trimming their own counter again = %d, want 403", got)
+	}
+	if got := act(buyerID, `{"action": "accept"}`); got != 200 {
+		t.Errorf("buyer accepting the trimmed offer = %d, want 200", got)
+	}
+}
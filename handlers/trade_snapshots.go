@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/xashathebest/clovia/models"
+)
+
+// productSnapshot is what a product looked like when it was put into a trade
+type productSnapshot struct {
+	SellerID int
+	Title    string
+	Price    *float64
+	ImageURL string
+}
+
+// loadProductSnapshot reads the title, price and lead image of a product
+func loadProductSnapshot(q queryRower, productID int) (productSnapshot, error) {
+	var snap productSnapshot
+	var price sql.NullFloat64
+	var imageURLs, imageURL sql.NullString
+	err := q.QueryRow("SELECT seller_id, COALESCE(title, ''), price, CAST(image_urls AS CHAR), image_url FROM products WHERE id = ?", productID).
+		Scan(&snap.SellerID, &snap.Title, &price, &imageURLs, &imageURL)
+	if err != nil {
+		return snap, err
+	}
+	if price.Valid {
+		p := price.Float64
+		snap.Price = &p
+	}
+	if imageURLs.Valid && imageURLs.String != "" {
+		if urls := parseImageURLs(productID, imageURLs.String); len(urls) > 0 {
+			snap.ImageURL = urls[0]
+		}
+	}
+	if snap.ImageURL == "" && imageURL.Valid {
+		snap.ImageURL = imageURL.String
+	}
+	return snap, nil
+}
+
+// insertTradeItem adds a product to a trade together with a snapshot of its current details,
+// so later edits to the listing don't rewrite what was offered. The product's owner must be
+// the party on the offeredBy side.
+func insertTradeItem(tx *sql.Tx, tradeID, productID int, offeredBy string) error {
+	snap, err := loadProductSnapshot(tx, productID)
+	if err != nil {
+		return err
+	}
+	if err := checkItemSide(tx, tradeID, snap.SellerID, offeredBy); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO trade_items (trade_id, product_id, offered_by, snapshot_title, snapshot_price, snapshot_image_url)
+		VALUES (?, ?, ?, ?, ?, ?)`, tradeID, productID, offeredBy, snap.Title, snap.Price, snap.ImageURL)
+	return err
+}
+
+// snapshotTradeTarget stores the target product's details on the trade
+func snapshotTradeTarget(tx *sql.Tx, tradeID, productID int) error {
+	snap, err := loadProductSnapshot(tx, productID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE trades SET target_snapshot_title = ?, target_snapshot_price = ?, target_snapshot_image_url = ? WHERE id = ?",
+		snap.Title, snap.Price, snap.ImageURL, tradeID)
+	return err
+}
+
+// applyItemSnapshot prefers the details captured when the item was offered over the live
+// product and flags items whose listing has been edited or removed since. A deleted listing
+// has no live title and a product_id of 0.
+func applyItemSnapshot(it *models.TradeItem, title, imageURL sql.NullString, price sql.NullFloat64) {
+	if !title.Valid {
+		// Items offered before snapshots existed only have live details
+		return
+	}
+	it.ProductChanged = it.ProductTitle != title.String
+	it.ProductTitle = title.String
+	if imageURL.Valid && imageURL.String != "" {
+		it.ProductImageURL = imageURL.String
+	}
+	if price.Valid {
+		p := price.Float64
+		it.ProductPrice = &p
+	}
+}
+
+// loadTradeItems fetches the items of several trades in one query and groups them by trade
+func loadTradeItems(db *sql.DB, tradeIDs []int) (map[int][]models.TradeItem, error) {
+	byTrade := map[int][]models.TradeItem{}
+	if len(tradeIDs) == 0 {
+		return byTrade, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(tradeIDs)), ",")
+	args := make([]interface{}, len(tradeIDs))
+	for i, id := range tradeIDs {
+		args[i] = id
+	}
+	rows, err := db.Query(`
+		SELECT ti.id, ti.trade_id, ti.product_id, ti.offered_by, ti.created_at,
+		       p.title, p.status, p.image_url, ti.snapshot_title, ti.snapshot_price, ti.snapshot_image_url
+		FROM trade_items ti
+		LEFT JOIN products p ON p.id = ti.product_id
+		WHERE ti.trade_id IN (`+placeholders+`)
+		ORDER BY ti.trade_id, ti.id`, args...)
+	if err != nil {
+		return byTrade, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var it models.TradeItem
+		var offeredBy, title, pstatus, pimg, snapTitle, snapImage sql.NullString
+		var snapPrice sql.NullFloat64
+		var productID sql.NullInt64
+		if err := rows.Scan(&it.ID, &it.TradeID, &productID, &offeredBy, &it.CreatedAt, &title, &pstatus, &pimg, &snapTitle, &snapPrice, &snapImage); err != nil {
+			return byTrade, err
+		}
+		it.ProductID = int(productID.Int64)
+		it.OfferedBy = offeredBy.String
+		it.ProductTitle = title.String
+		it.ProductStatus = pstatus.String
+		it.ProductImageURL = pimg.String
+		applyItemSnapshot(&it, snapTitle, snapImage, snapPrice)
+		byTrade[it.TradeID] = append(byTrade[it.TradeID], it)
+	}
+	return byTrade, rows.Err()
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/database"
+	"github.com/xashathebest/clovia/models"
+)
+
+func TestApplyItemSnapshot(t *testing.T) {
+	it := models.TradeItem{ProductTitle: "Renamed bike", ProductStatus: "available", ProductImageURL: "/new.jpg"}
+	applyItemSnapshot(&it,
+		sql.NullString{String: "Mountain bike", Valid: true},
+		sql.NullString{String: "/old.jpg", Valid: true},
+		sql.NullFloat64{Float64: 1500, Valid: true})
+	if it.ProductTitle != "Mountain bike" || it.ProductImageURL != "/old.jpg" {
+		t.Errorf("snapshot not preferred: %+v", it)
+	}
+	if it.ProductPrice == nil || *it.ProductPrice != 1500 {
+		t.Errorf("snapshot price = %v, want 1500", it.ProductPrice)
+	}
+	if !it.ProductChanged || it.ProductStatus != "available" {
+		t.Errorf("want changed flag and live status, got %+v", it)
+	}
+
+	legacy := models.TradeItem{ProductTitle: "Lamp"}
+	applyItemSnapshot(&legacy, sql.NullString{}, sql.NullString{}, sql.NullFloat64{})
+	if legacy.ProductTitle != "Lamp" || legacy.ProductChanged {
+		t.Errorf("item without snapshot changed: %+v", legacy)
+	}
+}
+
+// TestTradeSurvivesDeletedProducts deletes both listings of a trade and checks the trade
+// still reads back from its snapshots, with the open trade closed by the delete
+func TestTradeSurvivesDeletedProducts(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	database.DB = db
+
+	buyerID := createTestUser(t, db, "snapbuyer")
+	sellerID := createTestUser(t, db, "snapseller")
+	target := createTestProduct(t, db, sellerID, "Snapshot Target")
+	offered := createTestProduct(t, db, buyerID, "Snapshot Offered")
+	t.Cleanup(func() {
+		db.Exec("DELETE FROM trades WHERE buyer_id = ?", buyerID)
+		db.Exec("DELETE FROM products WHERE id IN (?, ?)", target, offered)
+	})
+
+	trades := &TradeHandler{db: db}
+	products := &ProductHandler{db: db}
+	currentUser := buyerID
+	app := newTestApp(&currentUser, func(app *fiber.App) {
+		app.Post("/trades", trades.CreateTrade)
+		app.Get("/trades/:id", trades.GetTrade)
+		app.Delete("/products/:id", products.DeleteProduct)
+	})
+
+	body := fmt.Sprintf(`{"target_product_id": %d, "offered_product_ids": [%d]}`, target, offered)
+	req := httptest.NewRequest("POST", "/trades", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if resp, err := app.Test(req, -1); err != nil || resp.StatusCode != 201 {
+		t.Fatalf("create trade: %v %v", resp, err)
+	}
+	var tradeID int
+	if err := db.QueryRow("SELECT id FROM trades WHERE target_product_id = ?", target).Scan(&tradeID); err != nil {
+		t.Fatalf("find trade: %v", err)
+	}
+
+	for _, del := range []struct{ user, product int }{{buyerID, offered}, {sellerID, target}} {
+		currentUser = del.user
+		resp, err := app.Test(httptest.NewRequest("DELETE", fmt.Sprintf("/products/%d", del.product), nil), -1)
+		if err != nil || resp.StatusCode != 200 {
+			t.Fatalf("delete product %d: %v %v", del.product, resp, err)
+		}
+	}
+
+	currentUser = buyerID
+	resp, err := app.Test(httptest.NewRequest("GET", fmt.Sprintf("/trades/%d", tradeID), nil), -1)
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatalf("get trade after delete: %v %v", resp, err)
+	}
+	var out struct {
+		Data models.Trade `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	tr := out.Data
+	if tr.ProductTitle != "Snapshot Target" || tr.TargetProductID != 0 {
+		t.Errorf("target = %q (#%d), want the snapshot title and no live product", tr.ProductTitle, tr.TargetProductID)
+	}
+	if tr.Status != "declined" {
+		t.Errorf("status = %q, want the pending trade declined by the delete", tr.Status)
+	}
+	if len(tr.Items) != 1 || tr.Items[0].ProductTitle != "Snapshot Offered" || !tr.Items[0].ProductChanged {
+		t.Errorf("items = %+v, want the offered item from its snapshot, flagged as changed", tr.Items)
+	}
+
+	// Readers of the nullable product columns skip the deleted products
+	if mismatches, err := findTradeItemMismatches(db, tradeID); err != nil || len(mismatches) != 0 {
+		t.Errorf("findTradeItemMismatches = %+v, %v; want no mismatches and no error", mismatches, err)
+	}
+	if route, err := loadTradeRoute(db, tradeID, 0); err != nil || len(route.ProductIDs) != 0 {
+		t.Errorf("loadTradeRoute = %+v, %v; want no products", route, err)
+	}
+}
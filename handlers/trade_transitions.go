@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/models"
+)
+
+// tradeActionSources lists the statuses each UpdateTrade action may start from. Anything
+// else means the trade already moved on, usually because the same action was sent twice.
+var tradeActionSources = map[string][]string{
+	"accept":              {"pending", "countered"},
+	"accept_with_removal": {"pending", "countered"},
+	"decline":             {"pending", "countered"},
+	"counter":             {"pending", "countered"},
+	"complete":            {"accepted", "active", "awaiting_confirmation"},
+	"cancel":              openTradeStatuses,
+}
+
+// tradeStateError reports an action that is not valid from the trade's current status
+type tradeStateError struct {
+	Action string
+	Status string
+}
+
+func (e *tradeStateError) Error() string {
+	return fmt.Sprintf("Trade is already %s, so %q is no longer possible", e.Status, e.Action)
+}
+
+// canApplyTradeAction reports whether action may run on a trade in status
+func canApplyTradeAction(action, status string) bool {
+	for _, s := range tradeActionSources[action] {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// tradeActionSourceClause is "status IN (...)" over action's source statuses, for guarding
+// an UPDATE without a separate locking read
+func tradeActionSourceClause(action string) (string, []interface{}) {
+	sources := tradeActionSources[action]
+	args := make([]interface{}, len(sources))
+	for i, s := range sources {
+		args[i] = s
+	}
+	return "status IN (" + strings.TrimSuffix(strings.Repeat("?,", len(sources)), ",") + ")", args
+}
+
+// lockTradeForAction reads the trade's status under a row lock and checks that action may
+// run from it, so a repeated or concurrent request waits and then fails cleanly instead of
+// repeating the transition. It returns the status the transition starts from.
+func lockTradeForAction(tx *sql.Tx, tradeID int, action string) (string, error) {
+	var status string
+	if err := tx.QueryRow("SELECT status FROM trades WHERE id = ? FOR UPDATE", tradeID).Scan(&status); err != nil {
+		return "", err
+	}
+	if !canApplyTradeAction(action, status) {
+		return status, &tradeStateError{Action: action, Status: status}
+	}
+	return status, nil
+}
+
+// errOwnOffer is returned when someone tries to accept the offer they made themselves
+var errOwnOffer = errors.New("You made the latest offer; the other party has to accept it")
+
+// checkOfferResponder makes sure userID is not the one who put the current offer on the
+// table: the latest counter's author, or the buyer while their proposal is unanswered.
+// Counters made before countered_by existed fall back to the last countered event.
+func checkOfferResponder(tx *sql.Tx, tradeID, userID int) error {
+	var offeredBy int
+	err := tx.QueryRow(`
+		SELECT COALESCE(t.countered_by,
+			(SELECT te.actor_id FROM trade_events te WHERE te.trade_id = t.id AND te.to_status = 'countered' ORDER BY te.id DESC LIMIT 1),
+			t.buyer_id)
+		FROM trades t WHERE t.id = ?`, tradeID).Scan(&offeredBy)
+	if err != nil {
+		return err
+	}
+	if offeredBy == userID {
+		return errOwnOffer
+	}
+	return nil
+}
+
+// tradeActionFailed answers a failed lockTradeForAction or checkOfferResponder: 409 when
+// the trade moved on, 403 for accepting one's own offer, 500 otherwise
+func tradeActionFailed(c *fiber.Ctx, err error) error {
+	if stateErr, ok := err.(*tradeStateError); ok {
+		return c.Status(409).JSON(models.APIResponse{Success: false, Error: stateErr.Error()})
+	}
+	if err == errOwnOffer {
+		return c.Status(403).JSON(models.APIResponse{Success: false, Error: err.Error()})
+	}
+	return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to load trade"})
+}
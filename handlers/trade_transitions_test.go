@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCanApplyTradeAction(t *testing.T) {
+	cases := []struct {
+		action, status string
+		want           bool
+	}{
+		{"accept", "pending", true},
+		{"accept", "countered", true},
+		{"accept", "active", false}, // double-clicked accept
+		{"decline", "declined", false},
+		{"counter", "active", false},
+		{"complete", "active", true},
+		{"complete", "completed", false},
+		{"cancel", "active", true},
+		{"cancel", "cancelled", false},
+		{"unknown", "pending", false},
+	}
+	for _, tc := range cases {
+		if got := canApplyTradeAction(tc.action, tc.status); got != tc.want {
+			t.Errorf("canApplyTradeAction(%q, %q) = %v, want %v", tc.action, tc.status, got, tc.want)
+		}
+	}
+}
+
+func TestTradeActionSourceClause(t *testing.T) {
+	clause, args := tradeActionSourceClause("accept")
+	if clause != "status IN (?,?)" || len(args) != 2 || args[0] != "pending" || args[1] != "countered" {
+		t.Errorf("clause = %q %v", clause, args)
+	}
+	err := &tradeStateError{Action: "accept", Status: "active"}
+	if !strings.Contains(err.Error(), "already active") {
+		t.Errorf("error = %q, want it to name the current status", err.Error())
+	}
+}
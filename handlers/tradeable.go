@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/middleware"
+	"github.com/xashathebest/clovia/models"
+)
+
+// tradeableLimit caps each side of the trade builder, newest listings first
+const tradeableLimit = 100
+
+// GetTradeableListings returns what a user has up for trade and, when the viewer is signed
+// in and looking at someone else, the viewer's own listings they could offer for it. Only
+// available listings appear on either side; locked, sold and traded ones are left out, as
+// are the viewer's listings already offered in another open trade. While the user is away
+// their listings are withheld, matching what CreateTrade accepts.
+//
+// There is no blocking between users yet; once there is, this is the place to refuse it.
+func (h *UserHandler) GetTradeableListings(c *fiber.Ctx) error {
+	ownerID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid user ID"})
+	}
+	var exists bool
+	if err := h.db.QueryRow("SELECT COUNT(*) > 0 FROM users WHERE id = ?", ownerID).Scan(&exists); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to fetch user"})
+	}
+	if !exists {
+		return c.Status(404).JSON(models.APIResponse{Success: false, Error: "User not found"})
+	}
+
+	result := models.TradeableListings{UserID: ownerID, Listings: []models.TradeableProduct{}}
+	awayUntil, err := sellerAwayUntil(h.db, ownerID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to fetch user"})
+	}
+	if awayUntil != nil {
+		result.AwayUntil = awayUntil
+		result.AwayNotice = sellerAwayMessage(*awayUntil)
+	} else {
+		result.Listings, err = loadTradeableProducts(h.db, ownerID, false)
+		if err != nil {
+			return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to fetch listings"})
+		}
+	}
+
+	if viewerID, ok := middleware.GetUserIDFromContext(c); ok && viewerID != ownerID {
+		result.Offerable, err = loadTradeableProducts(h.db, viewerID, true)
+		if err != nil {
+			return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to fetch your listings"})
+		}
+	}
+	return c.JSON(models.APIResponse{Success: true, Data: result})
+}
+
+// loadTradeableProducts lists a seller's available listings. With excludeOffered it also
+// drops listings already offered in an open trade, which CreateTrade would refuse.
+func loadTradeableProducts(db *sql.DB, sellerID int, excludeOffered bool) ([]models.TradeableProduct, error) {
+	query := `
+		SELECT p.id, p.title, COALESCE(p.slug, ''), p.price, p.image_urls,
+			COALESCE(p.` + "`condition`" + `, ''), COALESCE(p.category, ''), p.suggested_value,
+			COALESCE(p.allow_buying, FALSE), COALESCE(p.barter_only, FALSE)
+		FROM products p
+		WHERE p.seller_id = ? AND p.status = 'available'`
+	args := []interface{}{sellerID}
+	if excludeOffered {
+		statusPlaceholders := strings.TrimSuffix(strings.Repeat("?,", len(openTradeStatuses)), ",")
+		query += `
+		AND NOT EXISTS (
+			SELECT 1 FROM trade_items ti JOIN trades t ON t.id = ti.trade_id
+			WHERE ti.product_id = p.id AND t.status IN (` + statusPlaceholders + `)
+		)`
+		for _, s := range openTradeStatuses {
+			args = append(args, s)
+		}
+	}
+	query += `
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT ?`
+	args = append(args, tradeableLimit)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := []models.TradeableProduct{}
+	for rows.Next() {
+		var tp models.TradeableProduct
+		var price sql.NullFloat64
+		var images sql.NullString
+		var suggested sql.NullInt64
+		var allowBuying, barterOnly bool
+		if err := rows.Scan(&tp.ID, &tp.Title, &tp.Slug, &price, &images,
+			&tp.Condition, &tp.Category, &suggested, &allowBuying, &barterOnly); err != nil {
+			return nil, err
+		}
+		if price.Valid {
+			tp.Price = &price.Float64
+		}
+		if images.Valid {
+			tp.ImageURLs = parseImageURLs(tp.ID, images.String)
+		}
+		if suggested.Valid {
+			v := int(suggested.Int64)
+			tp.SuggestedValue = &v
+		}
+		tp.AcceptsCashOnly = models.PurchaseAllowed(allowBuying, barterOnly)
+		products = append(products, tp)
+	}
+	return products, rows.Err()
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/models"
+)
+
+// TestGetTradeableListings checks both sides of the trade builder: the owner's locked
+// listing is left out, and so is the viewer's listing already offered in an open trade
+func TestGetTradeableListings(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	owner := createTestUser(t, db, "Tradeable Owner")
+	viewer := createTestUser(t, db, "Tradeable Viewer")
+	other := createTestUser(t, db, "Tradeable Other")
+	listed := createTestProduct(t, db, owner, "Tradeable Bike")
+	locked := createTestProduct(t, db, owner, "Locked Bike")
+	free := createTestProduct(t, db, viewer, "Free Helmet")
+	offered := createTestProduct(t, db, viewer, "Offered Helmet")
+	otherTarget := createTestProduct(t, db, other, "Other Target")
+	t.Cleanup(func() {
+		db.Exec("DELETE FROM products WHERE id IN (?, ?, ?, ?, ?)", listed, locked, free, offered, otherTarget)
+	})
+	if _, err := db.Exec("UPDATE products SET status = 'locked' WHERE id = ?", locked); err != nil {
+		t.Fatalf("lock product: %v", err)
+	}
+	res, err := db.Exec("INSERT INTO trades (buyer_id, seller_id, target_product_id, status) VALUES (?, ?, ?, 'pending')", viewer, other, otherTarget)
+	if err != nil {
+		t.Fatalf("insert trade: %v", err)
+	}
+	tradeID, _ := res.LastInsertId()
+	if _, err := db.Exec("INSERT INTO trade_items (trade_id, product_id, offered_by) VALUES (?, ?, 'buyer')", tradeID, offered); err != nil {
+		t.Fatalf("insert trade item: %v", err)
+	}
+
+	h := &UserHandler{db: db}
+	app := newTestApp(&viewer, func(app *fiber.App) { app.Get("/users/:id/tradeable", h.GetTradeableListings) })
+	resp, err := app.Test(httptest.NewRequest("GET", "/users/"+strconv.Itoa(owner)+"/tradeable", nil), -1)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	var body struct {
+		Data models.TradeableListings `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(body.Data.Listings) != 1 || body.Data.Listings[0].ID != listed {
+		t.Errorf("listings = %+v, want only %d", body.Data.Listings, listed)
+	}
+	if len(body.Data.Offerable) != 1 || body.Data.Offerable[0].ID != free {
+		t.Errorf("offerable = %+v, want only %d", body.Data.Offerable, free)
+	}
+}
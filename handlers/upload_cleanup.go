@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/middleware"
+	"github.com/xashathebest/clovia/models"
+	"github.com/xashathebest/clovia/services"
+	"github.com/xashathebest/clovia/storage"
+)
+
+// CleanupUploads reports uploaded files that no listing, profile, trade snapshot or chat
+// message references and that are older than UPLOAD_CLEANUP_MIN_AGE. It only reports
+// unless called with ?dry_run=false, in which case the files are deleted.
+func (h *AdminHandler) CleanupUploads(c *fiber.Ctx) error {
+	adminID, _ := middleware.GetUserIDFromContext(c)
+	dryRun := true
+	if raw := c.Query("dry_run"); raw != "" {
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return c.Status(400).JSON(models.APIResponse{Success: false, Error: "dry_run must be true or false"})
+		}
+		dryRun = v
+	}
+
+	report, err := services.CleanupOrphanedUploads(h.db, storage.Default(), services.UploadCleanupMinAge(), dryRun)
+	if err == services.ErrUploadListingUnsupported {
+		return c.Status(501).JSON(models.APIResponse{Success: false, Error: "Upload cleanup is only available for local storage"})
+	}
+	if err != nil {
+		log.Printf("Upload cleanup failed: %v", err)
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to check uploads; nothing further was deleted", Data: report})
+	}
+
+	message := "Dry run: nothing was deleted"
+	if !dryRun {
+		message = "Orphaned uploads deleted"
+		log.Printf("Admin %d removed %d orphaned upload(s), %d bytes", adminID, report.ReclaimedFiles, report.ReclaimedBytes)
+	}
+	return c.JSON(models.APIResponse{Success: true, Message: message, Data: report})
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+
+	"github.com/xashathebest/clovia/services"
+	"github.com/xashathebest/clovia/storage"
+)
+
+// productImageURLs reads a product's stored image list, tolerating NULL or malformed JSON
+func productImageURLs(db *sql.DB, productID int) []string {
+	var raw sql.NullString
+	if err := db.QueryRow("SELECT image_urls FROM products WHERE id = ?", productID).Scan(&raw); err != nil || !raw.Valid {
+		return nil
+	}
+	var urls []string
+	if err := json.Unmarshal([]byte(raw.String), &urls); err != nil {
+		return nil
+	}
+	return urls
+}
+
+// releaseUploads deletes stored files that nothing references any more. Only
+// content-addressed files are considered; legacy uploads are left alone. Files just handed
+// out again by storage.SaveUpload are kept, see storage.ReleaseUpload.
+func releaseUploads(db *sql.DB, files storage.Storage, urls []string) {
+	for _, u := range urls {
+		key := storage.KeyFromURL(u)
+		if !storage.IsContentKey(key) {
+			continue
+		}
+		_, err := storage.ReleaseUpload(files, key, func() (bool, error) {
+			return services.UploadReferenced(db, key)
+		})
+		if err != nil {
+			log.Printf("Failed to release upload %s: %v", key, err)
+		}
+	}
+}
+
+// removedURLs returns the entries of before that are missing from after
+func removedURLs(before, after []string) []string {
+	kept := make(map[string]bool, len(after))
+	for _, u := range after {
+		kept[u] = true
+	}
+	var removed []string
+	for _, u := range before {
+		if !kept[u] {
+			removed = append(removed, u)
+		}
+	}
+	return removed
+}
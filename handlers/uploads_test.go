@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRemovedURLs(t *testing.T) {
+	before := []string{"/uploads/a.jpg", "/uploads/b.jpg", "/uploads/c.jpg"}
+	after := []string{"/uploads/c.jpg", "/uploads/d.jpg"}
+	got := removedURLs(before, after)
+	if !reflect.DeepEqual(got, []string{"/uploads/a.jpg", "/uploads/b.jpg"}) {
+		t.Fatalf("unexpected removed urls: %v", got)
+	}
+	if got := removedURLs(nil, after); len(got) != 0 {
+		t.Fatalf("nothing can be removed from an empty list: %v", got)
+	}
+}
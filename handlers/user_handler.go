@@ -3,6 +3,8 @@ package handlers
 import (
 	"database/sql"
 	"fmt"
+	"log"
+	"math"
 	"strconv"
 	"strings"
 	"time"
@@ -12,18 +14,21 @@ import (
 	"github.com/xashathebest/clovia/database"
 	"github.com/xashathebest/clovia/middleware"
 	"github.com/xashathebest/clovia/models"
+	"github.com/xashathebest/clovia/storage"
 	"github.com/xashathebest/clovia/utils"
 )
 
 // UserHandler handles user-related HTTP requests
 type UserHandler struct {
-	db *sql.DB
+	db    *sql.DB
+	files storage.Storage
 }
 
 // NewUserHandler creates a new user handler
 func NewUserHandler() *UserHandler {
 	return &UserHandler{
-		db: database.DB,
+		db:    database.DB,
+		files: storage.Default(),
 	}
 }
 
@@ -37,6 +42,15 @@ func nullableString(p *string) interface{} {
 	return *p
 }
 
+// isAdminUser reports whether the given user has the admin role
+func isAdminUser(db *sql.DB, userID int) bool {
+	var role string
+	if err := db.QueryRow("SELECT role FROM users WHERE id = ?", userID).Scan(&role); err != nil {
+		return false
+	}
+	return role == "admin"
+}
+
 func derefString(p *string) string {
 	if p == nil {
 		return ""
@@ -54,6 +68,14 @@ func (h *UserHandler) Register(c *fiber.Ctx) error {
 		})
 	}
 
+	user.Email = models.NormalizeEmail(user.Email)
+	if !models.ValidEmail(user.Email) {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Please enter a valid email address",
+		})
+	}
+
 	// Check if user already exists
 	var existingUser models.User
 	err := h.db.QueryRow("SELECT id FROM users WHERE email = ?", user.Email).Scan(&existingUser.ID)
@@ -66,7 +88,7 @@ func (h *UserHandler) Register(c *fiber.Ctx) error {
 
 	// WMSU prioritization: enforce WMSU email for non-organization accounts
 	if !user.IsOrganization {
-		if !strings.HasSuffix(strings.ToLower(user.Email), "@wmsu.edu.ph") {
+		if !strings.HasSuffix(user.Email, "@wmsu.edu.ph") {
 			return c.Status(400).JSON(models.APIResponse{
 				Success: false,
 				Error:   "WMSU students must register with their @wmsu.edu.ph email",
@@ -78,6 +100,22 @@ func (h *UserHandler) Register(c *fiber.Ctx) error {
 		}
 	}
 
+	// Only departments from the official list are stored, as their code
+	var departmentID *int
+	if user.Department != nil && strings.TrimSpace(*user.Department) != "" {
+		dept, err := resolveDepartment(h.db, *user.Department)
+		if err == sql.ErrNoRows {
+			return c.Status(400).JSON(models.APIResponse{Success: false, Error: errUnknownDepartment})
+		}
+		if err != nil {
+			return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to check department"})
+		}
+		user.Department = &dept.Code
+		departmentID = &dept.ID
+	} else {
+		user.Department = nil
+	}
+
 	// Hash password
 	hashedPassword, err := utils.HashPassword(user.Password)
 	if err != nil {
@@ -89,7 +127,7 @@ func (h *UserHandler) Register(c *fiber.Ctx) error {
 
 	// Insert new user
 	result, err := h.db.Exec(
-		"INSERT INTO users (name, email, password_hash, role, is_organization, org_verified, org_name, org_logo_url, department, bio, badges, profile_picture) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, JSON_ARRAY(), ?)",
+		"INSERT INTO users (name, email, password_hash, role, is_organization, org_verified, org_name, org_logo_url, department, department_id, bio, badges, profile_picture) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, JSON_ARRAY(), ?)",
 		user.Name,
 		user.Email,
 		hashedPassword,
@@ -99,9 +137,17 @@ func (h *UserHandler) Register(c *fiber.Ctx) error {
 		user.OrgName,
 		user.OrgLogoURL,
 		nullableString(user.Department),
+		departmentID,
 		user.Bio,
 		"",
 	)
+	if isDuplicateEntry(err) {
+		// Registered concurrently under the same address
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Error:   "User with this email already exists",
+		})
+	}
 	if err != nil {
 		// Log the actual error for debugging
 		fmt.Printf("❌ Error creating user: %v\n", err)
@@ -155,6 +201,7 @@ func (h *UserHandler) Login(c *fiber.Ctx) error {
 	}
 
 	// Find user by email
+	login.Email = models.NormalizeEmail(login.Email)
 	var user models.User
 	err := h.db.QueryRow(
 		"SELECT id, name, email, password_hash, role, verified FROM users WHERE email = ?",
@@ -206,11 +253,15 @@ func (h *UserHandler) GetProfile(c *fiber.Ctx) error {
 	}
 
 	var user models.User
+	var autoArrange bool
+	var vacationUntil sql.NullTime
 	// Fixed: single SELECT and Scan (removed duplicated/invalid lines)
 	err := h.db.QueryRow(
-		"SELECT id, name, email, role, verified, org_logo_url, COALESCE(profile_picture, '') as profile_picture, COALESCE(bio, '') as bio, COALESCE(background_image, '') as background_image, COALESCE(background_position, '') as background_position, created_at, updated_at FROM users WHERE id = ?",
+		"SELECT id, name, COALESCE(username, '') as username, email, role, verified, org_logo_url, COALESCE(profile_picture, '') as profile_picture, COALESCE(bio, '') as bio, COALESCE(background_image, '') as background_image, COALESCE(background_position, '') as background_position, created_at, updated_at, COALESCE(auto_arrange_delivery, FALSE), vacation_until FROM users WHERE id = ?",
 		userID,
-	).Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.Verified, &user.OrgLogoURL, &user.ProfilePicture, &user.Bio, &user.BackgroundImage, &user.BackgroundPosition, &user.CreatedAt, &user.UpdatedAt)
+	).Scan(&user.ID, &user.Name, &user.Username, &user.Email, &user.Role, &user.Verified, &user.OrgLogoURL, &user.ProfilePicture, &user.Bio, &user.BackgroundImage, &user.BackgroundPosition, &user.CreatedAt, &user.UpdatedAt, &autoArrange, &vacationUntil)
+	user.AutoArrangeDelivery = &autoArrange
+	setVacation(&user, vacationUntil, time.Now())
 
 	if err != nil {
 		// Return a friendly fallback (200) so frontend does not produce a network 404.
@@ -229,7 +280,7 @@ func (h *UserHandler) GetProfile(c *fiber.Ctx) error {
 		})
 	}
 
-	return c.JSON(models.APIResponse{
+	return jsonWithETag(c, models.APIResponse{
 		Success: true,
 		Data:    user,
 	})
@@ -247,11 +298,15 @@ func (h *UserHandler) UpdateProfile(c *fiber.Ctx) error {
 
 	var updateData struct {
 		Name               *string `json:"name"`
+		Username           *string `json:"username"`
 		Email              *string `json:"email"`
 		ProfilePicture     *string `json:"profile_picture"`
 		Bio                *string `json:"bio"`
+		Department         *string `json:"department"`
 		BackgroundImage    *string `json:"background_image"`
 		BackgroundPosition *string `json:"background_position"`
+		// AutoArrangeDelivery opts into a pending delivery being created when a trade completes
+		AutoArrangeDelivery *bool `json:"auto_arrange_delivery"`
 	}
 
 	if err := c.BodyParser(&updateData); err != nil {
@@ -269,9 +324,48 @@ func (h *UserHandler) UpdateProfile(c *fiber.Ctx) error {
 		query += ", name = ?"
 		args = append(args, *updateData.Name)
 	}
+	if updateData.Username != nil {
+		username := normalizeUsername(*updateData.Username)
+		if username == "" {
+			// Empty clears the handle
+			query += ", username = NULL"
+		} else {
+			if !validUsername(username) {
+				return c.Status(400).JSON(models.APIResponse{
+					Success: false,
+					Error:   "Username must be 3-30 characters of letters, numbers, '_' or '.'",
+				})
+			}
+			var takenBy int
+			err := h.db.QueryRow("SELECT id FROM users WHERE username = ? AND id <> ?", username, userID).Scan(&takenBy)
+			if err == nil {
+				return c.Status(409).JSON(models.APIResponse{
+					Success: false,
+					Error:   "Username is already taken",
+				})
+			}
+			query += ", username = ?"
+			args = append(args, username)
+		}
+	}
 	if updateData.Email != nil {
+		email := models.NormalizeEmail(*updateData.Email)
+		if !models.ValidEmail(email) {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Please enter a valid email address",
+			})
+		}
+		var takenBy int
+		err := h.db.QueryRow("SELECT id FROM users WHERE email = ? AND id <> ?", email, userID).Scan(&takenBy)
+		if err == nil {
+			return c.Status(409).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Email is already in use by another account",
+			})
+		}
 		query += ", email = ?"
-		args = append(args, *updateData.Email)
+		args = append(args, email)
 	}
 	if updateData.ProfilePicture != nil {
 		query += ", profile_picture = ?"
@@ -288,6 +382,31 @@ func (h *UserHandler) UpdateProfile(c *fiber.Ctx) error {
 		args = append(args, *updateData.Bio)
 	}
 
+	if updateData.Department != nil {
+		if strings.TrimSpace(*updateData.Department) == "" {
+			// Student accounts must keep a department; organizations may clear theirs
+			var isOrg bool
+			h.db.QueryRow("SELECT is_organization FROM users WHERE id = ?", userID).Scan(&isOrg)
+			if !isOrg {
+				return c.Status(400).JSON(models.APIResponse{
+					Success: false,
+					Error:   "Please select your department/college",
+				})
+			}
+			query += ", department = NULL, department_id = NULL"
+		} else {
+			dept, err := resolveDepartment(h.db, *updateData.Department)
+			if err == sql.ErrNoRows {
+				return c.Status(400).JSON(models.APIResponse{Success: false, Error: errUnknownDepartment})
+			}
+			if err != nil {
+				return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to check department"})
+			}
+			query += ", department = ?, department_id = ?"
+			args = append(args, dept.Code, dept.ID)
+		}
+	}
+
 	if updateData.BackgroundImage != nil {
 		// allow column name background_image or cover_photo depending on schema; try background_image first
 		query += ", background_image = ?"
@@ -299,6 +418,11 @@ func (h *UserHandler) UpdateProfile(c *fiber.Ctx) error {
 		args = append(args, *updateData.BackgroundPosition)
 	}
 
+	if updateData.AutoArrangeDelivery != nil {
+		query += ", auto_arrange_delivery = ?"
+		args = append(args, *updateData.AutoArrangeDelivery)
+	}
+
 	query += " WHERE id = ?"
 	args = append(args, userID)
 
@@ -344,17 +468,19 @@ func (h *UserHandler) UploadProfilePicture(c *fiber.Ctx) error {
 		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "No file uploaded: " + err.Error()})
 	}
 
-	savePath := fmt.Sprintf("uploads/%d_%s", time.Now().UnixNano(), file.Filename)
-	if err := c.SaveFile(file, savePath); err != nil {
+	url, err := storage.SaveUpload(h.files, file)
+	if err != nil {
 		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to save file"})
 	}
 
-	// Build an absolute URL so clients (dev server on different port) can load images
-	host := c.Get("Host")
-	if host == "" {
-		host = "localhost:4000"
+	// Local storage returns a path; build an absolute URL so clients (dev server on different port) can load images
+	if strings.HasPrefix(url, "/") {
+		host := c.Get("Host")
+		if host == "" {
+			host = "localhost:4000"
+		}
+		url = fmt.Sprintf("http://%s%s", host, url)
 	}
-	url := fmt.Sprintf("http://%s/%s", host, savePath)
 
 	// Ensure profile_picture column exists
 	var exists int
@@ -363,11 +489,16 @@ func (h *UserHandler) UploadProfilePicture(c *fiber.Ctx) error {
 		h.db.Exec("ALTER TABLE users ADD COLUMN profile_picture VARCHAR(255) NULL")
 	}
 
-	// Save URL to user's profile
+	// Save URL to user's profile, releasing the previous picture if nothing else uses it
+	var previous sql.NullString
+	_ = h.db.QueryRow("SELECT profile_picture FROM users WHERE id = ?", userID).Scan(&previous)
 	_, err = h.db.Exec("UPDATE users SET profile_picture = ? WHERE id = ?", url, userID)
 	if err != nil {
 		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to update user profile picture"})
 	}
+	if previous.Valid && previous.String != url {
+		releaseUploads(h.db, h.files, []string{previous.String})
+	}
 
 	return c.JSON(models.APIResponse{Success: true, Data: url, Message: "Uploaded"})
 }
@@ -442,12 +573,7 @@ func (h *UserHandler) GetUserByID(c *fiber.Ctx) error {
 		})
 	}
 
-	var user models.User
-	err = h.db.QueryRow(
-		"SELECT id, name, email, role, verified, is_organization, org_verified, org_name, org_logo_url, COALESCE(profile_picture, '') as profile_picture, department, bio, badges, created_at, updated_at FROM users WHERE id = ?",
-		userID,
-	).Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.Verified, &user.IsOrganization, &user.OrgVerified, &user.OrgName, &user.OrgLogoURL, &user.ProfilePicture, &user.Department, &user.Bio, &user.Badges, &user.CreatedAt, &user.UpdatedAt)
-
+	user, err := h.loadPublicUser("id = ?", userID)
 	if err != nil {
 		// Return a friendly fallback (200) so frontend does not produce a network 404.
 		fallback := models.User{
@@ -464,21 +590,171 @@ func (h *UserHandler) GetUserByID(c *fiber.Ctx) error {
 		})
 	}
 
+	h.loadProfileSummary(&user)
+
+	return jsonWithETag(c, models.APIResponse{
+		Success: true,
+		Data:    user,
+	})
+}
+
+// GetUserByUsername gets a user's public profile by their handle
+func (h *UserHandler) GetUserByUsername(c *fiber.Ctx) error {
+	username := normalizeUsername(c.Params("username"))
+	if !validUsername(username) {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid username",
+		})
+	}
+
+	user, err := h.loadPublicUser("username = ?", username)
+	if err == sql.ErrNoRows {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Error:   "User not found",
+		})
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Failed to fetch user",
+		})
+	}
+
+	h.loadProfileSummary(&user)
+
 	return c.JSON(models.APIResponse{
 		Success: true,
 		Data:    user,
 	})
 }
 
-// GetUsers gets all users (admin only, paginated)
+// loadPublicUser reads the public profile columns for the user matching where
+func (h *UserHandler) loadPublicUser(where string, arg interface{}) (models.User, error) {
+	var user models.User
+	var vacationUntil sql.NullTime
+	err := h.db.QueryRow(
+		"SELECT id, name, COALESCE(username, '') as username, email, role, verified, is_organization, org_verified, org_name, org_logo_url, COALESCE(profile_picture, '') as profile_picture, department, bio, badges, created_at, updated_at, vacation_until FROM users WHERE "+where,
+		arg,
+	).Scan(&user.ID, &user.Name, &user.Username, &user.Email, &user.Role, &user.Verified, &user.IsOrganization, &user.OrgVerified, &user.OrgName, &user.OrgLogoURL, &user.ProfilePicture, &user.Department, &user.Bio, &user.Badges, &user.CreatedAt, &user.UpdatedAt, &vacationUntil)
+	setVacation(&user, vacationUntil, time.Now())
+	return user, err
+}
+
+// normalizeUsername trims a leading '@' and lowercases the handle
+func normalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(username), "@"))
+}
+
+// validUsername reports whether a normalized handle is 3-30 chars of [a-z0-9_.]
+func validUsername(username string) bool {
+	if len(username) < 3 || len(username) > 30 {
+		return false
+	}
+	for _, r := range username {
+		if !(r >= 'a' && r <= 'z') && !(r >= '0' && r <= '9') && r != '_' && r != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+// loadProfileSummary attaches inventory counts, completed trade count and
+// average received rating to a public profile. Failures are logged and skipped.
+func (h *UserHandler) loadProfileSummary(user *models.User) {
+	inventory := models.UserInventory{}
+	rows, err := h.db.Query("SELECT status, COUNT(*) FROM products WHERE seller_id = ? GROUP BY status", user.ID)
+	if err != nil {
+		log.Printf("Warning: failed to load inventory counts for user %d: %v", user.ID, err)
+	} else {
+		defer rows.Close()
+		for rows.Next() {
+			var status string
+			var count int
+			if err := rows.Scan(&status, &count); err != nil {
+				continue
+			}
+			switch status {
+			case "available":
+				inventory.Available = count
+			case "sold":
+				inventory.Sold = count
+			case "traded":
+				inventory.Traded = count
+			}
+		}
+	}
+	user.Inventory = &inventory
+
+	// Ratings are stored by the rater: buyer_rating is what the buyer gave the seller
+	var completed int
+	var avgRating sql.NullFloat64
+	err = h.db.QueryRow(`
+		SELECT
+			COUNT(*),
+			AVG(CASE WHEN seller_id = ? THEN buyer_rating ELSE seller_rating END)
+		FROM trades
+		WHERE (buyer_id = ? OR seller_id = ?) AND status IN ('completed', 'auto_completed')
+	`, user.ID, user.ID, user.ID).Scan(&completed, &avgRating)
+	if err != nil {
+		log.Printf("Warning: failed to load trade stats for user %d: %v", user.ID, err)
+		return
+	}
+	user.CompletedTrades = &completed
+	if avgRating.Valid {
+		rating := math.Round(avgRating.Float64*100) / 100
+		user.AverageRating = &rating
+	}
+}
+
+// GetUsers gets all users (admin only, paginated).
+// Supports ?q= (name/email/org_name), ?role=, ?verified= and ?is_organization= filters.
 func (h *UserHandler) GetUsers(c *fiber.Ctx) error {
-	page, _ := strconv.Atoi(c.Query("page", "1"))
-	limit, _ := strconv.Atoi(c.Query("limit", "10"))
-	offset := (page - 1) * limit
+	pg, err := parsePaginationDefault(c, 10)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: err.Error()})
+	}
+	page, limit, offset := pg.Page, pg.Limit, pg.Offset
+
+	whereClause := "WHERE 1=1"
+	var args []interface{}
+
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		whereClause += " AND (name LIKE ? OR email LIKE ? OR org_name LIKE ?)"
+		like := "%" + q + "%"
+		args = append(args, like, like, like)
+	}
+	if role := c.Query("role"); role != "" {
+		whereClause += " AND role = ?"
+		args = append(args, role)
+	}
+	if verified := c.Query("verified"); verified != "" {
+		v, err := strconv.ParseBool(verified)
+		if err != nil {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Invalid verified filter",
+			})
+		}
+		whereClause += " AND verified = ?"
+		args = append(args, v)
+	}
+	if isOrg := c.Query("is_organization"); isOrg != "" {
+		v, err := strconv.ParseBool(isOrg)
+		if err != nil {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Invalid is_organization filter",
+			})
+		}
+		whereClause += " AND is_organization = ?"
+		args = append(args, v)
+	}
 
 	// Get total count
 	var total int
-	err := h.db.QueryRow("SELECT COUNT(*) FROM users").Scan(&total)
+	err = h.db.QueryRow("SELECT COUNT(*) FROM users "+whereClause, args...).Scan(&total)
 	if err != nil {
 		return c.Status(500).JSON(models.APIResponse{
 			Success: false,
@@ -487,10 +763,14 @@ func (h *UserHandler) GetUsers(c *fiber.Ctx) error {
 	}
 
 	// Get users
-	rows, err := h.db.Query(
-		"SELECT id, name, email, verified, created_at FROM users ORDER BY created_at DESC LIMIT ? OFFSET ?",
-		limit, offset,
-	)
+	query := `
+		SELECT id, name, email, role, verified, is_organization, org_verified,
+			COALESCE(org_name, ''), COALESCE(org_logo_url, ''), COALESCE(department, ''),
+			COALESCE(profile_picture, ''), created_at, updated_at
+		FROM users ` + whereClause + `
+		ORDER BY created_at DESC
+		LIMIT ? OFFSET ?`
+	rows, err := h.db.Query(query, append(args, limit, offset)...)
 	if err != nil {
 		return c.Status(500).JSON(models.APIResponse{
 			Success: false,
@@ -499,10 +779,14 @@ func (h *UserHandler) GetUsers(c *fiber.Ctx) error {
 	}
 	defer rows.Close()
 
-	var users []models.User
+	users := []models.User{}
 	for rows.Next() {
 		var user models.User
-		err := rows.Scan(&user.ID, &user.Name, &user.Email, &user.Verified, &user.CreatedAt)
+		err := rows.Scan(
+			&user.ID, &user.Name, &user.Email, &user.Role, &user.Verified,
+			&user.IsOrganization, &user.OrgVerified, &user.OrgName, &user.OrgLogoURL,
+			&user.Department, &user.ProfilePicture, &user.CreatedAt, &user.UpdatedAt,
+		)
 		if err != nil {
 			continue
 		}
@@ -553,36 +837,30 @@ func (h *UserHandler) SaveProduct(c *fiber.Ctx) error {
 		})
 	}
 
-	// Check if already saved (including soft-deleted ones)
-	var existingID sql.NullInt64
-	err = h.db.QueryRow("SELECT id FROM saved_products WHERE user_id = ? AND product_id = ?", userID, req.ProductID).Scan(&existingID)
-
-	if err == nil && existingID.Valid {
-		// Record exists - check if it's soft-deleted
-		var deletedAt sql.NullTime
-		err = h.db.QueryRow("SELECT deleted_at FROM saved_products WHERE id = ?", existingID.Int64).Scan(&deletedAt)
-		if err == nil {
-			if deletedAt.Valid && !deletedAt.Time.IsZero() {
-				// Restore soft-deleted record
-				_, err = h.db.Exec("UPDATE saved_products SET deleted_at = NULL, updated_at = NOW() WHERE id = ?", existingID.Int64)
-				if err != nil {
-					return c.Status(500).JSON(models.APIResponse{
-						Success: false,
-						Error:   "Failed to restore saved product",
-					})
-				}
-				return c.JSON(models.APIResponse{
-					Success: true,
-					Message: "Product saved successfully",
-				})
-			} else {
-				// Already saved and not deleted
-				return c.Status(409).JSON(models.APIResponse{
-					Success: false,
-					Error:   "Product already saved",
-				})
-			}
+	// Check if already saved (including soft-deleted ones). A non-NULL deleted_at is the
+	// only mark of a removed save.
+	var existingID int64
+	var deleted bool
+	err = h.db.QueryRow("SELECT id, deleted_at IS NOT NULL FROM saved_products WHERE user_id = ? AND product_id = ?", userID, req.ProductID).Scan(&existingID, &deleted)
+	if err == nil {
+		if !deleted {
+			return c.Status(409).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Product already saved",
+			})
 		}
+		// Restore soft-deleted record
+		_, err = h.db.Exec("UPDATE saved_products SET deleted_at = NULL, updated_at = NOW() WHERE id = ?", existingID)
+		if err != nil {
+			return c.Status(500).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Failed to restore saved product",
+			})
+		}
+		return c.JSON(models.APIResponse{
+			Success: true,
+			Message: "Product saved successfully",
+		})
 	} else if err != sql.ErrNoRows {
 		// Some other error occurred
 		fmt.Printf("❌ SaveProduct check failed!\n")
@@ -596,6 +874,13 @@ func (h *UserHandler) SaveProduct(c *fiber.Ctx) error {
 
 	// Save the product (new record)
 	_, err = h.db.Exec("INSERT INTO saved_products (user_id, product_id, created_at) VALUES (?, ?, NOW())", userID, req.ProductID)
+	if isDuplicateEntry(err) {
+		// A concurrent request saved it first
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Product already saved",
+		})
+	}
 	if err != nil {
 		return c.Status(500).JSON(models.APIResponse{
 			Success: false,
@@ -627,7 +912,7 @@ func (h *UserHandler) UnsaveProduct(c *fiber.Ctx) error {
 	}
 
 	// Soft delete the saved product
-	result, err := h.db.Exec("UPDATE saved_products SET deleted_at = NOW() WHERE user_id = ? AND product_id = ? AND (deleted_at IS NULL OR deleted_at = '0000-00-00 00:00:00')", userID, productID)
+	result, err := h.db.Exec("UPDATE saved_products SET deleted_at = NOW() WHERE user_id = ? AND product_id = ? AND deleted_at IS NULL", userID, productID)
 	if err != nil {
 		fmt.Printf("❌ UnsaveProduct query failed!\n")
 		fmt.Printf("UserID: %d, ProductID: %d\n", userID, productID)
@@ -671,7 +956,7 @@ func (h *UserHandler) CheckSavedProduct(c *fiber.Ctx) error {
 	}
 	var isSaved bool
 	// Keep check that excludes soft-deleted saved_products
-	query := "SELECT EXISTS(SELECT 1 FROM saved_products WHERE user_id = ? AND product_id = ? AND (deleted_at IS NULL OR deleted_at = '0000-00-00 00:00:00'))"
+	query := "SELECT EXISTS(SELECT 1 FROM saved_products WHERE user_id = ? AND product_id = ? AND deleted_at IS NULL)"
 	if err := h.db.QueryRow(query, userID, productID).Scan(&isSaved); err != nil {
 		// Log for debugging
 		fmt.Printf("❌ Failed to check saved status (user=%d, product=%d): %v\n", userID, productID, err)
@@ -698,13 +983,15 @@ func (h *UserHandler) GetSavedProducts(c *fiber.Ctx) error {
 			Error:   "User not authenticated",
 		})
 	}
-	page, _ := strconv.Atoi(c.Query("page", "1"))
-	limit, _ := strconv.Atoi(c.Query("limit", "10"))
-	offset := (page - 1) * limit
+	pg, err := parsePaginationDefault(c, 10)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: err.Error()})
+	}
+	page, limit, offset := pg.Page, pg.Limit, pg.Offset
 
 	// Get total count (excluding soft-deleted)
 	var total int
-	err := h.db.QueryRow("SELECT COUNT(*) FROM saved_products WHERE user_id = ? AND (deleted_at IS NULL OR deleted_at = '0000-00-00 00:00:00')", userID).Scan(&total)
+	err = h.db.QueryRow("SELECT COUNT(*) FROM saved_products WHERE user_id = ? AND deleted_at IS NULL", userID).Scan(&total)
 	if err != nil {
 		fmt.Printf("❌ GetSavedProducts count query failed!\n")
 		fmt.Printf("UserID: %d\n", userID)
@@ -720,13 +1007,13 @@ func (h *UserHandler) GetSavedProducts(c *fiber.Ctx) error {
 		SELECT 
 			p.id, p.title, p.description, p.price, p.image_urls, p.seller_id,
 			p.premium, p.status, p.allow_buying, p.barter_only, p.location,
-			p.condition, p.suggested_value, p.category, p.created_at, p.updated_at,
+			p.condition, p.suggested_value, p.category, COALESCE(p.currency, 'PHP'), p.created_at, p.updated_at,
 			u.name as seller_name,
 			sp.created_at as saved_at
 		FROM saved_products sp
 		JOIN products p ON p.id = sp.product_id
 		JOIN users u ON u.id = p.seller_id
-		WHERE sp.user_id = ? AND (sp.deleted_at IS NULL OR sp.deleted_at = '0000-00-00 00:00:00')
+		WHERE sp.user_id = ? AND sp.deleted_at IS NULL
 		ORDER BY sp.created_at DESC
 		LIMIT ? OFFSET ?
 	`, userID, limit, offset)
@@ -746,7 +1033,7 @@ func (h *UserHandler) GetSavedProducts(c *fiber.Ctx) error {
 			&product.ID, &product.Title, &product.Description, &product.Price,
 			&product.ImageURLs, &product.SellerID, &product.Premium, &product.Status,
 			&product.AllowBuying, &product.BarterOnly, &product.Location,
-			&product.Condition, &product.SuggestedValue, &product.Category,
+			&product.Condition, &product.SuggestedValue, &product.Category, &product.Currency,
 			&product.CreatedAt, &product.UpdatedAt, &product.SellerName, &savedAt,
 		)
 		if err != nil {
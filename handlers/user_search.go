@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/models"
+)
+
+// SearchUsers is the public people search. ?q= matches name, username, organization
+// name and department; ?department= and ?is_organization= narrow the results.
+// Exact and prefix name matches rank first, then verified accounts.
+func (h *UserHandler) SearchUsers(c *fiber.Ctx) error {
+	pg, err := parsePagination(c)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: err.Error()})
+	}
+
+	q := strings.TrimSpace(c.Query("q"))
+	// Anonymized accounts and the deleted-user placeholder are not people to find
+	where := "WHERE u.email NOT LIKE ?"
+	args := []interface{}{"%" + deletedEmailDomain}
+	if q != "" {
+		like := "%" + q + "%"
+		where += " AND (u.name LIKE ? OR u.username LIKE ? OR u.org_name LIKE ? OR u.department LIKE ?)"
+		args = append(args, like, like, like, like)
+	}
+	if department := strings.TrimSpace(c.Query("department")); department != "" {
+		where += " AND u.department = ?"
+		args = append(args, department)
+	}
+	if isOrg := c.Query("is_organization"); isOrg != "" {
+		v, err := strconv.ParseBool(isOrg)
+		if err != nil {
+			return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid is_organization filter"})
+		}
+		where += " AND u.is_organization = ?"
+		args = append(args, v)
+	}
+
+	var total int
+	if err := h.db.QueryRow("SELECT COUNT(*) FROM users u "+where, args...).Scan(&total); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to search users"})
+	}
+
+	// Rank: exact name, name prefix, then verified people and organizations
+	orderArgs := []interface{}{q, q + "%"}
+	query := `
+		SELECT u.id, u.name, COALESCE(u.username, ''), u.verified, u.is_organization, u.org_verified,
+			COALESCE(u.org_name, ''), COALESCE(u.org_logo_url, ''), COALESCE(u.department, ''),
+			COALESCE(u.profile_picture, ''),
+			(SELECT COUNT(*) FROM products p WHERE p.seller_id = u.id AND p.status = 'available')
+		FROM users u ` + where + `
+		ORDER BY (u.name = ?) DESC, (u.name LIKE ?) DESC, (u.verified OR u.org_verified) DESC, u.name ASC, u.id ASC
+		LIMIT ? OFFSET ?`
+	rows, err := h.db.Query(query, append(append(args, orderArgs...), pg.Limit, pg.Offset)...)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to search users"})
+	}
+	defer rows.Close()
+
+	results := []models.UserSearchResult{}
+	for rows.Next() {
+		var r models.UserSearchResult
+		if err := rows.Scan(&r.ID, &r.Name, &r.Username, &r.Verified, &r.IsOrganization, &r.OrgVerified,
+			&r.OrgName, &r.OrgLogoURL, &r.Department, &r.ProfilePicture, &r.ActiveListings); err != nil {
+			continue
+		}
+		results = append(results, r)
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data: models.PaginatedResponse{
+			Data:       results,
+			Total:      total,
+			Page:       pg.Page,
+			Limit:      pg.Limit,
+			TotalPages: (total + pg.Limit - 1) / pg.Limit,
+		},
+	})
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/database"
+)
+
+// TestSearchUsersRanksExactMatchFirst checks ranking and that private fields stay hidden
+func TestSearchUsersRanksExactMatchFirst(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	name := fmt.Sprintf("Searchable%d", time.Now().UnixNano())
+	partial := createTestUser(t, db, "Zed "+name)
+	prefix := createTestUser(t, db, name+" Junior")
+	exact := createTestUser(t, db, name)
+
+	database.DB = db
+	h := NewUserHandler()
+	viewer := 0
+	app := newTestApp(&viewer, func(app *fiber.App) { app.Get("/users/search", h.SearchUsers) })
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/users/search?q="+url.QueryEscape(name), nil))
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	var body struct {
+		Data struct {
+			Data  []map[string]interface{} `json:"data"`
+			Total int                      `json:"total"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Data.Total != 3 || len(body.Data.Data) != 3 {
+		t.Fatalf("got %d of %d results, want 3", len(body.Data.Data), body.Data.Total)
+	}
+	want := []int{exact, prefix, partial}
+	for i, r := range body.Data.Data {
+		if int(r["id"].(float64)) != want[i] {
+			t.Errorf("result %d = user %v, want %d", i, r["id"], want[i])
+		}
+		if _, ok := r["email"]; ok {
+			t.Errorf("result %d exposes email", i)
+		}
+		if _, ok := r["role"]; ok {
+			t.Errorf("result %d exposes role", i)
+		}
+	}
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/users/search?is_organization=maybe", nil))
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if resp.StatusCode != 400 {
+		t.Errorf("invalid is_organization status = %d, want 400", resp.StatusCode)
+	}
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/xashathebest/clovia/middleware"
+	"github.com/xashathebest/clovia/models"
+)
+
+// maxVacation is the longest a seller can hide their listings in one go
+const maxVacation = 365 * 24 * time.Hour
+
+// sellerNotAwayClause keeps rows whose seller (joined as u) is not on vacation
+const sellerNotAwayClause = "(u.vacation_until IS NULL OR u.vacation_until <= NOW())"
+
+// vacationDateLayout is how vacation dates appear in messages
+const vacationDateLayout = "Jan 2, 2006"
+
+// sellerAwayUntil returns the seller's vacation end when they are currently away, or nil
+func sellerAwayUntil(q queryRower, sellerID int) (*time.Time, error) {
+	var until sql.NullTime
+	err := q.QueryRow("SELECT vacation_until FROM users WHERE id = ? AND vacation_until > NOW()", sellerID).Scan(&until)
+	if err == sql.ErrNoRows || (err == nil && !until.Valid) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &until.Time, nil
+}
+
+// sellerAwayMessage is the error shown when someone tries to trade with or buy from an away seller
+func sellerAwayMessage(until time.Time) string {
+	return fmt.Sprintf("This seller is away until %s, so their listings are unavailable", until.Format(vacationDateLayout))
+}
+
+// setVacation fills the vacation fields on a profile when the vacation is still running
+func setVacation(user *models.User, until sql.NullTime, now time.Time) {
+	if !until.Valid || !until.Time.After(now) {
+		return
+	}
+	t := until.Time
+	user.VacationUntil = &t
+	user.AwayNotice = fmt.Sprintf("Away until %s", t.Format(vacationDateLayout))
+}
+
+// parseVacationUntil accepts an RFC 3339 timestamp or a YYYY-MM-DD date (the end of that
+// day). An empty value means "clear vacation mode" and returns nil.
+func parseVacationUntil(raw string, now time.Time) (*time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	until, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		day, dayErr := time.ParseInLocation("2006-01-02", raw, now.Location())
+		if dayErr != nil {
+			return nil, fmt.Errorf("until must be a date (YYYY-MM-DD) or an RFC 3339 timestamp")
+		}
+		until = day.Add(24*time.Hour - time.Second)
+	}
+	if !until.After(now) {
+		return nil, fmt.Errorf("until must be in the future")
+	}
+	if until.Sub(now) > maxVacation {
+		return nil, fmt.Errorf("vacation can last at most %d days", int(maxVacation.Hours()/24))
+	}
+	return &until, nil
+}
+
+// SetVacation turns vacation mode on until a date, or off when until is empty or null.
+// Body: { "until": "2026-08-01" }
+func (h *UserHandler) SetVacation(c *fiber.Ctx) error {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(401).JSON(models.APIResponse{Success: false, Error: "User not authenticated"})
+	}
+	var payload struct {
+		Until *string `json:"until"`
+	}
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: "Invalid request body"})
+	}
+	raw := ""
+	if payload.Until != nil {
+		raw = *payload.Until
+	}
+	until, err := parseVacationUntil(raw, time.Now())
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Success: false, Error: err.Error()})
+	}
+
+	if _, err := h.db.Exec("UPDATE users SET vacation_until = ?, updated_at = NOW() WHERE id = ?", until, userID); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Success: false, Error: "Failed to update vacation mode"})
+	}
+
+	if until == nil {
+		return c.JSON(models.APIResponse{Success: true, Message: "Vacation mode off", Data: fiber.Map{"vacation_until": nil}})
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: fmt.Sprintf("Vacation mode on until %s", until.Format(vacationDateLayout)),
+		Data:    fiber.Map{"vacation_until": until},
+	})
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/xashathebest/clovia/models"
+)
+
+func TestParseVacationUntil(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	if until, err := parseVacationUntil("  ", now); err != nil || until != nil {
+		t.Fatalf("empty value = %v, %v; want nil (clear)", until, err)
+	}
+	until, err := parseVacationUntil("2026-03-20", now)
+	if err != nil {
+		t.Fatalf("date: %v", err)
+	}
+	if want := time.Date(2026, 3, 20, 23, 59, 59, 0, time.UTC); !until.Equal(want) {
+		t.Errorf("date until = %v, want end of day %v", until, want)
+	}
+	if _, err := parseVacationUntil("2026-03-11T08:00:00Z", now); err != nil {
+		t.Errorf("timestamp rejected: %v", err)
+	}
+	for _, raw := range []string{"2026-03-01", "next week", "2028-01-01"} {
+		if _, err := parseVacationUntil(raw, now); err == nil {
+			t.Errorf("parseVacationUntil(%q) accepted, want error", raw)
+		}
+	}
+}
+
+func TestSetVacationIgnoresPastDates(t *testing.T) {
+	now := time.Now()
+	var user models.User
+	setVacation(&user, sql.NullTime{Time: now.Add(-time.Hour), Valid: true}, now)
+	if user.VacationUntil != nil || user.AwayNotice != "" {
+		t.Errorf("expired vacation shown on profile: %+v", user)
+	}
+	setVacation(&user, sql.NullTime{Time: now.Add(48 * time.Hour), Valid: true}, now)
+	if user.VacationUntil == nil || user.AwayNotice == "" {
+		t.Errorf("active vacation missing from profile: %+v", user)
+	}
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// errNotWishlistable is returned for products that do not exist or are not public
+var errNotWishlistable = errors.New("Product not found")
+
+// addToWishlist records the wishlist entry and bumps products.wishlist_count in one
+// transaction. It reports false when the product was already wishlisted, in which case the
+// count is left alone. The increment is relative, so concurrent adds never lose an update.
+// Drafts and removed listings are refused with errNotWishlistable, as if they did not exist.
+func addToWishlist(db *sql.DB, userID, productID int) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRow("SELECT status FROM products WHERE id = ?", productID).Scan(&status)
+	if err == sql.ErrNoRows || (err == nil && hiddenProductStatuses[status]) {
+		return false, errNotWishlistable
+	}
+	if err != nil {
+		return false, err
+	}
+
+	res, err := tx.Exec("INSERT IGNORE INTO wishlists (user_id, product_id) VALUES (?, ?)", userID, productID)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	if _, err := tx.Exec("UPDATE products SET wishlist_count = wishlist_count + 1 WHERE id = ?", productID); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// removeFromWishlist deletes the wishlist entry and lowers products.wishlist_count with it.
+// It reports false when there was nothing to remove.
+func removeFromWishlist(db *sql.DB, userID, productID int) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("DELETE FROM wishlists WHERE user_id = ? AND product_id = ?", userID, productID)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	if _, err := tx.Exec("UPDATE products SET wishlist_count = GREATEST(wishlist_count - 1, 0) WHERE id = ?", productID); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// clearWishlist deletes all of a user's wishlist entries and lowers the count on every
+// product they wishlisted, inside the caller's transaction
+func clearWishlist(tx *sql.Tx, userID int) error {
+	if _, err := tx.Exec(`UPDATE products p JOIN wishlists w ON w.product_id = p.id
+		SET p.wishlist_count = GREATEST(p.wishlist_count - 1, 0) WHERE w.user_id = ?`, userID); err != nil {
+		return err
+	}
+	_, err := tx.Exec("DELETE FROM wishlists WHERE user_id = ?", userID)
+	return err
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/xashathebest/clovia/database"
+	"github.com/xashathebest/clovia/models"
+)
+
+// TestWishlistCountUnderConcurrentAdds wishlists one product from several users at once
+// and checks the cached count matches the wishlist rows
+func TestWishlistCountUnderConcurrentAdds(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	sellerID := createTestUser(t, db, "wishcount_seller")
+	productID := createTestProduct(t, db, sellerID, "Popular Item")
+	defer db.Exec("DELETE FROM products WHERE id = ?", productID)
+
+	const fans = 8
+	users := make([]int, fans)
+	for i := range users {
+		users[i] = createTestUser(t, db, fmt.Sprintf("wishcount_fan%d", i))
+	}
+
+	var wg sync.WaitGroup
+	for _, uid := range users {
+		wg.Add(2)
+		// Each user adds twice; only the first add may count
+		for j := 0; j < 2; j++ {
+			go func(uid int) {
+				defer wg.Done()
+				if _, err := addToWishlist(db, uid, productID); err != nil {
+					t.Errorf("add for user %d: %v", uid, err)
+				}
+			}(uid)
+		}
+	}
+	wg.Wait()
+
+	count := func() int {
+		var n int
+		db.QueryRow("SELECT wishlist_count FROM products WHERE id = ?", productID).Scan(&n)
+		return n
+	}
+	if got := count(); got != fans {
+		t.Errorf("wishlist_count = %d after %d users added, want %d", got, fans, fans)
+	}
+
+	if removed, err := removeFromWishlist(db, users[0], productID); err != nil || !removed {
+		t.Fatalf("remove: %v %v", removed, err)
+	}
+	if removed, _ := removeFromWishlist(db, users[0], productID); removed {
+		t.Error("second remove reported a removal")
+	}
+	if got := count(); got != fans-1 {
+		t.Errorf("wishlist_count = %d after one removal, want %d", got, fans-1)
+	}
+}
+
+// TestWishlistRefusesHiddenListings tries to wishlist another seller's draft and checks it
+// is treated as missing and does not leak through the wishlist or its count
+func TestWishlistRefusesHiddenListings(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	database.DB = db
+
+	sellerID := createTestUser(t, db, "wishdraft_seller")
+	fanID := createTestUser(t, db, "wishdraft_fan")
+	productID := createTestProduct(t, db, sellerID, "Secret Draft")
+	defer db.Exec("DELETE FROM products WHERE id = ?", productID)
+	db.Exec("UPDATE products SET status = 'draft' WHERE id = ?", productID)
+
+	if _, err := addToWishlist(db, fanID, productID); err != errNotWishlistable {
+		t.Fatalf("addToWishlist(draft) = %v, want errNotWishlistable", err)
+	}
+
+	// A save made while the listing was public must not expose it once hidden
+	db.Exec("INSERT INTO wishlists (user_id, product_id) VALUES (?, ?)", fanID, productID)
+	wishlists := NewWishlistHandler()
+	products := &ProductHandler{db: db}
+	app := newTestApp(&fanID, func(app *fiber.App) {
+		app.Get("/wishlist", wishlists.GetWishlist)
+		app.Get("/products/:id/wishlist-count", products.GetWishlistCount)
+	})
+	resp, err := app.Test(httptest.NewRequest("GET", "/wishlist", nil), -1)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	var body struct {
+		Data []models.Wishlist `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	if len(body.Data) != 0 {
+		t.Errorf("wishlist shows %d hidden listings, want 0", len(body.Data))
+	}
+	resp, err = app.Test(httptest.NewRequest("GET", fmt.Sprintf("/products/%d/wishlist-count", productID), nil), -1)
+	if err != nil || resp.StatusCode != 404 {
+		t.Errorf("wishlist count of a draft: %v (%v), want 404", resp.StatusCode, err)
+	}
+}
@@ -30,14 +30,25 @@ func (h *WishlistHandler) AddToWishlist(c *fiber.Ctx) error {
 		return fiber.ErrBadRequest
 	}
 
-	query := `INSERT INTO wishlists (user_id, product_id) VALUES (?, ?)`
-	_, err := database.DB.Exec(query, userID, payload.ProductID)
+	added, err := addToWishlist(database.DB, userID, payload.ProductID)
+	if err == errNotWishlistable {
+		return c.Status(fiber.StatusNotFound).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(models.APIResponse{
 			Success: false,
 			Error:   "Failed to add product to wishlist",
 		})
 	}
+	if !added {
+		return c.Status(fiber.StatusConflict).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Product is already in your wishlist",
+		})
+	}
 
 	return c.Status(fiber.StatusCreated).JSON(models.APIResponse{
 		Success: true,
@@ -57,17 +68,14 @@ func (h *WishlistHandler) RemoveFromWishlist(c *fiber.Ctx) error {
 		return fiber.ErrBadRequest
 	}
 
-	query := `DELETE FROM wishlists WHERE user_id = ? AND product_id = ?`
-	res, err := database.DB.Exec(query, userID, productID)
+	removed, err := removeFromWishlist(database.DB, userID, productID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(models.APIResponse{
 			Success: false,
 			Error:   "Failed to remove product from wishlist",
 		})
 	}
-
-	rowsAffected, _ := res.RowsAffected()
-	if rowsAffected == 0 {
+	if !removed {
 		return c.Status(fiber.StatusNotFound).JSON(models.APIResponse{
 			Success: false,
 			Error:   "Product not found in wishlist",
@@ -87,13 +95,15 @@ func (h *WishlistHandler) GetWishlist(c *fiber.Ctx) error {
 		return fiber.ErrUnauthorized
 	}
 
+	// Drafts and removed listings are not public, even to users who wishlisted them earlier
 	query := `
 		SELECT 
 			w.id, w.user_id, w.product_id, w.created_at,
-			p.id, p.title, p.description, p.price, p.image_url, p.seller_id, p.status
+			p.id, p.title, p.description, p.price, p.image_url, p.seller_id, p.status,
+			p.allow_buying, p.barter_only
 		FROM wishlists w
 		JOIN products p ON w.product_id = p.id
-		WHERE w.user_id = ?
+		WHERE w.user_id = ? AND ` + visibleStatusClause("p.status") + `
 		ORDER BY w.created_at DESC`
 	rows, err := database.DB.Query(query, userID)
 	if err != nil {
@@ -111,10 +121,12 @@ func (h *WishlistHandler) GetWishlist(c *fiber.Ctx) error {
 		err := rows.Scan(
 			&item.ID, &item.UserID, &item.ProductID, &item.CreatedAt,
 			&product.ID, &product.Title, &product.Description, &product.Price, &product.ImageURL, &product.SellerID, &product.Status,
+			&product.AllowBuying, &product.BarterOnly,
 		)
 		if err != nil {
 			continue
 		}
+		// can_buy is derived from these by Product.MarshalJSON via models.PurchaseAllowed
 		item.Product = &product
 		wishlist = append(wishlist, item)
 	}
@@ -10,10 +10,12 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/joho/godotenv"
+	"github.com/xashathebest/clovia/config"
 	"github.com/xashathebest/clovia/database"
 	"github.com/xashathebest/clovia/handlers"
 	"github.com/xashathebest/clovia/middleware"
 	"github.com/xashathebest/clovia/services"
+	"github.com/xashathebest/clovia/storage"
 )
 
 func main() {
@@ -33,8 +35,29 @@ func main() {
 		log.Fatal("Failed to create database tables:", err)
 	}
 
+	// Tokens of deleted accounts must stay rejected across restarts
+	if err := middleware.LoadTokenBlacklist(database.DB); err != nil {
+		log.Fatal("Failed to load token blacklist:", err)
+	}
+
+	// Select the upload storage backend (local UPLOAD_DIR unless STORAGE_BACKEND=s3)
+	if err := storage.Init(); err != nil {
+		log.Fatal("Failed to configure storage:", err)
+	}
+
+	// Delivery caps change what riders carry; refuse to start on a bad value
+	if _, err := config.DeliveryLimitsFromEnv(); err != nil {
+		log.Fatal("Invalid delivery limits: ", err)
+	}
+
+	// Apply any counterfeit thresholds saved by an admin; env defaults otherwise
+	if err := services.LoadCounterfeitSettings(database.DB); err != nil {
+		log.Printf("Warning: failed to load counterfeit settings, using defaults: %v", err)
+	}
+
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
+		BodyLimit: config.BodyLimit(),
 		ErrorHandler: func(c *fiber.Ctx, err error) error {
 			code := fiber.StatusInternalServerError
 			if e, ok := err.(*fiber.Error); ok {
@@ -56,8 +79,15 @@ func main() {
 		AllowMethods: "GET, POST, PUT, DELETE, OPTIONS",
 	}))
 
-	// Serve static files (uploads directory)
-	app.Static("/uploads", "./uploads")
+	// Reject writes while read-only maintenance mode is on
+	middleware.InitMaintenanceMode()
+	app.Use(middleware.MaintenanceMiddleware())
+	// Bound each request's database work so slow handlers cannot hold pool connections
+	app.Use(middleware.RequestTimeoutMiddleware())
+
+	// Serve static files from the local storage root; kept under S3 too so files uploaded
+	// before switching backends stay reachable
+	app.Static(storage.LocalBaseURL, storage.LocalDir())
 
 	// Add after middleware setup
 	app.Get("/", func(c *fiber.Ctx) error {
@@ -70,9 +100,10 @@ func main() {
 	// Health check
 	app.Get("/health", func(c *fiber.Ctx) error {
 		return c.JSON(fiber.Map{
-			"success": true,
-			"message": "Clovia API is running",
-			"version": "1.0.0",
+			"success":     true,
+			"message":     "Clovia API is running",
+			"version":     config.APIVersion,
+			"maintenance": middleware.CurrentMaintenanceMode(),
 		})
 	})
 
@@ -107,6 +138,9 @@ func main() {
 	wishlistHandler := handlers.NewWishlistHandler()
 	aiFeaturesHandler := handlers.NewAIFeaturesHandler()
 	deliveryHandler := handlers.NewDeliveryHandler()
+	meetupHandler := handlers.NewMeetupHandler()
+	feeHandler := handlers.NewFeeHandler()
+	metaHandler := handlers.NewMetaHandler()
 
 	// Auth routes (no authentication required)
 	auth := api.Group("/auth")
@@ -122,6 +156,23 @@ func main() {
 	users.Post("/change-password", middleware.AuthMiddleware(), userHandler.ChangePassword)
 	users.Put("/change-password", middleware.AuthMiddleware(), userHandler.ChangePassword)
 	users.Patch("/change-password", middleware.AuthMiddleware(), userHandler.ChangePassword)
+	// Unified activity feed (offers, replies, comments, wishlist adds, orders)
+	users.Get("/me/activity", middleware.AuthMiddleware(), userHandler.GetMyActivity)
+	users.Get("/me/dashboard", middleware.AuthMiddleware(), userHandler.GetMyDashboard)
+	users.Get("/me/inventory-value", middleware.AuthMiddleware(), userHandler.GetInventoryValue)
+	users.Get("/me/locked-products", middleware.AuthMiddleware(), userHandler.GetLockedProducts)
+	users.Get("/me/drafts", middleware.AuthMiddleware(), productHandler.GetMyDrafts)
+	users.Get("/me/back-in-stock", middleware.AuthMiddleware(), productHandler.GetBackInStock)
+	users.Put("/me/vacation", middleware.AuthMiddleware(), userHandler.SetVacation)
+	users.Post("/me/payouts", middleware.AuthMiddleware(), userHandler.RequestPayout)
+	users.Get("/me/payouts", middleware.AuthMiddleware(), userHandler.GetMyPayouts)
+	users.Get("/me/export", middleware.AuthMiddleware(), userHandler.ExportMyData)
+	users.Delete("/me", middleware.AuthMiddleware(), userHandler.DeleteAccount)
+	users.Get("/me/notification-preferences", middleware.AuthMiddleware(), userHandler.GetNotificationPreferences)
+	users.Put("/me/notification-preferences", middleware.AuthMiddleware(), userHandler.UpdateNotificationPreferences)
+	users.Get("/:id/rating-breakdown", userHandler.GetRatingBreakdown)
+	users.Get("/:id/presence", middleware.AuthMiddleware(), userHandler.GetUserPresence)
+	users.Get("/:id/tradeable", middleware.OptionalAuthMiddleware(), userHandler.GetTradeableListings)
 
 	// Saved products routes (must be BEFORE dynamic ":id" route)
 	users.Post("/saved-products", middleware.AuthMiddleware(), userHandler.SaveProduct)
@@ -129,9 +180,14 @@ func main() {
 	users.Get("/saved-products/:id", middleware.AuthMiddleware(), userHandler.CheckSavedProduct)
 	users.Get("/saved-products", middleware.AuthMiddleware(), userHandler.GetSavedProducts)
 
+	// Public people search (must be BEFORE dynamic ":id" route)
+	users.Get("/search", userHandler.SearchUsers)
+	users.Get("/recommended-sellers", middleware.AuthMiddleware(), userHandler.GetRecommendedSellers)
+
 	// Dynamic and list routes placed after static subpaths
-	users.Get("/:id", userHandler.GetUserByID) // Public route
-	users.Get("/", userHandler.GetUsers)       // Admin route (no auth for demo)
+	users.Get("/by-username/:username", userHandler.GetUserByUsername)                              // Public route
+	users.Get("/:id", userHandler.GetUserByID)                                                      // Public route
+	users.Get("/", middleware.AuthMiddleware(), middleware.AdminMiddleware(), userHandler.GetUsers) // Admin route
 
 	// Product routes
 	products := api.Group("/products")
@@ -139,11 +195,24 @@ func main() {
 	products.Get("", productHandler.GetProducts)                       // Support no trailing slash
 	products.Get("/user/:id", productHandler.GetUserProducts)          // Public route
 	products.Get("/user/:id/listings", productHandler.GetUserProducts) // alias for listings
+	products.Get("/stats", productHandler.GetProductStats)             // Public, cached feed stats
+	products.Get("/suggest", productHandler.SuggestProducts)           // Public typeahead
 	// Specific routes must come before generic :id route
 	products.Get("/:id/wishlist/status", middleware.AuthMiddleware(), productHandler.GetUserWishlistStatus)
-	products.Get("/:id/comments", commentHandler.GetComments)
+	products.Get("/:id/comments", middleware.OptionalAuthMiddleware(), commentHandler.GetComments)
 	products.Post("/:id/comments", middleware.AuthMiddleware(), commentHandler.CreateComment)
-	products.Get("/:id", productHandler.GetProduct) // Public route (must be last)
+	products.Get("/transfers/incoming", middleware.AuthMiddleware(), productHandler.GetIncomingTransfers)
+	products.Post("/transfers/:token/accept", middleware.AuthMiddleware(), productHandler.AcceptProductTransfer)
+	products.Post("/transfers/:token/decline", middleware.AuthMiddleware(), productHandler.DeclineProductTransfer)
+	products.Post("/:id/transfer", middleware.AuthMiddleware(), productHandler.TransferProduct)
+	products.Post("/:id/regeocode", middleware.AuthMiddleware(), productHandler.RegeocodeProduct)
+	products.Post("/:id/publish", middleware.AuthMiddleware(), productHandler.PublishProduct)
+	products.Post("/:id/premium", middleware.AuthMiddleware(), productHandler.PurchasePremium)
+	products.Post("/:id/regenerate-slug", middleware.AuthMiddleware(), productHandler.RegenerateSlug)
+	products.Post("/:id/price-offers", middleware.AuthMiddleware(), productHandler.CreatePriceOffer)
+	products.Get("/:id/price-offers", middleware.AuthMiddleware(), productHandler.GetPriceOffers)
+	products.Get("/compare", middleware.OptionalAuthMiddleware(), productHandler.CompareProducts)
+	products.Get("/:id", middleware.OptionalAuthMiddleware(), productHandler.GetProduct) // Public route (must be last)
 	products.Post("/", middleware.AuthMiddleware(), productHandler.CreateProduct)
 	products.Get("/", productHandler.GetProducts) // Public route
 	products.Get("", productHandler.GetProducts)  // Support no trailing slash
@@ -151,7 +220,10 @@ func main() {
 	products.Get("/user/:id", productHandler.GetUserProducts)          // Public route
 	products.Get("/user/:id/listings", productHandler.GetUserProducts) // alias for listings
 	products.Post("/:id/vote", middleware.AuthMiddleware(), productHandler.VoteProduct)
-	products.Get("/:id/comments", commentHandler.GetComments)
+	products.Get("/:id/votes", middleware.OptionalAuthMiddleware(), productHandler.GetProductVotes)
+	products.Get("/:id/votes/stream", productHandler.StreamProductVotes)
+	products.Get("/:id/history", middleware.AuthMiddleware(), productHandler.GetProductStatusHistory)
+	products.Get("/:id/comments", middleware.OptionalAuthMiddleware(), commentHandler.GetComments)
 	products.Post("/:id/comments", middleware.AuthMiddleware(), commentHandler.CreateComment)
 	// User-specific wishlist status for a product
 	products.Get("/:id/wishlist/status", middleware.AuthMiddleware(), productHandler.GetUserWishlistStatus)
@@ -163,13 +235,21 @@ func main() {
 	orders := api.Group("/orders")
 	orders.Post("/", middleware.AuthMiddleware(), orderHandler.CreateOrder)
 	orders.Get("/", middleware.AuthMiddleware(), orderHandler.GetOrders)
+	orders.Get("/summary", middleware.AuthMiddleware(), orderHandler.GetOrderSummary)
 	orders.Get("/:id", middleware.AuthMiddleware(), orderHandler.GetOrder)
 	orders.Put("/:id/status", middleware.AuthMiddleware(), orderHandler.UpdateOrderStatus)
+	orders.Post("/:id/conversation", middleware.AuthMiddleware(), orderHandler.OpenOrderConversation)
+
+	// Price offers on buyable listings (created under /products/:id/price-offers)
+	priceOffers := api.Group("/price-offers")
+	priceOffers.Put("/:id", middleware.AuthMiddleware(), productHandler.RespondToPriceOffer)
 
 	// Chat routes (REST + SSE)
 	chat := api.Group("/chat")
 	chat.Get("/conversations", middleware.AuthMiddleware(), chatHandler.GetConversations)
 	chat.Get("/conversations/:id/messages", middleware.AuthMiddleware(), chatHandler.GetMessages)
+	chat.Post("/conversations/:id/mute", middleware.AuthMiddleware(), chatHandler.MuteConversation)
+	chat.Post("/conversations/:id/unmute", middleware.AuthMiddleware(), chatHandler.UnmuteConversation)
 	chat.Post("/conversations", middleware.AuthMiddleware(), chatHandler.EnsureConversation)
 	chat.Post("/messages", middleware.AuthMiddleware(), chatHandler.SendMessage)
 	chat.Post("/typing", middleware.AuthMiddleware(), chatHandler.Typing)
@@ -184,21 +264,42 @@ func main() {
 	trades.Get("/:id", middleware.AuthMiddleware(), tradeHandler.GetTrade)
 	trades.Get("/:id/messages", middleware.AuthMiddleware(), tradeHandler.GetTradeMessages)
 	trades.Post("/:id/messages", middleware.AuthMiddleware(), tradeHandler.SendTradeMessage)
+	trades.Post("/:id/messages/read", middleware.AuthMiddleware(), tradeHandler.MarkTradeMessagesRead)
 	trades.Get("/:id/history", middleware.AuthMiddleware(), tradeHandler.GetTradeHistory)
 	// Allow optional auth for counts endpoint so unauthenticated UI polling returns a safe zero value
 	trades.Get("/count", middleware.OptionalAuthMiddleware(), tradeHandler.CountTrades)
 	trades.Put("/:id/complete", middleware.AuthMiddleware(), tradeHandler.CompleteTrade)
 	trades.Get("/:id/completion-status", middleware.AuthMiddleware(), tradeHandler.GetTradeCompletionStatus)
+	if config.DeliveryEnabled() {
+		trades.Post("/:id/arrange-delivery", middleware.AuthMiddleware(), deliveryHandler.ArrangeTradeDelivery)
+	}
 
 	// Notifications routes
 	notifs := api.Group("/notifications")
 	notifs.Get("/", middleware.AuthMiddleware(), notificationHandler.GetNotifications)
 	notifs.Put("/:id/read", middleware.AuthMiddleware(), notificationHandler.MarkAsRead)
 	notifs.Put("/read-all", middleware.AuthMiddleware(), notificationHandler.MarkAllAsRead)
+	notifs.Put("/read", middleware.AuthMiddleware(), notificationHandler.MarkReadByFilter)
+	notifs.Get("/announcements", middleware.AuthMiddleware(), userHandler.GetAnnouncements)
 
 	// Admin routes
 	admin := api.Group("/admin")
 	admin.Get("/stats", middleware.AuthMiddleware(), middleware.AdminMiddleware(), adminHandler.GetAdminStats)
+	admin.Put("/users/:id/listing-limit", middleware.AuthMiddleware(), middleware.AdminMiddleware(), adminHandler.SetListingLimit)
+	admin.Post("/impersonate/:userId", middleware.AuthMiddleware(), middleware.AdminMiddleware(), adminHandler.ImpersonateUser)
+	admin.Get("/comments", middleware.AuthMiddleware(), middleware.AdminMiddleware(), adminHandler.GetModerationComments)
+	admin.Put("/comments/:id/moderate", middleware.AuthMiddleware(), middleware.AdminMiddleware(), adminHandler.ModerateComment)
+	admin.Get("/counterfeit-settings", middleware.AuthMiddleware(), middleware.AdminMiddleware(), adminHandler.GetCounterfeitSettings)
+	admin.Put("/counterfeit-settings", middleware.AuthMiddleware(), middleware.AdminMiddleware(), adminHandler.UpdateCounterfeitSettings)
+	admin.Put("/maintenance", middleware.AuthMiddleware(), middleware.AdminMiddleware(), adminHandler.SetMaintenanceMode)
+	admin.Post("/trades/:id/repair-items", middleware.AuthMiddleware(), middleware.AdminMiddleware(), adminHandler.RepairTradeItems)
+	admin.Get("/products/bad-images", middleware.AuthMiddleware(), middleware.AdminMiddleware(), adminHandler.GetBadImageProducts)
+	admin.Post("/products/:id/takedown", middleware.AuthMiddleware(), middleware.AdminMiddleware(), adminHandler.TakedownProduct)
+	admin.Get("/payouts", middleware.AuthMiddleware(), middleware.AdminMiddleware(), adminHandler.ListPayouts)
+	admin.Put("/payouts/:id", middleware.AuthMiddleware(), middleware.AdminMiddleware(), adminHandler.UpdatePayout)
+	admin.Post("/announcements", middleware.AuthMiddleware(), middleware.AdminMiddleware(), adminHandler.CreateAnnouncement)
+	admin.Post("/cleanup-uploads", middleware.AuthMiddleware(), middleware.AdminMiddleware(), adminHandler.CleanupUploads)
+	admin.Get("/duplicates", middleware.AuthMiddleware(), middleware.AdminMiddleware(), adminHandler.GetDuplicateListings)
 
 	// Wishlist routes
 	wishlist := api.Group("/wishlist")
@@ -207,25 +308,45 @@ func main() {
 	wishlist.Delete("/:productId", middleware.AuthMiddleware(), wishlistHandler.RemoveFromWishlist)
 
 	// Delivery routes
-	deliveries := api.Group("/deliveries")
-	deliveries.Post("/", middleware.AuthMiddleware(), deliveryHandler.CreateDelivery)
-	deliveries.Get("/", middleware.AuthMiddleware(), deliveryHandler.GetDeliveries)
-	deliveries.Get("/:id", middleware.AuthMiddleware(), deliveryHandler.GetDelivery)
-	deliveries.Put("/:id/status", middleware.AuthMiddleware(), deliveryHandler.UpdateDeliveryStatus)
-	deliveries.Post("/:id/assign", middleware.AuthMiddleware(), deliveryHandler.AssignRider)
-	// Rider-specific routes
-	deliveries.Get("/available", middleware.AuthMiddleware(), deliveryHandler.GetAvailableDeliveries)
-	deliveries.Get("/rider/my-deliveries", middleware.AuthMiddleware(), deliveryHandler.GetRiderDeliveries)
-	deliveries.Post("/:id/claim", middleware.AuthMiddleware(), deliveryHandler.ClaimDelivery)
-	deliveries.Get("/rider/earnings", middleware.AuthMiddleware(), deliveryHandler.GetRiderEarnings)
+	if config.DeliveryEnabled() {
+		deliveries := api.Group("/deliveries")
+		deliveries.Post("/", middleware.AuthMiddleware(), deliveryHandler.CreateDelivery)
+		deliveries.Get("/", middleware.AuthMiddleware(), deliveryHandler.GetDeliveries)
+		deliveries.Get("/:id", middleware.AuthMiddleware(), deliveryHandler.GetDelivery)
+		deliveries.Put("/:id/status", middleware.AuthMiddleware(), deliveryHandler.UpdateDeliveryStatus)
+		deliveries.Get("/:id/trail", middleware.AuthMiddleware(), deliveryHandler.GetDeliveryTrail)
+		deliveries.Post("/:id/assign", middleware.AuthMiddleware(), deliveryHandler.AssignRider)
+		// Rider-specific routes
+		deliveries.Get("/available", middleware.AuthMiddleware(), deliveryHandler.GetAvailableDeliveries)
+		deliveries.Get("/rider/my-deliveries", middleware.AuthMiddleware(), deliveryHandler.GetRiderDeliveries)
+		deliveries.Post("/:id/claim", middleware.AuthMiddleware(), deliveryHandler.ClaimDelivery)
+		deliveries.Post("/:id/respond", middleware.AuthMiddleware(), deliveryHandler.RespondToAssignment)
+		deliveries.Get("/rider/earnings", middleware.AuthMiddleware(), deliveryHandler.GetRiderEarnings)
+		admin.Get("/deliveries", middleware.AuthMiddleware(), middleware.AdminMiddleware(), deliveryHandler.AdminListDeliveries)
+	}
+
+	// API capabilities (public, cacheable)
+	api.Get("/meta", metaHandler.GetMeta)
+
+	// Platform fee preview (public)
+	api.Get("/fees/quote", feeHandler.GetFeeQuote)
+
+	// Department list for signup and profile dropdowns (public)
+	api.Get("/departments", userHandler.GetDepartments)
+
+	// Meetup spot routes (public)
+	api.Get("/meetup-spots", meetupHandler.GetMeetupSpots)
 
 	// AI Features routes
-	ai := api.Group("/ai")
-	ai.Get("/proximity", middleware.AuthMiddleware(), aiFeaturesHandler.GetProximity)
-	ai.Get("/response-metrics", middleware.AuthMiddleware(), aiFeaturesHandler.GetResponseMetrics)
-	ai.Get("/profile-analysis", middleware.AuthMiddleware(), aiFeaturesHandler.GetProfileAnalysis)
-	ai.Get("/profile-analysis/all", middleware.AuthMiddleware(), aiFeaturesHandler.AnalyzeAllProfiles)
-	ai.Get("/counterfeit/:id", aiFeaturesHandler.GetCounterfeitReport)
+	if config.AIFeaturesEnabled() {
+		ai := api.Group("/ai")
+		ai.Get("/proximity", middleware.AuthMiddleware(), aiFeaturesHandler.GetProximity)
+		ai.Get("/response-metrics", middleware.AuthMiddleware(), aiFeaturesHandler.GetResponseMetrics)
+		ai.Get("/profile-analysis", middleware.AuthMiddleware(), aiFeaturesHandler.GetProfileAnalysis)
+		ai.Get("/profile-analysis/all", middleware.AuthMiddleware(), aiFeaturesHandler.AnalyzeAllProfiles)
+		ai.Get("/profile-analysis/all/status", middleware.AuthMiddleware(), aiFeaturesHandler.GetProfileAnalysisJob)
+		ai.Get("/counterfeit/:id", middleware.AuthMiddleware(), aiFeaturesHandler.GetCounterfeitReport)
+	}
 
 	// Get port from environment or use default
 	port := os.Getenv("PORT")
@@ -236,6 +357,14 @@ func main() {
 	// Start server
 	// Start background trade timeout scheduler
 	services.StartTradeTimeoutScheduler(database.DB)
+	services.StartVacationScheduler(database.DB)
+	services.StartPremiumExpiryScheduler(database.DB)
+	if config.DeliveryEnabled() {
+		services.StartRiderTrailPruner(database.DB)
+		services.StartDeliveryAssignmentExpiry(database.DB)
+		services.StartStaleDeliveryMonitor(database.DB)
+	}
+	services.StartUploadCleanupJob(database.DB, storage.Default())
 	log.Printf("Starting Clovia server on port %s", port)
 	log.Fatal(app.Listen(":" + port))
 }
@@ -17,6 +17,14 @@ func AdminMiddleware() fiber.Handler {
 			})
 		}
 
+		// Impersonation tokens act as the target user and never carry admin rights
+		if _, impersonating := GetImpersonatorFromContext(c); impersonating {
+			return c.Status(403).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Admin routes are not available while impersonating",
+			})
+		}
+
 		// Check if user is admin
 		var role string
 		err := database.DB.QueryRow("SELECT role FROM users WHERE id = ?", userID).Scan(&role)
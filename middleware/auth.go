@@ -4,6 +4,7 @@ import (
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/xashathebest/clovia/utils"
 )
 
@@ -56,10 +57,24 @@ func AuthMiddleware() fiber.Handler {
 			})
 		}
 
+		if tokenRevoked(int(userID), claims) {
+			return c.Status(401).JSON(fiber.Map{
+				"success": false,
+				"error":   "Token has been revoked",
+			})
+		}
+
 		// Store user information in context for later use
 		c.Locals("user_id", int(userID))
 		c.Locals("user_email", email)
 
+		if !applyImpersonation(c, claims) {
+			return c.Status(403).JSON(fiber.Map{
+				"success": false,
+				"error":   "Impersonation sessions are read-only",
+			})
+		}
+
 		return c.Next()
 	}
 }
@@ -98,10 +113,22 @@ func OptionalAuthMiddleware() fiber.Handler {
 			return c.Next()
 		}
 
+		// A revoked token is treated like no token at all
+		if tokenRevoked(int(userID), claims) {
+			return c.Next()
+		}
+
 		// Store user information in context for later use
 		c.Locals("user_id", int(userID))
 		c.Locals("user_email", email)
 
+		if !applyImpersonation(c, claims) {
+			return c.Status(403).JSON(fiber.Map{
+				"success": false,
+				"error":   "Impersonation sessions are read-only",
+			})
+		}
+
 		return c.Next()
 	}
 }
@@ -117,3 +144,25 @@ func GetUserEmailFromContext(c *fiber.Ctx) (string, bool) {
 	email, ok := c.Locals("user_email").(string)
 	return email, ok
 }
+
+// GetImpersonatorFromContext returns the admin id when the request uses an impersonation token
+func GetImpersonatorFromContext(c *fiber.Ctx) (int, bool) {
+	adminID, ok := c.Locals("impersonated_by").(int)
+	return adminID, ok
+}
+
+// applyImpersonation marks impersonation sessions in the context and reports
+// whether the request may proceed; those tokens are read-only
+func applyImpersonation(c *fiber.Ctx, claims jwt.MapClaims) bool {
+	adminID, ok := claims["impersonated_by"].(float64)
+	if !ok {
+		return true
+	}
+	c.Locals("impersonated_by", int(adminID))
+
+	switch c.Method() {
+	case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/xashathebest/clovia/utils"
+)
+
+func TestAuthMiddlewareImpersonationIsReadOnly(t *testing.T) {
+	token, _, err := utils.GenerateImpersonationJWT(7, "user@wmsu.edu.ph", 1)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	app := fiber.New()
+	handler := func(c *fiber.Ctx) error {
+		adminID, _ := GetImpersonatorFromContext(c)
+		userID, _ := GetUserIDFromContext(c)
+		if adminID != 1 || userID != 7 {
+			return c.SendStatus(500)
+		}
+		return c.SendStatus(200)
+	}
+	app.Get("/things", AuthMiddleware(), handler)
+	app.Post("/things", AuthMiddleware(), handler)
+
+	for method, want := range map[string]int{"GET": 200, "POST": 403} {
+		req := httptest.NewRequest(method, "/things", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("%s request failed: %v", method, err)
+		}
+		if resp.StatusCode != want {
+			t.Errorf("%s: expected %d, got %d", method, want, resp.StatusCode)
+		}
+	}
+}
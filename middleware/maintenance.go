@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/xashathebest/clovia/config"
+	"github.com/xashathebest/clovia/models"
+)
+
+// defaultMaintenanceMessage is shown to clients when no custom message was set
+const defaultMaintenanceMessage = "Clovia is in read-only maintenance mode. Please try again shortly."
+
+// MaintenanceState is the current read-only mode
+type MaintenanceState struct {
+	Enabled bool   `json:"enabled"`
+	Message string `json:"message,omitempty"`
+}
+
+var maintenance = struct {
+	sync.RWMutex
+	state MaintenanceState
+}{}
+
+// maintenanceExemptPrefixes stay writable in maintenance mode so admins can sign in
+// and switch it off again
+var maintenanceExemptPrefixes = []string{"/api/admin", "/api/auth/login"}
+
+// InitMaintenanceMode sets the starting mode from MAINTENANCE_MODE and MAINTENANCE_MESSAGE
+func InitMaintenanceMode() {
+	SetMaintenanceMode(config.GetEnvBool("MAINTENANCE_MODE", false), config.GetEnv("MAINTENANCE_MESSAGE", ""))
+}
+
+// SetMaintenanceMode switches read-only mode at runtime; an empty message uses the default
+func SetMaintenanceMode(enabled bool, message string) MaintenanceState {
+	message = strings.TrimSpace(message)
+	if message == "" {
+		message = defaultMaintenanceMessage
+	}
+	maintenance.Lock()
+	defer maintenance.Unlock()
+	maintenance.state = MaintenanceState{Enabled: enabled, Message: message}
+	return maintenance.state
+}
+
+// CurrentMaintenanceMode returns the current read-only mode
+func CurrentMaintenanceMode() MaintenanceState {
+	maintenance.RLock()
+	defer maintenance.RUnlock()
+	return maintenance.state
+}
+
+// MaintenanceMiddleware rejects writes with 503 while read-only mode is on. GET, HEAD and
+// OPTIONS requests and admin routes are always served.
+func MaintenanceMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		state := CurrentMaintenanceMode()
+		if !state.Enabled {
+			return c.Next()
+		}
+		switch c.Method() {
+		case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+			return c.Next()
+		}
+		for _, prefix := range maintenanceExemptPrefixes {
+			if strings.HasPrefix(c.Path(), prefix) {
+				return c.Next()
+			}
+		}
+		c.Set(fiber.HeaderRetryAfter, "120")
+		return c.Status(fiber.StatusServiceUnavailable).JSON(models.APIResponse{
+			Success: false,
+			Error:   state.Message,
+		})
+	}
+}
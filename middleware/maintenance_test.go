@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestMaintenanceMiddleware(t *testing.T) {
+	defer SetMaintenanceMode(false, "")
+
+	app := fiber.New()
+	app.Use(MaintenanceMiddleware())
+	ok := func(c *fiber.Ctx) error { return c.SendStatus(200) }
+	app.Get("/api/products", ok)
+	app.Post("/api/products", ok)
+	app.Put("/api/admin/maintenance", ok)
+	app.Post("/api/auth/login", ok)
+
+	cases := []struct {
+		method, path string
+		enabled      bool
+		want         int
+	}{
+		{"POST", "/api/products", false, 200},
+		{"GET", "/api/products", true, 200},
+		{"POST", "/api/products", true, 503},
+		{"PUT", "/api/admin/maintenance", true, 200},
+		{"POST", "/api/auth/login", true, 200},
+	}
+	for _, tc := range cases {
+		SetMaintenanceMode(tc.enabled, "")
+		resp, err := app.Test(httptest.NewRequest(tc.method, tc.path, nil), -1)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", tc.method, tc.path, err)
+		}
+		if resp.StatusCode != tc.want {
+			t.Errorf("%s %s (maintenance=%v) = %d, want %d", tc.method, tc.path, tc.enabled, resp.StatusCode, tc.want)
+		}
+	}
+}
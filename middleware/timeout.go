@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/xashathebest/clovia/config"
+	"github.com/xashathebest/clovia/models"
+)
+
+// requestTimeoutMessage is returned when a request ran out of time
+const requestTimeoutMessage = "The server took too long to answer. Please try again."
+
+// RequestTimeoutMiddleware gives each request a context that expires after REQUEST_TIMEOUT.
+// Handlers pass c.UserContext() to QueryContext/ExecContext so a slow query is cancelled
+// and its pool connection released. A request that fails after its deadline passed is
+// answered with 503. SSE streams are long-lived by design and get no deadline.
+func RequestTimeoutMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		timeout := config.RequestTimeout()
+		if timeout <= 0 || strings.HasSuffix(c.Path(), "/stream") {
+			return c.Next()
+		}
+		ctx, cancel := context.WithTimeout(c.UserContext(), timeout)
+		defer cancel()
+		c.SetUserContext(ctx)
+
+		err := c.Next()
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && (err != nil || c.Response().StatusCode() >= 500) {
+			return c.Status(503).JSON(models.APIResponse{Success: false, Error: requestTimeoutMessage})
+		}
+		return err
+	}
+}
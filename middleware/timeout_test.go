@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRequestTimeoutMiddleware(t *testing.T) {
+	t.Setenv("REQUEST_TIMEOUT", "20ms")
+
+	app := fiber.New()
+	app.Use(RequestTimeoutMiddleware())
+	app.Get("/slow", func(c *fiber.Ctx) error {
+		// Stands in for a query run with QueryContext that is cancelled at the deadline
+		<-c.UserContext().Done()
+		return c.Status(500).JSON(fiber.Map{"error": c.UserContext().Err().Error()})
+	})
+	app.Get("/fast", func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+	app.Get("/events/stream", func(c *fiber.Ctx) error {
+		if _, ok := c.UserContext().Deadline(); ok {
+			return c.Status(500).SendString("stream got a deadline")
+		}
+		return c.SendString("ok")
+	})
+
+	cases := map[string]int{"/slow": 503, "/fast": 200, "/events/stream": 200}
+	for path, want := range cases {
+		resp, err := app.Test(httptest.NewRequest("GET", path, nil), int(time.Second/time.Millisecond))
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if resp.StatusCode != want {
+			t.Errorf("%s: status %d, want %d", path, resp.StatusCode, want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// tokenBlacklist holds, per user, the time up to which every token issued to them is
+// rejected. It mirrors the token_blacklist table, loaded once at startup, so the check
+// on each request needs no query.
+var tokenBlacklist = struct {
+	sync.RWMutex
+	revokedBefore map[int]time.Time
+}{revokedBefore: map[int]time.Time{}}
+
+// execer is satisfied by *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// LoadTokenBlacklist reads the stored revocations into memory
+func LoadTokenBlacklist(db *sql.DB) error {
+	rows, err := db.Query("SELECT user_id, revoked_before FROM token_blacklist")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var userID int
+		var before time.Time
+		if err := rows.Scan(&userID, &before); err != nil {
+			return err
+		}
+		RememberTokenRevocation(userID, before)
+	}
+	return rows.Err()
+}
+
+// SaveTokenRevocation stores that every token issued to userID up to before is void. Pass
+// the transaction that makes the change needing it, then call RememberTokenRevocation
+// once that transaction has committed.
+func SaveTokenRevocation(db execer, userID int, before time.Time) error {
+	_, err := db.Exec(`INSERT INTO token_blacklist (user_id, revoked_before) VALUES (?, ?)
+		ON DUPLICATE KEY UPDATE revoked_before = GREATEST(revoked_before, VALUES(revoked_before))`, userID, before)
+	return err
+}
+
+// RememberTokenRevocation applies a revocation to this process's auth checks
+func RememberTokenRevocation(userID int, before time.Time) {
+	tokenBlacklist.Lock()
+	defer tokenBlacklist.Unlock()
+	if before.After(tokenBlacklist.revokedBefore[userID]) {
+		tokenBlacklist.revokedBefore[userID] = before
+	}
+}
+
+// tokenRevoked reports whether a token for userID was issued before their revocation.
+// A token without an issue time cannot be told apart and counts as revoked.
+func tokenRevoked(userID int, claims jwt.MapClaims) bool {
+	tokenBlacklist.RLock()
+	before, ok := tokenBlacklist.revokedBefore[userID]
+	tokenBlacklist.RUnlock()
+	if !ok {
+		return false
+	}
+	issuedAt, err := claims.GetIssuedAt()
+	if err != nil || issuedAt == nil {
+		return true
+	}
+	return !issuedAt.After(before)
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/xashathebest/clovia/utils"
+)
+
+// TestAuthMiddlewareRejectsRevokedTokens checks that tokens issued before a revocation are
+// refused, and that optional auth then treats the caller as anonymous
+func TestAuthMiddlewareRejectsRevokedTokens(t *testing.T) {
+	const userID = 424242
+	token, err := utils.GenerateJWT(userID, "revoked@wmsu.edu.ph")
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	t.Cleanup(func() {
+		tokenBlacklist.Lock()
+		delete(tokenBlacklist.revokedBefore, userID)
+		tokenBlacklist.Unlock()
+	})
+
+	app := fiber.New()
+	app.Get("/required", AuthMiddleware(), func(c *fiber.Ctx) error { return c.SendStatus(200) })
+	app.Get("/optional", OptionalAuthMiddleware(), func(c *fiber.Ctx) error {
+		if _, ok := GetUserIDFromContext(c); ok {
+			return c.SendStatus(200)
+		}
+		return c.SendStatus(204)
+	})
+	call := func(path string) int {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("%s request failed: %v", path, err)
+		}
+		return resp.StatusCode
+	}
+
+	if got := call("/required"); got != 200 {
+		t.Fatalf("before revocation: expected 200, got %d", got)
+	}
+	RememberTokenRevocation(userID, time.Now())
+	if got := call("/required"); got != 401 {
+		t.Errorf("required auth after revocation: expected 401, got %d", got)
+	}
+	if got := call("/optional"); got != 204 {
+		t.Errorf("optional auth after revocation: expected anonymous 204, got %d", got)
+	}
+}
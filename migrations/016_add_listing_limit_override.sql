@@ -0,0 +1,4 @@
+-- Per-account override for the active listing cap (set by admins)
+-- NULL uses the configured default, 0 means unlimited
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS listing_limit_override INT NULL DEFAULT NULL COMMENT 'Admin override for max active listings';
@@ -0,0 +1,6 @@
+-- Link notifications to the entity they are about (trade, product, conversation)
+ALTER TABLE notifications
+ADD COLUMN IF NOT EXISTS reference_type VARCHAR(32) NULL DEFAULT NULL COMMENT 'Entity type the notification refers to',
+ADD COLUMN IF NOT EXISTS reference_id INT NULL DEFAULT NULL COMMENT 'ID of the referenced entity';
+
+CREATE INDEX IF NOT EXISTS idx_notifications_reference ON notifications(user_id, reference_type, reference_id);
@@ -0,0 +1,6 @@
+-- Optional public handle used for profile URLs and seller search
+-- Stored lowercase; NULL until the user picks one
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS username VARCHAR(30) NULL DEFAULT NULL COMMENT 'Public handle, lowercase';
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username ON users(username);
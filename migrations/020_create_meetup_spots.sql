@@ -0,0 +1,13 @@
+-- Public places suggested as safe meetup points for in-person trades
+-- Default rows are seeded on startup (see database/meetup_spots.go)
+CREATE TABLE IF NOT EXISTS meetup_spots (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    city VARCHAR(100) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    latitude DECIMAL(10,8) NOT NULL,
+    longitude DECIMAL(11,8) NOT NULL,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uniq_meetup_spots_city_name (city, name),
+    INDEX idx_meetup_spots_city (city)
+);
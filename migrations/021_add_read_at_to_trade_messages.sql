@@ -0,0 +1,5 @@
+-- Read receipts for trade negotiation messages
+ALTER TABLE trade_messages
+ADD COLUMN IF NOT EXISTS read_at TIMESTAMP NULL DEFAULT NULL COMMENT 'When the recipient read the message';
+
+CREATE INDEX IF NOT EXISTS idx_trade_messages_unread ON trade_messages(trade_id, read_at);
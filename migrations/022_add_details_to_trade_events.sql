@@ -0,0 +1,3 @@
+-- Structured event payloads, e.g. the before/after offer of a counter-offer
+ALTER TABLE trade_events
+ADD COLUMN IF NOT EXISTS details JSON NULL COMMENT 'Structured event details (offer snapshots)';
@@ -0,0 +1,8 @@
+-- Platform fees on order transactions and cash components of trades
+ALTER TABLE transactions
+ADD COLUMN IF NOT EXISTS fee DECIMAL(10,2) NOT NULL DEFAULT 0 COMMENT 'Platform fee withheld',
+ADD COLUMN IF NOT EXISTS net_amount DECIMAL(10,2) NULL COMMENT 'Amount after platform fee';
+
+ALTER TABLE trades
+ADD COLUMN IF NOT EXISTS platform_fee DECIMAL(10,2) NULL COMMENT 'Platform fee on offered cash',
+ADD COLUMN IF NOT EXISTS net_amount DECIMAL(10,2) NULL COMMENT 'Offered cash after platform fee';
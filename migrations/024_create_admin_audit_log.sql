@@ -0,0 +1,14 @@
+-- Audit trail of admin actions such as read-only user impersonation
+CREATE TABLE IF NOT EXISTS admin_audit_log (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    admin_id INT NOT NULL,
+    action VARCHAR(64) NOT NULL,
+    target_user_id INT NULL,
+    details JSON NULL,
+    ip_address VARCHAR(64) NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (admin_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (target_user_id) REFERENCES users(id) ON DELETE SET NULL,
+    INDEX idx_admin_audit_admin (admin_id, created_at),
+    INDEX idx_admin_audit_target (target_user_id, created_at)
+);
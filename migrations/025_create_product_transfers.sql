@@ -0,0 +1,18 @@
+-- Owner-initiated listing transfers; the recipient confirms with the token before seller_id changes
+CREATE TABLE IF NOT EXISTS product_transfers (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    product_id INT NOT NULL,
+    from_user_id INT NOT NULL,
+    to_user_id INT NOT NULL,
+    token VARCHAR(64) NOT NULL,
+    status ENUM('pending','accepted','declined','cancelled','expired') NOT NULL DEFAULT 'pending',
+    expires_at TIMESTAMP NOT NULL,
+    responded_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uniq_product_transfers_token (token),
+    FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE,
+    FOREIGN KEY (from_user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (to_user_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_product_transfers_product (product_id, status),
+    INDEX idx_product_transfers_to_user (to_user_id, status)
+);
@@ -0,0 +1,5 @@
+-- Soft-deletion of comments by moderators; removed comments stay visible to admins
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP NULL;
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS deleted_by INT NULL;
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS moderation_reason VARCHAR(255) NULL;
+CREATE INDEX IF NOT EXISTS idx_comments_product_deleted ON comments (product_id, deleted_at);
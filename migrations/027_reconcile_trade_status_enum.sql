@@ -0,0 +1,3 @@
+-- Allow every status the handlers and the timeout scheduler write
+ALTER TABLE trades
+  MODIFY status ENUM('pending','accepted','declined','countered','active','awaiting_confirmation','completed','auto_completed','cancelled') DEFAULT 'pending';
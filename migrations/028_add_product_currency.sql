@@ -0,0 +1,3 @@
+-- Explicit currency per listing; existing prices are PHP
+ALTER TABLE products
+  ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'PHP';
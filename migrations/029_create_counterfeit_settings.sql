@@ -0,0 +1,8 @@
+-- Admin-tuned counterfeit detection thresholds (single row, id = 1)
+CREATE TABLE IF NOT EXISTS counterfeit_settings (
+  id TINYINT PRIMARY KEY,
+  settings JSON NOT NULL,
+  updated_by INT NULL,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  FOREIGN KEY (updated_by) REFERENCES users(id) ON DELETE SET NULL
+);
@@ -0,0 +1,3 @@
+-- When a user's last live connection closed
+ALTER TABLE users
+  ADD COLUMN IF NOT EXISTS last_seen_at TIMESTAMP NULL;
@@ -0,0 +1,3 @@
+-- Opt-in: create a pending delivery when one of the user's trades completes
+ALTER TABLE users
+  ADD COLUMN IF NOT EXISTS auto_arrange_delivery BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,4 @@
+-- Outcome of turning a listing's location into coordinates: ok, failed or skipped.
+-- NULL for listings created before it was tracked.
+ALTER TABLE products
+  ADD COLUMN IF NOT EXISTS geocode_status VARCHAR(16) NULL;
@@ -0,0 +1,8 @@
+-- Near-duplicate listing detection: uploaded image hashes and the earlier listing a new one matched
+ALTER TABLE products
+ADD COLUMN IF NOT EXISTS image_hashes JSON DEFAULT NULL COMMENT 'SHA-256 of each uploaded image',
+ADD COLUMN IF NOT EXISTS duplicate_of_product_id INT NULL DEFAULT NULL COMMENT 'Earlier listing by the same seller this one duplicates',
+ADD COLUMN IF NOT EXISTS duplicate_similarity DECIMAL(5,4) DEFAULT NULL COMMENT 'Similarity to duplicate_of_product_id (0.0 to 1.0)',
+ADD COLUMN IF NOT EXISTS duplicate_flags JSON DEFAULT NULL COMMENT 'Why the listing was flagged as a duplicate';
+
+CREATE INDEX IF NOT EXISTS idx_products_duplicate_of ON products(duplicate_of_product_id);
@@ -0,0 +1,9 @@
+-- Conversations a participant silenced notifications for
+CREATE TABLE IF NOT EXISTS conversation_mutes (
+  conversation_id INT NOT NULL,
+  user_id INT NOT NULL,
+  muted_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (conversation_id, user_id),
+  FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
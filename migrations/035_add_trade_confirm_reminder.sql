@@ -0,0 +1,2 @@
+-- When the halfway reminder to confirm a one-sided trade completion was sent
+ALTER TABLE trades ADD COLUMN IF NOT EXISTS confirm_reminder_sent_at DATETIME NULL;
@@ -0,0 +1,2 @@
+-- Seller vacation mode: listings are hidden and new trades/orders refused until this time
+ALTER TABLE users ADD COLUMN IF NOT EXISTS vacation_until DATETIME NULL;
@@ -0,0 +1,10 @@
+-- Product details captured when they enter a trade, so later listing edits don't rewrite the offer
+ALTER TABLE trades
+  ADD COLUMN IF NOT EXISTS target_snapshot_title VARCHAR(255) NULL,
+  ADD COLUMN IF NOT EXISTS target_snapshot_price DECIMAL(10,2) NULL,
+  ADD COLUMN IF NOT EXISTS target_snapshot_image_url VARCHAR(500) NULL;
+
+ALTER TABLE trade_items
+  ADD COLUMN IF NOT EXISTS snapshot_title VARCHAR(255) NULL,
+  ADD COLUMN IF NOT EXISTS snapshot_price DECIMAL(10,2) NULL,
+  ADD COLUMN IF NOT EXISTS snapshot_image_url VARCHAR(500) NULL;
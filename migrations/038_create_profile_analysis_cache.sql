@@ -0,0 +1,7 @@
+-- Last profile analysis per user, served until it is older than PROFILE_ANALYSIS_TTL
+CREATE TABLE IF NOT EXISTS profile_analysis_cache (
+  user_id INT PRIMARY KEY,
+  analysis JSON NOT NULL,
+  computed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,4 @@
+-- Drafts are saved with publish=false and stay out of every public feed until
+-- POST /api/products/:id/publish takes them live.
+ALTER TABLE products
+  MODIFY status ENUM('available', 'sold', 'traded', 'locked', 'draft') DEFAULT 'available';
@@ -0,0 +1,11 @@
+-- Breadcrumbs riders report through UpdateDeliveryStatus, pruned after RIDER_TRAIL_RETENTION
+CREATE TABLE IF NOT EXISTS rider_location_history (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  delivery_id INT NOT NULL,
+  rider_id INT NOT NULL,
+  latitude DECIMAL(10,8) NOT NULL,
+  longitude DECIMAL(11,8) NOT NULL,
+  recorded_at TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  FOREIGN KEY (delivery_id) REFERENCES deliveries(id) ON DELETE CASCADE,
+  INDEX idx_rider_location_delivery (delivery_id, recorded_at)
+);
@@ -0,0 +1,7 @@
+-- A trade can have at most one delivery that hasn't been cancelled. active_trade_id
+-- mirrors trade_id while the delivery is live and is NULL once it is cancelled, so the
+-- unique index ignores cancelled deliveries and allows a replacement.
+ALTER TABLE deliveries
+  ADD COLUMN IF NOT EXISTS active_trade_id INT AS (IF(status = 'cancelled', NULL, trade_id)) STORED;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_deliveries_active_trade ON deliveries(active_trade_id);
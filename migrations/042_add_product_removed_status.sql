@@ -0,0 +1,4 @@
+-- 'removed' marks a listing an admin took down through POST /api/admin/products/:id/takedown.
+-- It is kept for the record but answers 410 to everyone except admins.
+ALTER TABLE products
+  MODIFY status ENUM('available', 'sold', 'traded', 'locked', 'draft', 'removed') DEFAULT 'available';
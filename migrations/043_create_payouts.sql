@@ -0,0 +1,18 @@
+-- Seller withdrawal requests; see services.SellerPayoutBalance for the balance they draw on
+CREATE TABLE IF NOT EXISTS payouts (
+  id INT AUTO_INCREMENT PRIMARY KEY,
+  user_id INT NOT NULL,
+  amount DECIMAL(10,2) NOT NULL,
+  status ENUM('pending', 'approved', 'paid', 'rejected') NOT NULL DEFAULT 'pending',
+  note VARCHAR(255) NULL,
+  admin_note VARCHAR(255) NULL,
+  reviewed_by INT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  approved_at TIMESTAMP NULL,
+  paid_at TIMESTAMP NULL,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY (reviewed_by) REFERENCES users(id) ON DELETE SET NULL,
+  INDEX idx_payouts_user (user_id, created_at),
+  INDEX idx_payouts_status (status)
+);
@@ -0,0 +1,11 @@
+-- Watchers told that a wishlisted or saved product is available again
+CREATE TABLE IF NOT EXISTS back_in_stock_alerts (
+  id INT AUTO_INCREMENT PRIMARY KEY,
+  user_id INT NOT NULL,
+  product_id INT NOT NULL,
+  alerted_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE,
+  UNIQUE KEY uniq_back_in_stock (user_id, product_id),
+  INDEX idx_back_in_stock_user (user_id, alerted_at)
+);
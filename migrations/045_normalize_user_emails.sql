@@ -0,0 +1,33 @@
+-- Emails are stored trimmed and lowercased. Accounts whose email only differed in case
+-- from an older account keep a placeholder address and are reported in
+-- email_normalization_conflicts for an admin to merge or follow up.
+CREATE TABLE IF NOT EXISTS email_normalization_conflicts (
+  user_id INT PRIMARY KEY,
+  original_email VARCHAR(255) NOT NULL,
+  normalized_email VARCHAR(255) NOT NULL,
+  kept_user_id INT NOT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT IGNORE INTO email_normalization_conflicts (user_id, original_email, normalized_email, kept_user_id)
+SELECT u.id, u.email, k.normalized, k.kept
+FROM users u
+JOIN (
+  SELECT LOWER(TRIM(email)) AS normalized, MIN(id) AS kept
+  FROM users GROUP BY LOWER(TRIM(email)) HAVING COUNT(*) > 1
+) k ON LOWER(TRIM(u.email)) = k.normalized
+WHERE u.id <> k.kept;
+
+UPDATE users u JOIN email_normalization_conflicts c ON c.user_id = u.id
+SET u.email = CONCAT('duplicate-', u.id, '+', c.normalized_email)
+WHERE u.email = c.original_email;
+
+UPDATE users SET email = LOWER(TRIM(email)) WHERE BINARY email <> BINARY LOWER(TRIM(email));
+
+-- Report what was set aside
+SELECT user_id, original_email, kept_user_id FROM email_normalization_conflicts ORDER BY kept_user_id, user_id;
+
+ALTER TABLE users
+  ADD COLUMN IF NOT EXISTS email_normalized VARCHAR(255) AS (LOWER(TRIM(email))) STORED;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_normalized ON users(email_normalized);
@@ -0,0 +1,11 @@
+-- Denormalized count of wishlist entries so product reads and the popular sort skip a
+-- COUNT(*) per row. The wishlist handlers adjust it in the same transaction as the entry.
+ALTER TABLE products ADD COLUMN IF NOT EXISTS wishlist_count INT NOT NULL DEFAULT 0;
+
+-- One-time backfill
+UPDATE products p
+LEFT JOIN (SELECT product_id, COUNT(*) AS n FROM wishlists GROUP BY product_id) w ON w.product_id = p.id
+SET p.wishlist_count = COALESCE(w.n, 0)
+WHERE p.wishlist_count <> COALESCE(w.n, 0);
+
+CREATE INDEX IF NOT EXISTS idx_products_wishlist_count ON products(status, wishlist_count);
@@ -0,0 +1,14 @@
+-- Admin broadcasts, kept so users who were offline can still read them
+CREATE TABLE IF NOT EXISTS announcements (
+  id INT AUTO_INCREMENT PRIMARY KEY,
+  title VARCHAR(120) NOT NULL,
+  message VARCHAR(500) NOT NULL,
+  critical BOOLEAN NOT NULL DEFAULT FALSE,
+  created_by INT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE SET NULL,
+  INDEX idx_announcements_created (created_at)
+);
+
+-- Opt-out of non-critical announcements
+ALTER TABLE users ADD COLUMN IF NOT EXISTS mute_announcements BOOLEAN NOT NULL DEFAULT FALSE;
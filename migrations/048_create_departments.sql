@@ -0,0 +1,60 @@
+-- Official list of WMSU colleges. Signup and profile updates only accept these, and
+-- users.department keeps the chosen code next to department_id.
+CREATE TABLE IF NOT EXISTS departments (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    code VARCHAR(20) NOT NULL UNIQUE,
+    name VARCHAR(150) NOT NULL UNIQUE,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+INSERT IGNORE INTO departments (code, name) VALUES
+    ('CA', 'College of Agriculture'),
+    ('CAIS', 'College of Asian and Islamic Studies'),
+    ('CARCH', 'College of Architecture'),
+    ('CCJE', 'College of Criminal Justice Education'),
+    ('CCS', 'College of Computing Studies'),
+    ('COE', 'College of Engineering'),
+    ('CFES', 'College of Forestry and Environmental Studies'),
+    ('CHE', 'College of Home Economics'),
+    ('CL', 'College of Law'),
+    ('CLA', 'College of Liberal Arts'),
+    ('CM', 'College of Medicine'),
+    ('CN', 'College of Nursing'),
+    ('CPADS', 'College of Public Administration and Development Studies'),
+    ('CSM', 'College of Science and Mathematics'),
+    ('CSSPE', 'College of Sports Science and Physical Education'),
+    ('CSWCD', 'College of Social Work and Community Development'),
+    ('CTE', 'College of Teacher Education');
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS department_id INT NULL;
+CREATE INDEX IF NOT EXISTS idx_users_department_id ON users(department_id);
+
+-- Map free-text values that spell out a code or a college name
+UPDATE users u JOIN departments d
+    ON LOWER(TRIM(u.department)) IN (LOWER(d.code), LOWER(d.name))
+SET u.department_id = d.id, u.department = d.code
+WHERE u.department_id IS NULL;
+
+-- Common informal spellings. The server applies a longer alias list at startup
+-- (database/departments.go); whatever still matches nothing is left for an admin.
+UPDATE users u JOIN departments d ON d.code = CASE
+        WHEN LOWER(TRIM(u.department)) IN ('cs', 'comp sci', 'computer science', 'college of computing', 'computing studies', 'bscs', 'bsit', 'it') THEN 'CCS'
+        WHEN LOWER(TRIM(u.department)) IN ('engineering', 'engg', 'cet') THEN 'COE'
+        WHEN LOWER(TRIM(u.department)) IN ('education', 'educ', 'teacher education', 'ced') THEN 'CTE'
+        WHEN LOWER(TRIM(u.department)) IN ('nursing', 'bsn') THEN 'CN'
+        WHEN LOWER(TRIM(u.department)) IN ('criminology', 'criminal justice', 'crim') THEN 'CCJE'
+        WHEN LOWER(TRIM(u.department)) IN ('liberal arts', 'arts') THEN 'CLA'
+        WHEN LOWER(TRIM(u.department)) IN ('science and mathematics', 'math', 'mathematics') THEN 'CSM'
+        WHEN LOWER(TRIM(u.department)) IN ('architecture', 'archi') THEN 'CARCH'
+        WHEN LOWER(TRIM(u.department)) IN ('agriculture', 'agri') THEN 'CA'
+        WHEN LOWER(TRIM(u.department)) IN ('home economics', 'home econ') THEN 'CHE'
+        WHEN LOWER(TRIM(u.department)) IN ('law') THEN 'CL'
+        WHEN LOWER(TRIM(u.department)) IN ('medicine', 'med') THEN 'CM'
+        WHEN LOWER(TRIM(u.department)) IN ('social work', 'community development') THEN 'CSWCD'
+        WHEN LOWER(TRIM(u.department)) IN ('public administration', 'pub ad', 'pubad') THEN 'CPADS'
+        WHEN LOWER(TRIM(u.department)) IN ('forestry', 'environmental studies') THEN 'CFES'
+        WHEN LOWER(TRIM(u.department)) IN ('physical education', 'pe', 'sports science', 'cpers') THEN 'CSSPE'
+        WHEN LOWER(TRIM(u.department)) IN ('islamic studies', 'asian and islamic studies') THEN 'CAIS'
+    END
+SET u.department_id = d.id, u.department = d.code
+WHERE u.department_id IS NULL;
@@ -0,0 +1,10 @@
+-- Slugs a product had before its seller regenerated it. GetProduct resolves them to the
+-- product so shared links keep working, and new slugs never reuse them.
+CREATE TABLE IF NOT EXISTS product_slug_history (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    product_id INT NOT NULL,
+    slug VARCHAR(255) NOT NULL UNIQUE,
+    retired_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE,
+    INDEX idx_product_slug_history_product (product_id)
+);
@@ -0,0 +1,25 @@
+-- Cash price negotiation on buyable listings, separate from barter trades. amount is the
+-- latest proposal and proposed_by says whose it is; the other party accepts, declines or
+-- counters. An accepted offer is turned into an order at that price.
+CREATE TABLE IF NOT EXISTS price_offers (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    product_id INT NOT NULL,
+    buyer_id INT NOT NULL,
+    seller_id INT NOT NULL,
+    amount DECIMAL(10,2) NOT NULL,
+    proposed_by ENUM('buyer', 'seller') NOT NULL DEFAULT 'buyer',
+    status ENUM('pending', 'accepted', 'declined', 'withdrawn', 'ordered', 'closed') NOT NULL DEFAULT 'pending',
+    message VARCHAR(500) NULL,
+    order_id INT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE,
+    FOREIGN KEY (buyer_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (seller_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE SET NULL,
+    INDEX idx_price_offers_product (product_id, status),
+    INDEX idx_price_offers_buyer (buyer_id, status)
+);
+
+-- Price agreed through an offer; transactions use it instead of the list price
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS agreed_price DECIMAL(10,2) NULL;
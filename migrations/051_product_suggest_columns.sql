@@ -0,0 +1,10 @@
+-- Lowercased title, category and location for search-box suggestions. Suggestions use a
+-- prefix LIKE ('q%') on these columns so the indexes below can serve it.
+ALTER TABLE products
+  ADD COLUMN IF NOT EXISTS title_normalized VARCHAR(255) AS (LOWER(TRIM(title))) STORED,
+  ADD COLUMN IF NOT EXISTS category_normalized VARCHAR(100) AS (LOWER(TRIM(category))) STORED,
+  ADD COLUMN IF NOT EXISTS location_normalized VARCHAR(255) AS (LOWER(TRIM(location))) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_products_title_normalized ON products(title_normalized);
+CREATE INDEX IF NOT EXISTS idx_products_category_normalized ON products(category_normalized);
+CREATE INDEX IF NOT EXISTS idx_products_location_normalized ON products(location_normalized);
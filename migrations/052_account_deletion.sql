@@ -0,0 +1,13 @@
+-- Self-service account deletion. Tokens issued to a user up to revoked_before are
+-- rejected; there is no foreign key so the entry outlives a hard-deleted user.
+CREATE TABLE IF NOT EXISTS token_blacklist (
+    user_id INT PRIMARY KEY,
+    revoked_before TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Previously added on first upload; anonymization blanks them
+ALTER TABLE users
+  ADD COLUMN IF NOT EXISTS profile_picture VARCHAR(255) NULL,
+  ADD COLUMN IF NOT EXISTS background_image VARCHAR(255) NULL,
+  ADD COLUMN IF NOT EXISTS background_position VARCHAR(50) NULL;
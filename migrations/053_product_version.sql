@@ -0,0 +1,7 @@
+-- Optimistic concurrency for listing edits. Migration 005 added the column on some
+-- databases; this makes sure every database has it and that it is never NULL.
+ALTER TABLE products ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;
+
+UPDATE products SET version = 1 WHERE version IS NULL;
+
+ALTER TABLE products MODIFY version INT NOT NULL DEFAULT 1;
@@ -0,0 +1,4 @@
+-- saved_products marks a removed save with deleted_at; NULL means still saved. Rows written
+-- under non-strict SQL mode may hold the zero date instead, which strict mode rejects as a
+-- literal, so they are matched by range: no real TIMESTAMP is before 1970-01-01 00:00:01.
+UPDATE saved_products SET deleted_at = NULL WHERE deleted_at < '1970-01-02';
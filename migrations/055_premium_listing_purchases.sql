@@ -0,0 +1,2 @@
+-- Premium placement is bought per window; amount is what was paid for it, in PHP
+ALTER TABLE premium_listings ADD COLUMN IF NOT EXISTS amount DECIMAL(10,2) NOT NULL DEFAULT 0;
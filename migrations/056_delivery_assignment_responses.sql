@@ -0,0 +1,4 @@
+-- Assigned riders accept or turn down deliveries. While assignment_expires_at is set the
+-- assignment awaits the rider; reject_count tallies refusals and lapsed assignments.
+ALTER TABLE deliveries ADD COLUMN IF NOT EXISTS assignment_expires_at TIMESTAMP NULL;
+ALTER TABLE riders ADD COLUMN IF NOT EXISTS reject_count INT NOT NULL DEFAULT 0;
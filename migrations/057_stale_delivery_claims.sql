@@ -0,0 +1,16 @@
+-- Claimed deliveries that never reached pickup in time. stale_flagged_at is when the
+-- monitor flagged the delivery; delivery_stale_events keeps every flag and whether the
+-- rider was released from it.
+ALTER TABLE deliveries ADD COLUMN IF NOT EXISTS stale_flagged_at TIMESTAMP NULL;
+
+CREATE TABLE IF NOT EXISTS delivery_stale_events (
+  id INT AUTO_INCREMENT PRIMARY KEY,
+  delivery_id INT NOT NULL,
+  rider_id INT NULL,
+  claimed_at TIMESTAMP NULL,
+  released BOOLEAN NOT NULL DEFAULT FALSE,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (delivery_id) REFERENCES deliveries(id) ON DELETE CASCADE,
+  FOREIGN KEY (rider_id) REFERENCES riders(id) ON DELETE SET NULL,
+  INDEX idx_delivery_stale_events_delivery (delivery_id, created_at)
+);
@@ -0,0 +1,26 @@
+-- Deleting a listing used to cascade into its trades and take the product snapshots with
+-- them. The product columns become nullable and are cleared instead, so the trade and its
+-- snapshot_* columns survive. The generated names of the old keys vary, so they are looked up.
+SET @fk = (SELECT rc.CONSTRAINT_NAME FROM information_schema.REFERENTIAL_CONSTRAINTS rc
+  JOIN information_schema.KEY_COLUMN_USAGE kcu
+    ON kcu.CONSTRAINT_SCHEMA = rc.CONSTRAINT_SCHEMA AND kcu.CONSTRAINT_NAME = rc.CONSTRAINT_NAME AND kcu.TABLE_NAME = rc.TABLE_NAME
+  WHERE rc.CONSTRAINT_SCHEMA = DATABASE() AND rc.TABLE_NAME = 'trades' AND kcu.COLUMN_NAME = 'target_product_id'
+    AND rc.REFERENCED_TABLE_NAME = 'products' AND rc.DELETE_RULE = 'CASCADE' LIMIT 1);
+SET @sql = IF(@fk IS NULL, 'DO 0', CONCAT('ALTER TABLE trades DROP FOREIGN KEY `', @fk, '`'));
+PREPARE stmt FROM @sql; EXECUTE stmt; DEALLOCATE PREPARE stmt;
+
+SET @fk = (SELECT rc.CONSTRAINT_NAME FROM information_schema.REFERENTIAL_CONSTRAINTS rc
+  JOIN information_schema.KEY_COLUMN_USAGE kcu
+    ON kcu.CONSTRAINT_SCHEMA = rc.CONSTRAINT_SCHEMA AND kcu.CONSTRAINT_NAME = rc.CONSTRAINT_NAME AND kcu.TABLE_NAME = rc.TABLE_NAME
+  WHERE rc.CONSTRAINT_SCHEMA = DATABASE() AND rc.TABLE_NAME = 'trade_items' AND kcu.COLUMN_NAME = 'product_id'
+    AND rc.REFERENCED_TABLE_NAME = 'products' AND rc.DELETE_RULE = 'CASCADE' LIMIT 1);
+SET @sql = IF(@fk IS NULL, 'DO 0', CONCAT('ALTER TABLE trade_items DROP FOREIGN KEY `', @fk, '`'));
+PREPARE stmt FROM @sql; EXECUTE stmt; DEALLOCATE PREPARE stmt;
+
+ALTER TABLE trades MODIFY target_product_id INT NULL;
+ALTER TABLE trade_items MODIFY product_id INT NULL;
+
+ALTER TABLE trades
+  ADD CONSTRAINT fk_trades_target_product FOREIGN KEY (target_product_id) REFERENCES products(id) ON DELETE SET NULL;
+ALTER TABLE trade_items
+  ADD CONSTRAINT fk_trade_items_product FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE SET NULL;
@@ -0,0 +1,16 @@
+-- What sellers pay the platform out of their earnings, such as premium placement. The
+-- charges are deducted from the payout balance; there is no product foreign key so a
+-- charge outlives the listing it was for.
+CREATE TABLE IF NOT EXISTS seller_charges (
+  id INT AUTO_INCREMENT PRIMARY KEY,
+  user_id INT NOT NULL,
+  kind VARCHAR(30) NOT NULL,
+  amount DECIMAL(10,2) NOT NULL,
+  product_id INT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  INDEX idx_seller_charges_user (user_id, created_at)
+);
+
+-- The charge that paid for each premium window
+ALTER TABLE premium_listings ADD COLUMN IF NOT EXISTS charge_id INT NULL;
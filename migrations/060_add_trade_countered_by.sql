@@ -0,0 +1,3 @@
+-- Who made the latest counter-offer; only the other party may accept it. NULL means the
+-- buyer's original proposal is still on the table.
+ALTER TABLE trades ADD COLUMN IF NOT EXISTS countered_by INT NULL;
@@ -0,0 +1,16 @@
+-- Databases that had several live deliveries for one trade before migration 041 could not
+-- build idx_deliveries_active_trade. Keep the delivery furthest along (the oldest on a tie)
+-- and cancel the rest, then create the index.
+UPDATE deliveries SET status = 'cancelled'
+WHERE id IN (
+  SELECT id FROM (
+    SELECT DISTINCT d.id
+    FROM deliveries d
+    JOIN deliveries k ON k.trade_id = d.trade_id AND k.id <> d.id AND k.status <> 'cancelled'
+    WHERE d.status <> 'cancelled'
+      AND (FIELD(k.status, 'pending', 'claimed', 'picked_up', 'in_transit', 'delivered') > FIELD(d.status, 'pending', 'claimed', 'picked_up', 'in_transit', 'delivered')
+        OR (k.status = d.status AND k.id < d.id))
+  ) AS superseded
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_deliveries_active_trade ON deliveries(active_trade_id);
@@ -4,10 +4,21 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"net/mail"
 	"reflect"
+	"strings"
 	"time"
+
+	"github.com/xashathebest/clovia/config"
 )
 
+// ProductStatuses are the listing states a product moves through
+var ProductStatuses = []string{"available", "sold", "traded", "locked", "draft", "removed"}
+
+// ProductConditions are the accepted values of Product.Condition
+var ProductConditions = []string{"New", "Like-New", "Used", "Fair"}
+
 // StringArray is a custom type for scanning JSON arrays from SQL
 type StringArray []string
 
@@ -44,7 +55,8 @@ func (a *StringArray) UnmarshalJSON(data []byte) error {
 
 	// Try unmarshalling as []string first
 	var arr []string
-	if err := json.Unmarshal(data, &arr); err == nil {
+	arrErr := json.Unmarshal(data, &arr)
+	if arrErr == nil {
 		*a = StringArray(arr)
 		return nil
 	}
@@ -62,7 +74,7 @@ func (a *StringArray) UnmarshalJSON(data []byte) error {
 		return nil
 	}
 
-	return errors.New("StringArray: unsupported JSON type")
+	return fmt.Errorf("StringArray: not a JSON array of strings: %w", arrErr)
 }
 
 // MarshalJSON ensures []string is marshalled as a JSON array
@@ -95,24 +107,96 @@ func (a StringArray) Value() (driver.Value, error) {
 
 // User represents a user in the system
 type User struct {
-	ID             int       `json:"id"`
-	Name           string    `json:"name" validate:"required,min=2,max=255"`
-	Email          string    `json:"email" validate:"required,email"`
-	PasswordHash   string    `json:"-" validate:"required"`
-	Role           string    `json:"role" validate:"oneof=user admin"`
-	Verified       bool      `json:"verified"`
-	IsOrganization bool      `json:"is_organization"`
-	OrgVerified    bool      `json:"org_verified"`
-	OrgName        string    `json:"org_name,omitempty"`
-	OrgLogoURL     string    `json:"org_logo_url,omitempty"`
-	Department     string    `json:"department,omitempty"`
-	Bio            string    `json:"bio,omitempty"`
-	Badges         IntArray  `json:"badges,omitempty"`
-	ProfilePicture string    `json:"profile_picture,omitempty"`
-	Latitude       *float64  `json:"latitude,omitempty"`
-	Longitude      *float64  `json:"longitude,omitempty"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
+	ID                 int       `json:"id"`
+	Name               string    `json:"name" validate:"required,min=2,max=255"`
+	Username           string    `json:"username,omitempty"`
+	Email              string    `json:"email" validate:"required,email"`
+	PasswordHash       string    `json:"-" validate:"required"`
+	Role               string    `json:"role" validate:"oneof=user admin"`
+	Verified           bool      `json:"verified"`
+	IsOrganization     bool      `json:"is_organization"`
+	OrgVerified        bool      `json:"org_verified"`
+	OrgName            string    `json:"org_name,omitempty"`
+	OrgLogoURL         string    `json:"org_logo_url,omitempty"`
+	Department         string    `json:"department,omitempty"`
+	Bio                string    `json:"bio,omitempty"`
+	Badges             IntArray  `json:"badges,omitempty"`
+	ProfilePicture     string    `json:"profile_picture,omitempty"`
+	BackgroundImage    string    `json:"background_image,omitempty"`
+	BackgroundPosition string    `json:"background_position,omitempty"`
+	Latitude           *float64  `json:"latitude,omitempty"`
+	Longitude          *float64  `json:"longitude,omitempty"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+	// Own-profile preference: create a pending delivery when a trade completes
+	AutoArrangeDelivery *bool `json:"auto_arrange_delivery,omitempty"`
+	// Vacation mode: while set and in the future the seller's listings are hidden
+	VacationUntil *time.Time `json:"vacation_until,omitempty"`
+	AwayNotice    string     `json:"away_notice,omitempty"`
+	// Public profile summary (populated by GetUserByID)
+	Inventory       *UserInventory `json:"inventory,omitempty"`
+	CompletedTrades *int           `json:"completed_trades,omitempty"`
+	AverageRating   *float64       `json:"average_rating,omitempty"`
+}
+
+// RatingBreakdown counts the trade ratings a user received per star (1-5)
+type RatingBreakdown struct {
+	UserID  int         `json:"user_id"`
+	Total   int         `json:"total"`
+	Average *float64    `json:"average,omitempty"`
+	Counts  map[int]int `json:"counts"`
+}
+
+// UserSearchResult is the public profile card returned by user search; it omits email and role
+type UserSearchResult struct {
+	ID             int    `json:"id"`
+	Name           string `json:"name"`
+	Username       string `json:"username,omitempty"`
+	Verified       bool   `json:"verified"`
+	IsOrganization bool   `json:"is_organization"`
+	OrgVerified    bool   `json:"org_verified"`
+	OrgName        string `json:"org_name,omitempty"`
+	OrgLogoURL     string `json:"org_logo_url,omitempty"`
+	Department     string `json:"department,omitempty"`
+	ProfilePicture string `json:"profile_picture,omitempty"`
+	ActiveListings int    `json:"active_listings"`
+}
+
+// ProductSuggestion is one typeahead entry: a listing title, category or location that
+// starts with what the user typed, with how many available listings carry it
+type ProductSuggestion struct {
+	Type  string `json:"type"` // "title", "category" or "location"
+	Text  string `json:"text"`
+	Count int    `json:"count"`
+}
+
+// SellerListingSample is a small preview of one of a seller's available listings
+type SellerListingSample struct {
+	ID       int      `json:"id"`
+	Slug     string   `json:"slug,omitempty"`
+	Title    string   `json:"title"`
+	Price    *float64 `json:"price,omitempty"`
+	Currency string   `json:"currency"`
+	ImageURL string   `json:"image_url,omitempty"`
+	Category string   `json:"category,omitempty"`
+}
+
+// RecommendedSeller is a shop suggested to a buyer, with the signals that ranked it
+type RecommendedSeller struct {
+	Seller           UserSearchResult      `json:"seller"`
+	Score            float64               `json:"score"`
+	DistanceKm       *float64              `json:"distance_km,omitempty"`
+	SharedCategories []string              `json:"shared_categories,omitempty"`
+	ResponseScore    *float64              `json:"response_score,omitempty"`
+	AverageRating    *float64              `json:"average_rating,omitempty"`
+	SampleListings   []SellerListingSample `json:"sample_listings"`
+}
+
+// UserInventory summarizes a seller's listings by status
+type UserInventory struct {
+	Available int `json:"available"`
+	Sold      int `json:"sold"`
+	Traded    int `json:"traded"`
 }
 
 // UserLogin represents login credentials
@@ -134,6 +218,26 @@ type UserRegister struct {
 	Bio            string  `json:"bio"`
 }
 
+// NormalizeEmail is the form emails are stored and looked up in: trimmed and lowercased,
+// so User@WMSU.edu.ph and user@wmsu.edu.ph are the same account
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// ValidEmail reports whether email is a bare address such as name@wmsu.edu.ph, without a
+// display name or angle brackets
+func ValidEmail(email string) bool {
+	if len(email) > 255 {
+		return false
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return false
+	}
+	at := strings.LastIndex(email, "@")
+	return at > 0 && strings.Contains(email[at+1:], ".")
+}
+
 // Product represents a product listing
 type Product struct {
 	ID             int         `json:"id"`
@@ -141,12 +245,13 @@ type Product struct {
 	Title          string      `json:"title" validate:"required,min=2,max=255"`
 	Description    string      `json:"description"`
 	Price          *float64    `json:"price,omitempty"`      // Optional for barter-only items
+	Currency       string      `json:"currency"`             // ISO 4217 code; PHP unless set
 	ImageURLs      StringArray `json:"image_urls,omitempty"` // Multiple images
 	ImageURL       string      `json:"image_url,omitempty"`  // Single image for compatibility
 	SellerID       int         `json:"seller_id"`
 	SellerName     string      `json:"seller_name,omitempty"`
 	Premium        bool        `json:"premium"`
-	Status         string      `json:"status" validate:"oneof=available sold traded locked"`
+	Status         string      `json:"status" validate:"oneof=available sold traded locked draft removed"`
 	AllowBuying    bool        `json:"allow_buying"` // Whether buying is allowed
 	BarterOnly     bool        `json:"barter_only"`  // Whether it's barter only
 	Location       string      `json:"location,omitempty"`
@@ -155,17 +260,33 @@ type Product struct {
 	Category       string      `json:"category,omitempty"`
 	Latitude       *float64    `json:"latitude,omitempty"`
 	Longitude      *float64    `json:"longitude,omitempty"`
+	GeocodeStatus  string      `json:"geocode_status,omitempty"` // ok, failed or skipped; empty for older listings
 	CreatedAt      time.Time   `json:"created_at"`
 	UpdatedAt      time.Time   `json:"updated_at"`
 	BiddingType    string      `json:"bidding_type,omitempty" validate:"omitempty,oneof=none blind open"`
 	WishlistCount  int         `json:"wishlist_count,omitempty"`
+	Version        int         `json:"version,omitempty"` // send back with updates; see ProductUpdate
+	// Counterfeit signals, only populated for the seller and admins
+	CounterfeitConfidence *float64    `json:"counterfeit_confidence,omitempty"`
+	CounterfeitFlags      StringArray `json:"counterfeit_flags,omitempty"`
+	// Remaining premium placement, only populated for the seller
+	PremiumUntil            *time.Time `json:"premium_until,omitempty"`
+	PremiumSecondsRemaining int64      `json:"premium_seconds_remaining,omitempty"`
 }
 
+// Geocode statuses recorded when a listing's location is turned into coordinates
+const (
+	GeocodeOK      = "ok"
+	GeocodeFailed  = "failed"
+	GeocodeSkipped = "skipped"
+)
+
 // ProductCreate represents data for creating a product
 type ProductCreate struct {
 	Title       string      `json:"title" validate:"required,min=2,max=255"`
 	Description string      `json:"description"`
 	Price       *float64    `json:"price,omitempty"` // Optional for barter-only items
+	Currency    string      `json:"currency,omitempty"`
 	ImageURLs   StringArray `json:"image_urls,omitempty"`
 	Premium     bool        `json:"premium"`
 	AllowBuying bool        `json:"allow_buying"`
@@ -180,15 +301,53 @@ type ProductUpdate struct {
 	Title       *string      `json:"title,omitempty" validate:"omitempty,min=2,max=255"`
 	Description *string      `json:"description,omitempty"`
 	Price       *float64     `json:"price,omitempty" validate:"omitempty,gt=0"`
+	Currency    *string      `json:"currency,omitempty"`
 	ImageURLs   *StringArray `json:"image_urls,omitempty"`
 	Premium     *bool        `json:"premium,omitempty"`
-	Status      *string      `json:"status,omitempty" validate:"omitempty,oneof=available sold traded locked"`
+	Status      *string      `json:"status,omitempty" validate:"omitempty,oneof=available sold traded locked draft"`
 	AllowBuying *bool        `json:"allow_buying,omitempty"`
 	BarterOnly  *bool        `json:"barter_only,omitempty"`
 	Location    *string      `json:"location,omitempty"`
 	Condition   *string      `json:"condition,omitempty" validate:"omitempty,oneof=New Like-New Used Fair"`
 	Category    *string      `json:"category,omitempty"`
 	BiddingType *string      `json:"bidding_type,omitempty" validate:"omitempty,oneof=none blind open"`
+	// Version the client last saw; the update is refused if the listing changed since
+	Version *int `json:"version,omitempty"`
+}
+
+// ProductStatusChange represents one entry in a product's status history
+type ProductStatusChange struct {
+	ID         int       `json:"id"`
+	ProductID  int       `json:"product_id"`
+	FromStatus *string   `json:"from_status,omitempty"`
+	ToStatus   string    `json:"to_status"`
+	ActorID    *int      `json:"actor_id,omitempty"`
+	ActorName  *string   `json:"actor_name,omitempty"`
+	Reason     *string   `json:"reason,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ProductTransfer is a pending or resolved hand-over of a listing to another user.
+// Token is only exposed to the recipient, who confirms the transfer with it.
+type ProductTransfer struct {
+	ID           int        `json:"id"`
+	ProductID    int        `json:"product_id"`
+	ProductTitle string     `json:"product_title,omitempty"`
+	FromUserID   int        `json:"from_user_id"`
+	FromUserName string     `json:"from_user_name,omitempty"`
+	ToUserID     int        `json:"to_user_id"`
+	ToUserName   string     `json:"to_user_name,omitempty"`
+	Token        string     `json:"token,omitempty"`
+	Status       string     `json:"status"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	RespondedAt  *time.Time `json:"responded_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// ProductTransferCreate is the request body for starting a listing transfer
+type ProductTransferCreate struct {
+	ToUserID   int    `json:"to_user_id"`
+	ToUsername string `json:"to_username"`
 }
 
 // ProductVote represents a user's vote on a product price
@@ -202,12 +361,13 @@ type ProductVote struct {
 
 // Order represents an order
 type Order struct {
-	ID        int       `json:"id"`
-	ProductID int       `json:"product_id"`
-	BuyerID   int       `json:"buyer_id"`
-	Status    string    `json:"status" validate:"oneof=pending completed cancelled"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID          int       `json:"id"`
+	ProductID   int       `json:"product_id"`
+	BuyerID     int       `json:"buyer_id"`
+	Status      string    `json:"status" validate:"oneof=pending completed cancelled"`
+	AgreedPrice *float64  `json:"agreed_price,omitempty"` // set when bought through an accepted price offer
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
 
 	// Related data
 	Product *Product `json:"product,omitempty"`
@@ -217,6 +377,8 @@ type Order struct {
 // OrderCreate represents data for creating an order
 type OrderCreate struct {
 	ProductID int `json:"product_id" validate:"required"`
+	// PriceOfferID buys at the price agreed in an accepted offer instead of the list price
+	PriceOfferID *int `json:"price_offer_id,omitempty"`
 }
 
 // OrderUpdate represents data for updating an order
@@ -224,6 +386,22 @@ type OrderUpdate struct {
 	Status *string `json:"status,omitempty" validate:"omitempty,oneof=pending completed cancelled"`
 }
 
+// OrderStatusCounts counts a user's orders by status
+type OrderStatusCounts struct {
+	Pending   int `json:"pending"`
+	Completed int `json:"completed"`
+	Cancelled int `json:"cancelled"`
+	Total     int `json:"total"`
+}
+
+// OrderSummary is a user's order totals as buyer and seller
+type OrderSummary struct {
+	AsBuyer     OrderStatusCounts `json:"as_buyer"`
+	AsSeller    OrderStatusCounts `json:"as_seller"`
+	TotalEarned float64           `json:"total_earned"` // net of platform fees
+	TotalSpent  float64           `json:"total_spent"`
+}
+
 // Transaction represents a payment transaction
 type Transaction struct {
 	ID          int       `json:"id"`
@@ -232,6 +410,20 @@ type Transaction struct {
 	PaymentDate time.Time `json:"payment_date"`
 }
 
+// TradeStatuses lists every status a trade can hold. It must match the Trade.Status
+// validate tag and the trades.status column enum (checked at startup).
+var TradeStatuses = []string{
+	"pending",
+	"accepted",
+	"declined",
+	"countered",
+	"active",
+	"awaiting_confirmation",
+	"completed",
+	"auto_completed",
+	"cancelled",
+}
+
 // Trade represents a barter trade proposal
 type Trade struct {
 	ID              int         `json:"id"`
@@ -244,6 +436,10 @@ type Trade struct {
 	CreatedAt       time.Time   `json:"created_at"`
 	UpdatedAt       time.Time   `json:"updated_at"`
 	Items           []TradeItem `json:"items"`
+	// Items split by the side that offered them (GetTrade only)
+	BuyerItems      []TradeItem `json:"buyer_items,omitempty"`
+	SellerItems     []TradeItem `json:"seller_items,omitempty"`
+	CashOnly        bool        `json:"cash_only"` // buyer offers money and no products
 	BuyerCompleted  bool        `json:"buyer_completed"`
 	SellerCompleted bool        `json:"seller_completed"`
 	CompletedAt     *time.Time  `json:"completed_at,omitempty"`
@@ -253,7 +449,78 @@ type Trade struct {
 	AutoCompletedAt           *time.Time `json:"auto_completed_at,omitempty"`
 	BuyerName                 string     `json:"buyer_name,omitempty"`
 	SellerName                string     `json:"seller_name,omitempty"`
-	ProductTitle              string     `json:"product_title,omitempty"`
+	// Target product as it was when the trade was proposed (live values for older trades)
+	ProductTitle    string   `json:"product_title,omitempty"`
+	ProductPrice    *float64 `json:"product_price,omitempty"`
+	ProductImageURL string   `json:"product_image_url,omitempty"`
+	// Delivery arranged for this trade, if any
+	Delivery *TradeDeliverySummary `json:"delivery,omitempty"`
+	// Meetup spot closest to the midpoint between buyer and seller, if both have coordinates
+	SuggestedMeetup *MeetupSpot `json:"suggested_meetup,omitempty"`
+	// Messages from the other party the viewer hasn't read yet (trades list only)
+	UnreadCount *int `json:"unread_count,omitempty"`
+}
+
+// MeetupSpot is a public place suggested for in-person trades
+type MeetupSpot struct {
+	ID         int      `json:"id"`
+	City       string   `json:"city"`
+	Name       string   `json:"name"`
+	Latitude   float64  `json:"latitude"`
+	Longitude  float64  `json:"longitude"`
+	DistanceKm *float64 `json:"distance_km,omitempty"`
+}
+
+// TradeOfferSnapshot is what was on the table in a trade at one point of the negotiation
+type TradeOfferSnapshot struct {
+	Items []TradeSnapshotItem `json:"items"`
+	Cash  *float64            `json:"cash,omitempty"`
+}
+
+// TradeSnapshotItem is one offered product in a TradeOfferSnapshot
+type TradeSnapshotItem struct {
+	ProductID int    `json:"product_id"`
+	Title     string `json:"title"`
+	OfferedBy string `json:"offered_by"`
+}
+
+// TradeOfferChange is stored on countered trade events
+type TradeOfferChange struct {
+	// Kind is empty for a full counter and "accept_with_removal" when the seller only dropped items
+	Kind    string             `json:"kind,omitempty"`
+	Before  TradeOfferSnapshot `json:"before"`
+	After   TradeOfferSnapshot `json:"after"`
+	Balance *TradeBalance      `json:"balance,omitempty"`
+}
+
+// TradeBalance compares what is offered against the listed value of the target product
+type TradeBalance struct {
+	TargetValue  float64 `json:"target_value"`
+	OfferedValue float64 `json:"offered_value"`
+	Cash         float64 `json:"cash"`
+	CashOnly     bool    `json:"cash_only"`
+	// Balance is offered items plus cash minus the target value; negative means the offer is short
+	Balance float64 `json:"balance"`
+}
+
+// TradeOfferDiff describes how a counter-offer changed the trade
+type TradeOfferDiff struct {
+	AddedItems   []TradeSnapshotItem `json:"added_items"`
+	RemovedItems []TradeSnapshotItem `json:"removed_items"`
+	CashBefore   *float64            `json:"cash_before,omitempty"`
+	CashAfter    *float64            `json:"cash_after,omitempty"`
+	Summary      string              `json:"summary"`
+	Kind         string              `json:"kind,omitempty"`
+	Balance      *TradeBalance       `json:"balance,omitempty"`
+}
+
+// TradeDeliverySummary is the delivery linked to a trade, as shown on the trade detail
+type TradeDeliverySummary struct {
+	ID           int        `json:"id"`
+	DeliveryType string     `json:"delivery_type"`
+	Status       string     `json:"status"`
+	EstimatedETA *time.Time `json:"estimated_eta,omitempty"`
+	RiderName    *string    `json:"rider_name,omitempty"`
 }
 
 // TradeItem represents an item offered in a trade
@@ -263,26 +530,33 @@ type TradeItem struct {
 	ProductID int       `json:"product_id"`
 	OfferedBy string    `json:"offered_by" validate:"oneof=buyer seller"`
 	CreatedAt time.Time `json:"created_at"`
-	// Denormalized product details for display
-	ProductTitle    string `json:"product_title,omitempty"`
-	ProductStatus   string `json:"product_status,omitempty"`
-	ProductImageURL string `json:"product_image_url,omitempty"`
+	// Product details for display: title, price and image come from the snapshot taken when
+	// the item was offered; status is always live
+	ProductTitle    string   `json:"product_title,omitempty"`
+	ProductStatus   string   `json:"product_status,omitempty"`
+	ProductImageURL string   `json:"product_image_url,omitempty"`
+	ProductPrice    *float64 `json:"product_price,omitempty"`
+	// ProductChanged is true when the live listing no longer matches the snapshot
+	ProductChanged bool `json:"product_changed,omitempty"`
 }
 
-// TradeCreate represents payload to create a trade
+// TradeCreate represents payload to create a trade.
+// Either offered products or a positive cash amount is required; cash alone is a cash-only offer.
 type TradeCreate struct {
 	TargetProductID   int      `json:"target_product_id" validate:"required"`
-	OfferedProductIDs []int    `json:"offered_product_ids" validate:"required,min=1,dive,gt=0"`
+	OfferedProductIDs []int    `json:"offered_product_ids" validate:"required_without=OfferedCashAmount,omitempty,dive,gt=0"`
 	Message           string   `json:"message"`
-	OfferedCashAmount *float64 `json:"offered_cash_amount,omitempty"`
+	OfferedCashAmount *float64 `json:"offered_cash_amount,omitempty" validate:"omitempty,gt=0"`
 }
 
 // TradeAction represents accept/decline/counter actions
 type TradeAction struct {
-	Action                   string   `json:"action" validate:"required,oneof=accept decline counter complete cancel"`
+	Action                   string   `json:"action" validate:"required,oneof=accept accept_with_removal decline counter complete cancel"`
 	Message                  string   `json:"message,omitempty"`
 	CounterOfferedProductIDs []int    `json:"counter_offered_product_ids,omitempty"`
 	CounterOfferedCashAmount *float64 `json:"counter_offered_cash_amount,omitempty"`
+	// RemoveProductIDs are the buyer's offered items the seller drops with accept_with_removal
+	RemoveProductIDs []int `json:"remove_product_ids,omitempty"`
 }
 
 // ChatConversation represents a conversation between a buyer and seller about a product
@@ -293,6 +567,7 @@ type ChatConversation struct {
 	SellerID  int       `json:"seller_id"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+	Muted     bool      `json:"muted"` // the caller silenced notifications for this conversation
 }
 
 // ChatMessage represents a message within a conversation
@@ -311,9 +586,16 @@ type PremiumListing struct {
 	ProductID int       `json:"product_id"`
 	StartDate time.Time `json:"start_date"`
 	EndDate   time.Time `json:"end_date"`
+	Amount    float64   `json:"amount"`
+	Currency  string    `json:"currency"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// PremiumPurchase is a request to buy premium placement for a listing
+type PremiumPurchase struct {
+	Days int `json:"days"`
+}
+
 // Comment represents a comment on a product listing
 type Comment struct {
 	ID            int       `json:"id"`
@@ -323,6 +605,11 @@ type Comment struct {
 	CreatedAt     time.Time `json:"created_at"`
 	UpdatedAt     time.Time `json:"updated_at"`
 	CommenterName string    `json:"commenter_name,omitempty"`
+	// Moderation fields; removed comments are only returned to admins
+	Removed          bool       `json:"removed,omitempty"`
+	DeletedAt        *time.Time `json:"deleted_at,omitempty"`
+	DeletedBy        *int       `json:"deleted_by,omitempty"`
+	ModerationReason *string    `json:"moderation_reason,omitempty"`
 }
 
 // Wishlist represents a user's wishlist item
@@ -355,6 +642,23 @@ type PaginatedResponse struct {
 	Page       int         `json:"page"`
 	Limit      int         `json:"limit"`
 	TotalPages int         `json:"total_pages"`
+	// Facets is only populated by product search when ?facets=true
+	Facets *ProductFacets `json:"facets,omitempty"`
+	// DidYouMean is set by product search when the keyword matched nothing and the
+	// results are for this corrected keyword instead
+	DidYouMean string `json:"did_you_mean,omitempty"`
+}
+
+// FacetCount is the number of results a single facet value would yield
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// ProductFacets groups search result counts by category and by condition
+type ProductFacets struct {
+	Categories []FacetCount `json:"categories"`
+	Conditions []FacetCount `json:"conditions"`
 }
 
 // MarshalJSON ensures Data is a predictable non-null value (empty array) when nil or a typed nil slice.
@@ -378,6 +682,38 @@ func (p PaginatedResponse) MarshalJSON() ([]byte, error) {
 	return json.Marshal(a)
 }
 
+// Notification reference types tell clients which screen a notification deep-links to
+const (
+	NotificationRefTrade        = "trade"
+	NotificationRefProduct      = "product"
+	NotificationRefConversation = "conversation"
+	NotificationRefOrder        = "order"
+	NotificationRefPayout       = "payout"
+	NotificationRefAnnouncement = "announcement"
+	NotificationRefPriceOffer   = "price_offer"
+)
+
+// NotificationReferenceTypes lists every valid notification reference type
+var NotificationReferenceTypes = []string{
+	NotificationRefTrade,
+	NotificationRefProduct,
+	NotificationRefConversation,
+	NotificationRefOrder,
+	NotificationRefPayout,
+	NotificationRefAnnouncement,
+	NotificationRefPriceOffer,
+}
+
+// IsValidNotificationReferenceType reports whether t is a known reference type
+func IsValidNotificationReferenceType(t string) bool {
+	for _, v := range NotificationReferenceTypes {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
 // APIResponse represents a standard API response
 type APIResponse struct {
 	Success bool        `json:"success"`
@@ -422,36 +758,41 @@ type Rider struct {
 
 // Delivery represents a delivery request
 type Delivery struct {
-	ID                 int        `json:"id"`
-	UserID             int        `json:"user_id"`
-	TradeID            *int       `json:"trade_id,omitempty"` // Optional: can be standalone delivery
-	DeliveryType       string     `json:"delivery_type" validate:"oneof=standard express"`
-	Status             string     `json:"status" validate:"oneof=pending claimed picked_up in_transit delivered cancelled"`
-	RiderID            *int       `json:"rider_id,omitempty"`
-	PickupLatitude     *float64   `json:"pickup_latitude,omitempty"`
-	PickupLongitude    *float64   `json:"pickup_longitude,omitempty"`
-	PickupAddress      string     `json:"pickup_address"`
-	DeliveryLatitude   *float64   `json:"delivery_latitude,omitempty"`
-	DeliveryLongitude  *float64   `json:"delivery_longitude,omitempty"`
-	DeliveryAddress    string     `json:"delivery_address"`
-	SpecialInstructions string    `json:"special_instructions,omitempty"`
-	TotalCost          float64    `json:"total_cost"`
-	EstimatedETA       *time.Time `json:"estimated_eta,omitempty"`
-	ItemCount          int        `json:"item_count"` // Number of items in delivery
-	IsFragile          bool       `json:"is_fragile"`  // Flag for fragile items
-	ClaimedAt          *time.Time `json:"claimed_at,omitempty"`
-	PickedUpAt         *time.Time `json:"picked_up_at,omitempty"`
-	InTransitAt        *time.Time `json:"in_transit_at,omitempty"`
-	DeliveredAt        *time.Time `json:"delivered_at,omitempty"`
-	CreatedAt          time.Time  `json:"created_at"`
-	UpdatedAt          time.Time  `json:"updated_at"`
+	ID                  int        `json:"id"`
+	UserID              int        `json:"user_id"`
+	TradeID             *int       `json:"trade_id,omitempty"` // Optional: can be standalone delivery
+	DeliveryType        string     `json:"delivery_type" validate:"oneof=standard express"`
+	Status              string     `json:"status" validate:"oneof=pending claimed picked_up in_transit delivered cancelled"`
+	RiderID             *int       `json:"rider_id,omitempty"`
+	PickupLatitude      *float64   `json:"pickup_latitude,omitempty"`
+	PickupLongitude     *float64   `json:"pickup_longitude,omitempty"`
+	PickupAddress       string     `json:"pickup_address"`
+	DeliveryLatitude    *float64   `json:"delivery_latitude,omitempty"`
+	DeliveryLongitude   *float64   `json:"delivery_longitude,omitempty"`
+	DeliveryAddress     string     `json:"delivery_address"`
+	SpecialInstructions string     `json:"special_instructions,omitempty"`
+	TotalCost           float64    `json:"total_cost"`
+	EstimatedETA        *time.Time `json:"estimated_eta,omitempty"`
+	ItemCount           int        `json:"item_count"`         // Number of items in delivery
+	IsFragile           bool       `json:"is_fragile"`         // Flag for fragile items
+	FragileItemCount    int        `json:"fragile_item_count"` // Items individually flagged fragile
+	ClaimedAt           *time.Time `json:"claimed_at,omitempty"`
+	AssignmentExpiresAt *time.Time `json:"assignment_expires_at,omitempty"` // set while an assigned rider has yet to accept
+	StaleFlaggedAt      *time.Time `json:"stale_flagged_at,omitempty"`      // set when the claim sat too long without a pickup
+	PickedUpAt          *time.Time `json:"picked_up_at,omitempty"`
+	InTransitAt         *time.Time `json:"in_transit_at,omitempty"`
+	DeliveredAt         *time.Time `json:"delivered_at,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
 	// Denormalized fields for display
-	UserName           string    `json:"user_name,omitempty"`
-	RiderName          string    `json:"rider_name,omitempty"`
-	RiderVehicle       string    `json:"rider_vehicle,omitempty"`
-	RiderRating        *float64  `json:"rider_rating,omitempty"`
-	RiderLatitude      *float64  `json:"rider_latitude,omitempty"`
-	RiderLongitude     *float64  `json:"rider_longitude,omitempty"`
+	UserName       string   `json:"user_name,omitempty"`
+	RiderName      string   `json:"rider_name,omitempty"`
+	RiderVehicle   string   `json:"rider_vehicle,omitempty"`
+	RiderRating    *float64 `json:"rider_rating,omitempty"`
+	RiderLatitude  *float64 `json:"rider_latitude,omitempty"`
+	RiderLongitude *float64 `json:"rider_longitude,omitempty"`
+	// Products included in the delivery
+	Items []DeliveryItem `json:"items"`
 }
 
 // DeliveryItem represents an item in a delivery
@@ -466,25 +807,306 @@ type DeliveryItem struct {
 
 // DeliveryRequest represents a request to create a delivery
 type DeliveryRequest struct {
-	TradeID            *int     `json:"trade_id,omitempty"`
-	DeliveryType       string   `json:"delivery_type" validate:"required,oneof=standard express"`
-	PickupLatitude     *float64 `json:"pickup_latitude,omitempty"`
-	PickupLongitude    *float64 `json:"pickup_longitude,omitempty"`
-	PickupAddress      string   `json:"pickup_address" validate:"required"`
-	DeliveryLatitude   *float64 `json:"delivery_latitude,omitempty"`
-	DeliveryLongitude  *float64 `json:"delivery_longitude,omitempty"`
-	DeliveryAddress    string   `json:"delivery_address" validate:"required"`
-	SpecialInstructions string  `json:"special_instructions,omitempty"`
-	ProductIDs         []int    `json:"product_ids" validate:"required,min=1"` // Products to deliver
+	TradeID             *int     `json:"trade_id,omitempty"`
+	DeliveryType        string   `json:"delivery_type" validate:"required,oneof=standard express"`
+	PickupLatitude      *float64 `json:"pickup_latitude,omitempty"`
+	PickupLongitude     *float64 `json:"pickup_longitude,omitempty"`
+	PickupAddress       string   `json:"pickup_address" validate:"required"`
+	DeliveryLatitude    *float64 `json:"delivery_latitude,omitempty"`
+	DeliveryLongitude   *float64 `json:"delivery_longitude,omitempty"`
+	DeliveryAddress     string   `json:"delivery_address" validate:"required"`
+	SpecialInstructions string   `json:"special_instructions,omitempty"`
+	ProductIDs          []int    `json:"product_ids" validate:"required,min=1"` // Products to deliver
 }
 
 // DeliveryUpdate represents an update to delivery status
 type DeliveryUpdate struct {
-	Status             *string   `json:"status,omitempty" validate:"omitempty,oneof=claimed picked_up in_transit delivered cancelled"`
-	RiderID            *int      `json:"rider_id,omitempty"`
-	Latitude           *float64  `json:"latitude,omitempty"`
-	Longitude          *float64  `json:"longitude,omitempty"`
-	EstimatedETA       *time.Time `json:"estimated_eta,omitempty"`
+	Status       *string    `json:"status,omitempty" validate:"omitempty,oneof=claimed picked_up in_transit delivered cancelled"`
+	RiderID      *int       `json:"rider_id,omitempty"`
+	Latitude     *float64   `json:"latitude,omitempty"`
+	Longitude    *float64   `json:"longitude,omitempty"`
+	EstimatedETA *time.Time `json:"estimated_eta,omitempty"`
+}
+
+// DeliveryAdminList is a page of deliveries for operators, with how many deliveries
+// match the other filters in each status
+type DeliveryAdminList struct {
+	PaginatedResponse
+	StatusCounts map[string]int `json:"status_counts"`
+}
+
+// DeliveryAssignmentResponse is an assigned rider accepting or turning down a delivery
+type DeliveryAssignmentResponse struct {
+	Accept *bool `json:"accept"`
+}
+
+// Announcement is a message from the admins to every user. Critical announcements reach
+// users who muted announcements too.
+type Announcement struct {
+	ID        int       `json:"id"`
+	Title     string    `json:"title"`
+	Message   string    `json:"message"`
+	Critical  bool      `json:"critical"`
+	CreatedBy int       `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PriceOffer is a buyer's cash offer on a buyable listing. Amount is the latest proposal;
+// ProposedBy says who made it, and the other party is the one who may answer.
+type PriceOffer struct {
+	ID            int       `json:"id"`
+	ProductID     int       `json:"product_id"`
+	ProductTitle  string    `json:"product_title,omitempty"`
+	ListPrice     *float64  `json:"list_price,omitempty"`
+	ProductStatus string    `json:"product_status,omitempty"`
+	BuyerID       int       `json:"buyer_id"`
+	BuyerName     string    `json:"buyer_name,omitempty"`
+	SellerID      int       `json:"seller_id"`
+	Amount        float64   `json:"amount"`
+	ProposedBy    string    `json:"proposed_by" validate:"oneof=buyer seller"`
+	Status        string    `json:"status" validate:"oneof=pending accepted declined withdrawn ordered closed"`
+	Message       string    `json:"message,omitempty"`
+	OrderID       *int      `json:"order_id,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// OrphanedUpload is a stored file that no listing, profile, trade or message points at
+type OrphanedUpload struct {
+	Key        string    `json:"key"`
+	Size       int64     `json:"size"`
+	ModifiedAt time.Time `json:"modified_at"`
+}
+
+// UploadCleanupReport summarizes one pass over the uploads directory. In a dry run the
+// orphans are listed but nothing is deleted, and Reclaimed* is what a real run would free.
+type UploadCleanupReport struct {
+	DryRun         bool             `json:"dry_run"`
+	MinAgeHours    float64          `json:"min_age_hours"`
+	Scanned        int              `json:"scanned"`
+	Referenced     int              `json:"referenced"`
+	TooRecent      int              `json:"too_recent"`
+	Orphaned       []OrphanedUpload `json:"orphaned"`
+	ReclaimedFiles int              `json:"reclaimed_files"`
+	ReclaimedBytes int64            `json:"reclaimed_bytes"`
+	Failed         []string         `json:"failed,omitempty"`
+}
+
+// Department is one of the official colleges a student account belongs to
+type Department struct {
+	ID   int    `json:"id"`
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+// NotificationPreferences are the user's opt-outs; conversation mutes live per conversation
+type NotificationPreferences struct {
+	Announcements bool `json:"announcements"`
+}
+
+// BackInStockAlert is a wishlisted or saved product that became available again
+type BackInStockAlert struct {
+	Product   Product   `json:"product"`
+	AlertedAt time.Time `json:"alerted_at"`
+}
+
+// Payout is a seller's request to withdraw earnings, moving pending -> approved -> paid
+// or ending rejected
+type Payout struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"user_id"`
+	UserName   string     `json:"user_name,omitempty"`
+	Amount     float64    `json:"amount"`
+	Status     string     `json:"status"`
+	Note       string     `json:"note,omitempty"`
+	AdminNote  string     `json:"admin_note,omitempty"`
+	ReviewedBy *int       `json:"reviewed_by,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ApprovedAt *time.Time `json:"approved_at,omitempty"`
+	PaidAt     *time.Time `json:"paid_at,omitempty"`
+}
+
+// PayoutBalance is how much of a seller's earnings can still be withdrawn
+type PayoutBalance struct {
+	Earnings  float64 `json:"earnings"`  // net of platform fees, from completed orders and cash trades
+	Requested float64 `json:"requested"` // pending and approved payouts not yet paid
+	Paid      float64 `json:"paid"`
+	Charged   float64 `json:"charged"` // paid to the platform out of earnings, such as premium placement
+	Available float64 `json:"available"`
+}
+
+// RiderLocationPoint is one breadcrumb a rider reported while on a delivery
+type RiderLocationPoint struct {
+	Latitude   float64   `json:"latitude"`
+	Longitude  float64   `json:"longitude"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// DeliveryTrail is the ordered breadcrumb trail of a delivery, oldest point first
+type DeliveryTrail struct {
+	DeliveryID int                  `json:"delivery_id"`
+	Status     string               `json:"status"`
+	Points     []RiderLocationPoint `json:"points"`
+}
+
+// InventoryBreakdown totals a seller's active listings for one category or condition
+type InventoryBreakdown struct {
+	Value          string  `json:"value"`
+	Count          int     `json:"count"`
+	SuggestedValue int     `json:"suggested_value"`
+	Price          float64 `json:"price"`
+}
+
+// DuplicateListing is an entry in the admin queue of listings flagged as near-duplicates
+type DuplicateListing struct {
+	ProductID         int         `json:"product_id"`
+	Title             string      `json:"title"`
+	Status            string      `json:"status"`
+	SellerID          int         `json:"seller_id"`
+	SellerName        string      `json:"seller_name"`
+	CreatedAt         time.Time   `json:"created_at"`
+	DuplicateOfID     int         `json:"duplicate_of_id"`
+	DuplicateOfTitle  string      `json:"duplicate_of_title"`
+	DuplicateOfStatus string      `json:"duplicate_of_status"`
+	Similarity        float64     `json:"similarity"`
+	Reasons           StringArray `json:"reasons"`
+}
+
+// LockedProduct is one of the caller's listings held by an open trade
+type LockedProduct struct {
+	ProductID            int         `json:"product_id"`
+	Title                string      `json:"title"`
+	Slug                 string      `json:"slug,omitempty"`
+	ImageURLs            StringArray `json:"image_urls,omitempty"`
+	TradeID              *int        `json:"trade_id,omitempty"` // nil when no open trade references the product
+	TradeStatus          string      `json:"trade_status,omitempty"`
+	CounterpartyID       *int        `json:"counterparty_id,omitempty"`
+	CounterpartyName     string      `json:"counterparty_name,omitempty"`
+	CounterpartyUsername string      `json:"counterparty_username,omitempty"`
+	TradeUpdatedAt       *time.Time  `json:"trade_updated_at,omitempty"`
+}
+
+// TradeableProduct is a listing as shown when putting a trade offer together
+type TradeableProduct struct {
+	ID              int         `json:"id"`
+	Title           string      `json:"title"`
+	Slug            string      `json:"slug,omitempty"`
+	Price           *float64    `json:"price,omitempty"`
+	ImageURLs       StringArray `json:"image_urls,omitempty"`
+	Condition       string      `json:"condition,omitempty"`
+	Category        string      `json:"category,omitempty"`
+	SuggestedValue  *int        `json:"suggested_value,omitempty"`
+	AcceptsCashOnly bool        `json:"accepts_cash_only"` // false means an offer must include a product
+}
+
+// TradeableListings is both sides of a trade offer: what the user has up for trade and,
+// for a signed-in viewer, what the viewer can offer in return
+type TradeableListings struct {
+	UserID     int                `json:"user_id"`
+	AwayUntil  *time.Time         `json:"away_until,omitempty"`
+	AwayNotice string             `json:"away_notice,omitempty"`
+	Listings   []TradeableProduct `json:"listings"`
+	Offerable  []TradeableProduct `json:"your_offerable,omitempty"`
+}
+
+// ProductComparison is one column of a side-by-side product comparison
+type ProductComparison struct {
+	ProductID         int         `json:"product_id"`
+	Title             string      `json:"title"`
+	Slug              string      `json:"slug,omitempty"`
+	Status            string      `json:"status"`
+	ImageURLs         StringArray `json:"image_urls,omitempty"`
+	Price             *float64    `json:"price,omitempty"`
+	Currency          string      `json:"currency"`
+	SuggestedValue    int         `json:"suggested_value"`
+	Condition         string      `json:"condition,omitempty"`
+	Location          string      `json:"location,omitempty"`
+	DistanceKm        *float64    `json:"distance_km,omitempty"` // nil when either side has no coordinates
+	SellerID          int         `json:"seller_id"`
+	SellerName        string      `json:"seller_name"`
+	SellerRating      *float64    `json:"seller_rating,omitempty"`
+	SellerRatingCount int         `json:"seller_rating_count"`
+	WishlistCount     int         `json:"wishlist_count"`
+	VotesUnder        int         `json:"votes_under"`
+	VotesOver         int         `json:"votes_over"`
+}
+
+// InventoryValue summarizes the appraised value of a seller's available listings
+type InventoryValue struct {
+	ActiveListings      int     `json:"active_listings"`
+	TotalSuggestedValue int     `json:"total_suggested_value"`
+	TotalPrice          float64 `json:"total_price"`
+	// TotalPriceByCurrency splits TotalPrice when listings are priced in several currencies
+	TotalPriceByCurrency map[string]float64   `json:"total_price_by_currency"`
+	ByCategory           []InventoryBreakdown `json:"by_category"`
+	ByCondition          []InventoryBreakdown `json:"by_condition"`
+	// Appraisal gaps: listings that would value more accurately once completed
+	MissingCondition   int `json:"missing_condition"`
+	MissingCategory    int `json:"missing_category"`
+	IncompleteListings int `json:"incomplete_listings"`
+}
+
+// UserPresence is whether a user has a live connection and when they last disconnected
+type UserPresence struct {
+	UserID     int        `json:"user_id"`
+	Online     bool       `json:"online"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+}
+
+// Activity feed entry types
+const (
+	ActivityOfferReceived = "offer_received"
+	ActivityOfferAccepted = "offer_accepted"
+	ActivityOfferDeclined = "offer_declined"
+	ActivityComment       = "comment"
+	ActivityWishlisted    = "wishlisted"
+	ActivityOrderPlaced   = "order_placed"
+)
+
+// ActivityEntry is one event in a user's activity feed
+type ActivityEntry struct {
+	ID           string    `json:"id"` // "<type>:<source id>", unique across the feed
+	Type         string    `json:"type"`
+	SourceID     int       `json:"source_id"`
+	ActorID      *int      `json:"actor_id,omitempty"`
+	ActorName    string    `json:"actor_name,omitempty"`
+	ProductID    *int      `json:"product_id,omitempty"`
+	ProductTitle string    `json:"product_title,omitempty"`
+	TradeID      *int      `json:"trade_id,omitempty"`
+	OrderID      *int      `json:"order_id,omitempty"`
+	Snippet      string    `json:"snippet,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// ActivityFeed is one page of the activity feed; pass NextCursor back as ?cursor= for the next page
+type ActivityFeed struct {
+	Entries    []ActivityEntry `json:"entries"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+	HasMore    bool            `json:"has_more"`
+}
+
+// DeliverySummary is an active delivery as listed on the dashboard
+type DeliverySummary struct {
+	ID           int        `json:"id"`
+	TradeID      *int       `json:"trade_id,omitempty"`
+	DeliveryType string     `json:"delivery_type"`
+	Status       string     `json:"status"`
+	ItemCount    int        `json:"item_count"`
+	RiderName    string     `json:"rider_name,omitempty"`
+	EstimatedETA *time.Time `json:"estimated_eta,omitempty"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// Dashboard is everything the home screen shows for the signed-in user
+type Dashboard struct {
+	UnreadNotifications   int               `json:"unread_notifications"`
+	IncomingPendingTrades int               `json:"incoming_pending_trades"`
+	OutgoingPendingTrades int               `json:"outgoing_pending_trades"`
+	ActiveDeliveries      []DeliverySummary `json:"active_deliveries"`
+	SavedCount            int               `json:"saved_count"`
+	WishlistCount         int               `json:"wishlist_count"`
+	RecentActivity        []ActivityEntry   `json:"recent_activity"`
+	GeneratedAt           time.Time         `json:"generated_at"`
 }
 
 // JWTClaims represents JWT token claims
@@ -494,7 +1116,23 @@ type JWTClaims struct {
 	Exp    int64  `json:"exp"`
 }
 
-// MarshalJSON ensures image_url is populated for compatibility with frontends expecting a single image.
+// PurchaseAllowed is the rule for whether a listing accepts orders. Barter-only listings
+// take trades but not orders, and allow_buying=false also rules out orders.
+func PurchaseAllowed(allowBuying, barterOnly bool) bool {
+	return allowBuying && !barterOnly
+}
+
+// TradeOnlyMessage is returned when an order or cash-only offer targets a listing that
+// does not accept purchases
+const TradeOnlyMessage = "This item is available for trade only. Propose a trade instead of placing an order"
+
+// CanBuy reports whether the listing accepts orders
+func (p Product) CanBuy() bool {
+	return PurchaseAllowed(p.AllowBuying, p.BarterOnly)
+}
+
+// MarshalJSON ensures image_url is populated for compatibility with frontends expecting a single image,
+// and adds can_buy so clients show the action buttons the listing supports.
 func (p Product) MarshalJSON() ([]byte, error) {
 	type alias Product
 	a := alias(p)
@@ -502,6 +1140,23 @@ func (p Product) MarshalJSON() ([]byte, error) {
 	if a.ImageURL == "" && len(a.ImageURLs) > 0 {
 		a.ImageURL = a.ImageURLs[0]
 	}
+	// Rows loaded without the currency column are priced in the default currency
+	if a.Currency == "" {
+		a.Currency = config.DefaultCurrency
+	}
 	// Ensure nil slice becomes empty array in JSON (optional; StringArray.MarshalJSON already handles this)
-	return json.Marshal(a)
+	return json.Marshal(struct {
+		alias
+		CanBuy bool `json:"can_buy"`
+	}{a, p.CanBuy()})
+}
+
+// BadImageProduct is a product whose stored image_urls value cannot be parsed
+type BadImageProduct struct {
+	ProductID int    `json:"product_id"`
+	Title     string `json:"title"`
+	SellerID  int    `json:"seller_id"`
+	Status    string `json:"status"`
+	Raw       string `json:"raw"`
+	Error     string `json:"error"`
 }
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestProductJSONPurchaseRules(t *testing.T) {
+	cases := []struct {
+		name                    string
+		allowBuying, barterOnly bool
+		wantCanBuy              bool
+	}{
+		{"buyable", true, false, true},
+		{"barter only", true, true, false},
+		{"buying disabled", false, false, false},
+		{"barter only and buying disabled", false, true, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := json.Marshal(Product{ID: 1, AllowBuying: tc.allowBuying, BarterOnly: tc.barterOnly})
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+			var out map[string]interface{}
+			if err := json.Unmarshal(b, &out); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			if out["allow_buying"] != tc.allowBuying || out["barter_only"] != tc.barterOnly {
+				t.Errorf("flags not returned as set: %s", b)
+			}
+			if out["can_buy"] != tc.wantCanBuy {
+				t.Errorf("can_buy = %v, want %v", out["can_buy"], tc.wantCanBuy)
+			}
+			if out["currency"] != "PHP" {
+				t.Errorf("currency = %v, want PHP default", out["currency"])
+			}
+		})
+	}
+}
+
+func TestStringArrayScan(t *testing.T) {
+	cases := []struct {
+		name    string
+		raw     string
+		want    int
+		wantErr bool
+	}{
+		{"array", `["a.jpg","b.jpg"]`, 2, false},
+		{"null", `null`, 0, false},
+		{"double encoded", `"[\"a.jpg\"]"`, 1, false},
+		{"truncated", `["a.jpg",`, 0, true},
+		{"object", `{"url":"a.jpg"}`, 0, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var a StringArray
+			err := a.Scan([]byte(tc.raw))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Scan(%s) error = %v, wantErr %v", tc.raw, err, tc.wantErr)
+			}
+			if !tc.wantErr && len(a) != tc.want {
+				t.Errorf("Scan(%s) = %v, want %d entries", tc.raw, a, tc.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeAndValidateEmail(t *testing.T) {
+	if got := NormalizeEmail("  User@WMSU.edu.ph "); got != "user@wmsu.edu.ph" {
+		t.Errorf("NormalizeEmail = %q", got)
+	}
+	for email, want := range map[string]bool{
+		"user@wmsu.edu.ph":          true,
+		"first.last+tag@gmail.com":  true,
+		"user@localhost":            false,
+		"user":                      false,
+		"@wmsu.edu.ph":              false,
+		"User <user@wmsu.edu.ph>":   false,
+		"user@wmsu.edu.ph, a@b.com": false,
+	} {
+		if got := ValidEmail(email); got != want {
+			t.Errorf("ValidEmail(%q) = %v, want %v", email, got, want)
+		}
+	}
+}
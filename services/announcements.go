@@ -0,0 +1,77 @@
+package services
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/xashathebest/clovia/models"
+)
+
+// AnnouncementBatchSize caps the rows in one multi-row notification INSERT
+const AnnouncementBatchSize = 500
+
+// deletedAccountEmailDomain is the address domain given to anonymized accounts and to the
+// placeholder account their listings move to
+const deletedAccountEmailDomain = "@clovia.invalid"
+
+// AnnouncementRecipients lists the users an announcement is delivered to. Users who muted
+// announcements only receive critical ones; see NotificationSuppressed for the other preferences.
+// Deleted accounts never receive announcements.
+func AnnouncementRecipients(db *sql.DB, critical bool) ([]int, error) {
+	rows, err := db.Query(`SELECT id FROM users
+		WHERE (? OR mute_announcements = FALSE) AND email NOT LIKE ?
+		ORDER BY id`, critical, "%"+deletedAccountEmailDomain)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// StoreAnnouncementNotifications inserts one announcement notification per recipient in
+// multi-row INSERTs of at most batchSize rows and returns how many were stored. A failed
+// batch stops the run so the caller can report how far it got.
+func StoreAnnouncementNotifications(db sqlExecer, a models.Announcement, recipients []int, batchSize int) (int, error) {
+	if batchSize <= 0 {
+		batchSize = AnnouncementBatchSize
+	}
+	message := AnnouncementNotificationText(a)
+	stored := 0
+	for start := 0; start < len(recipients); start += batchSize {
+		end := start + batchSize
+		if end > len(recipients) {
+			end = len(recipients)
+		}
+		batch := recipients[start:end]
+		rows := make([]string, len(batch))
+		args := make([]interface{}, 0, len(batch)*4)
+		for i, uid := range batch {
+			rows[i] = "(?, 'announcement', ?, FALSE, ?, ?)"
+			args = append(args, uid, message, models.NotificationRefAnnouncement, a.ID)
+		}
+		query := "INSERT INTO notifications (user_id, type, message, is_read, reference_type, reference_id) VALUES " + strings.Join(rows, ", ")
+		if _, err := db.Exec(query, args...); err != nil {
+			return stored, err
+		}
+		stored += len(batch)
+	}
+	return stored, nil
+}
+
+// AnnouncementNotificationText is the notification line for an announcement, cut to fit
+// the 500 character notifications.message column
+func AnnouncementNotificationText(a models.Announcement) string {
+	text := a.Title + ": " + a.Message
+	if r := []rune(text); len(r) > 500 {
+		text = string(r[:497]) + "..."
+	}
+	return text
+}
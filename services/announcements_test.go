@@ -0,0 +1,47 @@
+package services
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/xashathebest/clovia/models"
+)
+
+// batchRecorder captures statements instead of running them
+type batchRecorder struct {
+	queries []string
+}
+
+func (r *batchRecorder) Exec(query string, args ...interface{}) (sql.Result, error) {
+	r.queries = append(r.queries, query)
+	return nil, nil
+}
+
+func TestStoreAnnouncementNotificationsChunks(t *testing.T) {
+	recipients := make([]int, 7)
+	for i := range recipients {
+		recipients[i] = i + 1
+	}
+	db := &batchRecorder{}
+	stored, err := StoreAnnouncementNotifications(db, models.Announcement{ID: 3, Title: "Maintenance", Message: "Down at 10pm"}, recipients, 3)
+	if err != nil || stored != 7 {
+		t.Fatalf("stored %d (%v), want 7", stored, err)
+	}
+	if len(db.queries) != 3 {
+		t.Fatalf("expected 3 batches of at most 3 rows, got %d statements", len(db.queries))
+	}
+	for i, want := range []int{3, 3, 1} {
+		if got := strings.Count(db.queries[i], "(?, 'announcement'"); got != want {
+			t.Errorf("batch %d has %d rows, want %d", i, got, want)
+		}
+	}
+}
+
+func TestAnnouncementNotificationTextFitsColumn(t *testing.T) {
+	a := models.Announcement{Title: "Policy", Message: strings.Repeat("é", 600)}
+	text := AnnouncementNotificationText(a)
+	if n := len([]rune(text)); n != 500 || !strings.HasSuffix(text, "...") {
+		t.Errorf("text is %d runes, want 500 ending in ...", n)
+	}
+}
@@ -0,0 +1,20 @@
+package services
+
+import "sort"
+
+// defaultCategory is assigned when appraisal finds no category keyword
+const defaultCategory = "General"
+
+// ProductCategories lists the categories appraisal can assign, sorted, with the default last
+func ProductCategories() []string {
+	seen := map[string]bool{}
+	var categories []string
+	for _, category := range categoryKeywords {
+		if !seen[category] {
+			seen[category] = true
+			categories = append(categories, category)
+		}
+	}
+	sort.Strings(categories)
+	return append(categories, defaultCategory)
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"database/sql"
 	"math"
 	"time"
@@ -19,7 +20,7 @@ type ResponseMetrics struct {
 }
 
 // CalculateResponseMetrics calculates response metrics for a user based on their chat history
-func CalculateResponseMetrics(db *sql.DB, userID int) (ResponseMetrics, error) {
+func CalculateResponseMetrics(ctx context.Context, db *sql.DB, userID int) (ResponseMetrics, error) {
 	metrics := ResponseMetrics{
 		ResponseRate:   0.0,
 		ResponseScore:  0.0,
@@ -28,7 +29,7 @@ func CalculateResponseMetrics(db *sql.DB, userID int) (ResponseMetrics, error) {
 	}
 
 	// Get all conversations where user is a participant
-	rows, err := db.Query(`
+	rows, err := db.QueryContext(ctx, `
 		SELECT id, buyer_id, seller_id 
 		FROM conversations 
 		WHERE buyer_id = ? OR seller_id = ?
@@ -59,7 +60,7 @@ func CalculateResponseMetrics(db *sql.DB, userID int) (ResponseMetrics, error) {
 	}
 
 	// Get all messages in these conversations, ordered by conversation and time
-	messageRows, err := db.Query(`
+	messageRows, err := db.QueryContext(ctx, `
 		SELECT conversation_id, sender_id, created_at
 		FROM messages
 		WHERE conversation_id IN (
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xashathebest/clovia/config"
+)
+
+// CounterfeitSettings are the tunable thresholds used by DetectCounterfeit
+type CounterfeitSettings struct {
+	// SuspicionThreshold is the confidence at or above which a listing is flagged
+	SuspicionThreshold float64 `json:"suspicion_threshold"`
+	// BelowMedianRatio flags prices under this fraction of the category median (0.3 = 70% below)
+	BelowMedianRatio float64 `json:"below_median_ratio"`
+	// MinCategorySamples is how many priced listings a category needs before its median is trusted
+	MinCategorySamples int `json:"min_category_samples"`
+	// LuxuryPriceFloor flags luxury wording on listings priced below it
+	LuxuryPriceFloor float64 `json:"luxury_price_floor"`
+	// PromoPriceFloor flags heavy promotional wording on listings priced below it
+	PromoPriceFloor    float64  `json:"promo_price_floor"`
+	SuspiciousKeywords []string `json:"suspicious_keywords"`
+}
+
+// DefaultCounterfeitSettings reads the COUNTERFEIT_* environment variables, falling
+// back to the built-in heuristics. COUNTERFEIT_KEYWORDS is a comma-separated list.
+func DefaultCounterfeitSettings() CounterfeitSettings {
+	keywords := defaultSuspiciousKeywords
+	if raw := config.GetEnv("COUNTERFEIT_KEYWORDS", ""); raw != "" {
+		keywords = normalizeKeywords(strings.Split(raw, ","))
+	}
+	return CounterfeitSettings{
+		SuspicionThreshold: config.GetEnvFloat("COUNTERFEIT_SUSPICION_THRESHOLD", 0.3),
+		BelowMedianRatio:   config.GetEnvFloat("COUNTERFEIT_BELOW_MEDIAN_RATIO", 0.3),
+		MinCategorySamples: config.GetEnvInt("COUNTERFEIT_MIN_CATEGORY_SAMPLES", 5),
+		LuxuryPriceFloor:   config.GetEnvFloat("COUNTERFEIT_LUXURY_PRICE_FLOOR", 50),
+		PromoPriceFloor:    config.GetEnvFloat("COUNTERFEIT_PROMO_PRICE_FLOOR", 100),
+		SuspiciousKeywords: keywords,
+	}
+}
+
+// ErrInvalidCounterfeitSettings wraps every Validate failure
+var ErrInvalidCounterfeitSettings = errors.New("invalid counterfeit settings")
+
+// Normalize lower-cases and de-duplicates the keyword list
+func (s CounterfeitSettings) Normalize() CounterfeitSettings {
+	s.SuspiciousKeywords = normalizeKeywords(s.SuspiciousKeywords)
+	return s
+}
+
+// Validate checks every threshold is in range
+func (s CounterfeitSettings) Validate() error {
+	switch {
+	case s.SuspicionThreshold <= 0 || s.SuspicionThreshold > 1:
+		return fmt.Errorf("%w: suspicion_threshold must be greater than 0 and at most 1", ErrInvalidCounterfeitSettings)
+	case s.BelowMedianRatio < 0 || s.BelowMedianRatio >= 1:
+		return fmt.Errorf("%w: below_median_ratio must be at least 0 and less than 1", ErrInvalidCounterfeitSettings)
+	case s.MinCategorySamples < 1:
+		return fmt.Errorf("%w: min_category_samples must be at least 1", ErrInvalidCounterfeitSettings)
+	case s.LuxuryPriceFloor < 0 || s.PromoPriceFloor < 0:
+		return fmt.Errorf("%w: price floors must not be negative", ErrInvalidCounterfeitSettings)
+	}
+	return nil
+}
+
+func normalizeKeywords(in []string) []string {
+	seen := make(map[string]bool)
+	out := []string{}
+	for _, k := range in {
+		k = strings.ToLower(strings.TrimSpace(k))
+		if k == "" || seen[k] {
+			continue
+		}
+		seen[k] = true
+		out = append(out, k)
+	}
+	return out
+}
+
+// counterfeitSettings holds the active settings; nil until loaded or saved
+var counterfeitSettings struct {
+	sync.RWMutex
+	active *CounterfeitSettings
+}
+
+// CurrentCounterfeitSettings returns the settings in effect
+func CurrentCounterfeitSettings() CounterfeitSettings {
+	counterfeitSettings.RLock()
+	defer counterfeitSettings.RUnlock()
+	if counterfeitSettings.active == nil {
+		return DefaultCounterfeitSettings()
+	}
+	return *counterfeitSettings.active
+}
+
+func setCounterfeitSettings(s CounterfeitSettings) {
+	counterfeitSettings.Lock()
+	counterfeitSettings.active = &s
+	counterfeitSettings.Unlock()
+}
+
+// LoadCounterfeitSettings activates the settings saved by an admin, if any.
+// Without a saved row the environment defaults stay in effect.
+func LoadCounterfeitSettings(db *sql.DB) error {
+	var raw string
+	err := db.QueryRow("SELECT settings FROM counterfeit_settings WHERE id = 1").Scan(&raw)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s := DefaultCounterfeitSettings()
+	if err := json.Unmarshal([]byte(raw), &s); err != nil {
+		return err
+	}
+	s = s.Normalize()
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	setCounterfeitSettings(s)
+	return nil
+}
+
+// SaveCounterfeitSettings validates, persists and activates new settings
+func SaveCounterfeitSettings(db *sql.DB, s CounterfeitSettings, adminID int) (CounterfeitSettings, error) {
+	s = s.Normalize()
+	if err := s.Validate(); err != nil {
+		return s, err
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		return s, err
+	}
+	_, err = db.Exec(`
+		INSERT INTO counterfeit_settings (id, settings, updated_by) VALUES (1, ?, ?)
+		ON DUPLICATE KEY UPDATE settings = VALUES(settings), updated_by = VALUES(updated_by)`, string(b), adminID)
+	if err != nil {
+		return s, err
+	}
+	setCounterfeitSettings(s)
+	return s, nil
+}
+
+// CategoryPriceStats is the live median price of one category, in the default currency
+type CategoryPriceStats struct {
+	Median  float64 `json:"median"`
+	Samples int     `json:"samples"`
+}
+
+// categoryMedianCache keeps the last computed medians for COUNTERFEIT_MEDIAN_CACHE_TTL (default 10m)
+var categoryMedianCache struct {
+	sync.Mutex
+	data      map[string]CategoryPriceStats
+	expiresAt time.Time
+}
+
+// CategoryPriceMedians returns the median price per category across priced, non-flagged
+// listings, converted to the default currency. Results are cached.
+func CategoryPriceMedians(db *sql.DB) (map[string]CategoryPriceStats, error) {
+	categoryMedianCache.Lock()
+	defer categoryMedianCache.Unlock()
+	if categoryMedianCache.data != nil && time.Now().Before(categoryMedianCache.expiresAt) {
+		return categoryMedianCache.data, nil
+	}
+
+	rows, err := db.Query(`
+		SELECT category, price, COALESCE(currency, 'PHP')
+		FROM products
+		WHERE price > 0 AND category IS NOT NULL AND category <> ''
+		  AND status IN ('available', 'sold', 'traded')
+		  AND (counterfeit_confidence IS NULL OR counterfeit_confidence < ?)`, CurrentCounterfeitSettings().SuspicionThreshold)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prices := make(map[string][]float64)
+	for rows.Next() {
+		var category, currency string
+		var price float64
+		if err := rows.Scan(&category, &price, &currency); err != nil {
+			return nil, err
+		}
+		prices[category] = append(prices[category], price*config.PointsPerUnit(currency))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	data := make(map[string]CategoryPriceStats, len(prices))
+	for category, list := range prices {
+		data[category] = CategoryPriceStats{Median: median(list), Samples: len(list)}
+	}
+	categoryMedianCache.data = data
+	categoryMedianCache.expiresAt = time.Now().Add(config.GetEnvDuration("COUNTERFEIT_MEDIAN_CACHE_TTL", 10*time.Minute))
+	return data, nil
+}
+
+// median returns the middle value of values, which it sorts in place
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Float64s(values)
+	mid := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[mid-1] + values[mid]) / 2
+	}
+	return values[mid]
+}
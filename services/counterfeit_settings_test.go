@@ -0,0 +1,104 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testCounterfeitSettings() CounterfeitSettings {
+	return CounterfeitSettings{
+		SuspicionThreshold: 0.3,
+		BelowMedianRatio:   0.3,
+		MinCategorySamples: 5,
+		LuxuryPriceFloor:   50,
+		PromoPriceFloor:    100,
+		SuspiciousKeywords: defaultSuspiciousKeywords,
+	}
+}
+
+func TestDetectCounterfeitCategoryMedian(t *testing.T) {
+	settings := testCounterfeitSettings()
+	medians := map[string]CategoryPriceStats{
+		"Electronics": {Median: 10000, Samples: 20},
+		"Books":       {Median: 300, Samples: 2},
+	}
+
+	far := detectCounterfeit("Wireless headphones", "Barely used", "Electronics", 1000, settings, medians)
+	if !far.IsSuspicious {
+		t.Errorf("price 90%% below median should be suspicious, got %+v", far)
+	}
+
+	near := detectCounterfeit("Wireless headphones", "Barely used", "Electronics", 6000, settings, medians)
+	if near.IsSuspicious || len(near.Flags) != 0 {
+		t.Errorf("price near median should not be flagged, got %+v", near)
+	}
+
+	thin := detectCounterfeit("Paperback novel", "Good copy", "Books", 1, settings, medians)
+	for _, f := range thin.Flags {
+		if strings.Contains(f, "typical price") {
+			t.Errorf("category with too few samples should not be compared: %+v", thin)
+		}
+	}
+}
+
+func TestDetectCounterfeitUsesSettings(t *testing.T) {
+	settings := testCounterfeitSettings()
+	settings.SuspiciousKeywords = []string{"bootleg"}
+
+	if r := detectCounterfeit("Bootleg jersey", "", "", 500, settings, nil); !r.IsSuspicious {
+		t.Errorf("custom keyword should flag the listing, got %+v", r)
+	}
+	if r := detectCounterfeit("Replica jersey", "", "", 500, settings, nil); r.IsSuspicious {
+		t.Errorf("keyword removed from the list should not flag, got %+v", r)
+	}
+
+	settings.SuspicionThreshold = 0.9
+	if r := detectCounterfeit("Bootleg jersey", "", "", 500, settings, nil); r.IsSuspicious {
+		t.Errorf("raised threshold should not flag a single keyword, got %+v", r)
+	}
+}
+
+func TestCounterfeitSettingsValidate(t *testing.T) {
+	valid := testCounterfeitSettings()
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("defaults should validate: %v", err)
+	}
+	for name, mutate := range map[string]func(*CounterfeitSettings){
+		"zero threshold":   func(s *CounterfeitSettings) { s.SuspicionThreshold = 0 },
+		"threshold over 1": func(s *CounterfeitSettings) { s.SuspicionThreshold = 1.5 },
+		"ratio of 1":       func(s *CounterfeitSettings) { s.BelowMedianRatio = 1 },
+		"no samples":       func(s *CounterfeitSettings) { s.MinCategorySamples = 0 },
+		"negative floor":   func(s *CounterfeitSettings) { s.LuxuryPriceFloor = -1 },
+	} {
+		s := valid
+		mutate(&s)
+		if err := s.Validate(); !errors.Is(err, ErrInvalidCounterfeitSettings) {
+			t.Errorf("%s: err = %v, want ErrInvalidCounterfeitSettings", name, err)
+		}
+	}
+}
+
+func TestMedian(t *testing.T) {
+	cases := []struct {
+		in   []float64
+		want float64
+	}{
+		{nil, 0},
+		{[]float64{5}, 5},
+		{[]float64{9, 1, 5}, 5},
+		{[]float64{4, 1, 3, 2}, 2.5},
+	}
+	for _, tc := range cases {
+		if got := median(tc.in); got != tc.want {
+			t.Errorf("median(%v) = %v, want %v", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestNormalizeKeywords(t *testing.T) {
+	got := normalizeKeywords([]string{" Fake ", "fake", "", "Replica"})
+	if len(got) != 2 || got[0] != "fake" || got[1] != "replica" {
+		t.Errorf("normalizeKeywords = %v, want [fake replica]", got)
+	}
+}
@@ -1,7 +1,9 @@
 package services
 
 import (
+	"database/sql"
 	"fmt"
+	"log"
 	"math"
 	"regexp"
 	"strings"
@@ -15,8 +17,9 @@ type CounterfeitReport struct {
 	Flags        []string `json:"flags"`      // List of detected issues
 }
 
-// suspiciousKeywords is a list of words that may indicate a counterfeit product.
-var suspiciousKeywords = []string{
+// defaultSuspiciousKeywords is the built-in list of words that may indicate a counterfeit
+// product; COUNTERFEIT_KEYWORDS or the admin settings replace it.
+var defaultSuspiciousKeywords = []string{
 	"replica", "copy", "clone", "fake", "first copy", "inspired by",
 	"knockoff", "imitation", "duplicate", "counterfeit", "unauthorized",
 	"unbranded", "generic", "similar to", "looks like",
@@ -53,13 +56,27 @@ var suspiciousPatterns = []*regexp.Regexp{
 }
 
 // DetectCounterfeit analyzes a product's details to flag suspicious listings using AI-based heuristics.
-func DetectCounterfeit(title, description string, price float64) CounterfeitReport {
+// price is in the default currency. When db is given, the price is also compared with the
+// live median of the listing's category.
+func DetectCounterfeit(db *sql.DB, title, description, category string, price float64) CounterfeitReport {
+	var medians map[string]CategoryPriceStats
+	if db != nil && category != "" {
+		m, err := CategoryPriceMedians(db)
+		if err != nil {
+			log.Printf("Warning: failed to load category price medians: %v", err)
+		}
+		medians = m
+	}
+	return detectCounterfeit(title, description, category, price, CurrentCounterfeitSettings(), medians)
+}
+
+func detectCounterfeit(title, description, category string, price float64, settings CounterfeitSettings, medians map[string]CategoryPriceStats) CounterfeitReport {
 	text := strings.ToLower(title + " " + description)
 	flags := []string{}
 	confidence := 0.0
 
 	// Check for suspicious keywords (weight: 0.3)
-	for _, keyword := range suspiciousKeywords {
+	for _, keyword := range settings.SuspiciousKeywords {
 		if strings.Contains(text, keyword) {
 			flags = append(flags, fmt.Sprintf("Contains suspicious keyword: '%s'", keyword))
 			confidence += 0.3
@@ -90,6 +107,16 @@ func DetectCounterfeit(title, description string, price float64) CounterfeitRepo
 		}
 	}
 
+	// Check for prices far below the category's live median (weight: 0.35)
+	if stats, ok := medians[category]; ok && !priceFlagged && price > 0 && stats.Samples >= settings.MinCategorySamples && stats.Median > 0 {
+		if price < stats.Median*settings.BelowMedianRatio {
+			priceDiff := (stats.Median - price) / stats.Median
+			flags = append(flags, fmt.Sprintf("Price %.0f%% below the typical price for %s", priceDiff*100, category))
+			confidence += 0.35 * math.Min(priceDiff*2, 1.0)
+			priceFlagged = true
+		}
+	}
+
 	// Check for price-to-description mismatch (weight: 0.1)
 	// If description mentions luxury/premium but price is very low
 	luxuryKeywords := []string{"luxury", "premium", "designer", "authentic", "genuine", "original"}
@@ -100,7 +127,7 @@ func DetectCounterfeit(title, description string, price float64) CounterfeitRepo
 			break
 		}
 	}
-	if hasLuxuryMention && price < 50 && !priceFlagged {
+	if hasLuxuryMention && price < settings.LuxuryPriceFloor && !priceFlagged {
 		flags = append(flags, "Luxury/premium mentioned but price is very low")
 		confidence += 0.1
 	}
@@ -113,7 +140,7 @@ func DetectCounterfeit(title, description string, price float64) CounterfeitRepo
 			promoCount++
 		}
 	}
-	if promoCount >= 3 && price < 100 {
+	if promoCount >= 3 && price < settings.PromoPriceFloor {
 		flags = append(flags, "Excessive promotional language with low price")
 		confidence += 0.1
 	}
@@ -121,8 +148,8 @@ func DetectCounterfeit(title, description string, price float64) CounterfeitRepo
 	// Cap confidence at 1.0
 	confidence = math.Min(confidence, 1.0)
 
-	// Determine if suspicious (threshold: 0.3)
-	isSuspicious := confidence >= 0.3
+	// Determine if suspicious
+	isSuspicious := confidence >= settings.SuspicionThreshold
 	reason := ""
 	if isSuspicious {
 		if len(flags) > 0 {
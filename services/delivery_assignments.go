@@ -0,0 +1,109 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// deliveryAssignmentCheckInterval is how often lapsed rider assignments are released
+const deliveryAssignmentCheckInterval = time.Minute
+
+// StartDeliveryAssignmentExpiry periodically returns deliveries whose assigned rider
+// did not accept in time to the pending queue
+func StartDeliveryAssignmentExpiry(db *sql.DB) {
+	go func() {
+		ticker := time.NewTicker(deliveryAssignmentCheckInterval)
+		defer ticker.Stop()
+		for {
+			if n, err := ExpireDeliveryAssignments(db); err != nil {
+				log.Printf("delivery assignment expiry error: %v", err)
+			} else if n > 0 {
+				log.Printf("Released %d lapsed delivery assignments", n)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// ReleaseDeliveryAssignment takes an unaccepted delivery off its assigned rider and
+// returns it to pending so any rider can claim it, counting it against the rider. It
+// reports false when the delivery is no longer awaiting that rider.
+func ReleaseDeliveryAssignment(tx *sql.Tx, deliveryID, riderID int) (bool, error) {
+	res, err := tx.Exec(`
+		UPDATE deliveries
+		SET status = 'pending', rider_id = NULL, claimed_at = NULL, assignment_expires_at = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND rider_id = ? AND status = 'claimed' AND assignment_expires_at IS NOT NULL`, deliveryID, riderID)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	if _, err := tx.Exec("UPDATE riders SET reject_count = reject_count + 1 WHERE id = ?", riderID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// DeliveryReassignedMessage tells the customer their delivery is looking for a new rider
+func DeliveryReassignedMessage(deliveryID int) string {
+	return fmt.Sprintf("Your rider could not take delivery #%d, so it is back in the queue for another rider", deliveryID)
+}
+
+// ExpireDeliveryAssignments releases every assignment whose acceptance window has passed
+func ExpireDeliveryAssignments(db *sql.DB) (int, error) {
+	rows, err := db.Query(`
+		SELECT id, rider_id, user_id FROM deliveries
+		WHERE status = 'claimed' AND rider_id IS NOT NULL
+		  AND assignment_expires_at IS NOT NULL AND assignment_expires_at <= NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	type lapsed struct{ deliveryID, riderID, customerID int }
+	var due []lapsed
+	for rows.Next() {
+		var l lapsed
+		if err := rows.Scan(&l.deliveryID, &l.riderID, &l.customerID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		due = append(due, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, l := range due {
+		tx, err := db.Begin()
+		if err != nil {
+			return count, err
+		}
+		// Re-check the window under the row lock: the rider may have just accepted
+		var stillLapsed bool
+		err = tx.QueryRow(`SELECT COALESCE(assignment_expires_at <= NOW(), FALSE) FROM deliveries WHERE id = ? FOR UPDATE`, l.deliveryID).Scan(&stillLapsed)
+		if err != nil || !stillLapsed {
+			tx.Rollback()
+			if err != nil && err != sql.ErrNoRows {
+				return count, err
+			}
+			continue
+		}
+		released, err := ReleaseDeliveryAssignment(tx, l.deliveryID, l.riderID)
+		if err != nil {
+			tx.Rollback()
+			return count, err
+		}
+		if err := tx.Commit(); err != nil {
+			return count, err
+		}
+		if released {
+			count++
+			Notify(db, l.customerID, "delivery_reassigned", DeliveryReassignedMessage(l.deliveryID), "delivery", l.deliveryID)
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/xashathebest/clovia/config"
+)
+
+// staleDeliveryCheckInterval is how often claimed deliveries are checked for progress
+const staleDeliveryCheckInterval = 5 * time.Minute
+
+// StartStaleDeliveryMonitor periodically flags deliveries whose rider claimed them but
+// has not picked them up within DELIVERY_STALE_CLAIM_AFTER
+func StartStaleDeliveryMonitor(db *sql.DB) {
+	go func() {
+		ticker := time.NewTicker(staleDeliveryCheckInterval)
+		defer ticker.Stop()
+		for {
+			if n, err := FlagStaleDeliveries(db, config.DeliveryStaleClaimAfter(), config.DeliveryStaleAutoRelease()); err != nil {
+				log.Printf("stale delivery monitor error: %v", err)
+			} else if n > 0 {
+				log.Printf("Flagged %d stale delivery claims", n)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// FlagStaleDeliveries flags every accepted claim older than threshold that has not
+// reached pickup, records the event and tells the admins. With release set the delivery
+// also goes back to pending for another rider and the customer is told. Assignments
+// still awaiting the rider's answer are left to ExpireDeliveryAssignments, and a
+// delivery is flagged once per claim.
+func FlagStaleDeliveries(db *sql.DB, threshold time.Duration, release bool) (int, error) {
+	cutoff := time.Now().Add(-threshold)
+	rows, err := db.Query(`
+		SELECT id FROM deliveries
+		WHERE status = 'claimed' AND rider_id IS NOT NULL AND picked_up_at IS NULL
+		  AND assignment_expires_at IS NULL AND stale_flagged_at IS NULL
+		  AND claimed_at IS NOT NULL AND claimed_at <= ?`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	var due []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		due = append(due, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(due) == 0 {
+		return 0, nil
+	}
+
+	admins, err := adminUserIDs(db)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, deliveryID := range due {
+		customerID, flagged, err := flagStaleDelivery(db, deliveryID, cutoff, release)
+		if err != nil {
+			return count, err
+		}
+		if !flagged {
+			continue
+		}
+		count++
+		msg := fmt.Sprintf("Delivery #%d has been claimed for over %s without being picked up", deliveryID, threshold)
+		if release {
+			msg += "; it was returned to the pending queue"
+			Notify(db, customerID, "delivery_reassigned", DeliveryReassignedMessage(deliveryID), "delivery", deliveryID)
+		}
+		for _, adminID := range admins {
+			Notify(db, adminID, "delivery_stale", msg, "delivery", deliveryID)
+		}
+	}
+	return count, nil
+}
+
+// flagStaleDelivery flags one delivery, and releases it when asked, if it is still stuck
+// under the row lock. It returns the customer and whether the delivery was flagged.
+func flagStaleDelivery(db *sql.DB, deliveryID int, cutoff time.Time, release bool) (int, bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, false, err
+	}
+	defer tx.Rollback()
+
+	var customerID, riderID int
+	var claimedAt time.Time
+	err = tx.QueryRow(`
+		SELECT user_id, rider_id, claimed_at FROM deliveries
+		WHERE id = ? AND status = 'claimed' AND rider_id IS NOT NULL AND picked_up_at IS NULL
+		  AND assignment_expires_at IS NULL AND stale_flagged_at IS NULL
+		  AND claimed_at IS NOT NULL AND claimed_at <= ?
+		FOR UPDATE`, deliveryID, cutoff).Scan(&customerID, &riderID, &claimedAt)
+	if err == sql.ErrNoRows {
+		// The rider moved it along, or another pass got there first
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	if release {
+		_, err = tx.Exec(`
+			UPDATE deliveries
+			SET status = 'pending', rider_id = NULL, claimed_at = NULL, stale_flagged_at = NULL, updated_at = CURRENT_TIMESTAMP
+			WHERE id = ?`, deliveryID)
+	} else {
+		_, err = tx.Exec("UPDATE deliveries SET stale_flagged_at = CURRENT_TIMESTAMP WHERE id = ?", deliveryID)
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	if _, err := tx.Exec("INSERT INTO delivery_stale_events (delivery_id, rider_id, claimed_at, released) VALUES (?, ?, ?, ?)",
+		deliveryID, riderID, claimedAt, release); err != nil {
+		return 0, false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, false, err
+	}
+	return customerID, true, nil
+}
+
+// adminUserIDs lists the accounts that receive operational alerts
+func adminUserIDs(db *sql.DB) ([]int, error) {
+	rows, err := db.Query("SELECT id FROM users WHERE role = 'admin'")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
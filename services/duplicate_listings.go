@@ -0,0 +1,165 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/xashathebest/clovia/config"
+	"github.com/xashathebest/clovia/storage"
+)
+
+// Duplicate actions taken when a new listing matches one of the seller's recent listings
+const (
+	DuplicateActionFlag  = "flag"
+	DuplicateActionBlock = "block"
+)
+
+// DuplicateSettings tune near-duplicate listing detection
+type DuplicateSettings struct {
+	// SimilarityThreshold is the text similarity (0-1) at or above which listings match
+	SimilarityThreshold float64
+	// Action is flag (store the match for admin review) or block (reject the listing)
+	Action string
+	// Lookback limits the comparison to the seller's listings created within this window
+	Lookback time.Duration
+}
+
+// DefaultDuplicateSettings reads the DUPLICATE_* environment variables
+func DefaultDuplicateSettings() DuplicateSettings {
+	s := DuplicateSettings{
+		SimilarityThreshold: config.GetEnvFloat("DUPLICATE_SIMILARITY_THRESHOLD", 0.85),
+		Action:              strings.ToLower(config.GetEnv("DUPLICATE_ACTION", DuplicateActionFlag)),
+		Lookback:            config.GetEnvDuration("DUPLICATE_LOOKBACK", 30*24*time.Hour),
+	}
+	if s.SimilarityThreshold <= 0 || s.SimilarityThreshold > 1 {
+		s.SimilarityThreshold = 0.85
+	}
+	if s.Action != DuplicateActionBlock {
+		s.Action = DuplicateActionFlag
+	}
+	return s
+}
+
+// DuplicateMatch is the closest earlier listing a new one duplicates
+type DuplicateMatch struct {
+	ProductID  int      `json:"product_id"`
+	Title      string   `json:"title"`
+	Similarity float64  `json:"similarity"`
+	Reasons    []string `json:"reasons"`
+}
+
+// HashImage returns the hex SHA-256 of an uploaded image, so re-uploads of the same
+// file match even under a different name. It is the same hash storage.ContentKey
+// names the stored file by.
+func HashImage(r io.Reader) (string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	return storage.ContentHash(data), nil
+}
+
+// listingTokens lower-cases text, drops punctuation and returns its distinct words
+func listingTokens(text string) map[string]bool {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	tokens := make(map[string]bool, len(words))
+	for _, w := range words {
+		tokens[w] = true
+	}
+	return tokens
+}
+
+// ListingSimilarity is the Jaccard similarity of two listings' title and description
+// words. Case, punctuation, word order and repeated words do not matter.
+func ListingSimilarity(titleA, descA, titleB, descB string) float64 {
+	a := listingTokens(titleA + " " + descA)
+	b := listingTokens(titleB + " " + descB)
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for w := range a {
+		if b[w] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// duplicateCandidate is one of the seller's recent listings
+type duplicateCandidate struct {
+	ID          int
+	Title       string
+	Description string
+	ImageHashes []string
+}
+
+// matchDuplicate returns the best candidate that shares an image with the new listing or
+// whose text similarity reaches the threshold; a shared image counts as similarity 1
+func matchDuplicate(title, description string, imageHashes []string, candidates []duplicateCandidate, threshold float64) *DuplicateMatch {
+	hashes := make(map[string]bool, len(imageHashes))
+	for _, h := range imageHashes {
+		hashes[h] = true
+	}
+
+	var best *DuplicateMatch
+	for _, cand := range candidates {
+		var reasons []string
+		score := ListingSimilarity(title, description, cand.Title, cand.Description)
+		if score >= threshold {
+			reasons = append(reasons, "similar title and description")
+		}
+		for _, h := range cand.ImageHashes {
+			if hashes[h] {
+				reasons = append(reasons, "same image")
+				score = 1
+				break
+			}
+		}
+		if len(reasons) == 0 {
+			continue
+		}
+		if best == nil || score > best.Similarity {
+			best = &DuplicateMatch{ProductID: cand.ID, Title: cand.Title, Similarity: score, Reasons: reasons}
+		}
+	}
+	return best
+}
+
+// FindDuplicateListing compares a new listing with the seller's listings created within
+// the lookback window, skipping ones already traded or sold. Returns nil when none match.
+func FindDuplicateListing(db *sql.DB, sellerID int, title, description string, imageHashes []string, settings DuplicateSettings) (*DuplicateMatch, error) {
+	rows, err := db.Query(`
+		SELECT id, title, COALESCE(description, ''), image_hashes
+		FROM products
+		WHERE seller_id = ? AND status IN ('available', 'locked') AND created_at >= ?
+		ORDER BY created_at DESC
+		LIMIT 200`, sellerID, time.Now().Add(-settings.Lookback))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candidates []duplicateCandidate
+	for rows.Next() {
+		var cand duplicateCandidate
+		var hashes sql.NullString
+		if err := rows.Scan(&cand.ID, &cand.Title, &cand.Description, &hashes); err != nil {
+			return nil, err
+		}
+		if hashes.Valid && hashes.String != "" {
+			_ = json.Unmarshal([]byte(hashes.String), &cand.ImageHashes)
+		}
+		candidates = append(candidates, cand)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return matchDuplicate(title, description, imageHashes, candidates, settings.SimilarityThreshold), nil
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListingSimilarity(t *testing.T) {
+	same := ListingSimilarity("iPhone 12, 64GB!", "Barely used, with box", "iphone 12 64gb", "barely USED with box")
+	if same != 1 {
+		t.Errorf("reworded copy similarity = %v, want 1", same)
+	}
+	different := ListingSimilarity("Calculus textbook", "Stewart 8th edition", "Mountain bike", "27 speed, red")
+	if different != 0 {
+		t.Errorf("unrelated listings similarity = %v, want 0", different)
+	}
+	if got := ListingSimilarity("", "", "Anything", ""); got != 0 {
+		t.Errorf("empty listing similarity = %v, want 0", got)
+	}
+}
+
+func TestMatchDuplicate(t *testing.T) {
+	candidates := []duplicateCandidate{
+		{ID: 1, Title: "Mountain bike", Description: "27 speed red frame"},
+		{ID: 2, Title: "Calculus textbook", Description: "Stewart 8th edition", ImageHashes: []string{"abc"}},
+		{ID: 3, Title: "Calculus textbook", Description: "Stewart 8th edition, some notes"},
+	}
+
+	m := matchDuplicate("Calculus textbook", "Stewart 8th edition", nil, candidates, 0.85)
+	if m == nil || m.ProductID != 2 || m.Similarity != 1 {
+		t.Fatalf("text match = %+v, want product 2 at similarity 1", m)
+	}
+
+	m = matchDuplicate("Totally new title", "nothing alike", []string{"abc"}, candidates, 0.85)
+	if m == nil || m.ProductID != 2 || len(m.Reasons) != 1 || m.Reasons[0] != "same image" {
+		t.Fatalf("image match = %+v, want product 2 for the same image", m)
+	}
+
+	if m := matchDuplicate("Desk lamp", "LED, adjustable", []string{"zzz"}, candidates, 0.85); m != nil {
+		t.Errorf("unrelated listing matched %+v", m)
+	}
+}
+
+func TestHashImage(t *testing.T) {
+	a, _ := HashImage(strings.NewReader("same bytes"))
+	b, _ := HashImage(strings.NewReader("same bytes"))
+	c, _ := HashImage(strings.NewReader("other bytes"))
+	if a != b || a == c || len(a) != 64 {
+		t.Errorf("hashes = %q, %q, %q", a, b, c)
+	}
+}
+
+func TestDefaultDuplicateSettings(t *testing.T) {
+	t.Setenv("DUPLICATE_SIMILARITY_THRESHOLD", "0.7")
+	t.Setenv("DUPLICATE_ACTION", "BLOCK")
+	t.Setenv("DUPLICATE_LOOKBACK", "72h")
+	s := DefaultDuplicateSettings()
+	if s.SimilarityThreshold != 0.7 || s.Action != DuplicateActionBlock || s.Lookback != 72*time.Hour {
+		t.Errorf("settings = %+v", s)
+	}
+
+	t.Setenv("DUPLICATE_SIMILARITY_THRESHOLD", "3")
+	t.Setenv("DUPLICATE_ACTION", "delete")
+	s = DefaultDuplicateSettings()
+	if s.SimilarityThreshold != 0.85 || s.Action != DuplicateActionFlag {
+		t.Errorf("out-of-range settings = %+v, want defaults", s)
+	}
+}
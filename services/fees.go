@@ -0,0 +1,112 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"strings"
+
+	"github.com/xashathebest/clovia/config"
+)
+
+// Fee kinds, one per money flow that carries a platform fee
+const (
+	FeeKindOrder = "order"
+	FeeKindTrade = "trade"
+)
+
+// FeeKinds lists the supported fee kinds
+var FeeKinds = []string{FeeKindOrder, FeeKindTrade}
+
+// FeeQuote is the platform fee applied to an amount
+type FeeQuote struct {
+	Kind      string  `json:"kind"`
+	Amount    float64 `json:"amount"`
+	Percent   float64 `json:"percent"`
+	Fee       float64 `json:"fee"`
+	NetAmount float64 `json:"net_amount"`
+}
+
+// IsValidFeeKind reports whether kind is a known fee kind
+func IsValidFeeKind(kind string) bool {
+	for _, k := range FeeKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// feeRule is the configured fee for one kind
+type feeRule struct {
+	Percent float64
+	Min     float64
+	Max     float64 // 0 means no cap
+}
+
+// feeRuleFor reads FEE_<KIND>_PERCENT, FEE_<KIND>_MIN and FEE_<KIND>_MAX
+func feeRuleFor(kind string) feeRule {
+	prefix := "FEE_" + strings.ToUpper(kind) + "_"
+	return feeRule{
+		Percent: config.GetEnvFloat(prefix+"PERCENT", 0),
+		Min:     config.GetEnvFloat(prefix+"MIN", 0),
+		Max:     config.GetEnvFloat(prefix+"MAX", 0),
+	}
+}
+
+// ComputeFee returns the platform fee for an amount of the given kind.
+// The percentage fee is clamped to the configured min/max and never exceeds the amount.
+func ComputeFee(amount float64, kind string) (FeeQuote, error) {
+	if !IsValidFeeKind(kind) {
+		return FeeQuote{}, errors.New("unknown fee kind")
+	}
+	if amount < 0 || math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return FeeQuote{}, errors.New("amount must be a non-negative number")
+	}
+	return applyFeeRule(amount, kind, feeRuleFor(kind)), nil
+}
+
+func applyFeeRule(amount float64, kind string, rule feeRule) FeeQuote {
+	fee := 0.0
+	if amount > 0 {
+		fee = amount * rule.Percent / 100
+		if fee < rule.Min {
+			fee = rule.Min
+		}
+		if rule.Max > 0 && fee > rule.Max {
+			fee = rule.Max
+		}
+		if fee > amount {
+			fee = amount
+		}
+		if fee < 0 {
+			fee = 0
+		}
+	}
+	fee = math.Round(fee*100) / 100
+	return FeeQuote{
+		Kind:      kind,
+		Amount:    amount,
+		Percent:   rule.Percent,
+		Fee:       fee,
+		NetAmount: math.Round((amount-fee)*100) / 100,
+	}
+}
+
+// RecordTradeFee stores the platform fee and net amount for a completed trade's
+// cash component. Trades without cash are left untouched.
+func RecordTradeFee(tx *sql.Tx, tradeID int) error {
+	var cash sql.NullFloat64
+	if err := tx.QueryRow("SELECT offered_cash_amount FROM trades WHERE id = ?", tradeID).Scan(&cash); err != nil {
+		return err
+	}
+	if !cash.Valid {
+		return nil
+	}
+	quote, err := ComputeFee(cash.Float64, FeeKindTrade)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE trades SET platform_fee = ?, net_amount = ? WHERE id = ?", quote.Fee, quote.NetAmount, tradeID)
+	return err
+}
@@ -0,0 +1,50 @@
+package services
+
+import "testing"
+
+func TestApplyFeeRule(t *testing.T) {
+	cases := []struct {
+		name    string
+		amount  float64
+		rule    feeRule
+		wantFee float64
+		wantNet float64
+	}{
+		{"no fee configured", 500, feeRule{}, 0, 500},
+		{"percentage", 1000, feeRule{Percent: 5}, 50, 950},
+		{"minimum applies", 100, feeRule{Percent: 1, Min: 10}, 10, 90},
+		{"maximum applies", 100000, feeRule{Percent: 5, Max: 200}, 200, 99800},
+		{"never above amount", 5, feeRule{Min: 10}, 5, 0},
+		{"zero amount", 0, feeRule{Percent: 5, Min: 10}, 0, 0},
+		{"rounded to cents", 33.33, feeRule{Percent: 2.5}, 0.83, 32.5},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			q := applyFeeRule(tc.amount, FeeKindOrder, tc.rule)
+			if q.Fee != tc.wantFee || q.NetAmount != tc.wantNet {
+				t.Errorf("got fee=%v net=%v, want fee=%v net=%v", q.Fee, q.NetAmount, tc.wantFee, tc.wantNet)
+			}
+		})
+	}
+}
+
+func TestComputeFeeRejectsBadInput(t *testing.T) {
+	if _, err := ComputeFee(100, "subscription"); err == nil {
+		t.Error("expected error for unknown kind")
+	}
+	if _, err := ComputeFee(-1, FeeKindOrder); err == nil {
+		t.Error("expected error for negative amount")
+	}
+}
+
+func TestComputeFeeReadsConfig(t *testing.T) {
+	t.Setenv("FEE_TRADE_PERCENT", "10")
+	t.Setenv("FEE_TRADE_MAX", "15")
+	q, err := ComputeFee(200, FeeKindTrade)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if q.Percent != 10 || q.Fee != 15 || q.NetAmount != 185 {
+		t.Errorf("unexpected quote %+v", q)
+	}
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"database/sql"
+	"log"
+
+	"github.com/xashathebest/clovia/models"
+)
+
+// NotificationSuppressed reports whether the user asked not to hear about this
+// reference. Conversation mutes are the only preference today; new ones belong here
+// so every notification path picks them up.
+func NotificationSuppressed(db *sql.DB, userID int, refType string, refID int) (bool, error) {
+	if refType != models.NotificationRefConversation {
+		return false, nil
+	}
+	var muted bool
+	err := db.QueryRow("SELECT COUNT(*) > 0 FROM conversation_mutes WHERE conversation_id = ? AND user_id = ?", refID, userID).Scan(&muted)
+	return muted, err
+}
+
+// Notify stores a notification unless the user suppressed it and returns its id, or 0
+// when nothing was stored. The insert goes through WithRetry; any failure is logged,
+// never returned, because a lost notification must not fail the action that
+// triggered it. Handlers use notify, which also pushes the live event.
+func Notify(db *sql.DB, userID int, typ, message, refType string, refID int) int64 {
+	suppressed, err := NotificationSuppressed(db, userID, refType, refID)
+	if err != nil {
+		// Without the preferences, err on the side of staying quiet
+		log.Printf("Warning: skipped %s notification for user %d: %v", typ, userID, err)
+		return 0
+	}
+	if suppressed {
+		return 0
+	}
+
+	var id int64
+	err = WithRetry(func() error {
+		res, err := db.Exec(
+			"INSERT INTO notifications (user_id, type, message, is_read, reference_type, reference_id) VALUES (?, ?, ?, FALSE, ?, ?)",
+			userID, typ, message, refType, refID,
+		)
+		if err != nil {
+			return err
+		}
+		id, _ = res.LastInsertId()
+		return nil
+	})
+	if err != nil {
+		log.Printf("Warning: failed to store %s notification for user %d: %v", typ, userID, err)
+		return 0
+	}
+	return id
+}
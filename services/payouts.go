@@ -0,0 +1,57 @@
+package services
+
+import (
+	"database/sql"
+	"math"
+
+	"github.com/xashathebest/clovia/models"
+)
+
+// sqlQueryRower is satisfied by both *sql.DB and *sql.Tx
+type sqlQueryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// SellerPayoutBalance totals what a seller has earned and what is already spoken for.
+// Earnings are the net (after platform fee) of completed orders on their listings plus
+// the cash side of trades they completed as seller. Rejected payouts free their amount again;
+// seller_charges such as premium placement are spent.
+func SellerPayoutBalance(q sqlQueryRower, userID int) (models.PayoutBalance, error) {
+	var b models.PayoutBalance
+	var orders, trades float64
+	err := q.QueryRow(`
+		SELECT COALESCE(SUM(COALESCE(t.net_amount, t.amount - t.fee)), 0)
+		FROM transactions t
+		JOIN orders o ON o.id = t.order_id
+		JOIN products p ON p.id = o.product_id
+		WHERE p.seller_id = ? AND o.status = 'completed'`, userID).Scan(&orders)
+	if err != nil {
+		return b, err
+	}
+	err = q.QueryRow(`
+		SELECT COALESCE(SUM(net_amount), 0) FROM trades
+		WHERE seller_id = ? AND status IN ('completed', 'auto_completed') AND net_amount IS NOT NULL`, userID).Scan(&trades)
+	if err != nil {
+		return b, err
+	}
+	err = q.QueryRow(`
+		SELECT COALESCE(SUM(CASE WHEN status IN ('pending', 'approved') THEN amount ELSE 0 END), 0),
+		       COALESCE(SUM(CASE WHEN status = 'paid' THEN amount ELSE 0 END), 0)
+		FROM payouts WHERE user_id = ?`, userID).Scan(&b.Requested, &b.Paid)
+	if err != nil {
+		return b, err
+	}
+	if err := q.QueryRow("SELECT COALESCE(SUM(amount), 0) FROM seller_charges WHERE user_id = ?", userID).Scan(&b.Charged); err != nil {
+		return b, err
+	}
+	b.Earnings = roundCents(orders + trades)
+	b.Available = roundCents(b.Earnings - b.Requested - b.Paid - b.Charged)
+	if b.Available < 0 {
+		b.Available = 0
+	}
+	return b, nil
+}
+
+func roundCents(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// premiumCheckInterval is how often ended premium windows are expired
+const premiumCheckInterval = 5 * time.Minute
+
+// StartPremiumExpiryScheduler periodically takes premium placement off listings whose
+// bought windows have all ended
+func StartPremiumExpiryScheduler(db *sql.DB) {
+	go func() {
+		ticker := time.NewTicker(premiumCheckInterval)
+		defer ticker.Stop()
+		for {
+			if n, err := ExpirePremiumListings(db); err != nil {
+				log.Printf("premium expiry pass error: %v", err)
+			} else if n > 0 {
+				log.Printf("Expired premium placement on %d listings", n)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// ExpirePremiumListings clears the premium flag on listings that had premium windows and
+// have none left running, and tells each seller. Listings flagged premium without ever
+// buying a window are left alone.
+func ExpirePremiumListings(db *sql.DB) (int, error) {
+	rows, err := db.Query(`
+		SELECT p.id, p.seller_id, p.title FROM products p
+		WHERE p.premium = TRUE
+		  AND EXISTS (SELECT 1 FROM premium_listings pl WHERE pl.product_id = p.id)
+		  AND NOT EXISTS (SELECT 1 FROM premium_listings pl WHERE pl.product_id = p.id AND pl.end_date > NOW())`)
+	if err != nil {
+		return 0, err
+	}
+	type expired struct {
+		productID, sellerID int
+		title               string
+	}
+	var due []expired
+	for rows.Next() {
+		var e expired
+		if err := rows.Scan(&e.productID, &e.sellerID, &e.title); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		due = append(due, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, e := range due {
+		// Re-check the window so a purchase made since the scan is not undone
+		res, err := db.Exec(`
+			UPDATE products p SET p.premium = FALSE, p.version = p.version + 1
+			WHERE p.id = ? AND p.premium = TRUE
+			  AND NOT EXISTS (SELECT 1 FROM premium_listings pl WHERE pl.product_id = p.id AND pl.end_date > NOW())`, e.productID)
+		if err != nil {
+			return count, err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			continue
+		}
+		count++
+		Notify(db, e.sellerID, "premium_expired", fmt.Sprintf("Premium placement for \"%s\" has ended", e.title), "product", e.productID)
+	}
+	return count, nil
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// sqlExecer is satisfied by both *sql.DB and *sql.Tx
+type sqlExecer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// RecordProductStatusChange appends an entry to product_status_history.
+// fromStatus is empty for newly created products and actorID is 0 for system
+// changes (e.g. the trade timeout scheduler). Unchanged statuses are skipped.
+func RecordProductStatusChange(exec sqlExecer, productID int, fromStatus, toStatus string, actorID int, reason string) error {
+	if fromStatus == toStatus {
+		return nil
+	}
+
+	var from, actor, note interface{}
+	if fromStatus != "" {
+		from = fromStatus
+	}
+	if actorID > 0 {
+		actor = actorID
+	}
+	if reason != "" {
+		note = TruncateRunes(reason, 255)
+	}
+
+	_, err := exec.Exec(
+		"INSERT INTO product_status_history (product_id, from_status, to_status, actor_id, reason) VALUES (?, ?, ?, ?, ?)",
+		productID, from, toStatus, actor, note,
+	)
+	return err
+}
+
+// RecordProductOwnerChange appends a history entry for a listing moving to a new seller.
+// The status is unchanged, so from_status and to_status both hold the current status.
+func RecordProductOwnerChange(exec sqlExecer, productID int, status string, fromUserID, toUserID, actorID int) error {
+	var actor interface{}
+	if actorID > 0 {
+		actor = actorID
+	}
+	_, err := exec.Exec(
+		"INSERT INTO product_status_history (product_id, from_status, to_status, actor_id, reason) VALUES (?, ?, ?, ?, ?)",
+		productID, status, status, actor, fmt.Sprintf("ownership transferred from user #%d to user #%d", fromUserID, toUserID),
+	)
+	return err
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/xashathebest/clovia/config"
+)
+
+// ProfileAnalysisTTL is how long a cached profile analysis is served before it is recomputed
+// (PROFILE_ANALYSIS_TTL)
+func ProfileAnalysisTTL() time.Duration {
+	if ttl := config.GetEnvDuration("PROFILE_ANALYSIS_TTL", 6*time.Hour); ttl > 0 {
+		return ttl
+	}
+	return 6 * time.Hour
+}
+
+// ProfileAnalysisJobTimeout bounds one analyze-all run (PROFILE_ANALYSIS_JOB_TIMEOUT)
+func ProfileAnalysisJobTimeout() time.Duration {
+	if timeout := config.GetEnvDuration("PROFILE_ANALYSIS_JOB_TIMEOUT", 10*time.Minute); timeout > 0 {
+		return timeout
+	}
+	return 10 * time.Minute
+}
+
+// CachedProfileAnalysis is a stored analysis with its freshness
+type CachedProfileAnalysis struct {
+	Analysis   ProfileAnalysisResult `json:"analysis"`
+	ComputedAt time.Time             `json:"computed_at"`
+	Stale      bool                  `json:"stale"`
+}
+
+// LoadCachedProfileAnalysis returns the stored analysis for a user, or nil when there is none.
+// Stale is set when it is older than ttl.
+func LoadCachedProfileAnalysis(db *sql.DB, userID int, ttl time.Duration, now time.Time) (*CachedProfileAnalysis, error) {
+	var raw []byte
+	var computedAt time.Time
+	err := db.QueryRow("SELECT analysis, computed_at FROM profile_analysis_cache WHERE user_id = ?", userID).Scan(&raw, &computedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	cached := &CachedProfileAnalysis{ComputedAt: computedAt, Stale: now.Sub(computedAt) > ttl}
+	if err := json.Unmarshal(raw, &cached.Analysis); err != nil {
+		return nil, err
+	}
+	return cached, nil
+}
+
+// StoreProfileAnalysis writes a user's analysis to the cache and returns its computed-at time
+func StoreProfileAnalysis(db *sql.DB, userID int, analysis ProfileAnalysisResult) (time.Time, error) {
+	raw, err := json.Marshal(analysis)
+	if err != nil {
+		return time.Time{}, err
+	}
+	now := time.Now()
+	_, err = db.Exec(`INSERT INTO profile_analysis_cache (user_id, analysis, computed_at) VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE analysis = VALUES(analysis), computed_at = VALUES(computed_at)`, userID, raw, now)
+	return now, err
+}
+
+// RefreshProfileAnalysis recomputes a user's analysis and stores it in the cache
+func RefreshProfileAnalysis(ctx context.Context, db *sql.DB, userID int) (*CachedProfileAnalysis, error) {
+	analysis, err := AnalyzeProfile(ctx, db, userID)
+	if err != nil {
+		return nil, err
+	}
+	computedAt, err := StoreProfileAnalysis(db, userID, analysis)
+	if err != nil {
+		log.Printf("profile analysis cache write for user %d failed: %v", userID, err)
+		computedAt = time.Now()
+	}
+	return &CachedProfileAnalysis{Analysis: analysis, ComputedAt: computedAt}, nil
+}
+
+// ProfileAnalysisJob reports the state of the analyze-all background job
+type ProfileAnalysisJob struct {
+	Running    bool           `json:"running"`
+	StartedAt  *time.Time     `json:"started_at,omitempty"`
+	FinishedAt *time.Time     `json:"finished_at,omitempty"`
+	Summary    map[string]int `json:"summary,omitempty"`
+	Error      string         `json:"error,omitempty"`
+}
+
+var profileAnalysisJob = struct {
+	sync.Mutex
+	state ProfileAnalysisJob
+}{}
+
+// StartProfileAnalysisJob analyzes every profile in the background, writing each result into
+// the cache. The run keeps ctx's values but outlives the request that started it, and is
+// cancelled after ProfileAnalysisJobTimeout. It returns false when a run is already in progress.
+func StartProfileAnalysisJob(ctx context.Context, db *sql.DB) (ProfileAnalysisJob, bool) {
+	profileAnalysisJob.Lock()
+	defer profileAnalysisJob.Unlock()
+	if profileAnalysisJob.state.Running {
+		return profileAnalysisJob.state, false
+	}
+	started := time.Now()
+	profileAnalysisJob.state = ProfileAnalysisJob{Running: true, StartedAt: &started, Summary: profileAnalysisJob.state.Summary}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), ProfileAnalysisJobTimeout())
+	go func() {
+		defer cancel()
+		summary, err := AnalyzeAllProfiles(ctx, db)
+		finished := time.Now()
+
+		profileAnalysisJob.Lock()
+		defer profileAnalysisJob.Unlock()
+		profileAnalysisJob.state.Running = false
+		profileAnalysisJob.state.FinishedAt = &finished
+		// A run that timed out still reports the profiles it got through
+		profileAnalysisJob.state.Summary = summary
+		profileAnalysisJob.state.Error = ""
+		if err != nil {
+			log.Printf("profile analysis job failed: %v", err)
+			profileAnalysisJob.state.Error = err.Error()
+		}
+	}()
+	return profileAnalysisJob.state, true
+}
+
+// CurrentProfileAnalysisJob returns the state of the most recent analyze-all run
+func CurrentProfileAnalysisJob() ProfileAnalysisJob {
+	profileAnalysisJob.Lock()
+	defer profileAnalysisJob.Unlock()
+	return profileAnalysisJob.state
+}
@@ -0,0 +1,34 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestStartProfileAnalysisJobAlreadyRunning checks a second start while a run is in flight
+// reports the running job instead of launching another
+func TestStartProfileAnalysisJobAlreadyRunning(t *testing.T) {
+	profileAnalysisJob.Lock()
+	saved := profileAnalysisJob.state
+	started := time.Now().Add(-time.Minute)
+	profileAnalysisJob.state = ProfileAnalysisJob{Running: true, StartedAt: &started}
+	profileAnalysisJob.Unlock()
+	t.Cleanup(func() {
+		profileAnalysisJob.Lock()
+		profileAnalysisJob.state = saved
+		profileAnalysisJob.Unlock()
+	})
+
+	// A nil db would make a launched run fail, so getting here without a new run is the point
+	job, ok := StartProfileAnalysisJob(context.Background(), nil)
+	if ok {
+		t.Fatal("StartProfileAnalysisJob started a second run while one was in flight")
+	}
+	if !job.Running || job.StartedAt == nil || !job.StartedAt.Equal(started) {
+		t.Errorf("job = %+v, want the in-flight run started at %v", job, started)
+	}
+	if current := CurrentProfileAnalysisJob(); !current.Running {
+		t.Errorf("CurrentProfileAnalysisJob().Running = false, want true")
+	}
+}
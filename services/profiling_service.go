@@ -1,7 +1,9 @@
 package services
 
 import (
+	"context"
 	"database/sql"
+	"log"
 	"time"
 )
 
@@ -16,7 +18,7 @@ type ProfileAnalysisResult struct {
 }
 
 // AnalyzeProfile analyzes a user's profile to determine if it's outdated or inactive
-func AnalyzeProfile(db *sql.DB, userID int) (ProfileAnalysisResult, error) {
+func AnalyzeProfile(ctx context.Context, db *sql.DB, userID int) (ProfileAnalysisResult, error) {
 	result := ProfileAnalysisResult{
 		Recommendations: []string{},
 		Score:           1.0,
@@ -29,7 +31,7 @@ func AnalyzeProfile(db *sql.DB, userID int) (ProfileAnalysisResult, error) {
 	var hasLocation bool
 
 	// Get user profile info
-	err := db.QueryRow(`
+	err := db.QueryRowContext(ctx, `
 		SELECT 
 			u.created_at,
 			u.bio,
@@ -113,7 +115,7 @@ func AnalyzeProfile(db *sql.DB, userID int) (ProfileAnalysisResult, error) {
 }
 
 // AnalyzeAllProfiles analyzes all user profiles and returns a summary
-func AnalyzeAllProfiles(db *sql.DB) (map[string]int, error) {
+func AnalyzeAllProfiles(ctx context.Context, db *sql.DB) (map[string]int, error) {
 	summary := map[string]int{
 		"total":           0,
 		"outdated":        0,
@@ -121,7 +123,7 @@ func AnalyzeAllProfiles(db *sql.DB) (map[string]int, error) {
 		"needs_attention": 0,
 	}
 
-	rows, err := db.Query("SELECT id FROM users WHERE role = 'user'")
+	rows, err := db.QueryContext(ctx, "SELECT id FROM users WHERE role = 'user'")
 	if err != nil {
 		return summary, err
 	}
@@ -134,10 +136,17 @@ func AnalyzeAllProfiles(db *sql.DB) (map[string]int, error) {
 		}
 
 		summary["total"]++
-		analysis, err := AnalyzeProfile(db, userID)
+		analysis, err := AnalyzeProfile(ctx, db, userID)
+		if ctx.Err() != nil {
+			// Out of time; report how far the run got
+			return summary, ctx.Err()
+		}
 		if err != nil {
 			continue
 		}
+		if _, err := StoreProfileAnalysis(db, userID, analysis); err != nil {
+			log.Printf("profile analysis cache write for user %d failed: %v", userID, err)
+		}
 
 		if analysis.IsOutdated {
 			summary["outdated"]++
@@ -150,6 +159,6 @@ func AnalyzeAllProfiles(db *sql.DB) (map[string]int, error) {
 		}
 	}
 
-	return summary, nil
+	return summary, rows.Err()
 }
 
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// MySQL errors that mean the transaction lost a lock race and can simply be rerun
+const (
+	mysqlErrLockWaitTimeout = 1205
+	mysqlErrDeadlock        = 1213
+)
+
+// TxRetryAttempts is how many times WithRetry runs a transaction before giving up
+const TxRetryAttempts = 3
+
+// txRetryBackoff is the base delay between attempts; attempt n waits up to n times this, jittered
+var txRetryBackoff = 50 * time.Millisecond
+
+// ErrTransactionConflict is returned once a transaction has deadlocked on every attempt
+var ErrTransactionConflict = errors.New("this item is being updated by someone else, please try again")
+
+// IsRetryableTxError reports whether err is a deadlock or lock wait timeout
+func IsRetryableTxError(err error) bool {
+	var myErr *mysql.MySQLError
+	if !errors.As(err, &myErr) {
+		return false
+	}
+	return myErr.Number == mysqlErrDeadlock || myErr.Number == mysqlErrLockWaitTimeout
+}
+
+// WithRetry runs fn, a complete transaction or a single statement, rerunning it with jittered backoff when
+// it fails on a deadlock or lock wait timeout. Other errors are returned as-is; if
+// every attempt deadlocks the caller gets ErrTransactionConflict.
+func WithRetry(fn func() error) error {
+	var err error
+	for attempt := 1; attempt <= TxRetryAttempts; attempt++ {
+		err = fn()
+		if err == nil || !IsRetryableTxError(err) {
+			return err
+		}
+		log.Printf("Transaction attempt %d/%d hit lock contention: %v", attempt, TxRetryAttempts, err)
+		if attempt < TxRetryAttempts && txRetryBackoff > 0 {
+			time.Sleep(time.Duration(attempt)*txRetryBackoff/2 + time.Duration(rand.Int63n(int64(time.Duration(attempt)*txRetryBackoff/2)+1)))
+		}
+	}
+	return ErrTransactionConflict
+}
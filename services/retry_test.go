@@ -0,0 +1,54 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestWithRetry(t *testing.T) {
+	defer func(d time.Duration) { txRetryBackoff = d }(txRetryBackoff)
+	txRetryBackoff = 0
+	deadlock := fmt.Errorf("failed to lock product: %w", &mysql.MySQLError{Number: mysqlErrDeadlock, Message: "Deadlock found"})
+	lockWait := &mysql.MySQLError{Number: mysqlErrLockWaitTimeout, Message: "Lock wait timeout exceeded"}
+
+	t.Run("succeeds after a deadlock", func(t *testing.T) {
+		calls := 0
+		err := WithRetry(func() error {
+			calls++
+			if calls == 1 {
+				return deadlock
+			}
+			return nil
+		})
+		if err != nil || calls != 2 {
+			t.Errorf("err=%v calls=%d, want nil after 2 calls", err, calls)
+		}
+	})
+
+	t.Run("other errors are not retried", func(t *testing.T) {
+		calls := 0
+		notFound := errors.New("product not found")
+		err := WithRetry(func() error {
+			calls++
+			return notFound
+		})
+		if err != notFound || calls != 1 {
+			t.Errorf("err=%v calls=%d, want original error after 1 call", err, calls)
+		}
+	})
+
+	t.Run("gives up with a clean error", func(t *testing.T) {
+		calls := 0
+		err := WithRetry(func() error {
+			calls++
+			return lockWait
+		})
+		if !errors.Is(err, ErrTransactionConflict) || calls != TxRetryAttempts {
+			t.Errorf("err=%v calls=%d, want ErrTransactionConflict after %d calls", err, calls, TxRetryAttempts)
+		}
+	})
+}
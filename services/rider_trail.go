@@ -0,0 +1,54 @@
+package services
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/xashathebest/clovia/config"
+)
+
+// riderTrailPruneInterval is how often finished deliveries lose their breadcrumbs
+const riderTrailPruneInterval = time.Hour
+
+// RiderTrailRetention is how long a delivery's breadcrumbs are kept once it is
+// delivered or cancelled (RIDER_TRAIL_RETENTION)
+func RiderTrailRetention() time.Duration {
+	if d := config.GetEnvDuration("RIDER_TRAIL_RETENTION", 30*24*time.Hour); d > 0 {
+		return d
+	}
+	return 30 * 24 * time.Hour
+}
+
+// StartRiderTrailPruner periodically drops location history of deliveries that
+// finished longer than the retention window ago
+func StartRiderTrailPruner(db *sql.DB) {
+	go func() {
+		ticker := time.NewTicker(riderTrailPruneInterval)
+		defer ticker.Stop()
+		for {
+			if n, err := PruneRiderTrails(db, RiderTrailRetention()); err != nil {
+				log.Printf("rider trail prune error: %v", err)
+			} else if n > 0 {
+				log.Printf("Pruned %d rider location points", n)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// PruneRiderTrails deletes breadcrumbs of delivered or cancelled deliveries whose
+// completion is older than retention. Active deliveries keep their whole trail.
+func PruneRiderTrails(db *sql.DB, retention time.Duration) (int64, error) {
+	res, err := db.Exec(`
+		DELETE h FROM rider_location_history h
+		JOIN deliveries d ON d.id = h.delivery_id
+		WHERE d.status IN ('delivered', 'cancelled')
+		  AND COALESCE(d.delivered_at, d.updated_at) < ?`,
+		time.Now().Add(-retention),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
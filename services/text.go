@@ -0,0 +1,14 @@
+package services
+
+// TruncateRunes cuts s to at most n characters without splitting a multi-byte character.
+// VARCHAR limits count characters, so this is what fits a column of that size.
+func TruncateRunes(s string, n int) string {
+	count := 0
+	for i := range s {
+		if count == n {
+			return s[:i]
+		}
+		count++
+	}
+	return s
+}
@@ -0,0 +1,21 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateRunes(t *testing.T) {
+	if got := TruncateRunes("short", 255); got != "short" {
+		t.Errorf("short string = %q, want it unchanged", got)
+	}
+	long := strings.Repeat("ñ", 300)
+	got := TruncateRunes(long, 255)
+	if !utf8.ValidString(got) || utf8.RuneCountInString(got) != 255 {
+		t.Errorf("truncated to %d runes (valid UTF-8: %v), want 255", utf8.RuneCountInString(got), utf8.ValidString(got))
+	}
+	if got := TruncateRunes("añb", 2); got != "añ" {
+		t.Errorf("TruncateRunes(\"añb\", 2) = %q, want \"añ\"", got)
+	}
+}
@@ -2,17 +2,75 @@ package services
 
 import (
 	"database/sql"
+	"fmt"
 	"log"
 	"time"
+
+	"github.com/xashathebest/clovia/config"
+	"github.com/xashathebest/clovia/models"
 )
 
+// TradeTimeoutSettings control how long a one-sided trade completion waits for the other party
+type TradeTimeoutSettings struct {
+	// ConfirmWindow is how long after the first completion the other party has to confirm
+	// before the trade moves to awaiting_confirmation
+	ConfirmWindow time.Duration
+	// ReminderAfter is when the party who hasn't confirmed gets a reminder (default: halfway)
+	ReminderAfter time.Duration
+	// AutoCompleteAfter is when an unconfirmed trade is completed automatically
+	AutoCompleteAfter time.Duration
+	// CheckInterval is how often the scheduler runs
+	CheckInterval time.Duration
+}
+
+// DefaultTradeTimeoutSettings reads the TRADE_* timeout environment variables
+func DefaultTradeTimeoutSettings() TradeTimeoutSettings {
+	s := TradeTimeoutSettings{
+		ConfirmWindow:     config.GetEnvDuration("TRADE_CONFIRM_WINDOW", 24*time.Hour),
+		AutoCompleteAfter: config.GetEnvDuration("TRADE_AUTO_COMPLETE_AFTER", 48*time.Hour),
+		CheckInterval:     config.GetEnvDuration("TRADE_TIMEOUT_CHECK_INTERVAL", 5*time.Minute),
+	}
+	if s.ConfirmWindow <= 0 {
+		s.ConfirmWindow = 24 * time.Hour
+	}
+	if s.AutoCompleteAfter < s.ConfirmWindow {
+		s.AutoCompleteAfter = 2 * s.ConfirmWindow
+	}
+	if s.CheckInterval <= 0 {
+		s.CheckInterval = 5 * time.Minute
+	}
+	s.ReminderAfter = config.GetEnvDuration("TRADE_CONFIRM_REMINDER_AFTER", s.ConfirmWindow/2)
+	if s.ReminderAfter <= 0 || s.ReminderAfter >= s.ConfirmWindow {
+		s.ReminderAfter = s.ConfirmWindow / 2
+	}
+	return s
+}
+
+// FormatTradeWindow renders a timeout for notification text, e.g. "24 hours" or "90 minutes"
+func FormatTradeWindow(d time.Duration) string {
+	if d >= time.Hour && d%time.Hour == 0 {
+		if d == time.Hour {
+			return "1 hour"
+		}
+		return fmt.Sprintf("%d hours", d/time.Hour)
+	}
+	minutes := int(d.Round(time.Minute) / time.Minute)
+	if minutes == 1 {
+		return "1 minute"
+	}
+	return fmt.Sprintf("%d minutes", minutes)
+}
+
 // StartTradeTimeoutScheduler runs periodic checks to progress trades through two-stage timeout
 func StartTradeTimeoutScheduler(db *sql.DB) {
+	settings := DefaultTradeTimeoutSettings()
+	log.Printf("Trade timeouts: confirm within %s, remind after %s, auto-complete after %s",
+		settings.ConfirmWindow, settings.ReminderAfter, settings.AutoCompleteAfter)
 	go func() {
-		ticker := time.NewTicker(5 * time.Minute)
+		ticker := time.NewTicker(settings.CheckInterval)
 		defer ticker.Stop()
 		for {
-			if err := runTradeTimeoutPass(db); err != nil {
+			if err := runTradeTimeoutPass(db, settings); err != nil {
 				log.Printf("trade timeout pass error: %v", err)
 			}
 			<-ticker.C
@@ -20,7 +78,7 @@ func StartTradeTimeoutScheduler(db *sql.DB) {
 	}()
 }
 
-func runTradeTimeoutPass(db *sql.DB) error {
+func runTradeTimeoutPass(db *sql.DB, settings TradeTimeoutSettings) error {
 	// If the DB doesn't have the expected timeout columns (migrations not applied),
 	// skip the pass to avoid SQL errors. Check for existence of first_completion_at.
 	var cnt int
@@ -32,7 +90,12 @@ func runTradeTimeoutPass(db *sql.DB) error {
 		// migrations not applied; nothing to do for trade timeouts
 		return nil
 	}
-	// Stage 1: Move to awaiting_confirmation after 24h from first_completion_at
+
+	if err := sendTradeConfirmReminders(db, settings); err != nil {
+		log.Printf("trade confirmation reminders failed: %v", err)
+	}
+
+	// Stage 1: Move to awaiting_confirmation once the confirm window has passed since first_completion_at
 	if _, err := db.Exec(`
         UPDATE trades
         SET status = 'awaiting_confirmation', awaiting_confirmation_since = NOW(), updated_at = NOW()
@@ -40,8 +103,8 @@ func runTradeTimeoutPass(db *sql.DB) error {
           AND first_completion_at IS NOT NULL
           AND awaiting_confirmation_since IS NULL
           AND ((buyer_completed = TRUE AND seller_completed = FALSE) OR (buyer_completed = FALSE AND seller_completed = TRUE))
-          AND TIMESTAMPDIFF(HOUR, first_completion_at, NOW()) >= 24
-    `); err != nil {
+          AND TIMESTAMPDIFF(SECOND, first_completion_at, NOW()) >= ?
+    `, int(settings.ConfirmWindow.Seconds())); err != nil {
 		return err
 	}
 
@@ -51,28 +114,30 @@ func runTradeTimeoutPass(db *sql.DB) error {
         SELECT id, buyer_id, seller_id FROM trades
         WHERE status = 'awaiting_confirmation' 
           AND awaiting_confirmation_since IS NOT NULL
-          AND TIMESTAMPDIFF(MINUTE, awaiting_confirmation_since, NOW()) < 10
-    `)
+          AND TIMESTAMPDIFF(SECOND, awaiting_confirmation_since, NOW()) < ?
+    `, int(settings.CheckInterval.Seconds()))
 	if err == nil {
 		defer rows.Close()
+		reminder := fmt.Sprintf("Reminder: Please confirm the trade within %s or it will be completed automatically.",
+			FormatTradeWindow(settings.AutoCompleteAfter-settings.ConfirmWindow))
 		for rows.Next() {
 			var id, buyerID, sellerID int
 			if err := rows.Scan(&id, &buyerID, &sellerID); err == nil {
-				_, _ = db.Exec("INSERT INTO notifications (user_id, type, message, is_read) VALUES (?, 'trade_update', ?, FALSE)", buyerID, "Reminder: Please confirm the trade within 24 hours.")
-				_, _ = db.Exec("INSERT INTO notifications (user_id, type, message, is_read) VALUES (?, 'trade_update', ?, FALSE)", sellerID, "Reminder: Please confirm the trade within 24 hours.")
+				Notify(db, buyerID, "trade_update", reminder, models.NotificationRefTrade, id)
+				Notify(db, sellerID, "trade_update", reminder, models.NotificationRefTrade, id)
 			}
 		}
 	}
 
-	// Stage 2: Auto-complete after 48h from first_completion_at
+	// Stage 2: Auto-complete once AutoCompleteAfter has passed since first_completion_at
 	rows2, err := db.Query(`
         SELECT id FROM trades
         WHERE (status = 'awaiting_confirmation' OR status = 'active')
           AND first_completion_at IS NOT NULL
           AND auto_completed_at IS NULL
           AND ((buyer_completed = TRUE AND seller_completed = FALSE) OR (buyer_completed = FALSE AND seller_completed = TRUE))
-          AND TIMESTAMPDIFF(HOUR, first_completion_at, NOW()) >= 48
-    `)
+          AND TIMESTAMPDIFF(SECOND, first_completion_at, NOW()) >= ?
+    `, int(settings.AutoCompleteAfter.Seconds()))
 	if err != nil {
 		return err
 	}
@@ -80,7 +145,7 @@ func runTradeTimeoutPass(db *sql.DB) error {
 	for rows2.Next() {
 		var tradeID int
 		if err := rows2.Scan(&tradeID); err == nil {
-			if err := autoCompleteTrade(db, tradeID); err != nil {
+			if err := autoCompleteTrade(db, tradeID, settings); err != nil {
 				log.Printf("auto-complete trade %d failed: %v", tradeID, err)
 			}
 		}
@@ -88,7 +153,57 @@ func runTradeTimeoutPass(db *sql.DB) error {
 	return nil
 }
 
-func autoCompleteTrade(db *sql.DB, tradeID int) error {
+// sendTradeConfirmReminders notifies the party who hasn't confirmed a one-sided completion
+// once ReminderAfter has passed. Each trade is reminded once.
+func sendTradeConfirmReminders(db *sql.DB, settings TradeTimeoutSettings) error {
+	rows, err := db.Query(`
+        SELECT id, buyer_id, seller_id, buyer_completed,
+               GREATEST(? - TIMESTAMPDIFF(SECOND, first_completion_at, NOW()), 0)
+        FROM trades
+        WHERE status = 'active'
+          AND first_completion_at IS NOT NULL
+          AND confirm_reminder_sent_at IS NULL
+          AND buyer_completed <> seller_completed
+          AND TIMESTAMPDIFF(SECOND, first_completion_at, NOW()) >= ?
+    `, int(settings.ConfirmWindow.Seconds()), int(settings.ReminderAfter.Seconds()))
+	if err != nil {
+		return err
+	}
+	type reminder struct {
+		tradeID, userID, remaining int
+	}
+	var due []reminder
+	for rows.Next() {
+		var id, buyerID, sellerID, remaining int
+		var buyerCompleted bool
+		if err := rows.Scan(&id, &buyerID, &sellerID, &buyerCompleted, &remaining); err != nil {
+			continue
+		}
+		pending := buyerID
+		if buyerCompleted {
+			pending = sellerID
+		}
+		due = append(due, reminder{tradeID: id, userID: pending, remaining: remaining})
+	}
+	rows.Close()
+
+	for _, r := range due {
+		// Claim the reminder first so overlapping passes don't send it twice
+		res, err := db.Exec("UPDATE trades SET confirm_reminder_sent_at = NOW() WHERE id = ? AND confirm_reminder_sent_at IS NULL", r.tradeID)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			continue
+		}
+		left := time.Duration(r.remaining) * time.Second
+		msg := fmt.Sprintf("The other party marked this trade completed. Please confirm within %s.", FormatTradeWindow(left))
+		Notify(db, r.userID, "trade_update", msg, models.NotificationRefTrade, r.tradeID)
+	}
+	return nil
+}
+
+func autoCompleteTrade(db *sql.DB, tradeID int, settings TradeTimeoutSettings) error {
 	tx, err := db.Begin()
 	if err != nil {
 		return err
@@ -96,7 +211,8 @@ func autoCompleteTrade(db *sql.DB, tradeID int) error {
 	defer tx.Rollback()
 
 	// Lock trade and fetch participants and target
-	var targetProductID, buyerID, sellerID int
+	var targetProductID sql.NullInt64 // NULL once the listing was deleted
+	var buyerID, sellerID int
 	var status string
 	err = tx.QueryRow(`
         SELECT target_product_id, buyer_id, seller_id, status
@@ -106,23 +222,35 @@ func autoCompleteTrade(db *sql.DB, tradeID int) error {
 		return err
 	}
 
-	// Mark all products as traded
-	// target product
-	if _, err := tx.Exec("UPDATE products SET status='traded', updated_at=NOW() WHERE id = ?", targetProductID); err != nil {
-		return err
+	// Collect target and offered products; deleted ones have nothing to mark
+	var productIDs []int
+	if targetProductID.Valid {
+		productIDs = append(productIDs, int(targetProductID.Int64))
 	}
-	// offered products
-	rows, err := tx.Query("SELECT product_id FROM trade_items WHERE trade_id = ?", tradeID)
+	rows, err := tx.Query("SELECT product_id FROM trade_items WHERE trade_id = ? AND product_id IS NOT NULL", tradeID)
 	if err != nil {
 		return err
 	}
-	defer rows.Close()
 	for rows.Next() {
 		var pid int
 		if err := rows.Scan(&pid); err != nil {
+			rows.Close()
+			return err
+		}
+		productIDs = append(productIDs, pid)
+	}
+	rows.Close()
+
+	// Mark all products as traded
+	for _, pid := range productIDs {
+		var fromStatus string
+		if err := tx.QueryRow("SELECT status FROM products WHERE id = ?", pid).Scan(&fromStatus); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE products SET status='traded', version = version + 1, updated_at=NOW() WHERE id = ?", pid); err != nil {
 			return err
 		}
-		if _, err := tx.Exec("UPDATE products SET status='traded', updated_at=NOW() WHERE id = ?", pid); err != nil {
+		if err := RecordProductStatusChange(tx, pid, fromStatus, "traded", 0, fmt.Sprintf("trade #%d auto-completed", tradeID)); err != nil {
 			return err
 		}
 	}
@@ -131,13 +259,17 @@ func autoCompleteTrade(db *sql.DB, tradeID int) error {
 	if _, err := tx.Exec("UPDATE trades SET status='auto_completed', completed_at=NOW(), auto_completed_at=NOW(), updated_at=NOW() WHERE id = ?", tradeID); err != nil {
 		return err
 	}
+	if err := RecordTradeFee(tx, tradeID); err != nil {
+		return err
+	}
 
 	if err := tx.Commit(); err != nil {
 		return err
 	}
 
 	// Notify both users with dispute info
-	_, _ = db.Exec("INSERT INTO notifications (user_id, type, message, is_read) VALUES (?, 'trade_update', ?, FALSE)", buyerID, "Trade auto-completed after 48 hours. If there is an issue, open a dispute.")
-	_, _ = db.Exec("INSERT INTO notifications (user_id, type, message, is_read) VALUES (?, 'trade_update', ?, FALSE)", sellerID, "Trade auto-completed after 48 hours. If there is an issue, open a dispute.")
+	msg := fmt.Sprintf("Trade auto-completed after %s. If there is an issue, open a dispute.", FormatTradeWindow(settings.AutoCompleteAfter))
+	Notify(db, buyerID, "trade_update", msg, models.NotificationRefTrade, tradeID)
+	Notify(db, sellerID, "trade_update", msg, models.NotificationRefTrade, tradeID)
 	return nil
 }
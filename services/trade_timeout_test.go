@@ -0,0 +1,46 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDefaultTradeTimeoutSettings(t *testing.T) {
+	t.Setenv("TRADE_CONFIRM_WINDOW", "6h")
+	t.Setenv("TRADE_AUTO_COMPLETE_AFTER", "")
+	t.Setenv("TRADE_CONFIRM_REMINDER_AFTER", "")
+	s := DefaultTradeTimeoutSettings()
+	if s.ConfirmWindow != 6*time.Hour {
+		t.Errorf("ConfirmWindow = %v, want 6h", s.ConfirmWindow)
+	}
+	if s.ReminderAfter != 3*time.Hour {
+		t.Errorf("ReminderAfter = %v, want halfway (3h)", s.ReminderAfter)
+	}
+	if s.AutoCompleteAfter != 48*time.Hour {
+		t.Errorf("AutoCompleteAfter = %v, want 48h", s.AutoCompleteAfter)
+	}
+
+	t.Setenv("TRADE_CONFIRM_REMINDER_AFTER", "8h")
+	t.Setenv("TRADE_AUTO_COMPLETE_AFTER", "1h")
+	s = DefaultTradeTimeoutSettings()
+	if s.ReminderAfter != 3*time.Hour {
+		t.Errorf("reminder after the window = %v, want halfway fallback", s.ReminderAfter)
+	}
+	if s.AutoCompleteAfter != 12*time.Hour {
+		t.Errorf("auto-complete before the window = %v, want twice the window", s.AutoCompleteAfter)
+	}
+}
+
+func TestFormatTradeWindow(t *testing.T) {
+	cases := map[time.Duration]string{
+		24 * time.Hour:   "24 hours",
+		time.Hour:        "1 hour",
+		90 * time.Minute: "90 minutes",
+		time.Minute:      "1 minute",
+	}
+	for d, want := range cases {
+		if got := FormatTradeWindow(d); got != want {
+			t.Errorf("FormatTradeWindow(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/xashathebest/clovia/config"
+	"github.com/xashathebest/clovia/models"
+	"github.com/xashathebest/clovia/storage"
+)
+
+// ErrUploadListingUnsupported is returned when the storage backend cannot enumerate its files
+var ErrUploadListingUnsupported = errors.New("the storage backend cannot list uploads")
+
+// uploadReference is a column that may hold an upload URL. JSON columns hold an array of
+// URLs; Text columns are free text (chat) where a URL can appear anywhere and are only
+// searched with LIKE.
+type uploadReference struct {
+	Table, Column string
+	JSON, Text    bool
+}
+
+// uploadReferences are every place an uploaded file can be linked from. Deliveries have
+// no attachment column; anything shared about one goes through chat.
+var uploadReferences = []uploadReference{
+	{Table: "products", Column: "image_urls", JSON: true},
+	{Table: "products", Column: "image_url"},
+	{Table: "users", Column: "profile_picture"},
+	{Table: "users", Column: "background_image"},
+	{Table: "users", Column: "org_logo_url"},
+	{Table: "trades", Column: "target_snapshot_image_url"},
+	{Table: "trade_items", Column: "snapshot_image_url"},
+	{Table: "messages", Column: "content", Text: true},
+	{Table: "trade_messages", Column: "content", Text: true},
+}
+
+// UploadCleanupMinAge is how old an unreferenced upload must be before it is deleted, so
+// files of a listing or profile that is still being saved are never caught (UPLOAD_CLEANUP_MIN_AGE)
+func UploadCleanupMinAge() time.Duration {
+	if d := config.GetEnvDuration("UPLOAD_CLEANUP_MIN_AGE", 24*time.Hour); d > 0 {
+		return d
+	}
+	return 24 * time.Hour
+}
+
+// StartUploadCleanupJob deletes orphaned uploads every UPLOAD_CLEANUP_INTERVAL. The job is
+// off unless the interval is set.
+func StartUploadCleanupJob(db *sql.DB, files storage.Storage) {
+	interval := config.GetEnvDuration("UPLOAD_CLEANUP_INTERVAL", 0)
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			<-ticker.C
+			report, err := CleanupOrphanedUploads(db, files, UploadCleanupMinAge(), false)
+			if err != nil {
+				log.Printf("upload cleanup error: %v", err)
+			} else if report.ReclaimedFiles > 0 || len(report.Failed) > 0 {
+				log.Printf("Upload cleanup removed %d file(s), %d bytes; %d failed",
+					report.ReclaimedFiles, report.ReclaimedBytes, len(report.Failed))
+			}
+		}
+	}()
+}
+
+// CleanupOrphanedUploads finds stored files older than minAge that nothing references and,
+// unless dryRun, deletes them. Any error while checking references aborts the pass before
+// more files are removed: a file is only deleted once it is known to be unused.
+func CleanupOrphanedUploads(db *sql.DB, files storage.Storage, minAge time.Duration, dryRun bool) (models.UploadCleanupReport, error) {
+	report := models.UploadCleanupReport{DryRun: dryRun, MinAgeHours: minAge.Hours(), Orphaned: []models.OrphanedUpload{}}
+	lister, ok := files.(storage.Lister)
+	if !ok {
+		return report, ErrUploadListingUnsupported
+	}
+	objects, err := lister.List()
+	if err != nil {
+		return report, err
+	}
+	refs, err := existingUploadReferences(db)
+	if err != nil {
+		return report, err
+	}
+	known, err := referencedUploadKeys(db, refs)
+	if err != nil {
+		return report, err
+	}
+
+	cutoff := time.Now().Add(-minAge)
+	for _, o := range objects {
+		if strings.HasPrefix(path.Base(o.Key), ".") {
+			continue // .gitkeep and the like
+		}
+		report.Scanned++
+		// A reused file is as new as its latest upload, whatever its modification time says
+		if o.ModTime.After(cutoff) || storage.RecentlyClaimed(o.Key) {
+			report.TooRecent++
+			continue
+		}
+		if known[o.Key] {
+			report.Referenced++
+			continue
+		}
+		// Not linked from a URL column; chat text and unusual spellings are searched directly
+		referenced, err := uploadMentioned(db, refs, o.Key)
+		if err != nil {
+			return report, err
+		}
+		if referenced {
+			report.Referenced++
+			continue
+		}
+
+		report.Orphaned = append(report.Orphaned, models.OrphanedUpload{Key: o.Key, Size: o.Size, ModifiedAt: o.ModTime})
+		if !dryRun {
+			// Uploaded again since the scan started: keep it
+			deleted, err := storage.ReleaseUpload(files, o.Key, func() (bool, error) { return false, nil })
+			if err != nil {
+				log.Printf("Failed to delete orphaned upload %s: %v", o.Key, err)
+				report.Failed = append(report.Failed, o.Key)
+				continue
+			}
+			if !deleted {
+				report.Orphaned = report.Orphaned[:len(report.Orphaned)-1]
+				report.TooRecent++
+				continue
+			}
+		}
+		report.ReclaimedFiles++
+		report.ReclaimedBytes += o.Size
+	}
+	return report, nil
+}
+
+// UploadReferenced reports whether anything in uploadReferences still points at key. It is
+// the check run before a replaced or deleted listing's files are removed.
+func UploadReferenced(db *sql.DB, key string) (bool, error) {
+	refs, err := existingUploadReferences(db)
+	if err != nil {
+		return false, err
+	}
+	return uploadMentioned(db, refs, key)
+}
+
+// existingUploadReferences drops reference columns the schema does not have (yet), such
+// as the users image columns on a database that has not been migrated
+func existingUploadReferences(db *sql.DB) ([]uploadReference, error) {
+	rows, err := db.Query(`SELECT TABLE_NAME, COLUMN_NAME FROM information_schema.COLUMNS
+		WHERE TABLE_SCHEMA = DATABASE()
+		  AND TABLE_NAME IN ('products', 'users', 'trades', 'trade_items', 'messages', 'trade_messages')`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	present := map[string]bool{}
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			return nil, err
+		}
+		present[table+"."+column] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// Without the listing images every file would look unused
+	if !present["products.image_urls"] {
+		return nil, errors.New("products.image_urls not found in information_schema")
+	}
+	var refs []uploadReference
+	for _, r := range uploadReferences {
+		if present[r.Table+"."+r.Column] {
+			refs = append(refs, r)
+		}
+	}
+	return refs, nil
+}
+
+// referencedUploadKeys collects the keys of every upload linked from a URL column
+func referencedUploadKeys(db *sql.DB, refs []uploadReference) (map[string]bool, error) {
+	keys := map[string]bool{}
+	for _, r := range refs {
+		if r.Text {
+			continue
+		}
+		rows, err := db.Query("SELECT " + r.Column + " FROM " + r.Table + " WHERE " + r.Column + " IS NOT NULL AND " + r.Column + " <> ''")
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var raw string
+			if err := rows.Scan(&raw); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			urls := []string{raw}
+			if r.JSON {
+				var list []string
+				if json.Unmarshal([]byte(raw), &list) == nil {
+					urls = list
+				}
+			}
+			for _, u := range urls {
+				for _, k := range uploadKeysOf(u) {
+					keys[k] = true
+				}
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// uploadKeysOf returns the storage keys a stored URL may point at: its last path segment,
+// the path below /uploads/, and their unescaped forms
+func uploadKeysOf(u string) []string {
+	candidates := []string{storage.KeyFromURL(u)}
+	if i := strings.Index(u, "/uploads/"); i >= 0 {
+		rest := u[i+len("/uploads/"):]
+		if j := strings.IndexAny(rest, "?#"); j >= 0 {
+			rest = rest[:j]
+		}
+		candidates = append(candidates, rest)
+	}
+	var keys []string
+	seen := map[string]bool{}
+	for _, k := range candidates {
+		for _, form := range []string{k, unescapeUploadKey(k)} {
+			if !seen[form] {
+				seen[form] = true
+				keys = append(keys, form)
+			}
+		}
+	}
+	return keys
+}
+
+func unescapeUploadKey(k string) string {
+	if unescaped, err := url.PathUnescape(k); err == nil {
+		return unescaped
+	}
+	return k
+}
+
+// uploadMentioned reports whether key, plain or URL-escaped, appears in any reference column
+func uploadMentioned(db *sql.DB, refs []uploadReference, key string) (bool, error) {
+	if len(refs) == 0 {
+		return false, nil
+	}
+	spellings := []string{key}
+	if escaped := (&url.URL{Path: key}).EscapedPath(); escaped != key {
+		spellings = append(spellings, escaped)
+	}
+	var clauses []string
+	var args []interface{}
+	for _, r := range refs {
+		for _, s := range spellings {
+			clauses = append(clauses, "EXISTS(SELECT 1 FROM "+r.Table+" WHERE "+r.Column+" LIKE ?)")
+			args = append(args, "%"+s+"%")
+		}
+	}
+	var mentioned bool
+	err := db.QueryRow("SELECT "+strings.Join(clauses, " OR "), args...).Scan(&mentioned)
+	return mentioned, err
+}
@@ -0,0 +1,20 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUploadKeysOf(t *testing.T) {
+	cases := map[string][]string{
+		"/uploads/abc.jpg": {"abc.jpg"},
+		"http://localhost:4000/uploads/avatars/me.png": {"me.png", "avatars/me.png"},
+		"/uploads/images%20(10).jpg?v=2":               {"images%20(10).jpg", "images (10).jpg"},
+		"https://cdn.example.com/bucket/def.webp":      {"def.webp"},
+	}
+	for in, want := range cases {
+		if got := uploadKeysOf(in); !reflect.DeepEqual(got, want) {
+			t.Errorf("uploadKeysOf(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package services
+
+import (
+	"database/sql"
+	"log"
+	"time"
+)
+
+// vacationCheckInterval is how often expired vacation dates are cleared
+const vacationCheckInterval = 15 * time.Minute
+
+// StartVacationScheduler periodically clears vacation dates that have passed so
+// sellers come back without having to switch vacation mode off themselves
+func StartVacationScheduler(db *sql.DB) {
+	go func() {
+		ticker := time.NewTicker(vacationCheckInterval)
+		defer ticker.Stop()
+		for {
+			if n, err := ClearExpiredVacations(db); err != nil {
+				log.Printf("vacation pass error: %v", err)
+			} else if n > 0 {
+				log.Printf("Cleared %d expired seller vacations", n)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// ClearExpiredVacations resets vacation_until for every user whose vacation has ended
+func ClearExpiredVacations(db *sql.DB) (int64, error) {
+	res, err := db.Exec("UPDATE users SET vacation_until = NULL WHERE vacation_until IS NOT NULL AND vacation_until <= NOW()")
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// UploadClaimWindow is how long a file handed out by SaveUpload is kept from being
+// released. It covers the gap between saving the file and committing the row that links
+// it, during which nothing in the database references the file yet.
+const UploadClaimWindow = 10 * time.Minute
+
+// uploadLocks serialise SaveUpload and ReleaseUpload per key; keys share one of a fixed
+// set of mutexes by hash
+var uploadLocks [64]sync.Mutex
+
+func uploadLock(key string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &uploadLocks[h.Sum32()%uint32(len(uploadLocks))]
+}
+
+// uploadClaims records when SaveUpload last handed out each key
+var uploadClaims = struct {
+	sync.Mutex
+	m map[string]time.Time
+}{m: make(map[string]time.Time)}
+
+// claimUpload marks key as just handed out and forgets claims older than the window
+func claimUpload(key string, now time.Time) {
+	uploadClaims.Lock()
+	defer uploadClaims.Unlock()
+	for k, at := range uploadClaims.m {
+		if now.Sub(at) > UploadClaimWindow {
+			delete(uploadClaims.m, k)
+		}
+	}
+	uploadClaims.m[key] = now
+}
+
+// RecentlyClaimed reports whether SaveUpload handed out key within UploadClaimWindow
+func RecentlyClaimed(key string) bool {
+	uploadClaims.Lock()
+	defer uploadClaims.Unlock()
+	at, ok := uploadClaims.m[key]
+	return ok && time.Since(at) <= UploadClaimWindow
+}
+
+// ReleaseUpload deletes key unless SaveUpload handed it out recently or inUse reports it
+// is still referenced, and reports whether it was deleted. The key's lock is held through
+// the check and the delete, so an upload of the same bytes either claims the file first
+// and keeps it, or finds it gone and writes it again.
+func ReleaseUpload(s Storage, key string, inUse func() (bool, error)) (bool, error) {
+	mu := uploadLock(key)
+	mu.Lock()
+	defer mu.Unlock()
+	if RecentlyClaimed(key) {
+		return false, nil
+	}
+	referenced, err := inUse()
+	if err != nil || referenced {
+		return false, err
+	}
+	if err := s.Delete(key); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// errNotFound is returned by HEAD requests for missing objects
+var errNotFound = errors.New("object not found")
+
+// S3 stores files in an S3-compatible bucket (AWS, MinIO, R2, ...) using path-style
+// requests signed with AWS Signature Version 4
+type S3 struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// PublicURL is the base for returned URLs (e.g. a CDN); defaults to Endpoint/Bucket
+	PublicURL string
+	// Client defaults to a client with a 30s timeout
+	Client *http.Client
+}
+
+// Save uploads data with a PUT to Bucket/key
+func (s *S3) Save(key string, data []byte, contentType string) (string, error) {
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	if err := s.do(http.MethodPut, key, data, contentType); err != nil {
+		return "", err
+	}
+	return s.URL(key), nil
+}
+
+// Delete removes Bucket/key; S3 treats missing keys as success
+func (s *S3) Delete(key string) error {
+	return s.do(http.MethodDelete, key, nil, "")
+}
+
+// Exists sends a HEAD request for Bucket/key
+func (s *S3) Exists(key string) (bool, error) {
+	err := s.do(http.MethodHead, key, nil, "")
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, errNotFound) {
+		return false, nil
+	}
+	return false, err
+}
+
+// URL is the public address of an object
+func (s *S3) URL(key string) string {
+	base := s.PublicURL
+	if base == "" {
+		base = s.Endpoint + "/" + s.Bucket
+	}
+	return base + "/" + awsURIEscape(key, false)
+}
+
+func (s *S3) do(method, key string, body []byte, contentType string) error {
+	req, err := http.NewRequest(method, s.Endpoint+"/"+s.Bucket+"/"+awsURIEscape(key, false), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	s.sign(req, body, time.Now().UTC())
+
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound && method == http.MethodHead {
+		return errNotFound
+	}
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("s3 %s %s: %s: %s", method, key, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// sign adds SigV4 headers covering host, x-amz-content-sha256 and x-amz-date
+func (s *S3) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host + "\n" +
+			"x-amz-content-sha256:" + payloadHash + "\n" +
+			"x-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := day + "/" + s.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), day)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKeyID, scope, signedHeaders, signature,
+	))
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// awsURIEscape percent-encodes everything except unreserved characters; slashes are kept
+// unless encodeSlash is set, matching the SigV4 canonical URI rules
+func awsURIEscape(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,290 @@
+// Package storage abstracts where uploaded files live. The local backend writes to
+// UPLOAD_DIR (served by app.Static); the S3 backend targets any S3-compatible service.
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xashathebest/clovia/config"
+)
+
+// Storage saves and removes uploaded files and reports their public URL
+type Storage interface {
+	// Save stores data under key and returns the URL clients should use to fetch it
+	Save(key string, data []byte, contentType string) (string, error)
+	// Delete removes the object stored under key; missing objects are not an error
+	Delete(key string) error
+	// Exists reports whether an object is stored under key
+	Exists(key string) (bool, error)
+	// URL is the public address of key, whether or not it exists
+	URL(key string) string
+}
+
+// Object describes a stored file as returned by Lister
+type Object struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Toucher is implemented by backends that can mark a stored file as just used, so an
+// age-based cleanup does not take a file that was uploaded again
+type Toucher interface {
+	Touch(key string) error
+}
+
+// Lister is implemented by backends that can enumerate what they store
+type Lister interface {
+	List() ([]Object, error)
+}
+
+var (
+	defaultMu      sync.RWMutex
+	defaultStorage Storage
+)
+
+// Init selects the backend from STORAGE_BACKEND (local or s3). Local is the default.
+func Init() error {
+	s, err := NewFromEnv()
+	if err != nil {
+		return err
+	}
+	defaultMu.Lock()
+	defaultStorage = s
+	defaultMu.Unlock()
+	return nil
+}
+
+// LocalBaseURL is the path locally stored uploads are served under
+const LocalBaseURL = "/uploads"
+
+// LocalDir is the directory the local backend stores uploads in (UPLOAD_DIR, default uploads)
+func LocalDir() string {
+	return config.GetEnv("UPLOAD_DIR", "uploads")
+}
+
+// Default returns the configured backend, falling back to local storage when Init was not called
+func Default() Storage {
+	defaultMu.RLock()
+	s := defaultStorage
+	defaultMu.RUnlock()
+	if s != nil {
+		return s
+	}
+	return NewLocal(LocalDir(), LocalBaseURL)
+}
+
+// NewFromEnv builds a backend from environment variables
+func NewFromEnv() (Storage, error) {
+	switch backend := strings.ToLower(config.GetEnv("STORAGE_BACKEND", "local")); backend {
+	case "", "local":
+		return NewLocal(LocalDir(), LocalBaseURL), nil
+	case "s3":
+		s := &S3{
+			Endpoint:        strings.TrimRight(config.GetEnv("S3_ENDPOINT", "https://s3.amazonaws.com"), "/"),
+			Region:          config.GetEnv("S3_REGION", "us-east-1"),
+			Bucket:          config.GetEnv("S3_BUCKET", ""),
+			AccessKeyID:     config.GetEnv("S3_ACCESS_KEY_ID", ""),
+			SecretAccessKey: config.GetEnv("S3_SECRET_ACCESS_KEY", ""),
+			PublicURL:       strings.TrimRight(config.GetEnv("S3_PUBLIC_URL", ""), "/"),
+		}
+		if s.Bucket == "" || s.AccessKeyID == "" || s.SecretAccessKey == "" {
+			return nil, fmt.Errorf("STORAGE_BACKEND=s3 requires S3_BUCKET, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY")
+		}
+		log.Printf("Using S3 storage: bucket %s at %s", s.Bucket, s.Endpoint)
+		return s, nil
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_BACKEND %q (expected local or s3)", backend)
+	}
+}
+
+// contentKeyPattern matches keys produced by ContentKey
+var contentKeyPattern = regexp.MustCompile(`^[0-9a-f]{64}\.[a-z0-9]+$`)
+
+// extensionAliases maps equivalent extensions onto one spelling
+var extensionAliases = map[string]string{".jpeg": ".jpg", ".jpe": ".jpg", ".tif": ".tiff"}
+
+// ContentHash is the hex SHA-256 of a file's bytes, the content part of its ContentKey
+func ContentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ContentKey names a file by the SHA-256 of its bytes plus a normalized extension, so
+// identical uploads always map to the same object. The extension comes from the file
+// name, falling back to the sniffed content type.
+func ContentKey(data []byte, filename string) string {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if alias, ok := extensionAliases[ext]; ok {
+		ext = alias
+	}
+	if ext == "" || len(ext) > 6 || strings.Trim(ext[1:], "abcdefghijklmnopqrstuvwxyz0123456789") != "" {
+		ext = ".bin"
+		if exts, _ := mime.ExtensionsByType(http.DetectContentType(data)); len(exts) > 0 {
+			ext = exts[0]
+			if alias, ok := extensionAliases[ext]; ok {
+				ext = alias
+			}
+		}
+	}
+	return ContentHash(data) + ext
+}
+
+// IsContentKey reports whether key was produced by ContentKey. Only such keys are
+// shared between uploads and safe to garbage-collect by reference counting.
+func IsContentKey(key string) bool {
+	return contentKeyPattern.MatchString(key)
+}
+
+// KeyFromURL returns the object key at the end of a stored file's URL
+func KeyFromURL(fileURL string) string {
+	if i := strings.IndexAny(fileURL, "?#"); i >= 0 {
+		fileURL = fileURL[:i]
+	}
+	return path.Base(fileURL)
+}
+
+// SaveUpload stores a multipart file under its content key. When the same bytes were
+// uploaded before, nothing is written and the existing URL is returned. Either way the key
+// is claimed, so ReleaseUpload leaves it alone while the caller saves the row linking it.
+func SaveUpload(s Storage, file *multipart.FileHeader) (string, error) {
+	f, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, f); err != nil {
+		return "", err
+	}
+	data := buf.Bytes()
+	key := ContentKey(data, file.Filename)
+
+	mu := uploadLock(key)
+	mu.Lock()
+	defer mu.Unlock()
+	exists, err := s.Exists(key)
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		now := time.Now()
+		if t, ok := s.(Toucher); ok {
+			if err := t.Touch(key); err != nil {
+				return "", err
+			}
+		}
+		claimUpload(key, now)
+		return s.URL(key), nil
+	}
+	contentType := file.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	url, err := s.Save(key, data, contentType)
+	if err != nil {
+		return "", err
+	}
+	claimUpload(key, time.Now())
+	return url, nil
+}
+
+// Local stores files in a directory on disk and returns URLs under BaseURL
+type Local struct {
+	Dir     string
+	BaseURL string
+}
+
+// NewLocal returns a local-filesystem backend
+func NewLocal(dir, baseURL string) *Local {
+	return &Local{Dir: dir, BaseURL: strings.TrimRight(baseURL, "/")}
+}
+
+// Save writes data to Dir/key
+func (l *Local) Save(key string, data []byte, contentType string) (string, error) {
+	path := filepath.Join(l.Dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", err
+	}
+	return l.URL(key), nil
+}
+
+// URL is BaseURL/key
+func (l *Local) URL(key string) string {
+	return l.BaseURL + "/" + key
+}
+
+// Exists checks for Dir/key on disk
+func (l *Local) Exists(key string) (bool, error) {
+	_, err := os.Stat(filepath.Join(l.Dir, filepath.FromSlash(key)))
+	if err == nil {
+		return true, nil
+	}
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return false, err
+}
+
+// Touch sets the modification time of Dir/key to now
+func (l *Local) Touch(key string) error {
+	now := time.Now()
+	return os.Chtimes(filepath.Join(l.Dir, filepath.FromSlash(key)), now, now)
+}
+
+// Delete removes Dir/key
+func (l *Local) Delete(key string) error {
+	err := os.Remove(filepath.Join(l.Dir, filepath.FromSlash(key)))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// List walks Dir and returns every file in it, keyed by its slash-separated path under Dir.
+// A missing directory holds nothing.
+func (l *Local) List() ([]Object, error) {
+	var objects []Object
+	err := filepath.WalkDir(l.Dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && p == l.Dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(l.Dir, p)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, Object{Key: filepath.ToSlash(rel), Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	return objects, err
+}
@@ -0,0 +1,218 @@
+package storage
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLocalSaveAndDelete(t *testing.T) {
+	dir := t.TempDir()
+	s := NewLocal(dir, "/uploads/")
+
+	url, err := s.Save("a_photo.jpg", []byte("img"), "image/jpeg")
+	if err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	if url != "/uploads/a_photo.jpg" {
+		t.Errorf("unexpected url %q", url)
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, "a_photo.jpg")); err != nil || string(b) != "img" {
+		t.Fatalf("file not written: %q %v", b, err)
+	}
+	if err := s.Delete("a_photo.jpg"); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if err := s.Delete("a_photo.jpg"); err != nil {
+		t.Errorf("deleting a missing file should not fail: %v", err)
+	}
+}
+
+func TestLocalListWalksSubdirectories(t *testing.T) {
+	dir := t.TempDir()
+	s := NewLocal(dir, "/uploads")
+	s.Save("top.jpg", []byte("a"), "image/jpeg")
+	s.Save("avatars/me.png", []byte("bc"), "image/png")
+
+	objects, err := s.List()
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	sizes := map[string]int64{}
+	for _, o := range objects {
+		sizes[o.Key] = o.Size
+	}
+	if len(sizes) != 2 || sizes["top.jpg"] != 1 || sizes["avatars/me.png"] != 2 {
+		t.Errorf("unexpected listing %v", sizes)
+	}
+
+	if objects, err := NewLocal(filepath.Join(dir, "missing"), "/uploads").List(); err != nil || len(objects) != 0 {
+		t.Errorf("missing dir: got %v, %v; want empty", objects, err)
+	}
+}
+
+func TestContentKeyNormalizesExtension(t *testing.T) {
+	a := ContentKey([]byte("same bytes"), "../../etc/Photo.JPEG")
+	b := ContentKey([]byte("same bytes"), "other.jpg")
+	if a != b {
+		t.Errorf("identical content should share a key: %q vs %q", a, b)
+	}
+	if !IsContentKey(a) || !strings.HasSuffix(a, ".jpg") {
+		t.Errorf("unexpected key %q", a)
+	}
+	if got := ContentKey([]byte("%PDF-1.4"), "noext"); !strings.HasSuffix(got, ".pdf") {
+		t.Errorf("expected sniffed .pdf extension, got %q", got)
+	}
+	if IsContentKey("1700000000_photo.jpg") {
+		t.Error("legacy timestamp keys must not be treated as content keys")
+	}
+	if got := KeyFromURL("http://localhost:4000/uploads/abc.png?v=2"); got != "abc.png" {
+		t.Errorf("unexpected key from url: %q", got)
+	}
+}
+
+// multipartFile builds a FileHeader the way fiber hands uploads to handlers
+func multipartFile(t *testing.T, name string, data []byte) *multipart.FileHeader {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, _ := w.CreateFormFile("image", name)
+	part.Write(data)
+	w.Close()
+	form, err := multipart.NewReader(&body, w.Boundary()).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatalf("failed to build multipart form: %v", err)
+	}
+	return form.File["image"][0]
+}
+
+func TestSaveUploadDeduplicates(t *testing.T) {
+	dir := t.TempDir()
+	s := NewLocal(dir, "/uploads")
+
+	first, err := SaveUpload(s, multipartFile(t, "a.png", []byte("pixels")))
+	if err != nil {
+		t.Fatalf("first upload failed: %v", err)
+	}
+	second, err := SaveUpload(s, multipartFile(t, "b.PNG", []byte("pixels")))
+	if err != nil {
+		t.Fatalf("second upload failed: %v", err)
+	}
+	if first != second {
+		t.Errorf("expected the same url for identical content, got %q and %q", first, second)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("expected one stored file, found %d", len(entries))
+	}
+}
+
+// TestSaveUploadTouchesReusedFile checks a deduplicated upload refreshes the stored file's
+// modification time, which the orphaned-upload cleanup uses as its safety window
+func TestSaveUploadTouchesReusedFile(t *testing.T) {
+	dir := t.TempDir()
+	s := NewLocal(dir, "/uploads")
+	url, err := SaveUpload(s, multipartFile(t, "a.png", []byte("old pixels")))
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+	path := filepath.Join(dir, KeyFromURL(url))
+	old := time.Now().Add(-48 * time.Hour)
+	os.Chtimes(path, old, old)
+
+	if _, err := SaveUpload(s, multipartFile(t, "b.png", []byte("old pixels"))); err != nil {
+		t.Fatalf("re-upload failed: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if time.Since(info.ModTime()) > time.Minute {
+		t.Errorf("modification time = %v, want it refreshed by the re-upload", info.ModTime())
+	}
+}
+
+// TestReleaseUploadKeepsReusedFile re-uploads a file that is about to be released and
+// checks the release leaves it in place until the claim runs out
+func TestReleaseUploadKeepsReusedFile(t *testing.T) {
+	dir := t.TempDir()
+	s := NewLocal(dir, "/uploads")
+	unreferenced := func() (bool, error) { return false, nil }
+
+	url, err := SaveUpload(s, multipartFile(t, "a.png", []byte("shared pixels")))
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+	key := KeyFromURL(url)
+	if deleted, err := ReleaseUpload(s, key, unreferenced); err != nil || deleted {
+		t.Fatalf("release of a just-claimed file = %v, %v; want it kept", deleted, err)
+	}
+
+	// Once the claim has expired an unreferenced file goes
+	uploadClaims.Lock()
+	uploadClaims.m[key] = time.Now().Add(-2 * UploadClaimWindow)
+	uploadClaims.Unlock()
+	if deleted, err := ReleaseUpload(s, key, func() (bool, error) { return true, nil }); err != nil || deleted {
+		t.Fatalf("release of a referenced file = %v, %v; want it kept", deleted, err)
+	}
+	if deleted, err := ReleaseUpload(s, key, unreferenced); err != nil || !deleted {
+		t.Fatalf("release after the claim expired = %v, %v; want it deleted", deleted, err)
+	}
+	if ok, _ := s.Exists(key); ok {
+		t.Error("file still stored after release")
+	}
+}
+
+func TestS3SaveSignsPathStyleRequest(t *testing.T) {
+	var gotPath, gotAuth, gotBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.EscapedPath()
+		gotAuth = r.Header.Get("Authorization")
+		b, _ := io.ReadAll(r.Body)
+		gotBody = string(b)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	s := &S3{Endpoint: srv.URL, Region: "us-east-1", Bucket: "clovia", AccessKeyID: "AKID", SecretAccessKey: "secret"}
+	url, err := s.Save("123_my photo.png", []byte("png"), "image/png")
+	if err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	if gotPath != "/clovia/123_my%20photo.png" {
+		t.Errorf("unexpected request path %q", gotPath)
+	}
+	if !strings.HasPrefix(gotAuth, "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(gotAuth, "/us-east-1/s3/aws4_request") {
+		t.Errorf("unexpected authorization header %q", gotAuth)
+	}
+	if gotBody != "png" {
+		t.Errorf("unexpected body %q", gotBody)
+	}
+	if url != srv.URL+"/clovia/123_my%20photo.png" {
+		t.Errorf("unexpected url %q", url)
+	}
+
+	s.PublicURL = "https://cdn.example.com"
+	if got := s.URL("k.png"); got != "https://cdn.example.com/k.png" {
+		t.Errorf("public url not used: %q", got)
+	}
+}
+
+func TestS3SaveReportsErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "AccessDenied", http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	s := &S3{Endpoint: srv.URL, Region: "us-east-1", Bucket: "clovia", AccessKeyID: "AKID", SecretAccessKey: "secret"}
+	if _, err := s.Save("k.png", []byte("x"), ""); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("expected a 403 error, got %v", err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/xashathebest/clovia/config"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -22,19 +23,58 @@ func CheckPasswordHash(password, hash string) bool {
 	return err == nil
 }
 
+// jwtIssuer is the iss claim set on and required of every token (JWT_ISSUER)
+func jwtIssuer() string {
+	return config.GetEnv("JWT_ISSUER", "clovia")
+}
+
+// jwtExpiry is the access token lifetime (JWT_EXPIRY, e.g. "24h"; defaults to 7 days)
+func jwtExpiry() time.Duration {
+	return config.GetEnvDuration("JWT_EXPIRY", time.Hour*24*7)
+}
+
 // GenerateJWT generates a JWT token for a user
 func GenerateJWT(userID int, email string) (string, error) {
+	now := time.Now()
 	claims := jwt.MapClaims{
 		"user_id": userID,
 		"email":   email,
-		"exp":     time.Now().Add(time.Hour * 24 * 7).Unix(), // 7 days
-		"iat":     time.Now().Unix(),
+		"iss":     jwtIssuer(),
+		"iat":     now.Unix(),
+		"nbf":     now.Unix(),
+		"exp":     now.Add(jwtExpiry()).Unix(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString(jwtSecret)
 }
 
+// impersonationExpiry is the lifetime of admin "view as user" tokens (IMPERSONATION_TOKEN_EXPIRY)
+func impersonationExpiry() time.Duration {
+	return config.GetEnvDuration("IMPERSONATION_TOKEN_EXPIRY", 15*time.Minute)
+}
+
+// GenerateImpersonationJWT mints a short-lived, read-only token that acts as userID.
+// The impersonated_by claim carries the admin's id so the session can be told apart.
+func GenerateImpersonationJWT(userID int, email string, adminID int) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(impersonationExpiry())
+	claims := jwt.MapClaims{
+		"user_id":         userID,
+		"email":           email,
+		"impersonated_by": adminID,
+		"read_only":       true,
+		"iss":             jwtIssuer(),
+		"iat":             now.Unix(),
+		"nbf":             now.Unix(),
+		"exp":             expiresAt.Unix(),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signed, err := token.SignedString(jwtSecret)
+	return signed, expiresAt, err
+}
+
 // ValidateJWT validates a JWT token and returns the claims
 func ValidateJWT(tokenString string) (jwt.MapClaims, error) {
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
@@ -42,7 +82,7 @@ func ValidateJWT(tokenString string) (jwt.MapClaims, error) {
 			return nil, errors.New("unexpected signing method")
 		}
 		return jwtSecret, nil
-	})
+	}, jwt.WithIssuer(jwtIssuer()), jwt.WithExpirationRequired())
 
 	if err != nil {
 		return nil, err
@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func signTestToken(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtSecret)
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return token
+}
+
+func TestGenerateJWTRoundTrip(t *testing.T) {
+	t.Setenv("JWT_EXPIRY", "2h")
+
+	token, err := GenerateJWT(42, "user@wmsu.edu.ph")
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	claims, err := ValidateJWT(token)
+	if err != nil {
+		t.Fatalf("Expected token to validate, got %v", err)
+	}
+	if claims["iss"] != jwtIssuer() {
+		t.Errorf("Expected iss %q, got %v", jwtIssuer(), claims["iss"])
+	}
+	for _, key := range []string{"iat", "nbf"} {
+		if _, ok := claims[key]; !ok {
+			t.Errorf("Expected %s claim to be set", key)
+		}
+	}
+
+	exp, _ := claims.GetExpirationTime()
+	if d := time.Until(exp.Time); d > 2*time.Hour || d < 2*time.Hour-time.Minute {
+		t.Errorf("Expected expiry about 2h from now, got %v", d)
+	}
+}
+
+func TestValidateJWTRejectsInvalidClaims(t *testing.T) {
+	now := time.Now()
+	base := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"user_id": 1,
+			"email":   "user@wmsu.edu.ph",
+			"iss":     jwtIssuer(),
+			"iat":     now.Unix(),
+			"nbf":     now.Unix(),
+			"exp":     now.Add(time.Hour).Unix(),
+		}
+	}
+
+	expired := base()
+	expired["exp"] = now.Add(-time.Minute).Unix()
+
+	notYetValid := base()
+	notYetValid["nbf"] = now.Add(time.Hour).Unix()
+
+	wrongIssuer := base()
+	wrongIssuer["iss"] = "someone-else"
+
+	missingIssuer := base()
+	delete(missingIssuer, "iss")
+
+	cases := map[string]jwt.MapClaims{
+		"expired":        expired,
+		"not yet valid":  notYetValid,
+		"wrong issuer":   wrongIssuer,
+		"missing issuer": missingIssuer,
+	}
+	for name, claims := range cases {
+		if _, err := ValidateJWT(signTestToken(t, claims)); err == nil {
+			t.Errorf("%s: expected token to be rejected", name)
+		}
+	}
+
+	if _, err := ValidateJWT(signTestToken(t, base())); err != nil {
+		t.Errorf("Expected valid token to pass, got %v", err)
+	}
+}
+
+func TestGenerateImpersonationJWT(t *testing.T) {
+	t.Setenv("IMPERSONATION_TOKEN_EXPIRY", "10m")
+
+	token, expiresAt, err := GenerateImpersonationJWT(7, "user@wmsu.edu.ph", 1)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	if d := time.Until(expiresAt); d > 10*time.Minute || d < 9*time.Minute {
+		t.Errorf("Expected ~10m lifetime, got %v", d)
+	}
+
+	claims, err := ValidateJWT(token)
+	if err != nil {
+		t.Fatalf("Expected impersonation token to validate, got %v", err)
+	}
+	if claims["user_id"].(float64) != 7 || claims["impersonated_by"].(float64) != 1 || claims["read_only"] != true {
+		t.Errorf("Unexpected claims: %v", claims)
+	}
+}